@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
 	"monkey/token"
 	"strings"
 )
@@ -11,9 +12,13 @@ type Program struct {
 	Statements []Statement
 }
 
+// Node is a node of the AST. Pos and End are the byte offsets in the parsed
+// source of its first character and just past its last; see Source.
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() int
+	End() int
 }
 
 type Statement interface {
@@ -21,15 +26,60 @@ type Statement interface {
 	statementNode()
 }
 
+// StatementToken returns the token stmt starts at.
+func StatementToken(stmt Statement) token.Token {
+	switch stmt := stmt.(type) {
+	case *LetStatement:
+		return stmt.Token
+	case *DestructuringStatement:
+		return stmt.Token
+	case *ReturnStatement:
+		return stmt.Token
+	case *ExpressionStatement:
+		return stmt.Token
+	case *BlockStatement:
+		return stmt.Token
+	case *WhileStatement:
+		return stmt.Token
+	case *ForStatement:
+		return stmt.Token
+	case *ForInStatement:
+		return stmt.Token
+	case *BreakStatement:
+		return stmt.Token
+	case *ContinueStatement:
+		return stmt.Token
+	default:
+		return token.Token{}
+	}
+}
+
 type Expression interface {
 	Node
 	expressionNode()
 }
 
+// LetStatement is let name = value, or const name = value when Constant,
+// which makes assigning to name an error.
 type LetStatement struct {
-	Token token.Token // the token.LET token
-	Name  *Identifier
-	Value Expression
+	Token    token.Token // the token.LET or token.CONST token
+	Name     *Identifier
+	Value    Expression
+	Constant bool
+}
+
+// DestructuringStatement is let [a, b] = value, which binds each name to
+// the element of value at its position, or let {a, b} = value, which binds
+// each to the value's field of the same name, when Hash. Either may be
+// const, like a LetStatement. The array pattern may be written Bare, as
+// let a, b = value.
+type DestructuringStatement struct {
+	Token    token.Token // the token.LET or token.CONST token
+	Names    []*Identifier
+	Hash     bool
+	Bare     bool
+	Value    Expression
+	Constant bool
 }
 
 type ReturnStatement struct {
@@ -45,11 +95,28 @@ type ExpressionStatement struct {
 type Identifier struct {
 	Token token.Token // the token.IDENT token
 	Value string
+
+	// Location is filled in by the evaluator's resolver. It is nil until
+	// then, and for identifiers that are only ever looked up by name.
+	Location *Location `json:"-"`
+}
+
+// Location is where an identifier's binding lives: Depth environments out
+// from the one the identifier is evaluated in, at index Slot.
+type Location struct {
+	Depth int
+	Slot  int
 }
 
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	Big   *big.Int `json:"-"` // the value, in place of Value, of a literal too wide for an int64
+}
+
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
 }
 
 type PrefixExpression struct {
@@ -65,6 +132,20 @@ type InfixExpression struct {
 	Right    Expression
 }
 
+type AssignExpression struct {
+	Token token.Token // the '=' token
+	Name  *Identifier
+	Value Expression
+}
+
+// PostfixExpression is x++ or x--. It evaluates to the value x had before
+// the update.
+type PostfixExpression struct {
+	Token    token.Token // the '++' or '--' token
+	Operator string
+	Name     *Identifier
+}
+
 type Boolean struct {
 	Token token.Token
 	Value bool
@@ -77,21 +158,119 @@ type IfExpression struct {
 	Alternative *BlockStatement
 }
 
+// ConditionalExpression is Condition ? Consequence : Alternative, which
+// evaluates to one of the two like an if-else does.
+type ConditionalExpression struct {
+	Token       token.Token // the ? token
+	Condition   Expression
+	Consequence Expression
+	Alternative Expression
+}
+
+// SwitchExpression evaluates to the body of the first case with a value
+// equal (==) to Subject, to Default if none matches, or to null if there is
+// no default. Cases do not fall through.
+type SwitchExpression struct {
+	Token   token.Token // the 'switch' token
+	Subject Expression
+	Cases   []*SwitchCase
+	Default *BlockStatement
+	Closing token.Token `json:"-"` // the closing '}'
+}
+
+type SwitchCase struct {
+	Token  token.Token // the 'case' token
+	Values []Expression
+	Body   *BlockStatement
+}
+
+// TryExpression evaluates to Block, or, if a runtime error escapes it, to
+// Catch with the error's message bound to Parameter.
+type TryExpression struct {
+	Token     token.Token // the 'try' token
+	Block     *BlockStatement
+	Parameter *Identifier
+	Catch     *BlockStatement
+}
+
+// WhileStatement is a while loop, or a do-while loop if Do is set, whose
+// body runs once before Condition is first checked. The parser makes
+// loop { ... } into one too, with its token the 'loop' and a Condition of
+// true.
+type WhileStatement struct {
+	Token     token.Token // the 'while', 'do' or 'loop' token
+	Condition Expression
+	Body      *BlockStatement
+	Do        bool
+	Closing   token.Token `json:"-"` // the ')' ending a do-while's condition
+}
+
+// ForStatement is a C-style loop. Init, Condition and Post are all optional
+// and nil when omitted.
+type ForStatement struct {
+	Token     token.Token // the 'for' token
+	Init      Statement
+	Condition Expression
+	Post      Expression
+	Body      *BlockStatement
+}
+
+// ForInStatement runs Body once for each element of Iterable, with Name
+// bound to the element.
+type ForInStatement struct {
+	Token    token.Token // the 'for' token
+	Name     *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+type BreakStatement struct {
+	Token token.Token // the 'break' token
+}
+
+type ContinueStatement struct {
+	Token token.Token // the 'continue' token
+}
+
+// BlockStatement is { statements }, or the statements of a switch case,
+// which start at its ':' token and have no Closing.
 type BlockStatement struct {
 	Token      token.Token // the '{' token
 	Statements []Statement
+	Closing    token.Token `json:"-"` // the closing '}'
 }
 
 type FunctionLiteral struct {
 	Token      token.Token // the fn token
 	Parameters []*Identifier
+	Defaults   []Expression // nil, or for each parameter its default value or nil
+	Variadic   bool         // the last parameter, written ...rest, collects the extra arguments
 	Body       *BlockStatement
+	Name       string // set when the literal is bound by a let statement
 }
 
 type CallExpression struct {
 	Token     token.Token // the "(" token
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+	Named     []*NamedArgument // written after Arguments
+	Pipe      bool             // written x |> f(...), with x as the first argument
+	Closing   token.Token      `json:"-"` // the closing ')', unless written x |> f
+}
+
+// NamedArgument is name: value in a call's arguments, which passes value
+// as the parameter called name.
+type NamedArgument struct {
+	Token token.Token // the name's token
+	Name  string
+	Value Expression
+}
+
+// SpreadExpression is ...value in a call's arguments, which passes the
+// elements of the array value as separate arguments.
+type SpreadExpression struct {
+	Token token.Token // the ... token
+	Value Expression
 }
 
 type StringLiteral struct {
@@ -99,6 +278,84 @@ type StringLiteral struct {
 	Value string
 }
 
+// PropertyName returns the name in left.name, which the parser turns into
+// the index "name", and whether index was written that way.
+func PropertyName(index Expression) (string, bool) {
+	str, ok := index.(*StringLiteral)
+
+	if !ok || str.Token.Type != token.IDENT {
+		return "", false
+	}
+
+	return str.Value, true
+}
+
+// StringInterpolation is a string literal with embedded expressions, such
+// as "Hello, ${name}!". Strings holds the text around them, so it has one
+// more element than Values.
+type StringInterpolation struct {
+	Token   token.Token // the INTERP_START token
+	Strings []string
+	Values  []Expression
+	Closing token.Token `json:"-"` // the INTERP_END token
+}
+
+type ArrayLiteral struct {
+	Token    token.Token // the '[' token
+	Elements []Expression
+	Closing  token.Token `json:"-"` // the closing ']', unless written return a, b
+}
+
+// IndexExpression is left[index]. The parser also reads left.name as
+// left["name"]; see PropertyName. An Optional one, left?[index] or
+// left?.name, is null when left is, without evaluating index.
+type IndexExpression struct {
+	Token    token.Token // the '[', '.', '?[' or '?.' token
+	Left     Expression
+	Index    Expression
+	Optional bool
+	Closing  token.Token `json:"-"` // the closing ']', unless written left.name
+}
+
+// SliceExpression is left[low:high]. Low and High are nil when omitted.
+// Like an index, an Optional slice, left?[low:high], is null when left is.
+type SliceExpression struct {
+	Token    token.Token // the '[' or '?[' token
+	Left     Expression
+	Low      Expression
+	High     Expression
+	Optional bool
+	Closing  token.Token `json:"-"` // the closing ']'
+}
+
+// IndexAssignment is left[index] = value. It updates the array or hash in
+// place, so every binding that refers to it sees the change.
+type IndexAssignment struct {
+	Token token.Token // the '=' token
+	Left  Expression
+	Index Expression
+	Value Expression
+}
+
+type MacroLiteral struct {
+	Token      token.Token // the 'macro' token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+// HashLiteral keeps its pairs in source order so that evaluation and
+// iteration follow the order they were written in.
+type HashLiteral struct {
+	Token   token.Token // the '{' token
+	Pairs   []HashPair
+	Closing token.Token `json:"-"` // the closing '}'
+}
+
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
 func (p *Program) TokenLiteral() string {
 	if len(p.Statements) > 0 {
 		return p.Statements[0].TokenLiteral()
@@ -120,7 +377,7 @@ func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
-	out.WriteString(ls.TokenLiteral() + "")
+	out.WriteString(ls.TokenLiteral() + " ")
 	out.WriteString(ls.Name.String())
 	out.WriteString(" = ")
 
@@ -132,6 +389,37 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+func (ds *DestructuringStatement) statementNode()       {}
+func (ds *DestructuringStatement) TokenLiteral() string { return ds.Token.Literal }
+func (ds *DestructuringStatement) String() string {
+	var out bytes.Buffer
+
+	names := []string{}
+
+	for _, name := range ds.Names {
+		names = append(names, name.String())
+	}
+
+	opening, closing := "[", "]"
+
+	if ds.Hash {
+		opening, closing = "{", "}"
+	} else if ds.Bare {
+		opening, closing = "", ""
+	}
+
+	out.WriteString(ds.TokenLiteral() + " ")
+	out.WriteString(opening + strings.Join(names, ", ") + closing)
+	out.WriteString(" = ")
+
+	if ds.Value != nil {
+		out.WriteString(ds.Value.String())
+	}
+	out.WriteString(";")
+
+	return out.String()
+}
+
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
 func (i *Identifier) String() string       { return i.Value }
@@ -140,7 +428,7 @@ func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
-	out.WriteString(rs.TokenLiteral() + "")
+	out.WriteString(rs.TokenLiteral() + " ")
 
 	if rs.ReturnValue != nil {
 		out.WriteString(rs.ReturnValue.String())
@@ -165,6 +453,10 @@ func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
 func (pe *PrefixExpression) String() string {
@@ -192,10 +484,68 @@ func (ie *InfixExpression) String() string {
 	return out.String()
 }
 
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ae.Name.String())
+	out.WriteString(" = ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+func (pe *PostfixExpression) expressionNode()      {}
+func (pe *PostfixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PostfixExpression) String() string {
+	return "(" + pe.Name.String() + pe.Operator + ")"
+}
+
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
+func (ce *ConditionalExpression) expressionNode()      {}
+func (ce *ConditionalExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *ConditionalExpression) String() string {
+	return "(" + ce.Condition.String() + " ? " + ce.Consequence.String() + " : " + ce.Alternative.String() + ")"
+}
+
+func (se *SwitchExpression) expressionNode()      {}
+func (se *SwitchExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SwitchExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("switch")
+	out.WriteString(se.Subject.String())
+	out.WriteString(" {")
+
+	for _, c := range se.Cases {
+		values := []string{}
+
+		for _, v := range c.Values {
+			values = append(values, v.String())
+		}
+
+		out.WriteString(" case ")
+		out.WriteString(strings.Join(values, ", "))
+		out.WriteString(": ")
+		out.WriteString(c.Body.String())
+	}
+
+	if se.Default != nil {
+		out.WriteString(" default: ")
+		out.WriteString(se.Default.String())
+	}
+
+	out.WriteString(" }")
+
+	return out.String()
+}
+
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
 func (ie *IfExpression) String() string {
@@ -213,6 +563,81 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("try ")
+	out.WriteString(te.Block.String())
+	out.WriteString(" catch(")
+	out.WriteString(te.Parameter.String())
+	out.WriteString(") ")
+	out.WriteString(te.Catch.String())
+
+	return out.String()
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) String() string {
+	switch {
+	case ws.Do:
+		return "do " + ws.Body.String() + " while" + ws.Condition.String()
+	case ws.Token.Type == token.LOOP:
+		return "loop " + ws.Body.String()
+	}
+
+	var out bytes.Buffer
+	out.WriteString("while")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+
+	return out.String()
+}
+
+func (fs *ForInStatement) statementNode()       {}
+func (fs *ForInStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForInStatement) String() string {
+	return "for (" + fs.Name.String() + " in " + fs.Iterable.String() + ") " + fs.Body.String()
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("for (")
+
+	if fs.Init != nil {
+		out.WriteString(strings.TrimSuffix(fs.Init.String(), ";"))
+	}
+
+	out.WriteString("; ")
+
+	if fs.Condition != nil {
+		out.WriteString(fs.Condition.String())
+	}
+
+	out.WriteString("; ")
+
+	if fs.Post != nil {
+		out.WriteString(fs.Post.String())
+	}
+
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) String() string       { return bs.Token.Literal + ";" }
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) String() string       { return cs.Token.Literal + ";" }
+
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
 func (bs *BlockStatement) String() string {
@@ -231,8 +656,18 @@ func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 	params := []string{}
 
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+	for i, p := range fl.Parameters {
+		param := p.String()
+
+		if i < len(fl.Defaults) && fl.Defaults[i] != nil {
+			param += " = " + fl.Defaults[i].String()
+		}
+
+		params = append(params, param)
+	}
+
+	if fl.Variadic {
+		params[len(params)-1] = "..." + params[len(params)-1]
 	}
 
 	out.WriteString(fl.TokenLiteral())
@@ -244,6 +679,25 @@ func (fl *FunctionLiteral) String() string {
 	return out.String()
 }
 
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) String() string {
+	var out bytes.Buffer
+	params := []string{}
+
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(ml.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(")")
+	out.WriteString(ml.Body.String())
+
+	return out.String()
+}
+
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
 func (ce *CallExpression) String() string {
@@ -255,14 +709,163 @@ func (ce *CallExpression) String() string {
 		args = append(args, a.String())
 	}
 
+	for _, a := range ce.Named {
+		args = append(args, a.String())
+	}
+
+	if ce.Pipe {
+		out.WriteString("(" + args[0] + " |> ")
+		args = args[1:]
+	}
+
 	out.WriteString(ce.Function.String())
 	out.WriteString("(")
 	out.WriteString(strings.Join(args, ", "))
 	out.WriteString(")")
 
+	if ce.Pipe {
+		out.WriteString(")")
+	}
+
 	return out.String()
 }
 
+func (na *NamedArgument) TokenLiteral() string { return na.Token.Literal }
+func (na *NamedArgument) String() string       { return na.Name + ": " + na.Value.String() }
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) String() string       { return "..." + se.Value.String() }
+
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+
+func (si *StringInterpolation) expressionNode()      {}
+func (si *StringInterpolation) TokenLiteral() string { return si.Token.Literal }
+func (si *StringInterpolation) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(si.Strings[0])
+
+	for i, value := range si.Values {
+		out.WriteString("${")
+		out.WriteString(value.String())
+		out.WriteString("}")
+		out.WriteString(si.Strings[i+1])
+	}
+
+	return out.String()
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+
+	for _, el := range al.Elements {
+		elements = append(elements, el.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+func (hl *HashLiteral) expressionNode()      {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+
+	if ie.Optional {
+		out.WriteString("?")
+	}
+
+	if name, ok := PropertyName(ie.Index); ok {
+		out.WriteString("." + name + ")")
+
+		return out.String()
+	}
+
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
+
+	return out.String()
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+
+	if se.Optional {
+		out.WriteString("?")
+	}
+
+	out.WriteString("[")
+
+	if se.Low != nil {
+		out.WriteString(se.Low.String())
+	}
+
+	out.WriteString(":")
+
+	if se.High != nil {
+		out.WriteString(se.High.String())
+	}
+
+	out.WriteString("])")
+
+	return out.String()
+}
+
+func (ia *IndexAssignment) expressionNode()      {}
+func (ia *IndexAssignment) TokenLiteral() string { return ia.Token.Literal }
+func (ia *IndexAssignment) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ia.Left.String())
+
+	if name, ok := PropertyName(ia.Index); ok {
+		out.WriteString("." + name + " = ")
+	} else {
+		out.WriteString("[")
+		out.WriteString(ia.Index.String())
+		out.WriteString("] = ")
+	}
+
+	out.WriteString(ia.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
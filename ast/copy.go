@@ -0,0 +1,63 @@
+package ast
+
+import "reflect"
+
+// Copy returns a deep copy of node, so that Modify can rewrite the copy
+// and leave node as it was.
+func Copy(node Node) Node {
+	if node == nil {
+		return nil
+	}
+
+	copied, _ := copyValue(reflect.ValueOf(node)).Interface().(Node)
+
+	return copied
+}
+
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(copyValue(v.Elem()))
+
+		return copied
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+
+		copied := reflect.New(v.Type().Elem())
+		copied.Elem().Set(copyValue(v.Elem()))
+
+		return copied
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(copyValue(v.Index(i)))
+		}
+
+		return copied
+	case reflect.Struct:
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(v)
+
+		for i := 0; i < v.NumField(); i++ {
+			if copied.Field(i).CanSet() {
+				copied.Field(i).Set(copyValue(v.Field(i)))
+			}
+		}
+
+		return copied
+	default:
+		return v
+	}
+}
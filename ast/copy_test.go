@@ -0,0 +1,40 @@
+package ast
+
+import (
+	"monkey/token"
+	"reflect"
+	"testing"
+)
+
+func TestCopy(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Token: token.Token{Literal: "1"}, Value: 1} }
+
+	original := &CallExpression{
+		Function:  &Identifier{Value: "f"},
+		Arguments: []Expression{one(), &ArrayLiteral{Elements: []Expression{one()}}},
+		Named:     []*NamedArgument{{Name: "x", Value: one()}},
+	}
+
+	copied := Copy(original)
+
+	if !reflect.DeepEqual(copied, original) {
+		t.Fatalf("copy differs. got=%#v, want=%#v", copied, original)
+	}
+
+	Modify(copied, func(node Node) Node {
+		if integer, ok := node.(*IntegerLiteral); ok {
+			integer.Value = 2
+			integer.Token.Literal = "2"
+		}
+
+		return node
+	})
+
+	if original.String() != "f(1, [1], x: 1)" {
+		t.Errorf("modifying the copy changed the original to %s", original.String())
+	}
+
+	if copied.String() != "f(2, [2], x: 2)" {
+		t.Errorf("wrong copy after modifying it. got=%s", copied.String())
+	}
+}
@@ -0,0 +1,79 @@
+package ast
+
+import (
+	"encoding/json"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ToJSON encodes node as indented JSON for external tools. Every node
+// becomes an object with a "node" field naming its type, "line" and
+// "column" from its token, and one field per child or value, named after the
+// struct field with a lower-case first letter. Omitted children are null,
+// and fields tagged `json:"-"` are left out. An integer literal too wide
+// for an int64 still has its value as a JSON number.
+func ToJSON(node Node) ([]byte, error) {
+	return json.MarshalIndent(jsonValue(reflect.ValueOf(node)), "", "  ")
+}
+
+func jsonValue(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+
+		return jsonValue(v.Elem())
+	case reflect.Slice:
+		elements := make([]interface{}, v.Len())
+
+		for i := range elements {
+			elements[i] = jsonValue(v.Index(i))
+		}
+
+		return elements
+	case reflect.Struct:
+		return jsonObject(v)
+	default:
+		return v.Interface()
+	}
+}
+
+func jsonObject(v reflect.Value) map[string]interface{} {
+	object := make(map[string]interface{})
+
+	if reflect.PointerTo(v.Type()).Implements(reflect.TypeOf((*Node)(nil)).Elem()) {
+		object["node"] = v.Type().Name()
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+
+		if field.Tag.Get("json") == "-" {
+			continue
+		}
+
+		if field.Name == "Token" {
+			tok := v.Field(i)
+			object["line"] = tok.FieldByName("Line").Interface()
+			object["column"] = tok.FieldByName("Column").Interface()
+
+			continue
+		}
+
+		object[lowerFirst(field.Name)] = jsonValue(v.Field(i))
+	}
+
+	if wide, ok := v.Interface().(IntegerLiteral); ok && wide.Big != nil {
+		object["value"] = json.Number(wide.Big.String())
+	}
+
+	return object
+}
+
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+
+	return string(unicode.ToLower(r)) + s[size:]
+}
@@ -0,0 +1,101 @@
+package ast
+
+import (
+	"encoding/json"
+	"monkey/token"
+	"reflect"
+	"testing"
+)
+
+func TestToJSON(t *testing.T) {
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let", Line: 1, Column: 1},
+				Name: &Identifier{
+					Token: token.Token{Type: token.IDENT, Literal: "x", Line: 1, Column: 5},
+					Value: "x",
+				},
+				Value: &SliceExpression{
+					Token: token.Token{Type: token.LBRACKET, Literal: "[", Line: 1, Column: 10},
+					Left: &Identifier{
+						Token: token.Token{Type: token.IDENT, Literal: "a", Line: 1, Column: 9},
+						Value: "a",
+					},
+					Low: &IntegerLiteral{
+						Token: token.Token{Type: token.INT, Literal: "1", Line: 1, Column: 11},
+						Value: 1,
+					},
+				},
+			},
+		},
+	}
+
+	encoded, err := ToJSON(program)
+
+	if err != nil {
+		t.Fatalf("ToJSON returned an error: %s", err)
+	}
+
+	var actual interface{}
+
+	if err := json.Unmarshal(encoded, &actual); err != nil {
+		t.Fatalf("ToJSON produced invalid JSON: %s", err)
+	}
+
+	var expected interface{}
+
+	err = json.Unmarshal([]byte(`{
+		"node": "Program",
+		"statements": [{
+			"node": "LetStatement", "line": 1, "column": 1,
+			"name": {"node": "Identifier", "line": 1, "column": 5, "value": "x"},
+			"constant": false,
+			"value": {
+				"node": "SliceExpression", "line": 1, "column": 10,
+				"left": {"node": "Identifier", "line": 1, "column": 9, "value": "a"},
+				"low": {"node": "IntegerLiteral", "line": 1, "column": 11, "value": 1},
+				"high": null,
+				"optional": false
+			}
+		}]
+	}`), &expected)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("wrong JSON.\nexpected=%v\ngot=%s", expected, encoded)
+	}
+}
+
+func TestToJSONHashPairs(t *testing.T) {
+	hash := &HashLiteral{
+		Pairs: []HashPair{
+			{Key: &StringLiteral{Value: "a"}, Value: &Boolean{Value: true}},
+		},
+	}
+
+	encoded, err := ToJSON(hash)
+
+	if err != nil {
+		t.Fatalf("ToJSON returned an error: %s", err)
+	}
+
+	var actual map[string]interface{}
+
+	if err := json.Unmarshal(encoded, &actual); err != nil {
+		t.Fatalf("ToJSON produced invalid JSON: %s", err)
+	}
+
+	pair := actual["pairs"].([]interface{})[0].(map[string]interface{})
+
+	if _, ok := pair["node"]; ok {
+		t.Errorf("HashPair is not a node but was given a node type")
+	}
+
+	if pair["key"].(map[string]interface{})["node"] != "StringLiteral" {
+		t.Errorf("wrong key. got=%v", pair["key"])
+	}
+}
@@ -0,0 +1,171 @@
+package ast
+
+type ModifierFunc func(Node) Node
+
+// Modify walks node depth-first, replacing every child with the result of
+// modifier before calling modifier on node itself.
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch node := node.(type) {
+
+	case *Program:
+		for i, statement := range node.Statements {
+			node.Statements[i], _ = Modify(statement, modifier).(Statement)
+		}
+
+	case *ExpressionStatement:
+		node.Expression, _ = Modify(node.Expression, modifier).(Expression)
+
+	case *InfixExpression:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
+		node.Right, _ = Modify(node.Right, modifier).(Expression)
+
+	case *PrefixExpression:
+		node.Right, _ = Modify(node.Right, modifier).(Expression)
+
+	case *SpreadExpression:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+
+	case *PostfixExpression:
+		node.Name, _ = Modify(node.Name, modifier).(*Identifier)
+
+	case *CallExpression:
+		node.Function, _ = Modify(node.Function, modifier).(Expression)
+
+		for i := range node.Arguments {
+			node.Arguments[i], _ = Modify(node.Arguments[i], modifier).(Expression)
+		}
+
+		for i := range node.Named {
+			node.Named[i], _ = Modify(node.Named[i], modifier).(*NamedArgument)
+		}
+
+	case *NamedArgument:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+
+	case *AssignExpression:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+
+	case *IndexExpression:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
+		node.Index, _ = Modify(node.Index, modifier).(Expression)
+
+	case *SliceExpression:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
+
+		if node.Low != nil {
+			node.Low, _ = Modify(node.Low, modifier).(Expression)
+		}
+
+		if node.High != nil {
+			node.High, _ = Modify(node.High, modifier).(Expression)
+		}
+
+	case *IndexAssignment:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
+		node.Index, _ = Modify(node.Index, modifier).(Expression)
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+
+	case *IfExpression:
+		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		node.Consequence, _ = Modify(node.Consequence, modifier).(*BlockStatement)
+
+		if node.Alternative != nil {
+			node.Alternative, _ = Modify(node.Alternative, modifier).(*BlockStatement)
+		}
+
+	case *ConditionalExpression:
+		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		node.Consequence, _ = Modify(node.Consequence, modifier).(Expression)
+		node.Alternative, _ = Modify(node.Alternative, modifier).(Expression)
+
+	case *TryExpression:
+		node.Block, _ = Modify(node.Block, modifier).(*BlockStatement)
+		node.Catch, _ = Modify(node.Catch, modifier).(*BlockStatement)
+
+	case *SwitchExpression:
+		node.Subject, _ = Modify(node.Subject, modifier).(Expression)
+
+		for _, c := range node.Cases {
+			for i := range c.Values {
+				c.Values[i], _ = Modify(c.Values[i], modifier).(Expression)
+			}
+
+			c.Body, _ = Modify(c.Body, modifier).(*BlockStatement)
+		}
+
+		if node.Default != nil {
+			node.Default, _ = Modify(node.Default, modifier).(*BlockStatement)
+		}
+
+	case *WhileStatement:
+		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+
+	case *ForInStatement:
+		node.Iterable, _ = Modify(node.Iterable, modifier).(Expression)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+
+	case *ForStatement:
+		if node.Init != nil {
+			node.Init, _ = Modify(node.Init, modifier).(Statement)
+		}
+
+		if node.Condition != nil {
+			node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		}
+
+		if node.Post != nil {
+			node.Post, _ = Modify(node.Post, modifier).(Expression)
+		}
+
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+
+	case *BlockStatement:
+		for i := range node.Statements {
+			node.Statements[i], _ = Modify(node.Statements[i], modifier).(Statement)
+		}
+
+	case *ReturnStatement:
+		node.ReturnValue, _ = Modify(node.ReturnValue, modifier).(Expression)
+
+	case *LetStatement:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+
+	case *DestructuringStatement:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+
+	case *FunctionLiteral:
+		for i := range node.Parameters {
+			node.Parameters[i], _ = Modify(node.Parameters[i], modifier).(*Identifier)
+		}
+
+		for i := range node.Defaults {
+			if node.Defaults[i] != nil {
+				node.Defaults[i], _ = Modify(node.Defaults[i], modifier).(Expression)
+			}
+		}
+
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+
+	case *StringInterpolation:
+		for i := range node.Values {
+			node.Values[i], _ = Modify(node.Values[i], modifier).(Expression)
+		}
+
+	case *ArrayLiteral:
+		for i := range node.Elements {
+			node.Elements[i], _ = Modify(node.Elements[i], modifier).(Expression)
+		}
+
+	case *HashLiteral:
+		for i, pair := range node.Pairs {
+			newKey, _ := Modify(pair.Key, modifier).(Expression)
+			newValue, _ := Modify(pair.Value, modifier).(Expression)
+
+			node.Pairs[i] = HashPair{Key: newKey, Value: newValue}
+		}
+
+	}
+
+	return modifier(node)
+}
@@ -0,0 +1,202 @@
+package ast
+
+import "monkey/token"
+
+// Source returns the text of src, the source node was parsed from, that
+// node spans. A node the parser did not make, such as one a macro or the
+// optimizer built, may have no position, and its text is then "".
+func Source(src string, node Node) string {
+	start, end := node.Pos(), node.End()
+
+	if start < 0 || end > len(src) || start >= end {
+		return ""
+	}
+
+	return src[start:end]
+}
+
+// closingEnd returns where the node ends: past closing, the token that
+// closes it, if the parser recorded one, or else past last.
+func closingEnd(closing token.Token, last Node) int {
+	if closing.End > 0 || last == nil {
+		return closing.End
+	}
+
+	return last.End()
+}
+
+// endOf returns where node ends, or fallback if the node is missing, as the
+// value of a let the parser could not read is.
+func endOf(node Node, fallback int) int {
+	if node == nil {
+		return fallback
+	}
+
+	return node.End()
+}
+
+// startOf returns where node starts, or where tok does if the node is
+// missing.
+func startOf(node Node, tok token.Token) int {
+	if node == nil {
+		return tok.Offset
+	}
+
+	return node.Pos()
+}
+
+func (p *Program) Pos() int {
+	if len(p.Statements) == 0 {
+		return 0
+	}
+
+	return p.Statements[0].Pos()
+}
+
+func (p *Program) End() int {
+	if len(p.Statements) == 0 {
+		return 0
+	}
+
+	return p.Statements[len(p.Statements)-1].End()
+}
+
+func (ls *LetStatement) Pos() int { return ls.Token.Offset }
+func (ls *LetStatement) End() int { return endOf(ls.Value, ls.Name.End()) }
+
+func (ds *DestructuringStatement) Pos() int { return ds.Token.Offset }
+func (ds *DestructuringStatement) End() int { return endOf(ds.Value, ds.Token.End) }
+
+func (rs *ReturnStatement) Pos() int { return rs.Token.Offset }
+func (rs *ReturnStatement) End() int { return endOf(rs.ReturnValue, rs.Token.End) }
+
+func (es *ExpressionStatement) Pos() int { return startOf(es.Expression, es.Token) }
+func (es *ExpressionStatement) End() int { return endOf(es.Expression, es.Token.End) }
+
+func (i *Identifier) Pos() int { return i.Token.Offset }
+func (i *Identifier) End() int { return i.Token.End }
+
+func (il *IntegerLiteral) Pos() int { return il.Token.Offset }
+func (il *IntegerLiteral) End() int { return il.Token.End }
+
+func (fl *FloatLiteral) Pos() int { return fl.Token.Offset }
+func (fl *FloatLiteral) End() int { return fl.Token.End }
+
+func (b *Boolean) Pos() int { return b.Token.Offset }
+func (b *Boolean) End() int { return b.Token.End }
+
+func (sl *StringLiteral) Pos() int { return sl.Token.Offset }
+func (sl *StringLiteral) End() int { return sl.Token.End }
+
+func (pe *PrefixExpression) Pos() int { return pe.Token.Offset }
+func (pe *PrefixExpression) End() int { return endOf(pe.Right, pe.Token.End) }
+
+func (ie *InfixExpression) Pos() int { return startOf(ie.Left, ie.Token) }
+func (ie *InfixExpression) End() int { return endOf(ie.Right, ie.Token.End) }
+
+func (ae *AssignExpression) Pos() int { return ae.Name.Pos() }
+func (ae *AssignExpression) End() int { return endOf(ae.Value, ae.Token.End) }
+
+func (pe *PostfixExpression) Pos() int { return pe.Name.Pos() }
+func (pe *PostfixExpression) End() int { return pe.Token.End }
+
+func (ie *IfExpression) Pos() int { return ie.Token.Offset }
+func (ie *IfExpression) End() int {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+
+	return ie.Consequence.End()
+}
+
+func (ce *ConditionalExpression) Pos() int { return startOf(ce.Condition, ce.Token) }
+func (ce *ConditionalExpression) End() int { return endOf(ce.Alternative, ce.Token.End) }
+
+func (se *SwitchExpression) Pos() int { return se.Token.Offset }
+func (se *SwitchExpression) End() int { return se.Closing.End }
+
+func (sc *SwitchCase) Pos() int { return sc.Token.Offset }
+func (sc *SwitchCase) End() int { return sc.Body.End() }
+
+func (te *TryExpression) Pos() int { return te.Token.Offset }
+func (te *TryExpression) End() int { return te.Catch.End() }
+
+func (ws *WhileStatement) Pos() int { return ws.Token.Offset }
+func (ws *WhileStatement) End() int { return max(ws.Closing.End, ws.Body.End()) }
+
+func (fs *ForStatement) Pos() int { return fs.Token.Offset }
+func (fs *ForStatement) End() int { return fs.Body.End() }
+
+func (fs *ForInStatement) Pos() int { return fs.Token.Offset }
+func (fs *ForInStatement) End() int { return fs.Body.End() }
+
+func (bs *BreakStatement) Pos() int { return bs.Token.Offset }
+func (bs *BreakStatement) End() int { return bs.Token.End }
+
+func (cs *ContinueStatement) Pos() int { return cs.Token.Offset }
+func (cs *ContinueStatement) End() int { return cs.Token.End }
+
+func (bs *BlockStatement) Pos() int { return bs.Token.Offset }
+func (bs *BlockStatement) End() int {
+	if bs.Closing.End > 0 || len(bs.Statements) == 0 {
+		return max(bs.Closing.End, bs.Token.End)
+	}
+
+	return bs.Statements[len(bs.Statements)-1].End()
+}
+
+func (fl *FunctionLiteral) Pos() int { return fl.Token.Offset }
+func (fl *FunctionLiteral) End() int { return fl.Body.End() }
+
+func (ml *MacroLiteral) Pos() int { return ml.Token.Offset }
+func (ml *MacroLiteral) End() int { return ml.Body.End() }
+
+// A piped call starts at the value piped into it, its first argument.
+func (ce *CallExpression) Pos() int {
+	if ce.Pipe && len(ce.Arguments) > 0 {
+		return ce.Arguments[0].Pos()
+	}
+
+	return ce.Function.Pos()
+}
+
+func (ce *CallExpression) End() int { return closingEnd(ce.Closing, ce.Function) }
+
+func (na *NamedArgument) Pos() int { return na.Token.Offset }
+func (na *NamedArgument) End() int { return endOf(na.Value, na.Token.End) }
+
+func (se *SpreadExpression) Pos() int { return se.Token.Offset }
+func (se *SpreadExpression) End() int { return endOf(se.Value, se.Token.End) }
+
+func (si *StringInterpolation) Pos() int { return si.Token.Offset }
+func (si *StringInterpolation) End() int { return si.Closing.End }
+
+// An ArrayLiteral the parser made of return a, b starts at its first
+// element, not at its token, the return.
+func (al *ArrayLiteral) Pos() int {
+	if al.Token.Type == token.RETURN && len(al.Elements) > 0 {
+		return al.Elements[0].Pos()
+	}
+
+	return al.Token.Offset
+}
+
+func (al *ArrayLiteral) End() int {
+	if al.Closing.End > 0 || len(al.Elements) == 0 {
+		return max(al.Closing.End, al.Token.End)
+	}
+
+	return al.Elements[len(al.Elements)-1].End()
+}
+
+func (hl *HashLiteral) Pos() int { return hl.Token.Offset }
+func (hl *HashLiteral) End() int { return hl.Closing.End }
+
+func (ie *IndexExpression) Pos() int { return ie.Left.Pos() }
+func (ie *IndexExpression) End() int { return closingEnd(ie.Closing, ie.Index) }
+
+func (se *SliceExpression) Pos() int { return se.Left.Pos() }
+func (se *SliceExpression) End() int { return se.Closing.End }
+
+func (ia *IndexAssignment) Pos() int { return ia.Left.Pos() }
+func (ia *IndexAssignment) End() int { return endOf(ia.Value, ia.Token.End) }
@@ -0,0 +1,155 @@
+package ast
+
+// Inspect walks node depth-first in source order, calling f on node and
+// then on each of its children. Children are skipped when f returns false.
+// Omitted parts, such as a missing else block, are not visited.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		inspectStatements(node.Statements, f)
+	case *LetStatement:
+		Inspect(node.Name, f)
+		inspectExpression(node.Value, f)
+	case *DestructuringStatement:
+		for _, name := range node.Names {
+			Inspect(name, f)
+		}
+
+		inspectExpression(node.Value, f)
+	case *ReturnStatement:
+		inspectExpression(node.ReturnValue, f)
+	case *ExpressionStatement:
+		inspectExpression(node.Expression, f)
+	case *BlockStatement:
+		inspectStatements(node.Statements, f)
+	case *WhileStatement:
+		inspectExpression(node.Condition, f)
+		inspectBlock(node.Body, f)
+	case *ForInStatement:
+		Inspect(node.Name, f)
+		inspectExpression(node.Iterable, f)
+		inspectBlock(node.Body, f)
+	case *ForStatement:
+		if node.Init != nil {
+			Inspect(node.Init, f)
+		}
+
+		inspectExpression(node.Condition, f)
+		inspectExpression(node.Post, f)
+		inspectBlock(node.Body, f)
+	case *PrefixExpression:
+		inspectExpression(node.Right, f)
+	case *SpreadExpression:
+		inspectExpression(node.Value, f)
+	case *InfixExpression:
+		inspectExpression(node.Left, f)
+		inspectExpression(node.Right, f)
+	case *AssignExpression:
+		Inspect(node.Name, f)
+		inspectExpression(node.Value, f)
+	case *PostfixExpression:
+		Inspect(node.Name, f)
+	case *IfExpression:
+		inspectExpression(node.Condition, f)
+		inspectBlock(node.Consequence, f)
+		inspectBlock(node.Alternative, f)
+	case *SwitchExpression:
+		inspectExpression(node.Subject, f)
+
+		for _, c := range node.Cases {
+			for _, value := range c.Values {
+				inspectExpression(value, f)
+			}
+
+			inspectBlock(c.Body, f)
+		}
+
+		inspectBlock(node.Default, f)
+	case *ConditionalExpression:
+		inspectExpression(node.Condition, f)
+		inspectExpression(node.Consequence, f)
+		inspectExpression(node.Alternative, f)
+	case *TryExpression:
+		inspectBlock(node.Block, f)
+
+		if node.Parameter != nil {
+			Inspect(node.Parameter, f)
+		}
+
+		inspectBlock(node.Catch, f)
+	case *FunctionLiteral:
+		for i, param := range node.Parameters {
+			Inspect(param, f)
+
+			if i < len(node.Defaults) {
+				inspectExpression(node.Defaults[i], f)
+			}
+		}
+
+		inspectBlock(node.Body, f)
+	case *MacroLiteral:
+		for _, param := range node.Parameters {
+			Inspect(param, f)
+		}
+
+		inspectBlock(node.Body, f)
+	case *CallExpression:
+		inspectExpression(node.Function, f)
+
+		for _, arg := range node.Arguments {
+			inspectExpression(arg, f)
+		}
+
+		for _, arg := range node.Named {
+			Inspect(arg, f)
+		}
+	case *NamedArgument:
+		inspectExpression(node.Value, f)
+	case *StringInterpolation:
+		for _, value := range node.Values {
+			inspectExpression(value, f)
+		}
+	case *ArrayLiteral:
+		for _, element := range node.Elements {
+			inspectExpression(element, f)
+		}
+	case *IndexExpression:
+		inspectExpression(node.Left, f)
+		inspectExpression(node.Index, f)
+	case *SliceExpression:
+		inspectExpression(node.Left, f)
+		inspectExpression(node.Low, f)
+		inspectExpression(node.High, f)
+	case *IndexAssignment:
+		inspectExpression(node.Left, f)
+		inspectExpression(node.Index, f)
+		inspectExpression(node.Value, f)
+	case *HashLiteral:
+		for _, pair := range node.Pairs {
+			inspectExpression(pair.Key, f)
+			inspectExpression(pair.Value, f)
+		}
+	}
+}
+
+func inspectStatements(stmts []Statement, f func(Node) bool) {
+	for _, stmt := range stmts {
+		Inspect(stmt, f)
+	}
+}
+
+func inspectExpression(exp Expression, f func(Node) bool) {
+	if exp != nil {
+		Inspect(exp, f)
+	}
+}
+
+func inspectBlock(block *BlockStatement, f func(Node) bool) {
+	if block != nil {
+		Inspect(block, f)
+	}
+}
@@ -0,0 +1,71 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	ident := func(name string) *Identifier { return &Identifier{Value: name} }
+	integer := func(value int64) *IntegerLiteral { return &IntegerLiteral{Value: value} }
+
+	// let f = fn(x) { if (x) { x[1] } }; f(2);
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Name: ident("f"),
+				Value: &FunctionLiteral{
+					Parameters: []*Identifier{ident("x")},
+					Body: &BlockStatement{Statements: []Statement{
+						&ExpressionStatement{Expression: &IfExpression{
+							Condition: ident("x"),
+							Consequence: &BlockStatement{Statements: []Statement{
+								&ExpressionStatement{Expression: &IndexExpression{Left: ident("x"), Index: integer(1)}},
+							}},
+						}},
+					}},
+				},
+			},
+			&ExpressionStatement{Expression: &CallExpression{
+				Function:  ident("f"),
+				Arguments: []Expression{integer(2)},
+			}},
+		},
+	}
+
+	var visited []string
+
+	Inspect(program, func(node Node) bool {
+		switch node := node.(type) {
+		case *Identifier:
+			visited = append(visited, node.Value)
+		case *IntegerLiteral:
+			visited = append(visited, fmt.Sprint(node.Value))
+		}
+
+		return true
+	})
+
+	expected := []string{"f", "x", "x", "x", "1", "f", "2"}
+
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("wrong visit order. want=%v, got=%v", expected, visited)
+	}
+
+	var skipped []string
+
+	Inspect(program, func(node Node) bool {
+		if ident, ok := node.(*Identifier); ok {
+			skipped = append(skipped, ident.Value)
+		}
+
+		_, isFunction := node.(*FunctionLiteral)
+
+		return !isFunction
+	})
+
+	if !reflect.DeepEqual(skipped, []string{"f", "f"}) {
+		t.Errorf("children of a skipped node were visited. got=%v", skipped)
+	}
+}
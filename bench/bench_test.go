@@ -0,0 +1,165 @@
+package bench
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/vm"
+	"strings"
+	"testing"
+)
+
+const fibonacci = `
+let fibonacci = fn(x) {
+	if (x < 2) { x } else { fibonacci(x - 1) + fibonacci(x - 2) }
+};
+fibonacci(20);
+`
+
+const stringConcat = `
+let s = "";
+for (let i = 0; i < 1000; i++) { s += "x"; }
+len(s);
+`
+
+// stringBuilding is stringConcat at a size where copying the whole string
+// on every += would dominate.
+const stringBuilding = `
+let s = "";
+for (let i = 0; i < 20000; i++) { s += "abc"; }
+len(s);
+`
+
+const arrayBuilding = `
+let build = fn(n) {
+	let arr = [];
+	for (let i = 0; i < n; i++) { arr = push(arr, i); }
+	arr
+};
+len(build(500));
+`
+
+// integerArithmetic works with integers too large for the small-integer
+// cache, so every intermediate result that is boxed costs an allocation.
+const integerArithmetic = `
+let sum = 0;
+for (let i = 0; i < 5000; i++) { sum = (sum + i * i) % 1000003; }
+sum;
+`
+
+const hashLookups = `
+let h = {};
+for (let i = 0; i < 100; i++) { h[i] = i * 2; }
+let sum = 0;
+for (let i = 0; i < 5000; i++) { sum += h[i % 100]; }
+sum;
+`
+
+func BenchmarkFibonacci(b *testing.B)         { benchmarkProgram(b, fibonacci, "6765") }
+func BenchmarkStringConcat(b *testing.B)      { benchmarkProgram(b, stringConcat, "1000") }
+func BenchmarkStringBuilding(b *testing.B)    { benchmarkProgram(b, stringBuilding, "60000") }
+func BenchmarkArrayBuilding(b *testing.B)     { benchmarkProgram(b, arrayBuilding, "500") }
+func BenchmarkIntegerArithmetic(b *testing.B) { benchmarkProgram(b, integerArithmetic, "42538") }
+func BenchmarkHashLookups(b *testing.B)       { benchmarkProgram(b, hashLookups, "495000") }
+
+// BenchmarkParse times lexing and parsing a generated file of ten thousand
+// lines, without running it.
+func BenchmarkParse(b *testing.B) {
+	input := generated(10000)
+
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		parse(b, input)
+	}
+}
+
+// generatedFunction is the shape of ordinary code: lets, calls,
+// arithmetic, conditionals, loops and literals. %[1]d numbers it and %[2]d
+// is the function before it.
+const generatedFunction = `let f%[1]d = fn(a, b) {
+	let total = a * %[1]d + b - (a / 2);
+	let items = [a, b, total, "item %[1]d", true];
+	let lookup = {"a": a, "b": b, "n": %[1]d};
+	if (total > lookup["n"] && a != b) {
+		total = total + len(items);
+	} else {
+		total = total - items[0];
+	}
+	for (let i = 0; i < b; i++) {
+		total += i %% 3;
+	}
+	return f%[2]d(total, lookup["a"]);
+};
+`
+
+// generated returns a program of lines lines, rounded up to a whole
+// function, made of copies of generatedFunction.
+func generated(lines int) string {
+	var out strings.Builder
+
+	per := strings.Count(generatedFunction, "\n")
+
+	for i := 0; i*per < lines; i++ {
+		fmt.Fprintf(&out, generatedFunction, i, max(i-1, 0))
+	}
+
+	return out.String()
+}
+
+// benchmarkProgram times input on each engine, excluding parsing and
+// compilation, and checks that both produce expected.
+func benchmarkProgram(b *testing.B, input string, expected string) {
+	program := parse(b, input)
+
+	b.Run("eval", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			result := evaluator.Eval(program, object.NewEnvironment())
+			check(b, result, expected)
+		}
+	})
+
+	b.Run("vm", func(b *testing.B) {
+		comp := compiler.New()
+
+		if err := comp.Compile(program); err != nil {
+			b.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := comp.Bytecode()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			machine := vm.New(bytecode)
+
+			if err := machine.Run(); err != nil {
+				b.Fatalf("vm error: %s", err)
+			}
+
+			check(b, machine.LastPoppedStackElem(), expected)
+		}
+	})
+}
+
+func parse(b *testing.B, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		b.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return program
+}
+
+func check(b *testing.B, result object.Object, expected string) {
+	if result == nil || result.Inspect() != expected {
+		b.Fatalf("wrong result. expected=%s, got=%v", expected, result)
+	}
+}
@@ -0,0 +1,7 @@
+// Package bench benchmarks small Monkey programs that stress the
+// interpreter's hot paths (function calls, string concatenation, array
+// building, integer arithmetic and hash lookups) on both engines, and the
+// parser on a generated ten-thousand-line file. Run them with
+//
+//	go test -bench . -benchmem ./bench
+package bench
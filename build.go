@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"monkey/repl"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runBuild implements `monkey build [-o file] script.mky`, compiling the
+// script for the VM and saving the bytecode, by default next to the script
+// with a .mkyc extension, for `monkey run` to load. Flags may also follow
+// the script. The result is meant to be used as the process exit code.
+func runBuild(args []string, optimize bool, errOut io.Writer) int {
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	output := flags.String("o", "", "write the bytecode to this file")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprintln(errOut, "usage: monkey build [-o file] script.mky")
+
+		return 2
+	}
+
+	path := flags.Arg(0)
+
+	if err := flags.Parse(flags.Args()[1:]); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 0 {
+		fmt.Fprintln(errOut, "usage: monkey build [-o file] script.mky")
+
+		return 2
+	}
+
+	if *output == "" {
+		*output = strings.TrimSuffix(path, filepath.Ext(path)) + ".mkyc"
+	}
+
+	source, err := os.ReadFile(path)
+
+	if err != nil {
+		fmt.Fprintf(errOut, "could not read %s: %s\n", path, err)
+
+		return 1
+	}
+
+	bytecode, errors := repl.Compile(string(source), optimize)
+
+	if len(errors) != 0 {
+		for _, msg := range errors {
+			fmt.Fprintf(errOut, "%s: %s\n", path, msg)
+		}
+
+		return 1
+	}
+
+	encoded, err := bytecode.Encode()
+
+	if err != nil {
+		fmt.Fprintf(errOut, "could not compile %s: %s\n", path, err)
+
+		return 1
+	}
+
+	if err := os.WriteFile(*output, encoded, 0o644); err != nil {
+		fmt.Fprintf(errOut, "could not write %s: %s\n", *output, err)
+
+		return 1
+	}
+
+	return 0
+}
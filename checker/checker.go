@@ -0,0 +1,287 @@
+// Package checker looks for mistakes in a program before it runs: names
+// that are never bound, a let that repeats one in the same block, return
+// at the top level, and statements that can never be reached, which are
+// only a warning. It reports every problem it finds, not just the first.
+package checker
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/token"
+)
+
+// Problem is one mistake Check found, at the token it starts at. A
+// warning is a mistake the program can still run with.
+type Problem struct {
+	Token   token.Token
+	Message string
+	Warning bool
+}
+
+func (p Problem) String() string {
+	severity := "error"
+
+	if p.Warning {
+		severity = "warning"
+	}
+
+	return fmt.Sprintf("%s at line %d, col %d: %s", severity, p.Token.Line, p.Token.Column, p.Message)
+}
+
+type checker struct {
+	defined  func(name string) bool
+	scope    *scope
+	problems []Problem
+}
+
+// scope holds the names bound anywhere in one function, or at the top
+// level, outside the functions nested in it. A name counts as bound
+// wherever it is used in that function, so a function can call one that is
+// defined after it.
+type scope struct {
+	names map[string]bool
+	outer *scope
+}
+
+// Check returns the problems in program, in source order. defined reports
+// the names that are bound before the program runs, such as builtins and
+// the lets of earlier REPL inputs.
+func Check(program *ast.Program, defined func(name string) bool) []Problem {
+	c := &checker{defined: defined}
+
+	c.enter(nil, program)
+	c.statements(program.Statements)
+
+	return c.problems
+}
+
+func (c *checker) report(tok token.Token, format string, a ...interface{}) {
+	c.problems = append(c.problems, Problem{Token: tok, Message: fmt.Sprintf(format, a...)})
+}
+
+func (c *checker) warn(tok token.Token, format string, a ...interface{}) {
+	c.problems = append(c.problems, Problem{Token: tok, Message: fmt.Sprintf(format, a...), Warning: true})
+}
+
+// enter opens the scope of a function with params and body, declaring
+// every name the body binds.
+func (c *checker) enter(params []*ast.Identifier, body ast.Node) {
+	c.scope = &scope{names: make(map[string]bool), outer: c.scope}
+
+	for _, param := range params {
+		c.scope.names[param.Value] = true
+	}
+
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			return node == body
+		case *ast.LetStatement:
+			c.scope.names[node.Name.Value] = true
+		case *ast.DestructuringStatement:
+			for _, name := range node.Names {
+				c.scope.names[name.Value] = true
+			}
+		case *ast.ForInStatement:
+			c.scope.names[node.Name.Value] = true
+		case *ast.TryExpression:
+			if node.Parameter != nil {
+				c.scope.names[node.Parameter.Value] = true
+			}
+		}
+
+		return true
+	})
+}
+
+func (c *checker) leave() {
+	c.scope = c.scope.outer
+}
+
+func (c *checker) use(ident *ast.Identifier) {
+	for s := c.scope; s != nil; s = s.outer {
+		if s.names[ident.Value] {
+			return
+		}
+	}
+
+	if !c.defined(ident.Value) {
+		c.report(ident.Token, "identifier not found: %s", ident.Value)
+	}
+}
+
+// statements checks one block. Only its first unreachable statement is
+// reported; the ones after it are unreachable for the same reason.
+func (c *checker) statements(stmts []ast.Statement) {
+	declared := map[string]bool{}
+	unreachable := false
+
+	for _, stmt := range stmts {
+		if unreachable {
+			c.warn(ast.StatementToken(stmt), "unreachable code")
+
+			unreachable = false
+		}
+
+		for _, name := range boundNames(stmt) {
+			if declared[name.Value] {
+				c.report(name.Token, "duplicate declaration: %s", name.Value)
+			}
+
+			declared[name.Value] = true
+		}
+
+		c.statement(stmt)
+
+		switch stmt.(type) {
+		case *ast.ReturnStatement, *ast.BreakStatement, *ast.ContinueStatement:
+			unreachable = stmt != stmts[len(stmts)-1]
+		}
+	}
+}
+
+// boundNames returns the names stmt declares, if it is a let.
+func boundNames(stmt ast.Statement) []*ast.Identifier {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return []*ast.Identifier{stmt.Name}
+	case *ast.DestructuringStatement:
+		return stmt.Names
+	default:
+		return nil
+	}
+}
+
+func (c *checker) block(block *ast.BlockStatement) {
+	if block != nil {
+		c.statements(block.Statements)
+	}
+}
+
+func (c *checker) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		c.expression(stmt.Value)
+	case *ast.DestructuringStatement:
+		c.expression(stmt.Value)
+	case *ast.ReturnStatement:
+		if c.scope.outer == nil {
+			c.report(stmt.Token, "return outside of a function")
+		}
+
+		c.expression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		c.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		c.block(stmt)
+	case *ast.WhileStatement:
+		c.expression(stmt.Condition)
+		c.block(stmt.Body)
+	case *ast.ForStatement:
+		if stmt.Init != nil {
+			c.statement(stmt.Init)
+		}
+
+		c.expression(stmt.Condition)
+		c.expression(stmt.Post)
+		c.block(stmt.Body)
+	case *ast.ForInStatement:
+		c.expression(stmt.Iterable)
+		c.block(stmt.Body)
+	}
+}
+
+func (c *checker) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		c.use(exp)
+	case *ast.AssignExpression:
+		c.use(exp.Name)
+		c.expression(exp.Value)
+	case *ast.PostfixExpression:
+		c.use(exp.Name)
+	case *ast.IfExpression:
+		c.expression(exp.Condition)
+		c.block(exp.Consequence)
+		c.block(exp.Alternative)
+	case *ast.ConditionalExpression:
+		c.expression(exp.Condition)
+		c.expression(exp.Consequence)
+		c.expression(exp.Alternative)
+	case *ast.SwitchExpression:
+		c.expression(exp.Subject)
+
+		for _, sc := range exp.Cases {
+			c.expressions(sc.Values)
+			c.block(sc.Body)
+		}
+
+		c.block(exp.Default)
+	case *ast.TryExpression:
+		c.block(exp.Block)
+		c.block(exp.Catch)
+	case *ast.FunctionLiteral:
+		c.expressions(exp.Defaults)
+		c.function(exp.Parameters, exp)
+	case *ast.MacroLiteral:
+		c.function(exp.Parameters, exp)
+	case *ast.CallExpression:
+		// Quoted code is data, and may name things only its caller binds.
+		if exp.Function.TokenLiteral() == "quote" {
+			return
+		}
+
+		c.expression(exp.Function)
+		c.expressions(exp.Arguments)
+
+		for _, arg := range exp.Named {
+			c.expression(arg.Value)
+		}
+	case *ast.PrefixExpression:
+		c.expression(exp.Right)
+	case *ast.SpreadExpression:
+		c.expression(exp.Value)
+	case *ast.InfixExpression:
+		c.expression(exp.Left)
+		c.expression(exp.Right)
+	case *ast.IndexAssignment:
+		c.expression(exp.Left)
+		c.expression(exp.Index)
+		c.expression(exp.Value)
+	case *ast.StringInterpolation:
+		c.expressions(exp.Values)
+	case *ast.ArrayLiteral:
+		c.expressions(exp.Elements)
+	case *ast.IndexExpression:
+		c.expression(exp.Left)
+		c.expression(exp.Index)
+	case *ast.SliceExpression:
+		c.expression(exp.Left)
+		c.expression(exp.Low)
+		c.expression(exp.High)
+	case *ast.HashLiteral:
+		for _, pair := range exp.Pairs {
+			c.expression(pair.Key)
+			c.expression(pair.Value)
+		}
+	}
+}
+
+func (c *checker) function(params []*ast.Identifier, fn ast.Expression) {
+	c.enter(params, fn)
+
+	switch fn := fn.(type) {
+	case *ast.FunctionLiteral:
+		c.block(fn.Body)
+	case *ast.MacroLiteral:
+		c.block(fn.Body)
+	}
+
+	c.leave()
+}
+
+func (c *checker) expressions(exps []ast.Expression) {
+	for _, exp := range exps {
+		c.expression(exp)
+	}
+}
@@ -0,0 +1,78 @@
+package checker
+
+import (
+	"monkey/lexer"
+	"monkey/parser"
+	"reflect"
+	"testing"
+)
+
+func defined(name string) bool {
+	return name == "len" || name == "puts"
+}
+
+func check(t *testing.T, input string) []string {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	var problems []string
+
+	for _, problem := range Check(program, defined) {
+		problems = append(problems, problem.String())
+	}
+
+	return problems
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let x = 1; puts(len([x]));`, nil},
+		{`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };`, nil},
+		{`let d = 1; let f = fn(a, b = d) { let c = a + b; fn() { a + b + c } };`, nil},
+		{`for (x in [1, 2]) { puts(x) }; try { 1 } catch (e) { e };`, nil},
+		{`let x = 1; if (true) { let x = 2; x }; x;`, nil},
+		{`let m = macro(a) { quote(unquote(a) + later) };`, nil},
+		{`let f = fn() { return 1 }; while (true) { break }`, nil},
+		{`y + 1;`, []string{"error at line 1, col 1: identifier not found: y"}},
+		{`let f = fn(a) { a + b }; a;`, []string{
+			"error at line 1, col 21: identifier not found: b",
+			"error at line 1, col 26: identifier not found: a",
+		}},
+		{`let f = fn(n = n) { n };`, []string{"error at line 1, col 16: identifier not found: n"}},
+		{`z = 1; z++;`, []string{
+			"error at line 1, col 1: identifier not found: z",
+			"error at line 1, col 8: identifier not found: z",
+		}},
+		{`let x = 1;
+let x = 2;`, []string{"error at line 2, col 5: duplicate declaration: x"}},
+		{`let [a, b] = [1, 2]; let f = fn(h) { let {c} = h; a + b + c };`, nil},
+		{`let a = 1; let [b, a] = [2, 3];`, []string{"error at line 1, col 20: duplicate declaration: a"}},
+		{`let x = 1; const x = 2;`, []string{"error at line 1, col 18: duplicate declaration: x"}},
+		{`return 5;`, []string{"error at line 1, col 1: return outside of a function"}},
+		{`if (true) { return 5 }`, []string{"error at line 1, col 13: return outside of a function"}},
+		{`let f = fn() { return 1; puts(2); 3 };`, []string{"warning at line 1, col 26: unreachable code"}},
+		{`while (true) { break; let x = 1; }`, []string{"warning at line 1, col 23: unreachable code"}},
+		{`for (let i = 0; i < 3; i++) { continue; puts(i) }`, []string{"warning at line 1, col 41: unreachable code"}},
+		{`let x = 1; let x = 2; return q;`, []string{
+			"error at line 1, col 16: duplicate declaration: x",
+			"error at line 1, col 23: return outside of a function",
+			"error at line 1, col 30: identifier not found: q",
+		}},
+	}
+
+	for _, tt := range tests {
+		problems := check(t, tt.input)
+
+		if !reflect.DeepEqual(problems, tt.expected) {
+			t.Errorf("wrong problems for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, problems)
+		}
+	}
+}
@@ -0,0 +1,647 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"math/big"
+	"monkey/code"
+	"monkey/object"
+)
+
+// A compiled program is saved as a .mkyc file: BYTECODE_MAGIC, the format
+// version as a uint16, the main program's instructions, the constants and
+// a CRC-32 of everything before it. Numbers are varints and every byte
+// string and list is preceded by its length.
+const (
+	BYTECODE_MAGIC   = "MKYC"
+	BYTECODE_VERSION = 1 // bump whenever opcodes or builtins are changed in a way old files could notice
+)
+
+// The tags a constant is written with, ahead of its value.
+const (
+	tagInteger  byte = 'i'
+	tagBigInt   byte = 'n'
+	tagFloat    byte = 'f'
+	tagString   byte = 's'
+	tagArray    byte = 'a'
+	tagFunction byte = 'c'
+)
+
+// constantOperands gives, for the opcodes that refer to a constant, which
+// of their operands is its index.
+var constantOperands = map[code.Opcode]int{
+	code.OpConstant:  0,
+	code.OpClosure:   0,
+	code.OpProperty:  0,
+	code.OpCallNamed: 1,
+}
+
+var errTruncated = errors.New("unexpected end of file")
+
+// Encode returns the bytes of b as a .mkyc file. It fails on a constant
+// the compiler never makes, which has no encoding.
+func (b *Bytecode) Encode() ([]byte, error) {
+	var out bytes.Buffer
+
+	out.WriteString(BYTECODE_MAGIC)
+	out.Write(binary.BigEndian.AppendUint16(nil, BYTECODE_VERSION))
+
+	writeBytes(&out, b.Instructions)
+	writeUvarint(&out, uint64(len(b.Constants)))
+
+	for _, constant := range b.Constants {
+		if err := writeConstant(&out, constant); err != nil {
+			return nil, err
+		}
+	}
+
+	out.Write(binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(out.Bytes())))
+
+	return out.Bytes(), nil
+}
+
+func writeUvarint(out *bytes.Buffer, n uint64) {
+	out.Write(binary.AppendUvarint(nil, n))
+}
+
+func writeBytes(out *bytes.Buffer, b []byte) {
+	writeUvarint(out, uint64(len(b)))
+	out.Write(b)
+}
+
+func writeConstant(out *bytes.Buffer, constant object.Object) error {
+	switch constant := constant.(type) {
+	case *object.Integer:
+		out.WriteByte(tagInteger)
+		out.Write(binary.AppendVarint(nil, constant.Value))
+	case *object.BigInt:
+		text, _ := constant.Value.MarshalText()
+
+		out.WriteByte(tagBigInt)
+		writeBytes(out, text)
+	case *object.Float:
+		out.WriteByte(tagFloat)
+		out.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(constant.Value)))
+	case *object.String:
+		out.WriteByte(tagString)
+		writeBytes(out, []byte(constant.Value))
+	case *object.Array:
+		out.WriteByte(tagArray)
+		writeUvarint(out, uint64(len(constant.Elements)))
+
+		for _, el := range constant.Elements {
+			if err := writeConstant(out, el); err != nil {
+				return err
+			}
+		}
+	case *object.CompiledFunction:
+		out.WriteByte(tagFunction)
+		writeBytes(out, constant.Instructions)
+		writeUvarint(out, uint64(constant.NumLocals))
+		writeUvarint(out, uint64(constant.NumParameters))
+		writeUvarint(out, uint64(constant.NumDefaults))
+
+		if constant.Variadic {
+			out.WriteByte(1)
+		} else {
+			out.WriteByte(0)
+		}
+
+		writeUvarint(out, uint64(len(constant.Parameters)))
+
+		for _, param := range constant.Parameters {
+			writeBytes(out, []byte(param))
+		}
+	default:
+		return fmt.Errorf("cannot save constant of type %s", constant.Type())
+	}
+
+	return nil
+}
+
+// DecodeBytecode reads a .mkyc file written by Encode. It checks the
+// file's version and checksum, and that every instruction is one this VM
+// knows, whole, refers only to constants, builtins, locals, free
+// variables, globals and jump targets that exist, and never pops more
+// values than the stack holds, so that a file that loads can be run.
+func DecodeBytecode(data []byte) (*Bytecode, error) {
+	if len(data) < len(BYTECODE_MAGIC) || string(data[:len(BYTECODE_MAGIC)]) != BYTECODE_MAGIC {
+		return nil, errors.New("not a compiled Monkey program")
+	}
+
+	if len(data) < len(BYTECODE_MAGIC)+2+4 {
+		return nil, errTruncated
+	}
+
+	version := binary.BigEndian.Uint16(data[len(BYTECODE_MAGIC):])
+
+	if version != BYTECODE_VERSION {
+		return nil, fmt.Errorf("compiled for format version %d, this is version %d", version, BYTECODE_VERSION)
+	}
+
+	body, sum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+
+	if crc32.ChecksumIEEE(body) != sum {
+		return nil, errors.New("checksum mismatch: the file is corrupt")
+	}
+
+	r := &bytecodeReader{data: body[len(BYTECODE_MAGIC)+2:]}
+
+	instructions := code.Instructions(r.bytes())
+	constants := make([]object.Object, r.count())
+
+	for i := range constants {
+		constants[i] = r.constant()
+	}
+
+	if r.err == nil && len(r.data) != 0 {
+		r.err = errors.New("unexpected data after the constants")
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	bytecode := &Bytecode{Instructions: instructions, Constants: constants}
+
+	if err := validateProgram(instructions, constants); err != nil {
+		return nil, err
+	}
+
+	return bytecode, nil
+}
+
+// bytecodeReader reads the values Encode writes, keeping the first error
+// it meets; after one, every read returns a zero value.
+type bytecodeReader struct {
+	data []byte
+	err  error
+}
+
+func (r *bytecodeReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+
+	n, size := binary.Uvarint(r.data)
+
+	if size <= 0 {
+		r.err = errTruncated
+
+		return 0
+	}
+
+	r.data = r.data[size:]
+
+	return n
+}
+
+// count reads a length, which cannot be more than the bytes left, as every
+// element takes at least one.
+func (r *bytecodeReader) count() int {
+	n := r.uvarint()
+
+	if n > uint64(len(r.data)) {
+		r.err = errTruncated
+
+		return 0
+	}
+
+	return int(n)
+}
+
+func (r *bytecodeReader) bytes() []byte {
+	n := r.count()
+
+	if r.err != nil {
+		return nil
+	}
+
+	b := append([]byte{}, r.data[:n]...)
+	r.data = r.data[n:]
+
+	return b
+}
+
+func (r *bytecodeReader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+
+	if len(r.data) == 0 {
+		r.err = errTruncated
+
+		return 0
+	}
+
+	b := r.data[0]
+	r.data = r.data[1:]
+
+	return b
+}
+
+func (r *bytecodeReader) constant() object.Object {
+	switch tag := r.byte(); tag {
+	case tagInteger:
+		n, size := binary.Varint(r.data)
+
+		if size <= 0 {
+			r.err = errTruncated
+
+			return nil
+		}
+
+		r.data = r.data[size:]
+
+		return &object.Integer{Value: n}
+	case tagBigInt:
+		value, ok := new(big.Int).SetString(string(r.bytes()), 10)
+
+		if !ok && r.err == nil {
+			r.err = errors.New("malformed integer constant")
+		}
+
+		return &object.BigInt{Value: value}
+	case tagFloat:
+		if len(r.data) < 8 {
+			r.err = errTruncated
+
+			return nil
+		}
+
+		bits := binary.BigEndian.Uint64(r.data)
+		r.data = r.data[8:]
+
+		return &object.Float{Value: math.Float64frombits(bits)}
+	case tagString:
+		return object.Intern(string(r.bytes()))
+	case tagArray:
+		elements := make([]object.Object, r.count())
+
+		for i := range elements {
+			elements[i] = r.constant()
+		}
+
+		return &object.Array{Elements: elements}
+	case tagFunction:
+		fn := &object.CompiledFunction{
+			Instructions:  r.bytes(),
+			NumLocals:     int(r.uvarint()),
+			NumParameters: int(r.uvarint()),
+			NumDefaults:   int(r.uvarint()),
+			Variadic:      r.byte() == 1,
+		}
+
+		fn.Parameters = make([]string, r.count())
+
+		for i := range fn.Parameters {
+			fn.Parameters[i] = string(r.bytes())
+		}
+
+		return fn
+	default:
+		if r.err == nil {
+			r.err = fmt.Errorf("unknown constant tag %q", tag)
+		}
+
+		return nil
+	}
+}
+
+// instruction is one instruction of a stream being validated.
+type instruction struct {
+	offset   int
+	op       code.Opcode
+	def      *code.Definition
+	operands []int
+}
+
+// jumpOperands gives, for the opcodes that jump, which of their operands
+// is the target.
+var jumpOperands = map[code.Opcode]int{
+	code.OpJump:          0,
+	code.OpJumpNotTruthy: 0,
+	code.OpJumpNotNull:   0,
+	code.OpIterNext:      0,
+	code.OpTry:           0,
+	code.OpDefault:       1,
+}
+
+// stackEffects gives, for the opcodes whose counts do not depend on their
+// operands, how many values each pops and then pushes. Jumps are counted
+// as on the path that falls through.
+var stackEffects = map[code.Opcode][2]int{
+	code.OpConstant: {0, 1}, code.OpPop: {1, 0}, code.OpDup: {1, 2},
+	code.OpAdd: {2, 1}, code.OpSub: {2, 1}, code.OpMul: {2, 1}, code.OpDiv: {2, 1}, code.OpMod: {2, 1}, code.OpPow: {2, 1},
+	code.OpBitAnd: {2, 1}, code.OpBitOr: {2, 1}, code.OpBitXor: {2, 1}, code.OpShiftLeft: {2, 1}, code.OpShiftRight: {2, 1},
+	code.OpTrue: {0, 1}, code.OpFalse: {0, 1}, code.OpNull: {0, 1},
+	code.OpEqual: {2, 1}, code.OpNotEqual: {2, 1}, code.OpGreaterThan: {2, 1}, code.OpGreaterThanOrEqual: {2, 1},
+	code.OpMinus: {1, 1}, code.OpBang: {1, 1}, code.OpBitNot: {1, 1},
+	code.OpJump: {0, 0}, code.OpJumpNotTruthy: {1, 0}, code.OpJumpNotNull: {1, 0}, code.OpDefault: {0, 0},
+	code.OpGetGlobal: {0, 1}, code.OpSetGlobal: {1, 0}, code.OpGetLocal: {0, 1}, code.OpSetLocal: {1, 0},
+	code.OpGetBuiltin: {0, 1}, code.OpGetFree: {0, 1}, code.OpSetFree: {2, 0}, code.OpCurrentClosure: {0, 1},
+	code.OpReturnValue: {1, 0}, code.OpReturn: {0, 0},
+	code.OpIndex: {2, 1}, code.OpSetIndex: {3, 1}, code.OpSlice: {3, 1}, code.OpProperty: {1, 1},
+	code.OpIter: {1, 1}, code.OpIterNext: {1, 1}, code.OpTry: {0, 0}, code.OpEndTry: {0, 0},
+	code.OpCell: {1, 1}, code.OpGetCell: {1, 1}, code.OpSetCell: {2, 0},
+}
+
+// validateProgram checks the main program and every function constant.
+// It reads them all first, to learn how many free variables each function
+// is closed over with and which globals are ever set, then checks each
+// stream's operands against that.
+func validateProgram(main code.Instructions, constants []object.Object) error {
+	streams := map[*object.CompiledFunction][]instruction{}
+	mainFn := &object.CompiledFunction{Instructions: main}
+	functions := []*object.CompiledFunction{mainFn}
+
+	for i, constant := range constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			if err := validateFunction(i, fn); err != nil {
+				return err
+			}
+
+			functions = append(functions, fn)
+		}
+	}
+
+	numFree := map[*object.CompiledFunction]int{}
+	globals := map[int]bool{}
+
+	for _, fn := range functions {
+		stream, err := readInstructions(fn.Instructions, constants)
+
+		if err != nil {
+			return err
+		}
+
+		streams[fn] = stream
+
+		for _, in := range stream {
+			switch in.op {
+			case code.OpClosure:
+				closed := constants[in.operands[0]].(*object.CompiledFunction)
+
+				if n, ok := numFree[closed]; !ok || in.operands[1] < n {
+					numFree[closed] = in.operands[1]
+				}
+
+			case code.OpSetGlobal:
+				globals[in.operands[0]] = true
+			}
+		}
+	}
+
+	maxFree := 0
+
+	for _, n := range numFree {
+		maxFree = max(maxFree, n)
+	}
+
+	for _, fn := range functions {
+		if err := validateOperands(streams[fn], len(fn.Instructions), fn.NumLocals, numFree[fn], maxFree, globals); err != nil {
+			return err
+		}
+	}
+
+	for _, fn := range functions {
+		if err := validateStack(streams[fn], len(fn.Instructions), constants); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateFunction checks that the counts in the header of function
+// constant i agree with each other.
+func validateFunction(i int, fn *object.CompiledFunction) error {
+	fixed := fn.NumParameters
+
+	if fn.Variadic {
+		fixed--
+	}
+
+	switch {
+	case fixed < 0:
+		return fmt.Errorf("function constant %d is variadic but has no parameters", i)
+
+	case fn.NumParameters > fn.NumLocals:
+		return fmt.Errorf("function constant %d has %d parameters but %d locals", i, fn.NumParameters, fn.NumLocals)
+
+	case fn.NumDefaults > fixed:
+		return fmt.Errorf("function constant %d has %d defaults for %d parameters", i, fn.NumDefaults, fixed)
+
+	case len(fn.Parameters) != fn.NumParameters:
+		return fmt.Errorf("function constant %d names %d of its %d parameters", i, len(fn.Parameters), fn.NumParameters)
+	}
+
+	return nil
+}
+
+// readInstructions checks that ins is made of whole instructions this
+// VM knows, whose constant and builtin operands are in range, and returns
+// them.
+func readInstructions(ins code.Instructions, constants []object.Object) ([]instruction, error) {
+	var stream []instruction
+
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+
+		if err != nil {
+			return nil, err
+		}
+
+		width := 0
+
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+
+		if i+1+width > len(ins) {
+			return nil, fmt.Errorf("%s at %d is cut short", def.Name, i)
+		}
+
+		operands, read := code.ReadOperands(def, ins[i+1:])
+		op := code.Opcode(ins[i])
+
+		if index, ok := constantOperands[op]; ok && operands[index] >= len(constants) {
+			return nil, fmt.Errorf("%s at %d refers to constant %d of %d", def.Name, i, operands[index], len(constants))
+		}
+
+		if op == code.OpClosure {
+			if _, ok := constants[operands[0]].(*object.CompiledFunction); !ok {
+				return nil, fmt.Errorf("%s at %d refers to constant %d, which is not a function", def.Name, i, operands[0])
+			}
+		}
+
+		if op == code.OpGetBuiltin && operands[0] >= len(object.Builtins) {
+			return nil, fmt.Errorf("%s at %d refers to builtin %d, which this version does not have", def.Name, i, operands[0])
+		}
+
+		stream = append(stream, instruction{offset: i, op: op, def: def, operands: operands})
+
+		i += 1 + read
+	}
+
+	return stream, nil
+}
+
+// validateOperands checks that the instructions of one stream, length
+// bytes long, use only the locals and free variables its function has,
+// read only globals some instruction sets, and jump only to the start of
+// an instruction or the end of the stream. OpSetFree writes into the
+// closure below its value, which may be any, so it is only held to
+// maxFree, the most free variables a closure is made with.
+func validateOperands(stream []instruction, length, numLocals, numFree, maxFree int, globals map[int]bool) error {
+	starts := map[int]bool{length: true}
+
+	for _, in := range stream {
+		starts[in.offset] = true
+	}
+
+	for _, in := range stream {
+		switch in.op {
+		case code.OpGetLocal, code.OpSetLocal, code.OpDefault:
+			if in.operands[0] >= numLocals {
+				return fmt.Errorf("%s at %d refers to local %d of %d", in.def.Name, in.offset, in.operands[0], numLocals)
+			}
+
+		case code.OpGetFree:
+			if in.operands[0] >= numFree {
+				return fmt.Errorf("%s at %d refers to free variable %d of %d", in.def.Name, in.offset, in.operands[0], numFree)
+			}
+
+		case code.OpSetFree:
+			if in.operands[0] >= maxFree {
+				return fmt.Errorf("%s at %d refers to free variable %d, but no closure has more than %d", in.def.Name, in.offset, in.operands[0], maxFree)
+			}
+
+		case code.OpGetGlobal:
+			if !globals[in.operands[0]] {
+				return fmt.Errorf("%s at %d refers to global %d, which is never set", in.def.Name, in.offset, in.operands[0])
+			}
+		}
+
+		if index, ok := jumpOperands[in.op]; ok && !starts[in.operands[index]] {
+			return fmt.Errorf("%s at %d jumps to %d, which is not the start of an instruction", in.def.Name, in.offset, in.operands[index])
+		}
+	}
+
+	return nil
+}
+
+// stackEffect returns how many values in pops and then pushes.
+func stackEffect(in instruction, constants []object.Object) (int, int, error) {
+	switch in.op {
+	case code.OpCall, code.OpCallSpread:
+		return in.operands[0] + 1, 1, nil
+
+	case code.OpCallNamed:
+		names, ok := constants[in.operands[1]].(*object.Array)
+
+		if !ok {
+			return 0, 0, fmt.Errorf("%s at %d refers to constant %d, which is not an array of names", in.def.Name, in.offset, in.operands[1])
+		}
+
+		return in.operands[0] + len(names.Elements) + 1, 1, nil
+
+	case code.OpArray, code.OpHash, code.OpConcat:
+		return in.operands[0], 1, nil
+
+	case code.OpClosure:
+		return in.operands[1], 1, nil
+	}
+
+	effect, ok := stackEffects[in.op]
+
+	if !ok {
+		return 0, 0, fmt.Errorf("%s at %d has no known stack effect", in.def.Name, in.offset)
+	}
+
+	return effect[0], effect[1], nil
+}
+
+// validateStack checks that no instruction of one stream, length bytes
+// long, pops more values than the stack holds on any path to it. Where
+// paths meet with different depths, the shallowest is kept.
+func validateStack(stream []instruction, length int, constants []object.Object) error {
+	index := map[int]int{}
+
+	for i, in := range stream {
+		index[in.offset] = i
+	}
+
+	depths := map[int]int{}
+	pending := []int{}
+
+	reach := func(offset, depth int) {
+		if offset == length {
+			return
+		}
+
+		if seen, ok := depths[offset]; ok && seen <= depth {
+			return
+		}
+
+		depths[offset] = depth
+		pending = append(pending, offset)
+	}
+
+	reach(0, 0)
+
+	for len(pending) != 0 {
+		offset := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		in := stream[index[offset]]
+		depth := depths[offset]
+		pops, pushes, err := stackEffect(in, constants)
+
+		if err != nil {
+			return err
+		}
+
+		if pops > depth {
+			return fmt.Errorf("%s at %d pops %d values from a stack of %d", in.def.Name, in.offset, pops, depth)
+		}
+
+		next := in.offset + 1
+
+		for _, w := range in.def.OperandWidths {
+			next += w
+		}
+
+		after := depth - pops + pushes
+
+		switch in.op {
+		case code.OpReturnValue, code.OpReturn:
+			continue
+
+		case code.OpJump:
+			reach(in.operands[0], depth)
+
+			continue
+
+		case code.OpJumpNotTruthy, code.OpDefault:
+			reach(in.operands[jumpOperands[in.op]], after)
+
+		case code.OpJumpNotNull:
+			reach(in.operands[0], depth)
+
+		case code.OpIterNext:
+			reach(in.operands[0], depth-1)
+
+		case code.OpTry:
+			reach(in.operands[0], depth+1)
+		}
+
+		reach(next, after)
+	}
+
+	return nil
+}
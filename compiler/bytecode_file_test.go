@@ -0,0 +1,250 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"math/big"
+	"monkey/code"
+	"monkey/object"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBytecodeRoundTrip(t *testing.T) {
+	comp := New()
+
+	program := parse(`let f = fn(a, b = 2, ...rest) { let c = a + b; c * 1.5 }; f(a: 1); ({"k": 1}).k; "s${f(1)}"`)
+
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	bytecode := comp.Bytecode()
+	bytecode.Constants = append(bytecode.Constants, &object.BigInt{Value: new(big.Int).Lsh(big.NewInt(1), 100)})
+
+	encoded, err := bytecode.Encode()
+
+	if err != nil {
+		t.Fatalf("encode error: %s", err)
+	}
+
+	decoded, err := DecodeBytecode(encoded)
+
+	if err != nil {
+		t.Fatalf("decode error: %s", err)
+	}
+
+	if !bytes.Equal(decoded.Instructions, bytecode.Instructions) {
+		t.Errorf("wrong instructions.\nwant=%s\ngot=%s", bytecode.Instructions, decoded.Instructions)
+	}
+
+	if len(decoded.Constants) != len(bytecode.Constants) {
+		t.Fatalf("wrong number of constants. want=%d, got=%d", len(bytecode.Constants), len(decoded.Constants))
+	}
+
+	for i, constant := range bytecode.Constants {
+		got := decoded.Constants[i]
+
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			if !reflect.DeepEqual(got, fn) {
+				t.Errorf("wrong function constant %d.\nwant=%+v\ngot=%+v", i, fn, got)
+			}
+
+			continue
+		}
+
+		if got.Type() != constant.Type() || got.Inspect() != constant.Inspect() {
+			t.Errorf("wrong constant %d. want=%s %s, got=%s %s", i, constant.Type(), constant.Inspect(), got.Type(), got.Inspect())
+		}
+	}
+}
+
+// withChecksum finishes data, the body of a .mkyc file, with its checksum.
+func withChecksum(data []byte) []byte {
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(data))
+}
+
+func header(version uint16) []byte {
+	return binary.BigEndian.AppendUint16([]byte(BYTECODE_MAGIC), version)
+}
+
+// file returns a .mkyc file of instructions and no constants.
+func file(instructions ...[]byte) []byte {
+	ins := concat(instructions...)
+
+	data := header(BYTECODE_VERSION)
+	data = binary.AppendUvarint(data, uint64(len(ins)))
+	data = append(data, ins...)
+	data = binary.AppendUvarint(data, 0)
+
+	return withChecksum(data)
+}
+
+// withFunction returns a .mkyc file whose main program is main and whose
+// only constant is fn.
+func withFunction(main []byte, fn *object.CompiledFunction) []byte {
+	data := header(BYTECODE_VERSION)
+	data = binary.AppendUvarint(data, uint64(len(main)))
+	data = append(data, main...)
+	data = binary.AppendUvarint(data, 1)
+	data = append(data, tagFunction)
+	data = binary.AppendUvarint(data, uint64(len(fn.Instructions)))
+	data = append(data, fn.Instructions...)
+	data = binary.AppendUvarint(data, uint64(fn.NumLocals))
+	data = binary.AppendUvarint(data, uint64(fn.NumParameters))
+	data = binary.AppendUvarint(data, uint64(fn.NumDefaults))
+
+	if fn.Variadic {
+		data = append(data, 1)
+	} else {
+		data = append(data, 0)
+	}
+
+	data = binary.AppendUvarint(data, uint64(len(fn.Parameters)))
+
+	for _, name := range fn.Parameters {
+		data = binary.AppendUvarint(data, uint64(len(name)))
+		data = append(data, name...)
+	}
+
+	return withChecksum(data)
+}
+
+func concat(instructions ...[]byte) []byte {
+	var ins []byte
+
+	for _, in := range instructions {
+		ins = append(ins, in...)
+	}
+
+	return ins
+}
+
+func TestBytecodeValidation(t *testing.T) {
+	valid := file(code.Make(code.OpTrue), code.Make(code.OpPop))
+
+	corrupt := append([]byte{}, valid...)
+	corrupt[len(corrupt)-5] ^= 1
+
+	tests := []struct {
+		data     []byte
+		expected string
+	}{
+		{[]byte("puts(1)"), "not a compiled Monkey program"},
+		{withChecksum(header(BYTECODE_VERSION + 1)), "compiled for format version 2, this is version 1"},
+		{corrupt, "checksum mismatch: the file is corrupt"},
+		{withChecksum(append(header(BYTECODE_VERSION), 5, 1)), "unexpected end of file"},
+		{withChecksum(append(header(BYTECODE_VERSION), 0, 1, 'x')), "unknown constant tag 'x'"},
+		{withChecksum(append(header(BYTECODE_VERSION), 0, 0, 0)), "unexpected data after the constants"},
+		{file([]byte{255}), "opcode 255 undefined"},
+		{file(code.Make(code.OpConstant, 0)[:2]), "OpConstant at 0 is cut short"},
+		{file(code.Make(code.OpTrue), code.Make(code.OpConstant, 3)), "OpConstant at 1 refers to constant 3 of 0"},
+		{file(code.Make(code.OpGetBuiltin, 250)), "OpGetBuiltin at 0 refers to builtin 250, which this version does not have"},
+		{file(code.Make(code.OpGetLocal, 0), code.Make(code.OpPop)), "OpGetLocal at 0 refers to local 0 of 0"},
+		{file(code.Make(code.OpTrue), code.Make(code.OpSetLocal, 2)), "OpSetLocal at 1 refers to local 2 of 0"},
+		{file(code.Make(code.OpGetFree, 5), code.Make(code.OpPop)), "OpGetFree at 0 refers to free variable 5 of 0"},
+		{file(code.Make(code.OpGetGlobal, 7), code.Make(code.OpPop)), "OpGetGlobal at 0 refers to global 7, which is never set"},
+		{file(code.Make(code.OpJump, 2)), "OpJump at 0 jumps to 2, which is not the start of an instruction"},
+		{file(code.Make(code.OpTrue), code.Make(code.OpJumpNotTruthy, 99)), "OpJumpNotTruthy at 1 jumps to 99, which is not the start of an instruction"},
+		{file(code.Make(code.OpTry, 1), code.Make(code.OpEndTry)), "OpTry at 0 jumps to 1, which is not the start of an instruction"},
+		{file(code.Make(code.OpAdd), code.Make(code.OpPop)), "OpAdd at 0 pops 2 values from a stack of 0"},
+		{file(code.Make(code.OpTrue), code.Make(code.OpJumpNotTruthy, 4), code.Make(code.OpPop)), "OpPop at 4 pops 1 values from a stack of 0"},
+		{
+			withFunction(concat(code.Make(code.OpClosure, 0, 0), code.Make(code.OpTrue), code.Make(code.OpSetFree, 0)), &object.CompiledFunction{
+				Instructions: code.Make(code.OpReturn),
+			}),
+			"OpSetFree at 5 refers to free variable 0, but no closure has more than 0",
+		},
+		{
+			withFunction(concat(code.Make(code.OpClosure, 0, 1), code.Make(code.OpPop)), &object.CompiledFunction{
+				Instructions: concat(code.Make(code.OpGetLocal, 1), code.Make(code.OpReturnValue)),
+				NumLocals:    1,
+			}),
+			"OpGetLocal at 0 refers to local 1 of 1",
+		},
+		{
+			withFunction(concat(code.Make(code.OpClosure, 0, 1), code.Make(code.OpPop)), &object.CompiledFunction{
+				Instructions: concat(code.Make(code.OpGetFree, 1), code.Make(code.OpReturnValue)),
+			}),
+			"OpGetFree at 0 refers to free variable 1 of 1",
+		},
+		{
+			withFunction(concat(code.Make(code.OpClosure, 0, 0), code.Make(code.OpPop)), &object.CompiledFunction{
+				Instructions:  concat(code.Make(code.OpDefault, 0, 1), code.Make(code.OpReturn)),
+				NumLocals:     1,
+				NumParameters: 1,
+				NumDefaults:   1,
+				Parameters:    []string{"a"},
+			}),
+			"OpDefault at 0 jumps to 1, which is not the start of an instruction",
+		},
+		{
+			withFunction(nil, &object.CompiledFunction{NumParameters: 2, NumLocals: 1, Parameters: []string{"a", "b"}}),
+			"function constant 0 has 2 parameters but 1 locals",
+		},
+		{
+			withFunction(nil, &object.CompiledFunction{NumParameters: 1, NumLocals: 1, NumDefaults: 2, Parameters: []string{"a"}}),
+			"function constant 0 has 2 defaults for 1 parameters",
+		},
+		{
+			withFunction(nil, &object.CompiledFunction{NumLocals: 1, Variadic: true}),
+			"function constant 0 is variadic but has no parameters",
+		},
+		{
+			withFunction(nil, &object.CompiledFunction{NumParameters: 1, NumLocals: 1}),
+			"function constant 0 names 0 of its 1 parameters",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := DecodeBytecode(tt.data)
+
+		if err == nil {
+			t.Errorf("expected error %q, got none", tt.expected)
+
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, err.Error())
+		}
+	}
+
+	if _, err := DecodeBytecode(valid); err != nil {
+		t.Errorf("valid file refused: %s", err)
+	}
+
+	for _, input := range []string{
+		"let f = fn(a, b = 2, ...rest) { let c = a + b; fn() { c + a } }; f(1)()",
+		"let sum = 0; for (x in [1, 2, 3]) { if (x == 2) { continue; } sum += x; }; sum",
+		"let x = 0; while (x < 3) { x += 1; }; do { x -= 1; } while (x > 0); loop { break; }",
+		"let f = fn() { try { 1 / 0 } catch (e) { e } }; f()",
+		`let h = {"a": [1]}; h?.a?[0] ?? 3`,
+		"let countdown = fn(n) { if (n == 0) { return 0; } countdown(n - 1) }; countdown(3)",
+		"let f = fn() { let isEven = fn(n) { n == 0 || isOdd(n - 1) }; let isOdd = fn(n) { n != 0 && isEven(n - 1) }; isOdd(3) }; f()",
+		"let f = fn(a, b = 2) { [a, b] }; let xs = [1]; f(...xs) + f(1, b: 3) + [`${1}`, {1: 2}[1], xs[0:1]]",
+		"let n = 0; let inc = fn() { n++ }; inc(); switch (n) { case 1: n > 0 ? n : -n default: 0 }",
+	} {
+		comp := New()
+
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		encoded, err := comp.Bytecode().Encode()
+
+		if err != nil {
+			t.Fatalf("encode error: %s", err)
+		}
+
+		if _, err := DecodeBytecode(encoded); err != nil {
+			t.Errorf("compiled program %q refused: %s", input, err)
+		}
+	}
+
+	if _, err := (&Bytecode{Constants: []object.Object{object.TRUE}}).Encode(); err == nil || !strings.Contains(err.Error(), "BOOLEAN") {
+		t.Errorf("expected an error encoding a boolean constant, got %v", err)
+	}
+}
@@ -0,0 +1,1567 @@
+package compiler
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/code"
+	"monkey/object"
+)
+
+type Compiler struct {
+	constants []object.Object
+
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// CompilationScope holds the instructions of the function currently being
+// compiled; the main program is the outermost scope.
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+
+	loops []*loop
+}
+
+// loop records the jumps emitted by break and continue inside one loop, so
+// they can be patched once the loop's layout is known.
+type loop struct {
+	breaks    []int
+	continues []int
+	tries     int // try blocks entered since the loop began
+}
+
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+}
+
+func New() *Compiler {
+	mainScope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: NewSymbolTableWithBuiltins(),
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+	}
+}
+
+// NewWithState creates a compiler that keeps defining into an existing symbol
+// table and constant pool, which is how the REPL carries state between lines.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+
+	return compiler
+}
+
+// NewSymbolTableWithBuiltins returns a global symbol table that already knows
+// about every builtin, ready to be passed to NewWithState.
+func NewSymbolTableWithBuiltins() *SymbolTable {
+	symbolTable := NewSymbolTable()
+
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+
+	return symbolTable
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		c.symbolTable.shared = sharedNames(node)
+
+		err := c.compileStatements(node.Statements)
+
+		if err != nil {
+			return err
+		}
+
+	case *ast.ExpressionStatement:
+		err := c.Compile(node.Expression)
+
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		err := c.compileStatements(node.Statements)
+
+		if err != nil {
+			return err
+		}
+
+	case *ast.LetStatement:
+		symbol := c.define(node.Name, node.Constant)
+
+		err := c.Compile(node.Value)
+
+		if err != nil {
+			return err
+		}
+
+		c.bindSymbol(symbol)
+
+	case *ast.DestructuringStatement:
+		err := c.compileDestructuringStatement(node)
+
+		if err != nil {
+			return err
+		}
+
+	case *ast.ReturnStatement:
+		err := c.Compile(node.ReturnValue)
+
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpReturnValue)
+
+	case *ast.WhileStatement:
+		// A do-while jumps over its condition into the body the first
+		// time; continue still goes to the condition.
+		jumpToBodyPos := -1
+
+		if node.Do {
+			jumpToBodyPos = c.emit(code.OpJump, 9999)
+		}
+
+		loopStart := len(c.currentInstructions())
+
+		err := c.Compile(node.Condition)
+
+		if err != nil {
+			return err
+		}
+
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		if node.Do {
+			c.changeOperand(jumpToBodyPos, len(c.currentInstructions()))
+		}
+
+		c.enterLoop()
+		c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+
+		err = c.Compile(node.Body)
+
+		c.symbolTable = c.symbolTable.Outer
+
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpJump, loopStart)
+
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+		c.leaveLoop(loopStart, len(c.currentInstructions()))
+
+	case *ast.BreakStatement:
+		current, err := c.currentLoop("break")
+
+		if err != nil {
+			return err
+		}
+
+		c.endTries(current)
+		current.breaks = append(current.breaks, c.emit(code.OpJump, 9999))
+
+	case *ast.ContinueStatement:
+		current, err := c.currentLoop("continue")
+
+		if err != nil {
+			return err
+		}
+
+		c.endTries(current)
+		current.continues = append(current.continues, c.emit(code.OpJump, 9999))
+
+	case *ast.ForInStatement:
+		err := c.compileForInStatement(node)
+
+		if err != nil {
+			return err
+		}
+
+	case *ast.ForStatement:
+		err := c.compileForStatement(node)
+
+		if err != nil {
+			return err
+		}
+
+	case *ast.PrefixExpression:
+		err := c.Compile(node.Right)
+
+		if err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		case "~":
+			c.emit(code.OpBitNot)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogicalExpression(node)
+		}
+
+		if node.Operator == "??" {
+			return c.compileCoalesceExpression(node)
+		}
+
+		// a < b is compiled as b > a, and a <= b as b >= a, so the VM only
+		// needs the greater-than opcodes.
+		if node.Operator == "<" || node.Operator == "<=" {
+			err := c.Compile(node.Right)
+
+			if err != nil {
+				return err
+			}
+
+			err = c.Compile(node.Left)
+
+			if err != nil {
+				return err
+			}
+
+			if node.Operator == "<" {
+				c.emit(code.OpGreaterThan)
+			} else {
+				c.emit(code.OpGreaterThanOrEqual)
+			}
+
+			return nil
+		}
+
+		err := c.Compile(node.Left)
+
+		if err != nil {
+			return err
+		}
+
+		err = c.Compile(node.Right)
+
+		if err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "+":
+			c.emit(code.OpAdd)
+		case "-":
+			c.emit(code.OpSub)
+		case "*":
+			c.emit(code.OpMul)
+		case "/":
+			c.emit(code.OpDiv)
+		case "%":
+			c.emit(code.OpMod)
+		case "**":
+			c.emit(code.OpPow)
+		case "&":
+			c.emit(code.OpBitAnd)
+		case "|":
+			c.emit(code.OpBitOr)
+		case "^":
+			c.emit(code.OpBitXor)
+		case "<<":
+			c.emit(code.OpShiftLeft)
+		case ">>":
+			c.emit(code.OpShiftRight)
+		case ">":
+			c.emit(code.OpGreaterThan)
+		case ">=":
+			c.emit(code.OpGreaterThanOrEqual)
+		case "==":
+			c.emit(code.OpEqual)
+		case "!=":
+			c.emit(code.OpNotEqual)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.AssignExpression:
+		symbol, ok := c.symbolTable.Resolve(node.Name.Value)
+
+		if !ok {
+			return fmt.Errorf("cannot assign to unbound identifier: %s", node.Name.Value)
+		}
+
+		err := c.Compile(node.Value)
+
+		if err != nil {
+			return err
+		}
+
+		err = c.storeSymbol(symbol)
+
+		if err != nil {
+			return err
+		}
+
+		c.loadSymbol(symbol)
+
+	case *ast.PostfixExpression:
+		symbol, ok := c.symbolTable.Resolve(node.Name.Value)
+
+		if !ok {
+			return fmt.Errorf("cannot assign to unbound identifier: %s", node.Name.Value)
+		}
+
+		// The first load is the expression's value; the second is the
+		// operand of the update.
+		c.loadSymbol(symbol)
+		c.loadSymbol(symbol)
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: 1}))
+
+		if node.Operator == "++" {
+			c.emit(code.OpAdd)
+		} else {
+			c.emit(code.OpSub)
+		}
+
+		err := c.storeSymbol(symbol)
+
+		if err != nil {
+			return err
+		}
+
+	case *ast.IfExpression:
+		err := c.Compile(node.Condition)
+
+		if err != nil {
+			return err
+		}
+
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		err = c.compileBranch(node.Consequence)
+
+		if err != nil {
+			return err
+		}
+
+		jumpPos := c.emit(code.OpJump, 9999)
+
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+		if node.Alternative == nil {
+			c.emit(code.OpNull)
+		} else {
+			err := c.compileBranch(node.Alternative)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	case *ast.ConditionalExpression:
+		err := c.Compile(node.Condition)
+
+		if err != nil {
+			return err
+		}
+
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		err = c.Compile(node.Consequence)
+
+		if err != nil {
+			return err
+		}
+
+		jumpPos := c.emit(code.OpJump, 9999)
+
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+		err = c.Compile(node.Alternative)
+
+		if err != nil {
+			return err
+		}
+
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	case *ast.SwitchExpression:
+		err := c.compileSwitchExpression(node)
+
+		if err != nil {
+			return err
+		}
+
+	case *ast.TryExpression:
+		err := c.compileTryExpression(node)
+
+		if err != nil {
+			return err
+		}
+
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+
+		if !ok {
+			return fmt.Errorf("identifier not found: %s", node.Value)
+		}
+
+		c.loadSymbol(symbol)
+
+	case *ast.IntegerLiteral:
+		var integer object.Object = &object.Integer{Value: node.Value}
+
+		if node.Big != nil {
+			integer = &object.BigInt{Value: node.Big}
+		}
+
+		c.emit(code.OpConstant, c.addConstant(integer))
+
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+
+	case *ast.StringLiteral:
+		str := object.Intern(node.Value)
+		c.emit(code.OpConstant, c.addConstant(str))
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			err := c.Compile(el)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.StringInterpolation:
+		err := c.compileStringInterpolation(node)
+
+		if err != nil {
+			return err
+		}
+
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			err := c.Compile(pair.Key)
+
+			if err != nil {
+				return err
+			}
+
+			err = c.Compile(pair.Value)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		err := c.Compile(node.Left)
+
+		if err != nil {
+			return err
+		}
+
+		skip := c.skipIfNull(node.Optional)
+
+		if name, ok := ast.PropertyName(node.Index); ok {
+			c.emit(code.OpProperty, c.addConstant(object.Intern(name)))
+		} else {
+			err = c.Compile(node.Index)
+
+			if err != nil {
+				return err
+			}
+
+			c.emit(code.OpIndex)
+		}
+
+		c.endSkip(skip)
+
+	case *ast.SliceExpression:
+		err := c.Compile(node.Left)
+
+		if err != nil {
+			return err
+		}
+
+		skip := c.skipIfNull(node.Optional)
+
+		// An omitted bound is pushed as null.
+		for _, bound := range []ast.Expression{node.Low, node.High} {
+			if bound == nil {
+				c.emit(code.OpNull)
+
+				continue
+			}
+
+			err = c.Compile(bound)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpSlice)
+		c.endSkip(skip)
+
+	case *ast.IndexAssignment:
+		err := c.Compile(node.Left)
+
+		if err != nil {
+			return err
+		}
+
+		err = c.Compile(node.Index)
+
+		if err != nil {
+			return err
+		}
+
+		err = c.Compile(node.Value)
+
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpSetIndex)
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.FunctionLiteral:
+		_, err := c.compileFunctionLiteral(node)
+
+		if err != nil {
+			return err
+		}
+
+	case *ast.CallExpression:
+		err := c.Compile(node.Function)
+
+		if err != nil {
+			return err
+		}
+
+		if hasSpread(node.Arguments) {
+			return c.compileSpreadArguments(node.Arguments)
+		}
+
+		for _, a := range node.Arguments {
+			err := c.Compile(a)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if node.Named != nil {
+			return c.compileNamedArguments(node)
+		}
+
+		c.emit(code.OpCall, len(node.Arguments))
+
+	default:
+		return fmt.Errorf("compiler does not support %T yet", node)
+	}
+
+	return nil
+}
+
+// compileFunctionLiteral emits the closure for node and returns the symbols
+// it captured, in the order of its free variables.
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) ([]Symbol, error) {
+	c.enterScope()
+	c.symbolTable.shared = sharedNames(node.Body)
+
+	if node.Name != "" {
+		c.symbolTable.DefineFunctionName(node.Name)
+	}
+
+	err := c.compileParameters(node)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.Compile(node.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	instructions := c.leaveScope()
+
+	for _, s := range freeSymbols {
+		c.loadCapture(s)
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		Variadic:      node.Variadic,
+		NumDefaults:   numDefaults(node),
+		Parameters:    parameterNames(node),
+	}
+
+	fnIndex := c.addConstant(compiledFn)
+	c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+
+	return freeSymbols, nil
+}
+
+// sharedNames returns the names that body, a function's or the program's,
+// both assigns and refers to from a function nested in it. The locals,
+// and globals bound in blocks, that body binds to those names are kept in
+// cells, which closures capture instead of a copy of the value, so an
+// assignment on either side is seen by the other. Names are matched
+// without regard to scope, so a name that is shadowed may get a cell it
+// does not need, which costs only speed.
+func sharedNames(body ast.Node) map[string]bool {
+	assigned := map[string]bool{}
+	captured := map[string]bool{}
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignExpression:
+			assigned[n.Name.Value] = true
+
+		case *ast.PostfixExpression:
+			assigned[n.Name.Value] = true
+
+		case *ast.FunctionLiteral:
+			ast.Inspect(n, func(n ast.Node) bool {
+				if ident, ok := n.(*ast.Identifier); ok {
+					captured[ident.Value] = true
+				}
+
+				return true
+			})
+		}
+
+		return true
+	})
+
+	shared := map[string]bool{}
+
+	for name := range assigned {
+		if captured[name] {
+			shared[name] = true
+		}
+	}
+
+	return shared
+}
+
+// capture is a free variable of a closure that was created before the
+// function it refers to was bound.
+type capture struct {
+	closure Symbol
+	index   int
+}
+
+// compileStatements compiles the statements of a program or block. The
+// functions bound there with let are declared before any statement is
+// compiled, so they can refer to one another, as the evaluator allows,
+// whatever order they are written in. Globals need nothing more. A closure
+// that captures a local function bound after it captures null, though, so
+// once that function is bound OpSetFree writes it into the closure.
+func (c *Compiler) compileStatements(stmts []ast.Statement) error {
+	hoisted := c.hoistFunctions(stmts)
+	unbound := map[Symbol]bool{}
+
+	for _, symbol := range hoisted {
+		unbound[symbol] = true
+	}
+
+	captures := map[Symbol][]capture{}
+
+	for _, s := range stmts {
+		let, ok := s.(*ast.LetStatement)
+		symbol, isHoisted := hoisted[let]
+
+		if !ok || !isHoisted {
+			err := c.Compile(s)
+
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		free, err := c.compileFunctionLiteral(let.Value.(*ast.FunctionLiteral))
+
+		if err != nil {
+			return err
+		}
+
+		c.bindSymbol(symbol)
+
+		delete(unbound, symbol)
+
+		for i, f := range free {
+			if unbound[f] {
+				captures[f] = append(captures[f], capture{closure: symbol, index: i})
+			}
+		}
+
+		for _, cp := range captures[symbol] {
+			c.loadSymbol(cp.closure)
+			c.loadCapture(symbol)
+			c.emit(code.OpSetFree, cp.index)
+		}
+
+		delete(captures, symbol)
+	}
+
+	return nil
+}
+
+// hoistFunctions declares the names that stmts bind to function literals.
+// Only a name's first let is hoisted, and only if it binds a function; a
+// later let of the same name declares a new variable when it is reached,
+// as usual.
+func (c *Compiler) hoistFunctions(stmts []ast.Statement) map[*ast.LetStatement]Symbol {
+	hoisted := map[*ast.LetStatement]Symbol{}
+	seen := map[string]bool{}
+
+	for _, s := range stmts {
+		let, ok := s.(*ast.LetStatement)
+
+		if !ok || seen[let.Name.Value] {
+			continue
+		}
+
+		seen[let.Name.Value] = true
+
+		if _, isFunction := let.Value.(*ast.FunctionLiteral); isFunction {
+			hoisted[let] = c.define(let.Name, let.Constant)
+		}
+	}
+
+	return hoisted
+}
+
+// compileParameters defines node's parameters and compiles their default
+// values. Each default is guarded by an OpDefault, which skips it when the
+// call passed that parameter. Defaults are evaluated where the function was
+// defined, so they are compiled after the parameters' slots are allocated
+// but before their names are visible.
+func (c *Compiler) compileParameters(node *ast.FunctionLiteral) error {
+	symbols := make([]Symbol, len(node.Parameters))
+
+	for i, p := range node.Parameters {
+		symbols[i] = c.symbolTable.allocate(p.Value)
+	}
+
+	for i, value := range node.Defaults {
+		if value == nil {
+			continue
+		}
+
+		defaultPos := c.emit(code.OpDefault, symbols[i].Index, 9999)
+
+		err := c.Compile(value)
+
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpSetLocal, symbols[i].Index)
+
+		c.replaceInstruction(defaultPos, code.Make(code.OpDefault, symbols[i].Index, len(c.currentInstructions())))
+	}
+
+	for _, symbol := range symbols {
+		if symbol.Cell {
+			c.emit(code.OpGetLocal, symbol.Index)
+			c.emit(code.OpCell)
+			c.emit(code.OpSetLocal, symbol.Index)
+		}
+
+		c.symbolTable.store[symbol.Name] = symbol
+	}
+
+	return nil
+}
+
+func numDefaults(node *ast.FunctionLiteral) int {
+	n := 0
+
+	for _, value := range node.Defaults {
+		if value != nil {
+			n++
+		}
+	}
+
+	return n
+}
+
+// compileStringInterpolation pushes an interpolated string's text and
+// values in order, leaving out empty text, for OpConcat to join.
+func (c *Compiler) compileStringInterpolation(node *ast.StringInterpolation) error {
+	parts := 0
+
+	pushText := func(s string) {
+		if s != "" {
+			c.emit(code.OpConstant, c.addConstant(object.Intern(s)))
+			parts++
+		}
+	}
+
+	pushText(node.Strings[0])
+
+	for i, value := range node.Values {
+		err := c.Compile(value)
+
+		if err != nil {
+			return err
+		}
+
+		parts++
+		pushText(node.Strings[i+1])
+	}
+
+	c.emit(code.OpConcat, parts)
+
+	return nil
+}
+
+func parameterNames(node *ast.FunctionLiteral) []string {
+	names := make([]string, len(node.Parameters))
+
+	for i, p := range node.Parameters {
+		names[i] = p.Value
+	}
+
+	return names
+}
+
+// compileNamedArguments pushes the values of a call's named arguments after
+// its positional ones. OpCallNamed finds the names in an array constant and
+// moves each value to its parameter's place before making the call.
+func (c *Compiler) compileNamedArguments(node *ast.CallExpression) error {
+	names := make([]object.Object, len(node.Named))
+
+	for i, arg := range node.Named {
+		err := c.Compile(arg.Value)
+
+		if err != nil {
+			return err
+		}
+
+		names[i] = &object.String{Value: arg.Name}
+	}
+
+	c.emit(code.OpCallNamed, len(node.Arguments), c.addConstant(&object.Array{Elements: names}))
+
+	return nil
+}
+
+func hasSpread(args []ast.Expression) bool {
+	for _, arg := range args {
+		if _, ok := arg.(*ast.SpreadExpression); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compileSpreadArguments pushes a call's arguments as a series of arrays:
+// each spread argument's own value, and an OpArray for every run of plain
+// arguments between them. OpCallSpread then passes all their elements.
+func (c *Compiler) compileSpreadArguments(args []ast.Expression) error {
+	pieces := 0
+	run := 0
+
+	endRun := func() {
+		if run > 0 {
+			c.emit(code.OpArray, run)
+			pieces++
+			run = 0
+		}
+	}
+
+	for _, arg := range args {
+		spread, ok := arg.(*ast.SpreadExpression)
+
+		if ok {
+			endRun()
+			arg = spread.Value
+		}
+
+		err := c.Compile(arg)
+
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			pieces++
+		} else {
+			run++
+		}
+	}
+
+	endRun()
+
+	c.emit(code.OpCallSpread, pieces)
+
+	return nil
+}
+
+// compileForStatement mirrors the evaluator's scoping: the init clause lives
+// in a block scope around the loop and the body in a block scope of its own.
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+	defer func() { c.symbolTable = c.symbolTable.Outer }()
+
+	if node.Init != nil {
+		err := c.Compile(node.Init)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	loopStart := len(c.currentInstructions())
+	jumpNotTruthyPos := -1
+
+	if node.Condition != nil {
+		err := c.Compile(node.Condition)
+
+		if err != nil {
+			return err
+		}
+
+		jumpNotTruthyPos = c.emit(code.OpJumpNotTruthy, 9999)
+	}
+
+	c.enterLoop()
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+
+	err := c.Compile(node.Body)
+
+	c.symbolTable = c.symbolTable.Outer
+
+	if err != nil {
+		return err
+	}
+
+	postStart := len(c.currentInstructions())
+
+	if node.Post != nil {
+		err := c.Compile(node.Post)
+
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpPop)
+	}
+
+	c.emit(code.OpJump, loopStart)
+
+	if jumpNotTruthyPos != -1 {
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+	}
+
+	c.leaveLoop(postStart, len(c.currentInstructions()))
+
+	return nil
+}
+
+// compileForInStatement keeps the loop's iterator in a hidden slot, whose
+// name no identifier can spell. OpIterNext jumps out of the loop once the
+// iterator is done, leaving nothing on the stack, so break can jump to the
+// same place.
+func (c *Compiler) compileForInStatement(node *ast.ForInStatement) error {
+	err := c.Compile(node.Iterable)
+
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpIter)
+
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+	defer func() { c.symbolTable = c.symbolTable.Outer }()
+
+	iterator := c.symbolTable.Define("for-in iterator")
+	c.bindSymbol(iterator)
+
+	loopStart := len(c.currentInstructions())
+
+	c.loadSymbol(iterator)
+	iterNextPos := c.emit(code.OpIterNext, 9999)
+
+	c.bindSymbol(c.symbolTable.Define(node.Name.Value))
+
+	c.enterLoop()
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+
+	err = c.Compile(node.Body)
+
+	c.symbolTable = c.symbolTable.Outer
+
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpJump, loopStart)
+
+	afterLoop := len(c.currentInstructions())
+	c.changeOperand(iterNextPos, afterLoop)
+
+	c.leaveLoop(loopStart, afterLoop)
+
+	return nil
+}
+
+// compileDestructuringStatement keeps the value in a slot of its own and
+// binds each name to an index into it, as the evaluator does: the position
+// of the name in an array pattern, or its name in a hash pattern.
+func (c *Compiler) compileDestructuringStatement(node *ast.DestructuringStatement) error {
+	err := c.Compile(node.Value)
+
+	if err != nil {
+		return err
+	}
+
+	value := c.symbolTable.Define("destructured value")
+	c.bindSymbol(value)
+
+	for i, name := range node.Names {
+		var key object.Object = &object.Integer{Value: int64(i)}
+
+		if node.Hash {
+			key = object.Intern(name.Value)
+		}
+
+		c.loadSymbol(value)
+		c.emit(code.OpConstant, c.addConstant(key))
+		c.emit(code.OpIndex)
+		c.bindSymbol(c.define(name, node.Constant))
+	}
+
+	return nil
+}
+
+// compileCoalesceExpression leaves the left operand on the stack unless it is
+// null, in which case OpJumpNotNull drops it and the right operand is used.
+func (c *Compiler) compileCoalesceExpression(node *ast.InfixExpression) error {
+	err := c.Compile(node.Left)
+
+	if err != nil {
+		return err
+	}
+
+	jumpPos := c.emit(code.OpJumpNotNull, 9999)
+
+	err = c.Compile(node.Right)
+
+	if err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	return nil
+}
+
+// skipIfNull starts an optional index or slice, which is skipped when the
+// value it is taken of, on top of the stack, is null, leaving the null as
+// its result. It returns the jump for endSkip to point past the index, or
+// -1 if the index is not optional.
+func (c *Compiler) skipIfNull(optional bool) int {
+	if !optional {
+		return -1
+	}
+
+	notNullPos := c.emit(code.OpJumpNotNull, 9999)
+	c.emit(code.OpNull)
+	skipPos := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(notNullPos, len(c.currentInstructions()))
+
+	return skipPos
+}
+
+func (c *Compiler) endSkip(skipPos int) {
+	if skipPos >= 0 {
+		c.changeOperand(skipPos, len(c.currentInstructions()))
+	}
+}
+
+// compileLogicalExpression jumps over the right operand when the left one
+// already decides the result, leaving true or false on the stack.
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	err := c.Compile(node.Left)
+
+	if err != nil {
+		return err
+	}
+
+	if node.Operator == "&&" {
+		leftFalsePos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		err = c.Compile(node.Right)
+
+		if err != nil {
+			return err
+		}
+
+		rightFalsePos := c.emit(code.OpJumpNotTruthy, 9999)
+		c.emit(code.OpTrue)
+		jumpPos := c.emit(code.OpJump, 9999)
+
+		c.changeOperand(leftFalsePos, len(c.currentInstructions()))
+		c.changeOperand(rightFalsePos, len(c.currentInstructions()))
+		c.emit(code.OpFalse)
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+		return nil
+	}
+
+	leftFalsePos := c.emit(code.OpJumpNotTruthy, 9999)
+	c.emit(code.OpTrue)
+	leftTruePos := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(leftFalsePos, len(c.currentInstructions()))
+
+	err = c.Compile(node.Right)
+
+	if err != nil {
+		return err
+	}
+
+	rightFalsePos := c.emit(code.OpJumpNotTruthy, 9999)
+	c.emit(code.OpTrue)
+	rightTruePos := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(rightFalsePos, len(c.currentInstructions()))
+	c.emit(code.OpFalse)
+	c.changeOperand(leftTruePos, len(c.currentInstructions()))
+	c.changeOperand(rightTruePos, len(c.currentInstructions()))
+
+	return nil
+}
+
+func (c *Compiler) enterLoop() {
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, &loop{})
+}
+
+// leaveLoop patches the jumps of the innermost loop: continue jumps to
+// continueTarget and break jumps to breakTarget.
+func (c *Compiler) leaveLoop(continueTarget, breakTarget int) {
+	scope := &c.scopes[c.scopeIndex]
+	current := scope.loops[len(scope.loops)-1]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	for _, pos := range current.continues {
+		c.changeOperand(pos, continueTarget)
+	}
+
+	for _, pos := range current.breaks {
+		c.changeOperand(pos, breakTarget)
+	}
+}
+
+func (c *Compiler) currentLoop(keyword string) (*loop, error) {
+	loops := c.scopes[c.scopeIndex].loops
+
+	if len(loops) == 0 {
+		return nil, fmt.Errorf("%s outside of a loop", keyword)
+	}
+
+	return loops[len(loops)-1], nil
+}
+
+// endTries removes the handlers of the try blocks a break or continue jumps
+// out of, since it skips their OpEndTry.
+func (c *Compiler) endTries(l *loop) {
+	for i := 0; i < l.tries; i++ {
+		c.emit(code.OpEndTry)
+	}
+}
+
+// compileTryExpression brackets the try block with OpTry and OpEndTry. When
+// an error reaches the handler, the VM unwinds to it, pushes the message and
+// jumps to the catch block, which starts by storing the message in the catch
+// parameter.
+func (c *Compiler) compileTryExpression(node *ast.TryExpression) error {
+	loops := c.scopes[c.scopeIndex].loops
+	tryPos := c.emit(code.OpTry, 9999)
+
+	if len(loops) > 0 {
+		loops[len(loops)-1].tries++
+	}
+
+	err := c.compileBranch(node.Block)
+
+	if len(loops) > 0 {
+		loops[len(loops)-1].tries--
+	}
+
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpEndTry)
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(tryPos, len(c.currentInstructions()))
+
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+	defer func() { c.symbolTable = c.symbolTable.Outer }()
+
+	c.bindSymbol(c.symbolTable.Define(node.Parameter.Value))
+
+	err = c.compileBranch(node.Catch)
+
+	if err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	return nil
+}
+
+// compileSwitchExpression keeps the subject on the stack while the cases are
+// tried, comparing a copy of it with each value in turn, and pops it before
+// running the body that matched.
+func (c *Compiler) compileSwitchExpression(node *ast.SwitchExpression) error {
+	err := c.Compile(node.Subject)
+
+	if err != nil {
+		return err
+	}
+
+	endJumps := []int{}
+
+	for _, sc := range node.Cases {
+		bodyJumps := []int{}
+
+		for _, value := range sc.Values {
+			c.emit(code.OpDup)
+
+			err := c.Compile(value)
+
+			if err != nil {
+				return err
+			}
+
+			c.emit(code.OpEqual)
+			noMatchPos := c.emit(code.OpJumpNotTruthy, 9999)
+			bodyJumps = append(bodyJumps, c.emit(code.OpJump, 9999))
+			c.changeOperand(noMatchPos, len(c.currentInstructions()))
+		}
+
+		nextCasePos := c.emit(code.OpJump, 9999)
+
+		for _, pos := range bodyJumps {
+			c.changeOperand(pos, len(c.currentInstructions()))
+		}
+
+		c.emit(code.OpPop)
+
+		err := c.compileCaseBody(sc.Body)
+
+		if err != nil {
+			return err
+		}
+
+		endJumps = append(endJumps, c.emit(code.OpJump, 9999))
+		c.changeOperand(nextCasePos, len(c.currentInstructions()))
+	}
+
+	c.emit(code.OpPop)
+
+	if node.Default == nil {
+		c.emit(code.OpNull)
+	} else {
+		err := c.compileCaseBody(node.Default)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, pos := range endJumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+
+	return nil
+}
+
+// compileCaseBody is compileBranch for a case body, which directly follows
+// the OpPop of the subject: that pop must not be mistaken for the body's.
+func (c *Compiler) compileCaseBody(body *ast.BlockStatement) error {
+	if len(body.Statements) == 0 {
+		c.emit(code.OpNull)
+
+		return nil
+	}
+
+	return c.compileBranch(body)
+}
+
+// compileBranch compiles one arm of an if expression so that it always
+// leaves exactly one value on the stack, even when its last statement does
+// not produce one.
+func (c *Compiler) compileBranch(block *ast.BlockStatement) error {
+	err := c.Compile(block)
+
+	if err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	updatedInstructions := append(c.currentInstructions(), ins...)
+
+	c.scopes[c.scopeIndex].instructions = updatedInstructions
+
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	old := c.currentInstructions()
+	updated := old[:last.Position]
+
+	c.scopes[c.scopeIndex].instructions = updated
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operand)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
+
+// define declares a name a let, or a const when constant, binds.
+func (c *Compiler) define(name *ast.Identifier, constant bool) Symbol {
+	if constant {
+		return c.symbolTable.DefineConstant(name.Value)
+	}
+
+	return c.symbolTable.Define(name.Value)
+}
+
+// bindSymbol pops the top of the stack into the symbol a let just defined.
+// A symbol kept in a cell gets a new one, so each binding, such as each
+// pass of a loop's, is shared only by the closures made while it lasts.
+func (c *Compiler) bindSymbol(s Symbol) {
+	if s.Cell {
+		c.emit(code.OpCell)
+	}
+
+	if s.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, s.Index)
+	} else {
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+// storeSymbol pops the top of the stack into symbol. Globals and locals
+// are written in place and cells through the local or free variable that
+// holds them. Any other free variable is a copy nobody else would see, and
+// constants cannot be written at all.
+func (c *Compiler) storeSymbol(s Symbol) error {
+	if s.Constant {
+		return fmt.Errorf("cannot assign to constant: %s", s.Name)
+	}
+
+	switch {
+	case s.Cell:
+		c.loadCapture(s)
+		c.emit(code.OpSetCell)
+	case s.Scope == GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case s.Scope == LocalScope:
+		c.emit(code.OpSetLocal, s.Index)
+	default:
+		return fmt.Errorf("cannot assign to captured identifier: %s", s.Name)
+	}
+
+	return nil
+}
+
+// loadCapture pushes what a closure captures for s: its cell if it has
+// one, and otherwise its value.
+func (c *Compiler) loadCapture(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	default:
+		c.loadSymbol(s)
+	}
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	if s.Cell {
+		c.loadCapture(s)
+		c.emit(code.OpGetCell)
+
+		return
+	}
+
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
@@ -0,0 +1,167 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	BuiltinScope  SymbolScope = "BUILTIN"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+)
+
+type Symbol struct {
+	Name     string
+	Scope    SymbolScope
+	Index    int
+	Constant bool // bound by const, so it cannot be assigned
+	Cell     bool // shared with closures, so its slot holds an object.Cell
+	Block    bool // a global bound in a block, which closures capture like a local
+}
+
+// SymbolTable resolves identifiers to the slots the VM stores them in. A
+// block table (see NewBlockSymbolTable) shadows names like any other scope
+// but allocates its slots in the enclosing frame, so loop bodies get their
+// own bindings without needing a frame of their own.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+	block          bool
+	shared         map[string]bool // the names a function keeps in cells
+
+	FreeSymbols []Symbol
+}
+
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	free := []Symbol{}
+
+	return &SymbolTable{store: s, FreeSymbols: free}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+
+	return s
+}
+
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewEnclosedSymbolTable(outer)
+	s.block = true
+
+	return s
+}
+
+func (s *SymbolTable) Define(name string) Symbol {
+	symbol := s.allocate(name)
+	s.store[name] = symbol
+
+	return symbol
+}
+
+// DefineConstant is Define for a name bound by const.
+func (s *SymbolTable) DefineConstant(name string) Symbol {
+	symbol := s.allocate(name)
+	symbol.Constant = true
+	s.store[name] = symbol
+
+	return symbol
+}
+
+// allocate reserves the next slot in the frame this table belongs to.
+func (s *SymbolTable) allocate(name string) Symbol {
+	if s.block {
+		symbol := s.Outer.allocate(name)
+
+		if symbol.Scope == GlobalScope {
+			symbol.Block = true
+			symbol.Cell = s.root().shared[name]
+		}
+
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: s.numDefinitions}
+
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+		symbol.Cell = s.shared[name]
+	}
+
+	s.numDefinitions++
+
+	return symbol
+}
+
+// root returns the table of the frame s allocates its slots in.
+func (s *SymbolTable) root() *SymbolTable {
+	for s.block {
+		s = s.Outer
+	}
+
+	return s
+}
+
+// Globals returns the global symbols defined directly in s, in no
+// particular order.
+func (s *SymbolTable) Globals() []Symbol {
+	globals := []Symbol{}
+
+	for _, symbol := range s.store {
+		if symbol.Scope == GlobalScope {
+			globals = append(globals, symbol)
+		}
+	}
+
+	return globals
+}
+
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+
+	return symbol
+}
+
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = symbol
+
+	return symbol
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Constant: original.Constant, Cell: original.Cell}
+	symbol.Scope = FreeScope
+
+	s.store[original.Name] = symbol
+
+	return symbol
+}
+
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	obj, ok := s.store[name]
+
+	if ok || s.Outer == nil {
+		return obj, ok
+	}
+
+	obj, ok = s.Outer.Resolve(name)
+
+	if !ok || s.block {
+		return obj, ok
+	}
+
+	if (obj.Scope == GlobalScope && !obj.Block) || obj.Scope == BuiltinScope {
+		return obj, ok
+	}
+
+	return s.defineFree(obj), true
+}
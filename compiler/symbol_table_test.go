@@ -0,0 +1,78 @@
+package compiler
+
+import "testing"
+
+func TestDefineResolve(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("b")
+
+	nested := NewEnclosedSymbolTable(local)
+	nested.Define("c")
+
+	tests := []struct {
+		table    *SymbolTable
+		name     string
+		expected Symbol
+	}{
+		{global, "a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{local, "a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{local, "b", Symbol{Name: "b", Scope: LocalScope, Index: 0}},
+		{nested, "b", Symbol{Name: "b", Scope: FreeScope, Index: 0}},
+		{nested, "c", Symbol{Name: "c", Scope: LocalScope, Index: 0}},
+	}
+
+	for _, tt := range tests {
+		result, ok := tt.table.Resolve(tt.name)
+
+		if !ok {
+			t.Errorf("name %s not resolvable", tt.name)
+
+			continue
+		}
+
+		if result != tt.expected {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", tt.name, tt.expected, result)
+		}
+	}
+}
+
+func TestBlockSymbolTable(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	function := NewEnclosedSymbolTable(global)
+	function.Define("b")
+
+	block := NewBlockSymbolTable(function)
+	shadowed := block.Define("b")
+	inner := block.Define("c")
+
+	if shadowed != (Symbol{Name: "b", Scope: LocalScope, Index: 1}) {
+		t.Errorf("shadowed symbol wrong. got=%+v", shadowed)
+	}
+
+	if inner != (Symbol{Name: "c", Scope: LocalScope, Index: 2}) {
+		t.Errorf("block symbol wrong. got=%+v", inner)
+	}
+
+	if function.numDefinitions != 3 {
+		t.Errorf("block did not allocate in enclosing frame. got=%d", function.numDefinitions)
+	}
+
+	b, _ := function.Resolve("b")
+
+	if b.Index != 0 {
+		t.Errorf("block binding leaked into function scope. got=%+v", b)
+	}
+
+	if _, ok := function.Resolve("c"); ok {
+		t.Errorf("block binding c should not resolve outside the block")
+	}
+
+	if len(function.FreeSymbols) != 0 {
+		t.Errorf("block lookups should not create free symbols. got=%+v", function.FreeSymbols)
+	}
+}
@@ -0,0 +1,221 @@
+// Package coverage records which statements of a Monkey program ran, and
+// how often, and reports it line by line: as a source listing annotated
+// with counts or as an LCOV tracefile for other tools to read.
+package coverage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Line is how often the statements starting on a line of a file ran; a
+// line with several is counted by the one that ran most.
+type Line struct {
+	Number int
+	Hits   int
+}
+
+// Coverage collects the statements of the programs added to it, by file,
+// and counts the times each is run. It is safe to use from the goroutines
+// spawned tasks run on.
+type Coverage struct {
+	mu       sync.Mutex
+	files    map[string]map[int][]ast.Statement
+	programs map[*ast.Program]bool
+	hits     map[ast.Statement]int
+}
+
+func New() *Coverage {
+	return &Coverage{
+		files:    make(map[string]map[int][]ast.Statement),
+		programs: make(map[*ast.Program]bool),
+		hits:     make(map[ast.Statement]int),
+	}
+}
+
+// Add makes the statements of program, the contents of file, count toward
+// the file's coverage. Only the statements of programs and blocks are
+// counted, as those are the ones the evaluator reports running; code
+// inside a quote is left out, as it never runs as it is.
+func (c *Coverage) Add(file string, program *ast.Program) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.programs[program] {
+		return
+	}
+
+	c.programs[program] = true
+
+	lines, ok := c.files[file]
+
+	if !ok {
+		lines = make(map[int][]ast.Statement)
+		c.files[file] = lines
+	}
+
+	add := func(statements []ast.Statement) {
+		for _, stmt := range statements {
+			line := ast.StatementToken(stmt).Line
+			lines[line] = append(lines[line], stmt)
+		}
+	}
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Program:
+			add(node.Statements)
+		case *ast.BlockStatement:
+			add(node.Statements)
+		case *ast.CallExpression:
+			if ident, ok := node.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+				return false
+			}
+		}
+
+		return true
+	})
+}
+
+// Hit records that stmt ran once more.
+func (c *Coverage) Hit(stmt ast.Statement) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hits[stmt]++
+}
+
+// Files returns the files added so far, sorted.
+func (c *Coverage) Files() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files := make([]string, 0, len(c.files))
+
+	for file := range c.files {
+		files = append(files, file)
+	}
+
+	sort.Strings(files)
+
+	return files
+}
+
+// Forget leaves file out of the reports, as monkey test does for the test
+// files themselves.
+func (c *Coverage) Forget(file string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.files, file)
+}
+
+// Lines returns the lines of file that statements start on, in order.
+func (c *Coverage) Lines(file string) []Line {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lines := []Line{}
+
+	for number, statements := range c.files[file] {
+		line := Line{Number: number}
+
+		for _, stmt := range statements {
+			line.Hits = max(line.Hits, c.hits[stmt])
+		}
+
+		lines = append(lines, line)
+	}
+
+	sort.Slice(lines, func(i, j int) bool { return lines[i].Number < lines[j].Number })
+
+	return lines
+}
+
+// Summary returns how many of the lines of file that statements start on
+// ran at all, and how many there are.
+func (c *Coverage) Summary(file string) (covered, total int) {
+	for _, line := range c.Lines(file) {
+		if line.Hits > 0 {
+			covered++
+		}
+
+		total++
+	}
+
+	return covered, total
+}
+
+// Percent is covered as a percentage of total. Nothing to cover counts as
+// fully covered.
+func Percent(covered, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+
+	return 100 * float64(covered) / float64(total)
+}
+
+// WriteLCOV writes every file's lines to w as an LCOV tracefile.
+func (c *Coverage) WriteLCOV(w io.Writer) {
+	for _, file := range c.Files() {
+		fmt.Fprintf(w, "SF:%s\n", file)
+
+		covered, total := 0, 0
+
+		for _, line := range c.Lines(file) {
+			fmt.Fprintf(w, "DA:%d,%d\n", line.Number, line.Hits)
+
+			if line.Hits > 0 {
+				covered++
+			}
+
+			total++
+		}
+
+		fmt.Fprintf(w, "LH:%d\nLF:%d\nend_of_record\n", covered, total)
+	}
+}
+
+// WriteLCOVFile writes c's tracefile to the file at path.
+func (c *Coverage) WriteLCOVFile(path string) error {
+	var out bytes.Buffer
+
+	c.WriteLCOV(&out)
+
+	return os.WriteFile(path, out.Bytes(), 0o644)
+}
+
+// WriteListing writes source, the contents of file, to w with each line
+// marked by how often it ran: a count, ##### for a line that never did and
+// - for one no statement starts on, as gcov does.
+func (c *Coverage) WriteListing(w io.Writer, file string, source string) {
+	hits := make(map[int]int)
+
+	for _, line := range c.Lines(file) {
+		hits[line.Number] = line.Hits
+	}
+
+	covered, total := c.Summary(file)
+
+	fmt.Fprintf(w, "%s: %d of %d lines covered (%.1f%%)\n", file, covered, total, Percent(covered, total))
+
+	for i, text := range strings.Split(strings.TrimSuffix(source, "\n"), "\n") {
+		count, ok := hits[i+1]
+
+		switch {
+		case !ok:
+			fmt.Fprintf(w, "%6s: %s\n", "-", text)
+		case count == 0:
+			fmt.Fprintf(w, "%6s: %s\n", "#####", text)
+		default:
+			fmt.Fprintf(w, "%6d: %s\n", count, text)
+		}
+	}
+}
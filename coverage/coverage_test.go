@@ -0,0 +1,134 @@
+package coverage
+
+import (
+	"bytes"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+)
+
+const source = `let f = fn(x) {
+  if (x) { 1 } else { 2 }
+};
+let q = quote(fn() { 3 });
+f(true);
+`
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return program
+}
+
+// covered adds source to a Coverage as though f(true) had run once.
+func covered(t *testing.T) *Coverage {
+	program := parse(t, source)
+
+	c := New()
+	c.Add("f.mky", program)
+	c.Add("f.mky", program)
+
+	body := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral).Body
+	ifExpression := body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+
+	c.Hit(program.Statements[0])
+	c.Hit(program.Statements[1])
+	c.Hit(program.Statements[2])
+	c.Hit(body.Statements[0])
+	c.Hit(ifExpression.Consequence.Statements[0])
+
+	return c
+}
+
+func TestLines(t *testing.T) {
+	c := covered(t)
+
+	expected := []Line{{1, 1}, {2, 1}, {4, 1}, {5, 1}}
+	lines := c.Lines("f.mky")
+
+	if len(lines) != len(expected) {
+		t.Fatalf("wrong lines. expected=%v, got=%v", expected, lines)
+	}
+
+	for i, line := range lines {
+		if line != expected[i] {
+			t.Errorf("wrong line %d. expected=%v, got=%v", i, expected[i], line)
+		}
+	}
+
+	program := parse(t, "1;\n2;\n")
+	c.Add("g.mky", program)
+	c.Hit(program.Statements[1])
+
+	if covered, total := c.Summary("g.mky"); covered != 1 || total != 2 {
+		t.Errorf("wrong summary. expected=1 of 2, got=%d of %d", covered, total)
+	}
+
+	c.Forget("g.mky")
+
+	if files := c.Files(); len(files) != 1 || files[0] != "f.mky" {
+		t.Errorf("wrong files. got=%v", files)
+	}
+}
+
+func TestWriteLCOV(t *testing.T) {
+	c := covered(t)
+
+	program := parse(t, "1;\n2;\n")
+	c.Add("g.mky", program)
+	c.Hit(program.Statements[1])
+
+	var out bytes.Buffer
+
+	c.WriteLCOV(&out)
+
+	expected := `SF:f.mky
+DA:1,1
+DA:2,1
+DA:4,1
+DA:5,1
+LH:4
+LF:4
+end_of_record
+SF:g.mky
+DA:1,0
+DA:2,1
+LH:1
+LF:2
+end_of_record
+`
+
+	if out.String() != expected {
+		t.Errorf("wrong tracefile.\nexpected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestWriteListing(t *testing.T) {
+	c := New()
+	program := parse(t, "let x = 1;\nif (x > 1) {\n  x\n}\n")
+	c.Add("x.mky", program)
+	c.Hit(program.Statements[0])
+	c.Hit(program.Statements[1])
+	c.Hit(program.Statements[1])
+
+	var out bytes.Buffer
+
+	c.WriteListing(&out, "x.mky", "let x = 1;\nif (x > 1) {\n  x\n}\n")
+
+	expected := `x.mky: 2 of 3 lines covered (66.7%)
+     1: let x = 1;
+     2: if (x > 1) {
+ #####:   x
+     -: }
+`
+
+	if out.String() != expected {
+		t.Errorf("wrong listing.\nexpected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
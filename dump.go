@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"os"
+)
+
+// runDumpAST parses the script at path and writes its AST to out as JSON
+// instead of running it. The result is meant to be used as the process exit
+// code.
+func runDumpAST(path string, out io.Writer, errOut io.Writer) int {
+	source, err := os.ReadFile(path)
+
+	if err != nil {
+		fmt.Fprintf(errOut, "could not read %s: %s\n", path, err)
+
+		return 1
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(errOut, "%s: %s\n", path, msg)
+		}
+
+		return 1
+	}
+
+	encoded, err := ast.ToJSON(program)
+
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+
+		return 1
+	}
+
+	out.Write(encoded)
+	io.WriteString(out, "\n")
+
+	return 0
+}
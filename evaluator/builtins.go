@@ -2,20 +2,376 @@ package evaluator
 
 import (
 	"monkey/object"
+	"sort"
 )
 
 var builtins = map[string]*object.Builtin{
-	"len": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
-			switch arg := args[0].(type) {
-			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
-			default:
-				return newError("argument to `len` not supported, got %s", args[0].Type())
-			}
-		},
-	},
+	"len":          object.GetBuiltinByName("len"),
+	"puts":         object.GetBuiltinByName("puts"),
+	"first":        object.GetBuiltinByName("first"),
+	"last":         object.GetBuiltinByName("last"),
+	"rest":         object.GetBuiltinByName("rest"),
+	"push":         object.GetBuiltinByName("push"),
+	"split":        object.GetBuiltinByName("split"),
+	"join":         object.GetBuiltinByName("join"),
+	"contains":     object.GetBuiltinByName("contains"),
+	"replace":      object.GetBuiltinByName("replace"),
+	"trim":         object.GetBuiltinByName("trim"),
+	"upper":        object.GetBuiltinByName("upper"),
+	"lower":        object.GetBuiltinByName("lower"),
+	"indexOf":      object.GetBuiltinByName("indexOf"),
+	"keys":         object.GetBuiltinByName("keys"),
+	"values":       object.GetBuiltinByName("values"),
+	"entries":      object.GetBuiltinByName("entries"),
+	"delete":       object.GetBuiltinByName("delete"),
+	"isNull":       object.GetBuiltinByName("isNull"),
+	"print":        object.GetBuiltinByName("print"),
+	"printf":       object.GetBuiltinByName("printf"),
+	"format":       object.GetBuiltinByName("format"),
+	"readLine":     object.GetBuiltinByName("readLine"),
+	"readFile":     object.GetBuiltinByName("readFile"),
+	"writeFile":    object.GetBuiltinByName("writeFile"),
+	"args":         object.GetBuiltinByName("args"),
+	"isHashable":   object.GetBuiltinByName("isHashable"),
+	"assert":       object.GetBuiltinByName("assert"),
+	"assertEqual":  object.GetBuiltinByName("assertEqual"),
+	"charCodeAt":   object.GetBuiltinByName("charCodeAt"),
+	"fromCharCode": object.GetBuiltinByName("fromCharCode"),
+	"range":        object.GetBuiltinByName("range"),
+	"parseJSON":    object.GetBuiltinByName("parseJSON"),
+	"toJSON":       object.GetBuiltinByName("toJSON"),
+	"httpGet":      object.GetBuiltinByName("httpGet"),
+	"httpPost":     object.GetBuiltinByName("httpPost"),
+	"match":        object.GetBuiltinByName("match"),
+	"findAll":      object.GetBuiltinByName("findAll"),
+	"replaceRegex": object.GetBuiltinByName("replaceRegex"),
+	"abs":          object.GetBuiltinByName("abs"),
+	"min":          object.GetBuiltinByName("min"),
+	"max":          object.GetBuiltinByName("max"),
+	"pow":          object.GetBuiltinByName("pow"),
+	"sqrt":         object.GetBuiltinByName("sqrt"),
+	"floor":        object.GetBuiltinByName("floor"),
+	"ceil":         object.GetBuiltinByName("ceil"),
+	"round":        object.GetBuiltinByName("round"),
+	"random":       object.GetBuiltinByName("random"),
+	"randomInt":    object.GetBuiltinByName("randomInt"),
+	"seedRandom":   object.GetBuiltinByName("seedRandom"),
+	"now":          object.GetBuiltinByName("now"),
+	"timestamp":    object.GetBuiltinByName("timestamp"),
+	"formatTime":   object.GetBuiltinByName("formatTime"),
+	"parseTime":    object.GetBuiltinByName("parseTime"),
+	"int":          object.GetBuiltinByName("int"),
+	"float":        object.GetBuiltinByName("float"),
+	"str":          object.GetBuiltinByName("str"),
+	"bool":         object.GetBuiltinByName("bool"),
+	"type":         object.GetBuiltinByName("type"),
+	"deepEqual":    object.GetBuiltinByName("deepEqual"),
+	"compare":      object.GetBuiltinByName("compare"),
+	"freeze":       object.GetBuiltinByName("freeze"),
+	"isFrozen":     object.GetBuiltinByName("isFrozen"),
+	"exit":         object.GetBuiltinByName("exit"),
+	"panic":        object.GetBuiltinByName("panic"),
+	"env":          object.GetBuiltinByName("env"),
+	"setEnv":       object.GetBuiltinByName("setEnv"),
+	"exec":         object.GetBuiltinByName("exec"),
+	"inspect":      object.GetBuiltinByName("inspect"),
+	"bytes":        object.GetBuiltinByName("bytes"),
+	"hexEncode":    object.GetBuiltinByName("hexEncode"),
+	"hexDecode":    object.GetBuiltinByName("hexDecode"),
+	"base64Encode": object.GetBuiltinByName("base64Encode"),
+	"base64Decode": object.GetBuiltinByName("base64Decode"),
+	"set":          object.GetBuiltinByName("set"),
+	"has":          object.GetBuiltinByName("has"),
+	"union":        object.GetBuiltinByName("union"),
+	"intersect":    object.GetBuiltinByName("intersect"),
+	"difference":   object.GetBuiltinByName("difference"),
+}
+
+// The higher-order builtins call back into Monkey code through
+// applyFunction, import evaluates whole files, breakpoint needs the
+// evaluator's debugger, the concurrency builtins and sleep its scheduler
+// and delay its unevaluated argument, so they are registered here rather
+// than in the shared object.Builtins table. The VM has none of them but
+// sleep, which it runs itself.
+//
+// Registering them in init avoids an initialization cycle between builtins
+// and Eval.
+func init() {
+	builtins["map"] = &object.Builtin{Fn: builtinMap}
+	builtins["filter"] = &object.Builtin{Fn: builtinFilter}
+	builtins["reduce"] = &object.Builtin{Fn: builtinReduce}
+	builtins["sort"] = &object.Builtin{Fn: builtinSort}
+	builtins["reverse"] = &object.Builtin{Fn: builtinReverse}
+	importBuiltin = &object.Builtin{Fn: builtinImport}
+	builtins["import"] = importBuiltin
+	builtins["breakpoint"] = breakpoint
+	builtins["spawn"] = &object.Builtin{Fn: builtinSpawn}
+	builtins["wait"] = &object.Builtin{Fn: builtinWait}
+	builtins["channel"] = &object.Builtin{Fn: builtinChannel}
+	builtins["send"] = &object.Builtin{Fn: builtinSend}
+	builtins["recv"] = &object.Builtin{Fn: builtinRecv}
+	builtins["close"] = &object.Builtin{Fn: builtinClose}
+	builtins["sleep"] = &object.Builtin{Fn: builtinSleep}
+	builtins["delay"] = delay
+	builtins["force"] = &object.Builtin{Fn: builtinForce}
+	builtins["compose"] = &object.Builtin{Fn: builtinCompose}
+	builtins["curry"] = &object.Builtin{Fn: builtinCurry}
+	builtins["partial"] = &object.Builtin{Fn: builtinPartial}
+	builtins["recover"] = &object.Builtin{Fn: builtinRecover}
+}
+
+// BuiltinNames returns the names of the evaluator's builtin functions,
+// sorted.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+
+	for name := range builtins {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// IsBuiltin reports whether name is one of the evaluator's builtin
+// functions.
+func IsBuiltin(name string) bool {
+	_, ok := builtins[name]
+
+	return ok
+}
+
+// map(arr, fn) returns a new array holding fn(el) for every element.
+func builtinMap(args ...object.Object) object.Object {
+	arr, fn, err := arrayAndFunction("map", args)
+
+	if err != nil {
+		return err
+	}
+
+	elements := make([]object.Object, len(arr.Elements))
+
+	for i, el := range arr.Elements {
+		result := applyFunction(fn, []object.Object{el})
+
+		if isError(result) {
+			return result
+		}
+
+		elements[i] = result
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// filter(arr, fn) returns the elements for which fn(el) is truthy.
+func builtinFilter(args ...object.Object) object.Object {
+	arr, fn, err := arrayAndFunction("filter", args)
+
+	if err != nil {
+		return err
+	}
+
+	elements := []object.Object{}
+
+	for _, el := range arr.Elements {
+		result := applyFunction(fn, []object.Object{el})
+
+		if isError(result) {
+			return result
+		}
+
+		if isTruthy(result) {
+			elements = append(elements, el)
+		}
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// reduce(arr, initial, fn) folds the array from the left with fn(acc, el).
+func builtinReduce(args ...object.Object) object.Object {
+	if len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=3", len(args))
+	}
+
+	arr, err := arrayArgument("first argument to `reduce`", args[0])
+
+	if err != nil {
+		return err
+	}
+
+	fn := args[2]
+
+	if !isCallable(fn) {
+		return newError("third argument to `reduce` must be FUNCTION, got %s", fn.Type())
+	}
+
+	acc := args[1]
+
+	for _, el := range arr.Elements {
+		acc = applyFunction(fn, []object.Object{acc, el})
+
+		if isError(acc) {
+			return acc
+		}
+	}
+
+	return acc
+}
+
+// sort(arr) returns a sorted copy of an array of numbers or of strings.
+// sort(arr, fn) orders elements with fn(a, b), which returns true when a
+// belongs before b.
+func builtinSort(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	arr, err := arrayArgument("first argument to `sort`", args[0])
+
+	if err != nil {
+		return err
+	}
+	elements := make([]object.Object, len(arr.Elements))
+	copy(elements, arr.Elements)
+
+	var less func(a, b object.Object) object.Object
+
+	if len(args) == 2 {
+		if !isCallable(args[1]) {
+			return newError("second argument to `sort` must be FUNCTION, got %s", args[1].Type())
+		}
+
+		less = func(a, b object.Object) object.Object {
+			return applyFunction(args[1], []object.Object{a, b})
+		}
+	} else {
+		less = defaultLess
+	}
+
+	var sortErr object.Object
+
+	sort.SliceStable(elements, func(i, j int) bool {
+		if sortErr != nil {
+			return false
+		}
+
+		result := less(elements[i], elements[j])
+
+		if isError(result) {
+			sortErr = result
+
+			return false
+		}
+
+		return isTruthy(result)
+	})
+
+	if sortErr != nil {
+		return sortErr
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// reverse(arr) returns a copy of the array in reverse order.
+func builtinReverse(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	arr, err := arrayArgument("argument to `reverse`", args[0])
+
+	if err != nil {
+		return err
+	}
+	length := len(arr.Elements)
+	elements := make([]object.Object, length)
+
+	for i, el := range arr.Elements {
+		elements[length-1-i] = el
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// defaultLess orders numbers numerically and strings lexicographically.
+func defaultLess(a, b object.Object) object.Object {
+	c, err := object.Compare(a, b)
+
+	if err != nil {
+		return newError("%s in `sort`", err)
+	}
+
+	return nativeBoolToBooleanObject(c < 0)
+}
+
+func arrayAndFunction(name string, args []object.Object) (*object.Array, object.Object, *object.Error) {
+	if len(args) != 2 {
+		return nil, nil, newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	arr, err := arrayArgument("first argument to `"+name+"`", args[0])
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !isCallable(args[1]) {
+		return nil, nil, newError("second argument to `%s` must be FUNCTION, got %s", name, args[1].Type())
+	}
+
+	return arr, args[1], nil
+}
+
+// arrayArgument returns arg, which what describes, as an array. A Range
+// becomes the array of its elements.
+func arrayArgument(what string, arg object.Object) (*object.Array, *object.Error) {
+	arr, ok, err := object.AsArray(arg)
+
+	if err != nil {
+		return nil, newError("%s", err)
+	}
+
+	if !ok {
+		return nil, newError("%s must be ARRAY, got %s", what, arg.Type())
+	}
+
+	return arr, nil
+}
+
+func isCallable(obj object.Object) bool {
+	return obj.Type() == object.FUNCTION_OBJ || obj.Type() == object.BUILTIN_OBJ
+}
+
+// recover(f, args...) calls f with args and returns [result, null], or
+// [null, message] if the call raised an error, a panic included, so that
+// let value, err = recover(f) catches what try cannot. An exit and a
+// failed assertion still pass through.
+func builtinRecover(args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return newError("wrong number of arguments. got=0, want at least 1")
+	}
+
+	if !isCallable(args[0]) {
+		return newError("first argument to `recover` must be FUNCTION, got %s", args[0].Type())
+	}
+
+	result := applyFunction(args[0], append([]object.Object{}, args[1:]...))
+
+	if errObj, ok := result.(*object.Error); ok {
+		if errObj.Failure != nil || errObj.Exit != nil {
+			return errObj
+		}
+
+		return &object.Array{Elements: []object.Object{NULL, &object.String{Value: errObj.Message}}}
+	}
+
+	if result == nil {
+		result = NULL
+	}
+
+	return &object.Array{Elements: []object.Object{result, NULL}}
 }
@@ -0,0 +1,88 @@
+package evaluator
+
+import (
+	"monkey/object"
+)
+
+// The combinators build new functions out of existing ones. What they
+// return is a builtin that calls the functions it was made from, so it can
+// be passed around and called like any other function.
+
+// compose(f, g, h) returns a function that calls h with its arguments, then
+// g with the result, then f with that, so compose(f, g)(x) is f(g(x)).
+func builtinCompose(args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return newError("`compose` needs at least one function")
+	}
+
+	for _, arg := range args {
+		if !isCallable(arg) {
+			return newError("arguments to `compose` must be FUNCTION, got %s", arg.Type())
+		}
+	}
+
+	fns := append([]object.Object{}, args...)
+
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		result := applyFunction(fns[len(fns)-1], args)
+
+		for i := len(fns) - 2; i >= 0 && !isError(result); i-- {
+			result = applyFunction(fns[i], []object.Object{result})
+		}
+
+		return result
+	}}
+}
+
+// curry(f) returns a function that collects f's arguments over as many
+// calls as it takes, one or more at a time, and calls f once it has all
+// the arguments f requires, so curry(add)(1)(2) is add(1, 2). A builtin,
+// whose parameters are not known, cannot be curried.
+func builtinCurry(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	fn, ok := args[0].(*object.Function)
+
+	if !ok {
+		return newError("argument to `curry` must be FUNCTION, got %s", args[0].Type())
+	}
+
+	required, _ := arity(fn)
+
+	return curried(fn, required, nil)
+}
+
+// curried is fn waiting for the rest of its required arguments, given the
+// ones in collected so far.
+func curried(fn *object.Function, required int, collected []object.Object) object.Object {
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		all := append(append([]object.Object{}, collected...), args...)
+
+		if len(all) < required {
+			return curried(fn, required, all)
+		}
+
+		return applyFunction(fn, all)
+	}}
+}
+
+// partial(f, a, b) returns a function that calls f with a and b followed by
+// its own arguments, so partial(add, 1)(2) is add(1, 2).
+func builtinPartial(args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return newError("wrong number of arguments. got=0, want at least 1")
+	}
+
+	if !isCallable(args[0]) {
+		return newError("first argument to `partial` must be FUNCTION, got %s", args[0].Type())
+	}
+
+	fn := args[0]
+	bound := append([]object.Object{}, args[1:]...)
+
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		return applyFunction(fn, append(append([]object.Object{}, bound...), args...))
+	}}
+}
@@ -0,0 +1,404 @@
+package evaluator
+
+import (
+	"fmt"
+	"monkey/object"
+	"sync"
+)
+
+// Spawned functions run on goroutines of their own, but only one goroutine
+// evaluates Monkey code at a time: the one holding gil, which lets go of it
+// only while it waits on a channel or a task. Environments and values are
+// shared between tasks as they are between goroutines in Go, yet need no
+// locks of their own, and neither does the evaluator's global state.
+//
+// The goroutine that calls Eval holds gil from the start, so a spawned
+// function first runs when the program that spawned it waits.
+var gil sync.Mutex
+
+func init() {
+	gil.Lock()
+}
+
+// tasks counts the goroutines evaluating Monkey code, the first one
+// included, and sleeping holds the waiters of those blocked on a channel or
+// a task. Once every task is blocked nothing could ever wake them, so they
+// are all woken with a deadlock error instead. Both are only used while
+// holding gil.
+var (
+	tasks    = 1
+	sleeping = map[*waiter]bool{}
+)
+
+// waiter is a task blocked on a channel or another task. Whoever wakes it
+// hands it what it was waiting for.
+type waiter struct {
+	wake      chan struct{}
+	woken     bool
+	cancelled bool          // it stopped waiting, and must not be handed anything
+	deadlock  bool          // it was woken because every task was blocked
+	value     object.Object // sent to it, or being sent by it
+	closed    bool          // the channel was closed instead
+}
+
+func newWaiter(value object.Object) *waiter {
+	return &waiter{wake: make(chan struct{}), value: value}
+}
+
+// block lets go of gil until w is woken, or until the context of the
+// EvalContext call in progress is done.
+func block(w *waiter) *object.Error {
+	var done <-chan struct{}
+
+	b := limit
+
+	if b != nil {
+		done = b.ctx.Done()
+	}
+
+	sleeping[w] = true
+	checkDeadlock()
+
+	if !w.woken {
+		gil.Unlock()
+
+		select {
+		case <-w.wake:
+		case <-done:
+		}
+
+		gil.Lock()
+	}
+
+	if !w.woken {
+		w.cancelled = true
+		delete(sleeping, w)
+	}
+
+	// The tasks that would have woken w may have been stopped too.
+	if w.cancelled && b != nil && b.ctx.Err() != nil {
+		return newError("evaluation stopped: %s", b.ctx.Err())
+	}
+
+	if w.deadlock {
+		return newError("deadlock: every task is waiting")
+	}
+
+	return nil
+}
+
+func (w *waiter) wakeUp() {
+	w.woken = true
+	delete(sleeping, w)
+	close(w.wake)
+}
+
+// checkDeadlock wakes every task with a deadlock error if all of them are
+// blocked.
+func checkDeadlock() {
+	if len(sleeping) < tasks {
+		return
+	}
+
+	for w := range sleeping {
+		w.deadlock = true
+		w.cancelled = true
+		w.wakeUp()
+	}
+}
+
+// pop removes the first waiter in queue that is still waiting.
+func pop(queue *[]*waiter) *waiter {
+	for len(*queue) > 0 {
+		w := (*queue)[0]
+		*queue = (*queue)[1:]
+
+		if !w.cancelled {
+			return w
+		}
+	}
+
+	return nil
+}
+
+// Channel carries values between tasks. Sending blocks until a task
+// receives, or while the channel's buffer of capacity values is full.
+type Channel struct {
+	capacity  int
+	buffer    []object.Object
+	closed    bool
+	senders   []*waiter
+	receivers []*waiter
+}
+
+func (c *Channel) Type() object.ObjectType { return object.CHANNEL_OBJ }
+func (c *Channel) Inspect() string         { return fmt.Sprintf("channel(%d)", c.capacity) }
+
+func (c *Channel) send(value object.Object) *object.Error {
+	if c.closed {
+		return newError("send on closed channel")
+	}
+
+	if r := pop(&c.receivers); r != nil {
+		r.value = value
+		r.wakeUp()
+
+		return nil
+	}
+
+	if len(c.buffer) < c.capacity {
+		c.buffer = append(c.buffer, value)
+
+		return nil
+	}
+
+	w := newWaiter(value)
+	c.senders = append(c.senders, w)
+
+	if err := block(w); err != nil {
+		return err
+	}
+
+	if w.closed {
+		return newError("send on closed channel")
+	}
+
+	return nil
+}
+
+// recv returns the next value sent on c, or null once c is closed and
+// drained.
+func (c *Channel) recv() object.Object {
+	if len(c.buffer) > 0 {
+		value := c.buffer[0]
+		c.buffer = c.buffer[1:]
+
+		if s := pop(&c.senders); s != nil {
+			c.buffer = append(c.buffer, s.value)
+			s.wakeUp()
+		}
+
+		return value
+	}
+
+	if s := pop(&c.senders); s != nil {
+		s.wakeUp()
+
+		return s.value
+	}
+
+	if c.closed {
+		return NULL
+	}
+
+	w := newWaiter(nil)
+	c.receivers = append(c.receivers, w)
+
+	if err := block(w); err != nil {
+		return err
+	}
+
+	if w.closed {
+		return NULL
+	}
+
+	return w.value
+}
+
+func (c *Channel) close() *object.Error {
+	if c.closed {
+		return newError("close of closed channel")
+	}
+
+	c.closed = true
+
+	for _, queue := range []*[]*waiter{&c.receivers, &c.senders} {
+		for w := pop(queue); w != nil; w = pop(queue) {
+			w.closed = true
+			w.wakeUp()
+		}
+	}
+
+	return nil
+}
+
+// Task is a function running on a goroutine of its own, started by spawn.
+type Task struct {
+	done    bool
+	result  object.Object
+	waiters []*waiter
+}
+
+func (t *Task) Type() object.ObjectType { return object.TASK_OBJ }
+func (t *Task) Inspect() string {
+	if t.done {
+		return "task(done)"
+	}
+
+	return "task(running)"
+}
+
+// spawn(fn, args...) calls fn with args on a new task and returns the task.
+func builtinSpawn(args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return newError("wrong number of arguments. got=0, want at least 1")
+	}
+
+	fn := args[0]
+
+	if !isCallable(fn) {
+		return newError("first argument to `spawn` must be FUNCTION, got %s", fn.Type())
+	}
+
+	t := &Task{}
+	fnArgs := append([]object.Object{}, args[1:]...)
+
+	tasks++
+
+	go func() {
+		gil.Lock()
+		defer gil.Unlock()
+
+		t.result = applyFunction(fn, fnArgs)
+		t.done = true
+		tasks--
+
+		for w := pop(&t.waiters); w != nil; w = pop(&t.waiters) {
+			w.wakeUp()
+		}
+
+		checkDeadlock()
+	}()
+
+	return t
+}
+
+// wait(task) waits for task to finish and returns its result. An error the
+// task ended with is raised again in the waiting one.
+func builtinWait(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	t, ok := args[0].(*Task)
+
+	if !ok {
+		return newError("argument to `wait` must be TASK, got %s", args[0].Type())
+	}
+
+	if !t.done {
+		w := newWaiter(nil)
+		t.waiters = append(t.waiters, w)
+
+		if err := block(w); err != nil {
+			return err
+		}
+	}
+
+	return t.result
+}
+
+// channel(capacity = 0) returns a new channel.
+func builtinChannel(args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+	}
+
+	if len(args) == 0 {
+		return &Channel{}
+	}
+
+	capacity, ok := args[0].(*object.Integer)
+
+	if !ok || capacity.Value < 0 {
+		return newError("argument to `channel` must be a non-negative INTEGER, got %s", args[0].Inspect())
+	}
+
+	return &Channel{capacity: int(capacity.Value)}
+}
+
+func channelArgument(name string, args []object.Object, want int) (*Channel, *object.Error) {
+	if len(args) != want {
+		return nil, newError("wrong number of arguments. got=%d, want=%d", len(args), want)
+	}
+
+	c, ok := args[0].(*Channel)
+
+	if !ok {
+		return nil, newError("first argument to `%s` must be CHANNEL, got %s", name, args[0].Type())
+	}
+
+	return c, nil
+}
+
+// send(ch, value) sends value on ch.
+func builtinSend(args ...object.Object) object.Object {
+	c, err := channelArgument("send", args, 2)
+
+	if err != nil {
+		return err
+	}
+
+	if err := c.send(args[1]); err != nil {
+		return err
+	}
+
+	return NULL
+}
+
+// recv(ch) receives the next value sent on ch, or null once ch is closed
+// and every value sent before has been received.
+func builtinRecv(args ...object.Object) object.Object {
+	c, err := channelArgument("recv", args, 1)
+
+	if err != nil {
+		return err
+	}
+
+	return c.recv()
+}
+
+// close(ch) closes ch: sending on it fails from then on, and receiving
+// returns null once its buffer is drained.
+func builtinClose(args ...object.Object) object.Object {
+	c, err := channelArgument("close", args, 1)
+
+	if err != nil {
+		return err
+	}
+
+	if err := c.close(); err != nil {
+		return err
+	}
+
+	return NULL
+}
+
+// sleep(ms) pauses the task for ms milliseconds, letting the others run in
+// the meantime. It stops early, like the rest of the program, when the
+// context of the EvalContext call in progress is done.
+func builtinSleep(args ...object.Object) object.Object {
+	d, err := object.SleepDuration(args)
+
+	if err != nil {
+		return err
+	}
+
+	var done <-chan struct{}
+
+	b := limit
+
+	if b != nil {
+		done = b.ctx.Done()
+	}
+
+	gil.Unlock()
+	slept := object.Sleep(d, done)
+	gil.Lock()
+
+	if !slept {
+		return b.spend(newError("evaluation stopped: %s", b.ctx.Err()))
+	}
+
+	return NULL
+}
@@ -0,0 +1,65 @@
+package evaluator
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestConcurrency(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let ch = channel(); spawn(fn() { send(ch, 42) }); recv(ch)`, 42},
+		{`let t = spawn(fn(a, b) { a * b }, 6, 7); wait(t)`, 42},
+		{`let t = spawn(fn() { 1 }); [wait(t), wait(t)]`, []interface{}{1, 1}},
+		{`let ch = channel(2);
+		spawn(fn() { for (let i = 1; i <= 5; i++) { send(ch, i) }; close(ch) });
+		let sum = 0;
+		let v = recv(ch);
+		while (!isNull(v)) { sum += v; v = recv(ch) };
+		sum`, 15},
+		{`let n = 0;
+		let tasks = map([1, 2, 3], fn(i) { spawn(fn() { n += i }) });
+		map(tasks, wait);
+		n`, 6},
+		{`let ping = channel(); let pong = channel();
+		spawn(fn() { let v = recv(ping); while (v < 10) { send(pong, v + 1); v = recv(ping) }; close(pong) });
+		let v = 0;
+		while (!isNull(v)) { send(ping, v + 1); v = recv(pong) };
+		"done"`, "done"},
+		{`let ch = channel(1); ch.send(5); ch.recv()`, 5},
+		{`spawn(fn() { 1 }).wait()`, 1},
+		{`let ch = channel(1); send(ch, 1); close(ch); [recv(ch), recv(ch)]`, []interface{}{1, nil}},
+		{`let ch = channel(); spawn(fn() { close(ch) }); recv(ch)`, nil},
+		{`recv(channel())`, "deadlock: every task is waiting"},
+		{`send(channel(), 1)`, "deadlock: every task is waiting"},
+		{`let ch = channel(); spawn(fn() { 1 }); recv(ch)`, "deadlock: every task is waiting"},
+		{`let ch = channel(); let t = spawn(fn() { recv(ch) }); wait(t)`, "deadlock: every task is waiting"},
+		{`let ch = channel(); try { recv(ch) } catch (e) { e }`, "deadlock: every task is waiting"},
+		{`let ch = channel(); close(ch); send(ch, 1)`, "send on closed channel"},
+		{`let ch = channel(); close(ch); close(ch)`, "close of closed channel"},
+		{`let ch = channel(); spawn(fn() { close(ch) }); send(ch, 1)`, "send on closed channel"},
+		{`wait(spawn(fn() { 1 + true }))`, "type mismatch: INTEGER + BOOLEAN"},
+		{`spawn(1)`, "first argument to `spawn` must be FUNCTION, got INTEGER"},
+		{`spawn()`, "wrong number of arguments. got=0, want at least 1"},
+		{`wait(1)`, "argument to `wait` must be TASK, got INTEGER"},
+		{`recv([])`, "first argument to `recv` must be CHANNEL, got ARRAY"},
+		{`channel(-1)`, "argument to `channel` must be a non-negative INTEGER, got -1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		testResult(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+func TestBlockedTaskStopsWithContext(t *testing.T) {
+	input := `let ch = channel(); spawn(fn() { while (true) { } }); recv(ch)`
+
+	evaluated := testEvalLimited(context.Background(), input, Limits{Timeout: 10 * time.Millisecond})
+
+	testResult(t, input, evaluated, "evaluation stopped: context deadline exceeded")
+}
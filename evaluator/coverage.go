@@ -0,0 +1,32 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/coverage"
+)
+
+var (
+	cover     *coverage.Coverage
+	coverMain string
+)
+
+// SetCoverage makes the evaluator add every program it runs to c, under the
+// file being evaluated, and report each statement it runs. A program run
+// outside of any file, as a script passed to Eval is, counts as main, or
+// is left out if main is empty. nil turns coverage off again.
+func SetCoverage(c *coverage.Coverage, main string) {
+	cover = c
+	coverMain = main
+}
+
+func coverProgram(program *ast.Program) {
+	file := coverMain
+
+	if len(loading) > 0 {
+		file = loading[len(loading)-1]
+	}
+
+	if file != "" {
+		cover.Add(file, program)
+	}
+}
@@ -0,0 +1,36 @@
+package evaluator
+
+import (
+	"monkey/coverage"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	c := coverage.New()
+
+	SetCoverage(c, "main.mky")
+	defer SetCoverage(nil, "")
+
+	testEval(`let sign = fn(x) {
+  if (x < 0) {
+    return -1;
+  }
+  1
+};
+for (let i = 0; i < 3; i += 1) {
+  sign(i);
+}`)
+
+	expected := []coverage.Line{{Number: 1, Hits: 1}, {Number: 2, Hits: 3}, {Number: 3, Hits: 0}, {Number: 5, Hits: 3}, {Number: 7, Hits: 1}, {Number: 8, Hits: 3}}
+	lines := c.Lines("main.mky")
+
+	if len(lines) != len(expected) {
+		t.Fatalf("wrong lines. expected=%v, got=%v", expected, lines)
+	}
+
+	for i, line := range lines {
+		if line != expected[i] {
+			t.Errorf("wrong line %d. expected=%v, got=%v", i, expected[i], line)
+		}
+	}
+}
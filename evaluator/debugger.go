@@ -0,0 +1,52 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+// Debugger is told what the evaluator is about to do, so a tool can pause a
+// running program and look around. Statement is called before each
+// statement of a program, block or function body, and Breakpoint for each
+// call to breakpoint(). Evaluation carries on once the call returns.
+type Debugger interface {
+	Statement(stmt ast.Statement, env *object.Environment)
+	Breakpoint(call *ast.CallExpression, env *object.Environment)
+}
+
+var debugger Debugger
+
+// SetDebugger makes d the debugger of every later evaluation. nil removes
+// it, and breakpoint() then does nothing.
+func SetDebugger(d Debugger) {
+	debugger = d
+}
+
+// breakpoint is only a marker: a call to it is handled by evalBreakpoint,
+// which has the caller's environment. Called any other way, such as through
+// map, it does nothing.
+var breakpoint = &object.Builtin{Fn: func(args ...object.Object) object.Object {
+	return nil
+}}
+
+func evalBreakpoint(call *ast.CallExpression, env *object.Environment) object.Object {
+	if len(call.Arguments) != 0 {
+		return withPosition(newError("wrong number of arguments. got=%d, want=0", len(call.Arguments)), call.Token)
+	}
+
+	if debugger != nil {
+		debugger.Breakpoint(call, env)
+	}
+
+	return NULL
+}
+
+func traceStatement(stmt ast.Statement, env *object.Environment) {
+	if cover != nil {
+		cover.Hit(stmt)
+	}
+
+	if debugger != nil {
+		debugger.Statement(stmt, env)
+	}
+}
@@ -0,0 +1,69 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+	"testing"
+)
+
+type recordingDebugger struct {
+	statements []string
+	breaks     []int64
+}
+
+func (r *recordingDebugger) Statement(stmt ast.Statement, env *object.Environment) {
+	r.statements = append(r.statements, stmt.String())
+}
+
+func (r *recordingDebugger) Breakpoint(call *ast.CallExpression, env *object.Environment) {
+	val, _ := env.Get("i")
+	r.breaks = append(r.breaks, val.(*object.Integer).Value)
+}
+
+func TestDebuggerHooks(t *testing.T) {
+	input := `
+	let loop = fn(i) { if (i > 0) { breakpoint(); loop(i - 1) } else { 0 } };
+	loop(2);
+	`
+
+	r := &recordingDebugger{}
+
+	SetDebugger(r)
+	defer SetDebugger(nil)
+
+	testIntegerObject(t, testEval(input), 0)
+
+	body := "if(i > 0) breakpoint()loop((i - 1))else 0"
+	expected := []string{
+		"let loop = fn(i)" + body + ";",
+		"loop(2)",
+		body, "breakpoint()", "loop((i - 1))",
+		body, "breakpoint()", "loop((i - 1))",
+		body, "0",
+	}
+
+	if len(r.statements) != len(expected) {
+		t.Fatalf("wrong number of statements. want=%d, got=%q", len(expected), r.statements)
+	}
+
+	for i, want := range expected {
+		if r.statements[i] != want {
+			t.Errorf("statement %d wrong. want=%q, got=%q", i, want, r.statements[i])
+		}
+	}
+
+	if len(r.breaks) != 2 || r.breaks[0] != 2 || r.breaks[1] != 1 {
+		t.Errorf("wrong breakpoints. got=%v", r.breaks)
+	}
+}
+
+func TestBreakpointWithoutDebugger(t *testing.T) {
+	testNullObject(t, testEval("breakpoint()"))
+
+	evaluated := testEval("breakpoint(1)")
+	errObj, ok := evaluated.(*object.Error)
+
+	if !ok || errObj.Message != "wrong number of arguments. got=1, want=0" {
+		t.Errorf("wrong result. got=%+v", evaluated)
+	}
+}
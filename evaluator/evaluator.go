@@ -2,21 +2,48 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"monkey/ast"
 	"monkey/object"
+	"monkey/token"
+	"strings"
+	"unicode/utf8"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = object.NULL
+	TRUE     = object.TRUE
+	FALSE    = object.FALSE
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	if limit != nil {
+		if err := limit.step(); err != nil {
+			return err
+		}
+	}
+
+	if tracer != nil {
+		return evalTraced(node, env)
+	}
+
+	return eval(node, env)
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 
 	// Statements
 	case *ast.Program:
+		if cover != nil {
+			coverProgram(node)
+		}
+
+		resolveProgram(node, env)
+
 		return evalProgram(node, env)
 
 	case *ast.BlockStatement:
@@ -32,86 +59,282 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return &object.ReturnValue{Value: val}
 
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+
+	case *ast.ForInStatement:
+		return evalForInStatement(node, env)
+
+	case *ast.BreakStatement:
+		return BREAK
+
+	case *ast.ContinueStatement:
+		return CONTINUE
+
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		define(node.Name, env, val)
+
+		if node.Constant {
+			env.Freeze(node.Name.Value)
+		}
+
+	case *ast.DestructuringStatement:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+
+		if err := destructure(node, val, env); err != nil {
+			return withPosition(err, node.Token)
+		}
 
 	// Expressions
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		if node.Big != nil {
+			return &object.BigInt{Value: node.Big}
+		}
+
+		return object.NewInteger(node.Value)
+
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
+		right := evalStrict(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return withPosition(evalPrefixExpression(node.Operator, right), node.Token)
 
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, env)
+		}
+
+		if node.Operator == "??" {
+			return evalCoalesceExpression(node, env)
+		}
+
+		left := evalStrict(node.Left, env)
 		if isError(left) {
 			return left
 		}
 
-		right := Eval(node.Right, env)
+		right := evalStrict(node.Right, env)
 		if isError(right) {
 			return right
 		}
 
-		return evalInfixExpression(node.Operator, left, right)
+		return withPosition(evalInfixExpression(node.Operator, left, right), node.Token)
+
+	case *ast.AssignExpression:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+
+		if !assign(node.Name, env, val) {
+			return withPosition(assignError(node.Name, env), node.Token)
+		}
+
+		return val
+
+	case *ast.PostfixExpression:
+		return withPosition(evalPostfixExpression(node, env), node.Token)
 
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
 
+	case *ast.ConditionalExpression:
+		condition := evalStrict(node.Condition, env)
+
+		if isError(condition) {
+			return condition
+		}
+
+		if isTruthy(condition) {
+			return Eval(node.Consequence, env)
+		}
+
+		return Eval(node.Alternative, env)
+
+	case *ast.SwitchExpression:
+		return evalSwitchExpression(node, env)
+
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return withPosition(evalIdentifier(node, env), node.Token)
 
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
 
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{
+			Name:       node.Name,
+			Parameters: params,
+			Defaults:   node.Defaults,
+			Variadic:   node.Variadic,
+			Env:        env,
+			Body:       body,
+		}
+
+	case *ast.MacroLiteral:
+		// DefineMacros takes the macros out of a program before it runs, so
+		// any left are not bound by a let at the top level.
+		return withPosition(newError("a macro must be bound by a top-level let"), node.Token)
 
 	case *ast.StringLiteral:
-		return &object.String{Value: node.Value}
+		return object.Intern(node.Value)
+
+	case *ast.StringInterpolation:
+		return evalStringInterpolation(node, env)
+
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+
+		return &object.Array{Elements: elements}
+
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+
+	case *ast.IndexExpression:
+		left := evalStrict(node.Left, env)
+
+		if isError(left) || (node.Optional && left == NULL) {
+			return left
+		}
+
+		if name, ok := ast.PropertyName(node.Index); ok {
+			return withPosition(evalPropertyExpression(left, name), node.Token)
+		}
+
+		index := evalStrict(node.Index, env)
+
+		if isError(index) {
+			return index
+		}
+
+		return withPosition(evalIndexExpression(left, index), node.Token)
+
+	case *ast.SliceExpression:
+		left := evalStrict(node.Left, env)
+
+		if isError(left) || (node.Optional && left == NULL) {
+			return left
+		}
+
+		var low, high object.Object
+
+		if node.Low != nil {
+			low = evalStrict(node.Low, env)
+
+			if isError(low) {
+				return low
+			}
+		}
+
+		if node.High != nil {
+			high = evalStrict(node.High, env)
+
+			if isError(high) {
+				return high
+			}
+		}
+
+		return withPosition(evalSliceExpression(left, low, high), node.Token)
+
+	case *ast.IndexAssignment:
+		left := evalStrict(node.Left, env)
+
+		if isError(left) {
+			return left
+		}
+
+		index := evalStrict(node.Index, env)
+
+		if isError(index) {
+			return index
+		}
+
+		val := Eval(node.Value, env)
+
+		if isError(val) {
+			return val
+		}
+
+		return withPosition(evalIndexAssignment(left, index, val), node.Token)
 
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
+		if node.Function.TokenLiteral() == "quote" {
+			if len(node.Arguments) != 1 {
+				return withPosition(newError("wrong number of arguments. got=%d, want=1", len(node.Arguments)), node.Token)
+			}
+
+			return quote(node.Arguments[0], env)
+		}
+
+		function := evalStrict(node.Function, env)
 
 		if isError(function) {
 			return function
 		}
 
-		args := evalExpressions(node.Arguments, env)
+		if function == breakpoint {
+			return evalBreakpoint(node, env)
+		}
+
+		if function == delay {
+			return evalDelay(node, env)
+		}
+
+		args := evalArguments(node.Arguments, env)
 
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		if node.Named != nil {
+			var err object.Object
+
+			if args, err = bindNamedArguments(function, args, node.Named, env); err != nil {
+				return withPosition(err, node.Token)
+			}
+		}
+
+		result := withPosition(applyFunction(function, args), node.Token)
+
+		return withStackFrame(result, function, node.Token)
 	}
 
 	return nil
 }
 
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
-	if input {
-		return TRUE
-	}
-
-	return FALSE
+	return object.NativeBool(input)
 }
 
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range program.Statements {
+		traceStatement(statement, env)
+
 		result = Eval(statement, env)
 
 		switch result := result.(type) {
@@ -119,6 +342,8 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 			return result.Value
 		case *object.Error:
 			return result
+		case *object.Break, *object.Continue:
+			return newError("%s outside of a loop", result.Inspect())
 		}
 	}
 
@@ -129,26 +354,37 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	var result object.Object
 
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		traceStatement(statement, env)
 
-		if result != nil {
-			rt := result.Type()
+		result = Eval(statement, env)
 
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
-				return result
-			}
+		if result != nil && isUnwinding(result) {
+			return result
 		}
 	}
 
 	return result
 }
 
+// isUnwinding reports whether obj must stop the evaluation of the current
+// block and travel up to an enclosing loop, function or program.
+func isUnwinding(obj object.Object) bool {
+	switch obj.Type() {
+	case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+		return true
+	default:
+		return false
+	}
+}
+
 func evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusOperatorExpression(right)
+	case "~":
+		return evalBitNotOperatorExpression(right)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
@@ -167,14 +403,34 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 	}
 }
 
-func evalMinusOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+// evalBitNotOperatorExpression flips every bit of an integer, so ~x is
+// -x - 1.
+func evalBitNotOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return object.NewInteger(^right.Value)
+	case *object.BigInt:
+		return object.NormalizeInt(new(big.Int).Not(right.Value))
+	default:
+		return newError("unknown operator: ~%s", right.Type())
 	}
+}
 
-	value := right.(*object.Integer).Value
+func evalMinusOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return object.NormalizeInt(new(big.Int).Neg(big.NewInt(right.Value)))
+		}
 
-	return &object.Integer{Value: -value}
+		return object.NewInteger(-right.Value)
+	case *object.BigInt:
+		return object.NormalizeInt(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError("unknown operator: -%s", right.Type())
+	}
 }
 
 func evalInfixExpression(
@@ -184,12 +440,18 @@ func evalInfixExpression(
 	switch {
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
+	case left.Type() == object.BYTES_OBJ && right.Type() == object.BYTES_OBJ && operator == "+":
+		return object.ConcatBytes(left.(*object.Bytes), right.(*object.Bytes))
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isInteger(left) && isInteger(right):
+		return evalBigIntInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(object.Equal(left, right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
@@ -209,144 +471,1060 @@ func evalIntegerInfixExpression(
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
 		return nativeBoolToBooleanObject(leftVal != rightVal)
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		if result, ok := object.AddInt(leftVal, rightVal); ok {
+			return object.NewInteger(result)
+		}
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		if result, ok := object.SubInt(leftVal, rightVal); ok {
+			return object.NewInteger(result)
+		}
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		if result, ok := object.MulInt(leftVal, rightVal); ok {
+			return object.NewInteger(result)
+		}
 	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+		if rightVal == 0 {
+			return newError("division by zero: %d / 0", leftVal)
+		}
+
+		if result, ok := object.DivInt(leftVal, rightVal); ok {
+			return object.NewInteger(result)
+		}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero: %d %% 0", leftVal)
+		}
+
+		return object.NewInteger(leftVal % rightVal)
+	case "**":
+		if rightVal < 0 {
+			return &object.Float{Value: math.Pow(float64(leftVal), float64(rightVal))}
+		}
+
+		if result, ok := object.PowInt(leftVal, rightVal); ok {
+			return object.NewInteger(result)
+		}
+	case "&":
+		return object.NewInteger(leftVal & rightVal)
+	case "|":
+		return object.NewInteger(leftVal | rightVal)
+	case "^":
+		return object.NewInteger(leftVal ^ rightVal)
+	case "<<":
+		if result, ok := object.ShiftLeftInt(leftVal, rightVal); ok {
+			return object.NewInteger(result)
+		}
+	case ">>":
+		if rightVal >= 0 {
+			return object.NewInteger(leftVal >> min(rightVal, 63))
+		}
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
+
+	// The result overflowed an int64, or the operation is an error that
+	// BigArithmetic reports.
+	return evalBigIntInfixExpression(operator, left, right)
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
+// evalBigIntInfixExpression handles integers that are BigInts, or whose
+// result would be one.
+func evalBigIntInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftVal, _ := object.ToBigInt(left)
+	rightVal, _ := object.ToBigInt(right)
 
-	if isError(condition) {
-		return condition
-	}
+	switch operator {
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	case "+", "-", "*", "/", "%", "**", "&", "|", "^", "<<", ">>":
+		result, err := object.BigArithmetic(operator, leftVal, rightVal)
 
-	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
-	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
-	} else {
-		return NULL
+		if err != nil {
+			return newError("%s", err)
+		}
+
+		return result
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
-func evalIdentifier(
-	node *ast.Identifier,
-	env *object.Environment,
+// evalFloatInfixExpression handles float operands as well as mixed
+// integer/float operands, which are promoted to float.
+func evalFloatInfixExpression(
+	operator string,
+	left, right object.Object,
 ) object.Object {
-	if val, ok := env.Get(node.Value); ok {
-		return val
-	}
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
 
-	if builtin, ok := builtins[node.Value]; ok {
-		return builtin
+	switch operator {
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero: %s %% 0", left.Inspect())
+		}
+
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
+}
 
-	return newError("identifier not found: %s", node.Value)
+func isNumber(obj object.Object) bool {
+	return isInteger(obj) || obj.Type() == object.FLOAT_OBJ
 }
 
-func isTruthy(obj object.Object) bool {
-	switch obj {
-	case NULL:
-		return false
-	case TRUE:
-		return true
-	case FALSE:
-		return false
+func isInteger(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.BIGINT_OBJ
+}
+
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInt:
+		return obj.Float()
+	case *object.Float:
+		return obj.Value
 	default:
-		return true
+		return 0
 	}
 }
 
-func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
-}
+// evalPostfixExpression adds or subtracts one from a numeric binding and
+// returns the value it had before.
+func evalPostfixExpression(pe *ast.PostfixExpression, env *object.Environment) object.Object {
+	current, ok := lookup(pe.Name, env)
 
-func isError(obj object.Object) bool {
-	if obj != nil {
-		return obj.Type() == object.ERROR_OBJ
+	if !ok {
+		return newError("cannot assign to unbound identifier: %s", pe.Name.Value)
 	}
 
-	return false
-}
+	current = force(current)
 
-func evalExpressions(
-	exps []ast.Expression,
-	env *object.Environment,
-) []object.Object {
-	var result []object.Object
+	if isError(current) {
+		return current
+	}
 
-	for _, e := range exps {
-		evaluated := Eval(e, env)
+	if !isNumber(current) {
+		return newError("unknown operator: %s%s", current.Type(), pe.Operator)
+	}
 
-		if isError(evaluated) {
-			return []object.Object{evaluated}
-		}
+	updated := evalInfixExpression(pe.Operator[:1], current, object.NewInteger(1))
 
-		result = append(result, evaluated)
+	if !assign(pe.Name, env, updated) {
+		return assignError(pe.Name, env)
 	}
 
-	return result
+	return current
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
-	switch fn := fn.(type) {
-	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
+// assignError explains why assigning to ident failed.
+func assignError(ident *ast.Identifier, env *object.Environment) *object.Error {
+	if env.IsConstant(ident.Value) {
+		return newError("cannot assign to constant: %s", ident.Value)
+	}
 
-		evaluated := Eval(fn.Body, extendedEnv)
+	return newError("cannot assign to unbound identifier: %s", ident.Value)
+}
 
-		return unwrapReturnValue(evaluated)
-	case *object.Builtin:
-		return fn.Fn(args...)
-	default:
-		return newError("not a function: %s", fn.Type())
+// evalLogicalExpression only evaluates the right operand of && and || when
+// the left one does not decide the result. Operands are judged by isTruthy
+// and the result is always a boolean.
+func evalLogicalExpression(ie *ast.InfixExpression, env *object.Environment) object.Object {
+	left := evalStrict(ie.Left, env)
+
+	if isError(left) {
+		return left
 	}
-}
 
-func extendFunctionEnv(
-	fn *object.Function,
-	args []object.Object,
-) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+	if ie.Operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
 
-	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+	if ie.Operator == "||" && isTruthy(left) {
+		return TRUE
 	}
 
-	return env
-}
+	right := evalStrict(ie.Right, env)
 
-func unwrapReturnValue(obj object.Object) object.Object {
-	if returnValue, ok := obj.(*object.ReturnValue); ok {
-		return returnValue.Value
+	if isError(right) {
+		return right
 	}
 
-	return obj
+	return nativeBoolToBooleanObject(isTruthy(right))
 }
 
-func evalStringInfixExpression(
-	operator string,
-	left, right object.Object,
-) object.Object {
-	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+// evalCoalesceExpression returns the left operand of ?? unless it is null,
+// and only then evaluates the right one.
+func evalCoalesceExpression(ie *ast.InfixExpression, env *object.Environment) object.Object {
+	left := evalStrict(ie.Left, env)
+
+	if left != NULL {
+		return left
 	}
 
-	leftVal := left.(*object.String).Value
-	rightVal := right.(*object.String).Value
+	return Eval(ie.Right, env)
+}
 
-	return &object.String{Value: leftVal + rightVal}
+func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := evalStrict(ie.Condition, env)
+
+	if isError(condition) {
+		return condition
+	}
+
+	if isTruthy(condition) {
+		return blockValue(Eval(ie.Consequence, env))
+	} else if ie.Alternative != nil {
+		return blockValue(Eval(ie.Alternative, env))
+	} else {
+		return NULL
+	}
+}
+
+// blockValue is the value of a block used as an expression, which is NULL,
+// as it is in the VM, when the block is empty or ends in a statement with
+// no value of its own, such as let.
+func blockValue(result object.Object) object.Object {
+	if result == nil {
+		return NULL
+	}
+
+	return result
+}
+
+func evalSwitchExpression(se *ast.SwitchExpression, env *object.Environment) object.Object {
+	branch, err := selectSwitchBranch(se, env)
+
+	if err != nil {
+		return err
+	}
+
+	// An empty case body still produces a value, as it does in the VM.
+	if branch == nil || len(branch.Statements) == 0 {
+		return NULL
+	}
+
+	return Eval(branch, env)
+}
+
+// selectSwitchBranch evaluates the subject and then the case values in order
+// until one is == to the subject, and returns that case's body. It returns
+// the default, or nil when there is none, if no case matches.
+func selectSwitchBranch(se *ast.SwitchExpression, env *object.Environment) (*ast.BlockStatement, object.Object) {
+	subject := evalStrict(se.Subject, env)
+
+	if isError(subject) {
+		return nil, subject
+	}
+
+	for _, c := range se.Cases {
+		for _, v := range c.Values {
+			value := evalStrict(v, env)
+
+			if isError(value) {
+				return nil, value
+			}
+
+			if evalInfixExpression("==", subject, value) == TRUE {
+				return c.Body, nil
+			}
+		}
+	}
+
+	return se.Default, nil
+}
+
+// evalTryExpression catches an error escaping the try block and runs the
+// catch block in its own environment, with the parameter bound to the
+// error's message. The unwinding of return, break and continue is not an
+// error and passes through, and neither is a failed assertion, so a test
+// cannot swallow its own failure, nor an exit or a panic.
+func evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(te.Block, env)
+
+	if errObj, ok := result.(*object.Error); ok && errObj.Catchable() {
+		catchEnv := object.NewEnclosedEnvironmentSize(env, 1)
+		define(te.Parameter, catchEnv, &object.String{Value: errObj.Message})
+
+		result = Eval(te.Catch, catchEnv)
+	}
+
+	if result == nil {
+		return NULL
+	}
+
+	return result
+}
+
+// evalWhileStatement runs the body in a fresh enclosed environment on every
+// iteration, so bindings made inside the loop do not leak between passes. A
+// do-while skips the condition on the first pass only.
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for pass := 0; ; pass++ {
+		if pass > 0 || !ws.Do {
+			condition := evalStrict(ws.Condition, env)
+
+			if isError(condition) {
+				return condition
+			}
+
+			if !isTruthy(condition) {
+				return nil
+			}
+		}
+
+		result := Eval(ws.Body, object.NewEnclosedEnvironment(env))
+
+		if result == BREAK {
+			return nil
+		}
+
+		if result != nil && result != CONTINUE && isUnwinding(result) {
+			return result
+		}
+	}
+}
+
+// evalForStatement gives the loop its own scope for the init clause, and the
+// body a fresh scope inside it on every iteration like evalWhileStatement.
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	loopEnv := object.NewEnclosedEnvironment(env)
+
+	if fs.Init != nil {
+		init := Eval(fs.Init, loopEnv)
+
+		if isError(init) {
+			return init
+		}
+	}
+
+	for {
+		if fs.Condition != nil {
+			condition := evalStrict(fs.Condition, loopEnv)
+
+			if isError(condition) {
+				return condition
+			}
+
+			if !isTruthy(condition) {
+				return nil
+			}
+		}
+
+		result := Eval(fs.Body, object.NewEnclosedEnvironment(loopEnv))
+
+		if result == BREAK {
+			return nil
+		}
+
+		if result != nil && result != CONTINUE && isUnwinding(result) {
+			return result
+		}
+
+		if fs.Post != nil {
+			post := Eval(fs.Post, loopEnv)
+
+			if isError(post) {
+				return post
+			}
+		}
+	}
+}
+
+// evalForInStatement binds the loop variable afresh on every iteration, so
+// a closure made in the body keeps the element it saw.
+func evalForInStatement(fs *ast.ForInStatement, env *object.Environment) object.Object {
+	iterable := evalStrict(fs.Iterable, env)
+
+	if isError(iterable) {
+		return iterable
+	}
+
+	collection, ok := iterable.(object.Iterable)
+
+	if !ok {
+		return withPosition(newError("not iterable: %s", iterable.Type()), fs.Token)
+	}
+
+	it := collection.Iterate()
+
+	for {
+		value, ok := it.Next()
+
+		if !ok {
+			return nil
+		}
+
+		loopEnv := object.NewEnclosedEnvironment(env)
+		define(fs.Name, loopEnv, value)
+
+		result := Eval(fs.Body, object.NewEnclosedEnvironment(loopEnv))
+
+		if result == BREAK {
+			return nil
+		}
+
+		if result != nil && result != CONTINUE && isUnwinding(result) {
+			return result
+		}
+	}
+}
+
+func evalIdentifier(
+	node *ast.Identifier,
+	env *object.Environment,
+) object.Object {
+	if val, ok := lookup(node, env); ok {
+		return val
+	}
+
+	if builtin, ok := builtins[node.Value]; ok {
+		if builtin == importBuiltin {
+			return bindImport(env)
+		}
+
+		return env.IO().Bind(builtin)
+	}
+
+	return newError("identifier not found: %s", node.Value)
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL:
+		return false
+	case TRUE:
+		return true
+	case FALSE:
+		return false
+	default:
+		return true
+	}
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+// withPosition stamps the position of tok onto obj if it is an error that
+// has not been located yet, so the innermost failing node wins.
+func withPosition(obj object.Object, tok token.Token) object.Object {
+	if errObj, ok := obj.(*object.Error); ok && errObj.Line == 0 {
+		errObj.Line = tok.Line
+		errObj.Column = tok.Column
+	}
+
+	return obj
+}
+
+// withStackFrame records the call of fn at tok on an error unwinding out of
+// it. Only Monkey functions get a frame; builtins report through their
+// caller's position.
+func withStackFrame(obj object.Object, fn object.Object, tok token.Token) object.Object {
+	errObj, ok := obj.(*object.Error)
+
+	if !ok {
+		return obj
+	}
+
+	function, ok := fn.(*object.Function)
+
+	if !ok {
+		return obj
+	}
+
+	name := function.Name
+
+	if name == "" {
+		name = "<anonymous>"
+	}
+
+	frame := object.StackFrame{Function: name, Line: tok.Line, Column: tok.Column}
+	errObj.Stack = append(errObj.Stack, frame)
+
+	return obj
+}
+
+func isError(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.ERROR_OBJ
+	}
+
+	return false
+}
+
+func evalExpressions(
+	exps []ast.Expression,
+	env *object.Environment,
+) []object.Object {
+	var result []object.Object
+
+	for _, e := range exps {
+		evaluated := Eval(e, env)
+
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+
+		result = append(result, evaluated)
+	}
+
+	return result
+}
+
+// evalArguments evaluates a call's arguments, passing the elements of a
+// spread array as arguments of their own.
+func evalArguments(
+	exps []ast.Expression,
+	env *object.Environment,
+) []object.Object {
+	var result []object.Object
+
+	for _, e := range exps {
+		spread, isSpread := e.(*ast.SpreadExpression)
+
+		if isSpread {
+			e = spread.Value
+		}
+
+		evaluated := Eval(e, env)
+
+		if isSpread {
+			evaluated = force(evaluated)
+		}
+
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+
+		if !isSpread {
+			result = append(result, evaluated)
+			continue
+		}
+
+		array, ok, err := object.AsArray(evaluated)
+
+		if err != nil {
+			return []object.Object{withPosition(newError("%s", err), spread.Token)}
+		}
+
+		if !ok {
+			err := newError("spread argument must be ARRAY, got %s", evaluated.Type())
+
+			return []object.Object{withPosition(err, spread.Token)}
+		}
+
+		result = append(result, array.Elements...)
+	}
+
+	return result
+}
+
+// bindNamedArguments evaluates a call's named arguments and places each
+// among args at the position of the parameter it names. Parameters the
+// call leaves out are nil in the result, and must have defaults.
+func bindNamedArguments(
+	function object.Object,
+	args []object.Object,
+	named []*ast.NamedArgument,
+	env *object.Environment,
+) ([]object.Object, object.Object) {
+	fn, ok := function.(*object.Function)
+
+	if !ok {
+		return nil, newError("named arguments not supported: %s", function.Type())
+	}
+
+	params := fn.Parameters
+
+	if fn.Variadic {
+		params = params[:len(params)-1]
+	}
+
+	bound := make([]object.Object, max(len(args), len(params)))
+	copy(bound, args)
+
+	for _, arg := range named {
+		i := parameterIndex(params, arg.Name)
+
+		if i < 0 {
+			return nil, newError("unknown argument name: %s", arg.Name)
+		}
+
+		if bound[i] != nil {
+			return nil, newError("duplicate argument: %s", arg.Name)
+		}
+
+		val := Eval(arg.Value, env)
+
+		if isError(val) {
+			return nil, val
+		}
+
+		bound[i] = val
+	}
+
+	min, _ := arity(fn)
+
+	for i, param := range params[:min] {
+		if bound[i] == nil {
+			return nil, newError("missing argument: %s", param.Value)
+		}
+	}
+
+	return bound, nil
+}
+
+func parameterIndex(params []*ast.Identifier, name string) int {
+	for i, param := range params {
+		if param.Value == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// destructure binds the names of node to what indexing value gives: its
+// elements in order, or for a hash pattern its fields of the same names.
+// A missing element or field binds null, and a value that cannot be
+// indexed is an error.
+func destructure(node *ast.DestructuringStatement, value object.Object, env *object.Environment) object.Object {
+	for i, name := range node.Names {
+		var key object.Object = object.NewInteger(int64(i))
+
+		if node.Hash {
+			key = object.Intern(name.Value)
+		}
+
+		element := evalIndexExpression(value, key)
+
+		if isError(element) {
+			return element
+		}
+
+		define(name, env, element)
+
+		if node.Constant {
+			env.Freeze(name.Value)
+		}
+	}
+
+	return nil
+}
+
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.RANGE_OBJ && index.Type() == object.INTEGER_OBJ:
+		value, ok := left.(*object.Range).At(index.(*object.Integer).Value)
+
+		if !ok {
+			return NULL
+		}
+
+		return object.NewInteger(value)
+	case left.Type() == object.BYTES_OBJ && index.Type() == object.INTEGER_OBJ:
+		value, ok := left.(*object.Bytes).At(index.(*object.Integer).Value)
+
+		if !ok {
+			return NULL
+		}
+
+		return object.NewInteger(value)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
+	default:
+		return newError("index operator not supported: %s", left.Type())
+	}
+}
+
+// evalPropertyExpression looks up left.name as a hash field or a method.
+// A hash without either gives null, as left["name"] would.
+func evalPropertyExpression(left object.Object, name string) object.Object {
+	value, ok := object.Property(left, name, func(name string) *object.Builtin { return builtins[name] })
+
+	if ok {
+		return value
+	}
+
+	if left.Type() == object.HASH_OBJ {
+		return NULL
+	}
+
+	return newError("no method %s on %s", name, left.Type())
+}
+
+func evalArrayIndexExpression(array, index object.Object) object.Object {
+	arrayObject := array.(*object.Array)
+	idx := index.(*object.Integer).Value
+	max := int64(len(arrayObject.Elements) - 1)
+
+	if idx < 0 || idx > max {
+		return NULL
+	}
+
+	return arrayObject.Elements[idx]
+}
+
+// evalStringInterpolation joins an interpolated string's text with its
+// values, shown the way puts shows them.
+func evalStringInterpolation(node *ast.StringInterpolation, env *object.Environment) object.Object {
+	var out strings.Builder
+
+	out.WriteString(node.Strings[0])
+
+	for i, exp := range node.Values {
+		val := evalStrict(exp, env)
+
+		if isError(val) {
+			return val
+		}
+
+		out.WriteString(object.Display(val))
+		out.WriteString(node.Strings[i+1])
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+// evalStringIndexExpression returns the character at index as a string of
+// its own.
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	r, ok := object.RuneAt(str.(*object.String).Value, index.(*object.Integer).Value)
+
+	if !ok {
+		return NULL
+	}
+
+	return object.Intern(string(r))
+}
+
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+
+	if !ok {
+		return newError("%s", object.UnusableHashKey(index))
+	}
+
+	pair, ok := hashObject.Pairs[key.HashKey()]
+
+	if !ok {
+		return NULL
+	}
+
+	return pair.Value
+}
+
+// evalSliceExpression returns a new array, string or bytes holding
+// left[low:high].
+// A nil bound means the start or end. Bounds are clamped to the length, and
+// low past high gives an empty result.
+func evalSliceExpression(left, low, high object.Object) object.Object {
+	var length int
+
+	switch left := left.(type) {
+	case *object.Array:
+		length = len(left.Elements)
+	case *object.String:
+		length = utf8.RuneCountInString(left.Value)
+	case *object.Bytes:
+		length = len(left.Value)
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+
+	start, errObj := sliceBound(low, 0, length)
+
+	if errObj != nil {
+		return errObj
+	}
+
+	end, errObj := sliceBound(high, length, length)
+
+	if errObj != nil {
+		return errObj
+	}
+
+	if start > end {
+		start = end
+	}
+
+	if array, ok := left.(*object.Array); ok {
+		elements := make([]object.Object, end-start)
+		copy(elements, array.Elements[start:end])
+
+		return &object.Array{Elements: elements}
+	}
+
+	if bytes, ok := left.(*object.Bytes); ok {
+		return bytes.Slice(start, end)
+	}
+
+	return &object.String{Value: string([]rune(left.(*object.String).Value)[start:end])}
+}
+
+func sliceBound(bound object.Object, def, length int) (int, object.Object) {
+	if bound == nil {
+		return def, nil
+	}
+
+	integer, ok := bound.(*object.Integer)
+
+	if !ok {
+		return 0, newError("slice bounds must be INTEGER, got %s", bound.Type())
+	}
+
+	switch {
+	case integer.Value < 0:
+		return 0, nil
+	case integer.Value > int64(length):
+		return length, nil
+	default:
+		return int(integer.Value), nil
+	}
+}
+
+// evalIndexAssignment stores val at index in the array or hash left and
+// returns val. Arrays can only be written at an existing index, and frozen
+// arrays and hashes not at all.
+func evalIndexAssignment(left, index, val object.Object) object.Object {
+	switch {
+	case (left.Type() == object.ARRAY_OBJ || left.Type() == object.HASH_OBJ) && object.IsFrozen(left):
+		return newError("cannot assign to frozen %s", left.Type())
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		arrayObject := left.(*object.Array)
+		idx := index.(*object.Integer).Value
+
+		if idx < 0 || idx >= int64(len(arrayObject.Elements)) {
+			return newError("index out of range: %d, length %d", idx, len(arrayObject.Elements))
+		}
+
+		arrayObject.Elements[idx] = val
+
+		return val
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+
+		if !ok {
+			return newError("%s", object.UnusableHashKey(index))
+		}
+
+		left.(*object.Hash).Set(key.HashKey(), object.HashPair{Key: index, Value: val})
+
+		return val
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+}
+
+func evalHashLiteral(
+	node *ast.HashLiteral,
+	env *object.Environment,
+) object.Object {
+	hash := object.NewHash()
+
+	for _, pairNode := range node.Pairs {
+		key := evalStrict(pairNode.Key, env)
+
+		if isError(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+
+		if !ok {
+			return withPosition(newError("%s", object.UnusableHashKey(key)), node.Token)
+		}
+
+		value := Eval(pairNode.Value, env)
+
+		if isError(value) {
+			return value
+		}
+
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+
+	return hash
+}
+
+// Apply calls fn, a Monkey function or builtin, with args, as a call
+// expression would. It lets Go code call functions a program defined.
+func Apply(fn object.Object, args ...object.Object) object.Object {
+	return applyFunction(fn, args)
+}
+
+func applyFunction(fn object.Object, args []object.Object) object.Object {
+	if profiler != nil && isCallable(fn) {
+		defer profiler.Enter(profileName(fn))()
+	}
+
+	switch fn := fn.(type) {
+	case *object.Function:
+		return applyUserFunction(fn, args)
+	case *object.Builtin:
+		for i, arg := range args {
+			if args[i] = force(arg); isError(args[i]) {
+				return args[i]
+			}
+		}
+
+		if result := fn.Fn(args...); result != nil {
+			return result
+		}
+
+		return NULL
+	default:
+		return newError("not a function: %s", fn.Type())
+	}
+}
+
+// extendFunctionEnv binds fn's parameters to args. A parameter left out,
+// or nil in args, gets its default value, evaluated in the environment fn was defined in;
+// an error evaluating it is returned instead of the environment.
+func extendFunctionEnv(
+	fn *object.Function,
+	args []object.Object,
+) (*object.Environment, object.Object) {
+	env := object.NewEnclosedEnvironmentSize(fn.Env, len(fn.Parameters))
+
+	params := fn.Parameters
+
+	if fn.Variadic {
+		params = params[:len(params)-1]
+		rest := []object.Object{}
+
+		if len(args) > len(params) {
+			rest = make([]object.Object, len(args)-len(params))
+			copy(rest, args[len(params):])
+		}
+
+		define(fn.Parameters[len(params)], env, &object.Array{Elements: rest})
+	}
+
+	for paramIdx, param := range params {
+		if paramIdx < len(args) && args[paramIdx] != nil {
+			define(param, env, args[paramIdx])
+			continue
+		}
+
+		val := Eval(fn.Defaults[paramIdx], fn.Env)
+
+		if isError(val) {
+			return nil, val
+		}
+
+		define(param, env, val)
+	}
+
+	return env, nil
+}
+
+// arity returns how many arguments fn takes, at least and at most. The most
+// is -1 for a variadic function.
+func arity(fn *object.Function) (int, int) {
+	max := len(fn.Parameters)
+
+	if fn.Variadic {
+		max--
+	}
+
+	min := max
+
+	for min > 0 && min <= len(fn.Defaults) && fn.Defaults[min-1] != nil {
+		min--
+	}
+
+	if fn.Variadic {
+		max = -1
+	}
+
+	return min, max
+}
+
+func unwrapReturnValue(obj object.Object) object.Object {
+	if returnValue, ok := obj.(*object.ReturnValue); ok {
+		return returnValue.Value
+	}
+
+	return obj
+}
+
+func evalStringInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return object.Concat(left.(*object.String), right.(*object.String))
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
 }
@@ -1,10 +1,19 @@
 package evaluator
 
 import (
+	"bytes"
+	"fmt"
+	"io"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -27,6 +36,15 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"10 % 5 + 2 * 3 % 4", 2},
+		{"2 ** 10", 1024},
+		{"2 ** 0", 1},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"(-2) ** 3", -8},
+		{"3 * 2 ** 2", 12},
 	}
 
 	for _, tt := range tests {
@@ -35,6 +53,173 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.5", 3.5},
+		{"-2.25", -2.25},
+		{"1.5 + 1.5", 3.0},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2.0},
+		{"5 / 2.0", 2.5},
+		{"10 - 2.5 * 2", 5.0},
+		{"7.5 % 2", 1.5},
+		{"2 ** -1", 0.5},
+		{"2.0 ** 3", 8.0},
+		{"4 ** 0.5", 2.0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{} // a string is the decimal value of a BigInt
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"9223372036854775808", "9223372036854775808"},
+		{"-9223372036854775808", -9223372036854775808},
+		{"18446744073709551616 - 1", "18446744073709551615"},
+		{"0x1_0000_0000_0000_0000 == 2 ** 64", true},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"4294967296 * 4294967296", "18446744073709551616"},
+		{"2 ** 64", "18446744073709551616"},
+		{"2 ** 100", "1267650600228229401496703205376"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"2 ** 64 + 2 ** 64", "36893488147419103232"},
+		{"2 ** 64 * -1", "-18446744073709551616"},
+		{"2 ** 64 - 2 ** 64", 0},
+		{"2 ** 64 - (2 ** 64 - 5)", 5},
+		{"-(2 ** 63)", -9223372036854775808},
+		{"2 ** 64 / 2 ** 32", 4294967296},
+		{"(2 ** 64 + 7) % 10", 3},
+		{"-(2 ** 66) / 3", "-24595658764946068821"},
+		{"2 ** 64 > 9223372036854775807", true},
+		{"2 ** 64 < 1", false},
+		{"-(2 ** 64) < 1", true},
+		{"2 ** 64 == 2 ** 64", true},
+		{"2 ** 64 == 2 ** 65", false},
+		{"2 ** 64 != 1", true},
+		{"2 ** 64 == 2.0 ** 64", true},
+		{"let x = 9223372036854775807; x++; x", "9223372036854775808"},
+		{"let x = 9223372036854775806; x += 2; x", "9223372036854775808"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case string:
+			result, ok := evaluated.(*object.BigInt)
+
+			if !ok {
+				t.Errorf("%s: object is not BigInt. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+
+			if result.Inspect() != expected {
+				t.Errorf("%s: object has wrong value. got=%s, want=%s", tt.input, result.Inspect(), expected)
+			}
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestBitwiseOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"6 & 3", "2"},
+		{"6 | 3", "7"},
+		{"6 ^ 3", "5"},
+		{"~5", "-6"},
+		{"~-1", "0"},
+		{"1 << 10", "1024"},
+		{"1024 >> 3", "128"},
+		{"-8 >> 1", "-4"},
+		{"1 >> 100", "0"},
+		{"-1 >> 100", "-1"},
+		{"-1 & 255", "255"},
+		{"1 << 63", "9223372036854775808"},
+		{"-1 << 63", "-9223372036854775808"},
+		{"1 << 64", "18446744073709551616"},
+		{"(1 << 64) >> 64", "1"},
+		{"(2 ** 64 + 5) & 7", "5"},
+		{"(2 ** 64) | 1", "18446744073709551617"},
+		{"(2 ** 64) ^ (2 ** 64)", "0"},
+		{"~(2 ** 64)", "-18446744073709551617"},
+		{"-(2 ** 64) >> 1000", "-1"},
+		{"0 << 1000000000000", "0"},
+		{"1 | 2 ^ 3 & 4", "3"},
+		{"1 + 1 << 2", "8"},
+		{"5 & 1 == 1", "true"},
+		{"let flags = 0; flags = flags | 1 << 3; flags & 8 != 0", "true"},
+		{"1.5 & 1", "ERROR: unknown operator: FLOAT & INTEGER"},
+		{"true | false", "ERROR: unknown operator: BOOLEAN | BOOLEAN"},
+		{"~1.0", "ERROR: unknown operator: ~FLOAT"},
+		{`"a" ^ 1`, "ERROR: type mismatch: STRING ^ INTEGER"},
+		{"1 << -1", "ERROR: negative shift count: 1 << -1"},
+		{"1 >> -1", "ERROR: negative shift count: 1 >> -1"},
+		{"1 << 100000000", "ERROR: integer too large: 1 << 100000000"},
+		{"0xFF & 0b1010_1010", "170"},
+		{"0o17 | 1_000", "1007"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			if "ERROR: "+errObj.Message != tt.expected {
+				t.Errorf("wrong error for %s. got=%s, want=%s", tt.input, errObj.Message, tt.expected)
+			}
+
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestBigIntegerErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"2 ** 64 / 0", "division by zero: 18446744073709551616 / 0"},
+		{"2 ** 64 % 0", "division by zero: 18446744073709551616 % 0"},
+		{"2 ** 1000000000000", "integer too large: 2 ** 1000000000000"},
+		{"2 ** 64 + true", "type mismatch: BIGINT + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -59,6 +244,53 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{"1.5 < 2", true},
+		{"2 > 2.5", false},
+		{"2.0 == 2", true},
+		{"0.1 != 0.2", true},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"2.5 >= 2", true},
+		{"2 <= 1.5", false},
+		{`"a" < "b"`, true},
+		{`"b" > "a"`, true},
+		{`"a" <= "a"`, true},
+		{`"a" >= "b"`, false},
+		{`"monkey" == "monkey"`, true},
+		{`"monkey" != "ape"`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"true || false", true},
+		{"1 && 2", true},
+		{`0 && ""`, true},
+		{"if (false) { 1 } || true", true},
+		{"if (false) { 1 } && true", false},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"false && (1 + true)", false},
+		{"true || (1 + true)", true},
+		{"let x = 0; false && (x = 1); x == 0", true},
+		{"let x = 0; true || (x = 1); x == 0", true},
+		{"let x = 0; true && (x = 1); x == 1", true},
 	}
 
 	for _, tt := range tests {
@@ -67,6 +299,39 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestCoalesceOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"a": 1}["a"] ?? 2`, 1},
+		{`{"a": 1}["b"] ?? 2`, 2},
+		{"if (false) { 1 } ?? 3", 3},
+		{"[][0] ?? [][1] ?? 4", 4},
+		{"false ?? 5", false},
+		{"0 ?? 5", 0},
+		{"let x = 0; 1 ?? (x = 1); x", 0},
+		{`let h = {}; isNull(h["a"])`, true},
+		{"isNull(0)", false},
+		{`isHashable("a")`, true},
+		{"isHashable(1) && isHashable(true)", true},
+		{"isHashable(1.5)", false},
+		{"isHashable([1])", false},
+		{"isHashable({})", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
+
 func TestBangOperator(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -112,6 +377,34 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestConditionalExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"true ? 10 : 20", 10},
+		{"false ? 10 : 20", 20},
+		{"1 > 2 ? 10 : 20", 20},
+		{"[][0] ? 10 : 20", 20},
+		{"0 ? 10 : 20", 10},
+		{"let x = 5; x > 3 ? x < 4 ? 1 : 2 : 3", 2},
+		{"let x = 0; true ? 1 : (x = 9); x", 0},
+		{"false ? 1 : [][0]", nil},
+		{"let f = fn(n) { n == 0 ? 0 : f(n - 1) }; f(1000000);", 0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -139,6 +432,114 @@ func TestReturnStatements(t *testing.T) {
 	}
 }
 
+func TestWhileStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"while (false) { 10 }", nil},
+		{"while (false) { 10 }; 5", 5},
+		{"let f = fn() { while (true) { return 7; } }; f();", 7},
+		{"let f = fn(x) { while (x > 0) { return x * 2; }; 0 }; f(4);", 8},
+		{"let f = fn(x) { while (x > 0) { return x * 2; }; 0 }; f(-1);", 0},
+		{"let x = 1; let f = fn() { while (true) { let x = 2; return x; } }; f() + x;", 3},
+		{"let i = 5; do { i = i + 1 } while (i < 3); i", 6},
+		{"let i = 0; do { i = i + 1 } while (i < 3); i", 3},
+		{"do { 10 } while (false)", nil},
+		{"let f = fn() { loop { return 7; } }; f();", 7},
+		{"let i = 0; loop { i = i + 1; if (i == 4) { break } }; i", 4},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else if evaluated != nil {
+			t.Errorf("object is not nil. got=%T (%+v)", evaluated, evaluated)
+		}
+	}
+}
+
+func TestForStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"for (let i = 0; false; i) { 10 }", nil},
+		{"for (let i = 0; false; i) { 10 }; 5", 5},
+		{"let f = fn() { for (;;) { return 5; } }; f();", 5},
+		{"let f = fn() { for (let i = 3; i > 0; i) { return i * 2; } }; f();", 6},
+		{"let i = 1; let f = fn() { for (let i = 10; true; i) { return i; } }; f() + i;", 11},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else if evaluated != nil {
+			t.Errorf("object is not nil. got=%T (%+v)", evaluated, evaluated)
+		}
+	}
+}
+
+func TestForInStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let sum = 0; for (x in [1, 2, 3]) { sum += x; }; sum", 6},
+		{"let sum = 0; for (i in range(10)) { if (i % 2 == 0) { continue; } sum += i; }; sum", 25},
+		{"let sum = 0; for (i in range(1000000000)) { if (i == 4) { break; } sum += i; }; sum", 6},
+		{`let n = 0; for (k in {"a": 1, "b": 2}) { n += len(k); }; n`, 2},
+		{`let n = 0; for (c in "héllo") { if (c == "l") { n++; } }; n`, 2},
+		{"let n = 0; for (x in []) { n = 1; }; n", 0},
+		{"let xs = [1, 2]; let n = 0; for (x in xs) { xs = push(xs, x); n++; }; n", 2},
+		{"let f = fn() { for (x in [7, 8]) { return x; } }; f();", 7},
+		{"let f = fn() { let fs = []; for (i in range(3)) { fs = push(fs, fn() { i }); }; fs[0]() + fs[2]() }; f();", 2},
+		{"let x = 1; for (x in [5]) { x; }; x", 1},
+		{"for (x in [1, 2]) { x }", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else if evaluated != nil {
+			t.Errorf("object is not nil. got=%T (%+v)", evaluated, evaluated)
+		}
+	}
+}
+
+func TestBreakAndContinue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"while (true) { break; }; 5", 5},
+		{"for (;;) { if (true) { break; } }; 6", 6},
+		{"let f = fn() { while (true) { if (true) { break; } return 1; }; 2 }; f();", 2},
+		{"let f = fn() { while (true) { while (true) { break; } return 3; } }; f();", 3},
+		{"let i = 0; let n = 0; do { i++; if (i % 2 == 0) { continue; } n++; } while (i < 5); n", 3},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else if evaluated != nil {
+			t.Errorf("object is not nil. got=%T (%+v)", evaluated, evaluated)
+		}
+	}
+}
+
 func TestErrorHandling(t *testing.T) {
 	tests := []struct {
 		input           string
@@ -156,6 +557,78 @@ func TestErrorHandling(t *testing.T) {
 			"-true",
 			"unknown operator: -BOOLEAN",
 		},
+		{
+			"5 / 0",
+			"division by zero: 5 / 0",
+		},
+		{
+			"5 % 0",
+			"division by zero: 5 % 0",
+		},
+		{
+			"5.5 % 0",
+			"division by zero: 5.5 % 0",
+		},
+		{
+			"true ** 2",
+			"type mismatch: BOOLEAN ** INTEGER",
+		},
+		{
+			"true && (1 + true)",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			`{"name": "Monkey"}[fn(x) { x }];`,
+			"unusable as hash key: FUNCTION (keys must be INTEGER, BOOLEAN or STRING)",
+		},
+		{
+			"fn(x) { x; }();",
+			"wrong number of arguments: want=1, got=0",
+		},
+		{
+			"fn(x, y, ...z) { x; }(1);",
+			"wrong number of arguments: want at least 2, got=1",
+		},
+		{
+			"fn(x, y = 1) { x; }();",
+			"wrong number of arguments: want 1 to 2, got=0",
+		},
+		{
+			"fn(x, y = 1) { x; }(1, 2, 3);",
+			"wrong number of arguments: want 1 to 2, got=3",
+		},
+		{
+			"fn(x = 1 + true) { x; }();",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"fn(x) { x; }(y: 1);",
+			"unknown argument name: y",
+		},
+		{
+			"fn(x, ...xs) { x; }(xs: 1);",
+			"unknown argument name: xs",
+		},
+		{
+			"fn(x) { x; }(1, x: 2);",
+			"duplicate argument: x",
+		},
+		{
+			"fn(x) { x; }(x: 1, x: 2);",
+			"duplicate argument: x",
+		},
+		{
+			"fn(x, y = 1) { x; }(y: 2);",
+			"missing argument: x",
+		},
+		{
+			"len(x: 1);",
+			"named arguments not supported: BUILTIN",
+		},
+		{
+			"fn(x) { x; }(...1);",
+			"spread argument must be ARRAY, got INTEGER",
+		},
 		{
 			"true + false;",
 			"unknown operator: BOOLEAN + BOOLEAN",
@@ -185,6 +658,66 @@ func TestErrorHandling(t *testing.T) {
 			`"Hello" - "World"`,
 			"unknown operator: STRING - STRING",
 		},
+		{
+			"while (true) { foobar }",
+			"identifier not found: foobar",
+		},
+		{
+			"for (let i = 0; false; i) { }; i",
+			"identifier not found: i",
+		},
+		{
+			"x = 5;",
+			"cannot assign to unbound identifier: x",
+		},
+		{
+			"x++",
+			"cannot assign to unbound identifier: x",
+		},
+		{
+			"const x = 1; x = 2;",
+			"cannot assign to constant: x",
+		},
+		{
+			"const x = 1; x += 2;",
+			"cannot assign to constant: x",
+		},
+		{
+			"const x = 1; let f = fn() { x-- }; f()",
+			"cannot assign to constant: x",
+		},
+		{
+			"let a = [1]; a[1] = 2",
+			"index out of range: 1, length 1",
+		},
+		{
+			"let a = [1]; a[-1] = 2",
+			"index out of range: -1, length 1",
+		},
+		{
+			`let h = {}; h[[1]] = 2`,
+			"unusable as hash key: ARRAY (keys must be INTEGER, BOOLEAN or STRING)",
+		},
+		{
+			`let s = "abc"; s[0] = "x"`,
+			"index assignment not supported: STRING",
+		},
+		{
+			`let s = "a"; s++`,
+			"unknown operator: STRING++",
+		},
+		{
+			"let a = 1; a /= 0",
+			"division by zero: 1 / 0",
+		},
+		{
+			"for (x in 5) { x }",
+			"not iterable: INTEGER",
+		},
+		{
+			"let n = 5; n.x",
+			"no method x on INTEGER",
+		},
 	}
 
 	for _, tt := range tests {
@@ -204,15 +737,109 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
-func TestLetStatements(t *testing.T) {
+func TestErrorPositions(t *testing.T) {
 	tests := []struct {
-		input    string
-		expected int64
+		input          string
+		expectedLine   int
+		expectedColumn int
 	}{
-		{"let a = 5; a;", 5},
-		{"let a = 5 * 5; a;", 25},
-		{"let a = 5; let b = a; b;", 5},
-		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"5 + true;", 1, 3},
+		{"let x = 1;\n  -true", 2, 3},
+		{"let f = fn() {\n  foobar;\n};\nf();", 2, 3},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%v)", evaluated, evaluated)
+
+			continue
+		}
+
+		if errObj.Line != tt.expectedLine || errObj.Column != tt.expectedColumn {
+			t.Errorf("wrong error position. expected=%d:%d, got=%d:%d",
+				tt.expectedLine, tt.expectedColumn, errObj.Line, errObj.Column)
+		}
+	}
+}
+
+func TestErrorTraceback(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"5 + true;",
+			"ERROR at line 1, col 3: type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"let add = fn(a, b) {\n  a + b\n};\nlet outer = fn() { add(1, true) };\nouter();",
+			"ERROR at line 2, col 5: type mismatch: INTEGER + BOOLEAN\n" +
+				"  in add, called at line 4, col 23\n" +
+				"  in outer, called at line 5, col 6",
+		},
+		{
+			"fn() { -true }();",
+			"ERROR at line 1, col 8: unknown operator: -BOOLEAN\n" +
+				"  in <anonymous>, called at line 1, col 15",
+		},
+		{
+			"let f = fn() { len(1) };\nf();",
+			"ERROR at line 1, col 19: argument to `len` not supported, got INTEGER\n" +
+				"  in f, called at line 2, col 2",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%v)", evaluated, evaluated)
+
+			continue
+		}
+
+		if errObj.Traceback() != tt.expected {
+			t.Errorf("wrong traceback.\nexpected=%q\ngot=%q", tt.expected, errObj.Traceback())
+		}
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a = 10; a;", 10},
+		{"let a = 5; a = a * 2;", 10},
+		{"let a = 1; let b = 2; a = b = 3; a + b;", 6},
+		{"let a = 1; let f = fn() { a = 2; }; f(); a;", 2},
+		{"let a = 1; let f = fn() { let a = 5; a = 2; }; f(); a;", 1},
+		{"let i = 0; while (i < 5) { i = i + 1; }; i;", 5},
+		{"let sum = 0; for (let i = 0; i < 5; i = i + 1) { sum = sum + i; }; sum;", 10},
+		{"let sum = 0; for (let i = 0; i < 10; i = i + 1) { if (i > 3) { continue; } sum = sum + i; }; sum;", 6},
+		{"let i = 0; while (true) { i = i + 1; if (i == 7) { break; } }; i;", 7},
 	}
 
 	for _, tt := range tests {
@@ -220,6 +847,110 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestPostfixAndCompoundAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 5; i++", 5},
+		{"let i = 5; i++; i", 6},
+		{"let i = 5; i--; i", 4},
+		{"let i = 1.5; i++; i", 2.5},
+		{"let i = 0; let j = i++ + i++; j", 1},
+		{"let a = 10; a += 5; a", 15},
+		{"let a = 10; a -= 5", 5},
+		{"let a = 10; a *= 2; a", 20},
+		{"let a = 10; a /= 4; a", 2},
+		{"let sum = 0; for (let i = 0; i < 5; i++) { sum += i; }; sum;", 10},
+		{"let count = 0; let inc = fn() { count++ }; inc(); inc(); count", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestSwitchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"switch (2) { case 1: 10 case 2: 20 default: 30 }", 20},
+		{`switch ("foo") { case "bar": 1 case "foo": 2 }`, 2},
+		{"switch (3) { case 1, 2: 10 case 3, 4: 20 }", 20},
+		{"switch (5) { case 1: 10 default: 30 }", 30},
+		{"switch (5) { case 1: 10 }", nil},
+		{"switch (1) { case 1: 10 case 1: 20 }", 10},
+		{`switch ("1") { case 1: 10 case "1": 20 }`, 20},
+		{"switch (1) { case 1: default: 30 }", nil},
+		{"let x = 0; switch (1) { case 1: x = 5; x * 2 }", 10},
+		{"let f = fn(n) { switch (n) { case 0: return 1; default: 2 }; 3 }; f(0) + f(1)", 4},
+		{"let i = 0; switch (i++) { case 0: i }", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"try { 1 / 0 } catch (e) { e }", "division by zero: 1 / 0"},
+		{"try { [1, 2] + 1 } catch (e) { e }", "type mismatch: ARRAY + INTEGER"},
+		{"try { len(1) } catch (e) { e }", "argument to `len` not supported, got INTEGER"},
+		{"try { 1 } catch (e) { 2 }", 1},
+		{"try { 1 + true; 3 } catch (e) { 4 }", 4},
+		{"try { } catch (e) { 1 }", nil},
+		{"try { 1 / 0 } catch (e) { }", nil},
+		{"let f = fn() { 1 / 0 }; try { f() } catch (e) { 5 }", 5},
+		{"let f = fn() { try { return 1; } catch (e) { 2 }; 3 }; f()", 1},
+		{"try { try { 1 / 0 } catch (e) { -true } } catch (e) { e }", "unknown operator: -BOOLEAN"},
+		{"let e = 1; try { 1 / 0 } catch (e) { e }; e", 1},
+		{"let x = 0; try { x = 1; 1 / 0; x = 2 } catch (e) { x = x + 10 }; x", 11},
+		{"let n = 0; for (let i = 0; i < 5; i++) { try { if (i == 3) { break; } n = n + i } catch (e) { 0 } }; n", 3},
+		{"let safe = fn(n) { try { if (n == 0) { 1 / n } else { safe(n - 1) } } catch (e) { n } }; safe(3)", 0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if str.Value != expected {
+				t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
 	evaluated := testEval(input)
@@ -255,6 +986,33 @@ func TestFunctionApplication(t *testing.T) {
 		{"let double = fn(x) { x * 2; }; double(5);", 10},
 		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
 		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"let add = fn(x, y) { x + y; }; add(...[1, 2]);", 3},
+		{"let add = fn(x, y) { x + y; }; add(1, ...[2]);", 3},
+		{"let add = fn(x, y) { x + y; }; add(...[], 1, ...[2], ...[]);", 3},
+		{"let count = fn(...xs) { len(xs) }; count();", 0},
+		{"let count = fn(...xs) { len(xs) }; count(1, 2, 3);", 3},
+		{"let count = fn(x, ...xs) { len(xs) }; count(1);", 0},
+		{"let second = fn(x, ...xs) { xs[0] }; second(1, 2, 3);", 2},
+		{"let f = fn(...xs) { xs[1] }; f(...[1, 2], 3);", 2},
+		{"let f = fn(...xs) { len(xs) }; let g = fn(...ys) { f(...ys, ...ys) }; g(1, 2);", 4},
+		{"let f = fn(n, ...acc) { if (n == 0) { len(acc) } else { f(n - 1, n, ...acc) } }; f(100);", 100},
+		{"len(...[[1, 2]]);", 2},
+		{"let add = fn(x, y = 10) { x + y }; add(1);", 11},
+		{"let add = fn(x, y = 10) { x + y }; add(1, 2);", 3},
+		{"let add = fn(x = 1, y = 2) { x + y }; add();", 3},
+		{"let y = 5; let f = fn(x, y = y * 2) { x + y }; f(1);", 11},
+		{"let x = 100; let f = fn(x, y = x) { y }; f(1);", 100},
+		{"let n = 1; let f = fn(x = n) { x }; n = 7; f();", 7},
+		{"let f = fn(x = 1, ...xs) { x + len(xs) }; f();", 1},
+		{"let f = fn(x = 1, ...xs) { x + len(xs) }; f(5, 6, 7);", 7},
+		{"let make = fn(b) { fn(x = b) { x } }; make(4)();", 4},
+		{"let f = fn(x, y = 0) { if (x == 0) { y } else { f(x - 1, y + x) } }; f(100);", 5050},
+		{"let sub = fn(x, y) { x - y }; sub(y: 1, x: 10);", 9},
+		{"let sub = fn(x, y) { x - y }; sub(10, y: 1);", 9},
+		{"let f = fn(x, y = 2, z = 3) { x * 100 + y * 10 + z }; f(1, z: 9);", 129},
+		{"let f = fn(x = 1, y = 2) { x * 10 + y }; f(y: 5);", 15},
+		{"let f = fn(x, ...xs) { x + len(xs) }; f(x: 5);", 5},
+		{"let f = fn(n, acc = 0) { if (n == 0) { acc } else { f(acc: acc + n, n: n - 1) } }; f(100);", 5050},
 	}
 
 	for _, tt := range tests {
@@ -274,6 +1032,177 @@ addTwo(2);`
 	testIntegerObject(t, testEval(input), 4)
 }
 
+func TestConstStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"const x = 5; x * 2", 10},
+		{"const x = 5; let f = fn() { let x = 1; x = 2; x }; f() + x", 7},
+		{"const x = 5; let f = fn(x) { x = x + 1; x }; f(1) + x", 7},
+		{"const x = 5; let x = 6; x = 7; x", 7},
+		{"const double = fn(n) { n * 2 }; double(4)", 8},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDestructuringStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let [a, b, c] = [1, 2, 3]; a * 100 + b * 10 + c", 123},
+		{"let [a, b] = [1]; isNull(b)", true},
+		{"let [a] = [1, 2, 3]; a", 1},
+		{`let {name, age} = {"name": "ann", "age": 30}; age`, 30},
+		{`let {name, age} = {"name": "ann"}; isNull(age)`, true},
+		{`let [x, y] = range(5, 10); x + y`, 11},
+		{"let f = fn(pair) { let [a, b] = pair; a - b }; f([5, 3])", 2},
+		{"let a = 1; let b = 2; let f = fn() { let [a, b] = [b, a]; a * 10 + b }; f()", 21},
+		{"let divmod = fn(a, b) { return a / b, a % b }; let q, r = divmod(17, 5); q * 10 + r", 32},
+		{"let f = fn() { return 1, 2, 3 }; len(f())", 3},
+		{"let [a] = 5;", "index operator not supported: INTEGER"},
+		{"const [a] = [1]; a = 2;", "cannot assign to constant: a"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+
+				continue
+			}
+
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestMutualRecursion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`
+		let f = fn() {
+			let even = fn(n) { if (n == 0) { 1 } else { odd(n - 1) } };
+			let odd = fn(n) { if (n == 0) { 0 } else { even(n - 1) } };
+			even(10) + odd(7);
+		};
+		f();
+		`, 2},
+		{`
+		let f = fn(x) {
+			let a = fn() { let inner = fn() { b() }; inner() };
+			let y = x * 2;
+			let b = fn() { y + 1 };
+			a();
+		};
+		f(4) + f(5);
+		`, 20},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestClosureMutation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`
+		let makeCounter = fn() {
+			let count = 0;
+			fn() { count = count + 1 };
+		};
+		let c = makeCounter();
+		c();
+		c();
+		c();`, 3},
+		{`
+		let makeCounter = fn() {
+			let count = 0;
+			fn() { count = count + 1 };
+		};
+		let a = makeCounter();
+		let b = makeCounter();
+		a();
+		a();
+		b();`, 1},
+		{`
+		let makePair = fn() {
+			let value = 0;
+			[fn() { value = value + 10 }, fn() { value }];
+		};
+		let pair = makePair();
+		pair[0]();
+		pair[0]();
+		pair[1]();`, 20},
+		{`
+		let total = 0;
+		let add = fn(n) { total = total + n };
+		add(5);
+		add(7);
+		total;`, 12},
+		{`
+		let x = 1;
+		let shadow = fn() { let x = 100; x = x + 1; x };
+		shadow();
+		x;`, 1},
+		{`
+		let fns = [];
+		let i = 0;
+		while (i < 3) {
+			let j = i;
+			fns = push(fns, fn() { j });
+			i = i + 1;
+		}
+		fns[0]() + fns[1]() + fns[2]();`, 3},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let loop = fn(n) { if (n == 0) { 0 } else { loop(n - 1) } }; loop(1000000);", 0},
+		{"let sum = fn(n, acc) { if (n == 0) { return acc; } sum(n - 1, acc + n) }; sum(100000, 0);", 5000050000},
+		{"let count = fn(n) { if (n > 0) { return count(n - 1); }; 42 }; count(100000);", 42},
+		{`
+		let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+		let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+		if (isEven(100001)) { 1 } else { 0 };
+		`, 0},
+		{"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15);", 610},
+		{"let f = fn(n) { len(\"abc\") + n }; let g = fn() { f(1) }; g();", 4},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
 
@@ -290,6 +1219,33 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestStringInspectRoundTrip(t *testing.T) {
+	inputs := []string{
+		`"plain"`,
+		`"line\nbreak"`,
+		`"quote \" and \\ backslash\t"`,
+		`"bell \u{7} and \u{1F600}"`,
+	}
+
+	for _, input := range inputs {
+		first, ok := testEval(input).(*object.String)
+
+		if !ok {
+			t.Fatalf("object is not String for %s", input)
+		}
+
+		second, ok := testEval(first.Inspect()).(*object.String)
+
+		if !ok {
+			t.Fatalf("Inspect output %s does not evaluate to a String", first.Inspect())
+		}
+
+		if first.Value != second.Value {
+			t.Errorf("round trip changed the value. got=%q, want=%q", second.Value, first.Value)
+		}
+	}
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 
@@ -306,6 +1262,54 @@ func TestStringConcatenation(t *testing.T) {
 	}
 }
 
+func TestStringConcatenationSharing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = "x" + "y"; let b = a + "1"; let c = a + "2"; b + "|" + c`, "xy1|xy2"},
+		{`let a = "x" + "y"; let b = a + "1"; b = b + "2"; a + "|" + b`, "xy|xy12"},
+		{`let s = ""; for (let i = 0; i < 100; i++) { s += "ab"; }; len(s)`, "200"},
+		{`let s = "a" + ""; s + "" + s`, "aa"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected && evaluated.Inspect() != `"`+tt.expected+`"` {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Bob"; "Hello, ${name}!"`, "Hello, Bob!"},
+		{`let age = 41; "You are ${age + 1}"`, "You are 42"},
+		{`"${1}${2.5}${true}"`, "12.5true"},
+		{`"${[1, "a"]} ${{"k": 2}["k"]}"`, `[1, "a"] 2`},
+		{`let f = fn(x) { "<${x}>" }; "${f("in ${f(1)}")}"`, "<in <1>>"},
+		{`"\${x} $5 $"`, "${x} $5 $"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		str, ok := evaluated.(*object.String)
+
+		if !ok {
+			t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+		}
+
+		if str.Value != tt.expected {
+			t.Errorf("wrong result for %s. got=%q, want=%q", tt.input, str.Value, tt.expected)
+		}
+	}
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -314,8 +1318,17 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("naïve")`, 5},
+		{`len("😀")`, 1},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`len([1, 2, 3])`, 3},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`last([1, 2, 3])`, 3},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`push([], 1)`, []int{1}},
+		{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
 	}
 
 	for _, tt := range tests {
@@ -324,19 +1337,1570 @@ func TestBuiltinFunctions(t *testing.T) {
 		switch expected := tt.expected.(type) {
 		case int:
 			testIntegerObject(t, evaluated, int64(expected))
-		case string:
-			errObj, ok := evaluated.(*object.Error)
+		case nil:
+			testNullObject(t, evaluated)
+		case []int:
+			array, ok := evaluated.(*object.Array)
 
 			if !ok {
-				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
 
 				continue
 			}
 
-			if errObj.Message != expected {
-				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+
+				continue
 			}
-		}
+
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], int64(expectedElem))
+			}
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+
+				continue
+			}
+
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`join(split("a,b,c", ","), "-")`, "a-b-c"},
+		{`len(split("a,b,c", ","))`, 3},
+		{`split("abc", "")[1]`, "b"},
+		{`join([], ", ")`, ""},
+		{`contains("hello", "ell")`, true},
+		{`contains("hello", "xyz")`, false},
+		{`contains([1, "two", 3], "two")`, true},
+		{`contains([1, 2, 3], 4)`, false},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`trim("  hi \n")`, "hi"},
+		{`upper("Hello")`, "HELLO"},
+		{`lower("Hello")`, "hello"},
+		{`indexOf("hello", "l")`, 2},
+		{`indexOf("hello", "z")`, -1},
+		{`indexOf("añb", "b")`, 2},
+		{`indexOf([1, 2, 3], 3)`, 2},
+		{`indexOf([1, 2, 3], true)`, -1},
+		{`charCodeAt("abc", 1)`, 98},
+		{`charCodeAt("añb", 1)`, 241},
+		{`charCodeAt("abc", 3)`, nil},
+		{`fromCharCode(104, 105)`, "hi"},
+		{`fromCharCode(241)`, "ñ"},
+		{`fromCharCode()`, ""},
+		{`fromCharCode(charCodeAt("a", 0) + 1)`, "b"},
+		{`if (contains("abc", "b")) { 1 } else { 2 }`, 1},
+		{`contains("abc", "b") == true`, true},
+		{`format("%s is %d", "x", 42)`, "x is 42"},
+		{`format("%v and %s", "a", "a")`, `"a" and a`},
+		{`format("%s %v", [1, "b"], {"k": "v"})`, `[1, "b"] {"k": "v"}`},
+		{`format("100%%")`, "100%"},
+		{`format("ñ%sñ", 1.5)`, "ñ1.5ñ"},
+		{`format("%s", [][0])`, "null"},
+		{`print()`, nil},
+		{`printf("")`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			str, ok := evaluated.(*object.String)
+
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+
+				continue
+			}
+
+			if str.Value != expected {
+				t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+			}
+		}
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	passing := []string{
+		`assert(true)`,
+		`assert(1, "numbers are truthy")`,
+		`assertEqual(1 + 1, 2)`,
+		`assertEqual([1, [2, "a"]], [1, [2, "a"]])`,
+		`assertEqual({"a": [1], 2: true}, {2: true, "a": [1]})`,
+		`assertEqual([][0], if (false) { 1 })`,
+	}
+
+	for _, input := range passing {
+		testNullObject(t, testEval(input))
+	}
+
+	failing := []struct {
+		input    string
+		expected string
+	}{
+		{`assert(false)`, "assertion failed"},
+		{`assert([][0], "no value")`, "assertion failed: no value"},
+		{`assertEqual(1, 2)`, "assertEqual failed: expected 2, got 1"},
+		{`assertEqual([1, 2], [1])`, "assertEqual failed: expected [1], got [1, 2]"},
+		{`assertEqual({"a": 1}, {"a": 2})`, `assertEqual failed: expected {"a": 2}, got {"a": 1}`},
+		{`assertEqual(1, 1.0)`, "assertEqual failed: expected 1.0, got 1"},
+		{`try { assert(false, "kept") } catch (e) { e }`, "assertion failed: kept"},
+	}
+
+	for _, tt := range failing {
+		errObj, ok := testEval(tt.input).(*object.Error)
+
+		if !ok || errObj.Failure == nil {
+			t.Errorf("%s: expected a failed assertion, got=%+v", tt.input, errObj)
+
+			continue
+		}
+
+		if errObj.Message != tt.expected {
+			t.Errorf("%s: wrong message. want=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+
+	misused := []struct {
+		input    string
+		expected string
+	}{
+		{`assert()`, "wrong number of arguments. got=0, want=1 or 2"},
+		{`assert(true, 1)`, "second argument to `assert` must be STRING, got INTEGER"},
+		{`assertEqual(1)`, "wrong number of arguments. got=1, want=2"},
+	}
+
+	for _, tt := range misused {
+		errObj, ok := testEval(tt.input).(*object.Error)
+
+		if !ok || errObj.Failure != nil || errObj.Message != tt.expected {
+			t.Errorf("%s: want plain error %q, got=%+v", tt.input, tt.expected, errObj)
+		}
+	}
+}
+
+func TestExitAndPanic(t *testing.T) {
+	exits := []struct {
+		input    string
+		expected int
+	}{
+		{"exit()", 0},
+		{"exit(3); 1 + true", 3},
+		{"let f = fn() { try { exit(255) } catch (e) { 0 } }; f()", 255},
+		{"recover(fn() { exit(4) })", 4},
+	}
+
+	for _, tt := range exits {
+		errObj, ok := testEval(tt.input).(*object.Error)
+
+		if !ok || errObj.Exit == nil || errObj.Exit.Code != tt.expected {
+			t.Errorf("%s: expected exit(%d), got=%+v", tt.input, tt.expected, errObj)
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`try { panic("lost") } catch (e) { e }`, "panic: lost"},
+		{`let f = fn() { panic([1, "a"]) }; try { f() } catch (e) { e }`, `panic: [1, "a"]`},
+		{"recover(fn() { assert(false) })", "assertion failed"},
+		{"exit(1, 2)", "wrong number of arguments. got=2, want=0 or 1"},
+		{`exit("1")`, "argument to `exit` must be INTEGER, got STRING"},
+		{"exit(256)", "exit code must be between 0 and 255, got 256"},
+		{"panic()", "wrong number of arguments. got=0, want=1"},
+		{"recover()", "wrong number of arguments. got=0, want at least 1"},
+		{"recover(1)", "first argument to `recover` must be FUNCTION, got INTEGER"},
+	}
+
+	for _, tt := range errors {
+		errObj, ok := testEval(tt.input).(*object.Error)
+
+		if !ok || errObj.Message != tt.expected {
+			t.Errorf("%s: want error %q, got=%+v", tt.input, tt.expected, errObj)
+		}
+	}
+}
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`recover(fn() { panic("lost") })`, `[null, "panic: lost"]`},
+		{"recover(fn() { 1 / 0 })", `[null, "division by zero: 1 / 0"]`},
+		{"recover(fn(a, b) { a + b }, 1, 2)", "[3, null]"},
+		{"recover(fn() { })", "[null, null]"},
+		{"recover(len, [1, 2])", "[2, null]"},
+		{`let value, err = recover(fn() { panic("x") }); err`, `"panic: x"`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStringBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`split(1, ",")`, "first argument to `split` must be STRING, got INTEGER"},
+		{`split("a")`, "wrong number of arguments. got=1, want=2"},
+		{`join("abc", ",")`, "first argument to `join` must be ARRAY, got STRING"},
+		{`join([1, 2], ",")`, "argument to `join` must be ARRAY of STRING, got INTEGER element"},
+		{`contains(1, 1)`, "argument to `contains` not supported, got INTEGER"},
+		{`contains("abc", 1)`, "second argument to `contains` must be STRING, got INTEGER"},
+		{`replace("abc", "a", 1)`, "arguments to `replace` must be STRING, got INTEGER"},
+		{`trim(5)`, "argument to `trim` must be STRING, got INTEGER"},
+		{`upper("a", "b")`, "wrong number of arguments. got=2, want=1"},
+		{`lower([])`, "argument to `lower` must be STRING, got ARRAY"},
+		{`indexOf(true, 1)`, "argument to `indexOf` not supported, got BOOLEAN"},
+		{`charCodeAt("abc")`, "wrong number of arguments. got=1, want=2"},
+		{`charCodeAt(1, 0)`, "first argument to `charCodeAt` must be STRING, got INTEGER"},
+		{`charCodeAt("abc", "0")`, "second argument to `charCodeAt` must be INTEGER, got STRING"},
+		{`fromCharCode("a")`, "arguments to `fromCharCode` must be INTEGER, got STRING"},
+		{`fromCharCode(-1)`, "invalid character code: -1"},
+		{`fromCharCode(55296)`, "invalid character code: 55296"},
+		{`format()`, "wrong number of arguments. got=0, want at least 1"},
+		{`format(1)`, "first argument to `format` must be STRING, got INTEGER"},
+		{`format("%d", "1")`, "argument for %d in `format` must be INTEGER, got STRING"},
+		{`format("%s %s", 1)`, "missing argument for %s in `format`"},
+		{`format("%s", 1, 2)`, "too many arguments to `format`. got=2, want=1"},
+		{`format("%x", 1)`, "unknown verb %x in `format`"},
+		{`printf("50%")`, "format string of `printf` ends with a lone %"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+
+		if !ok {
+			t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+
+			continue
+		}
+
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestIOBuiltins(t *testing.T) {
+	var out bytes.Buffer
+
+	object.SetInput(strings.NewReader("first\r\nsecond"))
+	object.SetOutput(&out)
+	object.SetArgs([]string{"-v", "input.txt"})
+
+	defer object.SetInput(os.Stdin)
+	defer object.SetOutput(os.Stdout)
+	defer object.SetArgs([]string{})
+
+	path := filepath.Join(t.TempDir(), "out.txt")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[readLine(), readLine(), readLine()]`, `["first", "second", null]`},
+		{`args()`, `["-v", "input.txt"]`},
+		{fmt.Sprintf(`writeFile(%q, "a\nb"); readFile(%q)`, path, path), `"a\nb"`},
+		{`puts("x", 1); print("y"); printf("%d", 2)`, "null"},
+		{`readFile(1)`, "ERROR: argument to `readFile` must be STRING, got INTEGER"},
+		{`writeFile("x")`, "ERROR: wrong number of arguments. got=1, want=2"},
+		{`writeFile("x", 1)`, "ERROR: second argument to `writeFile` must be STRING, got INTEGER"},
+		{`readLine(1)`, "ERROR: wrong number of arguments. got=1, want=0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			errObj.Line = 0
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	if out.String() != "x\n1\ny2" {
+		t.Errorf("wrong output. expected=%q, got=%q", "x\n1\ny2", out.String())
+	}
+
+	evaluated := testEval(`readFile("/does/not/exist")`)
+
+	if !isError(evaluated) || !strings.HasPrefix(evaluated.(*object.Error).Message, "could not read file: ") {
+		t.Errorf("expected a read error. got=%s", evaluated.Inspect())
+	}
+}
+
+func TestEnvironmentIO(t *testing.T) {
+	var a, b bytes.Buffer
+
+	envA, envB := object.NewEnvironment(), object.NewEnvironment()
+	envA.SetIO(object.NewIO(strings.NewReader("line\n"), &a))
+	envB.SetIO(object.NewIO(nil, &b))
+
+	run := func(input string, env *object.Environment) object.Object {
+		return Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+	}
+
+	run(`let say = fn(x) { puts(x) }; let t = spawn(fn() { say("task") }); let p = print`, envA)
+	run(`puts("b")`, envB)
+	run(`wait(t); say(readLine()); map([1], p)`, envA)
+
+	if got, want := a.String(), "task\nline\n1"; got != want {
+		t.Errorf("wrong output in A. want=%q, got=%q", want, got)
+	}
+
+	if got, want := b.String(), "b\n"; got != want {
+		t.Errorf("wrong output in B. want=%q, got=%q", want, got)
+	}
+}
+
+func TestHigherOrderBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, []int{2, 4, 6}},
+		{`map([], fn(x) { x })`, []int{}},
+		{`filter([1, 2, 3, 4], fn(x) { x > 2 })`, []int{3, 4}},
+		{`reduce([1, 2, 3, 4], 0, fn(acc, x) { acc + x })`, 10},
+		{`reduce([], 7, fn(acc, x) { acc + x })`, 7},
+		{`sort([3, 1, 2])`, []int{1, 2, 3}},
+		{`sort([3, 1, 2], fn(a, b) { a > b })`, []int{3, 2, 1}},
+		{`join(sort(["b", "c", "a"]), "")`, "abc"},
+		{`reverse([1, 2, 3])`, []int{3, 2, 1}},
+		{`let a = [3, 1, 2]; sort(a); reverse(a); a`, []int{3, 1, 2}},
+		{`map(["a", "b"], upper)[1]`, "B"},
+		{`let n = 10; map([1, 2], fn(x) { x + n })`, []int{11, 12}},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+
+				continue
+			}
+
+			if str.Value != expected {
+				t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+			}
+		case []int:
+			array, ok := evaluated.(*object.Array)
+
+			if !ok {
+				t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+
+				continue
+			}
+
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+
+				continue
+			}
+
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], int64(expectedElem))
+			}
+		}
+	}
+}
+
+func TestRanges(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"range(3)", "range(0, 3)"},
+		{"range(1, 10, 3)", "range(1, 10, 3)"},
+		{"len(range(10))", "10"},
+		{"len(range(10, 0))", "0"},
+		{"len(range(1, 10, 3))", "3"},
+		{"len(range(10, 0, -3))", "4"},
+		{"len(range(0, 9223372036854775807))", "9223372036854775807"},
+		{"range(10, 0, -3)[3]", "1"},
+		{"range(3)[3]", "null"},
+		{"range(3)[-1]", "null"},
+		{"first(range(5, 0, -2))", "5"},
+		{"last(range(1, 10, 3))", "7"},
+		{"first(range(0))", "null"},
+		{"contains(range(0, 100, 5), 35)", "true"},
+		{"contains(range(0, 100, 5), 36)", "false"},
+		{`indexOf(range(10, 0, -2), 4)`, "3"},
+		{`indexOf(range(3), "1")`, "-1"},
+		{"rest(range(3))", "[1, 2]"},
+		{"push(range(2), 9)", "[0, 1, 9]"},
+		{"map(range(4), fn(x) { x * x })", "[0, 1, 4, 9]"},
+		{"filter(range(10), fn(x) { x % 3 == 0 })", "[0, 3, 6, 9]"},
+		{"reduce(range(101), 0, fn(acc, x) { acc + x })", "5050"},
+		{"reverse(range(3))", "[2, 1, 0]"},
+		{"let add = fn(a, b, c) { a + b + c }; add(...range(1, 4))", "6"},
+		{"len(range(1000000000000))", "1000000000000"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`parseJSON("{\"b\": [1, 2.5, \"x\"], \"a\": {\"ok\": true, \"none\": null}}")`,
+			`{"b": [1, 2.5, "x"], "a": {"ok": true, "none": null}}`},
+		{`parseJSON("12345678901234567890")`, "12345678901234567890"},
+		{`parseJSON("1e3")`, "1000.0"},
+		{`parseJSON(" \"\\u00e9\\n\" ")`, `"é\n"`},
+		{`parseJSON("{\"a\": 1, \"a\": 2}")`, `{"a": 2}`},
+		{`parseJSON("[]")`, "[]"},
+		{`toJSON({"b": [1, 2.5, "x"], 1: true, false: "f"})`, `"{\"b\":[1,2.5,\"x\"],\"1\":true,\"false\":\"f\"}"`},
+		{`toJSON(3.0)`, `"3.0"`},
+		{`toJSON(2 ** 70)`, `"1180591620717411303424"`},
+		{`toJSON("<a & \"b\">")`, `"\"<a & \\\"b\\\">\""`},
+		{`toJSON(range(3))`, `"[0,1,2]"`},
+		{`toJSON({"a": [1]}, "  ")`, `"{\n  \"a\": [\n    1\n  ]\n}"`},
+		{`let h = {"a": [1, {"b": parseJSON("null")}]}; assertEqual(parseJSON(toJSON(h)), h)`, "null"},
+		{`let a = [1]; toJSON([a, a])`, `"[[1],[1]]"`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`parseJSON("[undefined]")`, "invalid JSON: invalid character 'u' looking for beginning of value"},
+		{`parseJSON("[1, 2")`, "invalid JSON: unexpected end of JSON input"},
+		{`parseJSON("1 2")`, "invalid JSON: unexpected data after the JSON value"},
+		{`parseJSON("")`, "invalid JSON: unexpected end of JSON input"},
+		{`parseJSON("1e999")`, "invalid JSON: number out of range: 1e999"},
+		{`parseJSON(1)`, "argument to `parseJSON` must be STRING, got INTEGER"},
+		{`toJSON(fn() { 1 })`, "cannot convert FUNCTION to JSON"},
+		{`toJSON({1: 1, "1": 2})`, `duplicate JSON key: "1"`},
+		{`toJSON(0.0 / 0.0)`, "cannot convert NaN to JSON"},
+		{`let a = [1]; a[0] = a; toJSON(a)`, "cannot convert an array that contains itself to JSON"},
+		{`toJSON(1, 2)`, "second argument to `toJSON` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+
+			continue
+		}
+
+		if errObj.Message != tt.expected {
+			t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestMathBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`abs(-5)`, "5"},
+		{`abs(-2.5)`, "2.5"},
+		{`abs(-9223372036854775807 - 1)`, "9223372036854775808"},
+		{`abs(-(2 ** 70))`, "1180591620717411303424"},
+		{`min(3, 1.5, 2)`, "1.5"},
+		{`max([4, 9, 2])`, "9"},
+		{`max(2 ** 64, 1.0)`, "18446744073709551616"},
+		{`min(range(5, 10))`, "5"},
+		{`max(1, 1.0)`, "1"},
+		{`pow(2, 10)`, "1024"},
+		{`pow(2, -1)`, "0.5"},
+		{`pow(2.0, 0.5) == sqrt(2)`, "true"},
+		{`pow(3, 50)`, "717897987691852588770249"},
+		{`sqrt(16)`, "4.0"},
+		{`floor(2.7)`, "2"},
+		{`floor(-2.5)`, "-3"},
+		{`ceil(2.1)`, "3"},
+		{`round(2.5)`, "3"},
+		{`round(-2.5)`, "-3"},
+		{`round(2.0 ** 70)`, "1180591620717411303424"},
+		{`floor(7)`, "7"},
+		{`let r = random(); r >= 0 && r < 1`, "true"},
+		{`let ok = true; for (i in range(100)) { let n = randomInt(3); ok = ok && n >= 0 && n < 3 }; ok`, "true"},
+		{`abs("x")`, "ERROR: argument to `abs` must be INTEGER or FLOAT, got STRING"},
+		{`min()`, "ERROR: `min` needs at least one number"},
+		{`max([])`, "ERROR: `max` needs at least one number"},
+		{`max(1, "2")`, "ERROR: arguments to `max` must be INTEGER or FLOAT, got STRING"},
+		{`sqrt(-1)`, "ERROR: square root of a negative number: -1"},
+		{`round(0.0 / 0.0)`, "ERROR: cannot round NaN to an integer"},
+		{`pow(10, 10000000000)`, "ERROR: integer too large: 10 ** 10000000000"},
+		{`randomInt(0)`, "ERROR: argument to `randomInt` must be a positive INTEGER, got 0"},
+		{`seedRandom(1.5)`, "ERROR: argument to `seedRandom` must be INTEGER, got FLOAT"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			if "ERROR: "+errObj.Message != tt.expected {
+				t.Errorf("wrong error for %s. got=%s, want=%s", tt.input, errObj.Message, tt.expected)
+			}
+
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestSeededRandomIsRepeatable(t *testing.T) {
+	first := testEval(`seedRandom(7); [random(), randomInt(1000), randomInt(1000)]`).Inspect()
+	second := testEval(`seedRandom(7); [random(), randomInt(1000), randomInt(1000)]`).Inspect()
+
+	if first != second {
+		t.Errorf("seeded runs differ: %s and %s", first, second)
+	}
+}
+
+func TestTimeBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`formatTime(0, "DateTime", "UTC")`, `"1970-01-01 00:00:00"`},
+		{`formatTime(1709296200123, "2006-01-02T15:04:05.000", "UTC")`, `"2024-03-01T12:30:00.123"`},
+		{`parseTime("2024-03-01 12:30:00", "DateTime", "UTC")`, "1709296200000"},
+		{`parseTime("2024-03-01T13:30:00+01:00", "RFC3339")`, "1709296200000"},
+		{`let ts = timestamp(); parseTime(formatTime(ts, "RFC3339"), "RFC3339") == ts - ts % 1000`, "true"},
+		{`let t = now("UTC"); [t["year"] >= 2024, t["month"] <= 12, len(t["weekday"]) > 0]`, `[true, true, true]`},
+		{`let t = timestamp(); sleep(20); timestamp() - t >= 20`, "true"},
+		{`let ch = channel(); spawn(fn() { sleep(10); send(ch, "woke") }); recv(ch)`, `"woke"`},
+		{`sleep(0.5)`, "null"},
+		{`parseTime("March", "DateOnly")`, "ERROR: could not parse time: parsing time \"March\" as \"2006-01-02\": cannot parse \"March\" as \"2006\""},
+		{`formatTime(0, "DateTime", "Nowhere/Else")`, "ERROR: unknown time zone: Nowhere/Else"},
+		{`formatTime("0", "DateTime")`, "ERROR: first argument to `formatTime` must be INTEGER, got STRING"},
+		{`sleep(-1)`, "ERROR: argument to `sleep` must be a non-negative number of milliseconds, got -1"},
+		{`timestamp(1)`, "ERROR: wrong number of arguments. got=1, want=0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			if "ERROR: "+errObj.Message != tt.expected {
+				t.Errorf("wrong error for %s. got=%s, want=%s", tt.input, errObj.Message, tt.expected)
+			}
+
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestConversionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`int("42")`, "42"},
+		{`int("-7") + int("+3")`, "-4"},
+		{`int("123456789012345678901234567890")`, "123456789012345678901234567890"},
+		{`int(3.9)`, "3"},
+		{`int(-3.9)`, "-3"},
+		{`int(2.0 ** 80)`, "1208925819614629174706176"},
+		{`int(true) + int(false)`, "1"},
+		{`int(5)`, "5"},
+		{`float(2)`, "2.0"},
+		{`float("2.5")`, "2.5"},
+		{`float("1e3")`, "1000.0"},
+		{`float(true)`, "1.0"},
+		{`str(42)`, `"42"`},
+		{`str(1.5)`, `"1.5"`},
+		{`str("hi")`, `"hi"`},
+		{`str([1, "a"])`, `"[1, \"a\"]"`},
+		{`str(isNull(1))`, `"false"`},
+		{`bool(0)`, "true"},
+		{`bool("")`, "true"},
+		{`bool(isNull(1))`, "false"},
+		{`bool(first([]))`, "false"},
+		{`type(1)`, `"INTEGER"`},
+		{`type(2 ** 64)`, `"BIGINT"`},
+		{`type(1.5)`, `"FLOAT"`},
+		{`type("a")`, `"STRING"`},
+		{`type([])`, `"ARRAY"`},
+		{`type({})`, `"HASH"`},
+		{`type(fn() {})`, `"FUNCTION"`},
+		{`type(len)`, `"BUILTIN"`},
+		{`type(first([]))`, `"NULL"`},
+		{`type(range(3))`, `"RANGE"`},
+		{`let describe = fn(x) { switch (type(x)) { case "INTEGER", "FLOAT": "number" case "STRING": "text" default: "other" } };
+		[describe(1), describe(2.5), describe("s"), describe([])]`, `["number", "number", "text", "other"]`},
+		{`int("4.5")`, `ERROR: cannot convert "4.5" to INTEGER`},
+		{`int(" 4")`, `ERROR: cannot convert " 4" to INTEGER`},
+		{`int(0.0 / 0.0)`, "ERROR: cannot convert NaN to INTEGER"},
+		{`int([1])`, "ERROR: cannot convert ARRAY to INTEGER"},
+		{`float("abc")`, `ERROR: cannot convert "abc" to FLOAT`},
+		{`float(first([]))`, "ERROR: cannot convert NULL to FLOAT"},
+		{`type()`, "ERROR: wrong number of arguments. got=0, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			if "ERROR: "+errObj.Message != tt.expected {
+				t.Errorf("wrong error for %s. got=%s, want=%s", tt.input, errObj.Message, tt.expected)
+			}
+
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestStructuralEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2] == [1, 2]`, "true"},
+		{`[1, 2] != [1, 2]`, "false"},
+		{`[1, 2] == [2, 1]`, "false"},
+		{`[1, [2, "a"]] == [1, [2, "a"]]`, "true"},
+		{`[1] == [1.0]`, "true"},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, "true"},
+		{`{"a": 1} == {"a": 2}`, "false"},
+		{`{1: 1} == {"1": 1}`, "false"},
+		{`range(3) == [0, 1, 2]`, "true"},
+		{`range(0, 6, 2) == range(0, 5, 2)`, "true"},
+		{`[] == {}`, "false"},
+		{`let f = fn() {}; [f] == [f]`, "true"},
+		{`[fn() {}] == [fn() {}]`, "false"},
+		{`let nan = 0.0 / 0.0; [nan] == [nan]`, "false"},
+		{`let a = [1]; a[0] = a; let b = [1]; b[0] = b; a == b`, "true"},
+		{`switch ([1, 2]) { case [1, 2]: "matched" default: "no" }`, `"matched"`},
+		{`deepEqual([1, {"a": [2]}], [1, {"a": [2]}])`, "true"},
+		{`deepEqual([1], [1.0])`, "false"},
+		{`deepEqual(range(3), [0, 1, 2])`, "false"},
+		{`compare(1, 2)`, "-1"},
+		{`compare(2.5, 2)`, "1"},
+		{`compare("b", "a")`, "1"},
+		{`compare([1, 2], [1, 2])`, "0"},
+		{`compare([1, 2], [1, 2, 0])`, "-1"},
+		{`compare([1, "b"], [1, "a"])`, "1"},
+		{`compare(false, true)`, "-1"},
+		{`sort([[2, 1], [1, 5], [1, 2]])`, "[[1, 2], [1, 5], [2, 1]]"},
+		{`sort([3, 1, 2], fn(a, b) { compare(b, a) < 0 })`, "[3, 2, 1]"},
+		{`compare(1, "a")`, "ERROR: cannot compare INTEGER and STRING"},
+		{`compare({}, {})`, "ERROR: cannot compare HASH and HASH"},
+		{`compare([1], ["a"])`, "ERROR: cannot compare INTEGER and STRING"},
+		{`sort([[1], [true]])`, "ERROR: cannot compare BOOLEAN and INTEGER in `sort`"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			if "ERROR: "+errObj.Message != tt.expected {
+				t.Errorf("wrong error for %s. got=%s, want=%s", tt.input, errObj.Message, tt.expected)
+			}
+
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = freeze([1, 2]); a[0] = 5`, "ERROR: cannot assign to frozen ARRAY"},
+		{`let h = freeze({"a": 1}); h["b"] = 2`, "ERROR: cannot assign to frozen HASH"},
+		{`let h = freeze({"a": [1]}); h["a"][0] = 2`, "ERROR: cannot assign to frozen ARRAY"},
+		{`let a = freeze([1]); a[0] += 1`, "ERROR: cannot assign to frozen ARRAY"},
+		{`let a = freeze([1]); try { a[0] = 2 } catch (e) { 0 }; a`, "[1]"},
+		{`let a = [1]; let b = freeze(a); [a == b, isFrozen(a)]`, "[true, true]"},
+		{`let a = freeze([1]); let b = push(a, 2); b[0] = 9; [a, b, isFrozen(b)]`, "[[1], [9, 2], false]"},
+		{`let a = [1]; a[0] = a; freeze(a); isFrozen(a)`, "true"},
+		{`isFrozen([1])`, "false"},
+		{`isFrozen({})`, "false"},
+		{`isFrozen(1)`, "true"},
+		{`isFrozen("s")`, "true"},
+		{`freeze(5)`, "5"},
+		{`freeze()`, "ERROR: wrong number of arguments. got=0, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			if "ERROR: "+errObj.Message != tt.expected {
+				t.Errorf("wrong error for %s. got=%s, want=%s", tt.input, errObj.Message, tt.expected)
+			}
+
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestPipelines(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let isEven = fn(x) { x % 2 == 0 }; let double = fn(x) { x * 2 }; let sum = fn(xs) { reduce(xs, 0, fn(a, b) { a + b }) }; [1, 2, 3, 4] |> filter(isEven) |> map(double) |> sum()`, "12"},
+		{`"a,b" |> split(",") |> len`, "2"},
+		{`let add = fn(a, b) { a + b }; 1 + 2 |> add(10)`, "13"},
+		{`let add = fn(a, b) { a + b }; let adder = fn(n) { fn(x) { add(x, n) } }; 1 |> (2 |> adder)`, "3"},
+		{`let f = fn(x, scale = 1) { x * scale }; 5 |> f(scale: 3)`, "15"},
+		{`let f = fn(a, b, c) { [a, b, c] }; 1 |> f(...[2, 3])`, "[1, 2, 3]"},
+		{`1 |> 2`, "ERROR: not a function: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			if "ERROR: "+errObj.Message != tt.expected {
+				t.Errorf("wrong error for %s. got=%s, want=%s", tt.input, errObj.Message, tt.expected)
+			}
+
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestCombinators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let inc = fn(x) { x + 1 }; let double = fn(x) { x * 2 }; compose(inc, double)(5)`, "11"},
+		{`let add = fn(a, b) { a + b }; let neg = fn(x) { -x }; compose(neg, neg, add)(1, 2)`, "3"},
+		{`compose(len, upper)("abc")`, "3"},
+		{`compose(fn(x) { x })`, "builtin function"},
+		{`let addThree = fn(a, b, c) { a + b + c }; curry(addThree)(1)(2)(3)`, "6"},
+		{`let addThree = fn(a, b, c) { a + b + c }; let c = curry(addThree); let one = c(1); [one(2, 3), one(10)(20)]`, "[6, 31]"},
+		{`let f = fn(a, b = 10) { a + b }; curry(f)(1)`, "11"},
+		{`let f = fn(a, ...rest) { [a, rest] }; curry(f)(1)`, "[1, []]"},
+		{`let add = fn(a, b) { a + b }; partial(add, 1)(2)`, "3"},
+		{`let f = fn(a, b, c) { [a, b, c] }; partial(f, 1, 2)(3)`, "[1, 2, 3]"},
+		{`map([1, 2, 3], partial(fn(a, b) { a * b }, 10))`, "[10, 20, 30]"},
+		{`partial(push, [1])(2)`, "[1, 2]"},
+		{`compose()`, "ERROR: `compose` needs at least one function"},
+		{`compose(len, 1)`, "ERROR: arguments to `compose` must be FUNCTION, got INTEGER"},
+		{`compose(len, fn(x) { x / 0 })(1)`, "ERROR: division by zero: 1 / 0"},
+		{`curry(len)`, "ERROR: argument to `curry` must be FUNCTION, got BUILTIN"},
+		{`partial(1, 2)`, "ERROR: first argument to `partial` must be FUNCTION, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			if "ERROR: "+errObj.Message != tt.expected {
+				t.Errorf("wrong error for %s. got=%s, want=%s", tt.input, errObj.Message, tt.expected)
+			}
+
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestFuzzerFindings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let f = fn() {}; f() + 1`, "ERROR: type mismatch: NULL + INTEGER"},
+		{`let f = fn(n) { if (n < 0) { } else { 0 % f(-1) } }; f(0)`, "ERROR: type mismatch: INTEGER % NULL"},
+		{`let x = if (true) { let y = 1; }; [x]`, "[null]"},
+		{`macro() { 1 }.x`, "ERROR: a macro must be bound by a top-level let"},
+		{`quote()`, "ERROR: wrong number of arguments. got=0, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			if "ERROR: "+errObj.Message != tt.expected {
+				t.Errorf("wrong error for %s. got=%s, want=%s", tt.input, errObj.Message, tt.expected)
+			}
+
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestOptionalChaining(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let h = {"a": {"b": [1, 2]}}; h?.a?.b?[1]`, 2},
+		{`let h = {}; h.a?.b?[0] ?? 3`, 3},
+		{`let n = if (false) { 1 }; isNull(n?.a) && isNull(n?["a"]) && isNull(n?[0:1])`, true},
+		{`[1, 2, 3]?[1:]?[0]`, 2},
+		{`let calls = 0; let n = if (false) { 1 }; n?[calls += 1]; calls`, 0},
+		{`let h = {"a": 1}; h?.a`, 1},
+		{`let n = if (false) { 1 }; n?.a.b`, "no method b on NULL"},
+		{`1?[0]`, "index operator not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+
+				continue
+			}
+
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestRegularExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`match("b+", "abbc")`, `{"match": "bb", "index": 1, "groups": [], "named": {}}`},
+		{`match("(?P<year>\\d{4})-(\\d{2})?", "on 2024-")`,
+			`{"match": "2024-", "index": 3, "groups": ["2024", null], "named": {"year": "2024"}}`},
+		{`match("é(.)", "café!")["index"]`, "3"},
+		{`match("x", "abc")`, "null"},
+		{`map(findAll("(\\w)=(\\d)", "a=1, b=2"), fn(m) { m["groups"] })`, `[["a", "1"], ["b", "2"]]`},
+		{`findAll("x", "abc")`, "[]"},
+		{`replaceRegex("(\\w+)@(\\w+)", "me@home", "$2 of $1")`, `"home of me"`},
+		{`replaceRegex("(?P<n>\\d)", "a1b2", "<\${n}>")`, `"a<1>b<2>"`},
+		{`let n = 0; for (w in ["a", "bb", "c"]) { if (match("^.$", w)) { n++ } }; n`, "2"},
+		{`match("(", "x")`, "ERROR: invalid regular expression: missing closing ): `(`"},
+		{`findAll("a**", "x")`, "ERROR: invalid regular expression: invalid nested repetition operator: `**`"},
+		{`match(1, "x")`, "ERROR: arguments to `match` must be STRING, got INTEGER"},
+		{`replaceRegex("a", "b")`, "ERROR: wrong number of arguments. got=2, want=3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			if "ERROR: "+errObj.Message != tt.expected {
+				t.Errorf("wrong error for %s. got=%s, want=%s", tt.input, errObj.Message, tt.expected)
+			}
+
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+
+		body, _ := io.ReadAll(r.Body)
+
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Token", r.Header.Get("X-Token"))
+
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+
+		fmt.Fprintf(w, "%s %s", r.Header.Get("Content-Type"), body)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let r = httpGet("%s/"); [r.status, r.headers["X-Method"], r.body]`, `[200, "GET", " "]`},
+		{`httpGet("%s/missing").status`, "404"},
+		{`httpGet("%s/", {"headers": {"X-Token": "abc"}}).headers["X-Token"]`, `"abc"`},
+		{`let r = httpPost("%s/", "{}", {"Content-Type": "application/json"}); [r.headers["X-Method"], r.body]`,
+			`["POST", "application/json {}"]`},
+		{`httpPost("%s/", "hi", {}, {"headers": {"X-Token": "t"}}).headers["X-Token"]`, `"t"`},
+		{`httpGet("%s/slow", {"timeout": 0.05}).body`, "ERROR: request failed: "},
+		{`httpGet("%s/", {"timeout": 0})`, "ERROR: timeout of `httpGet` must be a positive number of seconds, got 0"},
+		{`httpGet("%s/", {"retries": 3})`, `ERROR: unknown option to ` + "`httpGet`" + `: "retries"`},
+		{`httpPost("%s/", "", {"a": 1})`, "ERROR: headers to `httpPost` must map STRING to STRING, got STRING: INTEGER"},
+		{`httpGet(1)`, "ERROR: first argument to `httpGet` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		input := strings.ReplaceAll(tt.input, "%s", server.URL)
+		evaluated := testEval(input)
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			// A failed request's message ends with the details of the failure.
+			if !strings.HasPrefix("ERROR: "+errObj.Message, tt.expected) {
+				t.Errorf("wrong error for %s. got=%s, want=%s", tt.input, errObj.Message, tt.expected)
+			}
+
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestHTTPWithoutNetwork(t *testing.T) {
+	object.SetNetwork(false)
+	defer object.SetNetwork(true)
+
+	evaluated := testEval(`httpGet("http://example.com")`)
+
+	errObj, ok := evaluated.(*object.Error)
+
+	if !ok || errObj.Message != "network access is disabled" {
+		t.Errorf("expected network access to be disabled, got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	long := `let h = {"name": "monkey", "tags": ["a", "b"], "nested": {"list": [1, 2, 3], "more": {"deep": [1, [2, [3]]]}}}; `
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = [1, 2]; a[0] = a; a", "[[...], 2]"},
+		{`let h = {"a": 1}; h["self"] = h; h`, `{"a": 1, "self": {...}}`},
+		{"let a = [1]; let b = [a, a]; b", "[[1], [1]]"},
+		{`inspect("a")`, `"\"a\""`},
+		{`inspect([1, [2, 3]])`, `"[1, [2, 3]]"`},
+		{`inspect([1, [2, [3]]], 1)`, `"[1, [...]]"`},
+		{`inspect([[], {}], 1)`, `"[[], {}]"`},
+		{`inspect([1], 0)`, `"[...]"`},
+		{"let a = [1, 2]; a[0] = a; inspect(a)", `"[[...], 2]"`},
+		{long + "inspect(h)", `"{\n  \"name\": \"monkey\",\n  \"tags\": [\"a\", \"b\"],\n  \"nested\": {\"list\": [1, 2, 3], \"more\": {\"deep\": [1, [2, [3]]]}}\n}"`},
+		{long + "inspect(h, 1)", `"{\"name\": \"monkey\", \"tags\": [...], \"nested\": {...}}"`},
+		{`let s = "abcdefghijklmnopqrstuvwxyz"; inspect([[1, 2], s, s, {"k": s}])`, `"[\n  [1, 2],\n  \"abcdefghijklmnopqrstuvwxyz\",\n  \"abcdefghijklmnopqrstuvwxyz\",\n  {\"k\": \"abcdefghijklmnopqrstuvwxyz\"}\n]"`},
+		{"inspect()", "wrong number of arguments. got=0, want=1 or 2"},
+		{"inspect(1, -1)", "depth of `inspect` must be a non-negative INTEGER, got -1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			if errObj.Message != tt.expected {
+				t.Errorf("wrong error for %s. got=%s, want=%s", tt.input, errObj.Message, tt.expected)
+			}
+
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s.\ngot=%s\nwant=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestBytes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`bytes("hi\n")`, `b"hi\n"`},
+		{`bytes([0, 34, 92, 127, 255])`, `b"\x00\"\\\x7f\xff"`},
+		{`let b = bytes("héllo"); [len(b), b[1], b[9]]`, "[6, 195, null]"},
+		{`bytes("hello")[1:3]`, `b"el"`},
+		{`bytes("hello")[-2:99] == bytes("hello")`, "true"},
+		{`bytes("ab") + bytes("cd")`, `b"abcd"`},
+		{`[compare(bytes("a"), bytes("b")), compare(bytes("b"), bytes("ab"))]`, "[-1, 1]"},
+		{`str(bytes("héllo"))`, `"héllo"`},
+		{`let b = bytes([1, 2]); bytes(b) == b`, "true"},
+		{`let xs = []; for (x in bytes("ab")) { xs = push(xs, x) }; xs`, "[97, 98]"},
+		{`hexEncode(bytes([0, 171, 255]))`, `"00abff"`},
+		{`hexEncode("hi")`, `"6869"`},
+		{`hexDecode("00ABff")`, `b"\x00\xab\xff"`},
+		{`base64Encode(bytes("hello"))`, `"aGVsbG8="`},
+		{`bytes("hello").base64Encode()`, `"aGVsbG8="`},
+		{`base64Decode("aGVsbG8=")`, `b"hello"`},
+		{`bytes(1)`, "argument to `bytes` must be STRING or ARRAY, got INTEGER"},
+		{`bytes([1, 256])`, "elements of an array for `bytes` must be INTEGER from 0 to 255, got 256"},
+		{`hexEncode(1)`, "argument to `hexEncode` must be BYTES or STRING, got INTEGER"},
+		{`hexDecode("abc")`, "invalid hex: odd length hex string"},
+		{`hexDecode("zz")`, "invalid hex: invalid byte: U+007A 'z'"},
+		{`base64Decode("a")`, "invalid base64: illegal base64 data at input byte 0"},
+		{`let b = bytes("a"); b[0] = 1`, "index assignment not supported: BYTES"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			if errObj.Message != tt.expected {
+				t.Errorf("wrong error for %s. got=%s, want=%s", tt.input, errObj.Message, tt.expected)
+			}
+
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestSets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`set([3, 1, 3, "a", true, 1])`, `set([3, 1, "a", true])`},
+		{`set()`, "set([])"},
+		{`set(range(3))`, "set([0, 1, 2])"},
+		{`let s = set([1, 2]); set(s) == s`, "true"},
+		{`len(set([1, 1, 2]))`, "2"},
+		{`let s = set(["a", "b"]); [has(s, "a"), has(s, "c"), has(s, 1), has(s, [1])]`, "[true, false, false, false]"},
+		{`let s = set([1]); [s.has(1), s.contains(2), contains(s, 1)]`, "[true, false, true]"},
+		{`union(set([1, 2]), set([2, 3]))`, "set([1, 2, 3])"},
+		{`intersect(set([1, 2, 3]), set([3, 2]))`, "set([2, 3])"},
+		{`difference(set([1, 2, 3]), set([2]))`, "set([1, 3])"},
+		{`set([1, 2]).union(set([3])).len()`, "3"},
+		{`[set([1, 2]) == set([2, 1]), set([1]) != set([1, 2]), set([1]) == [1]]`, "[true, true, false]"},
+		{`let xs = []; for (x in set([2, 2, 1])) { xs = push(xs, x) }; xs`, "[2, 1]"},
+		{`type(set())`, `"SET"`},
+		{`set(1)`, "argument to `set` must be ARRAY, got INTEGER"},
+		{`set([1], [2])`, "wrong number of arguments. got=2, want=0 or 1"},
+		{`set([1, [2]])`, "unusable as set element: ARRAY (elements must be INTEGER, BOOLEAN or STRING)"},
+		{`has([1], 1)`, "first argument to `has` must be SET, got ARRAY"},
+		{`union(set(), [1])`, "second argument to `union` must be SET, got ARRAY"},
+		{`set()[0]`, "index operator not supported: SET"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			if errObj.Message != tt.expected {
+				t.Errorf("wrong error for %s. got=%s, want=%s", tt.input, errObj.Message, tt.expected)
+			}
+
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestSystemBuiltins(t *testing.T) {
+	t.Setenv("MONKEY_TEST_VAR", "banana")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`env("MONKEY_TEST_VAR")`, `"banana"`},
+		{`env("MONKEY_TEST_UNSET")`, "null"},
+		{`setEnv("MONKEY_TEST_VAR", "split"); env("MONKEY_TEST_VAR")`, `"split"`},
+		{`exec("echo", "a", "b")`, `{"stdout": "a b\n", "stderr": "", "code": 0}`},
+		{`exec("sh", "-c", "echo oops >&2; exit 3")`, `{"stdout": "", "stderr": "oops\n", "code": 3}`},
+		{`exec("sh", "-c", "echo $MONKEY_TEST_VAR")["stdout"]`, `"split\n"`},
+		{`env()`, "ERROR: wrong number of arguments. got=0, want=1"},
+		{`env(1)`, "ERROR: argument to `env` must be STRING, got INTEGER"},
+		{`setEnv("a")`, "ERROR: wrong number of arguments. got=1, want=2"},
+		{`exec()`, "ERROR: wrong number of arguments. got=0, want at least 1"},
+		{`exec("echo", 1)`, "ERROR: arguments to `exec` must be STRING, got INTEGER"},
+		{`exec("monkey-test-no-such-command")`, "ERROR: could not run command:"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			// Failing to start a command ends with why it failed.
+			if !strings.HasPrefix("ERROR: "+errObj.Message, tt.expected) {
+				t.Errorf("wrong error for %s. got=%s, want=%s", tt.input, errObj.Message, tt.expected)
+			}
+
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestSystemWithoutAccess(t *testing.T) {
+	object.SetSystem(false)
+	defer object.SetSystem(true)
+
+	for _, input := range []string{`env("HOME")`, `setEnv("a", "b")`, `exec("ls")`} {
+		evaluated := testEval(input)
+
+		errObj, ok := evaluated.(*object.Error)
+
+		if !ok || errObj.Message != "system access is disabled" {
+			t.Errorf("%s: expected system access to be disabled, got=%T (%+v)", input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestMethodCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"hello".len()`, "5"},
+		{`"a,b".split(",")`, `["a", "b"]`},
+		{`" Hi ".trim().upper()`, `"HI"`},
+		{`[1, 2].push(3)`, "[1, 2, 3]"},
+		{`["a", "b"].join("-")`, `"a-b"`},
+		{`[3, 1, 2].sort().map(fn(x) { x * 10 })`, "[10, 20, 30]"},
+		{`range(5).filter(fn(x) { x % 2 == 0 }).len()`, "3"},
+		{`{"a": 1, "b": 2}.keys()`, `["a", "b"]`},
+		{`{"a": 1}.delete("a").len`, "null"},
+		{`let h = {"keys": fn() { 42 }}; h.keys()`, "42"},
+		{`let up = "abc".upper; up()`, `"ABC"`},
+		{`"abc".push(1)`, "no method push on STRING"},
+		{`let n = 1; n.len()`, "no method len on INTEGER"},
+		{`[1].upper()`, "no method upper on ARRAY"},
+		{`[1].push()`, "wrong number of arguments. got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		got := evaluated.Inspect()
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = errObj.Message
+		}
+
+		if got != tt.expected {
+			t.Errorf("wrong result for %s. got=%s, want=%s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestHigherOrderBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`map(1, fn(x) { x })`, "first argument to `map` must be ARRAY, got INTEGER"},
+		{`filter([1], 1)`, "second argument to `filter` must be FUNCTION, got INTEGER"},
+		{`reduce([1], fn(acc, x) { acc })`, "wrong number of arguments. got=2, want=3"},
+		{`reduce([1], 0, 0)`, "third argument to `reduce` must be FUNCTION, got INTEGER"},
+		{`map([1], fn(x) { x + true })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`map([1], fn(a, b) { a })`, "wrong number of arguments: want=2, got=1"},
+		{`sort([1, "a"])`, "cannot compare STRING and INTEGER in `sort`"},
+		{`reverse("abc")`, "argument to `reverse` must be ARRAY, got STRING"},
+		{`range()`, "wrong number of arguments. got=0, want 1 to 3"},
+		{`range("a")`, "arguments to `range` must be INTEGER, got STRING"},
+		{`range(0, 10, 0)`, "range step cannot be zero"},
+		{`range(-9223372036854775807 - 1, 9223372036854775807)`, "range too large: -9223372036854775808 to 9223372036854775807"},
+		{`map(range(1000000000000), fn(x) { x })`, "range too large to make an array: range(0, 1000000000000)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+
+		if !ok {
+			t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+
+			continue
+		}
+
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"hello"[0]`, "h"},
+		{`"hello"[4]`, "o"},
+		{`let s = "hello"; s[1] + s[2]`, "el"},
+		{`"añb"[1]`, "ñ"},
+		{`"añb"[2]`, "b"},
+		{`"hello"[5]`, nil},
+		{`"hello"[-1]`, nil},
+		{`""[0]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		expected, ok := tt.expected.(string)
+
+		if !ok {
+			testNullObject(t, evaluated)
+			continue
+		}
+
+		str, ok := evaluated.(*object.String)
+
+		if !ok {
+			t.Errorf("%s: object is not String. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if str.Value != expected {
+			t.Errorf("%s: String has wrong value. got=%q, want=%q", tt.input, str.Value, expected)
+		}
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3, 4][1:3]", []int64{2, 3}},
+		{"[1, 2, 3, 4][:2]", []int64{1, 2}},
+		{"[1, 2, 3, 4][2:]", []int64{3, 4}},
+		{"[1, 2, 3][:]", []int64{1, 2, 3}},
+		{"[1, 2, 3][-5:99]", []int64{1, 2, 3}},
+		{"[1, 2, 3][2:1]", []int64{}},
+		{"let a = [1, 2, 3]; let b = a[:]; b[0] = 9; a[0]", 1},
+		{`"hello"[1:3]`, "el"},
+		{`"hello"[:2]`, "he"},
+		{`"hello"[3:]`, "lo"},
+		{`"hello"[10:]`, ""},
+		{`"añb😀c"[1:4]`, "ñb😀"},
+		{"1[0:1]", "slice operator not supported: INTEGER"},
+		{`[1][true:]`, "slice bounds must be INTEGER, got BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case []int64:
+			array, ok := evaluated.(*object.Array)
+
+			if !ok {
+				t.Errorf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], expectedElem)
+			}
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+
+				continue
+			}
+
+			str, ok := evaluated.(*object.String)
+
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if str.Value != expected {
+				t.Errorf("String has wrong value. got=%q, want=%q", str.Value, expected)
+			}
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`{"foo": 5}.foo`, 5},
+		{`{"foo": 5}.bar`, nil},
+		{`let p = {"pos": {"x": 1, "y": 2}}; p.pos.y`, 2},
+		{`let p = {"double": fn(x) { x * 2 }}; p.double(3)`, 6},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestIndexAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let a = [1, 2, 3]; a[0] = 5; a[0]", 5},
+		{"let a = [1, 2, 3]; a[2] = 7", 7},
+		{"let a = [1, 2, 3]; let b = a; b[1] = 9; a[1]", 9},
+		{"let m = [[1, 2], [3, 4]]; m[1][0] = 8; m[1][0]", 8},
+		{"let a = [0]; let f = fn(arr) { arr[0] = 4 }; f(a); a[0]", 4},
+		{"let a = [1, 2]; a[0] += 1; a[0]", 2},
+		{`let h = {"n": 1}; h["n"] *= 5; h["n"]`, 5},
+		{`let h = {}; h["x"] = 1; h["x"]`, 1},
+		{`let h = {"x": 1}; h["x"] = 2; len(keys(h))`, 1},
+		{`let h = {"x": 1}; h["x"] = 2; h["x"]`, 2},
+		{`let h = {}; h[1] = 1; h[true] = 2; h[1] + h[true]`, 3},
+		{`let p = {"age": 30}; p.age = 31; p["age"]`, 31},
+		{`let p = {}; p.count = 1; p.count += 2; p.count`, 3},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`keys({"b": 1, "a": 2, 3: true})`, `["b", "a", 3]`},
+		{`values({"b": 1, "a": 2, 3: true})`, `[1, 2, true]`},
+		{`entries({"b": 1, "a": 2})`, `[["b", 1], ["a", 2]]`},
+		{`keys({})`, `[]`},
+		{`keys({"a": 1, "b": 2, "a": 3})`, `["a", "b"]`},
+		{`delete({"a": 1, "b": 2, "c": 3}, "b")`, `{"a": 1, "c": 3}`},
+		{`delete({"a": 1}, "z")`, `{"a": 1}`},
+		{`let h = {"a": 1}; delete(h, "a"); h`, `{"a": 1}`},
+		{`{"b": 1, "a": 2}`, `{"b": 1, "a": 2}`},
+		{`keys(1)`, "argument to `keys` must be HASH, got INTEGER"},
+		{`delete([], 1)`, "first argument to `delete` must be HASH, got ARRAY"},
+		{`delete({}, fn(x) { x })`, "unusable as hash key: FUNCTION (keys must be INTEGER, BOOLEAN or STRING)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		var actual string
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		} else {
+			actual = evaluated.Inspect()
+		}
+
+		if actual != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
 	}
 }
 
@@ -367,6 +2931,24 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%f, want=%f", result.Value, expected)
+
+		return false
+	}
+
+	return true
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	result, ok := obj.(*object.Boolean)
 
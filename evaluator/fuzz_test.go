@@ -0,0 +1,91 @@
+package evaluator
+
+import (
+	"context"
+	"io"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fuzzLimits are small enough that any program the fuzzer makes up stops
+// quickly, one way or another.
+var fuzzLimits = Limits{MaxDepth: 100, MaxSteps: 10000, Timeout: time.Second}
+
+// unsafeBuiltins reach outside the program; FuzzEval shadows them, as
+// arbitrary programs must not read or write the fuzzer's files.
+var unsafeBuiltins = []string{"readFile", "writeFile", "import", "httpGet", "httpPost"}
+
+// fuzzSeeds start FuzzEval off with programs that use the language's
+// features together. TestFuzzSeedsParse keeps them valid, since a seed that
+// does not parse only tests the parser.
+var fuzzSeeds = []string{
+	`let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)`,
+	`let h = {"a": [1, 2.5, "s${1 + 1}"]}; h.a[2] + str(h["a"][0] << 3)`,
+	`while (true) { }`,
+	`let f = fn(n) { 1 + f(n + 1) }; f(0)`,
+	`map(range(0, 10), fn(x) { x * x }) |> filter(fn(x) { x % 2 == 0 }) |> reduce(0, fn(a, b) { a + b })`,
+	`try { 1 / 0 } catch (e) { puts(e); {"code": 1}.code }`,
+	`let c = channel(1); spawn(fn() { send(c, 9223372036854775807 + 1) }); recv(c)`,
+	`let [a, b, c] = [1, 2, 3]; let {x, y} = {"x": 1}; switch (y ?? b) { case 1, 2: a + b + c default: x }`,
+	`sort(["b", "a"]) == ["a", "b"] && 0xFF & ~0 ^ 1 > 1_000 || {"a": 1}.b ?? freeze([1])`,
+	`let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) }; unless(false, 1, 2)`,
+	`let f = fn(a, b = 2, ...rest) { a + b + len(rest) }; f(1) + f(a: 1, b: 3) + f(1, ...[2, 3, 4])`,
+	`let s = set([1, 2]); let n = 0; do { n++ } while (n < 3); for (x in union(s, set([3]))) { n += x }; n`,
+}
+
+func TestFuzzSeedsParse(t *testing.T) {
+	for _, seed := range fuzzSeeds {
+		p := parser.New(lexer.New(seed))
+		p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			t.Errorf("seed %q does not parse: %v", seed, p.Errors())
+		}
+	}
+}
+
+func FuzzEval(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+
+	object.SetOutput(io.Discard)
+	object.SetInput(strings.NewReader(""))
+	object.SetNetwork(false)
+	object.SetSystem(false)
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			return
+		}
+
+		macroEnv := object.NewEnvironment()
+		DefineMacros(program, macroEnv)
+		expanded, err := ExpandMacros(program, macroEnv)
+
+		if err != nil {
+			return
+		}
+
+		env := object.NewEnvironment()
+
+		for _, name := range unsafeBuiltins {
+			env.Set(name, NULL)
+		}
+
+		start := time.Now()
+
+		EvalContext(context.Background(), expanded, env, fuzzLimits)
+
+		if elapsed := time.Since(start); elapsed > 5*fuzzLimits.Timeout {
+			t.Fatalf("ran for %s, past its limits, on %q", elapsed, input)
+		}
+	})
+}
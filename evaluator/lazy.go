@@ -0,0 +1,95 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+// Thunk is an expression whose evaluation delay put off until its value is
+// needed. It is evaluated at most once: the value is kept, and the
+// expression and its environment let go of. A thunk is forced wherever a
+// value is used rather than passed along, as by an operator, a condition or
+// a builtin, so a program rarely has to call force itself.
+type Thunk struct {
+	node    ast.Expression
+	env     *object.Environment
+	value   object.Object
+	forcing bool
+}
+
+func (t *Thunk) Type() object.ObjectType { return object.THUNK_OBJ }
+func (t *Thunk) Inspect() string {
+	if t.node != nil {
+		return "thunk"
+	}
+
+	return "thunk(" + t.value.Inspect() + ")"
+}
+
+// delay is only a marker like breakpoint: a call to it is handled by
+// evalDelay, which has the argument's expression. Called any other way,
+// such as through map, its argument has been evaluated and forced already.
+var delay = &object.Builtin{Fn: func(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	return &Thunk{value: args[0]}
+}}
+
+// delay(expr) returns a thunk that evaluates expr, in the caller's
+// environment, the first time it is forced.
+func evalDelay(call *ast.CallExpression, env *object.Environment) object.Object {
+	if len(call.Arguments) != 1 || call.Named != nil {
+		return withPosition(newError("wrong number of arguments. got=%d, want=1", len(call.Arguments)), call.Token)
+	}
+
+	return &Thunk{node: call.Arguments[0], env: env}
+}
+
+// force returns the value of obj, forcing it first if it is a thunk. An
+// error forcing a thunk is returned but not kept, so forcing it again
+// evaluates it again.
+func force(obj object.Object) object.Object {
+	t, ok := obj.(*Thunk)
+
+	if !ok {
+		return obj
+	}
+
+	if t.node == nil {
+		return t.value
+	}
+
+	if t.forcing {
+		return newError("thunk forced while it is being forced")
+	}
+
+	t.forcing = true
+	value := force(unwrapReturnValue(Eval(t.node, t.env)))
+	t.forcing = false
+
+	if isError(value) {
+		return value
+	}
+
+	t.value, t.node, t.env = value, nil, nil
+
+	return value
+}
+
+// force(value) returns value, forced if it is a thunk. applyFunction forces
+// the arguments of every builtin, so there is nothing left to do here.
+func builtinForce(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	return args[0]
+}
+
+// evalStrict evaluates node where its value is used, forcing it if it is a
+// thunk.
+func evalStrict(node ast.Node, env *object.Environment) object.Object {
+	return force(Eval(node, env))
+}
@@ -0,0 +1,65 @@
+package evaluator
+
+import "testing"
+
+func TestLazyEvaluation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`force(delay(1 + 2))`, 3},
+		{`delay(1 + 2) * 2`, 6},
+		{`let n = 0; let t = delay(n += 1); [t + t, n]`, []interface{}{2, 1}},
+		{`let n = 0; let t = delay(n += 1); n`, 0},
+		{`let x = 1; let t = delay(x); x = 2; force(t)`, 2},
+		{`if (delay(false)) { 1 } else { 2 }`, 2},
+		{`let t = delay(3); len(range(t))`, 3},
+		{`let t = delay([1, 2, 3]); t[1]`, 2},
+		{`let f = delay(fn(x) { x * 2 }); f(21)`, 42},
+		{`let t = delay(5); t++; t`, 6},
+		{`let s = delay("!"); "hi${s}"`, "hi!"},
+		{`force(7)`, 7},
+		{`force(delay(delay(8)))`, 8},
+		{`let d = delay; force(d(1 + 1))`, 2},
+		{`let unless = fn(c, then, otherwise) { if (c) { otherwise } else { then } };
+		force(unless(false, delay(1), delay(1 / 0)))`, 1},
+		{`let from = fn(n) { [n, delay(from(n + 1))] };
+		let take = fn(s, k) { let out = []; for (let i = 0; i < k; i++) { out = push(out, s[0]); s = s[1] }; out };
+		take(from(1), 5)`, []interface{}{1, 2, 3, 4, 5}},
+		{`let n = 0; let t = delay(1 / n); let r = try { force(t) } catch (e) { e }; n = 1; [r, force(t)]`,
+			[]interface{}{"division by zero: 1 / 0", 1}},
+		{`let t = delay(t + 1); force(t)`, "thunk forced while it is being forced"},
+		{`let t = delay(t); force(t)`, "thunk forced while it is being forced"},
+		{`delay()`, "wrong number of arguments. got=0, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		testResult(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+func TestThunkInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`delay(1 + 2)`, "thunk"},
+		{`let t = delay(1 + 2); force(t); t`, "thunk(3)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		thunk, ok := evaluated.(*Thunk)
+
+		if !ok {
+			t.Fatalf("%q: object is not Thunk. got=%T (%+v)", tt.input, evaluated, evaluated)
+		}
+
+		if thunk.Inspect() != tt.expected {
+			t.Errorf("%q: wrong Inspect. expected=%q, got=%q", tt.input, tt.expected, thunk.Inspect())
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package evaluator
+
+import (
+	"context"
+	"monkey/ast"
+	"monkey/object"
+	"time"
+)
+
+// Limits bounds the work one call to EvalContext may do, so that a host can
+// run code it does not trust. A zero field means no limit.
+type Limits struct {
+	MaxDepth int           // Monkey function calls nested inside each other
+	MaxSteps int           // nodes evaluated
+	Timeout  time.Duration // wall-clock time
+}
+
+// contextCheckInterval is how many steps pass between checks of the
+// context, which cost more than counting does.
+const contextCheckInterval = 1024
+
+// budget is what is left of the limits of the EvalContext call in progress.
+// Once a limit is exceeded the budget stays spent: every later step fails
+// too, so a program cannot catch the error and carry on.
+type budget struct {
+	ctx    context.Context
+	limits Limits
+	depth  int
+	steps  int
+	spent  string
+}
+
+// limit is nil outside of EvalContext, so Eval only has to check it.
+var limit *budget
+
+// EvalContext is Eval bounded by limits and by ctx. Running out of steps or
+// time fails the node being evaluated with an error try can catch like any
+// other, and every node after it too; a call nested too deeply fails only
+// itself.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, limits Limits) object.Object {
+	if limits.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+		defer cancel()
+	}
+
+	outer := limit
+	limit = &budget{ctx: ctx, limits: limits}
+
+	defer func() { limit = outer }()
+
+	return Eval(node, env)
+}
+
+// step counts one node evaluated, returning an error if that exceeds a limit.
+func (b *budget) step() *object.Error {
+	if b.spent != "" {
+		return newError("%s", b.spent)
+	}
+
+	b.steps++
+
+	if b.limits.MaxSteps > 0 && b.steps > b.limits.MaxSteps {
+		return b.spend(newError("step limit exceeded: %d", b.limits.MaxSteps))
+	}
+
+	if b.steps%contextCheckInterval == 0 {
+		if err := b.ctx.Err(); err != nil {
+			return b.spend(newError("evaluation stopped: %s", err))
+		}
+	}
+
+	return nil
+}
+
+// enter counts a function call starting, returning an error if that exceeds
+// the call depth. A call that did not fail must be followed by leave.
+func (b *budget) enter() *object.Error {
+	if b.limits.MaxDepth > 0 && b.depth >= b.limits.MaxDepth {
+		return newError("maximum call depth exceeded: %d", b.limits.MaxDepth)
+	}
+
+	b.depth++
+
+	return nil
+}
+
+func (b *budget) leave() {
+	b.depth--
+}
+
+func (b *budget) spend(err *object.Error) *object.Error {
+	b.spent = err.Message
+
+	return err
+}
@@ -0,0 +1,99 @@
+package evaluator
+
+import (
+	"context"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+	"time"
+)
+
+func testEvalLimited(ctx context.Context, input string, limits Limits) object.Object {
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	return EvalContext(ctx, program, object.NewEnvironment(), limits)
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		limits   Limits
+		expected interface{}
+	}{
+		{`let f = fn(n) { 1 + f(n + 1) }; f(0)`, Limits{MaxDepth: 100}, "maximum call depth exceeded: 100"},
+		{`let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(10)`, Limits{MaxDepth: 11}, 10},
+		{`let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(1000)`, Limits{MaxDepth: 10}, 0},
+		{`let f = fn(n) { 1 + f(n + 1) }; let r = try { f(0) } catch (e) { e }; f = fn(n) { n }; [r, f(5)]`,
+			Limits{MaxDepth: 50}, []interface{}{"maximum call depth exceeded: 50", 5}},
+		{`let f = fn(x) { x }; map([1, 2], fn(x) { f(x) + 1 })`, Limits{MaxDepth: 1}, "maximum call depth exceeded: 1"},
+		{`while (true) { }`, Limits{MaxSteps: 1000}, "step limit exceeded: 1000"},
+		{`while (true) { try { while (true) { } } catch (e) { 1 } }`, Limits{MaxSteps: 1000}, "step limit exceeded: 1000"},
+		{`let x = 0; while (x < 10) { x++ }; x`, Limits{MaxSteps: 1000}, 10},
+		{`while (true) { }`, Limits{Timeout: 10 * time.Millisecond}, "evaluation stopped: context deadline exceeded"},
+		{`try { sleep(60000) } catch (e) { sleep(60000) }`, Limits{Timeout: 10 * time.Millisecond}, "evaluation stopped: context deadline exceeded"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalLimited(context.Background(), tt.input, tt.limits)
+
+		testResult(t, tt.input, evaluated, tt.expected)
+	}
+}
+
+// testResult checks evaluated against expected: an int, nil for null, a
+// string for either a STRING or the message of an error, or a slice of
+// these for an array.
+func testResult(t *testing.T, input string, evaluated object.Object, expected interface{}) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		testIntegerObject(t, evaluated, int64(expected))
+	case nil:
+		testNullObject(t, evaluated)
+	case string:
+		errObj, ok := evaluated.(*object.Error)
+
+		if !ok {
+			if str, ok := evaluated.(*object.String); ok && str.Value == expected {
+				return
+			}
+
+			t.Errorf("%q: expected error %q, got=%T (%+v)", input, expected, evaluated, evaluated)
+
+			return
+		}
+
+		if errObj.Message != expected {
+			t.Errorf("%q: wrong error. expected=%q, got=%q", input, expected, errObj.Message)
+		}
+	case []interface{}:
+		arr, ok := evaluated.(*object.Array)
+
+		if !ok || len(arr.Elements) != len(expected) {
+			t.Errorf("%q: expected %d elements, got=%T (%+v)", input, len(expected), evaluated, evaluated)
+
+			return
+		}
+
+		for i, want := range expected {
+			testResult(t, input, arr.Elements[i], want)
+		}
+	}
+}
+
+func TestEvalContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	evaluated := testEvalLimited(ctx, `while (true) { }`, Limits{})
+
+	testResult(t, "cancelled", evaluated, "evaluation stopped: context canceled")
+}
+
+func TestEvalAfterEvalContextIsUnlimited(t *testing.T) {
+	testEvalLimited(context.Background(), `while (true) { }`, Limits{MaxSteps: 10})
+
+	testIntegerObject(t, testEval(`let x = 0; while (x < 100) { x++ }; x`), 100)
+}
@@ -0,0 +1,161 @@
+package evaluator
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/object"
+)
+
+// DefineMacros removes top-level macro definitions from program and binds
+// them in env.
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	definitions := []int{}
+
+	for i, statement := range program.Statements {
+		if isMacroDefinition(statement) {
+			addMacro(statement, env)
+			definitions = append(definitions, i)
+		}
+	}
+
+	for i := len(definitions) - 1; i >= 0; i = i - 1 {
+		definitionIndex := definitions[i]
+		program.Statements = append(
+			program.Statements[:definitionIndex],
+			program.Statements[definitionIndex+1:]...,
+		)
+	}
+}
+
+func isMacroDefinition(node ast.Statement) bool {
+	letStatement, ok := node.(*ast.LetStatement)
+
+	if !ok {
+		return false
+	}
+
+	_, ok = letStatement.Value.(*ast.MacroLiteral)
+
+	return ok
+}
+
+func addMacro(stmt ast.Statement, env *object.Environment) {
+	letStatement, _ := stmt.(*ast.LetStatement)
+	macroLiteral, _ := letStatement.Value.(*ast.MacroLiteral)
+
+	macro := &object.Macro{
+		Parameters: macroLiteral.Parameters,
+		Env:        env,
+		Body:       macroLiteral.Body,
+	}
+
+	env.Set(letStatement.Name.Value, macro)
+}
+
+// ExpandMacros replaces every call to a macro defined in env with the AST
+// the macro returns. A macro called with the wrong number of arguments, or
+// that fails or returns something other than a quote, is an error; the
+// first one is returned, with the call left as it was.
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, error) {
+	var failure error
+
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		callExpression, ok := node.(*ast.CallExpression)
+
+		if !ok || failure != nil {
+			return node
+		}
+
+		macro, ok := isMacroCall(callExpression, env)
+
+		if !ok {
+			return node
+		}
+
+		fail := func(format string, a ...interface{}) ast.Node {
+			tok := callExpression.Token
+			failure = fmt.Errorf("macro error at line %d, col %d: %s", tok.Line, tok.Column, fmt.Sprintf(format, a...))
+
+			return node
+		}
+
+		name := callExpression.Function.String()
+
+		if len(callExpression.Arguments) != len(macro.Parameters) {
+			return fail("wrong number of arguments to `%s`. got=%d, want=%d", name, len(callExpression.Arguments), len(macro.Parameters))
+		}
+
+		args := quoteArgs(callExpression)
+		evalEnv := extendMacroEnv(macro, args)
+
+		evaluated := Eval(macro.Body, evalEnv)
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			return fail("%s", errObj.Message)
+		}
+
+		quote, ok := evaluated.(*object.Quote)
+
+		if !ok {
+			got := "nothing"
+
+			if evaluated != nil {
+				got = string(evaluated.Type())
+			}
+
+			return fail("`%s` must return a quote, got %s", name, got)
+		}
+
+		return quote.Node
+	})
+
+	return expanded, failure
+}
+
+func isMacroCall(
+	exp *ast.CallExpression,
+	env *object.Environment,
+) (*object.Macro, bool) {
+	identifier, ok := exp.Function.(*ast.Identifier)
+
+	if !ok {
+		return nil, false
+	}
+
+	obj, ok := env.Get(identifier.Value)
+
+	if !ok {
+		return nil, false
+	}
+
+	macro, ok := obj.(*object.Macro)
+
+	if !ok {
+		return nil, false
+	}
+
+	return macro, true
+}
+
+func quoteArgs(exp *ast.CallExpression) []*object.Quote {
+	args := []*object.Quote{}
+
+	for _, a := range exp.Arguments {
+		args = append(args, &object.Quote{Node: a})
+	}
+
+	return args
+}
+
+func extendMacroEnv(
+	macro *object.Macro,
+	args []*object.Quote,
+) *object.Environment {
+	extended := object.NewEnclosedEnvironment(macro.Env)
+
+	for paramIdx, param := range macro.Parameters {
+		extended.Set(param.Value, args[paramIdx])
+	}
+
+	return extended
+}
@@ -0,0 +1,157 @@
+package evaluator
+
+import (
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// loading is the chain of imports currently being evaluated; it is used to
+// resolve relative paths and to detect cycles.
+var loading = []string{}
+
+// importBuiltin is the import builtin as init registers it. evalIdentifier
+// binds it to the environment it is looked up in, with bindImport.
+var importBuiltin *object.Builtin
+
+func builtinImport(args ...object.Object) object.Object {
+	return importModule(nil, args...)
+}
+
+// bindImport returns import for a program running in env. Every module is
+// cached by its absolute path in env.Modules(), so a file is only evaluated
+// once per program, or per REPL session or Interpreter, no matter how often
+// it is imported.
+func bindImport(env *object.Environment) *object.Builtin {
+	return object.Bind(importBuiltin, func(args ...object.Object) object.Object {
+		return importModule(env, args...)
+	})
+}
+
+// import(path) evaluates a Monkey file in a fresh environment and returns a
+// hash of its top-level bindings. Names starting with an underscore are
+// private to the module. Relative paths are resolved against the directory
+// of the importing module, or the working directory outside of one. The
+// module shares env's cache and IO; without an env, nothing is cached.
+func importModule(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	pathArg, ok := args[0].(*object.String)
+
+	if !ok {
+		return newError("argument to `import` must be STRING, got %s", args[0].Type())
+	}
+
+	path, err := resolveModulePath(pathArg.Value)
+
+	if err != nil {
+		return newError("could not import %s: %s", pathArg.Value, err)
+	}
+
+	modules := map[string]*object.Hash{}
+
+	if env != nil {
+		modules = env.Modules()
+	}
+
+	if module, ok := modules[path]; ok {
+		return module
+	}
+
+	for i, loadingPath := range loading {
+		if loadingPath == path {
+			cycle := append(append([]string{}, loading[i:]...), path)
+
+			return newError("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	loading = append(loading, path)
+	defer func() { loading = loading[:len(loading)-1] }()
+
+	result := evalModule(path, env)
+
+	if module, ok := result.(*object.Hash); ok {
+		modules[path] = module
+	}
+
+	return result
+}
+
+// InFile runs f as though it were evaluating the file at path, so relative
+// imports made meanwhile resolve against that file's directory.
+func InFile(path string, f func() object.Object) object.Object {
+	abs, err := filepath.Abs(path)
+
+	if err != nil {
+		return newError("could not resolve %s: %s", path, err)
+	}
+
+	loading = append(loading, abs)
+	defer func() { loading = loading[:len(loading)-1] }()
+
+	return f()
+}
+
+func resolveModulePath(path string) (string, error) {
+	if !filepath.IsAbs(path) && len(loading) > 0 {
+		path = filepath.Join(filepath.Dir(loading[len(loading)-1]), path)
+	}
+
+	return filepath.Abs(path)
+}
+
+func evalModule(path string, importer *object.Environment) object.Object {
+	source, err := os.ReadFile(path)
+
+	if err != nil {
+		return newError("could not import %s: %s", path, err)
+	}
+
+	l := lexer.New(string(source))
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		return newError("could not import %s: %s", path, p.Errors()[0])
+	}
+
+	macroEnv := object.NewEnvironment()
+	DefineMacros(program, macroEnv)
+	if _, err := ExpandMacros(program, macroEnv); err != nil {
+		return newError("could not import %s: %s", path, err)
+	}
+
+	env := object.NewEnvironment()
+
+	if importer != nil {
+		env.SetIO(importer.IO())
+		env.SetModules(importer.Modules())
+	}
+
+	evaluated := Eval(program, env)
+
+	if isError(evaluated) {
+		return evaluated
+	}
+
+	module := object.NewHash()
+
+	for _, name := range env.Names() {
+		if strings.HasPrefix(name, "_") {
+			continue
+		}
+
+		value, _ := env.Get(name)
+		key := object.Intern(name)
+
+		module.Set(key.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+
+	return module
+}
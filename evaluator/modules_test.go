@@ -0,0 +1,135 @@
+package evaluator
+
+import (
+	"bytes"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeModule(t *testing.T, dir, name, source string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+
+	writeModule(t, dir, "math.mky", `
+let square = fn(x) { x * x };
+let _helper = 1;
+let answer = square(6) + _helper * 6;
+`)
+	writeModule(t, dir, "main.mky", `
+let math = import("math.mky");
+let total = math["answer"];
+`)
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let m = import("` + filepath.Join(dir, "math.mky") + `"); m["square"](4)`, 16},
+		{`import("` + filepath.Join(dir, "math.mky") + `")["answer"]`, 42},
+		{`import("` + filepath.Join(dir, "math.mky") + `")["_helper"]`, nil},
+		{`keys(import("` + filepath.Join(dir, "math.mky") + `"))`, `["answer", "square"]`},
+		{`import("` + filepath.Join(dir, "main.mky") + `")["total"]`, 42},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result. expected=%s, got=%s", expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestImportCaching(t *testing.T) {
+	dir := t.TempDir()
+	path := writeModule(t, dir, "counter.mky", `let value = [1];`)
+
+	input := `import("` + path + `")["value"] == import("` + path + `")["value"]`
+
+	testBooleanObject(t, testEval(input), true)
+
+	var outA, outB bytes.Buffer
+
+	loud := writeModule(t, dir, "loud.mky", `puts("loaded"); let f = fn() { import("`+path+`") };`)
+
+	envA, envB := object.NewEnvironment(), object.NewEnvironment()
+	envA.SetIO(object.NewIO(nil, &outA))
+	envB.SetIO(object.NewIO(nil, &outB))
+
+	run := func(input string, env *object.Environment) object.Object {
+		return Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+	}
+
+	run(`let m = import("`+loud+`"); import("`+loud+`"); let v = import("`+path+`")["value"]`, envA)
+	run(`import("`+loud+`")`, envB)
+
+	if outA.String() != "loaded\n" || outB.String() != "loaded\n" {
+		t.Errorf("each environment should load a module once, into its own output. got=%q and %q", outA.String(), outB.String())
+	}
+
+	testBooleanObject(t, run(`m["f"]()["value"] == v`, envA), true)
+	v, _ := envA.Get("v")
+
+	if run(`import("`+path+`")["value"]`, envB) == v {
+		t.Errorf("another environment should import a module afresh")
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	a := writeModule(t, dir, "a.mky", `import("b.mky");`)
+	writeModule(t, dir, "b.mky", `import("a.mky");`)
+	broken := writeModule(t, dir, "broken.mky", `let = 5;`)
+	failing := writeModule(t, dir, "failing.mky", `1 + true;`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import(1)`, "argument to `import` must be STRING, got INTEGER"},
+		{`import("` + filepath.Join(dir, "missing.mky") + `")`, "could not import"},
+		{`import("` + a + `")`, "import cycle: " + a + " -> " + filepath.Join(dir, "b.mky") + " -> " + a},
+		{`import("` + broken + `")`, "could not import " + broken + ": parse error"},
+		{`import("` + failing + `")`, "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+
+		if !ok {
+			t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+
+			continue
+		}
+
+		if !strings.HasPrefix(errObj.Message, tt.expected) {
+			t.Errorf("wrong error message. expected prefix %q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
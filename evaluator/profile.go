@@ -0,0 +1,80 @@
+package evaluator
+
+import (
+	"fmt"
+	"monkey/object"
+	"monkey/profile"
+)
+
+var (
+	profiler     *profile.Profile
+	builtinNames map[*object.Builtin]string
+)
+
+// SetProfiler makes applyFunction report every call it makes to p. nil
+// turns profiling off again.
+func SetProfiler(p *profile.Profile) {
+	profiler = p
+
+	if builtinNames == nil {
+		builtinNames = make(map[*object.Builtin]string, len(builtins))
+
+		for name, builtin := range builtins {
+			builtinNames[builtin] = name
+		}
+	}
+}
+
+// profileName is what fn is called in a profile: its name, or where it was
+// defined if it has none. Builtins made by other builtins, such as those
+// compose returns, have no name of their own.
+func profileName(fn object.Object) string {
+	switch fn := fn.(type) {
+	case *object.Function:
+		if fn.Name != "" {
+			return fn.Name
+		}
+
+		return fmt.Sprintf("<anonymous> at line %d", fn.Body.Token.Line)
+	case *object.Builtin:
+		if name, ok := builtinNames[fn.Base()]; ok {
+			return name
+		}
+
+		return "<builtin>"
+	default:
+		return fn.Inspect()
+	}
+}
+
+// tailCallProfile profiles the functions applyUserFunction's loop calls
+// from tail position, each as if called by the one before it. A function
+// called again in the same loop is only counted, so that profiling a
+// tail-recursive function does not use more memory the longer it runs.
+type tailCallProfile struct {
+	entered map[string]bool
+	exits   []func()
+}
+
+func (t *tailCallProfile) enter(fn *object.Function) {
+	name := profileName(fn)
+
+	if t.entered[name] {
+		profiler.Count(name)
+
+		return
+	}
+
+	if t.entered == nil {
+		t.entered = make(map[string]bool)
+	}
+
+	t.entered[name] = true
+	t.exits = append(t.exits, profiler.Enter(name))
+}
+
+func (t *tailCallProfile) leave() {
+	for i := len(t.exits) - 1; i >= 0; i-- {
+		t.exits[i]()
+	}
+}
@@ -0,0 +1,49 @@
+package evaluator
+
+import (
+	"monkey/profile"
+	"testing"
+)
+
+func TestProfiler(t *testing.T) {
+	prof := profile.New()
+
+	SetProfiler(prof)
+	defer SetProfiler(nil)
+
+	testEval(`
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let count = fn(n) { if (n > 0) { count(n - 1) } else { n } };
+fib(5);
+count(1000);
+map([1, 2, 3], fn(x) { len(str(x)) });
+compose(len)("ab");
+`)
+
+	expected := map[string]int{
+		"fib":                   15,
+		"count":                 1001,
+		"map":                   1,
+		"<anonymous> at line 6": 3,
+		"len":                   4,
+		"str":                   3,
+		"compose":               1,
+		"<builtin>":             1,
+	}
+
+	calls := map[string]int{}
+
+	for _, entry := range prof.Entries() {
+		calls[entry.Name] = entry.Calls
+	}
+
+	for name, want := range expected {
+		if calls[name] != want {
+			t.Errorf("wrong number of calls to %s. expected=%d, got=%d", name, want, calls[name])
+		}
+	}
+
+	if len(calls) != len(expected) {
+		t.Errorf("wrong functions profiled. got=%v", calls)
+	}
+}
@@ -0,0 +1,302 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+// The resolver runs over a program before it is evaluated and records on
+// each identifier the environment and slot its binding will live in, so
+// evaluation can fetch it by index rather than by name. Its scopes mirror
+// the environments the evaluator creates: one per function call, per loop
+// iteration, per for loop and per catch block, with the environment the
+// program runs in outermost.
+//
+// A location is only a hint. The environment checks that the slot really
+// holds the name, and anything it cannot find that way is looked up by name
+// as before, so a let that has not run yet or a binding made outside the
+// resolver's view behaves exactly as it always did.
+
+// dynamic marks an identifier the resolver found bound in two different
+// places, which happens when macro expansion puts the same node in more
+// than one spot. It is always looked up by name.
+var dynamic = &ast.Location{Depth: -1}
+
+type resolver struct {
+	env   *object.Environment // the environment the program runs in
+	scope *scope              // the innermost scope inside env, nil at top level
+}
+
+// scope hands out the slots of one environment that is created while the
+// program runs.
+type scope struct {
+	slots map[string]int
+	outer *scope
+}
+
+func resolveProgram(program *ast.Program, env *object.Environment) {
+	r := &resolver{env: env}
+
+	r.statements(program.Statements)
+}
+
+func (r *resolver) enter() {
+	r.scope = &scope{slots: make(map[string]int), outer: r.scope}
+}
+
+func (r *resolver) leave() {
+	r.scope = r.scope.outer
+}
+
+// declare gives ident a slot in the innermost scope.
+func (r *resolver) declare(ident *ast.Identifier) {
+	if r.scope == nil {
+		locate(ident, 0, r.env.Declare(ident.Value))
+
+		return
+	}
+
+	slot, ok := r.scope.slots[ident.Value]
+
+	if !ok {
+		slot = len(r.scope.slots)
+		r.scope.slots[ident.Value] = slot
+	}
+
+	locate(ident, 0, slot)
+}
+
+// resolve finds the nearest binding of ident, first in the resolver's own
+// scopes and then in the environments the program runs in.
+func (r *resolver) resolve(ident *ast.Identifier) {
+	depth := 0
+
+	for s := r.scope; s != nil; s = s.outer {
+		if slot, ok := s.slots[ident.Value]; ok {
+			locate(ident, depth, slot)
+
+			return
+		}
+
+		depth++
+	}
+
+	for env := r.env; env != nil; env = env.Outer() {
+		if slot, ok := env.Slot(ident.Value); ok {
+			locate(ident, depth, slot)
+
+			return
+		}
+
+		depth++
+	}
+
+	// Builtins and names bound later, such as by a later REPL input.
+	if ident.Location != nil {
+		ident.Location = dynamic
+	}
+}
+
+func locate(ident *ast.Identifier, depth, slot int) {
+	loc := ident.Location
+
+	if loc == nil {
+		ident.Location = &ast.Location{Depth: depth, Slot: slot}
+	} else if loc.Depth != depth || loc.Slot != slot {
+		ident.Location = dynamic
+	}
+}
+
+func (r *resolver) statements(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		r.statement(stmt)
+	}
+}
+
+func (r *resolver) block(block *ast.BlockStatement) {
+	if block != nil {
+		r.statements(block.Statements)
+	}
+}
+
+func (r *resolver) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		// Declared first so a function can refer to itself by name.
+		r.declare(stmt.Name)
+		r.expression(stmt.Value)
+	case *ast.DestructuringStatement:
+		r.expression(stmt.Value)
+
+		for _, name := range stmt.Names {
+			r.declare(name)
+		}
+	case *ast.ReturnStatement:
+		r.expression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		r.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		r.block(stmt)
+	case *ast.WhileStatement:
+		r.expression(stmt.Condition)
+
+		r.enter()
+		r.block(stmt.Body)
+		r.leave()
+	case *ast.ForStatement:
+		r.enter()
+
+		if stmt.Init != nil {
+			r.statement(stmt.Init)
+		}
+
+		r.expression(stmt.Condition)
+		r.expression(stmt.Post)
+
+		r.enter()
+		r.block(stmt.Body)
+		r.leave()
+
+		r.leave()
+	case *ast.ForInStatement:
+		r.expression(stmt.Iterable)
+
+		r.enter()
+		r.declare(stmt.Name)
+
+		r.enter()
+		r.block(stmt.Body)
+		r.leave()
+
+		r.leave()
+	}
+}
+
+func (r *resolver) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		r.resolve(exp)
+	case *ast.PrefixExpression:
+		r.expression(exp.Right)
+	case *ast.SpreadExpression:
+		r.expression(exp.Value)
+	case *ast.InfixExpression:
+		r.expression(exp.Left)
+		r.expression(exp.Right)
+	case *ast.AssignExpression:
+		r.expression(exp.Value)
+		r.resolve(exp.Name)
+	case *ast.IndexAssignment:
+		r.expression(exp.Left)
+		r.expression(exp.Index)
+		r.expression(exp.Value)
+	case *ast.PostfixExpression:
+		r.resolve(exp.Name)
+	case *ast.IfExpression:
+		r.expression(exp.Condition)
+		r.block(exp.Consequence)
+		r.block(exp.Alternative)
+	case *ast.ConditionalExpression:
+		r.expression(exp.Condition)
+		r.expression(exp.Consequence)
+		r.expression(exp.Alternative)
+	case *ast.SwitchExpression:
+		r.expression(exp.Subject)
+
+		for _, c := range exp.Cases {
+			r.expressions(c.Values)
+			r.block(c.Body)
+		}
+
+		r.block(exp.Default)
+	case *ast.TryExpression:
+		r.block(exp.Block)
+
+		r.enter()
+		r.declare(exp.Parameter)
+		r.block(exp.Catch)
+		r.leave()
+	case *ast.FunctionLiteral:
+		// Defaults are evaluated where the function was defined.
+		r.expressions(exp.Defaults)
+
+		r.enter()
+
+		for _, param := range exp.Parameters {
+			r.declare(param)
+		}
+
+		r.block(exp.Body)
+		r.leave()
+	case *ast.CallExpression:
+		// A quoted expression is data; only its unquotes run, and they are
+		// looked up by name.
+		if exp.Function.TokenLiteral() == "quote" {
+			return
+		}
+
+		r.expression(exp.Function)
+		r.expressions(exp.Arguments)
+
+		for _, arg := range exp.Named {
+			r.expression(arg.Value)
+		}
+	case *ast.StringInterpolation:
+		r.expressions(exp.Values)
+	case *ast.ArrayLiteral:
+		r.expressions(exp.Elements)
+	case *ast.IndexExpression:
+		r.expression(exp.Left)
+		r.expression(exp.Index)
+	case *ast.SliceExpression:
+		r.expression(exp.Left)
+		r.expression(exp.Low)
+		r.expression(exp.High)
+	case *ast.HashLiteral:
+		for _, pair := range exp.Pairs {
+			r.expression(pair.Key)
+			r.expression(pair.Value)
+		}
+	}
+}
+
+func (r *resolver) expressions(exps []ast.Expression) {
+	for _, exp := range exps {
+		r.expression(exp)
+	}
+}
+
+// lookup, assign and define are the evaluator's ways of using a binding:
+// through the resolved slot when there is one, and by name otherwise.
+
+func lookup(ident *ast.Identifier, env *object.Environment) (object.Object, bool) {
+	if loc := ident.Location; loc != nil && loc != dynamic {
+		if val, ok := env.GetAt(loc.Depth, loc.Slot, ident.Value); ok {
+			return val, true
+		}
+	}
+
+	return env.Get(ident.Value)
+}
+
+func assign(ident *ast.Identifier, env *object.Environment, val object.Object) bool {
+	if loc := ident.Location; loc != nil && loc != dynamic {
+		if env.SetAt(loc.Depth, loc.Slot, ident.Value, val) {
+			return true
+		}
+	}
+
+	_, ok := env.Assign(ident.Value, val)
+
+	return ok
+}
+
+func define(ident *ast.Identifier, env *object.Environment, val object.Object) {
+	if loc := ident.Location; loc != nil && loc != dynamic && loc.Depth == 0 {
+		env.Define(loc.Slot, ident.Value, val)
+
+		return
+	}
+
+	env.Set(ident.Value, val)
+}
@@ -0,0 +1,143 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+)
+
+func TestResolvedScoping(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`let x = 1; let f = fn(x) { x }; f(2) + x;`, 3},
+		{`let x = 1; let f = fn() { if (false) { let x = 2; }; x }; f();`, 1},
+		{`let x = 1; let f = fn() { let x = x + 1; x }; f() * 10 + x;`, 21},
+		{`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+		let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+		if (even(10)) { 1 } else { 0 };`, 1},
+		{`let a = fn(x) { fn(y) { fn(z) { x + y + z } } }; a(1)(2)(3);`, 6},
+		{`let sum = 0; for (let i = 0; i < 5; i++) { let sq = i * i; sum += sq; }; sum;`, 30},
+		{`let n = 0; while (n < 3) { let m = n; n = m + 1; }; n;`, 3},
+		{`let r = try { [1][5] + 1 } catch (e) { let e = 7; e }; r;`, 7},
+		{`let f = fn(a, b, c, d, e, g, h, i, j, k) { a + b + c + d + e + g + h + i + j + k };
+		f(1, 2, 3, 4, 5, 6, 7, 8, 9, 10);`, 55},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestResolverLocations(t *testing.T) {
+	input := `let x = 1; let f = fn(a) { let b = a; fn() { x + a + b + len } };`
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	env := object.NewEnvironment()
+	Eval(program, env)
+
+	inner := program.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionLiteral).Body.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	body := inner.Body.Statements[0].(*ast.ExpressionStatement).Expression
+
+	var idents []*ast.Identifier
+
+	for exp := body; ; {
+		infix, ok := exp.(*ast.InfixExpression)
+
+		if !ok {
+			break
+		}
+
+		idents = append([]*ast.Identifier{infix.Right.(*ast.Identifier)}, idents...)
+		exp = infix.Left
+
+		if ident, ok := exp.(*ast.Identifier); ok {
+			idents = append([]*ast.Identifier{ident}, idents...)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		expected *ast.Location
+	}{
+		{"x", &ast.Location{Depth: 2, Slot: 0}},
+		{"a", &ast.Location{Depth: 1, Slot: 0}},
+		{"b", &ast.Location{Depth: 1, Slot: 1}},
+		{"len", nil},
+	}
+
+	if len(idents) != len(tests) {
+		t.Fatalf("wrong number of identifiers. got=%d", len(idents))
+	}
+
+	for i, tt := range tests {
+		ident := idents[i]
+
+		if ident.Value != tt.name {
+			t.Fatalf("identifier %d is %s, want %s", i, ident.Value, tt.name)
+		}
+
+		if tt.expected == nil {
+			if ident.Location != nil {
+				t.Errorf("%s should not be resolved. got=%+v", tt.name, *ident.Location)
+			}
+
+			continue
+		}
+
+		if ident.Location == nil || *ident.Location != *tt.expected {
+			t.Errorf("wrong location for %s. want=%+v, got=%+v", tt.name, *tt.expected, ident.Location)
+		}
+	}
+}
+
+func TestResolverAcrossPrograms(t *testing.T) {
+	env := object.NewEnvironment()
+
+	inputs := []string{
+		`let f = fn() { later };`,
+		`let later = 5;`,
+		`let counter = 0; let bump = fn() { counter++ };`,
+		`bump(); bump(); let later = later + counter;`,
+	}
+
+	for _, input := range inputs {
+		Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+	}
+
+	testIntegerObject(t, Eval(parser.New(lexer.New(`f();`)).ParseProgram(), env), 7)
+}
+
+func TestResolverSharedNodes(t *testing.T) {
+	input := `
+	let twice = macro(exp) { quote(fn(v) { let h = fn(w) { unquote(exp) }; [unquote(exp), h(0)] }) };
+	let v = 1;
+	let w = 2;
+	let g = twice(v + w);
+	g(10);
+	`
+
+	env := object.NewEnvironment()
+	program := testParseProgram(input)
+
+	DefineMacros(program, env)
+	expanded, err := ExpandMacros(program, env)
+
+	if err != nil {
+		t.Fatalf("ExpandMacros failed: %s", err)
+	}
+
+	evaluated := Eval(expanded, env)
+
+	array, ok := evaluated.(*object.Array)
+
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	testIntegerObject(t, array.Elements[0], 12)
+	testIntegerObject(t, array.Elements[1], 10)
+}
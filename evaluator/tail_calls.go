@@ -0,0 +1,206 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+)
+
+// tailCall is returned instead of calling a Monkey function from tail
+// position. applyUserFunction runs it in a loop rather than recursing, so
+// tail-recursive functions do not grow the Go stack. It never escapes
+// applyUserFunction.
+type tailCall struct {
+	fn   *object.Function
+	args []object.Object
+	tok  token.Token // the call's "(" token
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
+func isTailCall(obj object.Object) bool {
+	_, ok := obj.(*tailCall)
+
+	return ok
+}
+
+func applyUserFunction(fn *object.Function, args []object.Object) object.Object {
+	if limit != nil {
+		if err := limit.enter(); err != nil {
+			return err
+		}
+
+		defer limit.leave()
+	}
+
+	var call *tailCall
+	var tailCalls tailCallProfile
+
+	defer tailCalls.leave()
+
+	for {
+		result := callUserFunction(fn, args)
+
+		next, ok := result.(*tailCall)
+
+		if !ok {
+			if call != nil {
+				result = withPosition(result, call.tok)
+				result = withStackFrame(result, call.fn, call.tok)
+			}
+
+			return result
+		}
+
+		call = next
+		fn, args = call.fn, call.args
+
+		if profiler != nil {
+			tailCalls.enter(fn)
+		}
+	}
+}
+
+func callUserFunction(fn *object.Function, args []object.Object) object.Object {
+	if min, max := arity(fn); len(args) < min || (max >= 0 && len(args) > max) {
+		return newError("%s", object.WrongArguments(min, max, len(args)))
+	}
+
+	extendedEnv, err := extendFunctionEnv(fn, args)
+
+	if err != nil {
+		return err
+	}
+
+	evaluated := evalTailBlock(fn.Body, extendedEnv, true)
+
+	if evaluated == BREAK || evaluated == CONTINUE {
+		return newError("%s outside of a loop", evaluated.Inspect())
+	}
+
+	return blockValue(unwrapReturnValue(evaluated))
+}
+
+// evalTailBlock evaluates a block that is part of a function body. Return
+// statements are always in tail position; the last statement is only when
+// tail is set.
+func evalTailBlock(block *ast.BlockStatement, env *object.Environment, tail bool) object.Object {
+	var result object.Object
+
+	for i, statement := range block.Statements {
+		last := tail && i == len(block.Statements)-1
+
+		traceStatement(statement, env)
+
+		switch statement := statement.(type) {
+		case *ast.ReturnStatement:
+			val := evalTail(statement.ReturnValue, env, true)
+
+			if isError(val) || isTailCall(val) {
+				return val
+			}
+
+			return &object.ReturnValue{Value: val}
+
+		case *ast.ExpressionStatement:
+			result = evalTail(statement.Expression, env, last)
+
+		default:
+			result = Eval(statement, env)
+		}
+
+		if result != nil && (isUnwinding(result) || isTailCall(result)) {
+			return result
+		}
+	}
+
+	return result
+}
+
+func evalTail(node ast.Expression, env *object.Environment, tail bool) object.Object {
+	switch node := node.(type) {
+	case *ast.IfExpression:
+		condition := evalStrict(node.Condition, env)
+
+		if isError(condition) {
+			return condition
+		}
+
+		if isTruthy(condition) {
+			return blockValue(evalTailBlock(node.Consequence, env, tail))
+		} else if node.Alternative != nil {
+			return blockValue(evalTailBlock(node.Alternative, env, tail))
+		} else {
+			return NULL
+		}
+
+	case *ast.ConditionalExpression:
+		condition := evalStrict(node.Condition, env)
+
+		if isError(condition) {
+			return condition
+		}
+
+		if isTruthy(condition) {
+			return evalTail(node.Consequence, env, tail)
+		}
+
+		return evalTail(node.Alternative, env, tail)
+
+	case *ast.SwitchExpression:
+		branch, err := selectSwitchBranch(node, env)
+
+		if err != nil {
+			return err
+		}
+
+		if branch == nil || len(branch.Statements) == 0 {
+			return NULL
+		}
+
+		return evalTailBlock(branch, env, tail)
+
+	case *ast.CallExpression:
+		if !tail || node.Function.TokenLiteral() == "quote" {
+			return Eval(node, env)
+		}
+
+		function := evalStrict(node.Function, env)
+
+		if isError(function) {
+			return function
+		}
+
+		if function == breakpoint {
+			return evalBreakpoint(node, env)
+		}
+
+		if function == delay {
+			return evalDelay(node, env)
+		}
+
+		args := evalArguments(node.Arguments, env)
+
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+
+		if node.Named != nil {
+			var err object.Object
+
+			if args, err = bindNamedArguments(function, args, node.Named, env); err != nil {
+				return withPosition(err, node.Token)
+			}
+		}
+
+		if fn, ok := function.(*object.Function); ok {
+			return &tailCall{fn: fn, args: args, tok: node.Token}
+		}
+
+		return withPosition(applyFunction(function, args), node.Token)
+
+	default:
+		return Eval(node, env)
+	}
+}
@@ -0,0 +1,74 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/object"
+	"strings"
+)
+
+const traceIndent = "\t"
+
+// traceWidth is how much of a node's source a trace line shows.
+const traceWidth = 60
+
+var (
+	tracer     io.Writer
+	traceDepth int
+)
+
+// SetTrace makes Eval write every node it evaluates to w, indented by how
+// deeply it is nested, followed by the value the node produced. nil turns
+// tracing off again; Eval then only checks that tracer is unset.
+func SetTrace(w io.Writer) {
+	tracer = w
+	traceDepth = 0
+}
+
+func evalTraced(node ast.Node, env *object.Environment) object.Object {
+	name := strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+	source := traceSource(node)
+
+	tracePrint("BEGIN " + name + " " + source)
+	traceDepth++
+
+	result := eval(node, env)
+
+	traceDepth--
+
+	if result == nil {
+		tracePrint("END " + name)
+	} else {
+		tracePrint("END " + name + " = " + traceSource(result))
+	}
+
+	return result
+}
+
+func tracePrint(line string) {
+	fmt.Fprintf(tracer, "%s%s\n", strings.Repeat(traceIndent, traceDepth), line)
+}
+
+// traceSource renders a node or value on a single line, shortened to
+// traceWidth.
+func traceSource(v interface{}) string {
+	var s string
+
+	switch v := v.(type) {
+	case ast.Node:
+		s = v.String()
+	case *object.Error:
+		s = "ERROR: " + v.Message
+	case object.Object:
+		s = v.Inspect()
+	}
+
+	runes := []rune(strings.Join(strings.Fields(s), " "))
+
+	if len(runes) > traceWidth {
+		return string(runes[:traceWidth-3]) + "..."
+	}
+
+	return string(runes)
+}
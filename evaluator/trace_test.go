@@ -0,0 +1,53 @@
+package evaluator
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	var out bytes.Buffer
+
+	SetTrace(&out)
+	defer SetTrace(nil)
+
+	testEval(`let x = 2; -x`)
+
+	expected := `BEGIN Program let x = 2;(-x)
+	BEGIN LetStatement let x = 2;
+		BEGIN IntegerLiteral 2
+		END IntegerLiteral = 2
+	END LetStatement
+	BEGIN ExpressionStatement (-x)
+		BEGIN PrefixExpression (-x)
+			BEGIN Identifier x
+			END Identifier = 2
+		END PrefixExpression = -2
+	END ExpressionStatement = -2
+END Program = -2
+`
+
+	if out.String() != expected {
+		t.Errorf("wrong trace.\nwant:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestTraceShortensAndReportsErrors(t *testing.T) {
+	var out bytes.Buffer
+
+	SetTrace(&out)
+	defer SetTrace(nil)
+
+	testEval(`"` + string(bytes.Repeat([]byte("a"), 100)) + `" + 1`)
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	last := string(lines[len(lines)-1])
+
+	if last != "END Program = ERROR: type mismatch: STRING + INTEGER" {
+		t.Errorf("wrong last line. got=%q", last)
+	}
+
+	if len(lines[0]) > len("BEGIN Program ")+traceWidth {
+		t.Errorf("first line not shortened. got=%q", lines[0])
+	}
+}
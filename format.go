@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"monkey/lexer"
+	"monkey/printer"
+	"monkey/token"
+	"os"
+)
+
+// runFmt implements `monkey fmt [-w] file...`. Formatted source goes to out
+// unless -w is given, in which case each file is rewritten in place. The
+// printer drops comments, so -w refuses to rewrite a file that has any. The
+// result is meant to be used as the process exit code.
+func runFmt(args []string, out io.Writer, errOut io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	write := flags.Bool("w", false, "write the result back to the source file")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprintln(errOut, "usage: monkey fmt [-w] file...")
+
+		return 2
+	}
+
+	status := 0
+
+	for _, path := range flags.Args() {
+		source, err := os.ReadFile(path)
+
+		if err != nil {
+			fmt.Fprintf(errOut, "could not read %s: %s\n", path, err)
+			status = 1
+
+			continue
+		}
+
+		formatted, errors := printer.Format(string(source))
+
+		if len(errors) != 0 {
+			for _, msg := range errors {
+				fmt.Fprintf(errOut, "%s: %s\n", path, msg)
+			}
+
+			status = 1
+
+			continue
+		}
+
+		if !*write {
+			io.WriteString(out, formatted)
+
+			continue
+		}
+
+		if hasComments(string(source)) {
+			fmt.Fprintf(errOut, "%s: not rewritten, since formatting would drop its comments\n", path)
+			status = 1
+
+			continue
+		}
+
+		if err := os.WriteFile(path, []byte(formatted), 0o644); err != nil {
+			fmt.Fprintf(errOut, "could not write %s: %s\n", path, err)
+			status = 1
+		}
+	}
+
+	return status
+}
+
+func hasComments(source string) bool {
+	for _, tok := range lexer.TokenizeAll(source) {
+		if tok.Type == token.COMMENT {
+			return true
+		}
+	}
+
+	return false
+}
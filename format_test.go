@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunFmtWrite(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected int
+		result   string
+		errOut   string
+	}{
+		{"let x=1;puts( x )", 0, "let x = 1;\nputs(x);\n", ""},
+		{"// one\nlet x=1;\nputs( x ) /* two */", 1, "// one\nlet x=1;\nputs( x ) /* two */", ": not rewritten, since formatting would drop its comments\n"},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "script.mky")
+
+		if err := os.WriteFile(path, []byte(tt.source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		var out, errOut bytes.Buffer
+
+		if code := runFmt([]string{"-w", path}, &out, &errOut); code != tt.expected {
+			t.Errorf("%q: wrong exit code. expected=%d, got=%d", tt.source, tt.expected, code)
+		}
+
+		rewritten, err := os.ReadFile(path)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(rewritten) != tt.result {
+			t.Errorf("%q: wrong file contents. expected=%q, got=%q", tt.source, tt.result, rewritten)
+		}
+
+		if tt.errOut != "" {
+			tt.errOut = path + tt.errOut
+		}
+
+		if errOut.String() != tt.errOut {
+			t.Errorf("%q: wrong error output. expected=%q, got=%q", tt.source, tt.errOut, errOut.String())
+		}
+	}
+}
@@ -2,4 +2,9 @@ module monkey
 
 go 1.24
 
-require golang.org/x/text v0.24.0 // indirect
+require (
+	golang.org/x/term v0.31.0
+	golang.org/x/text v0.24.0
+)
+
+require golang.org/x/sys v0.32.0 // indirect
@@ -0,0 +1,213 @@
+// Package interp embeds Monkey in Go programs. An Interpreter keeps its
+// bindings between calls to Eval, so a host can run a script, read back the
+// values it defined and hand it Go functions to call:
+//
+//	in := interp.New()
+//	in.RegisterBuiltin("double", func(args ...object.Object) object.Object {
+//		n := args[0].(*object.Integer)
+//		return &object.Integer{Value: n.Value * 2}
+//	})
+//	result, err := in.Eval("let x = double(21); x")
+//
+// Programs run on the tree-walking evaluator with macros expanded, the same
+// way the REPL runs them. SetLimits bounds how long and how deeply they may
+// run, for hosts that run code they do not trust, and SetOutput and SetInput
+// give them a writer and reader of their own in place of stdout and stdin.
+//
+// An Interpreter is safe for use by several goroutines at once. The
+// evaluator keeps process-wide state, such as the limits in force, so
+// programs run one at a time, even in different Interpreters: a call to
+// Eval waits for any other to finish, and Get and Set wait for it too, so
+// they never see a binding half made. A builtin runs while its program
+// holds that turn, so it must not call an Interpreter's methods itself.
+//
+// Goroutines that share an Interpreter share its globals, and one's lets
+// are seen by the others. Clone gives one a copy of its own instead.
+package interp
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+	"sync"
+)
+
+// running is held while a program runs or an Interpreter's bindings are read
+// or changed. It is shared by every Interpreter because the evaluator's own
+// state is.
+var running sync.Mutex
+
+// Interpreter holds the global environment and the macros of every program
+// it has evaluated.
+//
+// Interpreters are isolated but not concurrent: all of them take turns
+// with the one lock the evaluator's process-wide state needs, so several
+// goroutines with an Interpreter each still run one program at a time.
+type Interpreter struct {
+	env    *object.Environment
+	macros *object.Environment
+	limits evaluator.Limits
+	input  io.Reader // nil to read the process's input
+	output io.Writer // nil to write to the process's output
+}
+
+func New() *Interpreter {
+	return &Interpreter{
+		env:    object.NewEnvironment(),
+		macros: object.NewEnvironment(),
+	}
+}
+
+// ParseError lists every error the parser found in a program. None of the
+// program runs when there is one.
+type ParseError struct {
+	Messages []string
+}
+
+func (e *ParseError) Error() string {
+	return strings.Join(e.Messages, "\n")
+}
+
+// RuntimeError is a Monkey error that reached the top of a program without
+// being caught.
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Err.Inspect()
+}
+
+// Eval parses src, expands its macros and evaluates it. The result is the
+// value of the last statement, or nil if the program has none. Parser errors
+// come back as *ParseError and uncaught Monkey errors as *RuntimeError.
+func (in *Interpreter) Eval(src string) (object.Object, error) {
+	return in.EvalContext(context.Background(), src)
+}
+
+// EvalContext is Eval stopped early, with a *RuntimeError, if ctx is done
+// before the program finishes.
+func (in *Interpreter) EvalContext(ctx context.Context, src string) (object.Object, error) {
+	running.Lock()
+	defer running.Unlock()
+
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Messages: p.Errors()}
+	}
+
+	evaluator.DefineMacros(program, in.macros)
+	expanded, err := evaluator.ExpandMacros(program, in.macros)
+
+	if err != nil {
+		return nil, &ParseError{Messages: []string{err.Error()}}
+	}
+
+	result := evaluator.EvalContext(ctx, expanded, in.env, in.limits)
+
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, &RuntimeError{Err: errObj}
+	}
+
+	return result, nil
+}
+
+// SetLimits bounds every later evaluation by limits. A program that exceeds
+// one fails with a *RuntimeError, unless it catches the error itself.
+func (in *Interpreter) SetLimits(limits evaluator.Limits) {
+	running.Lock()
+	defer running.Unlock()
+
+	in.limits = limits
+}
+
+// SetOutput makes puts, print and printf in in's programs write to w,
+// instead of to stdout or wherever object.SetOutput points them. nil goes
+// back to that. It changes only in: another Interpreter, even a clone,
+// keeps its own output. A spawned task that is still running once Eval
+// returns keeps writing to in's output.
+func (in *Interpreter) SetOutput(w io.Writer) {
+	running.Lock()
+	defer running.Unlock()
+
+	in.output = w
+	in.setIO()
+}
+
+// SetInput makes readLine in in's programs read from r. What one program
+// leaves unread is there for the next. nil goes back to stdin.
+func (in *Interpreter) SetInput(r io.Reader) {
+	running.Lock()
+	defer running.Unlock()
+
+	if r == nil {
+		in.input = nil
+	} else {
+		in.input = bufio.NewReader(r)
+	}
+
+	in.setIO()
+}
+
+// setIO hands in's input and output to its environments. The input keeps
+// its buffer, so changing the output does not lose what was left unread.
+func (in *Interpreter) setIO() {
+	io := object.NewIO(in.input, in.output)
+
+	in.env.SetIO(io)
+	in.macros.SetIO(io)
+}
+
+// RegisterBuiltin binds fn to name so later programs can call it like any
+// other function. It shadows a builtin of the same name. fn reports bad
+// arguments by returning an *object.Error.
+func (in *Interpreter) RegisterBuiltin(name string, fn object.BuiltinFunction) {
+	in.Set(name, &object.Builtin{Fn: fn})
+}
+
+// Get returns the global value bound to name.
+func (in *Interpreter) Get(name string) (object.Object, bool) {
+	running.Lock()
+	defer running.Unlock()
+
+	return in.env.Get(name)
+}
+
+// Set binds name to val, as a let at the top of a program would.
+func (in *Interpreter) Set(name string, val object.Object) {
+	running.Lock()
+	defer running.Unlock()
+
+	in.env.Set(name, val)
+}
+
+// Clone returns an Interpreter with a copy of in's globals, macros and
+// limits, and the same input and output, such as one per goroutine of a
+// host that sets up an environment once and then runs a program in it for
+// each request. What a program binds in the clone is not seen by in, nor
+// the other way around.
+//
+// Two things are shared all the same. Values are not copied, and a
+// function defined before the clone was made closes over in's globals, so
+// calling it from the clone reads and assigns those, not the clone's. And
+// the clone takes turns with in and every other Interpreter, as explained
+// on Interpreter, so it gives a goroutine isolation but not parallelism.
+func (in *Interpreter) Clone() *Interpreter {
+	running.Lock()
+	defer running.Unlock()
+
+	return &Interpreter{
+		env:    in.env.Clone(),
+		macros: in.macros.Clone(),
+		limits: in.limits,
+		input:  in.input,
+		output: in.output,
+	}
+}
@@ -0,0 +1,422 @@
+package interp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"monkey/evaluator"
+	"monkey/object"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEval(t *testing.T) {
+	in := New()
+
+	result, err := in.Eval("let add = fn(a, b) { a + b }; add(2, 3)")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	testInteger(t, result, 5)
+
+	result, err = in.Eval("add(10, 20)")
+
+	if err != nil {
+		t.Fatalf("bindings were not kept between calls: %s", err)
+	}
+
+	testInteger(t, result, 30)
+}
+
+func TestEvalMacros(t *testing.T) {
+	in := New()
+
+	_, err := in.Eval("let unless = macro(cond, alt) { quote(if (!(unquote(cond))) { unquote(alt) }) };")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := in.Eval("unless(false, 7)")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	testInteger(t, result, 7)
+}
+
+func TestEvalErrors(t *testing.T) {
+	in := New()
+
+	_, err := in.Eval("let = 5;")
+
+	if _, ok := err.(*ParseError); !ok {
+		t.Errorf("expected *ParseError, got=%T (%v)", err, err)
+	}
+
+	_, err = in.Eval("1 + true")
+
+	runtimeErr, ok := err.(*RuntimeError)
+
+	if !ok {
+		t.Fatalf("expected *RuntimeError, got=%T (%v)", err, err)
+	}
+
+	if runtimeErr.Err.Message != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong message. got=%q", runtimeErr.Err.Message)
+	}
+
+	_, err = in.Eval(`try { throw("caught") } catch (e) { e }`)
+
+	if err != nil {
+		t.Errorf("a caught error was reported: %s", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	in := New()
+	in.SetLimits(evaluator.Limits{MaxSteps: 10000, MaxDepth: 100})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while (true) { }", "step limit exceeded: 10000"},
+		{"let f = fn(n) { 1 + f(n) }; f(0)", "maximum call depth exceeded: 100"},
+	}
+
+	for _, tt := range tests {
+		_, err := in.Eval(tt.input)
+
+		runtimeErr, ok := err.(*RuntimeError)
+
+		if !ok {
+			t.Fatalf("%q: expected *RuntimeError, got=%T (%v)", tt.input, err, err)
+		}
+
+		if runtimeErr.Err.Message != tt.expected {
+			t.Errorf("%q: wrong message. expected=%q, got=%q", tt.input, tt.expected, runtimeErr.Err.Message)
+		}
+	}
+
+	// Each call gets its own budget.
+	result, err := in.Eval("let x = 0; while (x < 10) { x++ }; x")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	testInteger(t, result, 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	in.SetLimits(evaluator.Limits{})
+
+	_, err = in.EvalContext(ctx, "while (true) { }")
+
+	if _, ok := err.(*RuntimeError); !ok {
+		t.Errorf("expected *RuntimeError from a timed out context, got=%T (%v)", err, err)
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	in := New()
+
+	in.RegisterBuiltin("double", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	})
+
+	in.RegisterBuiltin("len", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: -1}
+	})
+
+	result, err := in.Eval("let quadruple = fn(x) { double(double(x)) }; quadruple(3) + len([1, 2])")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	testInteger(t, result, 11)
+}
+
+func TestGetSet(t *testing.T) {
+	in := New()
+
+	in.Set("limit", &object.Integer{Value: 10})
+
+	_, err := in.Eval("let total = limit * 2; let limit = limit + 1;")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	total, ok := in.Get("total")
+
+	if !ok {
+		t.Fatalf("total is not bound")
+	}
+
+	testInteger(t, total, 20)
+
+	limit, _ := in.Get("limit")
+	testInteger(t, limit, 11)
+
+	if _, ok := in.Get("missing"); ok {
+		t.Errorf("missing should not be bound")
+	}
+
+	in.Set("limit", &object.Integer{Value: 1})
+
+	result, _ := in.Eval("limit")
+	testInteger(t, result, 1)
+}
+
+func testInteger(t *testing.T, obj object.Object, expected int64) {
+	t.Helper()
+
+	integer, ok := obj.(*object.Integer)
+
+	if !ok {
+		t.Fatalf("object is not Integer. got=%T (%+v)", obj, obj)
+	}
+
+	if integer.Value != expected {
+		t.Errorf("wrong value. want=%d, got=%d", expected, integer.Value)
+	}
+}
+
+// Run with -race: the goroutines share one Interpreter, and a second one
+// shares the evaluator with it.
+func TestConcurrentEval(t *testing.T) {
+	in := New()
+	other := New()
+
+	in.Set("count", &object.Integer{Value: 0})
+	in.RegisterBuiltin("bump", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value + 1}
+	})
+
+	const goroutines, rounds = 8, 50
+
+	var wg sync.WaitGroup
+
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+
+		go func(g int) {
+			defer wg.Done()
+
+			for i := 0; i < rounds; i++ {
+				if _, err := in.Eval("count = bump(count); let seen = [count];"); err != nil {
+					t.Errorf("unexpected error: %s", err)
+					return
+				}
+
+				if _, ok := in.Get("seen"); !ok {
+					t.Errorf("seen is not bound")
+					return
+				}
+
+				in.Set(fmt.Sprintf("g%d", g), &object.Integer{Value: int64(i)})
+
+				if _, err := other.Eval("let xs = map([1, 2, 3], fn(x) { x * 2 }); xs"); err != nil {
+					t.Errorf("unexpected error: %s", err)
+					return
+				}
+			}
+		}(g)
+	}
+
+	wg.Wait()
+
+	count, _ := in.Get("count")
+	testInteger(t, count, goroutines*rounds)
+
+	for g := 0; g < goroutines; g++ {
+		last, _ := in.Get(fmt.Sprintf("g%d", g))
+		testInteger(t, last, rounds-1)
+	}
+}
+
+func TestClone(t *testing.T) {
+	in := New()
+
+	_, err := in.Eval("let base = 10; let twice = macro(x) { quote(unquote(x) * 2) };")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	in.SetLimits(evaluator.Limits{MaxSteps: 1000})
+
+	clones := make([]*Interpreter, 4)
+
+	for i := range clones {
+		clones[i] = in.Clone()
+	}
+
+	var wg sync.WaitGroup
+
+	for i, clone := range clones {
+		wg.Add(1)
+
+		go func(i int, clone *Interpreter) {
+			defer wg.Done()
+
+			_, err := clone.Eval(fmt.Sprintf("base = base + %d; let mine = twice(base);", i))
+
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		}(i, clone)
+	}
+
+	wg.Wait()
+
+	for i, clone := range clones {
+		mine, _ := clone.Get("mine")
+		testInteger(t, mine, int64(2*(10+i)))
+	}
+
+	base, _ := in.Get("base")
+	testInteger(t, base, 10)
+
+	if _, ok := in.Get("mine"); ok {
+		t.Errorf("a clone's let should not bind mine in the original")
+	}
+
+	if _, err := clones[0].Eval("while (true) {}"); err == nil {
+		t.Errorf("a clone should keep the original's limits")
+	}
+}
+
+// TestCloneSharesEarlierFunctions pins down what Clone documents: a function
+// defined before the clone was made keeps using the original's globals.
+func TestCloneSharesEarlierFunctions(t *testing.T) {
+	in := New()
+
+	_, err := in.Eval("let count = 0; let bump = fn() { count = count + 1 }; let read = fn() { count };")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	clone := in.Clone()
+
+	result, err := clone.Eval("count = 100; bump(); read()")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	testInteger(t, result, 1)
+
+	count, _ := clone.Get("count")
+	testInteger(t, count, 100)
+
+	count, _ = in.Get("count")
+	testInteger(t, count, 1)
+
+	result, err = clone.Eval("let bump = fn() { count = count + 1 }; bump(); count")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	testInteger(t, result, 101)
+}
+
+func TestOutputAndInput(t *testing.T) {
+	var out bytes.Buffer
+
+	in := New()
+	in.SetOutput(&out)
+	in.SetInput(strings.NewReader("first\nsecond\n"))
+
+	_, err := in.Eval(`puts("got " + readLine()); print(1, 2); printf("%d!\n", 3)`)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := in.Eval("readLine()")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if result.Inspect() != `"second"` {
+		t.Errorf("the second program should read on from the first. got=%s", result.Inspect())
+	}
+
+	if got, want := out.String(), "got first\n123!\n"; got != want {
+		t.Errorf("wrong output. want=%q, got=%q", want, got)
+	}
+}
+
+func TestOutputOfSpawnedTask(t *testing.T) {
+	var out, other bytes.Buffer
+
+	in := New()
+	in.SetOutput(&out)
+
+	if _, err := in.Eval(`let ch = channel(); let t = spawn(fn() { recv(ch); puts("late") })`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	second := New()
+	second.SetOutput(&other)
+
+	if _, err := second.Eval(`puts("other")`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := in.Eval("send(ch, 1); wait(t)"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if out.String() != "late\n" || other.String() != "other\n" {
+		t.Errorf("a task should write to its own Interpreter's output. got=%q and %q", out.String(), other.String())
+	}
+}
+
+// Run with -race: each goroutine's Interpreter writes to a buffer of its own.
+func TestOutputPerInterpreter(t *testing.T) {
+	const goroutines, rounds = 4, 20
+
+	outs := make([]bytes.Buffer, goroutines)
+
+	var wg sync.WaitGroup
+
+	for g := range outs {
+		wg.Add(1)
+
+		go func(g int) {
+			defer wg.Done()
+
+			in := New()
+			in.SetOutput(&outs[g])
+
+			for i := 0; i < rounds; i++ {
+				if _, err := in.Eval(fmt.Sprintf("puts(%d)", g)); err != nil {
+					t.Errorf("unexpected error: %s", err)
+					return
+				}
+			}
+		}(g)
+	}
+
+	wg.Wait()
+
+	for g := range outs {
+		if want := strings.Repeat(fmt.Sprintf("%d\n", g), rounds); outs[g].String() != want {
+			t.Errorf("goroutine %d: wrong output. want=%q, got=%q", g, want, outs[g].String())
+		}
+	}
+}
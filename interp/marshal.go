@@ -0,0 +1,447 @@
+package interp
+
+import (
+	"fmt"
+	"monkey/object"
+	"reflect"
+	"sort"
+)
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// RegisterFunc binds the Go function fn to name, converting arguments and
+// results between Go and Monkey values. Parameters may be integers, floats,
+// strings, bools, slices and maps of those, interface{} or object.Object;
+// fn may be variadic. It may return nothing, one value, an error, or a value
+// and an error. A Monkey call with the wrong number or types of arguments
+// gets a Monkey error instead of reaching fn, and so does a non-nil error
+// from fn.
+func (in *Interpreter) RegisterFunc(name string, fn interface{}) error {
+	builtin, err := wrapFunc(name, fn)
+
+	if err != nil {
+		return err
+	}
+
+	in.Set(name, builtin)
+
+	return nil
+}
+
+func wrapFunc(name string, fn interface{}) (*object.Builtin, error) {
+	f := reflect.ValueOf(fn)
+
+	if f.Kind() != reflect.Func {
+		return nil, fmt.Errorf("%s: want a function, got %T", name, fn)
+	}
+
+	ft := f.Type()
+
+	for i := 0; i < ft.NumIn(); i++ {
+		param := ft.In(i)
+
+		if ft.IsVariadic() && i == ft.NumIn()-1 {
+			param = param.Elem()
+		}
+
+		if !convertible(param) {
+			return nil, fmt.Errorf("%s: cannot pass Monkey values as parameter %d of type %s", name, i+1, param)
+		}
+	}
+
+	switch {
+	case ft.NumOut() > 2,
+		ft.NumOut() == 2 && ft.Out(1) != errorType,
+		ft.NumOut() >= 1 && ft.Out(0) != errorType && !convertible(ft.Out(0)):
+		return nil, fmt.Errorf("%s: cannot return %s to Monkey", name, ft)
+	}
+
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		in, errObj := callArgs(name, ft, args)
+
+		if errObj != nil {
+			return errObj
+		}
+
+		return callResult(name, f.Call(in))
+	}}, nil
+}
+
+func callArgs(name string, ft reflect.Type, args []object.Object) ([]reflect.Value, *object.Error) {
+	fixed := ft.NumIn()
+
+	if ft.IsVariadic() {
+		fixed--
+
+		if len(args) < fixed {
+			return nil, newError("wrong number of arguments. got=%d, want at least %d", len(args), fixed)
+		}
+	} else if len(args) != fixed {
+		return nil, newError("wrong number of arguments. got=%d, want=%d", len(args), fixed)
+	}
+
+	in := make([]reflect.Value, len(args))
+
+	for i, arg := range args {
+		var param reflect.Type
+
+		if i < fixed {
+			param = ft.In(i)
+		} else {
+			param = ft.In(fixed).Elem()
+		}
+
+		value, got := fromObject(arg, param)
+
+		if got != "" {
+			return nil, newError("argument %d to `%s` must be %s, got %s", i+1, name, typeName(param), got)
+		}
+
+		in[i] = value
+	}
+
+	return in, nil
+}
+
+func callResult(name string, out []reflect.Value) object.Object {
+	if len(out) > 0 && out[len(out)-1].Type() == errorType {
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			return newError("%s", err)
+		}
+
+		out = out[:len(out)-1]
+	}
+
+	if len(out) == 0 {
+		return object.NULL
+	}
+
+	result, err := toObject(out[0])
+
+	if err != nil {
+		return newError("`%s` returned %s", name, err)
+	}
+
+	return result
+}
+
+// ToObject converts a Go value to the Monkey value RegisterFunc would pass
+// for it, for use with Set. A nil interface or pointer becomes null; map
+// keys are sorted so the hash has a stable order.
+func ToObject(v interface{}) (object.Object, error) {
+	return toObject(reflect.ValueOf(v))
+}
+
+func toObject(v reflect.Value) (object.Object, error) {
+	if !v.IsValid() {
+		return object.NULL, nil
+	}
+
+	if v.Type().Implements(objectType) {
+		if (v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer) && v.IsNil() {
+			return object.NULL, nil
+		}
+
+		return v.Interface().(object.Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return object.NewInteger(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if int64(v.Uint()) < 0 {
+			return nil, fmt.Errorf("%d, which does not fit in an INTEGER", v.Uint())
+		}
+
+		return object.NewInteger(int64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Bool:
+		return object.NativeBool(v.Bool()), nil
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return object.NULL, nil
+		}
+
+		return toObject(v.Elem())
+	case reflect.Slice, reflect.Array:
+		elements := make([]object.Object, v.Len())
+
+		for i := range elements {
+			element, err := toObject(v.Index(i))
+
+			if err != nil {
+				return nil, err
+			}
+
+			elements[i] = element
+		}
+
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		return mapToHash(v)
+	default:
+		return nil, fmt.Errorf("a %s, which has no Monkey equivalent", v.Type())
+	}
+}
+
+func mapToHash(v reflect.Value) (object.Object, error) {
+	pairs := make([]object.HashPair, 0, v.Len())
+	iter := v.MapRange()
+
+	for iter.Next() {
+		key, err := toObject(iter.Key())
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := key.(object.Hashable); !ok {
+			return nil, fmt.Errorf("a map with key %s: %s", key.Inspect(), object.UnusableHashKey(key))
+		}
+
+		value, err := toObject(iter.Value())
+
+		if err != nil {
+			return nil, err
+		}
+
+		pairs = append(pairs, object.HashPair{Key: key, Value: value})
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return keyLess(pairs[i].Key, pairs[j].Key)
+	})
+
+	hash := object.NewHash()
+
+	for _, pair := range pairs {
+		hash.Set(pair.Key.(object.Hashable).HashKey(), pair)
+	}
+
+	return hash, nil
+}
+
+// keyLess orders hash keys by type, then by value.
+func keyLess(a, b object.Object) bool {
+	if a.Type() != b.Type() {
+		return a.Type() < b.Type()
+	}
+
+	switch a := a.(type) {
+	case *object.Integer:
+		return a.Value < b.(*object.Integer).Value
+	case *object.String:
+		return a.Value < b.(*object.String).Value
+	case *object.Boolean:
+		return !a.Value && b.(*object.Boolean).Value
+	default:
+		return false
+	}
+}
+
+// ToGo converts a Monkey value to its natural Go value: int64, float64,
+// string, bool, nil, []interface{} or map[interface{}]interface{}. Other
+// values, such as functions, are returned as they are.
+func ToGo(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
+		return nil
+	case *object.Array:
+		elements := make([]interface{}, len(obj.Elements))
+
+		for i, element := range obj.Elements {
+			elements[i] = ToGo(element)
+		}
+
+		return elements
+	case *object.Hash:
+		m := make(map[interface{}]interface{}, len(obj.Pairs))
+
+		for _, pair := range obj.Pairs {
+			m[ToGo(pair.Key)] = ToGo(pair.Value)
+		}
+
+		return m
+	default:
+		return obj
+	}
+}
+
+// fromObject converts obj to a Go value of type t. When it cannot, it
+// returns a description of what it got instead.
+func fromObject(obj object.Object, t reflect.Type) (reflect.Value, string) {
+	if t == objectType {
+		return reflect.ValueOf(&obj).Elem(), ""
+	}
+
+	if t.Kind() != reflect.Interface && t.Implements(objectType) {
+		if reflect.TypeOf(obj) == t {
+			return reflect.ValueOf(obj), ""
+		}
+
+		return reflect.Value{}, string(obj.Type())
+	}
+
+	if t.Kind() == reflect.Interface {
+		if t.NumMethod() == 0 {
+			value := reflect.New(t).Elem()
+
+			if v := ToGo(obj); v != nil {
+				value.Set(reflect.ValueOf(v))
+			}
+
+			return value, ""
+		}
+
+		if reflect.TypeOf(obj).Implements(t) {
+			return reflect.ValueOf(obj).Convert(t), ""
+		}
+
+		return reflect.Value{}, string(obj.Type())
+	}
+
+	value := reflect.New(t).Elem()
+
+	switch obj := obj.(type) {
+	case *object.Integer:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if value.OverflowInt(obj.Value) {
+				return reflect.Value{}, fmt.Sprintf("%d, which overflows %s", obj.Value, t)
+			}
+
+			value.SetInt(obj.Value)
+
+			return value, ""
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if obj.Value < 0 || value.OverflowUint(uint64(obj.Value)) {
+				return reflect.Value{}, fmt.Sprintf("%d, which overflows %s", obj.Value, t)
+			}
+
+			value.SetUint(uint64(obj.Value))
+
+			return value, ""
+		case reflect.Float32, reflect.Float64:
+			value.SetFloat(float64(obj.Value))
+
+			return value, ""
+		}
+	case *object.Float:
+		if t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64 {
+			value.SetFloat(obj.Value)
+
+			return value, ""
+		}
+	case *object.String:
+		if t.Kind() == reflect.String {
+			value.SetString(obj.Value)
+
+			return value, ""
+		}
+	case *object.Boolean:
+		if t.Kind() == reflect.Bool {
+			value.SetBool(obj.Value)
+
+			return value, ""
+		}
+	case *object.Array:
+		if t.Kind() == reflect.Slice {
+			value.Set(reflect.MakeSlice(t, len(obj.Elements), len(obj.Elements)))
+
+			for i, element := range obj.Elements {
+				v, got := fromObject(element, t.Elem())
+
+				if got != "" {
+					return reflect.Value{}, fmt.Sprintf("%s element", got)
+				}
+
+				value.Index(i).Set(v)
+			}
+
+			return value, ""
+		}
+	case *object.Hash:
+		if t.Kind() == reflect.Map {
+			value.Set(reflect.MakeMapWithSize(t, len(obj.Pairs)))
+
+			for _, pair := range obj.OrderedPairs() {
+				k, got := fromObject(pair.Key, t.Key())
+
+				if got != "" {
+					return reflect.Value{}, fmt.Sprintf("%s key", got)
+				}
+
+				v, got := fromObject(pair.Value, t.Elem())
+
+				if got != "" {
+					return reflect.Value{}, fmt.Sprintf("%s value", got)
+				}
+
+				value.SetMapIndex(k, v)
+			}
+
+			return value, ""
+		}
+	}
+
+	return reflect.Value{}, string(obj.Type())
+}
+
+// convertible reports whether fromObject and toObject handle t.
+func convertible(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String, reflect.Bool, reflect.Interface:
+		return true
+	case reflect.Slice:
+		return convertible(t.Elem())
+	case reflect.Map:
+		return convertible(t.Key()) && convertible(t.Elem())
+	default:
+		return t.Implements(objectType)
+	}
+}
+
+// typeName describes t with Monkey's type names, as in "ARRAY of INTEGER".
+func typeName(t reflect.Type) string {
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 || t == objectType {
+		return "any value"
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return string(object.INTEGER_OBJ)
+	case reflect.Float32, reflect.Float64:
+		return string(object.FLOAT_OBJ)
+	case reflect.String:
+		return string(object.STRING_OBJ)
+	case reflect.Bool:
+		return string(object.BOOLEAN_OBJ)
+	case reflect.Slice:
+		return fmt.Sprintf("%s of %s", object.ARRAY_OBJ, typeName(t.Elem()))
+	case reflect.Map:
+		return fmt.Sprintf("%s of %s to %s", object.HASH_OBJ, typeName(t.Key()), typeName(t.Elem()))
+	default:
+		return t.String()
+	}
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
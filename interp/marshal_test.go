@@ -0,0 +1,188 @@
+package interp
+
+import (
+	"errors"
+	"monkey/object"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegisterFunc(t *testing.T) {
+	in := New()
+
+	funcs := map[string]interface{}{
+		"add":    func(a, b int64) int64 { return a + b },
+		"half":   func(x float64) float64 { return x / 2 },
+		"shout":  func(s string) string { return strings.ToUpper(s) + "!" },
+		"not":    func(b bool) bool { return !b },
+		"sum":    func(xs []int) int { return sumInts(xs) },
+		"total":  func(xs ...int) int { return sumInts(xs) },
+		"words":  func(s string) []string { return strings.Fields(s) },
+		"counts": func(xs []string) map[string]int { return countWords(xs) },
+		"keys":   func(m map[string]interface{}) int { return len(m) },
+		"kind":   func(v interface{}) string { return reflect.TypeOf(v).String() },
+		"same":   func(obj object.Object) object.Object { return obj },
+		"noop":   func() {},
+		"check": func(n int) (int, error) {
+			if n < 0 {
+				return 0, errors.New("negative")
+			}
+
+			return n, nil
+		},
+	}
+
+	for name, fn := range funcs {
+		if err := in.RegisterFunc(name, fn); err != nil {
+			t.Fatalf("RegisterFunc(%q) failed: %s", name, err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`add(2, 3)`, "5"},
+		{`half(3)`, "1.5"},
+		{`half(5.0)`, "2.5"},
+		{`shout("hi")`, `"HI!"`},
+		{`not(true)`, "false"},
+		{`sum([1, 2, 3])`, "6"},
+		{`total()`, "0"},
+		{`total(1, 2, 3, 4)`, "10"},
+		{`words("a b  c")`, `["a", "b", "c"]`},
+		{`counts(["b", "a", "b"])`, `{"a": 1, "b": 2}`},
+		{`keys({"x": 1, "y": [true]})`, "2"},
+		{`kind([1, "a"])`, `"[]interface {}"`},
+		{`kind({1: 2})`, `"map[interface {}]interface {}"`},
+		{`kind(1)`, `"int64"`},
+		{`same(fn(x) { x })(4)`, "4"},
+		{`noop()`, "null"},
+		{`check(3)`, "3"},
+		{`try { check(-1) } catch (e) { e }`, `"negative"`},
+	}
+
+	for _, tt := range tests {
+		result, err := in.Eval(tt.input)
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.input, err)
+
+			continue
+		}
+
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s: want=%s, got=%s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestRegisterFuncArgumentErrors(t *testing.T) {
+	in := New()
+
+	in.RegisterFunc("add", func(a, b int64) int64 { return a + b })
+	in.RegisterFunc("small", func(n int8) int8 { return n })
+	in.RegisterFunc("sum", func(xs []int) int { return sumInts(xs) })
+	in.RegisterFunc("lookup", func(m map[string]int, key string) int { return m[key] })
+	in.RegisterFunc("join", func(sep string, parts ...string) string { return strings.Join(parts, sep) })
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`add(1)`, "wrong number of arguments. got=1, want=2"},
+		{`add(1, "2")`, "argument 2 to `add` must be INTEGER, got STRING"},
+		{`small(200)`, "argument 1 to `small` must be INTEGER, got 200, which overflows int8"},
+		{`sum([1, "a"])`, "argument 1 to `sum` must be ARRAY of INTEGER, got STRING element"},
+		{`lookup({1: 2}, "a")`, "argument 1 to `lookup` must be HASH of STRING to INTEGER, got INTEGER key"},
+		{`join()`, "wrong number of arguments. got=0, want at least 1"},
+		{`join(",", "a", 1)`, "argument 3 to `join` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		_, err := in.Eval(tt.input)
+
+		runtimeErr, ok := err.(*RuntimeError)
+
+		if !ok {
+			t.Errorf("%s: expected *RuntimeError, got=%T (%v)", tt.input, err, err)
+
+			continue
+		}
+
+		if runtimeErr.Err.Message != tt.expected {
+			t.Errorf("%s: wrong message. want=%q, got=%q", tt.input, tt.expected, runtimeErr.Err.Message)
+		}
+	}
+}
+
+func TestRegisterFuncRejectsSignatures(t *testing.T) {
+	in := New()
+
+	bad := []interface{}{
+		42,
+		func(ch chan int) {},
+		func() (int, int) { return 0, 0 },
+		func() struct{} { return struct{}{} },
+	}
+
+	for _, fn := range bad {
+		if err := in.RegisterFunc("bad", fn); err == nil {
+			t.Errorf("RegisterFunc accepted %T", fn)
+		}
+	}
+}
+
+func TestToObjectAndToGo(t *testing.T) {
+	obj, err := ToObject(map[string]interface{}{
+		"name": "monkey",
+		"tags": []string{"a", "b"},
+		"size": uint8(3),
+		"none": nil,
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := `{"name": "monkey", "none": null, "size": 3, "tags": ["a", "b"]}`
+
+	if obj.Inspect() != expected {
+		t.Errorf("wrong object. want=%s, got=%s", expected, obj.Inspect())
+	}
+
+	back := ToGo(obj).(map[interface{}]interface{})
+
+	if back["size"] != int64(3) || back["none"] != nil || !reflect.DeepEqual(back["tags"], []interface{}{"a", "b"}) {
+		t.Errorf("wrong Go value. got=%#v", back)
+	}
+
+	if _, err := ToObject(map[float64]int{1.5: 1}); err == nil {
+		t.Errorf("expected an error for FLOAT keys")
+	}
+
+	if _, err := ToObject(struct{}{}); err == nil {
+		t.Errorf("expected an error for a struct")
+	}
+}
+
+func sumInts(xs []int) int {
+	total := 0
+
+	for _, x := range xs {
+		total += x
+	}
+
+	return total
+}
+
+func countWords(words []string) map[string]int {
+	counts := make(map[string]int)
+
+	for _, word := range words {
+		counts[word]++
+	}
+
+	return counts
+}
@@ -0,0 +1,41 @@
+package lexer
+
+import (
+	"monkey/token"
+	"testing"
+)
+
+// fuzzSeeds are inputs that reach the lexer's trickier paths, for the
+// fuzzers of the lexer and parser to start from.
+var fuzzSeeds = []string{
+	`let five = 5; let add = fn(x, y) { x + y };`,
+	`"a${x + "b${y}"}c" "\n\t\"" "unterminated`,
+	`0x1F 0o17 0b101 1_000 1.5e3 0x 1e 1.`,
+	`// comment
+/* block */ /* open`,
+	`a |> f(1) ?? b ?. c ... <<= >>= ~x & | ^ && || == != <= >=`,
+	"`raw` 'c' @ # $ \x00 \xff",
+}
+
+func FuzzLexer(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		l := New(input)
+
+		// No token is empty, apart from the interpolation tokens, which
+		// need at least a $ or a quote around them, so a lexer that gets to
+		// the end never makes more tokens than twice the input's length.
+		for i := 0; i <= 2*len(input)+1; i++ {
+			tok := l.NextToken()
+
+			if tok.Type == token.EOF {
+				return
+			}
+		}
+
+		t.Fatalf("no EOF after %d tokens of %q", 2*len(input)+2, input)
+	})
+}
@@ -0,0 +1,36 @@
+package lexer
+
+import "monkey/token"
+
+// Token is a token as it is spelled in the source, for editors and syntax
+// highlighters. Text is the source text itself, so a string keeps its
+// quotes and escapes, and the gaps between the Offset and End of
+// consecutive tokens are exactly the whitespace between them.
+type Token struct {
+	token.Token
+	Category token.Category
+	Text     string
+}
+
+// TokenizeAll lexes src to the end, comments included. The EOF token is
+// left out.
+func TokenizeAll(src string) []Token {
+	l := New(src)
+	l.comments = true
+
+	tokens := []Token{}
+
+	for {
+		tok := l.NextToken()
+
+		if tok.Type == token.EOF {
+			return tokens
+		}
+
+		tokens = append(tokens, Token{
+			Token:    tok,
+			Category: token.CategoryOf(tok.Type),
+			Text:     src[tok.Offset:tok.End],
+		})
+	}
+}
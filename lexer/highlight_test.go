@@ -0,0 +1,97 @@
+package lexer
+
+import (
+	"monkey/token"
+	"strings"
+	"testing"
+)
+
+func TestTokenizeAll(t *testing.T) {
+	input := "// greet\nlet s = \"hi\\n\" + fn(x) { x ?? 1.5 }; /* é */ if (true) {}"
+
+	tests := []struct {
+		category token.Category
+		text     string
+		line     int
+		column   int
+	}{
+		{token.CATEGORY_COMMENT, "// greet", 1, 1},
+		{token.CATEGORY_KEYWORD, "let", 2, 1},
+		{token.CATEGORY_IDENTIFIER, "s", 2, 5},
+		{token.CATEGORY_OPERATOR, "=", 2, 7},
+		{token.CATEGORY_LITERAL, `"hi\n"`, 2, 9},
+		{token.CATEGORY_OPERATOR, "+", 2, 16},
+		{token.CATEGORY_KEYWORD, "fn", 2, 18},
+		{token.CATEGORY_DELIMITER, "(", 2, 20},
+		{token.CATEGORY_IDENTIFIER, "x", 2, 21},
+		{token.CATEGORY_DELIMITER, ")", 2, 22},
+		{token.CATEGORY_DELIMITER, "{", 2, 24},
+		{token.CATEGORY_IDENTIFIER, "x", 2, 26},
+		{token.CATEGORY_OPERATOR, "??", 2, 28},
+		{token.CATEGORY_LITERAL, "1.5", 2, 31},
+		{token.CATEGORY_DELIMITER, "}", 2, 35},
+		{token.CATEGORY_DELIMITER, ";", 2, 36},
+		{token.CATEGORY_COMMENT, "/* é */", 2, 38},
+		{token.CATEGORY_KEYWORD, "if", 2, 46},
+		{token.CATEGORY_DELIMITER, "(", 2, 49},
+		{token.CATEGORY_LITERAL, "true", 2, 50},
+		{token.CATEGORY_DELIMITER, ")", 2, 54},
+		{token.CATEGORY_DELIMITER, "{", 2, 56},
+		{token.CATEGORY_DELIMITER, "}", 2, 57},
+	}
+
+	tokens := TokenizeAll(input)
+
+	if len(tokens) != len(tests) {
+		t.Fatalf("wrong number of tokens. want=%d, got=%d", len(tests), len(tokens))
+	}
+
+	for i, tt := range tests {
+		tok := tokens[i]
+
+		if tok.Category != tt.category || tok.Text != tt.text || tok.Line != tt.line || tok.Column != tt.column {
+			t.Errorf("tokens[%d] wrong. want=%s %q at %d:%d, got=%s %q at %d:%d",
+				i, tt.category, tt.text, tt.line, tt.column, tok.Category, tok.Text, tok.Line, tok.Column)
+		}
+
+		if input[tok.Offset:tok.End] != tok.Text {
+			t.Errorf("tokens[%d] offsets %d:%d do not match its text %q", i, tok.Offset, tok.End, tok.Text)
+		}
+	}
+
+	// Only whitespace lies between tokens.
+	previous := 0
+
+	for _, tok := range tokens {
+		if gap := input[previous:tok.Offset]; strings.TrimSpace(gap) != "" {
+			t.Errorf("non-whitespace %q before %q", gap, tok.Text)
+		}
+
+		previous = tok.End
+	}
+}
+
+func TestTokenizeAllIllegal(t *testing.T) {
+	tokens := TokenizeAll("x @ \"open /* never")
+
+	// An unterminated string runs to the end of the input.
+	expected := []token.Category{token.CATEGORY_IDENTIFIER, token.CATEGORY_ILLEGAL, token.CATEGORY_ILLEGAL}
+
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. got=%+v", tokens)
+	}
+
+	for i, category := range expected {
+		if tokens[i].Category != category {
+			t.Errorf("tokens[%d] wrong category. want=%s, got=%s", i, category, tokens[i].Category)
+		}
+	}
+
+	if tokens[2].Text != "\"open /* never" {
+		t.Errorf("the string should run to the end. got=%q", tokens[2].Text)
+	}
+
+	if comment := TokenizeAll("/* open"); len(comment) != 1 || comment[0].Text != "/* open" || comment[0].Category != token.CATEGORY_ILLEGAL {
+		t.Errorf("wrong unterminated comment. got=%+v", comment)
+	}
+}
@@ -1,80 +1,332 @@
 package lexer
 
-import "monkey/token"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"monkey/token"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
+// An ILLEGAL token's literal is a message saying what is wrong with it.
+// These are the messages for input that ends inside a block comment or a
+// string literal, which the REPL takes as a sign more is to come.
+const (
+	UNTERMINATED_COMMENT = "unterminated block comment"
+	UNTERMINATED_STRING  = "unterminated string"
+)
+
+// readChunkSize is how much a lexer made by NewReader asks its reader for
+// at a time.
+const readChunkSize = 4096
+
+// Lexer reads its input as UTF-8, one rune at a time. Positions are byte
+// offsets into the input; columns count runes.
 type Lexer struct {
 	input        string
-	position     int
-	readPosition int
-	ch           byte
+	position     int  // byte offset of ch
+	readPosition int  // byte offset of the rune after ch
+	ch           rune // 0 at the end of the input
+	line         int
+	column       int
+
+	// comments makes NextToken return comments as COMMENT tokens instead
+	// of skipping them, which only TokenizeAll does. start is the byte
+	// offset of the token being lexed.
+	comments bool
+	start    int
+
+	// interpolations holds, for each ${ the lexer is inside, how many
+	// braces have been opened since, so that the } closing it is known.
+	interpolations []int
+
+	// reader, if set, is where the input comes from: input then holds only
+	// what has been read from it since the token being lexed began, and
+	// more is read whenever the lexer gets to the end of that. discarded is
+	// how many bytes before it have been let go of, and readErr is what
+	// reading stopped at, io.EOF at the end of the input.
+	reader    io.Reader
+	discarded int
+	readErr   error
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
+
+	l.readChar()
+
+	return l
+}
+
+// NewReader lexes the input r delivers as it arrives, instead of all of it
+// up front: NextToken reads only as far as it needs to end the next token,
+// and the input already lexed is let go of. Reading stops at the first
+// error, which ends the input; Err reports it.
+func NewReader(r io.Reader) *Lexer {
+	l := &Lexer{reader: r, line: 1}
 
 	l.readChar()
 
 	return l
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+// Err returns the error that cut the input of a lexer made by NewReader
+// short, or nil if it was read to the end.
+func (l *Lexer) Err() error {
+	if l.readErr == io.EOF {
+		return nil
+	}
+
+	return l.readErr
+}
+
+// fill reads from the reader, if there is one, until input holds a whole
+// rune at offset or there is nothing more to read.
+func (l *Lexer) fill(offset int) {
+	for l.reader != nil && l.readErr == nil && !utf8.FullRuneInString(l.input[min(offset, len(l.input)):]) {
+		buf := make([]byte, readChunkSize)
+		n, err := l.reader.Read(buf)
+
+		l.input += string(buf[:n])
+		l.readErr = err
+	}
+}
+
+// discard lets go of the input before the current character, all of which
+// has been lexed, when it comes from a reader.
+func (l *Lexer) discard() {
+	if l.reader == nil {
+		return
+	}
+
+	n := min(l.position, len(l.input))
+
+	l.input = l.input[n:]
+	l.position -= n
+	l.readPosition -= n
+	l.discarded += n
+}
+
+// makeTwoCharToken consumes the current character and the next one and
+// returns them as a single token of tokenType.
+func (l *Lexer) makeTwoCharToken(tokenType token.TokenType) token.Token {
+	ch := l.ch
+	l.readChar()
+
+	return token.Token{Type: tokenType, Literal: string(ch) + string(l.ch)}
+}
+
+// illegalCharacter describes a character no token starts with.
+func illegalCharacter(ch rune) string {
+	switch {
+	case ch == utf8.RuneError:
+		return "invalid UTF-8 in input"
+	case ch == '\'':
+		return "unexpected character `'`, strings are written in double quotes"
+	case unicode.IsPrint(ch):
+		return fmt.Sprintf("unexpected character `%c`", ch)
+	default:
+		return fmt.Sprintf("unexpected character %U", ch)
+	}
+}
+
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{
 		Type:    tokenType,
 		Literal: string(ch),
 	}
 }
 
+// NextToken returns the next token, with the byte offsets in the input of
+// where it starts and ends.
 func (l *Lexer) NextToken() token.Token {
+	tok := l.nextToken()
+
+	// Reading past the end of the input still advances position.
+	tok.Offset = l.discarded + min(l.start, len(l.input))
+	tok.End = l.discarded + min(l.position, len(l.input))
+
+	return tok
+}
+
+func (l *Lexer) nextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
+	l.discard()
+
+	for l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*') {
+		line, column := l.line, l.column
+		l.start = l.position
+
+		if l.peekChar() == '/' {
+			l.skipLineComment()
+		} else if !l.skipBlockComment() {
+			return token.Token{
+				Type:    token.ILLEGAL,
+				Literal: UNTERMINATED_COMMENT,
+				Line:    line,
+				Column:  column,
+			}
+		}
+
+		if l.comments {
+			return token.Token{
+				Type:    token.COMMENT,
+				Literal: l.input[l.start:l.position],
+				Line:    line,
+				Column:  column,
+			}
+		}
+
+		l.skipWhitespace()
+	}
+
+	line, column := l.line, l.column
+	l.start = l.position
 
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
-			ch := l.ch
-			l.readChar()
-			literal := string(ch) + string(l.ch)
-			tok = token.Token{Type: token.EQ, Literal: literal}
+			tok = l.makeTwoCharToken(token.EQ)
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case '!':
 		if l.peekChar() == '=' {
-			ch := l.ch
-			l.readChar()
-			literal := string(ch) + string(l.ch)
-			tok = token.Token{Type: token.NOT_EQ, Literal: literal}
+			tok = l.makeTwoCharToken(token.NOT_EQ)
 		} else {
 			tok = newToken(token.BANG, l.ch)
 		}
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.makeTwoCharToken(token.AND)
+		} else {
+			tok = newToken(token.BIT_AND, l.ch)
+		}
+	case '|':
+		switch l.peekChar() {
+		case '|':
+			tok = l.makeTwoCharToken(token.OR)
+		case '>':
+			tok = l.makeTwoCharToken(token.PIPE)
+		default:
+			tok = newToken(token.BIT_OR, l.ch)
+		}
+	case '^':
+		tok = newToken(token.BIT_XOR, l.ch)
+	case '~':
+		tok = newToken(token.BIT_NOT, l.ch)
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		switch l.peekChar() {
+		case '+':
+			tok = l.makeTwoCharToken(token.INCREMENT)
+		case '=':
+			tok = l.makeTwoCharToken(token.PLUS_ASSIGN)
+		default:
+			tok = newToken(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		switch l.peekChar() {
+		case '-':
+			tok = l.makeTwoCharToken(token.DECREMENT)
+		case '=':
+			tok = l.makeTwoCharToken(token.MINUS_ASSIGN)
+		default:
+			tok = newToken(token.MINUS, l.ch)
+		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.makeTwoCharToken(token.SLASH_ASSIGN)
+		} else {
+			tok = newToken(token.SLASH, l.ch)
+		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		switch l.peekChar() {
+		case '*':
+			tok = l.makeTwoCharToken(token.POWER)
+		case '=':
+			tok = l.makeTwoCharToken(token.ASTERISK_ASSIGN)
+		default:
+			tok = newToken(token.ASTERISK, l.ch)
+		}
+	case '?':
+		switch l.peekChar() {
+		case '?':
+			tok = l.makeTwoCharToken(token.COALESCE)
+		case '.':
+			tok = l.makeTwoCharToken(token.OPTIONAL_DOT)
+		case '[':
+			tok = l.makeTwoCharToken(token.OPTIONAL_LBRACKET)
+		default:
+			tok = newToken(token.QUESTION, l.ch)
+		}
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.makeTwoCharToken(token.LT_EQ)
+		case '<':
+			tok = l.makeTwoCharToken(token.SHIFT_LEFT)
+		default:
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.makeTwoCharToken(token.GT_EQ)
+		case '>':
+			tok = l.makeTwoCharToken(token.SHIFT_RIGHT)
+		default:
+			tok = newToken(token.GT, l.ch)
+		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
+	case '.':
+		l.fill(l.position + 2)
+
+		if strings.HasPrefix(l.input[l.position:], "...") {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
 	case '{':
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1]++
+		}
+
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
-		tok = newToken(token.RBRACE, l.ch)
+		if n := len(l.interpolations); n > 0 && l.interpolations[n-1] == 0 {
+			l.interpolations = l.interpolations[:n-1]
+			tok = l.readStringPart(token.INTERP_MID, token.INTERP_END)
+		} else {
+			if n > 0 {
+				l.interpolations[n-1]--
+			}
+
+			tok = newToken(token.RBRACE, l.ch)
+		}
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok = l.readStringPart(token.INTERP_START, token.STRING)
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -82,18 +334,21 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Line, tok.Column = line, column
 
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Literal, tok.Type = l.readNumber()
+			tok.Line, tok.Column = line, column
 
 			return tok
 		} else {
-			tok = newToken(token.ILLEGAL, l.ch)
+			tok = token.Token{Type: token.ILLEGAL, Literal: illegalCharacter(l.ch)}
 		}
 	}
 
+	tok.Line, tok.Column = line, column
+
 	l.readChar()
 
 	return tok
@@ -105,61 +360,246 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || ch == '?' || ch == '!'
+func (l *Lexer) skipLineComment() {
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+}
+
+// skipBlockComment consumes a /* ... */ comment, honouring nested block
+// comments. It reports false if the input ends before the comment is closed.
+func (l *Lexer) skipBlockComment() bool {
+	depth := 0
+
+	for l.ch != 0 {
+		if l.ch == '/' && l.peekChar() == '*' {
+			depth++
+			l.readChar()
+		} else if l.ch == '*' && l.peekChar() == '/' {
+			depth--
+			l.readChar()
+
+			if depth == 0 {
+				l.readChar()
+
+				return true
+			}
+		}
+
+		l.readChar()
+	}
+
+	return false
+}
+
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_' || ch == '?' || ch == '!'
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
+	l.fill(l.readPosition)
+
 	if l.readPosition >= len(l.input) {
 		return 0
-	} else {
-		return l.input[l.readPosition]
 	}
+
+	ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+
+	return ch
 }
 
+// readChar advances to the next rune. Invalid UTF-8 decodes as
+// utf8.RuneError one byte at a time, which lexes as ILLEGAL.
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+
+	width := 1
+
+	l.fill(l.readPosition)
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += width
+	l.column += 1
 }
 
+// readIdentifier stops short of "??", "?." and "?[" so that x??y lexes as
+// x ?? y and h?.name as h ?. name. It also stops at a '?' followed by what
+// can start the consequence of a conditional, a digit, '(' or a string, so
+// that a?1:2 lexes as a ? 1 : 2. A name can still end in '?' before
+// whitespace, as in let ok? = true. Digits may follow the first character,
+// as in _1 or utf8.
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for (isLetter(l.ch) || isDigit(l.ch)) && !(l.ch == '?' && questionEndsIdentifier(l.peekChar())) {
 		l.readChar()
 	}
 
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+func questionEndsIdentifier(next rune) bool {
+	return strings.ContainsRune("?.[(\"", next) || isDigit(next)
+}
+
+// readNumber reads an integer or, when the digits are followed by a '.' and
+// another digit, a float literal such as 3.14. Digits may be separated by
+// underscores, as in 1_000_000, and an integer may be written in hex, octal
+// or binary, as in 0xFF, 0o77 or 0b1010. The lexer takes every letter and
+// digit after such a prefix, so that the parser can report 0b12 or 0xG as
+// one malformed literal.
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
+	tokenType := token.TokenType(token.INT)
 
-	for isDigit(l.ch) {
+	if l.ch == '0' && strings.ContainsRune("xXoObB", l.peekChar()) {
 		l.readChar()
+		l.readChar()
+
+		for isDigit(l.ch) || unicode.IsLetter(l.ch) || l.ch == '_' {
+			l.readChar()
+		}
+
+		return l.input[position:l.position], tokenType
+	}
+
+	for isDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+
+		l.readChar()
+
+		for isDigit(l.ch) || l.ch == '_' {
+			l.readChar()
+		}
+	}
+
+	return l.input[position:l.position], tokenType
+}
+
+// readStringPart reads the text of a string literal that follows the
+// current character, a quote or the } ending an interpolation. The token is
+// of type open if the text ends at a ${, which is consumed, and of type
+// closed if it ends at the closing quote.
+func (l *Lexer) readStringPart(open, closed token.TokenType) token.Token {
+	value, interpolated, err := l.readString()
+
+	switch {
+	case err != nil:
+		return token.Token{Type: token.ILLEGAL, Literal: err.Error()}
+	case interpolated:
+		l.readChar()
+		l.interpolations = append(l.interpolations, 0)
+
+		return token.Token{Type: open, Literal: value}
+	default:
+		return token.Token{Type: closed, Literal: value}
 	}
-	return l.input[position:l.position]
 }
 
-func (l *Lexer) readString() string {
-	position := l.position + 1
+// readString reads a double quoted string literal, up to its closing quote or
+// the next ${, and returns its value with escape sequences decoded and
+// whether it stopped at a ${. On an invalid escape it still consumes the rest
+// of the literal, so lexing can carry on after the closing quote. Input that
+// ends before the closing quote is an UNTERMINATED_STRING.
+func (l *Lexer) readString() (string, bool, error) {
+	var out strings.Builder
+	var err error
 
 	for {
 		l.readChar()
 
-		if l.ch == '"' || l.ch == 0 {
+		if l.ch == '"' {
 			break
 		}
+
+		if l.ch == 0 && l.position >= len(l.input) {
+			return out.String(), false, errors.New(UNTERMINATED_STRING)
+		}
+
+		if l.ch == '$' && l.peekChar() == '{' && err == nil {
+			return out.String(), true, nil
+		}
+
+		if l.ch != '\\' {
+			out.WriteRune(l.ch)
+
+			continue
+		}
+
+		l.readChar()
+
+		decoded, escapeErr := l.readEscape()
+
+		if escapeErr != nil && err == nil {
+			err = escapeErr
+		}
+
+		out.WriteString(decoded)
 	}
 
-	return l.input[position:l.position]
+	return out.String(), false, err
+}
+
+// readEscape decodes the escape sequence whose first character, following the
+// backslash, is the current character.
+func (l *Lexer) readEscape() (string, error) {
+	switch l.ch {
+	case 'n':
+		return "\n", nil
+	case 't':
+		return "\t", nil
+	case '"':
+		return "\"", nil
+	case '$':
+		return "$", nil
+	case '\\':
+		return "\\", nil
+	case 'u':
+		if l.peekChar() != '{' {
+			return "", fmt.Errorf("invalid escape sequence: \\u must be followed by {")
+		}
+
+		l.readChar()
+		start := l.readPosition
+
+		for l.peekChar() != '}' && l.peekChar() != '"' && l.peekChar() != 0 {
+			l.readChar()
+		}
+
+		digits := l.input[start:l.readPosition]
+
+		if l.peekChar() != '}' {
+			return "", fmt.Errorf("invalid escape sequence: unterminated \\u{%s", digits)
+		}
+
+		l.readChar()
+
+		code, err := strconv.ParseUint(digits, 16, 32)
+
+		if err != nil || len(digits) == 0 || !utf8.ValidRune(rune(code)) {
+			return "", fmt.Errorf("invalid escape sequence: \\u{%s}", digits)
+		}
+
+		return string(rune(code)), nil
+	case 0:
+		return "", fmt.Errorf("invalid escape sequence at end of input")
+	default:
+		return "", fmt.Errorf("invalid escape sequence: \\%c", l.ch)
+	}
 }
@@ -1,8 +1,12 @@
 package lexer
 
 import (
+	"errors"
+	"io"
 	"monkey/token"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestNextToken(t *testing.T) {
@@ -14,7 +18,7 @@ let add = fn(x, y) {
 };
 
 let result = add(five, ten);
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 
 if (5 < 10) {
@@ -27,6 +31,23 @@ if (5 < 10) {
 10 != 9;
 "foobar"
 "foo bar"
+3.14;
+[1, 2];
+{"foo": "bar"}
+macro(x, y) { x + y; };
+1 <= 2 >= 3;
+a && b || c;
+a ?? b??c;
+a ? b : c;
+h?.a?[0] ok?;
+a?1:b?"x":c?(2):3;
+x |> f;
+a & b | c ^ ~d << 1 >> 2;
+7 % 2 ** 3;
+i++; i--; i += 1; i -= 1; i *= 2; i /= 2;
+f(...xs);
+for (x in xs) {}
+p.name;
 `
 
 	tests := []struct {
@@ -108,6 +129,143 @@ if (5 < 10) {
 		{token.SEMICOLON, ";"},
 		{token.STRING, "foobar"},
 		{token.STRING, "foo bar"},
+		{token.FLOAT, "3.14"},
+		{token.SEMICOLON, ";"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.LBRACE, "{"},
+		{token.STRING, "foo"},
+		{token.COLON, ":"},
+		{token.STRING, "bar"},
+		{token.RBRACE, "}"},
+		{token.MACRO, "macro"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.COMMA, ","},
+		{token.IDENT, "y"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.IDENT, "y"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "1"},
+		{token.LT_EQ, "<="},
+		{token.INT, "2"},
+		{token.GT_EQ, ">="},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.IDENT, "c"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.COALESCE, "??"},
+		{token.IDENT, "b"},
+		{token.COALESCE, "??"},
+		{token.IDENT, "c"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.QUESTION, "?"},
+		{token.IDENT, "b"},
+		{token.COLON, ":"},
+		{token.IDENT, "c"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "h"},
+		{token.OPTIONAL_DOT, "?."},
+		{token.IDENT, "a"},
+		{token.OPTIONAL_LBRACKET, "?["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.IDENT, "ok?"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.QUESTION, "?"},
+		{token.INT, "1"},
+		{token.COLON, ":"},
+		{token.IDENT, "b"},
+		{token.QUESTION, "?"},
+		{token.STRING, "x"},
+		{token.COLON, ":"},
+		{token.IDENT, "c"},
+		{token.QUESTION, "?"},
+		{token.LPAREN, "("},
+		{token.INT, "2"},
+		{token.RPAREN, ")"},
+		{token.COLON, ":"},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PIPE, "|>"},
+		{token.IDENT, "f"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.BIT_AND, "&"},
+		{token.IDENT, "b"},
+		{token.BIT_OR, "|"},
+		{token.IDENT, "c"},
+		{token.BIT_XOR, "^"},
+		{token.BIT_NOT, "~"},
+		{token.IDENT, "d"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.INT, "1"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "7"},
+		{token.PERCENT, "%"},
+		{token.INT, "2"},
+		{token.POWER, "**"},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "i"},
+		{token.INCREMENT, "++"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "i"},
+		{token.DECREMENT, "--"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "i"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "i"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "i"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "i"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "f"},
+		{token.LPAREN, "("},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "xs"},
+		{token.RPAREN, ")"},
+		{token.SEMICOLON, ";"},
+		{token.FOR, "for"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.IN, "in"},
+		{token.IDENT, "xs"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.IDENT, "p"},
+		{token.DOT, "."},
+		{token.IDENT, "name"},
+		{token.SEMICOLON, ";"},
 		{token.EOF, ""},
 	}
 
@@ -127,3 +285,412 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  x + "two";
+`
+
+	tests := []struct {
+		expectedType   token.TokenType
+		expectedLine   int
+		expectedColumn int
+	}{
+		{token.LET, 1, 1},
+		{token.IDENT, 1, 5},
+		{token.ASSIGN, 1, 7},
+		{token.INT, 1, 9},
+		{token.SEMICOLON, 1, 10},
+		{token.IDENT, 2, 3},
+		{token.PLUS, 2, 5},
+		{token.STRING, 2, 7},
+		{token.SEMICOLON, 2, 12},
+		{token.EOF, 3, 1},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("Tests[%d]   -    tokentype wrong. Expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Fatalf("Tests[%d]   -   position wrong. Expected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	input := `let café = "naïve 😀";
+π + ü_x;
+`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1, 1},
+		{token.IDENT, "café", 1, 5},
+		{token.ASSIGN, "=", 1, 10},
+		{token.STRING, "naïve 😀", 1, 12},
+		{token.SEMICOLON, ";", 1, 21},
+		{token.IDENT, "π", 2, 1},
+		{token.PLUS, "+", 2, 3},
+		{token.IDENT, "ü_x", 2, 5},
+		{token.SEMICOLON, ";", 2, 8},
+		{token.EOF, "", 3, 1},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("Tests[%d]   -    tokentype wrong. Expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("Tests[%d]   -   literal wrong. Expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Fatalf("Tests[%d]   -   position wrong. Expected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	l := New("\xff")
+	tok := l.NextToken()
+
+	if tok.Type != token.ILLEGAL {
+		t.Fatalf("tokentype wrong. Expected=%q, got=%q", token.ILLEGAL, tok.Type)
+	}
+
+	if next := l.NextToken(); next.Type != token.EOF {
+		t.Fatalf("expected EOF after invalid byte, got=%q", next.Type)
+	}
+}
+
+func TestIllegalCharacters(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+		expectedColumn  int
+	}{
+		{"1 @ 2", "unexpected character `@`", 3},
+		{"x = #", "unexpected character `#`", 5},
+		{"'c'", "unexpected character `'`, strings are written in double quotes", 1},
+		{"\x01", "unexpected character U+0001", 1},
+		{"\xff", "invalid UTF-8 in input", 1},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		for tok.Type != token.ILLEGAL && tok.Type != token.EOF {
+			tok = l.NextToken()
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("Tests[%d]   -   literal wrong. Expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Line != 1 || tok.Column != tt.expectedColumn {
+			t.Fatalf("Tests[%d]   -   position wrong. Expected=1:%d, got=%d:%d",
+				i, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestUnterminatedString(t *testing.T) {
+	l := New("let s = 1;\n  puts(\"never\n closed")
+
+	for i := 0; i < 7; i++ {
+		l.NextToken()
+	}
+
+	tok := l.NextToken()
+
+	if tok.Type != token.ILLEGAL || tok.Literal != UNTERMINATED_STRING {
+		t.Fatalf("wrong token. got=%+v", tok)
+	}
+
+	if tok.Line != 2 || tok.Column != 8 {
+		t.Fatalf("position wrong. Expected=2:8, got=%d:%d", tok.Line, tok.Column)
+	}
+
+	if next := l.NextToken(); next.Type != token.EOF {
+		t.Fatalf("expected EOF after the string, got=%q", next.Type)
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// a line comment
+let x = 5; // trailing
+/* a block
+   comment */ x /* inline */ + 1;
+/* outer /* nested */ still outer */ x
+10 / 2;
+`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.INT, "10"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("Tests[%d]   -    tokentype wrong. Expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("Tests[%d]   -   literal wrong. Expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := New("let x = 1;\n  /* never /* closed */")
+
+	for i := 0; i < 5; i++ {
+		l.NextToken()
+	}
+
+	tok := l.NextToken()
+
+	if tok.Type != token.ILLEGAL {
+		t.Fatalf("tokentype wrong. Expected=%q, got=%q", token.ILLEGAL, tok.Type)
+	}
+
+	if tok.Literal != "unterminated block comment" {
+		t.Fatalf("literal wrong. got=%q", tok.Literal)
+	}
+
+	if tok.Line != 2 || tok.Column != 3 {
+		t.Fatalf("position wrong. Expected=2:3, got=%d:%d", tok.Line, tok.Column)
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	input := `"a${x + "b${y}"}c${ {} }"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INTERP_START, "a"},
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.INTERP_START, "b"},
+		{token.IDENT, "y"},
+		{token.INTERP_END, ""},
+		{token.INTERP_MID, "c"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.INTERP_END, ""},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{`"a\nb"`, token.STRING, "a\nb"},
+		{`"tab\there"`, token.STRING, "tab\there"},
+		{`"say \"hi\""`, token.STRING, `say "hi"`},
+		{`"back\\slash"`, token.STRING, `back\slash`},
+		{`"\u{48}\u{1F600}"`, token.STRING, "H\U0001F600"},
+		{`"bad \q"`, token.ILLEGAL, `invalid escape sequence: \q`},
+		{`"\u{zz}"`, token.ILLEGAL, `invalid escape sequence: \u{zz}`},
+		{`"\u{110000}"`, token.ILLEGAL, `invalid escape sequence: \u{110000}`},
+		{`"\u48"`, token.ILLEGAL, `invalid escape sequence: \u must be followed by {`},
+		{`"\u{48"`, token.ILLEGAL, `invalid escape sequence: unterminated \u{48`},
+		{`"\${x} $5"`, token.STRING, "${x} $5"},
+		{`"never closed`, token.ILLEGAL, UNTERMINATED_STRING},
+		{`"bad \q and open`, token.ILLEGAL, UNTERMINATED_STRING},
+		{`"ends in \`, token.ILLEGAL, UNTERMINATED_STRING},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("Tests[%d]   -    tokentype wrong. Expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("Tests[%d]   -   literal wrong. Expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Fatalf("Tests[%d]   -   string not fully consumed. got=%q", i, next.Type)
+		}
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"0xFF", []token.Token{{Type: token.INT, Literal: "0xFF"}}},
+		{"0o77;", []token.Token{{Type: token.INT, Literal: "0o77"}, {Type: token.SEMICOLON, Literal: ";"}}},
+		{"0b1010+1", []token.Token{{Type: token.INT, Literal: "0b1010"}, {Type: token.PLUS, Literal: "+"}, {Type: token.INT, Literal: "1"}}},
+		{"1_000_000", []token.Token{{Type: token.INT, Literal: "1_000_000"}}},
+		{"3_141.592_6", []token.Token{{Type: token.FLOAT, Literal: "3_141.592_6"}}},
+		{"0b102", []token.Token{{Type: token.INT, Literal: "0b102"}}},
+		{"0xZZ_1", []token.Token{{Type: token.INT, Literal: "0xZZ_1"}}},
+		{"012", []token.Token{{Type: token.INT, Literal: "012"}}},
+		{"12abc", []token.Token{{Type: token.INT, Literal: "12"}, {Type: token.IDENT, Literal: "abc"}}},
+		{"x1 _2 utf8", []token.Token{{Type: token.IDENT, Literal: "x1"}, {Type: token.IDENT, Literal: "_2"}, {Type: token.IDENT, Literal: "utf8"}}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+
+		for i, expected := range tt.expected {
+			tok := l.NextToken()
+
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Errorf("%s: tokens[%d] wrong. want=%s %q, got=%s %q", tt.input, i, expected.Type, expected.Literal, tok.Type, tok.Literal)
+			}
+		}
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	inputs := []string{
+		"let add = fn(x, y) { x + y; };\nadd(1, 2.5) <= 3 ?? h?.a?[0];",
+		"\"héllo ${name + \"!\"} \\u{1F600}\" /* a /* nested */ comment */ f(...xs) // end",
+		"let 名前 = \"monkey\";\n  @ 'c' \"open",
+		"",
+	}
+
+	for _, input := range inputs {
+		want := New(input)
+		got := NewReader(iotest.OneByteReader(strings.NewReader(input)))
+
+		for {
+			expected, tok := want.NextToken(), got.NextToken()
+
+			if tok != expected {
+				t.Fatalf("%q: wrong token. want=%+v, got=%+v", input, expected, tok)
+			}
+
+			if tok.Type == token.EOF {
+				break
+			}
+		}
+
+		if err := got.Err(); err != nil {
+			t.Errorf("%q: unexpected error %s", input, err)
+		}
+	}
+}
+
+// chunkReader hands out one chunk per Read, counting how many it has.
+type chunkReader struct {
+	chunks []string
+	reads  int
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if r.reads == len(r.chunks) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, r.chunks[r.reads])
+	r.reads++
+
+	return n, nil
+}
+
+func TestNewReaderLexesAsInputArrives(t *testing.T) {
+	r := &chunkReader{chunks: []string{"let x = 5;\n", "x"}}
+	l := NewReader(r)
+
+	for _, expected := range []token.TokenType{token.LET, token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON} {
+		if tok := l.NextToken(); tok.Type != expected {
+			t.Fatalf("wrong token. want=%s, got=%s", expected, tok.Type)
+		}
+	}
+
+	if r.reads != 1 {
+		t.Errorf("read ahead of the tokens returned. reads=%d", r.reads)
+	}
+
+	if tok := l.NextToken(); tok.Type != token.IDENT || tok.Line != 2 {
+		t.Errorf("wrong token after the first chunk. got=%+v", tok)
+	}
+}
+
+func TestNewReaderError(t *testing.T) {
+	failure := errors.New("connection reset")
+	l := NewReader(io.MultiReader(strings.NewReader("let x"), iotest.ErrReader(failure)))
+
+	for _, expected := range []token.TokenType{token.LET, token.IDENT, token.EOF} {
+		if tok := l.NextToken(); tok.Type != expected {
+			t.Fatalf("wrong token. want=%s, got=%s", expected, tok.Type)
+		}
+	}
+
+	if l.Err() != failure {
+		t.Errorf("wrong error. want=%v, got=%v", failure, l.Err())
+	}
+}
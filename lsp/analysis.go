@@ -0,0 +1,446 @@
+package lsp
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/token"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// hoverWidth is how many runes of a let's value a hover shows.
+const hoverWidth = 60
+
+// definition is where a name was bound: by a let, as a function or macro
+// parameter, or as the error of a catch.
+type definition struct {
+	name  *ast.Identifier
+	kind  string // "let", "const", "parameter", "catch" or "loop"
+	value ast.Expression
+	owner string // the signature of the function a parameter belongs to
+}
+
+// analyzer binds every identifier in a program to its definition, with the
+// same scopes the evaluator's resolver uses. A use whose binding has not
+// been seen yet, such as a call to a function defined further down, is
+// looked up again once the whole program is walked.
+type analyzer struct {
+	defs    map[*ast.Identifier]*definition
+	scope   *analysisScope
+	pending []pendingUse
+}
+
+type analysisScope struct {
+	names map[string]*definition
+	outer *analysisScope
+}
+
+type pendingUse struct {
+	ident *ast.Identifier
+	scope *analysisScope
+}
+
+func analyze(program *ast.Program) map[*ast.Identifier]*definition {
+	a := &analyzer{defs: make(map[*ast.Identifier]*definition)}
+
+	a.enter()
+	a.statements(program.Statements)
+
+	for _, use := range a.pending {
+		if def := use.scope.lookup(use.ident.Value); def != nil {
+			a.defs[use.ident] = def
+		}
+	}
+
+	return a.defs
+}
+
+func (s *analysisScope) lookup(name string) *definition {
+	for ; s != nil; s = s.outer {
+		if def, ok := s.names[name]; ok {
+			return def
+		}
+	}
+
+	return nil
+}
+
+func (a *analyzer) enter() {
+	a.scope = &analysisScope{names: make(map[string]*definition), outer: a.scope}
+}
+
+func (a *analyzer) leave() {
+	a.scope = a.scope.outer
+}
+
+func (a *analyzer) declare(def *definition) {
+	a.scope.names[def.name.Value] = def
+	a.defs[def.name] = def
+}
+
+func (a *analyzer) use(ident *ast.Identifier) {
+	if def := a.scope.lookup(ident.Value); def != nil {
+		a.defs[ident] = def
+
+		return
+	}
+
+	a.pending = append(a.pending, pendingUse{ident: ident, scope: a.scope})
+}
+
+func (a *analyzer) statements(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		a.statement(stmt)
+	}
+}
+
+func (a *analyzer) block(block *ast.BlockStatement) {
+	if block != nil {
+		a.statements(block.Statements)
+	}
+}
+
+func (a *analyzer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		a.declare(&definition{name: stmt.Name, kind: stmt.TokenLiteral(), value: stmt.Value})
+		a.expression(stmt.Value)
+	case *ast.DestructuringStatement:
+		a.expression(stmt.Value)
+
+		for _, name := range stmt.Names {
+			a.declare(&definition{name: name, kind: stmt.TokenLiteral()})
+		}
+	case *ast.ReturnStatement:
+		a.expression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		a.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		a.block(stmt)
+	case *ast.WhileStatement:
+		a.expression(stmt.Condition)
+
+		a.enter()
+		a.block(stmt.Body)
+		a.leave()
+	case *ast.ForStatement:
+		a.enter()
+
+		if stmt.Init != nil {
+			a.statement(stmt.Init)
+		}
+
+		a.expression(stmt.Condition)
+		a.expression(stmt.Post)
+
+		a.enter()
+		a.block(stmt.Body)
+		a.leave()
+
+		a.leave()
+	case *ast.ForInStatement:
+		a.expression(stmt.Iterable)
+
+		a.enter()
+		a.declare(&definition{name: stmt.Name, kind: "loop"})
+
+		a.enter()
+		a.block(stmt.Body)
+		a.leave()
+
+		a.leave()
+	}
+}
+
+func (a *analyzer) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		a.use(exp)
+	case *ast.AssignExpression:
+		a.use(exp.Name)
+		a.expression(exp.Value)
+	case *ast.PostfixExpression:
+		a.use(exp.Name)
+	case *ast.IfExpression:
+		a.expression(exp.Condition)
+		a.block(exp.Consequence)
+		a.block(exp.Alternative)
+	case *ast.ConditionalExpression:
+		a.expression(exp.Condition)
+		a.expression(exp.Consequence)
+		a.expression(exp.Alternative)
+	case *ast.SwitchExpression:
+		a.expression(exp.Subject)
+
+		for _, c := range exp.Cases {
+			a.expressions(c.Values)
+			a.block(c.Body)
+		}
+
+		a.block(exp.Default)
+	case *ast.TryExpression:
+		a.block(exp.Block)
+
+		a.enter()
+
+		if exp.Parameter != nil {
+			a.declare(&definition{name: exp.Parameter, kind: "catch"})
+		}
+
+		a.block(exp.Catch)
+		a.leave()
+	case *ast.FunctionLiteral:
+		a.expressions(exp.Defaults)
+		a.function(signature("fn", exp.Name, exp.Parameters, exp.Variadic), exp.Parameters, exp.Body)
+	case *ast.MacroLiteral:
+		a.function(signature("macro", "", exp.Parameters, false), exp.Parameters, exp.Body)
+	case *ast.CallExpression:
+		// Like the resolver, leave quoted code alone: it is data.
+		if exp.Function.TokenLiteral() == "quote" {
+			return
+		}
+
+		a.expression(exp.Function)
+		a.expressions(exp.Arguments)
+
+		for _, arg := range exp.Named {
+			a.expression(arg.Value)
+		}
+	case *ast.PrefixExpression:
+		a.expression(exp.Right)
+	case *ast.SpreadExpression:
+		a.expression(exp.Value)
+	case *ast.InfixExpression:
+		a.expression(exp.Left)
+		a.expression(exp.Right)
+	case *ast.IndexAssignment:
+		a.expression(exp.Left)
+		a.expression(exp.Index)
+		a.expression(exp.Value)
+	case *ast.StringInterpolation:
+		a.expressions(exp.Values)
+	case *ast.ArrayLiteral:
+		a.expressions(exp.Elements)
+	case *ast.IndexExpression:
+		a.expression(exp.Left)
+		a.expression(exp.Index)
+	case *ast.SliceExpression:
+		a.expression(exp.Left)
+		a.expression(exp.Low)
+		a.expression(exp.High)
+	case *ast.HashLiteral:
+		for _, pair := range exp.Pairs {
+			a.expression(pair.Key)
+			a.expression(pair.Value)
+		}
+	}
+}
+
+func (a *analyzer) function(owner string, params []*ast.Identifier, body *ast.BlockStatement) {
+	a.enter()
+
+	for _, param := range params {
+		a.declare(&definition{name: param, kind: "parameter", owner: owner})
+	}
+
+	a.block(body)
+	a.leave()
+}
+
+func (a *analyzer) expressions(exps []ast.Expression) {
+	for _, exp := range exps {
+		a.expression(exp)
+	}
+}
+
+func signature(keyword, name string, params []*ast.Identifier, variadic bool) string {
+	names := make([]string, len(params))
+
+	for i, param := range params {
+		names[i] = param.Value
+	}
+
+	if variadic {
+		names[len(names)-1] = "..." + names[len(names)-1]
+	}
+
+	if name != "" {
+		keyword += " " + name
+	}
+
+	return fmt.Sprintf("%s(%s)", keyword, strings.Join(names, ", "))
+}
+
+// identifierAt returns the identifier whose token covers the lexer's line
+// and column.
+func (d *document) identifierAt(line, column int) *ast.Identifier {
+	var found *ast.Identifier
+
+	ast.Inspect(d.program, func(node ast.Node) bool {
+		ident, ok := node.(*ast.Identifier)
+
+		if ok && ident.Token.Line == line && column >= ident.Token.Column &&
+			column < ident.Token.Column+utf8.RuneCountInString(ident.Value) {
+			found = ident
+		}
+
+		return found == nil
+	})
+
+	return found
+}
+
+func (d *document) hover(pos Position) *Hover {
+	ident := d.identifierAt(pos.Line+1, d.column(pos))
+
+	if ident == nil {
+		return nil
+	}
+
+	var code, description string
+
+	if def, ok := d.defs[ident]; ok {
+		code, description = describe(def)
+	} else if evaluator.IsBuiltin(ident.Value) {
+		code, description = ident.Value, "Builtin function."
+	} else {
+		return nil
+	}
+
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```monkey\n" + code + "\n```\n" + description},
+		Range:    d.tokenRange(ident.Token.Line, ident.Token.Column, utf8.RuneCountInString(ident.Value)),
+	}
+}
+
+// describe returns the code a hover shows for def and a sentence about it.
+func describe(def *definition) (string, string) {
+	line := def.name.Token.Line
+
+	switch def.kind {
+	case "parameter":
+		return def.name.Value, fmt.Sprintf("Parameter of `%s`, line %d.", def.owner, line)
+	case "catch":
+		return def.name.Value, fmt.Sprintf("Error message caught on line %d.", line)
+	case "loop":
+		return def.name.Value, fmt.Sprintf("Loop variable, line %d.", line)
+	}
+
+	switch value := def.value.(type) {
+	case *ast.FunctionLiteral:
+		return signature("fn", def.name.Value, value.Parameters, value.Variadic), fmt.Sprintf("Function defined on line %d.", line)
+	case *ast.MacroLiteral:
+		return def.kind + " " + def.name.Value + " = " + signature("macro", "", value.Parameters, false), fmt.Sprintf("Macro defined on line %d.", line)
+	case nil:
+		return def.kind + " " + def.name.Value, fmt.Sprintf("Defined on line %d.", line)
+	default:
+		return def.kind + " " + def.name.Value + " = " + shorten(value.String()), fmt.Sprintf("Defined on line %d.", line)
+	}
+}
+
+func shorten(source string) string {
+	runes := []rune(source)
+
+	if len(runes) <= hoverWidth {
+		return source
+	}
+
+	return string(runes[:hoverWidth-3]) + "..."
+}
+
+// symbols lists the lets inside node, each with the lets nested inside its
+// value as children.
+func (d *document) symbols(node ast.Node) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		if destructuring, ok := n.(*ast.DestructuringStatement); ok {
+			for _, name := range destructuring.Names {
+				symbols = append(symbols, DocumentSymbol{
+					Name:           name.Value,
+					Kind:           SYMBOL_VARIABLE,
+					Range:          d.nodeRange(destructuring),
+					SelectionRange: d.tokenRange(name.Token.Line, name.Token.Column, utf8.RuneCountInString(name.Value)),
+				})
+			}
+
+			return true
+		}
+
+		let, ok := n.(*ast.LetStatement)
+
+		if !ok || n == node {
+			return true
+		}
+
+		symbol := DocumentSymbol{
+			Name:           let.Name.Value,
+			Kind:           SYMBOL_VARIABLE,
+			Range:          d.nodeRange(let),
+			SelectionRange: d.tokenRange(let.Name.Token.Line, let.Name.Token.Column, utf8.RuneCountInString(let.Name.Value)),
+		}
+
+		switch value := let.Value.(type) {
+		case *ast.FunctionLiteral:
+			symbol.Kind = SYMBOL_FUNCTION
+			symbol.Detail = signature("fn", "", value.Parameters, value.Variadic)
+		case *ast.MacroLiteral:
+			symbol.Kind = SYMBOL_FUNCTION
+			symbol.Detail = signature("macro", "", value.Parameters, false)
+		}
+
+		if let.Value != nil {
+			symbol.Children = d.symbols(let.Value)
+		}
+
+		symbols = append(symbols, symbol)
+
+		return false
+	})
+
+	return symbols
+}
+
+// nodeRange runs from node's token to the end of the last token inside it.
+// The AST keeps no closing delimiters, so a final } or ) is left out.
+func (d *document) nodeRange(node ast.Node) Range {
+	start, _ := nodeToken(node)
+	end := start
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		if tok, ok := nodeToken(n); ok && (tok.Line > end.Line || tok.Line == end.Line && tok.Column > end.Column) {
+			end = tok
+		}
+
+		return true
+	})
+
+	width := utf8.RuneCountInString(end.Literal)
+
+	if end.Type == token.STRING {
+		width += 2
+	}
+
+	return Range{Start: d.position(start.Line, start.Column), End: d.position(end.Line, end.Column+width)}
+}
+
+// nodeToken returns the token every AST node but the program keeps.
+func nodeToken(node ast.Node) (token.Token, bool) {
+	v := reflect.ValueOf(node)
+
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return token.Token{}, false
+	}
+
+	field := v.Elem().FieldByName("Token")
+
+	if !field.IsValid() {
+		return token.Token{}, false
+	}
+
+	tok, ok := field.Interface().(token.Token)
+
+	return tok, ok && tok.Line > 0
+}
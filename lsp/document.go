@@ -0,0 +1,156 @@
+package lsp
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// document is an open file: its text and what the server learned from the
+// last parse of it. Edits are applied to the text as they arrive and the
+// whole document is parsed again, which is quick enough for files of any
+// size Monkey is written in.
+type document struct {
+	text    string
+	lines   []string
+	program *ast.Program
+	errors  []parser.Error
+	defs    map[*ast.Identifier]*definition
+}
+
+func newDocument(text string) *document {
+	d := &document{}
+	d.setText(text)
+
+	return d
+}
+
+func (d *document) setText(text string) {
+	d.text = text
+	d.lines = strings.Split(text, "\n")
+
+	p := parser.New(lexer.New(text))
+	d.program = p.ParseProgram()
+	d.errors = p.ErrorDetails()
+	d.defs = analyze(d.program)
+}
+
+// apply makes the edits of one didChange notification, in order.
+func (d *document) apply(changes []contentChange) error {
+	text := d.text
+
+	for _, change := range changes {
+		if change.Range == nil {
+			text = change.Text
+
+			continue
+		}
+
+		start, end := offset(text, change.Range.Start), offset(text, change.Range.End)
+
+		if start > end {
+			return fmt.Errorf("edit range ends before it starts")
+		}
+
+		text = text[:start] + change.Text + text[end:]
+	}
+
+	d.setText(text)
+
+	return nil
+}
+
+func (d *document) diagnostics() []Diagnostic {
+	diagnostics := []Diagnostic{}
+
+	for _, err := range d.errors {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    d.tokenRange(err.Token.Line, err.Token.Column, max(utf8.RuneCountInString(err.Token.Literal), 1)),
+			Severity: SEVERITY_ERROR,
+			Source:   "monkey",
+			Message:  err.Message,
+		})
+	}
+
+	return diagnostics
+}
+
+// tokenRange is the range of a token of width runes starting at the
+// lexer's 1-based line and column.
+func (d *document) tokenRange(line, column, width int) Range {
+	return Range{Start: d.position(line, column), End: d.position(line, column+width)}
+}
+
+// position converts a lexer position, which counts runes from 1, to an LSP
+// position, which counts UTF-16 code units from 0.
+func (d *document) position(line, column int) Position {
+	if line < 1 || line > len(d.lines) {
+		return Position{Line: max(line-1, 0)}
+	}
+
+	units := 0
+	runes := 0
+
+	for _, r := range d.lines[line-1] {
+		if runes == column-1 {
+			break
+		}
+
+		units += utf16.RuneLen(r)
+		runes++
+	}
+
+	return Position{Line: line - 1, Character: units + max(column-1-runes, 0)}
+}
+
+// column converts an LSP position to the lexer's 1-based rune column.
+func (d *document) column(pos Position) int {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return 0
+	}
+
+	line := d.lines[pos.Line]
+
+	return utf8.RuneCountInString(line[:lineOffset(line, pos.Character)]) + 1
+}
+
+// offset converts an LSP position to a byte offset into text. Positions past
+// the end of a line or of the text are clamped to it.
+func offset(text string, pos Position) int {
+	start := 0
+
+	for line := 0; line < pos.Line; line++ {
+		next := strings.IndexByte(text[start:], '\n')
+
+		if next < 0 {
+			return len(text)
+		}
+
+		start += next + 1
+	}
+
+	line := text[start:]
+
+	if end := strings.IndexByte(line, '\n'); end >= 0 {
+		line = line[:end]
+	}
+
+	return start + lineOffset(line, pos.Character)
+}
+
+// lineOffset is the byte offset of the UTF-16 code unit units into line.
+func lineOffset(line string, units int) int {
+	for i, r := range line {
+		if units <= 0 {
+			return i
+		}
+
+		units -= utf16.RuneLen(r)
+	}
+
+	return len(line)
+}
@@ -0,0 +1,164 @@
+package lsp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyChanges(t *testing.T) {
+	doc := newDocument("let x = 1;\nlet y = x + 2;")
+
+	changes := []contentChange{
+		{Range: &Range{Start: Position{0, 8}, End: Position{0, 9}}, Text: "10"},
+		{Range: &Range{Start: Position{1, 4}, End: Position{1, 5}}, Text: "why"},
+		{Range: &Range{Start: Position{1, 40}, End: Position{1, 40}}, Text: "\nwhy"},
+	}
+
+	if err := doc.apply(changes); err != nil {
+		t.Fatalf("apply failed: %s", err)
+	}
+
+	expected := "let x = 10;\nlet why = x + 2;\nwhy"
+
+	if doc.text != expected {
+		t.Errorf("wrong text. want=%q, got=%q", expected, doc.text)
+	}
+
+	doc.apply([]contentChange{{Text: "let = 1;"}})
+
+	if doc.text != "let = 1;" || len(doc.errors) == 0 {
+		t.Errorf("a full change was not applied and parsed. text=%q, errors=%v", doc.text, doc.errors)
+	}
+}
+
+func TestPositions(t *testing.T) {
+	// é is one UTF-16 unit and 😀 is two, but each is one rune to the lexer.
+	doc := newDocument("let é = \"😀\"; x")
+
+	tests := []struct {
+		column   int
+		expected Position
+	}{
+		{1, Position{0, 0}},
+		{5, Position{0, 4}},
+		{9, Position{0, 8}},
+		{10, Position{0, 9}},
+		{11, Position{0, 11}},
+		{15, Position{0, 15}},
+	}
+
+	for _, tt := range tests {
+		pos := doc.position(1, tt.column)
+
+		if pos != tt.expected {
+			t.Errorf("position(1, %d) wrong. want=%+v, got=%+v", tt.column, tt.expected, pos)
+		}
+
+		if column := doc.column(pos); column != tt.column {
+			t.Errorf("column(%+v) wrong. want=%d, got=%d", pos, tt.column, column)
+		}
+	}
+
+	if got := offset(doc.text, Position{0, 11}); got != strings.Index(doc.text, "\";") {
+		t.Errorf("wrong offset after a surrogate pair. got=%d", got)
+	}
+}
+
+func TestHover(t *testing.T) {
+	input := `let add = fn(a, b) { a + b };
+let total = add(1, twice(2));
+let twice = fn(n) { n * 2 };
+try { len(total) } catch (e) { e };
+let m = macro(x) { quote(unquote(x)) };
+let long = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa";
+unknown`
+
+	doc := newDocument(input)
+
+	tests := []struct {
+		line, character int
+		expected        string
+	}{
+		{0, 5, "```monkey\nfn add(a, b)\n```\nFunction defined on line 1."},
+		{0, 21, "```monkey\na\n```\nParameter of `fn add(a, b)`, line 1."},
+		{1, 13, "```monkey\nfn add(a, b)\n```\nFunction defined on line 1."},
+		{1, 19, "```monkey\nfn twice(n)\n```\nFunction defined on line 3."},
+		{3, 7, "```monkey\nlen\n```\nBuiltin function."},
+		{3, 11, "```monkey\nlet total = add(1, twice(2))\n```\nDefined on line 2."},
+		{3, 31, "```monkey\ne\n```\nError message caught on line 4."},
+		{4, 4, "```monkey\nlet m = macro(x)\n```\nMacro defined on line 5."},
+		{5, 5, "```monkey\nlet long = aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa...\n```\nDefined on line 6."},
+	}
+
+	for _, tt := range tests {
+		hover := doc.hover(Position{tt.line, tt.character})
+
+		if hover == nil {
+			t.Errorf("no hover at %d:%d", tt.line, tt.character)
+
+			continue
+		}
+
+		if hover.Contents.Value != tt.expected {
+			t.Errorf("wrong hover at %d:%d. want=%q, got=%q", tt.line, tt.character, tt.expected, hover.Contents.Value)
+		}
+	}
+
+	for _, pos := range []Position{{6, 2}, {0, 1}, {0, 28}} {
+		if hover := doc.hover(pos); hover != nil {
+			t.Errorf("unexpected hover at %+v: %q", pos, hover.Contents.Value)
+		}
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	input := `let x = 1;
+let outer = fn(a) {
+	let inner = fn() { a };
+	let y = 2;
+	inner() + y
+};`
+
+	symbols := newDocument(input).symbols(newDocument(input).program)
+
+	if len(symbols) != 2 {
+		t.Fatalf("wrong number of symbols. got=%+v", symbols)
+	}
+
+	x, outer := symbols[0], symbols[1]
+
+	if x.Name != "x" || x.Kind != SYMBOL_VARIABLE || x.Range != (Range{Position{0, 0}, Position{0, 9}}) {
+		t.Errorf("wrong symbol for x. got=%+v", x)
+	}
+
+	if outer.Name != "outer" || outer.Kind != SYMBOL_FUNCTION || outer.Detail != "fn(a)" {
+		t.Errorf("wrong symbol for outer. got=%+v", outer)
+	}
+
+	if outer.SelectionRange != (Range{Position{1, 4}, Position{1, 9}}) {
+		t.Errorf("wrong selection range for outer. got=%+v", outer.SelectionRange)
+	}
+
+	if len(outer.Children) != 2 || outer.Children[0].Name != "inner" || outer.Children[1].Name != "y" {
+		t.Errorf("wrong children of outer. got=%+v", outer.Children)
+	}
+}
+
+func TestBrokenSource(t *testing.T) {
+	input := `let f = fn(a, b) { if (a > b) { a } else { try { b[0:1] } catch (e) { e } } };
+for (let i = 0; i < 3; i++) { while (false) { f(i, {"k": [1, 2]}) } }`
+
+	// Every prefix of the program, most of them broken, must be handled.
+	for i := range input {
+		doc := newDocument(input[:i])
+
+		doc.diagnostics()
+		doc.symbols(doc.program)
+
+		for _, line := range []int{0, 1} {
+			for character := 0; character < 90; character += 3 {
+				doc.hover(Position{line, character})
+			}
+		}
+	}
+}
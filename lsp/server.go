@@ -0,0 +1,203 @@
+// Package lsp is a language server for Monkey. It speaks the Language
+// Server Protocol over a pair of streams, normally stdin and stdout, and
+// gives editors parse errors as diagnostics, hovers describing what a name
+// is bound to, and an outline of each file's let statements.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNoShutdown is returned by Run when the client exits without asking
+// the server to shut down first.
+var ErrNoShutdown = errors.New("exit before shutdown")
+
+type Server struct {
+	in       *bufio.Reader
+	out      io.Writer
+	docs     map[string]*document
+	shutdown bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{in: bufio.NewReader(in), out: out, docs: make(map[string]*document)}
+}
+
+// Run handles messages until the client sends exit or closes the input.
+func (s *Server) Run() error {
+	for {
+		body, err := readMessage(s.in)
+
+		if err == io.EOF {
+			return ErrNoShutdown
+		}
+
+		if err != nil {
+			return err
+		}
+
+		var msg message
+
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return fmt.Errorf("bad message: %s", err)
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return ErrNoShutdown
+			}
+
+			return nil
+		}
+
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// handle answers a request or acts on a notification. Only failures to
+// write to the client are returned; anything wrong with the message itself
+// is reported back to the client.
+func (s *Server) handle(msg message) error {
+	if s.shutdown {
+		return s.fail(msg, INVALID_REQUEST, "the server is shutting down")
+	}
+
+	switch msg.Method {
+	case "initialize":
+		return s.reply(msg, map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync": map[string]interface{}{
+					"openClose": true,
+					"change":    SYNC_INCREMENTAL,
+				},
+				"hoverProvider":          true,
+				"documentSymbolProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "monkey-lsp"},
+		})
+	case "shutdown":
+		s.shutdown = true
+
+		return s.reply(msg, nil)
+	case "textDocument/didOpen":
+		var params didOpenParams
+
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil
+		}
+
+		s.docs[params.TextDocument.URI] = newDocument(params.TextDocument.Text)
+
+		return s.publishDiagnostics(params.TextDocument.URI)
+	case "textDocument/didChange":
+		var params didChangeParams
+
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil
+		}
+
+		doc, ok := s.docs[params.TextDocument.URI]
+
+		if !ok || doc.apply(params.ContentChanges) != nil {
+			return nil
+		}
+
+		return s.publishDiagnostics(params.TextDocument.URI)
+	case "textDocument/didClose":
+		var params textDocumentParams
+
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil
+		}
+
+		delete(s.docs, params.TextDocument.URI)
+
+		return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+
+		doc, err := s.document(msg, &params)
+
+		if err != nil {
+			return s.fail(msg, INVALID_PARAMS, err.Error())
+		}
+
+		if hover := doc.hover(params.Position); hover != nil {
+			return s.reply(msg, hover)
+		}
+
+		return s.reply(msg, nil)
+	case "textDocument/documentSymbol":
+		var params textDocumentParams
+
+		doc, err := s.document(msg, &params)
+
+		if err != nil {
+			return s.fail(msg, INVALID_PARAMS, err.Error())
+		}
+
+		return s.reply(msg, doc.symbols(doc.program))
+	default:
+		return s.fail(msg, METHOD_NOT_FOUND, "unsupported method "+msg.Method)
+	}
+}
+
+// document decodes the params of a request about an open document into
+// params, which must hold a textDocument field, and returns the document.
+func (s *Server) document(msg message, params interface{ uri() string }) (*document, error) {
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		return nil, err
+	}
+
+	doc, ok := s.docs[params.uri()]
+
+	if !ok {
+		return nil, fmt.Errorf("%s is not open", params.uri())
+	}
+
+	return doc, nil
+}
+
+func (p *textDocumentParams) uri() string         { return p.TextDocument.URI }
+func (p *textDocumentPositionParams) uri() string { return p.TextDocument.URI }
+
+func (s *Server) publishDiagnostics(uri string) error {
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: s.docs[uri].diagnostics(),
+	})
+}
+
+func (s *Server) reply(msg message, result interface{}) error {
+	if msg.ID == nil {
+		return nil
+	}
+
+	return writeMessage(s.out, map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": result})
+}
+
+// fail answers a request with an error. Notifications get no answer.
+func (s *Server) fail(msg message, code int, text string) error {
+	if msg.ID == nil {
+		return nil
+	}
+
+	return writeMessage(s.out, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      msg.ID,
+		"error":   responseError{Code: code, Message: text},
+	})
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	return writeMessage(s.out, map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
@@ -0,0 +1,110 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	var in bytes.Buffer
+
+	send := func(v map[string]interface{}) {
+		v["jsonrpc"] = "2.0"
+
+		if err := writeMessage(&in, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	uri := "file:///tmp/test.mky"
+	doc := map[string]interface{}{"uri": uri}
+
+	send(map[string]interface{}{"id": 1, "method": "initialize", "params": map[string]interface{}{}})
+	send(map[string]interface{}{"method": "initialized", "params": map[string]interface{}{}})
+	send(map[string]interface{}{"method": "textDocument/didOpen", "params": map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "monkey", "version": 1, "text": "let x = ;"},
+	}})
+	send(map[string]interface{}{"method": "textDocument/didChange", "params": map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []interface{}{
+			map[string]interface{}{
+				"range": Range{Start: Position{0, 8}, End: Position{0, 8}},
+				"text":  "fn(a) { a }",
+			},
+		},
+	}})
+	send(map[string]interface{}{"id": "hover", "method": "textDocument/hover", "params": map[string]interface{}{
+		"textDocument": doc, "position": Position{0, 4},
+	}})
+	send(map[string]interface{}{"id": 3, "method": "textDocument/documentSymbol", "params": map[string]interface{}{
+		"textDocument": doc,
+	}})
+	send(map[string]interface{}{"id": 4, "method": "textDocument/definition", "params": map[string]interface{}{}})
+	send(map[string]interface{}{"id": 5, "method": "shutdown"})
+	send(map[string]interface{}{"method": "exit"})
+
+	var out bytes.Buffer
+
+	if err := NewServer(&in, &out).Run(); err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+
+	expected := []string{
+		`{"id":1,"jsonrpc":"2.0","result":{"capabilities":{"documentSymbolProvider":true,"hoverProvider":true,"textDocumentSync":{"change":2,"openClose":true}},"serverInfo":{"name":"monkey-lsp"}}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"file:///tmp/test.mky","diagnostics":[{"range":{"start":{"line":0,"character":8},"end":{"line":0,"character":9}},"severity":1,"source":"monkey","message":"no prefix parse function for ; found"}]}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":"file:///tmp/test.mky","diagnostics":[]}}`,
+		`{"id":"hover","jsonrpc":"2.0","result":{"contents":{"kind":"markdown","value":"` + "```monkey\\nfn x(a)\\n```\\nFunction defined on line 1." + `"},"range":{"start":{"line":0,"character":4},"end":{"line":0,"character":5}}}}`,
+		`{"id":3,"jsonrpc":"2.0","result":[{"name":"x","detail":"fn(a)","kind":12,"range":{"start":{"line":0,"character":0},"end":{"line":0,"character":17}},"selectionRange":{"start":{"line":0,"character":4},"end":{"line":0,"character":5}}}]}`,
+		`{"error":{"code":-32601,"message":"unsupported method textDocument/definition"},"id":4,"jsonrpc":"2.0"}`,
+		`{"id":5,"jsonrpc":"2.0","result":null}`,
+	}
+
+	r := bufio.NewReader(&out)
+
+	for i, want := range expected {
+		body, err := readMessage(r)
+
+		if err != nil {
+			t.Fatalf("message %d: %s", i, err)
+		}
+
+		if !jsonEqual(t, body, want) {
+			t.Errorf("message %d wrong.\nwant=%s\ngot= %s", i, want, body)
+		}
+	}
+
+	if _, err := readMessage(r); err == nil {
+		t.Errorf("unexpected extra message")
+	}
+}
+
+func TestServerExitWithoutShutdown(t *testing.T) {
+	var in bytes.Buffer
+
+	writeMessage(&in, map[string]interface{}{"jsonrpc": "2.0", "method": "exit"})
+
+	if err := NewServer(&in, &bytes.Buffer{}).Run(); err != ErrNoShutdown {
+		t.Errorf("wrong error. want=%v, got=%v", ErrNoShutdown, err)
+	}
+}
+
+func jsonEqual(t *testing.T, got []byte, want string) bool {
+	t.Helper()
+
+	var a, b interface{}
+
+	if err := json.Unmarshal(got, &a); err != nil {
+		t.Fatalf("bad JSON %s: %s", got, err)
+	}
+
+	if err := json.Unmarshal([]byte(want), &b); err != nil {
+		t.Fatalf("bad expected JSON %s: %s", want, err)
+	}
+
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+
+	return bytes.Equal(x, y)
+}
@@ -1,15 +1,109 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"golang.org/x/term"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
+	"monkey/lsp"
+	"monkey/object"
 	"monkey/repl"
 	"os"
+	"os/signal"
 	"os/user"
 )
 
+var engine = flag.String("engine", repl.ENGINE_EVAL, "use 'vm' or 'eval'")
+var optimize = flag.Bool("optimize", false, "fold constants and drop dead code before running")
+var dumpAST = flag.Bool("dump-ast", false, "print the script's AST as JSON instead of running it")
+var trace = flag.Bool("trace", false, "print every node the evaluator runs, with its result (eval engine only)")
+var debug = flag.Bool("debug", false, "pause at breakpoint() calls and step through the program (eval engine only)")
+var profileCalls = flag.Bool("profile", false, "report how often each function was called, and the time spent in it, after the script finishes (eval engine only)")
+var cover = flag.Bool("cover", false, "print the script, and the modules it imports, with how often each line ran (eval engine only)")
+var coverProfile = flag.String("coverprofile", "", "write which lines of the script ran to this file, as an LCOV tracefile (eval engine only)")
+var noNetwork = flag.Bool("no-network", false, "make httpGet and httpPost fail instead of reaching the network")
+var noColor = flag.Bool("no-color", false, "print without colors, as is done anyway when output is not a terminal or NO_COLOR is set")
+var noBanner = flag.Bool("no-banner", false, "leave the monkey face out of error messages")
+var prompt = flag.String("prompt", repl.PROMPT, "the REPL's prompt")
+var noGreeting = flag.Bool("no-greeting", false, "start the REPL without greeting the user")
+var maxDepth = flag.Int("max-depth", 0, "fail calls nested more deeply than this, 0 for no limit (eval engine only)")
+
 func main() {
+	flag.Parse()
+
+	object.SetNetwork(!*noNetwork)
+
+	options := repl.Options{Engine: *engine, Optimize: *optimize, Debug: *debug, Trace: *trace, Profile: *profileCalls, NoBanner: *noBanner}
+	options.Prompt, options.MaxDepth = *prompt, *maxDepth
+	options.Cover, options.CoverProfile = *cover, *coverProfile
+	options.Color = !*noColor && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout) && isTerminal(os.Stderr)
+
+	if flag.Arg(0) == "fmt" {
+		os.Exit(runFmt(flag.Args()[1:], os.Stdout, os.Stderr))
+	}
+
+	if flag.Arg(0) == "test" {
+		os.Exit(runTest(flag.Args()[1:], os.Stdout, os.Stderr))
+	}
+
+	if flag.Arg(0) == "build" {
+		os.Exit(runBuild(flag.Args()[1:], *optimize, os.Stderr))
+	}
+
+	if flag.Arg(0) == "lsp" {
+		if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+			fmt.Fprintln(os.Stderr, "monkey lsp:", err)
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	}
+
+	if *dumpAST {
+		if flag.NArg() == 0 {
+			fmt.Fprintln(os.Stderr, "usage: monkey --dump-ast script.mky")
+			os.Exit(2)
+		}
+
+		os.Exit(runDumpAST(flag.Arg(0), os.Stdout, os.Stderr))
+	}
+
+	// Ctrl-C stops the program being run rather than the whole process.
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	options.Interrupts = interrupts
+
+	if flag.Arg(0) == "run" {
+		if flag.NArg() < 2 {
+			fmt.Fprintln(os.Stderr, "usage: monkey run script.mkyc [args...]")
+			os.Exit(2)
+		}
+
+		object.SetArgs(flag.Args()[2:])
+		os.Exit(repl.RunBytecode(flag.Arg(1), options, os.Stdout, os.Stderr))
+	}
+
+	if flag.NArg() > 0 {
+		object.SetArgs(flag.Args()[1:])
+		os.Exit(repl.RunFile(flag.Arg(0), options, os.Stdout, os.Stderr))
+	}
+
+	if !*noGreeting {
+		greet()
+	}
+
+	options.Startup = repl.DefaultStartupPath()
+
+	os.Exit(repl.Start(os.Stdin, os.Stdout, options))
+}
+
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// greet welcomes the user to the REPL by name.
+func greet() {
 	user, err := user.Current()
 
 	if err != nil {
@@ -21,6 +115,4 @@ func main() {
 
 	fmt.Printf("Hello %s! This is the Monkey programming language!\n", userName)
 	fmt.Printf("Feel free to type in commands\n")
-
-	repl.Start(os.Stdin, os.Stdout)
 }
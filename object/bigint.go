@@ -0,0 +1,205 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// BigInt is an integer too large for an Integer. Integer arithmetic that
+// would overflow an int64 produces one instead of wrapping around, and
+// BigInts mix freely with Integers. A BigInt never holds a value that fits
+// in an int64: results are passed through NormalizeInt, so 1 and a BigInt
+// of 1 can not both exist.
+type BigInt struct {
+	Value *big.Int
+}
+
+func (b *BigInt) Type() ObjectType { return BIGINT_OBJ }
+func (b *BigInt) Inspect() string  { return b.Value.String() }
+
+// Float returns the nearest float64 to b, or an infinity if b is out of
+// range.
+func (b *BigInt) Float() float64 {
+	f, _ := new(big.Float).SetInt(b.Value).Float64()
+
+	return f
+}
+
+// maxBigIntBits bounds the size of a power or a left shift, so that
+// something like 2 ** 1000000000000 fails instead of exhausting memory.
+const maxBigIntBits = 1 << 24
+
+// NormalizeInt returns value as an Integer if it fits in an int64 and as a
+// BigInt otherwise.
+func NormalizeInt(value *big.Int) Object {
+	if value.IsInt64() {
+		return NewInteger(value.Int64())
+	}
+
+	return &BigInt{Value: value}
+}
+
+// ToBigInt returns the value of an Integer or BigInt as a big.Int, which
+// the caller must not modify.
+func ToBigInt(obj Object) (*big.Int, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return big.NewInt(obj.Value), true
+	case *BigInt:
+		return obj.Value, true
+	default:
+		return nil, false
+	}
+}
+
+// BigArithmetic applies an arithmetic operator to two integers of any
+// size. Division and remainder truncate toward zero, as they do for
+// Integers, and a negative power is a Float. The bitwise operators treat
+// a negative integer as two's complement with infinitely many leading
+// ones, so -1 & x is x and -8 >> 1 is -4.
+func BigArithmetic(operator string, a, b *big.Int) (Object, error) {
+	result := new(big.Int)
+
+	switch operator {
+	case "+":
+		result.Add(a, b)
+	case "-":
+		result.Sub(a, b)
+	case "*":
+		result.Mul(a, b)
+	case "/":
+		if b.Sign() == 0 {
+			return nil, fmt.Errorf("division by zero: %s / 0", a)
+		}
+
+		result.Quo(a, b)
+	case "%":
+		if b.Sign() == 0 {
+			return nil, fmt.Errorf("division by zero: %s %% 0", a)
+		}
+
+		result.Rem(a, b)
+	case "**":
+		if b.Sign() < 0 {
+			base, _ := new(big.Float).SetInt(a).Float64()
+			exponent, _ := new(big.Float).SetInt(b).Float64()
+
+			return &Float{Value: math.Pow(base, exponent)}, nil
+		}
+
+		if a.BitLen() > 1 && (!b.IsInt64() || b.Int64() > maxBigIntBits/int64(a.BitLen()-1)) {
+			return nil, fmt.Errorf("integer too large: %s ** %s", a, b)
+		}
+
+		result.Exp(a, b, nil)
+	case "&":
+		result.And(a, b)
+	case "|":
+		result.Or(a, b)
+	case "^":
+		result.Xor(a, b)
+	case "<<":
+		if b.Sign() < 0 {
+			return nil, fmt.Errorf("negative shift count: %s << %s", a, b)
+		}
+
+		if a.Sign() != 0 && (!b.IsInt64() || int64(a.BitLen())+b.Int64() > maxBigIntBits) {
+			return nil, fmt.Errorf("integer too large: %s << %s", a, b)
+		}
+
+		if a.Sign() != 0 {
+			result.Lsh(a, uint(b.Int64()))
+		}
+	case ">>":
+		if b.Sign() < 0 {
+			return nil, fmt.Errorf("negative shift count: %s >> %s", a, b)
+		}
+
+		// Shifting by more than a's length leaves only its sign.
+		shift := uint(a.BitLen() + 1)
+
+		if b.IsInt64() && b.Int64() < int64(shift) {
+			shift = uint(b.Int64())
+		}
+
+		result.Rsh(a, shift)
+	default:
+		return nil, fmt.Errorf("unknown integer operator: %s", operator)
+	}
+
+	return NormalizeInt(result), nil
+}
+
+// AddInt, SubInt, MulInt, ShiftLeftInt, DivInt and PowInt return the
+// result of an Integer operation and whether it fit in an int64.
+
+func AddInt(a, b int64) (int64, bool) {
+	c := a + b
+
+	return c, (c > a) == (b > 0)
+}
+
+func SubInt(a, b int64) (int64, bool) {
+	c := a - b
+
+	return c, (c < a) == (b > 0)
+}
+
+func MulInt(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+
+	c := a * b
+
+	if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) || c/b != a {
+		return c, false
+	}
+
+	return c, true
+}
+
+// ShiftLeftInt shifts a left by a count from 0 to 63 that does not push
+// any of its bits out.
+func ShiftLeftInt(a, b int64) (int64, bool) {
+	if b < 0 || b > 63 {
+		return 0, false
+	}
+
+	c := a << b
+
+	return c, c>>b == a
+}
+
+func DivInt(a, b int64) (int64, bool) {
+	if a == math.MinInt64 && b == -1 {
+		return 0, false
+	}
+
+	return a / b, true
+}
+
+// PowInt raises base to a non-negative exponent by repeated squaring.
+func PowInt(base, exponent int64) (int64, bool) {
+	result := int64(1)
+	ok := true
+
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			if result, ok = MulInt(result, base); !ok {
+				return 0, false
+			}
+		}
+
+		exponent >>= 1
+
+		if exponent > 0 {
+			if base, ok = MulInt(base, base); !ok {
+				return 0, false
+			}
+		}
+	}
+
+	return result, true
+}
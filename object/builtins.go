@@ -0,0 +1,1185 @@
+package object
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Builtins is shared by the evaluator and the VM. The VM refers to builtins
+// by their index in this slice, so new entries must only ever be appended.
+// A builtin returning nil means it produced no value; callers turn that into
+// NULL. Booleans are always the shared TRUE and FALSE.
+var Builtins = []struct {
+	Name    string
+	Builtin *Builtin
+}{
+	{
+		"len",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *Array:
+				return NewInteger(int64(len(arg.Elements)))
+			case *Range:
+				return NewInteger(arg.Len())
+			case *String:
+				return NewInteger(int64(utf8.RuneCountInString(arg.Value)))
+			case *Bytes:
+				return NewInteger(int64(len(arg.Value)))
+			case *Set:
+				return NewInteger(int64(len(arg.Keys)))
+			default:
+				return newError("argument to `len` not supported, got %s", args[0].Type())
+			}
+		},
+		},
+	},
+	{
+		"puts",
+		withIO(func(streams *IO, args ...Object) Object {
+			for _, arg := range args {
+				fmt.Fprintln(streams.writer(), Display(arg))
+			}
+
+			return nil
+		}),
+	},
+	{
+		"first",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if r, ok := args[0].(*Range); ok {
+				return rangeElement(r, 0)
+			}
+
+			if args[0].Type() != ARRAY_OBJ {
+				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
+			}
+
+			arr := args[0].(*Array)
+
+			if len(arr.Elements) > 0 {
+				return arr.Elements[0]
+			}
+
+			return nil
+		},
+		},
+	},
+	{
+		"last",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if r, ok := args[0].(*Range); ok {
+				return rangeElement(r, r.Len()-1)
+			}
+
+			if args[0].Type() != ARRAY_OBJ {
+				return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
+			}
+
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+
+			if length > 0 {
+				return arr.Elements[length-1]
+			}
+
+			return nil
+		},
+		},
+	},
+	{
+		"rest",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			arr, err := arrayArgument("rest", args[0])
+
+			if err != nil {
+				return err
+			}
+			length := len(arr.Elements)
+
+			if length > 0 {
+				newElements := make([]Object, length-1)
+				copy(newElements, arr.Elements[1:length])
+
+				return &Array{Elements: newElements}
+			}
+
+			return nil
+		},
+		},
+	},
+	{
+		"push",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			arr, err := arrayArgument("push", args[0])
+
+			if err != nil {
+				return err
+			}
+			length := len(arr.Elements)
+
+			newElements := make([]Object, length+1)
+			copy(newElements, arr.Elements)
+			newElements[length] = args[1]
+
+			return &Array{Elements: newElements}
+		},
+		},
+	},
+	{
+		"split",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			str, sep, err := twoStrings("split", args)
+
+			if err != nil {
+				return err
+			}
+
+			parts := strings.Split(str, sep)
+			elements := make([]Object, len(parts))
+
+			for i, part := range parts {
+				elements[i] = &String{Value: part}
+			}
+
+			return &Array{Elements: elements}
+		},
+		},
+	},
+	{
+		"join",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			if args[0].Type() != ARRAY_OBJ {
+				return newError("first argument to `join` must be ARRAY, got %s", args[0].Type())
+			}
+
+			if args[1].Type() != STRING_OBJ {
+				return newError("second argument to `join` must be STRING, got %s", args[1].Type())
+			}
+
+			arr := args[0].(*Array)
+			parts := make([]string, len(arr.Elements))
+
+			for i, el := range arr.Elements {
+				str, ok := el.(*String)
+
+				if !ok {
+					return newError("argument to `join` must be ARRAY of STRING, got %s element", el.Type())
+				}
+
+				parts[i] = str.Value
+			}
+
+			return &String{Value: strings.Join(parts, args[1].(*String).Value)}
+		},
+		},
+	},
+	{
+		"contains",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *Array:
+				return NativeBool(indexOfElement(arg, args[1]) != -1)
+			case *Range:
+				return NativeBool(rangeIndexOf(arg, args[1]) != -1)
+			case *Set:
+				return NativeBool(arg.Has(args[1]))
+			case *String:
+				sub, ok := args[1].(*String)
+
+				if !ok {
+					return newError("second argument to `contains` must be STRING, got %s", args[1].Type())
+				}
+
+				return NativeBool(strings.Contains(arg.Value, sub.Value))
+			default:
+				return newError("argument to `contains` not supported, got %s", args[0].Type())
+			}
+		},
+		},
+	},
+	{
+		"replace",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=3", len(args))
+			}
+
+			for _, arg := range args {
+				if arg.Type() != STRING_OBJ {
+					return newError("arguments to `replace` must be STRING, got %s", arg.Type())
+				}
+			}
+
+			str := args[0].(*String).Value
+			old := args[1].(*String).Value
+			new := args[2].(*String).Value
+
+			return &String{Value: strings.ReplaceAll(str, old, new)}
+		},
+		},
+	},
+	{
+		"trim",
+		&Builtin{Fn: func(args ...Object) Object {
+			str, err := oneString("trim", args)
+
+			if err != nil {
+				return err
+			}
+
+			return &String{Value: strings.TrimSpace(str)}
+		},
+		},
+	},
+	{
+		"upper",
+		&Builtin{Fn: func(args ...Object) Object {
+			str, err := oneString("upper", args)
+
+			if err != nil {
+				return err
+			}
+
+			return &String{Value: strings.ToUpper(str)}
+		},
+		},
+	},
+	{
+		"lower",
+		&Builtin{Fn: func(args ...Object) Object {
+			str, err := oneString("lower", args)
+
+			if err != nil {
+				return err
+			}
+
+			return &String{Value: strings.ToLower(str)}
+		},
+		},
+	},
+	{
+		"indexOf",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *Array:
+				return NewInteger(int64(indexOfElement(arg, args[1])))
+			case *Range:
+				return NewInteger(rangeIndexOf(arg, args[1]))
+			case *String:
+				sub, ok := args[1].(*String)
+
+				if !ok {
+					return newError("second argument to `indexOf` must be STRING, got %s", args[1].Type())
+				}
+
+				return NewInteger(int64(runeIndex(arg.Value, sub.Value)))
+			default:
+				return newError("argument to `indexOf` not supported, got %s", args[0].Type())
+			}
+		},
+		},
+	},
+	{
+		"keys",
+		&Builtin{Fn: func(args ...Object) Object {
+			hash, err := oneHash("keys", args)
+
+			if err != nil {
+				return err
+			}
+
+			elements := []Object{}
+
+			for _, pair := range hash.OrderedPairs() {
+				elements = append(elements, pair.Key)
+			}
+
+			return &Array{Elements: elements}
+		},
+		},
+	},
+	{
+		"values",
+		&Builtin{Fn: func(args ...Object) Object {
+			hash, err := oneHash("values", args)
+
+			if err != nil {
+				return err
+			}
+
+			elements := []Object{}
+
+			for _, pair := range hash.OrderedPairs() {
+				elements = append(elements, pair.Value)
+			}
+
+			return &Array{Elements: elements}
+		},
+		},
+	},
+	{
+		"entries",
+		&Builtin{Fn: func(args ...Object) Object {
+			hash, err := oneHash("entries", args)
+
+			if err != nil {
+				return err
+			}
+
+			elements := []Object{}
+
+			for _, pair := range hash.OrderedPairs() {
+				entry := &Array{Elements: []Object{pair.Key, pair.Value}}
+				elements = append(elements, entry)
+			}
+
+			return &Array{Elements: elements}
+		},
+		},
+	},
+	{
+		"delete",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			hash, ok := args[0].(*Hash)
+
+			if !ok {
+				return newError("first argument to `delete` must be HASH, got %s", args[0].Type())
+			}
+
+			key, ok := args[1].(Hashable)
+
+			if !ok {
+				return newError("%s", UnusableHashKey(args[1]))
+			}
+
+			result := NewHash()
+
+			for _, k := range hash.Keys {
+				result.Set(k, hash.Pairs[k])
+			}
+
+			result.Delete(key.HashKey())
+
+			return result
+		},
+		},
+	},
+	{
+		"isNull",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			_, ok := args[0].(*Null)
+
+			return NativeBool(ok)
+		},
+		},
+	},
+	{
+		"print",
+		withIO(func(streams *IO, args ...Object) Object {
+			for _, arg := range args {
+				fmt.Fprint(streams.writer(), Display(arg))
+			}
+
+			return nil
+		}),
+	},
+	{
+		"printf",
+		withIO(func(streams *IO, args ...Object) Object {
+			formatted, err := format("printf", args)
+
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprint(streams.writer(), formatted)
+
+			return nil
+		}),
+	},
+	{
+		"format",
+		&Builtin{Fn: func(args ...Object) Object {
+			formatted, err := format("format", args)
+
+			if err != nil {
+				return err
+			}
+
+			return &String{Value: formatted}
+		},
+		},
+	},
+	{
+		"readLine",
+		withIO(func(streams *IO, args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+
+			line, err := streams.reader().ReadString('\n')
+
+			if err == io.EOF && line == "" {
+				return nil
+			}
+
+			if err != nil && err != io.EOF {
+				return newError("could not read line: %s", err)
+			}
+
+			line = strings.TrimSuffix(line, "\n")
+
+			return &String{Value: strings.TrimSuffix(line, "\r")}
+		}),
+	},
+	{
+		"readFile",
+		&Builtin{Fn: func(args ...Object) Object {
+			path, errObj := oneString("readFile", args)
+
+			if errObj != nil {
+				return errObj
+			}
+
+			contents, err := os.ReadFile(path)
+
+			if err != nil {
+				return newError("could not read file: %s", err)
+			}
+
+			return &String{Value: string(contents)}
+		},
+		},
+	},
+	{
+		"writeFile",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			path, contents, errObj := twoStrings("writeFile", args)
+
+			if errObj != nil {
+				return errObj
+			}
+
+			err := os.WriteFile(path, []byte(contents), 0o644)
+
+			if err != nil {
+				return newError("could not write file: %s", err)
+			}
+
+			return nil
+		},
+		},
+	},
+	{
+		"args",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+
+			elements := make([]Object, len(scriptArgs))
+
+			for i, arg := range scriptArgs {
+				elements[i] = &String{Value: arg}
+			}
+
+			return &Array{Elements: elements}
+		},
+		},
+	},
+	{
+		"isHashable",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			_, ok := args[0].(Hashable)
+
+			return NativeBool(ok)
+		},
+		},
+	},
+	{
+		"assert",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+
+			failure := &Failure{}
+
+			if len(args) == 2 {
+				msg, ok := args[1].(*String)
+
+				if !ok {
+					return newError("second argument to `assert` must be STRING, got %s", args[1].Type())
+				}
+
+				failure.Message = msg.Value
+			}
+
+			if ValueOf(args[0]).Truthy() {
+				return NULL
+			}
+
+			err := newError("assertion failed")
+
+			if failure.Message != "" {
+				err.Message += ": " + failure.Message
+			}
+
+			err.Failure = failure
+
+			return err
+		},
+		},
+	},
+	{
+		"assertEqual",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			actual, expected := args[0], args[1]
+
+			if deepEqual(actual, expected) {
+				return NULL
+			}
+
+			err := newError("assertEqual failed: expected %s, got %s", expected.Inspect(), actual.Inspect())
+			err.Failure = &Failure{Expected: expected, Actual: actual}
+
+			return err
+		},
+		},
+	},
+	{
+		"charCodeAt",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			str, ok := args[0].(*String)
+
+			if !ok {
+				return newError("first argument to `charCodeAt` must be STRING, got %s", args[0].Type())
+			}
+
+			index, ok := args[1].(*Integer)
+
+			if !ok {
+				return newError("second argument to `charCodeAt` must be INTEGER, got %s", args[1].Type())
+			}
+
+			if r, ok := RuneAt(str.Value, index.Value); ok {
+				return NewInteger(int64(r))
+			}
+
+			return nil
+		},
+		},
+	},
+	{
+		"fromCharCode",
+		&Builtin{Fn: func(args ...Object) Object {
+			var out strings.Builder
+
+			for _, arg := range args {
+				code, ok := arg.(*Integer)
+
+				if !ok {
+					return newError("arguments to `fromCharCode` must be INTEGER, got %s", arg.Type())
+				}
+
+				if code.Value < 0 || code.Value > utf8.MaxRune || !utf8.ValidRune(rune(code.Value)) {
+					return newError("invalid character code: %d", code.Value)
+				}
+
+				out.WriteRune(rune(code.Value))
+			}
+
+			return &String{Value: out.String()}
+		},
+		},
+	},
+	{
+		"range",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) < 1 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want 1 to 3", len(args))
+			}
+
+			bounds := []int64{0, 0, 1}
+
+			for i, arg := range args {
+				integer, ok := arg.(*Integer)
+
+				if !ok {
+					return newError("arguments to `range` must be INTEGER, got %s", arg.Type())
+				}
+
+				bounds[i] = integer.Value
+			}
+
+			// range(stop) counts from 0.
+			if len(args) == 1 {
+				bounds[0], bounds[1] = 0, bounds[0]
+			}
+
+			r, err := NewRange(bounds[0], bounds[1], bounds[2])
+
+			if err != nil {
+				return newError("%s", err)
+			}
+
+			return r
+		},
+		},
+	},
+	{
+		"parseJSON",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			str, ok := args[0].(*String)
+
+			if !ok {
+				return newError("argument to `parseJSON` must be STRING, got %s", args[0].Type())
+			}
+
+			value, err := ParseJSON(str.Value)
+
+			if err != nil {
+				return newError("invalid JSON: %s", err)
+			}
+
+			return value
+		},
+		},
+	},
+	{
+		"toJSON",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want 1 or 2", len(args))
+			}
+
+			text, err := ToJSON(args[0])
+
+			if err != nil {
+				return newError("%s", err)
+			}
+
+			// toJSON(value, indent) puts every element on a line of its own.
+			if len(args) == 2 {
+				indent, ok := args[1].(*String)
+
+				if !ok {
+					return newError("second argument to `toJSON` must be STRING, got %s", args[1].Type())
+				}
+
+				var out bytes.Buffer
+
+				json.Indent(&out, []byte(text), "", indent.Value)
+				text = out.String()
+			}
+
+			return &String{Value: text}
+		},
+		},
+	},
+	{
+		"httpGet",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want 1 or 2", len(args))
+			}
+
+			url, ok := args[0].(*String)
+
+			if !ok {
+				return newError("first argument to `httpGet` must be STRING, got %s", args[0].Type())
+			}
+
+			options, err := optionalHTTPOptions("httpGet", args[1:])
+
+			if err != nil {
+				return err
+			}
+
+			return httpRequest(http.MethodGet, url.Value, nil, options)
+		},
+		},
+	},
+	{
+		"httpPost",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) < 2 || len(args) > 4 {
+				return newError("wrong number of arguments. got=%d, want 2 to 4", len(args))
+			}
+
+			url, body, err := twoStrings("httpPost", args)
+
+			if err != nil {
+				return err
+			}
+
+			options, err := optionalHTTPOptions("httpPost", args[min(len(args), 3):])
+
+			if err != nil {
+				return err
+			}
+
+			// httpPost(url, body, headers) adds to the headers of the options.
+			if len(args) > 2 {
+				headers, err := httpHeaders("httpPost", args[2])
+
+				if err != nil {
+					return err
+				}
+
+				for key, value := range headers {
+					options.headers[key] = value
+				}
+			}
+
+			return httpRequest(http.MethodPost, url, strings.NewReader(body), options)
+		},
+		},
+	},
+	{
+		"match",
+		&Builtin{Fn: func(args ...Object) Object {
+			re, str, err := regexpArguments("match", args, 2)
+
+			if err != nil {
+				return err
+			}
+
+			loc := re.FindStringSubmatchIndex(str)
+
+			if loc == nil {
+				return nil
+			}
+
+			return matchHash(re, str, loc)
+		},
+		},
+	},
+	{
+		"findAll",
+		&Builtin{Fn: func(args ...Object) Object {
+			re, str, err := regexpArguments("findAll", args, 2)
+
+			if err != nil {
+				return err
+			}
+
+			matches := []Object{}
+
+			for _, loc := range re.FindAllStringSubmatchIndex(str, -1) {
+				matches = append(matches, matchHash(re, str, loc))
+			}
+
+			return &Array{Elements: matches}
+		},
+		},
+	},
+	{
+		"replaceRegex",
+		&Builtin{Fn: func(args ...Object) Object {
+			re, str, err := regexpArguments("replaceRegex", args, 3)
+
+			if err != nil {
+				return err
+			}
+
+			// $1 or ${name} in the replacement stands for that group.
+			return &String{Value: re.ReplaceAllString(str, args[2].(*String).Value)}
+		},
+		},
+	},
+	// The math builtins are defined in math.go.
+	{"abs", &Builtin{Fn: builtinAbs}},
+	{"min", &Builtin{Fn: builtinMin}},
+	{"max", &Builtin{Fn: builtinMax}},
+	{"pow", &Builtin{Fn: builtinPow}},
+	{"sqrt", &Builtin{Fn: builtinSqrt}},
+	{"floor", &Builtin{Fn: builtinFloor}},
+	{"ceil", &Builtin{Fn: builtinCeil}},
+	{"round", &Builtin{Fn: builtinRound}},
+	{"random", &Builtin{Fn: builtinRandom}},
+	{"randomInt", &Builtin{Fn: builtinRandomInt}},
+	{"seedRandom", &Builtin{Fn: builtinSeedRandom}},
+	// The time builtins are defined in time.go.
+	{"now", &Builtin{Fn: builtinNow}},
+	{"timestamp", &Builtin{Fn: builtinTimestamp}},
+	{"formatTime", &Builtin{Fn: builtinFormatTime}},
+	{"parseTime", &Builtin{Fn: builtinParseTime}},
+	{"sleep", &Builtin{Fn: builtinSleep}},
+	// The conversion builtins are defined in convert.go.
+	{"int", &Builtin{Fn: builtinInt}},
+	{"float", &Builtin{Fn: builtinFloat}},
+	{"str", &Builtin{Fn: builtinStr}},
+	{"bool", &Builtin{Fn: builtinBool}},
+	{"type", &Builtin{Fn: builtinType}},
+	{
+		"deepEqual",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			return NativeBool(deepEqual(args[0], args[1]))
+		},
+		},
+	},
+	{
+		"compare",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			c, err := Compare(args[0], args[1])
+
+			if err != nil {
+				return newError("%s", err)
+			}
+
+			return NewInteger(int64(c))
+		},
+		},
+	},
+	{
+		"freeze",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			Freeze(args[0])
+
+			return args[0]
+		},
+		},
+	},
+	{
+		"isFrozen",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			return NativeBool(IsFrozen(args[0]))
+		},
+		},
+	},
+	{"exit", &Builtin{Fn: builtinExit}},
+	{"panic", &Builtin{Fn: builtinPanic}},
+	{"env", &Builtin{Fn: builtinEnv}},
+	{"setEnv", &Builtin{Fn: builtinSetEnv}},
+	{"exec", &Builtin{Fn: builtinExec}},
+	{"inspect", &Builtin{Fn: builtinInspect}},
+	{"bytes", &Builtin{Fn: builtinBytes}},
+	{"hexEncode", &Builtin{Fn: builtinHexEncode}},
+	{"hexDecode", &Builtin{Fn: builtinHexDecode}},
+	{"base64Encode", &Builtin{Fn: builtinBase64Encode}},
+	{"base64Decode", &Builtin{Fn: builtinBase64Decode}},
+	{"set", &Builtin{Fn: builtinSet}},
+	{"has", &Builtin{Fn: builtinHas}},
+	{"union", &Builtin{Fn: builtinUnion}},
+	{"intersect", &Builtin{Fn: builtinIntersect}},
+	{"difference", &Builtin{Fn: builtinDifference}},
+}
+
+// withIO makes the Builtin for fn, which reads or writes through the IO it
+// is given.
+func withIO(fn func(streams *IO, args ...Object) Object) *Builtin {
+	return &Builtin{Fn: func(args ...Object) Object { return fn(stdio, args...) }, WithIO: fn}
+}
+
+func GetBuiltinByName(name string) *Builtin {
+	for _, def := range Builtins {
+		if def.Name == name {
+			return def.Builtin
+		}
+	}
+
+	return nil
+}
+
+// arrayArgument returns the argument of the builtin name that must be an
+// ARRAY, turning a Range into one.
+func arrayArgument(name string, arg Object) (*Array, *Error) {
+	arr, ok, err := AsArray(arg)
+
+	if err != nil {
+		return nil, newError("%s", err)
+	}
+
+	if !ok {
+		return nil, newError("argument to `%s` must be ARRAY, got %s", name, arg.Type())
+	}
+
+	return arr, nil
+}
+
+func rangeElement(r *Range, i int64) Object {
+	if value, ok := r.At(i); ok {
+		return NewInteger(value)
+	}
+
+	return nil
+}
+
+func rangeIndexOf(r *Range, obj Object) int64 {
+	if integer, ok := obj.(*Integer); ok {
+		return r.IndexOf(integer.Value)
+	}
+
+	return -1
+}
+
+func newError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
+
+// oneString checks that a builtin called name received exactly one STRING.
+func oneString(name string, args []Object) (string, *Error) {
+	if len(args) != 1 {
+		return "", newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	str, ok := args[0].(*String)
+
+	if !ok {
+		return "", newError("argument to `%s` must be STRING, got %s", name, args[0].Type())
+	}
+
+	return str.Value, nil
+}
+
+// oneHash checks that a builtin called name received exactly one HASH.
+func oneHash(name string, args []Object) (*Hash, *Error) {
+	if len(args) != 1 {
+		return nil, newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	hash, ok := args[0].(*Hash)
+
+	if !ok {
+		return nil, newError("argument to `%s` must be HASH, got %s", name, args[0].Type())
+	}
+
+	return hash, nil
+}
+
+// twoStrings checks that both arguments of a builtin called name are STRING.
+func twoStrings(name string, args []Object) (string, string, *Error) {
+	first, ok := args[0].(*String)
+
+	if !ok {
+		return "", "", newError("first argument to `%s` must be STRING, got %s", name, args[0].Type())
+	}
+
+	second, ok := args[1].(*String)
+
+	if !ok {
+		return "", "", newError("second argument to `%s` must be STRING, got %s", name, args[1].Type())
+	}
+
+	return first.Value, second.Value, nil
+}
+
+// Display is how puts, print and interpolated strings show a value: strings
+// as their raw contents, everything else as Inspect.
+func Display(obj Object) string {
+	if str, ok := obj.(*String); ok {
+		return str.Value
+	}
+
+	return obj.Inspect()
+}
+
+// format fills the verbs of the format string in args[0] with the remaining
+// arguments, in order. %s shows a value as puts does, %v as Inspect does (so
+// strings come out quoted), %d takes only an INTEGER, and %% is a literal
+// percent sign. name is the builtin reported in errors.
+func format(name string, args []Object) (string, *Error) {
+	if len(args) == 0 {
+		return "", newError("wrong number of arguments. got=0, want at least 1")
+	}
+
+	formatString, ok := args[0].(*String)
+
+	if !ok {
+		return "", newError("first argument to `%s` must be STRING, got %s", name, args[0].Type())
+	}
+
+	var out strings.Builder
+
+	values := args[1:]
+	used := 0
+	runes := []rune(formatString.Value)
+
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '%' {
+			out.WriteRune(runes[i])
+
+			continue
+		}
+
+		if i+1 == len(runes) {
+			return "", newError("format string of `%s` ends with a lone %%", name)
+		}
+
+		i++
+		verb := runes[i]
+
+		if verb == '%' {
+			out.WriteRune('%')
+
+			continue
+		}
+
+		if verb != 's' && verb != 'v' && verb != 'd' {
+			return "", newError("unknown verb %%%c in `%s`", verb, name)
+		}
+
+		if used == len(values) {
+			return "", newError("missing argument for %%%c in `%s`", verb, name)
+		}
+
+		value := values[used]
+		used++
+
+		switch verb {
+		case 's':
+			out.WriteString(Display(value))
+		case 'v':
+			out.WriteString(value.Inspect())
+		case 'd':
+			integer, ok := value.(*Integer)
+
+			if !ok {
+				return "", newError("argument for %%d in `%s` must be INTEGER, got %s", name, value.Type())
+			}
+
+			out.WriteString(integer.Inspect())
+		}
+	}
+
+	if used != len(values) {
+		return "", newError("too many arguments to `%s`. got=%d, want=%d", name, len(values), used)
+	}
+
+	return out.String(), nil
+}
+
+// indexOfElement returns the position of the first element equal to obj, or
+// -1. Integers, floats, strings, booleans and null compare by value;
+// everything else by identity.
+func indexOfElement(arr *Array, obj Object) int {
+	for i, el := range arr.Elements {
+		if valuesEqual(el, obj) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func valuesEqual(a, b Object) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value == b.(*Integer).Value
+	case *BigInt:
+		return a.Value.Cmp(b.(*BigInt).Value) == 0
+	case *Float:
+		return a.Value == b.(*Float).Value
+	case *String:
+		return a.Value == b.(*String).Value
+	case *Boolean:
+		return a.Value == b.(*Boolean).Value
+	case *Null:
+		return true
+	default:
+		return a == b
+	}
+}
+
+// runeIndex is strings.Index counted in runes rather than bytes, to match
+// `len` and slicing.
+func runeIndex(s, sub string) int {
+	i := strings.Index(s, sub)
+
+	if i < 0 {
+		return i
+	}
+
+	return utf8.RuneCountInString(s[:i])
+}
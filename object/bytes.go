@@ -0,0 +1,186 @@
+package object
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Bytes is binary data: a sequence of bytes, each indexed as an INTEGER from
+// 0 to 255. Like a String it never changes once made, so slicing one can
+// share its bytes instead of copying them.
+type Bytes struct {
+	Value []byte
+}
+
+func (b *Bytes) Type() ObjectType { return BYTES_OBJ }
+
+// Inspect writes b as b"...", with printable ASCII as itself and any other
+// byte as an escape such as \x00.
+func (b *Bytes) Inspect() string {
+	var out strings.Builder
+
+	out.WriteString(`b"`)
+
+	for _, c := range b.Value {
+		switch {
+		case c == '"' || c == '\\':
+			out.WriteByte('\\')
+			out.WriteByte(c)
+		case c == '\n':
+			out.WriteString(`\n`)
+		case c == '\t':
+			out.WriteString(`\t`)
+		case c >= ' ' && c <= '~':
+			out.WriteByte(c)
+		default:
+			fmt.Fprintf(&out, `\x%02x`, c)
+		}
+	}
+
+	out.WriteByte('"')
+
+	return out.String()
+}
+
+// Iterate yields the bytes of b, each as an INTEGER.
+func (b *Bytes) Iterate() *Iterator {
+	value := b.Value
+	i := 0
+
+	return &Iterator{next: func() (Object, bool) {
+		if i >= len(value) {
+			return nil, false
+		}
+
+		i++
+
+		return NewInteger(int64(value[i-1])), true
+	}}
+}
+
+// At returns the byte at i, or false if i is out of range.
+func (b *Bytes) At(i int64) (int64, bool) {
+	if i < 0 || i >= int64(len(b.Value)) {
+		return 0, false
+	}
+
+	return int64(b.Value[i]), true
+}
+
+// Slice returns b[start:end], sharing b's bytes.
+func (b *Bytes) Slice(start, end int) *Bytes {
+	return &Bytes{Value: b.Value[start:end:end]}
+}
+
+// ConcatBytes returns a + b in bytes of its own.
+func ConcatBytes(a, b *Bytes) *Bytes {
+	return &Bytes{Value: bytes.Join([][]byte{a.Value, b.Value}, nil)}
+}
+
+// bytes(x) returns the UTF-8 encoding of the string x, or bytes holding the
+// array x of integers from 0 to 255. Bytes are returned as they are.
+func builtinBytes(args ...Object) Object {
+	if err := oneArgument(args); err != nil {
+		return err
+	}
+
+	switch arg := args[0].(type) {
+	case *Bytes:
+		return arg
+	case *String:
+		return &Bytes{Value: []byte(arg.Value)}
+	case *Array:
+		value := make([]byte, len(arg.Elements))
+
+		for i, el := range arg.Elements {
+			integer, ok := el.(*Integer)
+
+			if !ok || integer.Value < 0 || integer.Value > 255 {
+				return newError("elements of an array for `bytes` must be INTEGER from 0 to 255, got %s", el.Inspect())
+			}
+
+			value[i] = byte(integer.Value)
+		}
+
+		return &Bytes{Value: value}
+	default:
+		return newError("argument to `bytes` must be STRING or ARRAY, got %s", arg.Type())
+	}
+}
+
+// binary returns the bytes of a BYTES, or of a STRING's UTF-8 encoding, as
+// the encoding builtin called name takes.
+func binary(name string, args []Object) ([]byte, *Error) {
+	if err := oneArgument(args); err != nil {
+		return nil, err
+	}
+
+	switch arg := args[0].(type) {
+	case *Bytes:
+		return arg.Value, nil
+	case *String:
+		return []byte(arg.Value), nil
+	default:
+		return nil, newError("argument to `%s` must be BYTES or STRING, got %s", name, arg.Type())
+	}
+}
+
+// hexEncode(b) returns b as a string of lowercase hex digits, two a byte.
+func builtinHexEncode(args ...Object) Object {
+	value, errObj := binary("hexEncode", args)
+
+	if errObj != nil {
+		return errObj
+	}
+
+	return &String{Value: hex.EncodeToString(value)}
+}
+
+// hexDecode(s) returns the bytes the hex digits of s spell out, in either
+// case.
+func builtinHexDecode(args ...Object) Object {
+	str, errObj := oneString("hexDecode", args)
+
+	if errObj != nil {
+		return errObj
+	}
+
+	value, err := hex.DecodeString(str)
+
+	if err != nil {
+		return newError("invalid hex: %s", strings.TrimPrefix(err.Error(), "encoding/hex: "))
+	}
+
+	return &Bytes{Value: value}
+}
+
+// base64Encode(b) returns b in standard, padded base64.
+func builtinBase64Encode(args ...Object) Object {
+	value, errObj := binary("base64Encode", args)
+
+	if errObj != nil {
+		return errObj
+	}
+
+	return &String{Value: base64.StdEncoding.EncodeToString(value)}
+}
+
+// base64Decode(s) returns the bytes s encodes in standard, padded base64.
+func builtinBase64Decode(args ...Object) Object {
+	str, errObj := oneString("base64Decode", args)
+
+	if errObj != nil {
+		return errObj
+	}
+
+	value, err := base64.StdEncoding.DecodeString(str)
+
+	if err != nil {
+		return newError("invalid base64: %s", err)
+	}
+
+	return &Bytes{Value: value}
+}
@@ -0,0 +1,136 @@
+package object
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// The conversion builtins turn a value into another type, failing with an
+// error, never a guess, when the value has no sensible counterpart.
+
+func oneArgument(args []Object) *Error {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	return nil
+}
+
+func conversionError(obj Object, to ObjectType) *Error {
+	if str, ok := obj.(*String); ok {
+		return newError("cannot convert %q to %s", str.Value, to)
+	}
+
+	return newError("cannot convert %s to %s", obj.Type(), to)
+}
+
+// int(x) returns an integer, or a float truncated toward zero, as an
+// integer; a string written as one in base 10, such as "-42"; and 1 or 0
+// for a boolean.
+func builtinInt(args ...Object) Object {
+	if err := oneArgument(args); err != nil {
+		return err
+	}
+
+	switch arg := args[0].(type) {
+	case *Integer, *BigInt:
+		return arg
+	case *Float:
+		if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+			return newError("cannot convert %s to INTEGER", arg.Inspect())
+		}
+
+		whole, _ := big.NewFloat(math.Trunc(arg.Value)).Int(nil)
+
+		return NormalizeInt(whole)
+	case *String:
+		value, ok := new(big.Int).SetString(arg.Value, 10)
+
+		if !ok {
+			return conversionError(arg, INTEGER_OBJ)
+		}
+
+		return NormalizeInt(value)
+	case *Boolean:
+		if arg.Value {
+			return NewInteger(1)
+		}
+
+		return NewInteger(0)
+	default:
+		return conversionError(arg, INTEGER_OBJ)
+	}
+}
+
+// float(x) returns a number as a float, a string written as a number, such
+// as "2.5" or "1e3", as one, and 1.0 or 0.0 for a boolean.
+func builtinFloat(args ...Object) Object {
+	if err := oneArgument(args); err != nil {
+		return err
+	}
+
+	switch arg := args[0].(type) {
+	case *Integer, *BigInt, *Float:
+		return &Float{Value: toFloat(arg)}
+	case *String:
+		value, err := strconv.ParseFloat(arg.Value, 64)
+
+		if err != nil {
+			return conversionError(arg, FLOAT_OBJ)
+		}
+
+		return &Float{Value: value}
+	case *Boolean:
+		if arg.Value {
+			return &Float{Value: 1}
+		}
+
+		return &Float{Value: 0}
+	default:
+		return conversionError(arg, FLOAT_OBJ)
+	}
+}
+
+// str(x) returns x as puts shows it, so a string is returned as it is. Bytes
+// are the exception: str decodes them as UTF-8, undoing bytes(s).
+func builtinStr(args ...Object) Object {
+	if err := oneArgument(args); err != nil {
+		return err
+	}
+
+	switch arg := args[0].(type) {
+	case *String:
+		return arg
+	case *Bytes:
+		return &String{Value: string(arg.Value)}
+	}
+
+	return &String{Value: Display(args[0])}
+}
+
+// bool(x) returns whether x counts as true in a condition: everything does
+// except false and null.
+func builtinBool(args ...Object) Object {
+	if err := oneArgument(args); err != nil {
+		return err
+	}
+
+	return NativeBool(args[0] != FALSE && args[0] != NULL)
+}
+
+// type(x) returns the name of x's type, such as "INTEGER" or "HASH". A
+// function compiled for the VM is a FUNCTION too, so that a program sees
+// the same types on either engine.
+func builtinType(args ...Object) Object {
+	if err := oneArgument(args); err != nil {
+		return err
+	}
+
+	switch t := args[0].Type(); t {
+	case CLOSURE_OBJ, COMPILED_FUNCTION_OBJ:
+		return Intern(string(FUNCTION_OBJ))
+	default:
+		return Intern(string(t))
+	}
+}
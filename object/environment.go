@@ -1,35 +1,328 @@
 package object
 
+import "sort"
+
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
+	return NewEnclosedEnvironmentSize(outer, 0)
+}
+
+// NewEnclosedEnvironmentSize makes room for size bindings up front, such as
+// a function's parameters.
+func NewEnclosedEnvironmentSize(outer *Environment, size int) *Environment {
+	env := &Environment{outer: outer}
+
+	if size <= inlineBindings {
+		env.bindings = env.inline[:0]
+	} else {
+		env.bindings = make([]binding, 0, size)
+	}
 
 	return env
 }
 
 func NewEnvironment() *Environment {
-	s := make(map[string]Object)
+	return NewEnclosedEnvironmentSize(nil, 0)
+}
+
+// inlineBindings is how many bindings an environment holds in its own
+// allocation. Most function calls bind only their parameters and a local or
+// two, so they never allocate a separate slice.
+const inlineBindings = 4
 
-	return &Environment{store: s, outer: nil}
+// indexThreshold is how many bindings an environment searches linearly
+// before it keeps a map from names to slots.
+const indexThreshold = 8
+
+type binding struct {
+	name     string
+	value    Object // nil while the name is declared but not yet bound
+	constant bool   // set by Freeze; assignments leave the binding alone
 }
 
+// Environment maps names to values for one scope and links to the scope it
+// was created in. A function value keeps a pointer to the environment it was
+// defined in, and every call gets a fresh environment enclosed by that one.
+// Closures therefore share captured bindings by reference: Assign on a
+// captured name changes it for every closure created in the same scope, and
+// for the defining scope itself.
+//
+// Bindings live in numbered slots. The evaluator's resolver works out the
+// slot of most identifiers before running a program, so GetAt and SetAt
+// can find them without searching by name.
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	inline   [inlineBindings]binding
+	bindings []binding      // backed by inline until it outgrows it
+	index    map[string]int // slot of each name, once there are many
+	outer    *Environment
+	io       *IO // set by SetIO, usually only on a program's top level
+	modules  map[string]*Hash
 }
 
-func (e *Environment) Get(name string) (Object, bool) {
-	obj, ok := e.store[name]
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// SetIO gives the code that runs in e, and in the environments it
+// encloses, an IO of its own.
+func (e *Environment) SetIO(io *IO) {
+	e.io = io
+}
+
+// IO returns the IO set on e or the nearest environment enclosing it, or
+// nil if there is none, in which case the process's is used.
+func (e *Environment) IO() *IO {
+	for env := e; env != nil; env = env.outer {
+		if env.io != nil {
+			return env.io
+		}
+	}
+
+	return nil
+}
+
+// Modules returns the cache of the modules imported by the code that runs
+// in e, by absolute path. It belongs to the outermost environment, which
+// makes it on first use, unless SetModules gave one to an environment on
+// the way there.
+func (e *Environment) Modules() map[string]*Hash {
+	env := e
+
+	for env.modules == nil && env.outer != nil {
+		env = env.outer
+	}
+
+	if env.modules == nil {
+		env.modules = map[string]*Hash{}
+	}
+
+	return env.modules
+}
+
+// SetModules makes e use modules as its cache of imported modules, so that
+// a module sees the same copy of a file the program importing it does.
+func (e *Environment) SetModules(modules map[string]*Hash) {
+	e.modules = modules
+}
+
+// Slot returns the slot name is declared in, in e itself.
+func (e *Environment) Slot(name string) (int, bool) {
+	if e.index != nil {
+		slot, ok := e.index[name]
 
-	if !ok && e.outer != nil {
-		obj, ok = e.outer.Get(name)
+		return slot, ok
 	}
 
-	return obj, ok
+	for i := range e.bindings {
+		if e.bindings[i].name == name {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// Declare returns the slot of name in e, adding an unbound one if there is
+// none yet.
+func (e *Environment) Declare(name string) int {
+	if slot, ok := e.Slot(name); ok {
+		return slot
+	}
+
+	return e.add(name, nil)
+}
+
+func (e *Environment) add(name string, val Object) int {
+	slot := len(e.bindings)
+	e.bindings = append(e.bindings, binding{name: name, value: val})
+
+	if e.index != nil {
+		e.index[name] = slot
+	} else if len(e.bindings) > indexThreshold {
+		e.buildIndex()
+	}
+
+	return slot
+}
+
+func (e *Environment) buildIndex() {
+	e.index = make(map[string]int, len(e.bindings))
+
+	for i, b := range e.bindings {
+		if b.name != "" {
+			e.index[b.name] = i
+		}
+	}
+}
+
+func (e *Environment) Get(name string) (Object, bool) {
+	for env := e; env != nil; env = env.outer {
+		if slot, ok := env.Slot(name); ok && env.bindings[slot].value != nil {
+			return env.bindings[slot].value, true
+		}
+	}
+
+	return nil, false
 }
 
 func (e *Environment) Set(name string, val Object) Object {
-	e.store[name] = val
+	if slot, ok := e.Slot(name); ok {
+		e.bindings[slot].value = val
+		e.bindings[slot].constant = false
+	} else {
+		e.add(name, val)
+	}
 
 	return val
 }
+
+// Define binds name in slot of e. If the slot is taken by another name the
+// binding is made by name instead, so a stale slot can never alias two
+// names.
+func (e *Environment) Define(slot int, name string, val Object) {
+	if slot < len(e.bindings) && e.bindings[slot].name == name {
+		e.bindings[slot].value = val
+		e.bindings[slot].constant = false
+
+		return
+	}
+
+	if _, ok := e.Slot(name); ok || (slot < len(e.bindings) && e.bindings[slot].name != "") {
+		e.Set(name, val)
+
+		return
+	}
+
+	// Slots in between belong to lets that have not run yet.
+	for len(e.bindings) <= slot {
+		e.bindings = append(e.bindings, binding{})
+	}
+
+	e.bindings[slot] = binding{name: name, value: val}
+
+	if e.index != nil {
+		e.index[name] = slot
+	} else if len(e.bindings) > indexThreshold {
+		e.buildIndex()
+	}
+}
+
+// at returns the binding in slot of the environment depth levels out, if it
+// holds name.
+func (e *Environment) at(depth, slot int, name string) *binding {
+	env := e
+
+	for ; depth > 0 && env != nil; depth-- {
+		env = env.outer
+	}
+
+	if env == nil || slot >= len(env.bindings) || env.bindings[slot].name != name {
+		return nil
+	}
+
+	return &env.bindings[slot]
+}
+
+// GetAt is Get for a binding whose slot is known. It reports false if that
+// slot does not hold a bound name, in which case the caller should fall
+// back to Get.
+func (e *Environment) GetAt(depth, slot int, name string) (Object, bool) {
+	b := e.at(depth, slot, name)
+
+	if b == nil || b.value == nil {
+		return nil, false
+	}
+
+	return b.value, true
+}
+
+// SetAt is Assign for a binding whose slot is known, and reports false in
+// the same cases as GetAt.
+func (e *Environment) SetAt(depth, slot int, name string, val Object) bool {
+	b := e.at(depth, slot, name)
+
+	if b == nil || b.value == nil || b.constant {
+		return false
+	}
+
+	b.value = val
+
+	return true
+}
+
+// Assign rebinds an existing name in the nearest environment that defines
+// it. It reports false, without binding anything, if name is unbound or
+// constant.
+func (e *Environment) Assign(name string, val Object) (Object, bool) {
+	for env := e; env != nil; env = env.outer {
+		if slot, ok := env.Slot(name); ok && env.bindings[slot].value != nil {
+			if env.bindings[slot].constant {
+				return nil, false
+			}
+
+			env.bindings[slot].value = val
+
+			return val, true
+		}
+	}
+
+	return nil, false
+}
+
+// Freeze makes the binding of name in e constant, until a let binds it
+// again.
+func (e *Environment) Freeze(name string) {
+	if slot, ok := e.Slot(name); ok {
+		e.bindings[slot].constant = true
+	}
+}
+
+// IsConstant reports whether the nearest binding of name is constant.
+func (e *Environment) IsConstant(name string) bool {
+	for env := e; env != nil; env = env.outer {
+		if slot, ok := env.Slot(name); ok && env.bindings[slot].value != nil {
+			return env.bindings[slot].constant
+		}
+	}
+
+	return false
+}
+
+// Names returns the names bound directly in e, not in its outer
+// environments, in sorted order.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.bindings))
+
+	for _, b := range e.bindings {
+		if b.value != nil {
+			names = append(names, b.name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Clone returns a copy of e's own bindings, in the same slots and enclosed
+// by the same outer environment. Binding or assigning a name in one leaves
+// the other alone, but the values both hold are shared, so an array changed
+// in place through one is changed in the other too.
+func (e *Environment) Clone() *Environment {
+	clone := NewEnclosedEnvironmentSize(e.outer, len(e.bindings))
+	clone.bindings = append(clone.bindings, e.bindings...)
+	clone.io = e.io
+
+	if e.modules != nil {
+		clone.modules = make(map[string]*Hash, len(e.modules))
+
+		for path, module := range e.modules {
+			clone.modules[path] = module
+		}
+	}
+
+	if e.index != nil {
+		clone.buildIndex()
+	}
+
+	return clone
+}
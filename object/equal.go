@@ -0,0 +1,196 @@
+package object
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+)
+
+// Equal reports whether a == b. Numbers are equal when their values are,
+// whatever their types; strings, bytes, booleans and null compare by value;
+// arrays and ranges element by element; and hashes pair by pair, in any
+// order. Sets are equal when they have the same elements. Anything else,
+// such as a function, is only equal to itself.
+func Equal(a, b Object) bool {
+	return equal(a, b, false, nil)
+}
+
+// deepEqual is Equal without mixing types, so that 1 and 1.0, or an array
+// and a range, differ, as assertEqual and the deepEqual builtin need.
+func deepEqual(a, b Object) bool {
+	return equal(a, b, true, nil)
+}
+
+// comparison is a pair of values being compared. An array or hash that
+// contains itself leads back to a comparison already in progress, which is
+// taken to hold rather than recursing forever.
+type comparison struct {
+	a, b Object
+}
+
+func equal(a, b Object, exact bool, comparing map[comparison]bool) bool {
+	if exact && a.Type() != b.Type() {
+		return false
+	}
+
+	// Checked before identity, as NaN is not equal even to itself.
+	if isNumber(a) && isNumber(b) {
+		aInt, aOk := ToBigInt(a)
+		bInt, bOk := ToBigInt(b)
+
+		if aOk && bOk {
+			return aInt.Cmp(bInt) == 0
+		}
+
+		return toFloat(a) == toFloat(b)
+	}
+
+	if a == b {
+		return true
+	}
+
+	switch a := a.(type) {
+	case *String:
+		other, ok := b.(*String)
+
+		return ok && a.Value == other.Value
+	case *Bytes:
+		other, ok := b.(*Bytes)
+
+		return ok && bytes.Equal(a.Value, other.Value)
+	case *Boolean:
+		other, ok := b.(*Boolean)
+
+		return ok && a.Value == other.Value
+	case *Null:
+		_, ok := b.(*Null)
+
+		return ok
+	case *Set:
+		other, ok := b.(*Set)
+
+		return ok && len(a.Keys) == len(other.Keys) && len(filterSet(a, other, false).Keys) == 0
+	case *Array, *Range, *Hash:
+		if comparing == nil {
+			comparing = map[comparison]bool{}
+		}
+
+		key := comparison{a, b}
+
+		if comparing[key] {
+			return true
+		}
+
+		comparing[key] = true
+		defer delete(comparing, key)
+
+		if hash, ok := a.(*Hash); ok {
+			return equalHashes(hash, b, exact, comparing)
+		}
+
+		return equalSequences(a, b, exact, comparing)
+	default:
+		return false
+	}
+}
+
+func equalSequences(a, b Object, exact bool, comparing map[comparison]bool) bool {
+	aLen, aAt, aOk := sequence(a)
+	bLen, bAt, bOk := sequence(b)
+
+	if !aOk || !bOk || aLen != bLen {
+		return false
+	}
+
+	for i := int64(0); i < aLen; i++ {
+		if !equal(aAt(i), bAt(i), exact, comparing) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func equalHashes(a *Hash, b Object, exact bool, comparing map[comparison]bool) bool {
+	other, ok := b.(*Hash)
+
+	if !ok || len(a.Pairs) != len(other.Pairs) {
+		return false
+	}
+
+	for key, pair := range a.Pairs {
+		otherPair, ok := other.Pairs[key]
+
+		if !ok || !equal(pair.Value, otherPair.Value, exact, comparing) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sequence returns the length of an array or range and a function giving
+// its elements, without making an array of a range.
+func sequence(obj Object) (int64, func(int64) Object, bool) {
+	switch obj := obj.(type) {
+	case *Array:
+		return int64(len(obj.Elements)), func(i int64) Object { return obj.Elements[i] }, true
+	case *Range:
+		return obj.Len(), func(i int64) Object { return rangeElement(obj, i) }, true
+	default:
+		return 0, nil, false
+	}
+}
+
+// Compare orders a and b, returning -1, 0 or +1 as a sorts before, with or
+// after b. Numbers of any type compare by value, strings and bytes by their
+// bytes as < compares strings, false before true, and arrays and ranges
+// element by element, with a prefix before the longer sequence. Other
+// values, and values of different kinds, have no order.
+func Compare(a, b Object) (int, error) {
+	if isNumber(a) && isNumber(b) {
+		return compareNumbers(a, b), nil
+	}
+
+	switch a := a.(type) {
+	case *String:
+		if other, ok := b.(*String); ok {
+			return cmp.Compare(a.Value, other.Value), nil
+		}
+	case *Bytes:
+		if other, ok := b.(*Bytes); ok {
+			return bytes.Compare(a.Value, other.Value), nil
+		}
+	case *Boolean:
+		if other, ok := b.(*Boolean); ok {
+			return cmp.Compare(boolRank(a.Value), boolRank(other.Value)), nil
+		}
+	case *Array, *Range:
+		if b.Type() == ARRAY_OBJ || b.Type() == RANGE_OBJ {
+			return compareSequences(a, b)
+		}
+	}
+
+	return 0, fmt.Errorf("cannot compare %s and %s", a.Type(), b.Type())
+}
+
+func compareSequences(a, b Object) (int, error) {
+	aLen, aAt, _ := sequence(a)
+	bLen, bAt, _ := sequence(b)
+
+	for i := int64(0); i < aLen && i < bLen; i++ {
+		if c, err := Compare(aAt(i), bAt(i)); err != nil || c != 0 {
+			return c, err
+		}
+	}
+
+	return cmp.Compare(aLen, bLen), nil
+}
+
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
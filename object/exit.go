@@ -0,0 +1,46 @@
+package object
+
+// exit(code) ends the program, with code, 0 if left out, as its exit
+// status. It returns an error carrying an Exit, which unwinds every call
+// and try on its way out.
+func builtinExit(args ...Object) Object {
+	if len(args) > 1 {
+		return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+	}
+
+	code := int64(0)
+
+	if len(args) == 1 {
+		integer, ok := args[0].(*Integer)
+
+		if !ok {
+			return newError("argument to `exit` must be INTEGER, got %s", args[0].Type())
+		}
+
+		code = integer.Value
+	}
+
+	if code < 0 || code > 255 {
+		return newError("exit code must be between 0 and 255, got %d", code)
+	}
+
+	err := newError("exit(%d)", code)
+	err.Exit = &Exit{Code: int(code)}
+
+	return err
+}
+
+// panic(msg) raises an error that try does not catch, for a failure the
+// program cannot carry on after. Only recover stops it; otherwise it ends
+// the program like any uncaught error. msg may be any value, shown as puts
+// would show it.
+func builtinPanic(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	err := newError("panic: %s", Display(args[0]))
+	err.Panic = true
+
+	return err
+}
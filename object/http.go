@@ -0,0 +1,162 @@
+package object
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultHTTPTimeout bounds a request that does not set a timeout of its
+// own, so a script cannot hang forever on a server that never answers.
+const defaultHTTPTimeout = 30 * time.Second
+
+// networkAllowed is whether httpGet and httpPost may make requests. A host
+// running code it does not trust turns it off with SetNetwork.
+var networkAllowed = true
+
+// SetNetwork allows or forbids the HTTP builtins to reach the network. While
+// it is forbidden they fail without making a request.
+func SetNetwork(allowed bool) {
+	networkAllowed = allowed
+}
+
+// httpOptions are the settings of one request, from the options hash that
+// is the last argument of httpGet and httpPost.
+type httpOptions struct {
+	headers map[string]string
+	timeout time.Duration
+}
+
+// optionalHTTPOptions reads the options hash in args, if there is one.
+func optionalHTTPOptions(name string, args []Object) (httpOptions, *Error) {
+	options := httpOptions{headers: map[string]string{}, timeout: defaultHTTPTimeout}
+
+	if len(args) == 0 {
+		return options, nil
+	}
+
+	hash, ok := args[0].(*Hash)
+
+	if !ok {
+		return options, newError("options to `%s` must be HASH, got %s", name, args[0].Type())
+	}
+
+	for _, pair := range hash.OrderedPairs() {
+		switch Display(pair.Key) {
+		case "headers":
+			headers, err := httpHeaders(name, pair.Value)
+
+			if err != nil {
+				return options, err
+			}
+
+			options.headers = headers
+		case "timeout":
+			seconds, ok := numberValue(pair.Value)
+
+			if !ok || seconds <= 0 {
+				return options, newError("timeout of `%s` must be a positive number of seconds, got %s", name, pair.Value.Inspect())
+			}
+
+			options.timeout = time.Duration(seconds * float64(time.Second))
+		default:
+			return options, newError("unknown option to `%s`: %s", name, pair.Key.Inspect())
+		}
+	}
+
+	return options, nil
+}
+
+// httpHeaders reads a hash of header names to values, both strings.
+func httpHeaders(name string, arg Object) (map[string]string, *Error) {
+	hash, ok := arg.(*Hash)
+
+	if !ok {
+		return nil, newError("headers to `%s` must be HASH, got %s", name, arg.Type())
+	}
+
+	headers := map[string]string{}
+
+	for _, pair := range hash.OrderedPairs() {
+		key, keyOk := pair.Key.(*String)
+		value, valueOk := pair.Value.(*String)
+
+		if !keyOk || !valueOk {
+			return nil, newError("headers to `%s` must map STRING to STRING, got %s: %s", name, pair.Key.Type(), pair.Value.Type())
+		}
+
+		headers[key.Value] = value.Value
+	}
+
+	return headers, nil
+}
+
+func numberValue(obj Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.Value), true
+	case *Float:
+		return obj.Value, true
+	default:
+		return 0, false
+	}
+}
+
+// httpRequest makes a request and returns the response as a hash of its
+// status, headers and body. A response with an error status is still a
+// response; only failing to get one at all is an error.
+func httpRequest(method, url string, body io.Reader, options httpOptions) Object {
+	if !networkAllowed {
+		return newError("network access is disabled")
+	}
+
+	req, err := http.NewRequest(method, url, body)
+
+	if err != nil {
+		return newError("invalid request: %s", err)
+	}
+
+	for key, value := range options.headers {
+		req.Header.Set(key, value)
+	}
+
+	client := &http.Client{Timeout: options.timeout}
+
+	resp, err := client.Do(req)
+
+	if err != nil {
+		return newError("request failed: %s", err)
+	}
+
+	defer resp.Body.Close()
+
+	contents, err := io.ReadAll(resp.Body)
+
+	if err != nil {
+		return newError("could not read response: %s", err)
+	}
+
+	// Headers are sorted by name, as a Go map has no order of its own.
+	names := make([]string, 0, len(resp.Header))
+
+	for key := range resp.Header {
+		names = append(names, key)
+	}
+
+	sort.Strings(names)
+
+	headers := NewHash()
+
+	for _, key := range names {
+		setPair(headers, key, &String{Value: strings.Join(resp.Header[key], ", ")})
+	}
+
+	result := NewHash()
+	setPair(result, "status", NewInteger(int64(resp.StatusCode)))
+	setPair(result, "headers", headers)
+	setPair(result, "body", &String{Value: string(contents)})
+
+	return result
+}
@@ -0,0 +1,161 @@
+package object
+
+import (
+	"strings"
+)
+
+// PRETTY_WIDTH is how wide a line Pretty writes may be before it spreads a
+// container over several lines.
+const PRETTY_WIDTH = 80
+
+const prettyIndent = "  "
+
+// inspector writes arrays and hashes for Inspect and Pretty. seen holds the
+// containers being written, so that one found inside itself, which index
+// assignment can make, is shown as [...] or {...} instead of recursing
+// forever.
+type inspector struct {
+	seen map[Object]bool
+}
+
+func newInspector() *inspector {
+	return &inspector{seen: map[Object]bool{}}
+}
+
+// Pretty returns obj as Inspect does, except that an array or hash too wide
+// for one line has each element on a line of its own, indented by how
+// deeply it is nested. Containers nested more than depth levels deep are
+// shown as [...] or {...}; a negative depth means no limit.
+func Pretty(obj Object, depth int) string {
+	return newInspector().pretty(obj, depth, "")
+}
+
+// flat writes obj on one line. depth counts down with each level, so a
+// negative one never reaches zero.
+func (in *inspector) flat(obj Object, depth int) string {
+	elided, ok := in.elided(obj, depth)
+
+	if ok {
+		return elided
+	}
+
+	in.seen[obj] = true
+	defer delete(in.seen, obj)
+
+	var out strings.Builder
+
+	switch obj := obj.(type) {
+	case *Array:
+		out.WriteString("[")
+
+		for i, el := range obj.Elements {
+			if i > 0 {
+				out.WriteString(", ")
+			}
+
+			out.WriteString(in.flat(el, depth-1))
+		}
+
+		out.WriteString("]")
+	case *Hash:
+		out.WriteString("{")
+
+		for i, pair := range obj.OrderedPairs() {
+			if i > 0 {
+				out.WriteString(", ")
+			}
+
+			out.WriteString(pair.Key.Inspect())
+			out.WriteString(": ")
+			out.WriteString(in.flat(pair.Value, depth-1))
+		}
+
+		out.WriteString("}")
+	}
+
+	return out.String()
+}
+
+// pretty writes obj starting at a line indented by indent, breaking it over
+// several lines if it does not fit on what is left of the first.
+func (in *inspector) pretty(obj Object, depth int, indent string) string {
+	flat := in.flat(obj, depth)
+
+	if _, ok := in.elided(obj, depth); ok || len(indent)+len(flat) <= PRETTY_WIDTH {
+		return flat
+	}
+
+	in.seen[obj] = true
+	defer delete(in.seen, obj)
+
+	inner := indent + prettyIndent
+
+	var lines []string
+	var opening, closing string
+
+	switch obj := obj.(type) {
+	case *Array:
+		opening, closing = "[", "]"
+
+		for _, el := range obj.Elements {
+			lines = append(lines, inner+in.pretty(el, depth-1, inner))
+		}
+	case *Hash:
+		opening, closing = "{", "}"
+
+		for _, pair := range obj.OrderedPairs() {
+			key := pair.Key.Inspect() + ": "
+			lines = append(lines, inner+key+in.pretty(pair.Value, depth-1, inner))
+		}
+	}
+
+	return opening + "\n" + strings.Join(lines, ",\n") + "\n" + indent + closing
+}
+
+// elided returns what obj is written as when it is not a container whose
+// elements are written out: an empty one, or one too deep or already being
+// written, or anything that is not an array or hash.
+func (in *inspector) elided(obj Object, depth int) (string, bool) {
+	switch obj := obj.(type) {
+	case *Array:
+		switch {
+		case len(obj.Elements) == 0:
+			return "[]", true
+		case depth == 0 || in.seen[obj]:
+			return "[...]", true
+		}
+	case *Hash:
+		switch {
+		case len(obj.Pairs) == 0:
+			return "{}", true
+		case depth == 0 || in.seen[obj]:
+			return "{...}", true
+		}
+	default:
+		return obj.Inspect(), true
+	}
+
+	return "", false
+}
+
+// inspect(x, depth) returns x as Pretty writes it, down to depth levels of
+// nesting if depth is given.
+func builtinInspect(args ...Object) Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	depth := -1
+
+	if len(args) == 2 {
+		integer, ok := args[1].(*Integer)
+
+		if !ok || integer.Value < 0 {
+			return newError("depth of `inspect` must be a non-negative INTEGER, got %s", args[1].Inspect())
+		}
+
+		depth = int(min(integer.Value, 1<<30))
+	}
+
+	return &String{Value: Pretty(args[0], depth)}
+}
@@ -0,0 +1,90 @@
+package object
+
+import (
+	"bufio"
+	"io"
+	"os"
+)
+
+// IO is where the input and output builtins read and write: puts, print
+// and printf write to its writer and readLine reads from its reader. The
+// evaluator takes it from the environment a builtin is looked up in, and
+// the VM from its own, so that each program can have its own.
+type IO struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	bound map[*Builtin]*Builtin // the builtins that use an IO, bound to this one
+}
+
+// stdio is the IO of programs that were not given one. It is the process's
+// stdin and stdout unless SetInput or SetOutput point it elsewhere.
+var stdio = &IO{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+
+var scriptArgs []string = []string{}
+
+// NewIO returns an IO that reads from in and writes to out. A nil in or out
+// leaves that side to whatever SetInput or SetOutput last chose.
+func NewIO(in io.Reader, out io.Writer) *IO {
+	s := &IO{out: out, bound: map[*Builtin]*Builtin{}}
+
+	if in != nil {
+		s.in = bufio.NewReader(in)
+	}
+
+	for _, def := range Builtins {
+		if fn := def.Builtin.WithIO; fn != nil {
+			s.bound[def.Builtin] = Bind(def.Builtin, func(args ...Object) Object { return fn(s, args...) })
+		}
+	}
+
+	return s
+}
+
+// Bind returns b reading and writing through s, or b itself if it does no
+// input or output.
+func (s *IO) Bind(b *Builtin) *Builtin {
+	if b.WithIO == nil || s == nil {
+		return b
+	}
+
+	if bound, ok := s.bound[b]; ok {
+		return bound
+	}
+
+	return b
+}
+
+func (s *IO) reader() *bufio.Reader {
+	if s.in == nil {
+		return stdio.in
+	}
+
+	return s.in
+}
+
+func (s *IO) writer() io.Writer {
+	if s.out == nil {
+		return stdio.out
+	}
+
+	return s.out
+}
+
+// SetInput makes readLine read from in, in programs that have no IO of
+// their own.
+func SetInput(in io.Reader) {
+	stdio.in = bufio.NewReader(in)
+}
+
+// SetOutput makes puts, print and printf write to out, in programs that
+// have no IO of their own.
+func SetOutput(out io.Writer) {
+	stdio.out = out
+}
+
+// SetArgs sets what args() returns: the command-line arguments that follow
+// the script name.
+func SetArgs(args []string) {
+	scriptArgs = args
+}
@@ -0,0 +1,85 @@
+package object
+
+// Iterable is implemented by the objects a for-in loop can walk over.
+type Iterable interface {
+	Object
+	Iterate() *Iterator
+}
+
+// Iterator steps through the elements of an Iterable. It is an Object only
+// so that the VM can keep it in a local slot while a loop runs; programs
+// never see one.
+type Iterator struct {
+	next func() (Object, bool)
+}
+
+func (it *Iterator) Type() ObjectType { return ITERATOR_OBJ }
+func (it *Iterator) Inspect() string  { return "iterator" }
+
+// Next returns the next element, or false once there are none left.
+func (it *Iterator) Next() (Object, bool) { return it.next() }
+
+// Iterate yields the elements of a, as they were when the loop started.
+func (a *Array) Iterate() *Iterator {
+	elements := a.Elements
+	i := 0
+
+	return &Iterator{next: func() (Object, bool) {
+		if i >= len(elements) {
+			return nil, false
+		}
+
+		i++
+
+		return elements[i-1], true
+	}}
+}
+
+// Iterate yields the keys of h in insertion order.
+func (h *Hash) Iterate() *Iterator {
+	pairs := h.OrderedPairs()
+	i := 0
+
+	return &Iterator{next: func() (Object, bool) {
+		if i >= len(pairs) {
+			return nil, false
+		}
+
+		i++
+
+		return pairs[i-1].Key, true
+	}}
+}
+
+// Iterate yields the characters of s, each as a one-character String.
+func (s *String) Iterate() *Iterator {
+	runes := []rune(s.Value)
+	i := 0
+
+	return &Iterator{next: func() (Object, bool) {
+		if i >= len(runes) {
+			return nil, false
+		}
+
+		i++
+
+		return Intern(string(runes[i-1])), true
+	}}
+}
+
+// Iterate yields the integers of r without building them all at once.
+func (r *Range) Iterate() *Iterator {
+	var i int64
+
+	return &Iterator{next: func() (Object, bool) {
+		v, ok := r.At(i)
+
+		if !ok {
+			return nil, false
+		}
+
+		i++
+
+		return NewInteger(v), true
+	}}
+}
@@ -0,0 +1,258 @@
+package object
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ParseJSON turns a JSON document into Monkey values: objects become hashes
+// with string keys, in the order the document gives them, and arrays,
+// strings, booleans and null their Monkey counterparts. A number is an
+// Integer, or a BigInt if it is too large for one, unless it has a fraction
+// or an exponent, which makes it a Float.
+func ParseJSON(input string) (Object, error) {
+	dec := json.NewDecoder(strings.NewReader(input))
+	dec.UseNumber()
+
+	value, err := parseJSONValue(dec)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the JSON value")
+	}
+
+	return value, nil
+}
+
+func parseJSONValue(dec *json.Decoder) (Object, error) {
+	tok, err := dec.Token()
+
+	if err == io.EOF {
+		return nil, fmt.Errorf("unexpected end of JSON input")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '[' {
+			return parseJSONArray(dec)
+		}
+
+		return parseJSONObject(dec)
+	case string:
+		return &String{Value: tok}, nil
+	case json.Number:
+		return parseJSONNumber(tok)
+	case bool:
+		return NativeBool(tok), nil
+	default:
+		return NULL, nil
+	}
+}
+
+func parseJSONArray(dec *json.Decoder) (Object, error) {
+	elements := []Object{}
+
+	for dec.More() {
+		el, err := parseJSONValue(dec)
+
+		if err != nil {
+			return nil, err
+		}
+
+		elements = append(elements, el)
+	}
+
+	// The closing bracket.
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	return &Array{Elements: elements}, nil
+}
+
+func parseJSONObject(dec *json.Decoder) (Object, error) {
+	hash := NewHash()
+
+	for dec.More() {
+		tok, err := dec.Token()
+
+		if err != nil {
+			return nil, err
+		}
+
+		key := &String{Value: tok.(string)}
+
+		value, err := parseJSONValue(dec)
+
+		if err != nil {
+			return nil, err
+		}
+
+		hash.Set(key.HashKey(), HashPair{Key: key, Value: value})
+	}
+
+	// The closing brace.
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	return hash, nil
+}
+
+func parseJSONNumber(n json.Number) (Object, error) {
+	if strings.ContainsAny(string(n), ".eE") {
+		f, err := strconv.ParseFloat(string(n), 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("number out of range: %s", n)
+		}
+
+		return &Float{Value: f}, nil
+	}
+
+	value, _ := new(big.Int).SetString(string(n), 10)
+
+	return NormalizeInt(value), nil
+}
+
+// ToJSON turns obj into a JSON document, the reverse of ParseJSON. JSON
+// object keys are strings, so integer and boolean hash keys are written as
+// their text, and 1 and "1" as keys of the same hash fail rather than
+// produce a duplicate. Only numbers, strings, booleans, null, arrays,
+// ranges and hashes can be converted, and an array or hash that contains
+// itself cannot.
+func ToJSON(obj Object) (string, error) {
+	var out bytes.Buffer
+
+	if err := writeJSON(&out, obj, map[Object]bool{}); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+func writeJSON(out *bytes.Buffer, obj Object, enclosing map[Object]bool) error {
+	switch obj := obj.(type) {
+	case *Integer, *BigInt, *Boolean, *Null:
+		out.WriteString(obj.Inspect())
+	case *Float:
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return fmt.Errorf("cannot convert %s to JSON", obj.Inspect())
+		}
+
+		text := strconv.FormatFloat(obj.Value, 'g', -1, 64)
+
+		// Keep it a float when it is read back.
+		if !strings.ContainsAny(text, ".e") {
+			text += ".0"
+		}
+
+		out.WriteString(text)
+	case *String:
+		writeJSONString(out, obj.Value)
+	case *Array, *Range:
+		arr, _, err := AsArray(obj)
+
+		if err != nil {
+			return err
+		}
+
+		if enclosing[obj] {
+			return fmt.Errorf("cannot convert an array that contains itself to JSON")
+		}
+
+		enclosing[obj] = true
+		defer delete(enclosing, obj)
+
+		out.WriteByte('[')
+
+		for i, el := range arr.Elements {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+
+			if err := writeJSON(out, el, enclosing); err != nil {
+				return err
+			}
+		}
+
+		out.WriteByte(']')
+	case *Hash:
+		if enclosing[obj] {
+			return fmt.Errorf("cannot convert a hash that contains itself to JSON")
+		}
+
+		enclosing[obj] = true
+		defer delete(enclosing, obj)
+
+		seen := map[string]bool{}
+
+		out.WriteByte('{')
+
+		for i, pair := range obj.OrderedPairs() {
+			key, err := jsonKey(pair.Key)
+
+			if err != nil {
+				return err
+			}
+
+			if seen[key] {
+				return fmt.Errorf("duplicate JSON key: %q", key)
+			}
+
+			seen[key] = true
+
+			if i > 0 {
+				out.WriteByte(',')
+			}
+
+			writeJSONString(out, key)
+			out.WriteByte(':')
+
+			if err := writeJSON(out, pair.Value, enclosing); err != nil {
+				return err
+			}
+		}
+
+		out.WriteByte('}')
+	default:
+		return fmt.Errorf("cannot convert %s to JSON", obj.Type())
+	}
+
+	return nil
+}
+
+func jsonKey(key Object) (string, error) {
+	switch key := key.(type) {
+	case *String:
+		return key.Value, nil
+	case *Integer, *Boolean:
+		return key.Inspect(), nil
+	default:
+		return "", fmt.Errorf("cannot convert %s key to JSON", key.Type())
+	}
+}
+
+// writeJSONString writes s quoted and escaped, leaving alone the <, > and &
+// that encoding/json would escape for HTML.
+func writeJSONString(out *bytes.Buffer, s string) {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+
+	// Encode ends the value with a newline.
+	out.Truncate(out.Len() - 1)
+}
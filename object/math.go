@@ -0,0 +1,266 @@
+package object
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// random is the generator behind random and randomInt. It starts from the
+// time, so every run differs, until seedRandom makes it repeatable.
+var random = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// numberArgument checks that a math builtin called name received exactly
+// one number.
+func numberArgument(name string, args []Object) (Object, *Error) {
+	if len(args) != 1 {
+		return nil, newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	if !isNumber(args[0]) {
+		return nil, newError("argument to `%s` must be INTEGER or FLOAT, got %s", name, args[0].Type())
+	}
+
+	return args[0], nil
+}
+
+func isNumber(obj Object) bool {
+	switch obj.Type() {
+	case INTEGER_OBJ, BIGINT_OBJ, FLOAT_OBJ:
+		return true
+	default:
+		return false
+	}
+}
+
+func toFloat(obj Object) float64 {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.Value)
+	case *BigInt:
+		return obj.Float()
+	default:
+		return obj.(*Float).Value
+	}
+}
+
+// compareNumbers returns -1, 0 or +1 as a is less than, equal to or greater
+// than b. Integers compare exactly, however large; a float and an integer
+// compare as floats, as they do with <.
+func compareNumbers(a, b Object) int {
+	aInt, aOk := ToBigInt(a)
+	bInt, bOk := ToBigInt(b)
+
+	if aOk && bOk {
+		return aInt.Cmp(bInt)
+	}
+
+	x, y := toFloat(a), toFloat(b)
+
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func builtinAbs(args ...Object) Object {
+	n, err := numberArgument("abs", args)
+
+	if err != nil {
+		return err
+	}
+
+	switch n := n.(type) {
+	case *Integer:
+		if n.Value >= 0 {
+			return n
+		}
+
+		if n.Value == math.MinInt64 {
+			return NormalizeInt(new(big.Int).Neg(big.NewInt(n.Value)))
+		}
+
+		return NewInteger(-n.Value)
+	case *BigInt:
+		return NormalizeInt(new(big.Int).Abs(n.Value))
+	default:
+		return &Float{Value: math.Abs(toFloat(n))}
+	}
+}
+
+// extreme is min and max: the least or greatest of its arguments, or of the
+// elements of a single array argument, whichever comes first on a tie.
+func extreme(name string, sign int, args []Object) Object {
+	if len(args) == 1 {
+		if arr, ok, err := AsArray(args[0]); err != nil {
+			return newError("%s", err)
+		} else if ok {
+			args = arr.Elements
+		}
+	}
+
+	if len(args) == 0 {
+		return newError("`%s` needs at least one number", name)
+	}
+
+	result := args[0]
+
+	for _, arg := range args {
+		if !isNumber(arg) {
+			return newError("arguments to `%s` must be INTEGER or FLOAT, got %s", name, arg.Type())
+		}
+
+		if compareNumbers(arg, result) == sign {
+			result = arg
+		}
+	}
+
+	return result
+}
+
+func builtinMin(args ...Object) Object {
+	return extreme("min", -1, args)
+}
+
+func builtinMax(args ...Object) Object {
+	return extreme("max", 1, args)
+}
+
+// pow(base, exponent) is base ** exponent.
+func builtinPow(args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	for _, arg := range args {
+		if !isNumber(arg) {
+			return newError("arguments to `pow` must be INTEGER or FLOAT, got %s", arg.Type())
+		}
+	}
+
+	base, baseOk := ToBigInt(args[0])
+	exponent, exponentOk := ToBigInt(args[1])
+
+	if !baseOk || !exponentOk {
+		return &Float{Value: math.Pow(toFloat(args[0]), toFloat(args[1]))}
+	}
+
+	result, err := BigArithmetic("**", base, exponent)
+
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	return result
+}
+
+func builtinSqrt(args ...Object) Object {
+	n, err := numberArgument("sqrt", args)
+
+	if err != nil {
+		return err
+	}
+
+	if compareNumbers(n, NewInteger(0)) < 0 {
+		return newError("square root of a negative number: %s", n.Inspect())
+	}
+
+	return &Float{Value: math.Sqrt(toFloat(n))}
+}
+
+// roundWith returns a float argument rounded to an integer by round.
+// Integers are already whole and are returned as they are.
+func roundWith(name string, round func(float64) float64, args []Object) Object {
+	n, err := numberArgument(name, args)
+
+	if err != nil {
+		return err
+	}
+
+	f, ok := n.(*Float)
+
+	if !ok {
+		return n
+	}
+
+	if math.IsNaN(f.Value) || math.IsInf(f.Value, 0) {
+		return newError("cannot round %s to an integer", f.Inspect())
+	}
+
+	whole, _ := big.NewFloat(round(f.Value)).Int(nil)
+
+	return NormalizeInt(whole)
+}
+
+func builtinFloor(args ...Object) Object {
+	return roundWith("floor", math.Floor, args)
+}
+
+func builtinCeil(args ...Object) Object {
+	return roundWith("ceil", math.Ceil, args)
+}
+
+// round rounds halves away from zero.
+func builtinRound(args ...Object) Object {
+	return roundWith("round", math.Round, args)
+}
+
+// random() returns a float from 0 up to, but not including, 1.
+func builtinRandom(args ...Object) Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+
+	random.Lock()
+	defer random.Unlock()
+
+	return &Float{Value: random.Float64()}
+}
+
+// randomInt(n) returns an integer from 0 up to, but not including, n.
+func builtinRandomInt(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	n, ok := args[0].(*Integer)
+
+	if !ok || n.Value <= 0 {
+		return newError("argument to `randomInt` must be a positive INTEGER, got %s", args[0].Inspect())
+	}
+
+	random.Lock()
+	defer random.Unlock()
+
+	return NewInteger(random.Int63n(n.Value))
+}
+
+// seedRandom(seed) restarts random and randomInt from seed, so that a
+// program, such as a test, sees the same numbers on every run.
+func builtinSeedRandom(args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	seed, ok := args[0].(*Integer)
+
+	if !ok {
+		return newError("argument to `seedRandom` must be INTEGER, got %s", args[0].Type())
+	}
+
+	random.Lock()
+	defer random.Unlock()
+
+	random.Rand = rand.New(rand.NewSource(seed.Value))
+
+	return nil
+}
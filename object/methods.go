@@ -0,0 +1,44 @@
+package object
+
+import "slices"
+
+// Methods lists, for each type, the builtins that can be called as methods
+// of its values: x.name(args) is name(x, args). Some of them, such as map,
+// exist only in the evaluator; an engine without the builtin has no such
+// method either.
+var Methods = map[ObjectType][]string{
+	STRING_OBJ:  {"len", "split", "contains", "replace", "trim", "upper", "lower", "indexOf", "charCodeAt"},
+	BYTES_OBJ:   {"len", "hexEncode", "base64Encode"},
+	ARRAY_OBJ:   {"len", "first", "last", "rest", "push", "join", "contains", "indexOf", "map", "filter", "reduce", "sort", "reverse"},
+	RANGE_OBJ:   {"len", "first", "last", "rest", "push", "contains", "indexOf", "map", "filter", "reduce", "sort", "reverse"},
+	HASH_OBJ:    {"keys", "values", "entries", "delete"},
+	SET_OBJ:     {"len", "has", "contains", "union", "intersect", "difference"},
+	CHANNEL_OBJ: {"send", "recv", "close"},
+	TASK_OBJ:    {"wait"},
+}
+
+// Property returns receiver.name: the field of that name if receiver is a
+// hash that has one, or else the method name bound to receiver, with
+// builtin looking up the engine's implementation. ok is false if there is
+// neither.
+func Property(receiver Object, name string, builtin func(name string) *Builtin) (Object, bool) {
+	if hash, isHash := receiver.(*Hash); isHash {
+		if pair, ok := hash.Pairs[Intern(name).HashKey()]; ok {
+			return pair.Value, true
+		}
+	}
+
+	if !slices.Contains(Methods[receiver.Type()], name) {
+		return nil, false
+	}
+
+	method := builtin(name)
+
+	if method == nil {
+		return nil, false
+	}
+
+	return &Builtin{Fn: func(args ...Object) Object {
+		return method.Fn(append([]Object{receiver}, args...)...)
+	}}, true
+}
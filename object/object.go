@@ -3,8 +3,12 @@ package object
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
 	"monkey/ast"
+	"monkey/code"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 type ObjectType string
@@ -12,13 +16,32 @@ type BuiltinFunction func(args ...Object) Object
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	BIGINT_OBJ       = "BIGINT"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
 	ERROR_OBJ        = "ERROR"
 	FUNCTION_OBJ     = "FUNCTION"
 	STRING_OBJ       = "STRING"
+	BYTES_OBJ        = "BYTES"
 	BUILTIN_OBJ      = "BUILTIN"
+	ARRAY_OBJ        = "ARRAY"
+	RANGE_OBJ        = "RANGE"
+	HASH_OBJ         = "HASH"
+	SET_OBJ          = "SET"
+	QUOTE_OBJ        = "QUOTE"
+	MACRO_OBJ        = "MACRO"
+	ITERATOR_OBJ     = "ITERATOR"
+	CHANNEL_OBJ      = "CHANNEL"
+	TASK_OBJ         = "TASK"
+	THUNK_OBJ        = "THUNK"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CLOSURE_OBJ           = "CLOSURE"
+	CELL_OBJ              = "CELL"
 )
 
 type Object interface {
@@ -36,16 +59,101 @@ func (i *Integer) Inspect() string {
 
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 
+// Integers from minCachedInteger to maxCachedInteger are allocated once and
+// handed out by NewInteger.
+const (
+	minCachedInteger = -128
+	maxCachedInteger = 1024
+)
+
+var cachedIntegers = func() []*Integer {
+	integers := make([]*Integer, maxCachedInteger-minCachedInteger+1)
+
+	for i := range integers {
+		integers[i] = &Integer{Value: int64(i + minCachedInteger)}
+	}
+
+	return integers
+}()
+
+// NewInteger returns an Integer holding value, sharing one object per small
+// value. Integers are never modified after creation, so sharing is safe.
+func NewInteger(value int64) *Integer {
+	if value >= minCachedInteger && value <= maxCachedInteger {
+		return cachedIntegers[value-minCachedInteger]
+	}
+
+	return &Integer{Value: value}
+}
+
+type Float struct {
+	Value float64
+}
+
+// Inspect always keeps a decimal point so floats stay distinguishable from
+// integers when printed.
+func (f *Float) Inspect() string {
+	out := strconv.FormatFloat(f.Value, 'f', -1, 64)
+
+	if !strings.ContainsAny(out, ".NI") {
+		out += ".0"
+	}
+
+	return out
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
 type Boolean struct {
 	Value bool
 }
 
+// TRUE, FALSE and NULL are the only Boolean and Null values the engines and
+// builtins produce, so they can be compared by pointer.
+var (
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+	NULL  = &Null{}
+)
+
+// NativeBool returns the shared Boolean for b.
+func NativeBool(b bool) *Boolean {
+	if b {
+		return TRUE
+	}
+
+	return FALSE
+}
+
 type String struct {
 	Value string
+	buf   *stringBuffer // set by Concat; see there
 }
 
 type Builtin struct {
 	Fn BuiltinFunction
+
+	// WithIO is set on the builtins that read or write, and is what Fn
+	// calls with the process's IO. IO.Bind makes a Builtin calling it with
+	// another.
+	WithIO func(streams *IO, args ...Object) Object
+
+	base *Builtin // what Bind bound, if it made this one
+}
+
+// Bind returns a Builtin that runs fn in place of b, such as b with some of
+// the state of the program calling it.
+func Bind(b *Builtin, fn BuiltinFunction) *Builtin {
+	return &Builtin{Fn: fn, base: b.Base()}
+}
+
+// Base returns the builtin that b was bound from, or b itself.
+func (b *Builtin) Base() *Builtin {
+	if b.base != nil {
+		return b.base
+	}
+
+	return b
 }
 
 func (b *Boolean) Inspect() string {
@@ -69,16 +177,85 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// Break and Continue unwind evaluation up to the nearest enclosing loop, the
+// same way ReturnValue unwinds up to the enclosing function.
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
 type Error struct {
 	Message string
+	Line    int // position of the node that raised the error, 0 if unknown
+	Column  int
+	Stack   []StackFrame // calls the error unwound through, innermost first
+	Failure *Failure     // set when a failed assertion raised the error
+	Exit    *Exit        // set when exit(code) raised the error
+	Panic   bool         // raised by panic(msg)
+}
+
+// Catchable reports whether try catches e. A failed assertion, an exit
+// and a panic pass through it.
+func (e *Error) Catchable() bool {
+	return e.Failure == nil && e.Exit == nil && !e.Panic
+}
+
+// Failure is what a failed assert or assertEqual knows about itself. It
+// lets a test runner tell a failing test from a broken one, and try does
+// not catch an error that carries one.
+type Failure struct {
+	Message  string // the message given to assert, if any
+	Expected Object // the values assertEqual compared; nil for assert
+	Actual   Object
+}
+
+// Exit is exit(code) on its way out of the program, which it leaves with
+// Code as its status. It unwinds like an error, but nothing catches it.
+type Exit struct {
+	Code int
+}
+
+// StackFrame is one function call on an error's way out: the function that
+// was called and the position of the call expression.
+type StackFrame struct {
+	Function string
+	Line     int
+	Column   int
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 
-func (e *Error) Inspect() string { return "ERROR: " + e.Message }
+func (e *Error) Inspect() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("ERROR at line %d, col %d: %s", e.Line, e.Column, e.Message)
+	}
+
+	return "ERROR: " + e.Message
+}
+
+// Traceback is Inspect followed by one line per stack frame.
+func (e *Error) Traceback() string {
+	var out bytes.Buffer
+
+	out.WriteString(e.Inspect())
+
+	for _, frame := range e.Stack {
+		out.WriteString(fmt.Sprintf("\n  in %s, called at line %d, col %d", frame.Function, frame.Line, frame.Column))
+	}
+
+	return out.String()
+}
 
 type Function struct {
+	Name       string // empty for anonymous functions
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression // as in ast.FunctionLiteral
+	Variadic   bool             // the last parameter collects the extra arguments
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -88,8 +265,18 @@ func (f *Function) Inspect() string {
 	var out bytes.Buffer
 	params := []string{}
 
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
+	for i, p := range f.Parameters {
+		param := p.String()
+
+		if i < len(f.Defaults) && f.Defaults[i] != nil {
+			param += " = " + f.Defaults[i].String()
+		}
+
+		params = append(params, param)
+	}
+
+	if f.Variadic {
+		params[len(params)-1] = "..." + params[len(params)-1]
 	}
 
 	out.WriteString("fn")
@@ -102,8 +289,280 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+// WrongArguments is the error message for calling a function that takes
+// min to max arguments with got of them. max is -1 when there is no limit.
+func WrongArguments(min, max, got int) string {
+	switch {
+	case min == max:
+		return fmt.Sprintf("wrong number of arguments: want=%d, got=%d", min, got)
+	case max < 0:
+		return fmt.Sprintf("wrong number of arguments: want at least %d, got=%d", min, got)
+	default:
+		return fmt.Sprintf("wrong number of arguments: want %d to %d, got=%d", min, max, got)
+	}
+}
+
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+	params := []string{}
+
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
 func (s *String) Type() ObjectType { return STRING_OBJ }
-func (s *String) Inspect() string  { return s.Value }
+
+// Inspect renders the string as a Monkey string literal, escaping anything
+// the lexer would need an escape sequence for, so the output reads back in
+// as the same value.
+func (s *String) Inspect() string {
+	var out strings.Builder
+
+	out.WriteByte('"')
+
+	for i, r := range s.Value {
+		switch {
+		case r == '"':
+			out.WriteString(`\"`)
+		case r == '$' && strings.HasPrefix(s.Value[i+1:], "{"):
+			out.WriteString(`\$`)
+		case r == '\\':
+			out.WriteString(`\\`)
+		case r == '\n':
+			out.WriteString(`\n`)
+		case r == '\t':
+			out.WriteString(`\t`)
+		case !unicode.IsPrint(r):
+			fmt.Fprintf(&out, `\u{%x}`, r)
+		default:
+			out.WriteRune(r)
+		}
+	}
+
+	out.WriteByte('"')
+
+	return out.String()
+}
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
 func (b *Builtin) Inspect() string  { return "builtin function" }
+
+type Array struct {
+	Elements []Object
+	Frozen   bool // set by freeze; index assignment fails from then on
+}
+
+func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
+func (ao *Array) Inspect() string  { return newInspector().flat(ao, -1) }
+
+// CompiledFunction holds the bytecode of a function literal produced by the
+// compiler, along with what the VM needs to set up its frame.
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	Variadic      bool     // the last parameter collects the extra arguments
+	NumDefaults   int      // the parameters before a variadic one that have default values
+	Parameters    []string // the parameters' names, for binding named arguments
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// Closure pairs a CompiledFunction with the free variables it captured when
+// the closure was created.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+func (c *Closure) Type() ObjectType { return CLOSURE_OBJ }
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
+// Cell holds a local variable that a closure captures and some code
+// assigns, so the function and its closures share the one binding. It is
+// only ever seen by the VM, which keeps the value unboxed.
+type Cell struct {
+	Value Value
+}
+
+func (c *Cell) Type() ObjectType { return CELL_OBJ }
+func (c *Cell) Inspect() string {
+	return fmt.Sprintf("Cell[%p]", c)
+}
+
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// Hashable is implemented by the values that can be hash keys: integers,
+// booleans and strings. Two keys are the same key when they have the same
+// type and value, so 1 and "1" are different keys and 1.0 is not a key at
+// all. Arrays and hashes can be changed in place, which would move them to
+// a different key after they were stored, so they are not hashable either.
+type Hashable interface {
+	HashKey() HashKey
+}
+
+// UnusableHashKey is the error message for using obj as a hash key.
+func UnusableHashKey(obj Object) string {
+	return fmt.Sprintf("unusable as hash key: %s (keys must be INTEGER, BOOLEAN or STRING)", obj.Type())
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+
+	if b.Value {
+		value = 1
+	} else {
+		value = 0
+	}
+
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash remembers the order keys were first inserted in. Keys holds that
+// order; Pairs holds the entries. Use Set and Delete to keep both in sync.
+type Hash struct {
+	Pairs  map[HashKey]HashPair
+	Keys   []HashKey
+	Frozen bool // set by freeze; index assignment fails from then on
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+// Set adds or replaces the pair for key. A replaced key keeps its original
+// position.
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	if _, ok := h.Pairs[key]; !ok {
+		h.Keys = append(h.Keys, key)
+	}
+
+	h.Pairs[key] = pair
+}
+
+func (h *Hash) Delete(key HashKey) {
+	if _, ok := h.Pairs[key]; !ok {
+		return
+	}
+
+	delete(h.Pairs, key)
+
+	for i, k := range h.Keys {
+		if k == key {
+			h.Keys = append(h.Keys[:i:i], h.Keys[i+1:]...)
+
+			break
+		}
+	}
+}
+
+// OrderedPairs returns the pairs in insertion order.
+func (h *Hash) OrderedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Keys))
+
+	for _, key := range h.Keys {
+		pairs = append(pairs, h.Pairs[key])
+	}
+
+	return pairs
+}
+
+// Freeze makes obj, and every array and hash inside it, immutable. Other
+// values already are.
+func Freeze(obj Object) {
+	switch obj := obj.(type) {
+	case *Array:
+		if obj.Frozen {
+			return
+		}
+
+		obj.Frozen = true
+
+		for _, el := range obj.Elements {
+			Freeze(el)
+		}
+	case *Hash:
+		if obj.Frozen {
+			return
+		}
+
+		obj.Frozen = true
+
+		for _, pair := range obj.Pairs {
+			Freeze(pair.Value)
+		}
+	}
+}
+
+// IsFrozen reports whether obj cannot be changed: everything but an array
+// or hash that has not been frozen.
+func IsFrozen(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Array:
+		return obj.Frozen
+	case *Hash:
+		return obj.Frozen
+	default:
+		return true
+	}
+}
+
+// setPair sets the value of a string key, for builtins that return hashes.
+func setPair(hash *Hash, key string, value Object) {
+	k := &String{Value: key}
+
+	hash.Set(k.HashKey(), HashPair{Key: k, Value: value})
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string  { return newInspector().flat(h, -1) }
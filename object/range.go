@@ -0,0 +1,103 @@
+package object
+
+import (
+	"fmt"
+	"math"
+)
+
+// Range is the integers from Start up to, but not including, Stop, Step
+// apart; with a negative Step it counts down to Stop instead. It is lazy:
+// its elements are computed as they are needed rather than stored, so
+// range(1000000000) costs no more than range(10). Builtins that take an
+// ARRAY also take a Range, through AsArray.
+type Range struct {
+	Start, Stop, Step int64
+	length            int64
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	if r.Step == 1 {
+		return fmt.Sprintf("range(%d, %d)", r.Start, r.Stop)
+	}
+
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.Stop, r.Step)
+}
+
+// NewRange returns the range from start to stop in steps of step, failing
+// if step is zero or the range would have more elements than an Integer
+// can count.
+func NewRange(start, stop, step int64) (*Range, error) {
+	var length uint64
+
+	switch {
+	case step == 0:
+		return nil, fmt.Errorf("range step cannot be zero")
+	case step > 0 && start < stop:
+		length = (uint64(stop)-uint64(start)-1)/uint64(step) + 1
+	case step < 0 && start > stop:
+		length = (uint64(start)-uint64(stop)-1)/(-uint64(step)) + 1
+	}
+
+	if length > math.MaxInt64 {
+		return nil, fmt.Errorf("range too large: %d to %d", start, stop)
+	}
+
+	return &Range{Start: start, Stop: stop, Step: step, length: int64(length)}, nil
+}
+
+// Len returns how many integers r holds.
+func (r *Range) Len() int64 { return r.length }
+
+// At returns the element at index i, and whether there is one.
+func (r *Range) At(i int64) (int64, bool) {
+	if i < 0 || i >= r.length {
+		return 0, false
+	}
+
+	return r.Start + i*r.Step, true
+}
+
+// IndexOf returns the index of value in r, or -1 if r does not hold it.
+func (r *Range) IndexOf(value int64) int64 {
+	offset := value - r.Start
+
+	if offset%r.Step != 0 {
+		return -1
+	}
+
+	if i := offset / r.Step; i >= 0 && i < r.length && r.Start+i*r.Step == value {
+		return i
+	}
+
+	return -1
+}
+
+// maxRangeArray bounds how many elements AsArray builds from a Range, so
+// that passing range(1000000000000) to an array builtin fails instead of
+// exhausting memory.
+const maxRangeArray = 1 << 26
+
+// AsArray returns obj as an array: an Array itself, or the elements of a
+// Range. iterable is false for anything else; err is set if a Range is too
+// long to turn into an array.
+func AsArray(obj Object) (arr *Array, iterable bool, err error) {
+	switch obj := obj.(type) {
+	case *Array:
+		return obj, true, nil
+	case *Range:
+		if obj.length > maxRangeArray {
+			return nil, true, fmt.Errorf("range too large to make an array: %s", obj.Inspect())
+		}
+
+		elements := make([]Object, obj.length)
+
+		for i := range elements {
+			elements[i] = NewInteger(obj.Start + int64(i)*obj.Step)
+		}
+
+		return &Array{Elements: elements}, true, nil
+	default:
+		return nil, false, nil
+	}
+}
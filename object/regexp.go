@@ -0,0 +1,103 @@
+package object
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"sync"
+	"unicode/utf8"
+)
+
+// maxCachedPatterns bounds the pattern cache, so a program building
+// patterns from data cannot grow it without end. When it is full it is
+// emptied and starts over.
+const maxCachedPatterns = 256
+
+// patterns caches compiled regular expressions by their source, as the
+// same pattern is usually matched again and again, such as in a loop.
+var patterns = struct {
+	sync.Mutex
+	compiled map[string]*regexp.Regexp
+}{compiled: map[string]*regexp.Regexp{}}
+
+// compilePattern returns the regular expression pattern, in Go's RE2
+// syntax, or an error explaining why it is not one.
+func compilePattern(pattern string) (*regexp.Regexp, *Error) {
+	patterns.Lock()
+	defer patterns.Unlock()
+
+	if re, ok := patterns.compiled[pattern]; ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+
+	if err != nil {
+		if syntaxErr, ok := err.(*syntax.Error); ok {
+			return nil, newError("invalid regular expression: %s: `%s`", syntaxErr.Code, syntaxErr.Expr)
+		}
+
+		return nil, newError("invalid regular expression: %s", err)
+	}
+
+	if len(patterns.compiled) >= maxCachedPatterns {
+		patterns.compiled = map[string]*regexp.Regexp{}
+	}
+
+	patterns.compiled[pattern] = re
+
+	return re, nil
+}
+
+// regexpArguments checks the pattern and string that the regular
+// expression builtin name starts with, and compiles the pattern.
+func regexpArguments(name string, args []Object, want int) (*regexp.Regexp, string, *Error) {
+	if len(args) != want {
+		return nil, "", newError("wrong number of arguments. got=%d, want=%d", len(args), want)
+	}
+
+	for _, arg := range args {
+		if arg.Type() != STRING_OBJ {
+			return nil, "", newError("arguments to `%s` must be STRING, got %s", name, arg.Type())
+		}
+	}
+
+	re, err := compilePattern(args[0].(*String).Value)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return re, args[1].(*String).Value, nil
+}
+
+// matchHash describes the match of re in str at loc, as returned by
+// FindStringSubmatchIndex: the matched text, its index counted in
+// characters like `len` and indexOf count them, the capture groups in
+// order, with null for a group that did not take part, and the named
+// groups by name.
+func matchHash(re *regexp.Regexp, str string, loc []int) *Hash {
+	groups := []Object{}
+	named := NewHash()
+
+	for i, name := range re.SubexpNames()[1:] {
+		var group Object = NULL
+
+		if start := loc[2*i+2]; start >= 0 {
+			group = &String{Value: str[start:loc[2*i+3]]}
+		}
+
+		groups = append(groups, group)
+
+		if name != "" {
+			setPair(named, name, group)
+		}
+	}
+
+	hash := NewHash()
+	setPair(hash, "match", &String{Value: str[loc[0]:loc[1]]})
+	setPair(hash, "index", NewInteger(int64(utf8.RuneCountInString(str[:loc[0]]))))
+	setPair(hash, "groups", &Array{Elements: groups})
+	setPair(hash, "named", named)
+
+	return hash
+}
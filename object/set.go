@@ -0,0 +1,202 @@
+package object
+
+import "strings"
+
+// Set is a collection of distinct values, each of a type that can be a hash
+// key. Looking a value up takes the same time however many there are, unlike
+// searching an array. Elements keep the order they were first added in, so
+// that printing and looping over a set are repeatable.
+//
+// Like a string, a set never changes once made: union, intersect and
+// difference make new ones.
+type Set struct {
+	Elements map[HashKey]Object
+	Keys     []HashKey
+}
+
+func NewSet() *Set {
+	return &Set{Elements: make(map[HashKey]Object)}
+}
+
+func (s *Set) Type() ObjectType { return SET_OBJ }
+
+// Inspect writes s as the call to set that would make it again.
+func (s *Set) Inspect() string {
+	elements := make([]string, len(s.Keys))
+
+	for i, key := range s.Keys {
+		elements[i] = s.Elements[key].Inspect()
+	}
+
+	return "set([" + strings.Join(elements, ", ") + "])"
+}
+
+// add puts obj in s, if it is not there already. Only a set being built may
+// be added to.
+func (s *Set) add(obj Hashable) {
+	key := obj.HashKey()
+
+	if _, ok := s.Elements[key]; !ok {
+		s.Keys = append(s.Keys, key)
+		s.Elements[key] = obj.(Object)
+	}
+}
+
+// Has reports whether obj is in s. Nothing that cannot be a hash key ever
+// is.
+func (s *Set) Has(obj Object) bool {
+	key, ok := obj.(Hashable)
+
+	if !ok {
+		return false
+	}
+
+	_, ok = s.Elements[key.HashKey()]
+
+	return ok
+}
+
+// Iterate yields the elements of s in the order they were added.
+func (s *Set) Iterate() *Iterator {
+	keys := s.Keys
+	i := 0
+
+	return &Iterator{next: func() (Object, bool) {
+		if i >= len(keys) {
+			return nil, false
+		}
+
+		i++
+
+		return s.Elements[keys[i-1]], true
+	}}
+}
+
+// set(xs) returns a set of the elements of the array or range xs, each once.
+// set() is the empty set.
+func builtinSet(args ...Object) Object {
+	if len(args) > 1 {
+		return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+	}
+
+	result := NewSet()
+
+	if len(args) == 0 {
+		return result
+	}
+
+	if set, ok := args[0].(*Set); ok {
+		return set
+	}
+
+	arr, errObj := arrayArgument("set", args[0])
+
+	if errObj != nil {
+		return errObj
+	}
+
+	for _, el := range arr.Elements {
+		key, ok := el.(Hashable)
+
+		if !ok {
+			return newError("unusable as set element: %s (elements must be INTEGER, BOOLEAN or STRING)", el.Type())
+		}
+
+		result.add(key)
+	}
+
+	return result
+}
+
+// has(s, x) reports whether x is in the set s.
+func builtinHas(args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	set, ok := args[0].(*Set)
+
+	if !ok {
+		return newError("first argument to `has` must be SET, got %s", args[0].Type())
+	}
+
+	return NativeBool(set.Has(args[1]))
+}
+
+// twoSets checks that a builtin called name received exactly two SETs.
+func twoSets(name string, args []Object) (*Set, *Set, *Error) {
+	if len(args) != 2 {
+		return nil, nil, newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	first, ok := args[0].(*Set)
+
+	if !ok {
+		return nil, nil, newError("first argument to `%s` must be SET, got %s", name, args[0].Type())
+	}
+
+	second, ok := args[1].(*Set)
+
+	if !ok {
+		return nil, nil, newError("second argument to `%s` must be SET, got %s", name, args[1].Type())
+	}
+
+	return first, second, nil
+}
+
+// filterSet returns the elements of a that are in b, or if in is false
+// those that are not, in a's order.
+func filterSet(a, b *Set, in bool) *Set {
+	result := NewSet()
+
+	for _, key := range a.Keys {
+		if _, ok := b.Elements[key]; ok == in {
+			result.Keys = append(result.Keys, key)
+			result.Elements[key] = a.Elements[key]
+		}
+	}
+
+	return result
+}
+
+// union(a, b) returns the elements in a or b: those of a, then those only in
+// b.
+func builtinUnion(args ...Object) Object {
+	a, b, errObj := twoSets("union", args)
+
+	if errObj != nil {
+		return errObj
+	}
+
+	result := NewSet()
+
+	for _, set := range []*Set{a, b} {
+		for _, key := range set.Keys {
+			result.add(set.Elements[key].(Hashable))
+		}
+	}
+
+	return result
+}
+
+// intersect(a, b) returns the elements of a that are also in b.
+func builtinIntersect(args ...Object) Object {
+	a, b, errObj := twoSets("intersect", args)
+
+	if errObj != nil {
+		return errObj
+	}
+
+	return filterSet(a, b, true)
+}
+
+// difference(a, b) returns the elements of a that are not in b.
+func builtinDifference(args ...Object) Object {
+	a, b, errObj := twoSets("difference", args)
+
+	if errObj != nil {
+		return errObj
+	}
+
+	return filterSet(a, b, false)
+}
@@ -0,0 +1,105 @@
+package object
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// Concat returns a + b. Building a string one piece at a time, as in
+// s = s + x inside a loop, would copy everything built so far on every
+// step. Instead the result of a Concat keeps the buffer it was built in,
+// with room to spare, and the next Concat onto that result appends into
+// the same buffer. Value is always the complete string; nothing needs to be
+// flattened before it is read.
+//
+// Earlier strings never see the appended bytes, since their Value ends
+// before them, and only the string that ends where the buffer does may
+// append to it. Concatenating onto any other string copies as before.
+func Concat(a, b *String) *String {
+	if b.Value == "" {
+		return a
+	}
+
+	if a.Value == "" {
+		return b
+	}
+
+	if buf := a.buf; buf != nil && len(buf.bytes) == len(a.Value) && unsafe.StringData(a.Value) == &buf.bytes[0] {
+		buf.bytes = append(buf.bytes, b.Value...)
+
+		return &String{Value: unsafe.String(&buf.bytes[0], len(buf.bytes)), buf: buf}
+	}
+
+	buf := &stringBuffer{bytes: make([]byte, 0, 2*(len(a.Value)+len(b.Value)))}
+	buf.bytes = append(buf.bytes, a.Value...)
+	buf.bytes = append(buf.bytes, b.Value...)
+
+	return &String{Value: unsafe.String(&buf.bytes[0], len(buf.bytes)), buf: buf}
+}
+
+// stringBuffer is shared by the strings Concat builds from one another.
+// Bytes are only ever appended, never changed, so every Value that points
+// into it stays valid.
+type stringBuffer struct {
+	bytes []byte
+}
+
+// maxInterned is the longest string Intern will share.
+const maxInterned = 64
+
+var interned sync.Map // string -> *String
+
+// Intern returns a shared String for short, identifier-like values such as
+// the keys of a hash literal, so evaluating the same literal again does not
+// allocate a new object. Strings are immutable, so sharing one is safe.
+// Other values get a String of their own.
+func Intern(value string) *String {
+	if !isIdentifierLike(value) {
+		return &String{Value: value}
+	}
+
+	if s, ok := interned.Load(value); ok {
+		return s.(*String)
+	}
+
+	s, _ := interned.LoadOrStore(value, &String{Value: value})
+
+	return s.(*String)
+}
+
+func isIdentifierLike(value string) bool {
+	if value == "" || len(value) > maxInterned {
+		return false
+	}
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// RuneAt returns the character at index i of s, counting in runes as `len`
+// and slicing do, and whether s has one there.
+func RuneAt(s string, i int64) (rune, bool) {
+	if i < 0 {
+		return 0, false
+	}
+
+	for _, r := range s {
+		if i == 0 {
+			return r, true
+		}
+
+		i--
+	}
+
+	return 0, false
+}
@@ -0,0 +1,115 @@
+package object
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// systemAllowed is whether env, setEnv and exec may reach the process's
+// environment and run commands. A host running code it does not trust
+// turns it off with SetSystem.
+var systemAllowed = true
+
+// SetSystem allows or forbids env, setEnv and exec. While they are
+// forbidden they fail without touching the environment.
+func SetSystem(allowed bool) {
+	systemAllowed = allowed
+}
+
+// env(name) returns the environment variable name, or null if it is not
+// set.
+func builtinEnv(args ...Object) Object {
+	name, errObj := oneString("env", args)
+
+	if errObj != nil {
+		return errObj
+	}
+
+	if !systemAllowed {
+		return newError("system access is disabled")
+	}
+
+	value, ok := os.LookupEnv(name)
+
+	if !ok {
+		return NULL
+	}
+
+	return &String{Value: value}
+}
+
+// setEnv(name, value) sets the environment variable name, for the rest of
+// the program and the commands it runs.
+func builtinSetEnv(args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	name, value, errObj := twoStrings("setEnv", args)
+
+	if errObj != nil {
+		return errObj
+	}
+
+	if !systemAllowed {
+		return newError("system access is disabled")
+	}
+
+	if err := os.Setenv(name, value); err != nil {
+		return newError("could not set environment variable: %s", err)
+	}
+
+	return nil
+}
+
+// exec(cmd, args...) runs cmd with args, not through a shell, and returns a
+// hash of what it wrote to stdout and stderr and its exit code. A command
+// that exits with a nonzero code still ran; only failing to start it is an
+// error.
+func builtinExec(args ...Object) Object {
+	if len(args) == 0 {
+		return newError("wrong number of arguments. got=0, want at least 1")
+	}
+
+	words := make([]string, len(args))
+
+	for i, arg := range args {
+		str, ok := arg.(*String)
+
+		if !ok {
+			return newError("arguments to `exec` must be STRING, got %s", arg.Type())
+		}
+
+		words[i] = str.Value
+	}
+
+	if !systemAllowed {
+		return newError("system access is disabled")
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(words[0], words[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	code := 0
+
+	var exitErr *exec.ExitError
+
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		return newError("could not run command: %s", err)
+	}
+
+	result := NewHash()
+	setPair(result, "stdout", &String{Value: stdout.String()})
+	setPair(result, "stderr", &String{Value: stderr.String()})
+	setPair(result, "code", NewInteger(int64(code)))
+
+	return result
+}
@@ -0,0 +1,195 @@
+package object
+
+import (
+	"math"
+	"time"
+)
+
+// Times are passed around as timestamps: Integers counting milliseconds
+// since the Unix epoch, so that subtracting two of them gives the duration
+// between them and sleep takes the same unit.
+
+// layouts are the names formatTime and parseTime accept in place of a
+// layout of their own.
+var layouts = map[string]string{
+	"RFC3339":  time.RFC3339,
+	"RFC1123":  time.RFC1123,
+	"DateTime": time.DateTime,
+	"DateOnly": time.DateOnly,
+	"TimeOnly": time.TimeOnly,
+	"Kitchen":  time.Kitchen,
+}
+
+// timeLayout returns the Go layout a layout argument names, if it names
+// one, or the argument itself.
+func timeLayout(layout string) string {
+	if named, ok := layouts[layout]; ok {
+		return named
+	}
+
+	return layout
+}
+
+// timeZone returns the location of the optional zone argument in args,
+// such as "UTC" or "Europe/Paris", or the local time zone.
+func timeZone(name string, args []Object) (*time.Location, *Error) {
+	if len(args) == 0 {
+		return time.Local, nil
+	}
+
+	zone, ok := args[0].(*String)
+
+	if !ok {
+		return nil, newError("time zone for `%s` must be STRING, got %s", name, args[0].Type())
+	}
+
+	loc, err := time.LoadLocation(zone.Value)
+
+	if err != nil {
+		return nil, newError("unknown time zone: %s", zone.Value)
+	}
+
+	return loc, nil
+}
+
+// now(zone) returns the parts of the current time in zone as a hash.
+func builtinNow(args ...Object) Object {
+	if len(args) > 1 {
+		return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+	}
+
+	loc, err := timeZone("now", args)
+
+	if err != nil {
+		return err
+	}
+
+	t := time.Now().In(loc)
+
+	hash := NewHash()
+	setPair(hash, "year", NewInteger(int64(t.Year())))
+	setPair(hash, "month", NewInteger(int64(t.Month())))
+	setPair(hash, "day", NewInteger(int64(t.Day())))
+	setPair(hash, "hour", NewInteger(int64(t.Hour())))
+	setPair(hash, "minute", NewInteger(int64(t.Minute())))
+	setPair(hash, "second", NewInteger(int64(t.Second())))
+	setPair(hash, "millisecond", NewInteger(int64(t.Nanosecond()/int(time.Millisecond))))
+	setPair(hash, "weekday", &String{Value: t.Weekday().String()})
+	setPair(hash, "timestamp", NewInteger(t.UnixMilli()))
+
+	return hash
+}
+
+// timestamp() returns the current time as a timestamp.
+func builtinTimestamp(args ...Object) Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+
+	return NewInteger(time.Now().UnixMilli())
+}
+
+// formatTime(ts, layout, zone) writes the timestamp ts out in zone, or the
+// local time zone, using a Go layout such as "2006-01-02 15:04" or one of
+// the names in layouts.
+func builtinFormatTime(args ...Object) Object {
+	if len(args) < 2 || len(args) > 3 {
+		return newError("wrong number of arguments. got=%d, want 2 or 3", len(args))
+	}
+
+	ts, ok := args[0].(*Integer)
+
+	if !ok {
+		return newError("first argument to `formatTime` must be INTEGER, got %s", args[0].Type())
+	}
+
+	layout, ok := args[1].(*String)
+
+	if !ok {
+		return newError("second argument to `formatTime` must be STRING, got %s", args[1].Type())
+	}
+
+	loc, err := timeZone("formatTime", args[2:])
+
+	if err != nil {
+		return err
+	}
+
+	t := time.UnixMilli(ts.Value).In(loc)
+
+	return &String{Value: t.Format(timeLayout(layout.Value))}
+}
+
+// parseTime(str, layout, zone) reads the time str written with layout and
+// returns its timestamp. A time without a zone of its own is taken to be in
+// zone, or the local time zone.
+func builtinParseTime(args ...Object) Object {
+	if len(args) < 2 || len(args) > 3 {
+		return newError("wrong number of arguments. got=%d, want 2 or 3", len(args))
+	}
+
+	str, layout, errObj := twoStrings("parseTime", args)
+
+	if errObj != nil {
+		return errObj
+	}
+
+	loc, errObj := timeZone("parseTime", args[2:])
+
+	if errObj != nil {
+		return errObj
+	}
+
+	t, err := time.ParseInLocation(timeLayout(layout), str, loc)
+
+	if err != nil {
+		return newError("could not parse time: %s", err)
+	}
+
+	return NewInteger(t.UnixMilli())
+}
+
+// SleepDuration checks the arguments of sleep, a number of milliseconds,
+// and returns how long it is.
+func SleepDuration(args []Object) (time.Duration, *Error) {
+	if len(args) != 1 {
+		return 0, newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	ms, ok := numberValue(args[0])
+
+	if !ok || ms < 0 || math.IsNaN(ms) {
+		return 0, newError("argument to `sleep` must be a non-negative number of milliseconds, got %s", args[0].Inspect())
+	}
+
+	return time.Duration(ms * float64(time.Millisecond)), nil
+}
+
+// Sleep waits for d to pass, or for done to be closed, and reports whether
+// it waited the whole time. A nil done is never closed.
+func Sleep(d time.Duration, done <-chan struct{}) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// sleep(ms) pauses for ms milliseconds. The evaluator and the VM do not
+// call it, but sleep themselves, so they can stop early when the program
+// they are running is cancelled.
+func builtinSleep(args ...Object) Object {
+	d, err := SleepDuration(args)
+
+	if err != nil {
+		return err
+	}
+
+	Sleep(d, nil)
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package object
+
+import "math"
+
+// Value is how the VM holds values on its stack and in its globals. Integers
+// and floats are stored unboxed, so arithmetic on them allocates nothing and
+// leaves the garbage collector no pointer to follow. Booleans and null are
+// the shared TRUE, FALSE and NULL objects and every other value is its heap
+// Object, as before. Object boxes a Value back into an Object wherever one
+// is needed, such as in an array or as an argument to a builtin.
+//
+// Values compare with == as the VM's equality does: integers and floats by
+// their bits, everything else by identity.
+type Value struct {
+	kind valueKind
+	bits uint64 // an unboxed integer or float
+	obj  Object // everything else
+}
+
+type valueKind uint8
+
+const (
+	objectKind valueKind = iota
+	intKind
+	floatKind
+)
+
+func IntValue(value int64) Value {
+	return Value{kind: intKind, bits: uint64(value)}
+}
+
+func FloatValue(value float64) Value {
+	return Value{kind: floatKind, bits: math.Float64bits(value)}
+}
+
+// ValueOf wraps obj, unboxing integers and floats.
+func ValueOf(obj Object) Value {
+	switch obj := obj.(type) {
+	case *Integer:
+		return IntValue(obj.Value)
+	case *Float:
+		return FloatValue(obj.Value)
+	default:
+		return Value{obj: obj}
+	}
+}
+
+func (v Value) Type() ObjectType {
+	switch v.kind {
+	case intKind:
+		return INTEGER_OBJ
+	case floatKind:
+		return FLOAT_OBJ
+	default:
+		return v.obj.Type()
+	}
+}
+
+func (v Value) IsInt() bool   { return v.kind == intKind }
+func (v Value) IsFloat() bool { return v.kind == floatKind }
+
+// Int and Float return the number held by an integer or float Value.
+func (v Value) Int() int64     { return int64(v.bits) }
+func (v Value) Float() float64 { return math.Float64frombits(v.bits) }
+
+// Truthy reports whether v counts as true in a condition, which everything
+// but false and null does.
+func (v Value) Truthy() bool {
+	return v.kind != objectKind || (v.obj != FALSE && v.obj != NULL)
+}
+
+// Object returns v as an Object, allocating one for a number outside the
+// small-integer cache. The zero Value, an unset global, is nil.
+func (v Value) Object() Object {
+	switch v.kind {
+	case intKind:
+		return NewInteger(v.Int())
+	case floatKind:
+		return &Float{Value: v.Float()}
+	default:
+		return v.obj
+	}
+}
@@ -0,0 +1,277 @@
+// Package optimizer rewrites a program's AST before it runs. It folds
+// operators whose operands are all literals, drops the branch of an if whose
+// condition is a literal, and removes statements that follow a return,
+// break or continue in the same block.
+//
+// Folding evaluates the expression with the tree-walking evaluator, so a
+// folded value is always the one the program would have computed. An
+// expression that would fail at runtime, such as 1 / 0, is left alone so the
+// error is still reported where it happens.
+package optimizer
+
+import (
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/token"
+)
+
+// Optimize rewrites program in place and returns it.
+func Optimize(program *ast.Program) *ast.Program {
+	program.Statements = optimizeStatements(program.Statements)
+
+	return program
+}
+
+func optimizeStatements(stmts []ast.Statement) []ast.Statement {
+	result := make([]ast.Statement, 0, len(stmts))
+
+	for _, stmt := range stmts {
+		stmt = optimizeStatement(stmt)
+		result = append(result, stmt)
+
+		switch stmt.(type) {
+		case *ast.ReturnStatement, *ast.BreakStatement, *ast.ContinueStatement:
+			return result
+		}
+	}
+
+	return result
+}
+
+func optimizeStatement(stmt ast.Statement) ast.Statement {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		stmt.Value = optimizeExpression(stmt.Value)
+	case *ast.DestructuringStatement:
+		stmt.Value = optimizeExpression(stmt.Value)
+	case *ast.ReturnStatement:
+		stmt.ReturnValue = optimizeExpression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		stmt.Expression = optimizeExpression(stmt.Expression)
+	case *ast.WhileStatement:
+		stmt.Condition = optimizeExpression(stmt.Condition)
+		stmt.Body = optimizeBlock(stmt.Body)
+	case *ast.ForStatement:
+		if stmt.Init != nil {
+			stmt.Init = optimizeStatement(stmt.Init)
+		}
+
+		if stmt.Condition != nil {
+			stmt.Condition = optimizeExpression(stmt.Condition)
+		}
+
+		if stmt.Post != nil {
+			stmt.Post = optimizeExpression(stmt.Post)
+		}
+
+		stmt.Body = optimizeBlock(stmt.Body)
+	case *ast.ForInStatement:
+		stmt.Iterable = optimizeExpression(stmt.Iterable)
+		stmt.Body = optimizeBlock(stmt.Body)
+	case *ast.BlockStatement:
+		return optimizeBlock(stmt)
+	}
+
+	return stmt
+}
+
+func optimizeBlock(block *ast.BlockStatement) *ast.BlockStatement {
+	block.Statements = optimizeStatements(block.Statements)
+
+	return block
+}
+
+func optimizeExpression(exp ast.Expression) ast.Expression {
+	switch exp := exp.(type) {
+	case *ast.PrefixExpression:
+		exp.Right = optimizeExpression(exp.Right)
+
+		if isLiteral(exp.Right) {
+			return fold(exp, exp.Token)
+		}
+	case *ast.InfixExpression:
+		exp.Left = optimizeExpression(exp.Left)
+		exp.Right = optimizeExpression(exp.Right)
+
+		if isLiteral(exp.Left) && isLiteral(exp.Right) {
+			return fold(exp, exp.Token)
+		}
+	case *ast.AssignExpression:
+		exp.Value = optimizeExpression(exp.Value)
+	case *ast.IndexAssignment:
+		exp.Left = optimizeExpression(exp.Left)
+		exp.Index = optimizeExpression(exp.Index)
+		exp.Value = optimizeExpression(exp.Value)
+	case *ast.IfExpression:
+		return optimizeIfExpression(exp)
+	case *ast.ConditionalExpression:
+		exp.Condition = optimizeExpression(exp.Condition)
+		exp.Consequence = optimizeExpression(exp.Consequence)
+		exp.Alternative = optimizeExpression(exp.Alternative)
+
+		// Like an if, a literal condition selects its branch.
+		if isLiteral(exp.Condition) {
+			if condition, ok := exp.Condition.(*ast.Boolean); ok && !condition.Value {
+				return exp.Alternative
+			}
+
+			return exp.Consequence
+		}
+	case *ast.SwitchExpression:
+		exp.Subject = optimizeExpression(exp.Subject)
+
+		for _, c := range exp.Cases {
+			optimizeExpressions(c.Values)
+			c.Body = optimizeBlock(c.Body)
+		}
+
+		if exp.Default != nil {
+			exp.Default = optimizeBlock(exp.Default)
+		}
+	case *ast.TryExpression:
+		exp.Block = optimizeBlock(exp.Block)
+		exp.Catch = optimizeBlock(exp.Catch)
+	case *ast.FunctionLiteral:
+		for i, value := range exp.Defaults {
+			if value != nil {
+				exp.Defaults[i] = optimizeExpression(value)
+			}
+		}
+
+		exp.Body = optimizeBlock(exp.Body)
+	case *ast.SpreadExpression:
+		exp.Value = optimizeExpression(exp.Value)
+	case *ast.CallExpression:
+		// A quoted expression is data, not code to run.
+		if exp.Function.TokenLiteral() == "quote" {
+			return exp
+		}
+
+		exp.Function = optimizeExpression(exp.Function)
+		optimizeExpressions(exp.Arguments)
+
+		for _, arg := range exp.Named {
+			arg.Value = optimizeExpression(arg.Value)
+		}
+	case *ast.StringInterpolation:
+		optimizeExpressions(exp.Values)
+	case *ast.ArrayLiteral:
+		optimizeExpressions(exp.Elements)
+	case *ast.IndexExpression:
+		exp.Left = optimizeExpression(exp.Left)
+		exp.Index = optimizeExpression(exp.Index)
+	case *ast.SliceExpression:
+		exp.Left = optimizeExpression(exp.Left)
+
+		if exp.Low != nil {
+			exp.Low = optimizeExpression(exp.Low)
+		}
+
+		if exp.High != nil {
+			exp.High = optimizeExpression(exp.High)
+		}
+	case *ast.HashLiteral:
+		for i, pair := range exp.Pairs {
+			exp.Pairs[i] = ast.HashPair{
+				Key:   optimizeExpression(pair.Key),
+				Value: optimizeExpression(pair.Value),
+			}
+		}
+	}
+
+	return exp
+}
+
+func optimizeExpressions(exps []ast.Expression) {
+	for i, exp := range exps {
+		exps[i] = optimizeExpression(exp)
+	}
+}
+
+// optimizeIfExpression keeps only the branch a literal condition selects.
+// A branch that is a single expression replaces the whole if; otherwise the
+// if stays, with a true condition and no alternative, so the branch still
+// runs as a block.
+func optimizeIfExpression(ie *ast.IfExpression) ast.Expression {
+	ie.Condition = optimizeExpression(ie.Condition)
+	ie.Consequence = optimizeBlock(ie.Consequence)
+
+	if ie.Alternative != nil {
+		ie.Alternative = optimizeBlock(ie.Alternative)
+	}
+
+	if !isLiteral(ie.Condition) {
+		return ie
+	}
+
+	branch := ie.Consequence
+
+	if condition, ok := ie.Condition.(*ast.Boolean); ok && !condition.Value {
+		branch = ie.Alternative
+	}
+
+	if branch == nil {
+		ie.Consequence = &ast.BlockStatement{Token: ie.Consequence.Token}
+		ie.Alternative = nil
+
+		return ie
+	}
+
+	if len(branch.Statements) == 1 {
+		if stmt, ok := branch.Statements[0].(*ast.ExpressionStatement); ok {
+			return stmt.Expression
+		}
+	}
+
+	return &ast.IfExpression{
+		Token:       ie.Token,
+		Condition:   &ast.Boolean{Token: token.Token{Type: token.TRUE, Literal: "true"}, Value: true},
+		Consequence: branch,
+	}
+}
+
+func isLiteral(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	default:
+		return false
+	}
+}
+
+// fold evaluates exp, whose operands are literals, and returns the result as
+// a literal positioned at tok. exp is returned unchanged if evaluating it
+// fails.
+func fold(exp ast.Expression, tok token.Token) ast.Expression {
+	result := evaluator.Eval(exp, object.NewEnvironment())
+
+	switch result := result.(type) {
+	case *object.Integer:
+		tok.Type, tok.Literal = token.INT, result.Inspect()
+
+		return &ast.IntegerLiteral{Token: tok, Value: result.Value}
+	case *object.BigInt:
+		tok.Type, tok.Literal = token.INT, result.Inspect()
+
+		return &ast.IntegerLiteral{Token: tok, Big: result.Value}
+	case *object.Float:
+		tok.Type, tok.Literal = token.FLOAT, result.Inspect()
+
+		return &ast.FloatLiteral{Token: tok, Value: result.Value}
+	case *object.String:
+		tok.Type, tok.Literal = token.STRING, result.Value
+
+		return &ast.StringLiteral{Token: tok, Value: result.Value}
+	case *object.Boolean:
+		tok.Type, tok.Literal = token.FALSE, "false"
+
+		if result.Value {
+			tok.Type, tok.Literal = token.TRUE, "true"
+		}
+
+		return &ast.Boolean{Token: tok, Value: result.Value}
+	default:
+		return exp
+	}
+}
@@ -0,0 +1,92 @@
+package optimizer
+
+import (
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/printer"
+	"strings"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2 * 3", "6;"},
+		{"1 + 2 * 3 - 4", "3;"},
+		{"x + 2 * 3", "x + 6;"},
+		{"2 * 3 + x", "6 + x;"},
+		{"-(2 + 3)", "-5;"},
+		{"2 ** 64 + 18446744073709551616", "36893488147419103232;"},
+		{"!true", "false;"},
+		{"1 < 2 && 3 > 4", "false;"},
+		{`"foo" + "bar"`, `"foobar";`},
+		{"7 / 2.0", "3.5;"},
+		{"2 ** -1", "0.5;"},
+		{"1 / 0", "1 / 0;"},
+		{"1 + true", "1 + true;"},
+		{"6 & 3 | 1 << 4", "18;"},
+		{"1 << -1", "1 << -1;"},
+		{"let a = [1 + 1, {2 * 2: 3 - 1}]", "let a = [2, {4: 2}];"},
+		{"f(1 + 1)[2 - 1]", "f(2)[1];"},
+		{"quote(1 + 1)", "quote(1 + 1);"},
+		{"if (true) { 1 } else { 2 }", "1;"},
+		{"false ? 1 : 2 * 3", "6;"},
+		{"x ? 1 + 1 : 2", "x ? 2 : 2;"},
+		{"if (1 > 2) { 1 } else { 2 }", "2;"},
+		{"if (false) { 1 }", "if (false) {}"},
+		{"if (true) { let x = 1; x }", "if (true) {\n    let x = 1;\n    x;\n}"},
+		{"if (x) { 1 + 1 }", "if (x) {\n    2;\n}"},
+		{"fn() { return 1; 2; 3 }", "fn() {\n    return 1;\n};"},
+		{"while (x) { break; x = 1 }", "while (x) {\n    break;\n}"},
+		{"while (x) { if (x) { continue; 1 } }", "while (x) {\n    if (x) {\n        continue;\n    }\n}"},
+		{"return 1; puts(2)", "return 1;"},
+	}
+
+	for _, tt := range tests {
+		actual := strings.TrimSuffix(printer.Print(Optimize(parse(t, tt.input))), "\n")
+
+		if actual != tt.expected {
+			t.Errorf("Optimize(%q) wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+// TestOptimizeKeepsResults runs each program with and without optimizing
+// it and expects the same value.
+func TestOptimizeKeepsResults(t *testing.T) {
+	inputs := []string{
+		"let fib = fn(n) { if (n < 1 + 1) { n } else { fib(n - 1) + fib(n - 2) } }; fib(2 * 5)",
+		"let x = 10; if (2 > 1) { x = x + 1; x * 2 } else { 0 }",
+		"let f = fn() { if (true) { return 1; 2 } 3 }; f()",
+		"let sum = 0; for (let i = 0; i < 2 + 3; i++) { if (false) { break; } sum += i * (1 + 1); }; sum",
+		`"a" + "b" == "ab" && !(1 > 2)`,
+		"if (false) { 1 }",
+		"9223372036854775807 + 1",
+	}
+
+	for _, input := range inputs {
+		expected := evaluator.Eval(parse(t, input), object.NewEnvironment())
+		actual := evaluator.Eval(Optimize(parse(t, input)), object.NewEnvironment())
+
+		if actual.Inspect() != expected.Inspect() {
+			t.Errorf("optimizing %q changed the result. expected=%s, got=%s",
+				input, expected.Inspect(), actual.Inspect())
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+
+	return program
+}
@@ -0,0 +1,56 @@
+package parser
+
+import "monkey/ast"
+
+// A program is mostly identifiers, literals, operators, calls and the
+// statements around them, a few dozen bytes each. Allocating each on its
+// own makes parsing a large file spend much of its time in the allocator,
+// so the parser takes these from slabs instead: one allocation holds many
+// nodes of a type, handed out one at a time.
+
+const (
+	minSlab = 8
+	maxSlab = 256
+)
+
+// slab hands out pointers into a block of nodes of type T. Each new block
+// is twice the size of the last, up to maxSlab, so a short REPL line does
+// not allocate room for hundreds of nodes it will never use.
+//
+// A block stays in memory as long as any node in it does, so a function
+// value that outlives the rest of its program keeps some of its
+// neighbours' nodes alive too.
+type slab[T any] struct {
+	free []T
+	size int
+}
+
+// new returns a pointer to a node holding node.
+func (s *slab[T]) new(node T) *T {
+	if len(s.free) == 0 {
+		s.size = min(max(2*s.size, minSlab), maxSlab)
+		s.free = make([]T, s.size)
+	}
+
+	next := &s.free[0]
+	s.free = s.free[1:]
+	*next = node
+
+	return next
+}
+
+// arena holds a slab for each of the most common node types.
+type arena struct {
+	identifiers slab[ast.Identifier]
+	integers    slab[ast.IntegerLiteral]
+	strings     slab[ast.StringLiteral]
+	booleans    slab[ast.Boolean]
+	prefixes    slab[ast.PrefixExpression]
+	infixes     slab[ast.InfixExpression]
+	assigns     slab[ast.AssignExpression]
+	calls       slab[ast.CallExpression]
+	indexes     slab[ast.IndexExpression]
+	expressions slab[ast.ExpressionStatement]
+	lets        slab[ast.LetStatement]
+	blocks      slab[ast.BlockStatement]
+}
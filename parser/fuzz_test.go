@@ -0,0 +1,34 @@
+package parser
+
+import (
+	"monkey/lexer"
+	"testing"
+)
+
+func FuzzParser(f *testing.F) {
+	seeds := []string{
+		`let five = 5; let add = fn(x, y = 2, ...rest) { return x + y; };`,
+		`if (a < b) { a } else if (b) { b } else { c }`,
+		`for (let i = 0; i < 10; i += 1) { if (i == 3) { continue; } break; }`,
+		`for (x in [1, 2]) { puts(x) } while (true) { }`,
+		`let {a, b} = h; let [x, ...y] = arr; h.a.b = 1; arr[0] += 2;`,
+		`match (x) { 1 => "one", [a, b] => a, _ => null }`,
+		`try { throw("x") } catch (e) { e } finally { 1 }`,
+		`"a${x}b" 9223372036854775808 0xFFFFFFFFFFFFFFFFF 1e999 f(a: 1)`,
+		`macro(x) { quote(unquote(x) + 1) } x |> f ?? g?.h ... ~1 << 2`,
+		`let = ; fn( { [1, 2 "unterminated`,
+	}
+
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			_ = program.String()
+		}
+	})
+}
@@ -1,47 +1,119 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/token"
 	"strconv"
+	"strings"
 )
 
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // x = y
+	CONDITIONAL // x ? y : z
+	PIPE        // x |> f
+	COALESCE    // ??
+	OR          // ||
+	AND         // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
+	BIT_OR      // |
+	BIT_XOR     // ^
+	BIT_AND     // &
+	SHIFT       // << or >>
 	SUM         // +
 	PRODUCT     // *
 	PREFIX      // -x or !x
+	EXPONENT    // x ** y
+	POSTFIX     // x++ or x--
 	CALL        // myFunction(x)
+	INDEX       // array[index]
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
+	token.ASSIGN:      ASSIGN,
+	token.OR:          OR,
+	token.AND:         AND,
+	token.EQ:          EQUALS,
+	token.NOT_EQ:      EQUALS,
+	token.LT:          LESSGREATER,
+	token.GT:          LESSGREATER,
+	token.LT_EQ:       LESSGREATER,
+	token.GT_EQ:       LESSGREATER,
+	token.BIT_OR:      BIT_OR,
+	token.BIT_XOR:     BIT_XOR,
+	token.BIT_AND:     BIT_AND,
+	token.SHIFT_LEFT:  SHIFT,
+	token.SHIFT_RIGHT: SHIFT,
+	token.PLUS:        SUM,
+	token.MINUS:       SUM,
+	token.SLASH:       PRODUCT,
+	token.ASTERISK:    PRODUCT,
+	token.PERCENT:     PRODUCT,
+	token.POWER:       EXPONENT,
+	token.LPAREN:      CALL,
+	token.LBRACKET:    INDEX,
+	token.DOT:         INDEX,
+
+	token.OPTIONAL_LBRACKET: INDEX,
+	token.OPTIONAL_DOT:      INDEX,
+
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.INCREMENT:       POSTFIX,
+	token.DECREMENT:       POSTFIX,
+	token.COALESCE:        COALESCE,
+	token.QUESTION:        CONDITIONAL,
+	token.PIPE:            PIPE,
+}
+
+// Error is a parser error together with the token it was found at, for
+// tools that need to place errors in the source rather than print them.
+type Error struct {
+	Token   token.Token
+	Message string
 }
 
 type Parser struct {
-	errors         []string
-	l              *lexer.Lexer
-	curToken       token.Token
-	peekToken      token.Token
-	prefixParseFns map[token.TokenType]prefixParseFn
-	infixParseFns  map[token.TokenType]infixParseFn
+	errors          []string
+	details         []Error
+	l               *lexer.Lexer
+	curToken        token.Token
+	peekToken       token.Token
+	prefixParseFns  map[token.TokenType]prefixParseFn
+	infixParseFns   map[token.TokenType]infixParseFn
+	postfixParseFns map[token.TokenType]postfixParseFn
+
+	// loopDepth counts the loops enclosing the current statement so break
+	// and continue can be rejected outside of them. Function literals reset
+	// it, since a loop never continues across a function boundary.
+	loopDepth int
+
+	// blockDepth counts the blocks enclosing the current statement, so
+	// that recovering from an error can tell a closing brace that ends
+	// one from a stray brace at the top level.
+	blockDepth int
+
+	// recovering is set by a syntax error, after which the parser has lost
+	// its place in the statement. Errors are not recorded until the
+	// statement loop skips to the next statement, since they would only
+	// follow from the first one.
+	recovering bool
+
+	// nodes holds the slabs the most common nodes are allocated from.
+	nodes arena
 }
 
 type prefixParseFn func() ast.Expression
 type infixParseFn func(ast.Expression) ast.Expression
+type postfixParseFn func(ast.Expression) ast.Expression
 
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
@@ -51,6 +123,7 @@ func New(l *lexer.Lexer) *Parser {
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
+	p.postfixParseFns = make(map[token.TokenType]postfixParseFn)
 
 	// Identifier
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
@@ -58,12 +131,17 @@ func New(l *lexer.Lexer) *Parser {
 	// Integer Literal
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
 
+	// Float Literal
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+
 	// String Literal
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.INTERP_START, p.parseStringInterpolation)
 
 	// Prefix Expressions
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.BIT_NOT, p.parsePrefixExpression)
 
 	// Grouped Expressions
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
@@ -75,6 +153,25 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 
+	// Arrays
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parsePropertyExpression)
+	p.registerInfix(token.OPTIONAL_LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.OPTIONAL_DOT, p.parsePropertyExpression)
+
+	// Hashes
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+
+	// Macros
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
+
+	// Switch
+	p.registerPrefix(token.SWITCH, p.parseSwitchExpression)
+
+	// Error handling
+	p.registerPrefix(token.TRY, p.parseTryExpression)
+
 	// Boolean
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
@@ -84,10 +181,35 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.BIT_OR, p.parseInfixExpression)
+	p.registerInfix(token.BIT_XOR, p.parseInfixExpression)
+	p.registerInfix(token.BIT_AND, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_RIGHT, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.COALESCE, p.parseInfixExpression)
+	p.registerInfix(token.QUESTION, p.parseConditionalExpression)
+	p.registerInfix(token.PIPE, p.parsePipeExpression)
+
+	// Assignment
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseCompoundAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseCompoundAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseCompoundAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseCompoundAssignExpression)
+
+	// Postfix Expressions
+	p.registerPostfix(token.INCREMENT, p.parsePostfixExpression)
+	p.registerPostfix(token.DECREMENT, p.parsePostfixExpression)
 
 	// Read two tokens, so curToken and peekToken are both set
 	p.nextToken()
@@ -97,10 +219,10 @@ func New(l *lexer.Lexer) *Parser {
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
-	expression := &ast.PrefixExpression{
+	expression := p.nodes.prefixes.new(ast.PrefixExpression{
 		Token:    p.curToken,
 		Operator: p.curToken.Literal,
-	}
+	})
 
 	p.nextToken()
 
@@ -110,14 +232,20 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 }
 
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
-	expression := &ast.InfixExpression{
+	expression := p.nodes.infixes.new(ast.InfixExpression{
 		Token:    p.curToken,
 		Operator: p.curToken.Literal,
 		Left:     left,
-	}
+	})
 
 	precedence := p.curPrecedence()
 
+	// ** is right-associative: parsing the right side one level lower lets
+	// it absorb further ** operators, so 2 ** 3 ** 2 is 2 ** (3 ** 2).
+	if p.curTokenIs(token.POWER) {
+		precedence--
+	}
+
 	p.nextToken()
 
 	expression.Right = p.parseExpression(precedence)
@@ -125,6 +253,135 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// parsePipeExpression desugars x |> f(a) into f(x, a), and x |> f into
+// f(x), so nothing past the parser needs to know about pipes. |> is
+// left-associative, so x |> f |> g is g(f(x)).
+func (p *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+	tok := p.curToken
+	precedence := p.curPrecedence()
+
+	p.nextToken()
+
+	right := p.parseExpression(precedence)
+
+	// A call that is itself a pipe, as in x |> (y |> f), returns the
+	// function to call, so it is not given another argument.
+	if call, ok := right.(*ast.CallExpression); ok && !call.Pipe {
+		call.Arguments = append([]ast.Expression{left}, call.Arguments...)
+		call.Pipe = true
+
+		return call
+	}
+
+	return p.nodes.calls.new(ast.CallExpression{Token: tok, Function: right, Arguments: []ast.Expression{left}, Pipe: true})
+}
+
+// parseCompoundAssignExpression desugars x += y into x = x + y, and likewise
+// for -=, *= and /=, so nothing past the parser needs to know about them.
+// For a[i] += y the desugared form evaluates a and i twice.
+func (p *Parser) parseCompoundAssignExpression(left ast.Expression) ast.Expression {
+	ident, isIdent := left.(*ast.Identifier)
+	index, isIndex := left.(*ast.IndexExpression)
+
+	if !isIdent && (!isIndex || index.Optional) {
+		p.errorAt(p.curToken, "cannot assign to %s", left.String())
+
+		return nil
+	}
+
+	operatorToken := p.curToken
+	operator := strings.TrimSuffix(operatorToken.Literal, "=")
+
+	p.nextToken()
+
+	value := p.nodes.infixes.new(ast.InfixExpression{
+		Token:    operatorToken,
+		Left:     left,
+		Operator: operator,
+		Right:    p.parseExpression(ASSIGN - 1),
+	})
+
+	if isIndex {
+		return &ast.IndexAssignment{Token: operatorToken, Left: index.Left, Index: index.Index, Value: value}
+	}
+
+	return p.nodes.assigns.new(ast.AssignExpression{Token: operatorToken, Name: ident, Value: value})
+}
+
+func (p *Parser) parsePostfixExpression(left ast.Expression) ast.Expression {
+	ident, ok := left.(*ast.Identifier)
+
+	if !ok {
+		p.errorAt(p.curToken, "cannot apply %s to %s", p.curToken.Literal, left.String())
+
+		return nil
+	}
+
+	return &ast.PostfixExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Name:     ident,
+	}
+}
+
+// parseConditionalExpression parses the branches of condition ? a : b. The
+// alternative is parsed one level lower so that conditionals nest to the
+// right: a ? b : c ? d : e is a ? b : (c ? d : e).
+func (p *Parser) parseConditionalExpression(condition ast.Expression) ast.Expression {
+	expression := &ast.ConditionalExpression{Token: p.curToken, Condition: condition}
+
+	p.nextToken()
+
+	expression.Consequence = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+
+	p.nextToken()
+
+	expression.Alternative = p.parseExpression(CONDITIONAL - 1)
+
+	return expression
+}
+
+// parseAssignExpression parses the right-hand side one precedence level lower
+// than ASSIGN so that a = b = c groups as a = (b = c).
+func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	if index, ok := left.(*ast.IndexExpression); ok && !index.Optional {
+		expression := &ast.IndexAssignment{
+			Token: p.curToken,
+			Left:  index.Left,
+			Index: index.Index,
+		}
+
+		p.nextToken()
+
+		expression.Value = p.parseExpression(ASSIGN - 1)
+
+		return expression
+	}
+
+	ident, ok := left.(*ast.Identifier)
+
+	if !ok {
+		p.errorAt(p.curToken, "cannot assign to %s", left.String())
+
+		return nil
+	}
+
+	expression := p.nodes.assigns.new(ast.AssignExpression{
+		Token: p.curToken,
+		Name:  ident,
+	})
+
+	p.nextToken()
+
+	expression.Value = p.parseExpression(ASSIGN - 1)
+
+	return expression
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	p.nextToken()
 
@@ -138,16 +395,18 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
+	if p.illegalError(p.curToken) {
+		return
+	}
 
-	p.errors = append(p.errors, msg)
+	p.syntaxError(p.curToken, "no prefix parse function for %s found", t)
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{
+	return p.nodes.identifiers.new(ast.Identifier{
 		Token: p.curToken,
 		Value: p.curToken.Literal,
-	}
+	})
 }
 
 func (p *Parser) nextToken() {
@@ -160,11 +419,17 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for !p.curTokenIs(token.EOF) {
+		start := p.curToken
 		stmt := p.parseStatement()
 
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
+
+		if p.recovering && p.synchronize(start) {
+			continue
+		}
+
 		p.nextToken()
 	}
 
@@ -173,10 +438,30 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
-	case token.LET:
+	case token.LET, token.CONST:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.IDENT:
+		// do and loop can still name things. They start a loop only before
+		// a brace, which a name at the start of a statement is never
+		// followed by.
+		switch {
+		case p.curToken.Literal == "do" && p.peekTokenIs(token.LBRACE):
+			return p.parseDoWhileStatement()
+		case p.curToken.Literal == "loop" && p.peekTokenIs(token.LBRACE):
+			return p.parseLoopStatement()
+		}
+
+		return p.parseExpressionStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK:
+		return p.parseBreakStatement()
+	case token.CONTINUE:
+		return p.parseContinueStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -191,9 +476,21 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		return nil
 	}
 
+	errors := len(p.errors)
 	leftExp := prefix()
 
-	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
+	// An operand that failed to parse, in whole or in part, has been
+	// reported already; the operators after it are not applied to it, as
+	// they could not make sense of it.
+	for len(p.errors) == errors && !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
+		if postfix, ok := p.postfixParseFns[p.peekToken.Type]; ok {
+			p.nextToken()
+
+			leftExp = postfix(leftExp)
+
+			continue
+		}
+
 		infix := p.infixParseFns[p.peekToken.Type]
 
 		if infix == nil {
@@ -207,14 +504,78 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	return leftExp
 }
 
-func (p *Parser) parseLetStatement() *ast.LetStatement {
-	stmt := &ast.LetStatement{Token: p.curToken}
+// parseLetStatement parses let name = value and the destructuring forms,
+// let [a, b] = value, let {a, b} = value and let a, b = value, which is
+// the first without its brackets. It returns an ast.Statement, not a
+// pointer that would make a non-nil Statement when nil.
+func (p *Parser) parseLetStatement() ast.Statement {
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		destructuring := &ast.DestructuringStatement{Token: p.curToken, Constant: p.curTokenIs(token.CONST)}
+
+		p.nextToken()
+
+		destructuring.Hash = p.curTokenIs(token.LBRACE)
+
+		if destructuring.Hash {
+			return p.parseDestructuringStatement(destructuring, token.RBRACE)
+		}
+
+		return p.parseDestructuringStatement(destructuring, token.RBRACKET)
+	}
+
+	stmt := p.nodes.lets.new(ast.LetStatement{Token: p.curToken, Constant: p.curTokenIs(token.CONST)})
 
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	stmt.Name = p.nodes.identifiers.new(ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+	if p.peekTokenIs(token.COMMA) {
+		destructuring := &ast.DestructuringStatement{Token: stmt.Token, Constant: stmt.Constant, Bare: true, Names: []*ast.Identifier{stmt.Name}}
+
+		return p.parseDestructuringStatement(destructuring, "")
+	}
+
+	if !p.expectPeek(token.ASSIGN) {
+		return nil
+	}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseDestructuringStatement reads the rest of a destructuring let: the
+// names of its pattern after those stmt has, which is one or more in all,
+// up to the end of the pattern, or to the = for a pattern without brackets,
+// when end is "", and then the value.
+func (p *Parser) parseDestructuringStatement(stmt *ast.DestructuringStatement, end token.TokenType) ast.Statement {
+	for len(stmt.Names) == 0 || p.peekTokenIs(token.COMMA) {
+		if len(stmt.Names) > 0 {
+			p.nextToken()
+		}
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		stmt.Names = append(stmt.Names, p.nodes.identifiers.new(ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}))
+	}
+
+	if end != "" && !p.expectPeek(end) {
+		return nil
+	}
 
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -231,6 +592,10 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+// parseReturnStatement parses return value, and return a, b, which returns
+// the array [a, b] for the caller to destructure, as in let q, r = f(). The
+// array's token is the return, which tells the printer to leave out the
+// brackets.
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
@@ -238,6 +603,19 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
+	if p.peekTokenIs(token.COMMA) {
+		values := &ast.ArrayLiteral{Token: stmt.Token, Elements: []ast.Expression{stmt.ReturnValue}}
+
+		for p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+			p.nextToken()
+
+			values.Elements = append(values.Elements, p.parseExpression(LOWEST))
+		}
+
+		stmt.ReturnValue = values
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -246,7 +624,7 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 }
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
-	stmt := &ast.ExpressionStatement{Token: p.curToken}
+	stmt := p.nodes.expressions.new(ast.ExpressionStatement{Token: p.curToken})
 
 	stmt.Expression = p.parseExpression(LOWEST)
 
@@ -258,15 +636,40 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
-	lit := &ast.IntegerLiteral{
+	lit := p.nodes.integers.new(ast.IntegerLiteral{
 		Token: p.curToken,
-	}
+	})
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 
+	// A literal too wide for an int64 is a big integer.
+	if errors.Is(err, strconv.ErrRange) {
+		if wide, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			lit.Big = wide
+
+			return lit
+		}
+	}
+
+	if err != nil {
+		p.errorAt(p.curToken, "could not parse %q as integer", p.curToken.Literal)
+
+		return nil
+	}
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{
+		Token: p.curToken,
+	}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.errorAt(p.curToken, "could not parse %q as float", p.curToken.Literal)
 
 		return nil
 	}
@@ -276,10 +679,10 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 }
 
 func (p *Parser) parseBoolean() ast.Expression {
-	return &ast.Boolean{
+	return p.nodes.booleans.new(ast.Boolean{
 		Token: p.curToken,
 		Value: p.curTokenIs(token.TRUE),
-	}
+	})
 }
 
 func (p *Parser) parseIfExpression() ast.Expression {
@@ -318,109 +721,719 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
-func (p *Parser) parseBlockStatement() *ast.BlockStatement {
-	block := &ast.BlockStatement{
-		Token: p.curToken,
+func (p *Parser) parseSwitchExpression() ast.Expression {
+	expression := &ast.SwitchExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
 	}
 
-	block.Statements = []ast.Statement{}
+	p.nextToken()
+	expression.Subject = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
 
 	p.nextToken()
 
-	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+	for !p.curTokenIs(token.RBRACE) {
+		switch p.curToken.Type {
+		case token.CASE:
+			c := &ast.SwitchCase{Token: p.curToken}
+
+			p.nextToken()
+			c.Values = append(c.Values, p.parseExpression(LOWEST))
+
+			for p.peekTokenIs(token.COMMA) {
+				p.nextToken()
+				p.nextToken()
+				c.Values = append(c.Values, p.parseExpression(LOWEST))
+			}
+
+			if !p.expectPeek(token.COLON) {
+				return nil
+			}
+
+			c.Body = p.parseCaseBody()
+			expression.Cases = append(expression.Cases, c)
+		case token.DEFAULT:
+			if expression.Default != nil {
+				p.errorAt(p.curToken, "multiple defaults in switch")
+			}
+
+			if !p.expectPeek(token.COLON) {
+				return nil
+			}
+
+			expression.Default = p.parseCaseBody()
+		case token.EOF:
+			p.syntaxError(p.curToken, "unterminated switch")
+
+			return nil
+		default:
+			p.syntaxError(p.curToken, "expected case or default, got %s instead", p.curToken.Type)
+
+			return nil
+		}
+	}
+
+	expression.Closing = p.curToken
+
+	return expression
+}
+
+// parseCaseBody parses the statements after a case or default label, up to
+// the next label or the closing brace of the switch. The current token is
+// the label's colon.
+func (p *Parser) parseCaseBody() *ast.BlockStatement {
+	block := p.nodes.blocks.new(ast.BlockStatement{Token: p.curToken, Statements: []ast.Statement{}})
+
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
+
+	p.nextToken()
+
+	for !p.curTokenIs(token.CASE) && !p.curTokenIs(token.DEFAULT) &&
+		!p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		start := p.curToken
 		stmt := p.parseStatement()
 
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 
+		if p.recovering && p.synchronize(start) {
+			continue
+		}
+
 		p.nextToken()
 	}
 
 	return block
 }
 
-func (p *Parser) parseFunctionLiteral() ast.Expression {
-	lit := &ast.FunctionLiteral{Token: p.curToken}
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
 
-	if !p.expectPeek(token.LPAREN) {
+	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	expression.Block = p.parseBlockStatement()
 
-	if !p.expectPeek(token.LBRACE) {
+	if !p.expectPeek(token.CATCH) {
 		return nil
 	}
 
-	lit.Body = p.parseBlockStatement()
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
 
-	return lit
-}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+	expression.Parameter = p.nodes.identifiers.new(ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
 
-	if p.peekTokenIs(token.RPAREN) {
-		p.nextToken()
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
 
-		return identifiers
+	if !p.expectPeek(token.LBRACE) {
+		return nil
 	}
 
-	p.nextToken()
+	expression.Catch = p.parseBlockStatement()
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return expression
+}
 
-	identifiers = append(identifiers, ident)
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{
+		Token: p.curToken,
+	}
 
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
-		p.nextToken()
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
 
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	p.nextToken()
 
-		identifiers = append(identifiers, ident)
-	}
+	stmt.Condition = p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RPAREN) {
 		return nil
 	}
 
-	return identifiers
-}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
 
-func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
-	exp := &ast.CallExpression{Token: p.curToken, Function: function}
+	stmt.Body = p.parseLoopBody()
 
-	exp.Arguments = p.parseCallArguments()
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
 
-	return exp
+	return stmt
 }
 
-func (p *Parser) parseCallArguments() []ast.Expression {
-	args := []ast.Expression{}
-
-	if p.peekTokenIs(token.RPAREN) {
-		p.nextToken()
-
-		return args
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{
+		Token: p.curToken,
 	}
-	p.nextToken()
 
-	args = append(args, p.parseExpression(LOWEST))
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
 
-	for p.peekTokenIs(token.COMMA) {
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
+	} else {
 		p.nextToken()
 
-		args = append(args, p.parseExpression(LOWEST))
-	}
+		stmt.Init = p.parseStatement()
 
-	if !p.expectPeek(token.RPAREN) {
-		return nil
-	}
+		if p.peekTokenIs(token.IN) {
+			return p.parseForInStatement(stmt.Token, stmt.Init)
+		}
+
+		if !p.curTokenIs(token.SEMICOLON) && !p.expectPeek(token.SEMICOLON) {
+			return nil
+		}
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	} else {
+		p.nextToken()
+
+		stmt.Condition = p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.SEMICOLON) {
+			return nil
+		}
+	}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+	} else {
+		p.nextToken()
+
+		stmt.Post = p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseForInStatement parses the rest of for (x in xs) { ... }, once the
+// for statement's init clause turned out to be the loop variable.
+func (p *Parser) parseForInStatement(tok token.Token, init ast.Statement) ast.Statement {
+	stmt := &ast.ForInStatement{Token: tok}
+
+	if es, ok := init.(*ast.ExpressionStatement); ok {
+		stmt.Name, _ = es.Expression.(*ast.Identifier)
+	}
+
+	p.nextToken()
+
+	if stmt.Name == nil {
+		p.syntaxError(p.curToken, "expected a name before in")
+
+		return nil
+	}
+
+	p.nextToken()
+
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) || !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseDoWhileStatement parses do { ... } while (condition), which runs the
+// body before it first checks the condition.
+func (p *Parser) parseDoWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken, Do: true}
+	stmt.Token.Type = token.DO
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if !p.expectPeek(token.WHILE) || !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	stmt.Closing = p.curToken
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseLoopStatement parses loop { ... }, which runs until a break or return
+// leaves it, as while (true) { ... } does.
+func (p *Parser) parseLoopStatement() ast.Statement {
+	tok := p.curToken
+	tok.Type = token.LOOP
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	body := p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	// The condition has no source of its own, so it is placed at the
+	// keyword.
+	condition := tok
+	condition.Type, condition.Literal = token.TRUE, "true"
+
+	return &ast.WhileStatement{
+		Token:     tok,
+		Condition: &ast.Boolean{Token: condition, Value: true},
+		Body:      body,
+	}
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseBreakStatement() ast.Statement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		p.errorAt(p.curToken, "break outside of a loop")
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() ast.Statement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		p.errorAt(p.curToken, "continue outside of a loop")
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	block := p.nodes.blocks.new(ast.BlockStatement{
+		Token: p.curToken,
+	})
+
+	block.Statements = []ast.Statement{}
+
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
+
+	p.nextToken()
+
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		start := p.curToken
+		stmt := p.parseStatement()
+
+		if stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+
+		if p.recovering && p.synchronize(start) {
+			continue
+		}
+
+		p.nextToken()
+	}
+
+	if p.curTokenIs(token.RBRACE) {
+		block.Closing = p.curToken
+	}
+
+	return block
+}
+
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	lit := &ast.FunctionLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	lit.Parameters, lit.Defaults, lit.Variadic = p.parseFunctionParameters()
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+
+	lit.Body = p.parseBlockStatement()
+
+	p.loopDepth = loopDepth
+
+	return lit
+}
+
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	tok := p.peekToken
+
+	var defaults []ast.Expression
+	var variadic bool
+
+	lit.Parameters, defaults, variadic = p.parseFunctionParameters()
+
+	if variadic {
+		p.errorAt(tok, "macro parameters cannot be variadic")
+	}
+
+	if defaults != nil {
+		p.errorAt(tok, "macro parameters cannot have defaults")
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+
+	lit.Body = p.parseBlockStatement()
+
+	p.loopDepth = loopDepth
 
-	return args
+	return lit
+}
+
+// parseFunctionParameters also returns the parameters' default values,
+// which are nil when no parameter has one, and whether the last parameter
+// is variadic, written ...name. Once a parameter has a default, every
+// parameter after it but a variadic one needs one too.
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, []ast.Expression, bool) {
+	identifiers := []*ast.Identifier{}
+	defaults := []ast.Expression{}
+	hasDefaults := false
+	variadic := false
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+
+		return identifiers, nil, variadic
+	}
+
+	for {
+		p.nextToken()
+
+		if variadic {
+			p.errorAt(p.curToken, "variadic parameter must be last")
+		}
+
+		if p.curTokenIs(token.ELLIPSIS) {
+			variadic = true
+
+			p.nextToken()
+		}
+
+		ident := p.nodes.identifiers.new(ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		var value ast.Expression
+
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+
+			if variadic {
+				p.errorAt(p.curToken, "variadic parameter cannot have a default")
+			}
+
+			p.nextToken()
+
+			value = p.parseExpression(LOWEST)
+			hasDefaults = true
+		} else if hasDefaults && !variadic {
+			p.errorAt(ident.Token, "parameter %s needs a default, as it follows one that has one", ident.Value)
+		}
+
+		identifiers = append(identifiers, ident)
+		defaults = append(defaults, value)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil, nil, false
+	}
+
+	if !hasDefaults {
+		defaults = nil
+	}
+
+	return identifiers, defaults, variadic
+}
+
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	exp := p.nodes.calls.new(ast.CallExpression{Token: p.curToken, Function: function})
+
+	exp.Arguments, exp.Named = p.parseCallArguments()
+
+	if p.curTokenIs(token.RPAREN) {
+		exp.Closing = p.curToken
+	}
+
+	return exp
+}
+
+// parseCallArguments parses a call's arguments up to and including the
+// closing parenthesis. An argument may be a spread, ...array, or named,
+// name: value. Named arguments come last and are not mixed with spreads.
+func (p *Parser) parseCallArguments() ([]ast.Expression, []*ast.NamedArgument) {
+	args := []ast.Expression{}
+	var named []*ast.NamedArgument
+	var spread token.Token
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+
+		return args, named
+	}
+
+	for {
+		p.nextToken()
+
+		switch {
+		case p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COLON):
+			arg := &ast.NamedArgument{Token: p.curToken, Name: p.curToken.Literal}
+
+			if spread.Type == token.ELLIPSIS {
+				p.errorAt(arg.Token, "named argument %s cannot follow a spread argument", arg.Name)
+			}
+
+			p.nextToken()
+			p.nextToken()
+
+			arg.Value = p.parseExpression(LOWEST)
+			named = append(named, arg)
+		case p.curTokenIs(token.ELLIPSIS):
+			spread = p.curToken
+
+			if named != nil {
+				p.errorAt(spread, "spread argument cannot follow a named argument")
+			}
+
+			p.nextToken()
+
+			args = append(args, &ast.SpreadExpression{Token: spread, Value: p.parseExpression(LOWEST)})
+		default:
+			if named != nil {
+				p.errorAt(p.curToken, "positional argument cannot follow a named argument")
+			}
+
+			args = append(args, p.parseExpression(LOWEST))
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil, nil
+	}
+
+	return args, named
+}
+
+// parseExpressionList parses comma separated expressions up to and including
+// the end token, as used by array literals.
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	list := []ast.Expression{}
+
+	if p.peekTokenIs(end) {
+		p.nextToken()
+
+		return list
+	}
+	p.nextToken()
+
+	list = append(list, p.parseExpression(LOWEST))
+
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+
+		list = append(list, p.parseExpression(LOWEST))
+	}
+
+	if !p.expectPeek(end) {
+		return nil
+	}
+
+	return list
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+
+	if p.curTokenIs(token.RBRACKET) {
+		array.Closing = p.curToken
+	}
+
+	return array
+}
+
+// parseIndexExpression parses left[index], or left[low:high] where either
+// bound may be left out, and their optional forms opened by ?[.
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	tok := p.curToken
+	optional := tok.Type == token.OPTIONAL_LBRACKET
+
+	var index ast.Expression
+
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		index = p.parseExpression(LOWEST)
+	}
+
+	if !p.peekTokenIs(token.COLON) {
+		if !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+
+		return p.nodes.indexes.new(ast.IndexExpression{Token: tok, Left: left, Index: index, Optional: optional, Closing: p.curToken})
+	}
+
+	p.nextToken()
+
+	slice := &ast.SliceExpression{Token: tok, Left: left, Low: index, Optional: optional}
+
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		slice.High = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	slice.Closing = p.curToken
+
+	return slice
+}
+
+// parsePropertyExpression reads left.name as left["name"], so field access
+// and assignment, and calls of functions kept in a hash, need nothing past
+// the parser. The name's token stays IDENT, which tells the printer to put
+// the dot back. left?.name is read as left?["name"].
+func (p *Parser) parsePropertyExpression(left ast.Expression) ast.Expression {
+	tok := p.curToken
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	name := p.nodes.strings.new(ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal})
+
+	return p.nodes.indexes.new(ast.IndexExpression{Token: tok, Left: left, Index: name, Optional: tok.Type == token.OPTIONAL_DOT})
+}
+
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	hash.Closing = p.curToken
+
+	return hash
 }
 
 func (p *Parser) curTokenIs(t token.TokenType) bool {
@@ -451,6 +1464,10 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 	p.infixParseFns[tokenType] = fn
 }
 
+func (p *Parser) registerPostfix(tokenType token.TokenType, fn postfixParseFn) {
+	p.postfixParseFns[tokenType] = fn
+}
+
 func (p *Parser) peekPrecedence() int {
 	if p, ok := precedences[p.peekToken.Type]; ok {
 		return p
@@ -471,12 +1488,147 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// ErrorDetails returns the same errors as Errors, with the position kept
+// apart from the message.
+func (p *Parser) ErrorDetails() []Error {
+	return p.details
+}
+
+// peekError reports that the next token is not t, or, if it is ILLEGAL,
+// what is wrong with it, which says more than that it was not expected.
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
+	if p.illegalError(p.peekToken) {
+		return
+	}
 
-	p.errors = append(p.errors, msg)
+	p.syntaxError(p.peekToken, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
+}
+
+// illegalError reports tok as a syntax error if it is ILLEGAL, whose
+// literal is the lexer's message saying what is wrong with it.
+func (p *Parser) illegalError(tok token.Token) bool {
+	if tok.Type != token.ILLEGAL {
+		return false
+	}
+
+	p.syntaxError(tok, "%s", tok.Literal)
+
+	return true
+}
+
+// errorAt records a parser error prefixed with the position of tok. An
+// error repeating the last one is dropped, as happens when recovery stops
+// at the token that caused it and it fails to start a statement too.
+func (p *Parser) errorAt(tok token.Token, format string, a ...interface{}) {
+	if p.recovering {
+		return
+	}
+
+	msg := fmt.Sprintf(format, a...)
+
+	if last := len(p.details) - 1; last >= 0 && p.details[last] == (Error{Token: tok, Message: msg}) {
+		return
+	}
+	prefix := fmt.Sprintf("parse error at line %d, col %d: ", tok.Line, tok.Column)
+
+	p.errors = append(p.errors, prefix+msg)
+	p.details = append(p.details, Error{Token: tok, Message: msg})
+}
+
+// syntaxError records an error after which the parser cannot make sense of
+// the rest of the statement, and starts recovering from it.
+func (p *Parser) syntaxError(tok token.Token, format string, a ...interface{}) {
+	p.errorAt(tok, format, a...)
+	p.recovering = true
+}
+
+// synchronize skips the rest of the statement begun at start, which had a
+// syntax error: up to a semicolon, or to a keyword only a new statement or
+// switch case can start with, ignoring any inside brackets opened on the
+// way. It reports whether it stopped at a token the statement loop must not
+// step over: the keyword, or a closing brace that ends the enclosing block.
+func (p *Parser) synchronize(start token.Token) bool {
+	p.recovering = false
+	depth := 0
+
+	for !p.curTokenIs(token.EOF) {
+		// Stopping at the statement's own keyword would parse it again.
+		if depth == 0 && statementKeywords[p.curToken.Type] && p.curToken != start {
+			return true
+		}
+
+		switch p.curToken.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACKET:
+			if depth > 0 {
+				depth--
+			}
+		case token.RBRACE:
+			if depth > 0 {
+				depth--
+			} else if p.blockDepth > 0 {
+				return true
+			}
+		case token.SEMICOLON:
+			if depth == 0 {
+				return false
+			}
+		}
+
+		p.nextToken()
+	}
+
+	return false
+}
+
+// statementKeywords are the tokens synchronize stops at.
+var statementKeywords = map[token.TokenType]bool{
+	token.LET:      true,
+	token.CONST:    true,
+	token.RETURN:   true,
+	token.WHILE:    true,
+	token.FOR:      true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
+	token.CASE:     true,
+	token.DEFAULT:  true,
 }
 
 func (p *Parser) parseStringLiteral() ast.Expression {
-	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	return p.nodes.strings.new(ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal})
+}
+
+// parseStringInterpolation parses the expressions embedded in a string,
+// from its INTERP_START token to its INTERP_END.
+func (p *Parser) parseStringInterpolation() ast.Expression {
+	exp := &ast.StringInterpolation{Token: p.curToken, Strings: []string{p.curToken.Literal}}
+
+	for !p.curTokenIs(token.INTERP_END) {
+		p.nextToken()
+
+		if p.curTokenIs(token.INTERP_MID) || p.curTokenIs(token.INTERP_END) {
+			p.syntaxError(p.curToken, "empty interpolation")
+
+			return nil
+		}
+
+		exp.Values = append(exp.Values, p.parseExpression(LOWEST))
+
+		if !p.peekTokenIs(token.INTERP_MID) && !p.peekTokenIs(token.INTERP_END) {
+			if !p.illegalError(p.peekToken) {
+				p.syntaxError(p.peekToken, "expected } to end interpolation, got %s instead", p.peekToken.Type)
+			}
+
+			return nil
+		}
+
+		p.nextToken()
+
+		exp.Strings = append(exp.Strings, p.curToken.Literal)
+	}
+
+	exp.Closing = p.curToken
+
+	return exp
 }
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"monkey/ast"
 	"monkey/lexer"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -41,6 +43,68 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestConstStatements(t *testing.T) {
+	program := New(lexer.New("const x = 5; let y = x;")).ParseProgram()
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	constStmt := program.Statements[0].(*ast.LetStatement)
+
+	if !constStmt.Constant || constStmt.String() != "const x = 5;" {
+		t.Errorf("wrong const statement. constant=%t, got=%q", constStmt.Constant, constStmt.String())
+	}
+
+	if program.Statements[1].(*ast.LetStatement).Constant {
+		t.Errorf("let statement should not be constant")
+	}
+}
+
+func TestDestructuringStatements(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedNames []string
+		expectedHash  bool
+		expected      string
+	}{
+		{"let [a, b, c] = xs;", []string{"a", "b", "c"}, false, "let [a, b, c] = xs;"},
+		{"let {name, age} = person", []string{"name", "age"}, true, "let {name, age} = person;"},
+		{"const [first] = f(1)", []string{"first"}, false, "const [first] = f(1);"},
+		{"let q, r = divmod(7, 2);", []string{"q", "r"}, false, "let q, r = divmod(7, 2);"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.DestructuringStatement)
+
+		if !ok {
+			t.Fatalf("stmt is not *ast.DestructuringStatement. got=%T", program.Statements[0])
+		}
+
+		var names []string
+
+		for _, name := range stmt.Names {
+			names = append(names, name.Value)
+		}
+
+		if !reflect.DeepEqual(names, tt.expectedNames) || stmt.Hash != tt.expectedHash {
+			t.Errorf("wrong pattern. expected=%v (hash=%t), got=%v (hash=%t)", tt.expectedNames, tt.expectedHash, names, stmt.Hash)
+		}
+
+		if stmt.String() != tt.expected {
+			t.Errorf("wrong String(). expected=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+}
+
 func testLetStatement(
 	t *testing.T,
 	s ast.Statement,
@@ -96,6 +160,42 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestStringInterpolationParsing(t *testing.T) {
+	input := `"Hello, ${name}! You are ${age + 1}";`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+	exp, ok := stmt.Expression.(*ast.StringInterpolation)
+
+	if !ok {
+		t.Fatalf("exp not *ast.StringInterpolation. got=%T", stmt.Expression)
+	}
+
+	expectedStrings := []string{"Hello, ", "! You are ", ""}
+
+	if len(exp.Strings) != len(expectedStrings) {
+		t.Fatalf("wrong length of strings. want %d, got=%d", len(expectedStrings), len(exp.Strings))
+	}
+
+	for i, s := range expectedStrings {
+		if exp.Strings[i] != s {
+			t.Errorf("exp.Strings[%d] not %q. got=%q", i, s, exp.Strings[i])
+		}
+	}
+
+	if len(exp.Values) != 2 {
+		t.Fatalf("wrong length of values. got=%d", len(exp.Values))
+	}
+
+	testIdentifier(t, exp.Values[0], "name")
+	testInfixExpression(t, exp.Values[1], "age", "+", 1)
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -132,6 +232,27 @@ func TestReturnStatements(t *testing.T) {
 	}
 }
 
+func TestReturningSeveralValues(t *testing.T) {
+	p := New(lexer.New("return a, b + 1, f(c);"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+	}
+
+	returnStmt := program.Statements[0].(*ast.ReturnStatement)
+	values, ok := returnStmt.ReturnValue.(*ast.ArrayLiteral)
+
+	if !ok {
+		t.Fatalf("ReturnValue not *ast.ArrayLiteral. got=%T", returnStmt.ReturnValue)
+	}
+
+	if values.String() != "[a, (b + 1), f(c)]" {
+		t.Errorf("wrong values. got=%q", values.String())
+	}
+}
+
 func TestIdentifierExpression(t *testing.T) {
 	input := "foobar;"
 
@@ -238,6 +359,146 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestIntegerLiteralBases(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0xFF", 255},
+		{"0Xff", 255},
+		{"0o77", 63},
+		{"0O10", 8},
+		{"0b1010", 10},
+		{"0B0", 0},
+		{"1_000_000", 1000000},
+		{"0xFF_FF", 65535},
+		{"0b_1", 1},
+		{"0x7FFFFFFFFFFFFFFF", 9223372036854775807},
+		{"0b111111111111111111111111111111111111111111111111111111111111111", 9223372036854775807},
+		{"0o777777777777777777777", 9223372036854775807},
+		{"9_223_372_036_854_775_807", 9223372036854775807},
+		{"0", 0},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+
+		if !ok {
+			t.Fatalf("%s: exp not *ast.IntegerLiteral. got=%T", tt.input, stmt.Expression)
+		}
+
+		if literal.Value != tt.expected {
+			t.Errorf("%s: literal.Value not %d. got=%d", tt.input, tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestWideIntegerLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775808", "9223372036854775808"},
+		{"0x8000000000000000", "9223372036854775808"},
+		{"123_456_789_012_345_678_901_234_567_890", "123456789012345678901234567890"},
+		{"0b1_0000000000000000000000000000000000000000000000000000000000000000", "18446744073709551616"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+
+		if !ok {
+			t.Fatalf("%s: exp not *ast.IntegerLiteral. got=%T", tt.input, stmt.Expression)
+		}
+
+		if literal.Big == nil || literal.Big.String() != tt.expected {
+			t.Errorf("%s: literal.Big not %s. got=%v", tt.input, tt.expected, literal.Big)
+		}
+	}
+}
+
+func TestMalformedNumberLiterals(t *testing.T) {
+	inputs := []string{
+		"0x", "0xG", "0b102", "0o8", "0b", "1__000", "1_", "0x_", "1.5_", "1_.5",
+		"0x8000000000000000G", "99999999999999999999_",
+	}
+
+	for _, input := range inputs {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+
+		if len(errors) != 1 {
+			t.Errorf("%s: expected one parser error, got %q", input, errors)
+			continue
+		}
+
+		if !strings.Contains(errors[0], fmt.Sprintf("could not parse %q as", input)) {
+			t.Errorf("%s: wrong error message. got=%q", input, errors[0])
+		}
+	}
+}
+
+func TestOperatorsAfterBrokenOperand(t *testing.T) {
+	inputs := []string{"08 = 1", "[-08 = 1]", "-0xG += 1", "f(1_, 2) |> g", "(08).x = 2"}
+
+	for _, input := range inputs {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+
+		if len(errors) == 0 || !strings.Contains(errors[0], "could not parse") {
+			t.Errorf("%s: expected the literal to be reported first, got %q", input, errors)
+		}
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	input := "3.14;"
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program has not enough statements. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	literal, ok := stmt.Expression.(*ast.FloatLiteral)
+
+	if !ok {
+		t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Value != 3.14 {
+		t.Errorf("literal.Value not %f. got=%f", 3.14, literal.Value)
+	}
+
+	if literal.TokenLiteral() != "3.14" {
+		t.Errorf("literal.TokenLiteral not %s. got=%s", "3.14", literal.TokenLiteral())
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input        string
@@ -299,6 +560,12 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 / 5", 5, "/", 5},
 		{"5 > 5", 5, ">", 5},
 		{"5 < 5", 5, "<", 5},
+		{"5 >= 5", 5, ">=", 5},
+		{"5 <= 5", 5, "<=", 5},
+		{"5 % 5", 5, "%", 5},
+		{"5 ** 5", 5, "**", 5},
+		{"true && false", true, "&&", false},
+		{"true || false", true, "||", false},
 		{"5 == 5", 5, "==", 5},
 		{"5 != 5", 5, "!=", 5},
 	}
@@ -445,6 +712,186 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"5 >= 4 == 3 <= 4",
+			"((5 >= 4) == (3 <= 4))",
+		},
+		{
+			"2 ** 3 ** 2",
+			"(2 ** (3 ** 2))",
+		},
+		{
+			"-2 ** 2",
+			"(-(2 ** 2))",
+		},
+		{
+			"a * b ** c % d",
+			"((a * (b ** c)) % d)",
+		},
+		{
+			"a ** b[0]",
+			"(a ** (b[0]))",
+		},
+		{
+			"a || b && c == d",
+			"(a || (b && (c == d)))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"!a && b < c",
+			"((!a) && (b < c))",
+		},
+		{
+			"x = a || b",
+			"(x = (a || b))",
+		},
+		{
+			"a ?? b || c",
+			"(a ?? (b || c))",
+		},
+		{
+			"a ?? b ?? c",
+			"((a ?? b) ?? c)",
+		},
+		{
+			"a?.b.c?[0]",
+			"(((a?.b).c)?[0])",
+		},
+		{
+			"-a?[1:2] ?? b?.c(d)",
+			"((-(a?[1:2])) ?? (b?.c)(d))",
+		},
+		{
+			"x ? [1] : [2]",
+			"(x ? [1] : [2])",
+		},
+		{
+			"x = a || b ? c + 1 : d ?? e",
+			"(x = ((a || b) ? (c + 1) : (d ?? e)))",
+		},
+		{
+			"a ? b : c ? d : e",
+			"(a ? b : (c ? d : e))",
+		},
+		{
+			"a ? b ? c : d : e",
+			"(a ? (b ? c : d) : e)",
+		},
+		{
+			"a | b ^ c & d",
+			"(a | (b ^ (c & d)))",
+		},
+		{
+			"a & b << c + d",
+			"(a & (b << (c + d)))",
+		},
+		{
+			"a << b >> c",
+			"((a << b) >> c)",
+		},
+		{
+			"a & 1 == b | c",
+			"((a & 1) == (b | c))",
+		},
+		{
+			"~a & ~-b",
+			"((~a) & (~(-b)))",
+		},
+		{
+			"x |> f(a) |> g",
+			"((x |> f(a)) |> g())",
+		},
+		{
+			"a + b |> f ?? c",
+			"((a + b) |> (f ?? c)())",
+		},
+		{
+			"x |> (y |> f)",
+			"(x |> (y |> f())())",
+		},
+		{
+			"y = x |> f ? a : b",
+			"(y = ((x |> f()) ? a : b))",
+		},
+		{
+			"x = h[k] ?? 0",
+			"(x = ((h[k]) ?? 0))",
+		},
+		{
+			"x = 1 + 2",
+			"(x = (1 + 2))",
+		},
+		{
+			"a = b = c == d",
+			"(a = (b = (c == d)))",
+		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
+		{
+			"a[1:2 + 1]",
+			"(a[1:(2 + 1)])",
+		},
+		{
+			"a[:n][i:]",
+			"((a[:n])[i:])",
+		},
+		{
+			"a[:]",
+			"(a[:])",
+		},
+		{
+			"a.b.c[0] + -p.x",
+			"((((a.b).c)[0]) + (-(p.x)))",
+		},
+		{
+			"p.greet(name)",
+			"(p.greet)(name)",
+		},
+		{
+			"p.age = p.age + 1",
+			"(p.age = ((p.age) + 1))",
+		},
+		{
+			"a[i] = b[j] = c",
+			"(a[i] = (b[j] = c))",
+		},
+		{
+			"m[0][1] = 2 * 3",
+			"((m[0])[1] = (2 * 3))",
+		},
+		{
+			"x += 1",
+			"(x = (x + 1))",
+		},
+		{
+			"a[0] += 1",
+			"(a[0] = ((a[0]) + 1))",
+		},
+		{
+			"x *= y + 2",
+			"(x = (x * (y + 2)))",
+		},
+		{
+			"x -= y /= 2",
+			"(x = (x - (y = (y / 2))))",
+		},
+		{
+			"-i++",
+			"(-(i++))",
+		},
+		{
+			"a + i-- * 2",
+			"(a + ((i--) * 2))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -574,11 +1021,60 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
-func TestFunctionLiteralParsing(t *testing.T) {
-	input := `fn(x, y) { x + y; }`
+func TestSwitchExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`switch (x) { case 1: "one" case 2, 3: "few" default: "many" }`,
+			"switchx { case 1: one case 2, 3: few default: many }",
+		},
+		{
+			"switch (a + b) { case c * d: let y = 1; y }",
+			"switch(a + b) { case (c * d): let y = 1;y }",
+		},
+		{
+			"switch (x) { case 1: }",
+			"switchx { case 1:  }",
+		},
+		{
+			"switch (x) {}",
+			"switchx { }",
+		},
+	}
 
-	l := lexer.New(input)
-	p := New(l)
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain %d statements. got=%d\n", 1, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		}
+
+		if _, ok := stmt.Expression.(*ast.SwitchExpression); !ok {
+			t.Fatalf("stmt.Expression is not ast.SwitchExpression. got=%T", stmt.Expression)
+		}
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	input := `try { x / y } catch (e) { e }`
+
+	l := lexer.New(input)
+	p := New(l)
 
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
@@ -587,71 +1083,678 @@ func TestFunctionLiteralParsing(t *testing.T) {
 		t.Fatalf("program.Statements does not contain %d statements. got=%d\n", 1, len(program.Statements))
 	}
 
-	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.TryExpression)
+
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.TryExpression. got=%T", stmt.Expression)
+	}
+
+	if len(exp.Block.Statements) != 1 {
+		t.Fatalf("block is not 1 statements. got=%d\n", len(exp.Block.Statements))
+	}
+
+	block, ok := exp.Block.Statements[0].(*ast.ExpressionStatement)
+
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T", exp.Block.Statements[0])
+	}
+
+	if !testInfixExpression(t, block.Expression, "x", "/", "y") {
+		return
+	}
+
+	testLiteralExpression(t, exp.Parameter, "e")
+
+	if len(exp.Catch.Statements) != 1 {
+		t.Fatalf("catch is not 1 statements. got=%d\n", len(exp.Catch.Statements))
+	}
+
+	catch, ok := exp.Catch.Statements[0].(*ast.ExpressionStatement)
+
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T", exp.Catch.Statements[0])
+	}
+
+	testIdentifier(t, catch.Expression, "e")
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < y) { x }`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", "y") {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statements. got=%d\n", len(stmt.Body.Statements))
+	}
+
+	body, ok := stmt.Body.Statements[0].(*ast.ExpressionStatement)
+
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T", stmt.Body.Statements[0])
+	}
+
+	testIdentifier(t, body.Expression, "x")
+}
+
+func TestDoWhileAndLoopStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		do       bool
+		expected string
+	}{
+		{"do { x } while (x < y);", true, "do x while(x < y)"},
+		{"do { x; y } while (true)", true, "do xy whiletrue"},
+		{"loop { break; }", false, "loop break;"},
+		{"loop {}", false, "loop "},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q: program.Statements does not contain 1 statement. got=%d", tt.input, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.WhileStatement)
+
+		if !ok {
+			t.Fatalf("%q: program.Statements[0] is not ast.WhileStatement. got=%T", tt.input, program.Statements[0])
+		}
+
+		if stmt.Do != tt.do {
+			t.Errorf("%q: stmt.Do wrong. want=%t, got=%t", tt.input, tt.do, stmt.Do)
+		}
+
+		if stmt.String() != tt.expected {
+			t.Errorf("%q: wrong String(). want=%q, got=%q", tt.input, tt.expected, stmt.String())
+		}
+	}
+}
+
+// do and loop are only keywords when a block follows them.
+func TestDoAndLoopAsNames(t *testing.T) {
+	input := "let loop = fn(do) { do }; loop(1); do"
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("program.Statements does not contain 3 statements. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[2].(*ast.ExpressionStatement)
+
+	if !ok {
+		t.Fatalf("program.Statements[2] is not ast.ExpressionStatement. got=%T", program.Statements[2])
+	}
+
+	testIdentifier(t, stmt.Expression, "do")
+}
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for (let i = 0; i < 10; i) { i }", "for (let i = 0; (i < 10); i) i"},
+		{"for (;;) { x }", "for (; ; ) x"},
+		{"for (x; ; f(x)) { x };", "for (x; ; f(x)) x"},
+		{"for (; x > 1;) { x }", "for (; (x > 1); ) x"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain %d statements. got=%d\n", 1, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ForStatement)
+
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T", program.Statements[0])
+		}
+
+		if stmt.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestForInStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for (x in xs) { x }", "for (x in xs) x"},
+		{"for (c in \"abc\") { puts(c); };", "for (c in abc) puts(c)"},
+		{"for (i in range(1, n + 1)) { break; }", "for (i in range(1, (n + 1))) break;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain %d statements. got=%d\n", 1, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ForInStatement)
+
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ForInStatement. got=%T", program.Statements[0])
+		}
+
+		if stmt.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestBreakAndContinueStatements(t *testing.T) {
+	input := `while (true) { if (x) { break; } continue; }`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.WhileStatement)
+
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("body is not 2 statements. got=%d\n", len(stmt.Body.Statements))
+	}
+
+	ifExp := stmt.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+
+	if _, ok := ifExp.Consequence.Statements[0].(*ast.BreakStatement); !ok {
+		t.Errorf("consequence is not ast.BreakStatement. got=%T", ifExp.Consequence.Statements[0])
+	}
+
+	if _, ok := stmt.Body.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Errorf("Statements[1] is not ast.ContinueStatement. got=%T", stmt.Body.Statements[1])
+	}
+}
+
+func TestFunctionLiteralParsing(t *testing.T) {
+	input := `fn(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	function, ok := stmt.Expression.(*ast.FunctionLiteral)
+
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(function.Parameters) != 2 {
+		t.Fatalf("function literal parameters wrong. want 2, got=%d\n", len(function.Parameters))
+	}
+
+	testLiteralExpression(t, function.Parameters[0], "x")
+	testLiteralExpression(t, function.Parameters[1], "y")
+
+	if len(function.Body.Statements) != 1 {
+		t.Fatalf("function.Body.Statements has not 1 statements. got=%d\n", len(function.Body.Statements))
+	}
+
+	bodyStmt, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
+
+	if !ok {
+		t.Fatalf("function body stmt is not ast.ExpressionStatement. got=%T", function.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
+func TestFunctionParametersParsing(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedParams   []string
+		expectedVariadic bool
+	}{
+		{input: "fn() {};", expectedParams: []string{}},
+		{input: "fn(x) {};", expectedParams: []string{"x"}},
+		{input: "fn(x, y, z) {};", expectedParams: []string{"x", "y", "z"}},
+		{input: "fn(...rest) {};", expectedParams: []string{"rest"}, expectedVariadic: true},
+		{input: "fn(x, ...rest) {};", expectedParams: []string{"x", "rest"}, expectedVariadic: true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Errorf("length parameters wrong. want %d, got=%d\n", len(tt.expectedParams), len(function.Parameters))
+		}
+
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+
+		if function.Variadic != tt.expectedVariadic {
+			t.Errorf("function.Variadic wrong. want %t, got=%t", tt.expectedVariadic, function.Variadic)
+		}
+	}
+}
+
+func TestDefaultParameterParsing(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedDefaults []string // "" for a parameter without one
+	}{
+		{"fn(x) {};", nil},
+		{"fn(x, y = 10) {};", []string{"", "10"}},
+		{"fn(x = 1, y = x * 2) {};", []string{"1", "(x * 2)"}},
+		{"fn(x = 1, ...rest) {};", []string{"1", ""}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if len(function.Defaults) != len(tt.expectedDefaults) {
+			t.Fatalf("%s: length of defaults wrong. want %d, got=%d", tt.input, len(tt.expectedDefaults), len(function.Defaults))
+		}
+
+		for i, expected := range tt.expectedDefaults {
+			value := function.Defaults[i]
+
+			switch {
+			case expected == "" && value != nil:
+				t.Errorf("%s: parameter %d should have no default. got=%s", tt.input, i, value)
+			case expected != "" && (value == nil || value.String() != expected):
+				t.Errorf("%s: default of parameter %d wrong. want %s, got=%v", tt.input, i, expected, value)
+			}
+		}
+	}
+}
+
+func TestCallExpressionParsing(t *testing.T) {
+	input := "add(1, 2 * 3, 4 + 5);"
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+
+	if !ok {
+		t.Fatalf("stmt is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, exp.Function, "add") {
+		return
+	}
+
+	if len(exp.Arguments) != 3 {
+		t.Fatalf("wrong length of arguments. got=%d", len(exp.Arguments))
+	}
+
+	testLiteralExpression(t, exp.Arguments[0], 1)
+	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
+	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
+}
+
+func TestSpreadArgumentParsing(t *testing.T) {
+	input := "add(1, ...xs, ...[2, 3]);"
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+	}
+
+	if len(exp.Arguments) != 3 {
+		t.Fatalf("wrong length of arguments. got=%d", len(exp.Arguments))
+	}
+
+	testLiteralExpression(t, exp.Arguments[0], 1)
+
+	spread, ok := exp.Arguments[1].(*ast.SpreadExpression)
+
+	if !ok {
+		t.Fatalf("exp.Arguments[1] is not ast.SpreadExpression. got=%T", exp.Arguments[1])
+	}
+
+	testIdentifier(t, spread.Value, "xs")
+
+	spread, ok = exp.Arguments[2].(*ast.SpreadExpression)
+
+	if !ok {
+		t.Fatalf("exp.Arguments[2] is not ast.SpreadExpression. got=%T", exp.Arguments[2])
+	}
+
+	if _, ok := spread.Value.(*ast.ArrayLiteral); !ok {
+		t.Fatalf("spread.Value is not ast.ArrayLiteral. got=%T", spread.Value)
+	}
+
+	if program.String() != "add(1, ...xs, ...[2, 3])" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestNamedArgumentParsing(t *testing.T) {
+	input := "makeUser(name, age: 30, admin: x > 1);"
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+	}
+
+	if len(exp.Arguments) != 1 {
+		t.Fatalf("wrong length of arguments. got=%d", len(exp.Arguments))
+	}
+
+	testLiteralExpression(t, exp.Arguments[0], "name")
+
+	if len(exp.Named) != 2 {
+		t.Fatalf("wrong length of named arguments. got=%d", len(exp.Named))
+	}
+
+	if exp.Named[0].Name != "age" {
+		t.Errorf("exp.Named[0].Name not %q. got=%q", "age", exp.Named[0].Name)
+	}
+
+	testLiteralExpression(t, exp.Named[0].Value, 30)
+
+	if exp.Named[1].Name != "admin" {
+		t.Errorf("exp.Named[1].Name not %q. got=%q", "admin", exp.Named[1].Name)
+	}
+
+	testInfixExpression(t, exp.Named[1].Value, "x", ">", 1)
+
+	if program.String() != "makeUser(name, age: 30, admin: (x > 1))" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+
+	if !ok {
+		t.Fatalf("stmt is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+
+	if !ok {
+		t.Fatalf("stmt is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
+func TestParsingIndexAssignment(t *testing.T) {
+	input := `h["a"] = x + 1`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+
+	if !ok {
+		t.Fatalf("stmt is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	assign, ok := stmt.Expression.(*ast.IndexAssignment)
+
+	if !ok {
+		t.Fatalf("exp not *ast.IndexAssignment. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, assign.Left, "h") {
+		return
+	}
+
+	if assign.Index.String() != "a" {
+		t.Errorf("assign.Index is not %q. got=%q", "a", assign.Index.String())
+	}
+
+	if !testInfixExpression(t, assign.Value, "x", "+", 1) {
+		return
+	}
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	input := `{"one": 1, "two": 2, "three": 3}`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
 
 	if !ok {
-		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
 	}
 
-	function, ok := stmt.Expression.(*ast.FunctionLiteral)
-
-	if !ok {
-		t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T", stmt.Expression)
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1},
+		{"two", 2},
+		{"three", 3},
 	}
 
-	if len(function.Parameters) != 2 {
-		t.Fatalf("function literal parameters wrong. want 2, got=%d\n", len(function.Parameters))
+	if len(hash.Pairs) != len(expected) {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
 	}
 
-	testLiteralExpression(t, function.Parameters[0], "x")
-	testLiteralExpression(t, function.Parameters[1], "y")
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 
-	if len(function.Body.Statements) != 1 {
-		t.Fatalf("function.Body.Statements has not 1 statements. got=%d\n", len(function.Body.Statements))
+		if !ok {
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
+
+			continue
+		}
+
+		if literal.Value != expected[i].key {
+			t.Errorf("pair %d has wrong key. want=%q, got=%q", i, expected[i].key, literal.Value)
+		}
+
+		testIntegerLiteral(t, pair.Value, expected[i].value)
 	}
+}
 
-	bodyStmt, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
+func TestParsingEmptyHashLiteral(t *testing.T) {
+	input := "{}"
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
 
 	if !ok {
-		t.Fatalf("function body stmt is not ast.ExpressionStatement. got=%T", function.Body.Statements[0])
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
 	}
 
-	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+	if len(hash.Pairs) != 0 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
 }
 
-func TestFunctionParametersParsing(t *testing.T) {
-	tests := []struct {
-		input          string
-		expectedParams []string
-	}{
-		{input: "fn() {};", expectedParams: []string{}},
-		{input: "fn(x) {};", expectedParams: []string{"x"}},
-		{input: "fn(x, y, z) {};", expectedParams: []string{"x", "y", "z"}},
-	}
+func TestParsingHashLiteralsWithExpressions(t *testing.T) {
+	input := `{"one": 0 + 1, "two": 10 - 8, "three": 15 / 5}`
 
-	for _, tt := range tests {
-		l := lexer.New(tt.input)
-		p := New(l)
+	l := lexer.New(input)
+	p := New(l)
 
-		program := p.ParseProgram()
-		checkParserErrors(t, p)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
 
-		stmt := program.Statements[0].(*ast.ExpressionStatement)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
 
-		function := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
 
-		if len(function.Parameters) != len(tt.expectedParams) {
-			t.Errorf("length parameters wrong. want %d, got=%d\n", len(tt.expectedParams), len(function.Parameters))
-		}
+	if len(hash.Pairs) != 3 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
 
-		for i, ident := range tt.expectedParams {
-			testLiteralExpression(t, function.Parameters[i], ident)
-		}
+	tests := []func(ast.Expression){
+		func(e ast.Expression) { testInfixExpression(t, e, 0, "+", 1) },
+		func(e ast.Expression) { testInfixExpression(t, e, 10, "-", 8) },
+		func(e ast.Expression) { testInfixExpression(t, e, 15, "/", 5) },
+	}
+
+	for i, pair := range hash.Pairs {
+		tests[i](pair.Value)
 	}
 }
 
-func TestCallExpressionParsing(t *testing.T) {
-	input := "add(1, 2 * 3, 4 + 5);"
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
 
 	l := lexer.New(input)
 	p := New(l)
@@ -666,26 +1769,33 @@ func TestCallExpressionParsing(t *testing.T) {
 	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
 
 	if !ok {
-		t.Fatalf("stmt is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		t.Fatalf("statement is not ast.ExpressionStatement. got=%T", program.Statements[0])
 	}
 
-	exp, ok := stmt.Expression.(*ast.CallExpression)
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
 
 	if !ok {
-		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T", stmt.Expression)
 	}
 
-	if !testIdentifier(t, exp.Function, "add") {
-		return
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d\n", len(macro.Parameters))
 	}
 
-	if len(exp.Arguments) != 3 {
-		t.Fatalf("wrong length of arguments. got=%d", len(exp.Arguments))
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d\n", len(macro.Body.Statements))
 	}
 
-	testLiteralExpression(t, exp.Arguments[0], 1)
-	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
-	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T", macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
 func testIntegerLiteral(
@@ -839,3 +1949,373 @@ func checkParserErrors(t *testing.T, p *Parser) {
 
 	t.FailNow()
 }
+
+func TestParserErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let x 5;",
+			"parse error at line 1, col 7: expected next token to be =, got INT instead",
+		},
+		{
+			"let x = 5;\nadd(1, 2;",
+			"parse error at line 2, col 9: expected next token to be ), got ; instead",
+		},
+		{
+			"\n  let = 5;",
+			"parse error at line 2, col 7: expected next token to be IDENT, got = instead",
+		},
+		{
+			"1;\n/* oops",
+			"parse error at line 2, col 1: unterminated block comment",
+		},
+		{
+			"break;",
+			"parse error at line 1, col 1: break outside of a loop",
+		},
+		{
+			"1 = 2;",
+			"parse error at line 1, col 3: cannot assign to 1",
+		},
+		{
+			"while (true) { fn() { continue; } }",
+			"parse error at line 1, col 23: continue outside of a loop",
+		},
+		{
+			"do { 1 } (x)",
+			"parse error at line 1, col 10: expected next token to be WHILE, got ( instead",
+		},
+		{
+			"loop { fn() { break; } }",
+			"parse error at line 1, col 15: break outside of a loop",
+		},
+		{
+			"switch (x) { default: 1 default: 2 }",
+			"parse error at line 1, col 25: multiple defaults in switch",
+		},
+		{
+			"switch (x) { case 1: 2",
+			"parse error at line 1, col 23: unterminated switch",
+		},
+		{
+			"try { 1 } (e) { 2 }",
+			"parse error at line 1, col 11: expected next token to be CATCH, got ( instead",
+		},
+		{
+			"try { 1 } catch e { 2 }",
+			"parse error at line 1, col 17: expected next token to be (, got IDENT instead",
+		},
+		{
+			"switch (x) { 1 }",
+			"parse error at line 1, col 14: expected case or default, got INT instead",
+		},
+		{
+			"fn(...xs, y) { 1 }",
+			"parse error at line 1, col 11: variadic parameter must be last",
+		},
+		{
+			"fn(x = 1, y) { 1 }",
+			"parse error at line 1, col 11: parameter y needs a default, as it follows one that has one",
+		},
+		{
+			"fn(...xs = []) { 1 }",
+			"parse error at line 1, col 10: variadic parameter cannot have a default",
+		},
+		{
+			"macro(x = 1) { 1 }",
+			"parse error at line 1, col 7: macro parameters cannot have defaults",
+		},
+		{
+			"macro(...xs) { 1 }",
+			"parse error at line 1, col 7: macro parameters cannot be variadic",
+		},
+		{
+			"[...xs]",
+			"parse error at line 1, col 2: no prefix parse function for ... found",
+		},
+		{
+			"a ? b c",
+			"parse error at line 1, col 7: expected next token to be :, got IDENT instead",
+		},
+		{
+			`"a ${} b"`,
+			"parse error at line 1, col 6: empty interpolation",
+		},
+		{
+			`"a ${x y} b"`,
+			"parse error at line 1, col 8: expected } to end interpolation, got IDENT instead",
+		},
+		{
+			"f(x: 1, 2)",
+			"parse error at line 1, col 9: positional argument cannot follow a named argument",
+		},
+		{
+			"f(x: 1, ...xs)",
+			"parse error at line 1, col 9: spread argument cannot follow a named argument",
+		},
+		{
+			"f(...xs, x: 1)",
+			"parse error at line 1, col 10: named argument x cannot follow a spread argument",
+		},
+		{
+			"p.1",
+			"parse error at line 1, col 3: expected next token to be IDENT, got INT instead",
+		},
+		{
+			"for (1 in xs) { 1 }",
+			"parse error at line 1, col 8: expected a name before in",
+		},
+		{
+			"for (x in xs { 1 }",
+			"parse error at line 1, col 14: expected next token to be ), got { instead",
+		},
+		{
+			"let s = \"never closed;\nputs(s);",
+			"parse error at line 1, col 9: unterminated string",
+		},
+		{
+			"let [a, 1] = xs;",
+			"parse error at line 1, col 9: expected next token to be IDENT, got INT instead",
+		},
+		{
+			"let {} = h;",
+			"parse error at line 1, col 6: expected next token to be IDENT, got } instead",
+		},
+		{
+			"let q, = f();",
+			"parse error at line 1, col 8: expected next token to be IDENT, got = instead",
+		},
+		{
+			"let [a, b} = xs;",
+			"parse error at line 1, col 10: expected next token to be ], got } instead",
+		},
+		{
+			"h?.name = 1",
+			"parse error at line 1, col 9: cannot assign to (h?.name)",
+		},
+		{
+			"h?[0] += 1",
+			"parse error at line 1, col 7: cannot assign to (h?[0])",
+		},
+		{
+			"let x = 1 @ 2;",
+			"parse error at line 1, col 11: unexpected character `@`",
+		},
+		{
+			"add(1, 2 # 3)",
+			"parse error at line 1, col 10: unexpected character `#`",
+		},
+		{
+			`"a ${x} b`,
+			"parse error at line 1, col 7: unterminated string",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q, got none", tt.input)
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestParserErrorDetails(t *testing.T) {
+	p := New(lexer.New("let x = 5;\nadd(1, 2;"))
+	p.ParseProgram()
+
+	details := p.ErrorDetails()
+
+	if len(details) != len(p.Errors()) {
+		t.Fatalf("got %d details for %d errors", len(details), len(p.Errors()))
+	}
+
+	first := details[0]
+
+	if first.Token.Line != 2 || first.Token.Column != 9 || first.Token.Literal != ";" {
+		t.Errorf("wrong token. got=%+v", first.Token)
+	}
+
+	if first.Message != "expected next token to be ), got ; instead" {
+		t.Errorf("wrong message. got=%q", first.Message)
+	}
+}
+
+func TestParserErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"let = 10; let y = 2;",
+			[]string{"parse error at line 1, col 5: expected next token to be IDENT, got = instead"},
+		},
+		{
+			"if (x { 1 } else { 2 };\nlet z = ;",
+			[]string{
+				"parse error at line 1, col 7: expected next token to be ), got { instead",
+				"parse error at line 2, col 9: no prefix parse function for ; found",
+			},
+		},
+		{
+			"let x = 1 +\nlet y = );\nlet z = 3;",
+			[]string{
+				"parse error at line 2, col 1: no prefix parse function for LET found",
+				"parse error at line 2, col 9: no prefix parse function for ) found",
+			},
+		},
+		{
+			"let f = fn() { let = 1; 2 }; let y = ;",
+			[]string{
+				"parse error at line 1, col 20: expected next token to be IDENT, got = instead",
+				"parse error at line 1, col 38: no prefix parse function for ; found",
+			},
+		},
+		{
+			"let f = fn() { x + }; f(",
+			[]string{
+				"parse error at line 1, col 20: no prefix parse function for } found",
+				"parse error at line 1, col 25: no prefix parse function for EOF found",
+			},
+		},
+		{
+			"let x = case 1",
+			[]string{"parse error at line 1, col 9: no prefix parse function for CASE found"},
+		},
+		{
+			"switch (x) { case 1: let = 2 case 2: 3 + default: 4 };",
+			[]string{
+				"parse error at line 1, col 26: expected next token to be IDENT, got = instead",
+				"parse error at line 1, col 42: no prefix parse function for DEFAULT found",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if !reflect.DeepEqual(p.Errors(), tt.expected) {
+			t.Errorf("wrong errors for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestParserRecoversStatements(t *testing.T) {
+	p := New(lexer.New("let a = 1; let = 2; let b = [3; let c = fn() { let = 4; 5 }; let d = 6;"))
+	program := p.ParseProgram()
+
+	var names []string
+
+	for _, stmt := range program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok {
+			names = append(names, let.Name.Value)
+		}
+	}
+
+	if strings.Join(names, " ") != "a b c d" {
+		t.Errorf("wrong statements kept. got=%q", names)
+	}
+
+	body := program.Statements[len(program.Statements)-2].(*ast.LetStatement).Value.(*ast.FunctionLiteral).Body
+
+	if body.String() != "5" {
+		t.Errorf("wrong function body. got=%q", body.String())
+	}
+}
+
+func TestBrokenLetStatementIsDropped(t *testing.T) {
+	p := New(lexer.New("let = 1; 2"))
+	program := p.ParseProgram()
+
+	for i, stmt := range program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok && let == nil {
+			t.Errorf("statement %d is a nil *ast.LetStatement", i)
+		}
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // the source text of the program's first statement
+	}{
+		{"let x = 1 + 2;", "let x = 1 + 2"},
+		{"  -a * b  ", "-a * b"},
+		{"const {a, b} = h", "const {a, b} = h"},
+		{"return a, b;", "return a, b"},
+		{"f(1, x: 2)[0] // comment", "f(1, x: 2)[0]"},
+		{"h?.name.first", "h?.name.first"},
+		{"xs[1:] ", "xs[1:]"},
+		{`"a ${b} c" + "d"`, `"a ${b} c" + "d"`},
+		{"x |> f(1) |> g", "x |> f(1) |> g"},
+		{"if (a) { 1 } else { 2 };", "if (a) { 1 } else { 2 }"},
+		{"switch (x) { case 1: 2 default: 3 }", "switch (x) { case 1: 2 default: 3 }"},
+		{"try { f() } catch (e) { e }", "try { f() } catch (e) { e }"},
+		{"for (let i = 0; i < 3; i++) { }", "for (let i = 0; i < 3; i++) { }"},
+		{"for (x in xs) { puts(x) }", "for (x in xs) { puts(x) }"},
+		{"while (true) { break; }", "while (true) { break; }"},
+		{"do { i++ } while (i < 3); x", "do { i++ } while (i < 3)"},
+		{"loop { break }", "loop { break }"},
+		{"fn(x, y = 1, ...z) { x }(...a)", "fn(x, y = 1, ...z) { x }(...a)"},
+		{"macro(a) { quote(a) };", "macro(a) { quote(a) }"},
+		{"a ? [1] : {2: 3}", "a ? [1] : {2: 3}"},
+		{"x += 1", "x += 1"},
+		{"a[0] = b", "a[0] = b"},
+		{"i++", "i++"},
+		{"let s = \"héllo\"; s", "let s = \"héllo\""},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if got := ast.Source(tt.input, program.Statements[0]); got != tt.expected {
+			t.Errorf("%q: wrong source. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// TestNodePositionsNest checks that every node of a program lies within the
+// node it is part of.
+func TestNodePositionsNest(t *testing.T) {
+	input := `
+let add = fn(a, b = 2) { return a + b; };
+let h = {"k": [1, 2.5, true], "f": fn() { h?.k[0:1] }};
+switch (add(1)) { case 3: puts("three ${h["k"][0]}") default: null? }
+for (let i = 0; i < 2; i++) { try { i |> add } catch (e) { -i } }
+do { loop { break } } while (false);
+`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	ast.Inspect(program, func(parent ast.Node) bool {
+		if parent.Pos() < 0 || parent.Pos() >= parent.End() || parent.End() > len(input) {
+			t.Errorf("%s: bad position %d to %d", parent.String(), parent.Pos(), parent.End())
+		}
+
+		ast.Inspect(parent, func(node ast.Node) bool {
+			if node.Pos() < parent.Pos() || node.End() > parent.End() {
+				t.Errorf("%q is not within %q", ast.Source(input, node), ast.Source(input, parent))
+			}
+
+			return true
+		})
+
+		return true
+	})
+}
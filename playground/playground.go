@@ -0,0 +1,64 @@
+// Package playground runs Monkey programs for the in-browser playground. It
+// has no browser dependencies, so the wasm bridge in playground/wasm stays a
+// thin wrapper and the behaviour can be tested natively.
+package playground
+
+import (
+	"bytes"
+	"monkey/evaluator"
+	"monkey/interp"
+	"monkey/object"
+	"strings"
+	"time"
+)
+
+// limits keeps a runaway program from hanging the page, or overflowing the
+// stack, before it reports anything.
+var limits = evaluator.Limits{
+	MaxDepth: 10000,
+	MaxSteps: 50000000,
+	Timeout:  5 * time.Second,
+}
+
+// Result is what one run of a program produced: everything it printed, the
+// value of its last statement, and its parser or runtime errors.
+type Result struct {
+	Output string
+	Value  string
+	Errors []string
+}
+
+// Evaluate runs source in a fresh interpreter, within limits. readLine sees
+// no input, the HTTP builtins may not reach the network, env, setEnv and
+// exec may not reach the system, and the program's output is collected
+// into the result instead of going to stdout.
+func Evaluate(source string) Result {
+	var out bytes.Buffer
+
+	object.SetNetwork(false)
+	object.SetSystem(false)
+
+	in := interp.New()
+	in.SetLimits(limits)
+	in.SetOutput(&out)
+	in.SetInput(strings.NewReader(""))
+
+	value, err := in.Eval(source)
+
+	result := Result{Output: out.String(), Errors: []string{}}
+
+	switch err := err.(type) {
+	case nil:
+		if value != nil {
+			result.Value = value.Inspect()
+		}
+	case *interp.ParseError:
+		result.Errors = err.Messages
+	case *interp.RuntimeError:
+		result.Errors = []string{err.Err.Traceback()}
+	default:
+		result.Errors = []string{err.Error()}
+	}
+
+	return result
+}
@@ -0,0 +1,71 @@
+package playground
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected Result
+	}{
+		{
+			`puts("hello"); printf("%d!", 6 * 7); 1 + 2`,
+			Result{Output: "hello\n42!", Value: "3", Errors: []string{}},
+		},
+		{
+			`let x = 1;`,
+			Result{Output: "", Value: "", Errors: []string{}},
+		},
+		{
+			`readLine()`,
+			Result{Output: "", Value: "null", Errors: []string{}},
+		},
+		{
+			`let = 1;`,
+			Result{Errors: []string{
+				"parse error at line 1, col 5: expected next token to be IDENT, got = instead",
+			}},
+		},
+		{
+			"puts(\"before\");\n1 + true",
+			Result{Output: "before\n", Errors: []string{"ERROR at line 2, col 3: type mismatch: INTEGER + BOOLEAN"}},
+		},
+		{
+			`httpGet("http://example.com")`,
+			Result{Errors: []string{"ERROR at line 1, col 8: network access is disabled"}},
+		},
+		{
+			`exec("ls")`,
+			Result{Errors: []string{"ERROR at line 1, col 5: system access is disabled"}},
+		},
+	}
+
+	for _, tt := range tests {
+		result := Evaluate(tt.source)
+
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%q: want=%#v, got=%#v", tt.source, tt.expected, result)
+		}
+	}
+}
+
+func TestEvaluateStartsFresh(t *testing.T) {
+	Evaluate(`let x = 1;`)
+
+	result := Evaluate(`x`)
+
+	if len(result.Errors) != 1 {
+		t.Errorf("a binding leaked between runs. got=%#v", result)
+	}
+}
+
+func TestEvaluateStopsRunawayPrograms(t *testing.T) {
+	result := Evaluate(`let f = fn(n) { 1 + f(n) }; f(0)`)
+
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0], "maximum call depth exceeded: 10000") {
+		t.Errorf("deep recursion was not stopped. got=%#v", result)
+	}
+}
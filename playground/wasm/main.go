@@ -0,0 +1,40 @@
+//go:build js && wasm
+
+// Command wasm is the browser side of the playground. Built with
+//
+//	GOOS=js GOARCH=wasm go build -o playground/wasm/monkey.wasm ./playground/wasm
+//
+// it defines a global evaluate(source) function that returns an object with
+// output, value and errors fields, then waits to be called.
+package main
+
+import (
+	"monkey/playground"
+	"syscall/js"
+)
+
+func main() {
+	js.Global().Set("evaluate", js.FuncOf(evaluate))
+
+	select {}
+}
+
+func evaluate(this js.Value, args []js.Value) any {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return js.Global().Get("Error").New("evaluate takes one string, the program source")
+	}
+
+	result := playground.Evaluate(args[0].String())
+
+	errors := make([]any, len(result.Errors))
+
+	for i, err := range result.Errors {
+		errors[i] = err
+	}
+
+	return map[string]any{
+		"output": result.Output,
+		"value":  result.Value,
+		"errors": errors,
+	}
+}
@@ -0,0 +1,580 @@
+// Package printer turns an AST back into canonically formatted Monkey
+// source: four-space indentation, one statement per line, a semicolon after
+// every statement that takes one, and only the parentheses the parser needs.
+//
+// The lexer drops comments, so formatting a file removes them.
+package printer
+
+import (
+	"bytes"
+	"math/big"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/token"
+	"strconv"
+	"strings"
+)
+
+const INDENT = "    "
+
+// Format parses source and returns it formatted. Parser errors are returned
+// instead, and the source is left alone.
+func Format(source string) (string, []string) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		return "", p.Errors()
+	}
+
+	return Print(program), nil
+}
+
+// Print formats a single node. A program ends with a newline; other nodes
+// do not.
+func Print(node ast.Node) string {
+	pr := &printer{}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		pr.statements(node.Statements)
+
+		if len(node.Statements) > 0 {
+			pr.out.WriteString("\n")
+		}
+	case ast.Statement:
+		pr.statement(node)
+	case ast.Expression:
+		pr.expression(node, parser.LOWEST)
+	}
+
+	return pr.out.String()
+}
+
+type printer struct {
+	out    bytes.Buffer
+	indent int
+}
+
+func (pr *printer) write(parts ...string) {
+	for _, part := range parts {
+		pr.out.WriteString(part)
+	}
+}
+
+func (pr *printer) newline() {
+	pr.out.WriteString("\n")
+	pr.out.WriteString(strings.Repeat(INDENT, pr.indent))
+}
+
+// statements prints stmts one per line. An if, switch or try used as a
+// statement only gets a semicolon when the next line would otherwise be
+// parsed as continuing it, as with a following -x, (x) or [x].
+func (pr *printer) statements(stmts []ast.Statement) {
+	for i, stmt := range stmts {
+		if i > 0 {
+			pr.newline()
+		}
+
+		if endsWithBrace(stmt) {
+			pr.expression(stmt.(*ast.ExpressionStatement).Expression, parser.LOWEST)
+
+			if i+1 < len(stmts) && strings.ContainsAny(Print(stmts[i+1])[:1], "-([") {
+				pr.write(";")
+			}
+
+			continue
+		}
+
+		pr.statement(stmt)
+	}
+}
+
+func endsWithBrace(stmt ast.Statement) bool {
+	exprStmt, ok := stmt.(*ast.ExpressionStatement)
+
+	if !ok {
+		return false
+	}
+
+	switch exprStmt.Expression.(type) {
+	case *ast.IfExpression, *ast.SwitchExpression, *ast.TryExpression:
+		return true
+	default:
+		return false
+	}
+}
+
+func (pr *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		pr.write(letKeyword(stmt.Constant), " ", stmt.Name.Value, " = ")
+		pr.expression(stmt.Value, parser.LOWEST)
+		pr.write(";")
+	case *ast.DestructuringStatement:
+		pr.destructuring(stmt)
+		pr.write(";")
+	case *ast.ReturnStatement:
+		pr.write("return ")
+
+		if values, ok := stmt.ReturnValue.(*ast.ArrayLiteral); ok && values.Token.Type == token.RETURN {
+			pr.list(values.Elements)
+		} else {
+			pr.expression(stmt.ReturnValue, parser.LOWEST)
+		}
+
+		pr.write(";")
+	case *ast.ExpressionStatement:
+		pr.expression(stmt.Expression, parser.LOWEST)
+		pr.write(";")
+	case *ast.BreakStatement:
+		pr.write("break;")
+	case *ast.ContinueStatement:
+		pr.write("continue;")
+	case *ast.WhileStatement:
+		switch {
+		case stmt.Do:
+			pr.write("do ")
+			pr.block(stmt.Body)
+			pr.write(" while (")
+			pr.expression(stmt.Condition, parser.LOWEST)
+			pr.write(");")
+		case stmt.Token.Type == token.LOOP:
+			pr.write("loop ")
+			pr.block(stmt.Body)
+		default:
+			pr.write("while (")
+			pr.expression(stmt.Condition, parser.LOWEST)
+			pr.write(") ")
+			pr.block(stmt.Body)
+		}
+	case *ast.ForStatement:
+		pr.write("for (")
+		pr.forInit(stmt.Init)
+		pr.write(";")
+
+		if stmt.Condition != nil {
+			pr.write(" ")
+			pr.expression(stmt.Condition, parser.LOWEST)
+		}
+
+		pr.write(";")
+
+		if stmt.Post != nil {
+			pr.write(" ")
+			pr.expression(stmt.Post, parser.LOWEST)
+		}
+
+		pr.write(") ")
+		pr.block(stmt.Body)
+	case *ast.ForInStatement:
+		pr.write("for (", stmt.Name.Value, " in ")
+		pr.expression(stmt.Iterable, parser.LOWEST)
+		pr.write(") ")
+		pr.block(stmt.Body)
+	case *ast.BlockStatement:
+		pr.block(stmt)
+	}
+}
+
+// forInit prints the first clause of a for loop, which is a statement but
+// takes its semicolon from the loop header.
+func (pr *printer) forInit(init ast.Statement) {
+	switch init := init.(type) {
+	case *ast.LetStatement:
+		pr.write(letKeyword(init.Constant), " ", init.Name.Value, " = ")
+		pr.expression(init.Value, parser.LOWEST)
+	case *ast.DestructuringStatement:
+		pr.destructuring(init)
+	case *ast.ExpressionStatement:
+		pr.expression(init.Expression, parser.LOWEST)
+	}
+}
+
+// destructuring prints let [a, b] = value or let {a, b} = value, without
+// the semicolon.
+func (pr *printer) destructuring(stmt *ast.DestructuringStatement) {
+	opening, closing := "[", "]"
+
+	if stmt.Hash {
+		opening, closing = "{", "}"
+	} else if stmt.Bare {
+		opening, closing = "", ""
+	}
+
+	pr.write(letKeyword(stmt.Constant), " ", opening)
+
+	for i, name := range stmt.Names {
+		if i > 0 {
+			pr.write(", ")
+		}
+
+		pr.write(name.Value)
+	}
+
+	pr.write(closing, " = ")
+	pr.expression(stmt.Value, parser.LOWEST)
+}
+
+func letKeyword(constant bool) string {
+	if constant {
+		return "const"
+	}
+
+	return "let"
+}
+
+func (pr *printer) block(block *ast.BlockStatement) {
+	if len(block.Statements) == 0 {
+		pr.write("{}")
+
+		return
+	}
+
+	pr.write("{")
+	pr.indent++
+	pr.newline()
+	pr.statements(block.Statements)
+	pr.indent--
+	pr.newline()
+	pr.write("}")
+}
+
+// atom is the precedence of expressions that never need parentheses.
+const atom = parser.INDEX + 1
+
+var infixPrecedences = map[string]int{
+	"??": parser.COALESCE,
+	"||": parser.OR,
+	"&&": parser.AND,
+	"==": parser.EQUALS,
+	"!=": parser.EQUALS,
+	"<":  parser.LESSGREATER,
+	">":  parser.LESSGREATER,
+	"<=": parser.LESSGREATER,
+	">=": parser.LESSGREATER,
+	"|":  parser.BIT_OR,
+	"^":  parser.BIT_XOR,
+	"&":  parser.BIT_AND,
+	"<<": parser.SHIFT,
+	">>": parser.SHIFT,
+	"+":  parser.SUM,
+	"-":  parser.SUM,
+	"*":  parser.PRODUCT,
+	"/":  parser.PRODUCT,
+	"%":  parser.PRODUCT,
+	"**": parser.EXPONENT,
+}
+
+func precedence(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.AssignExpression, *ast.IndexAssignment:
+		return parser.ASSIGN
+	case *ast.ConditionalExpression:
+		return parser.CONDITIONAL
+	case *ast.InfixExpression:
+		return infixPrecedences[exp.Operator]
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.PostfixExpression:
+		return parser.POSTFIX
+	case *ast.CallExpression:
+		if exp.Pipe {
+			return parser.PIPE
+		}
+
+		return parser.CALL
+	case *ast.IndexExpression, *ast.SliceExpression:
+		return parser.INDEX
+	default:
+		return atom
+	}
+}
+
+// expression prints exp, wrapped in parentheses if its own precedence is
+// lower than min, the lowest precedence the parser would accept in this
+// position without them.
+func (pr *printer) expression(exp ast.Expression, min int) {
+	prec := precedence(exp)
+
+	if prec < min {
+		pr.write("(")
+		defer pr.write(")")
+	}
+
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		pr.write(exp.Value)
+	case *ast.IntegerLiteral:
+		// An integer keeps the form it was written in, such as 0xFF or
+		// 1_000, unless it was made by a macro or the optimizer.
+		if exp.Big != nil {
+			if value, ok := new(big.Int).SetString(exp.Token.Literal, 0); ok && value.Cmp(exp.Big) == 0 {
+				pr.write(exp.Token.Literal)
+			} else {
+				pr.write(exp.Big.String())
+			}
+		} else if value, err := strconv.ParseInt(exp.Token.Literal, 0, 64); err == nil && value == exp.Value {
+			pr.write(exp.Token.Literal)
+		} else {
+			pr.write(strconv.FormatInt(exp.Value, 10))
+		}
+	case *ast.FloatLiteral:
+		pr.write((&object.Float{Value: exp.Value}).Inspect())
+	case *ast.StringLiteral:
+		pr.write((&object.String{Value: exp.Value}).Inspect())
+	case *ast.StringInterpolation:
+		pr.write(`"` + stringText(exp.Strings[0]))
+
+		for i, value := range exp.Values {
+			pr.write("${")
+			pr.expression(value, parser.LOWEST)
+			pr.write("}" + stringText(exp.Strings[i+1]))
+		}
+
+		pr.write(`"`)
+	case *ast.Boolean:
+		pr.write(strconv.FormatBool(exp.Value))
+	case *ast.PrefixExpression:
+		pr.write(exp.Operator)
+
+		// - -x must not come out as --x, which lexes as a decrement.
+		if inner, ok := exp.Right.(*ast.PrefixExpression); ok && exp.Operator == "-" && inner.Operator == "-" {
+			pr.write("(")
+			pr.expression(exp.Right, parser.LOWEST)
+			pr.write(")")
+
+			break
+		}
+
+		pr.expression(exp.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		left, right := prec, prec+1
+
+		// ** groups to the right, so a ** b ** c needs no parentheses but
+		// (a ** b) ** c does.
+		if exp.Operator == "**" {
+			left, right = prec+1, prec
+		}
+
+		pr.expression(exp.Left, left)
+		pr.write(" ", exp.Operator, " ")
+		pr.expression(exp.Right, right)
+	case *ast.AssignExpression:
+		pr.write(exp.Name.Value)
+		pr.assignedValue(exp.Token, exp.Value)
+	case *ast.IndexAssignment:
+		pr.expression(exp.Left, parser.CALL)
+		pr.index(exp.Index)
+		pr.assignedValue(exp.Token, exp.Value)
+	case *ast.PostfixExpression:
+		pr.write(exp.Name.Value, exp.Operator)
+	case *ast.ConditionalExpression:
+		pr.expression(exp.Condition, parser.CONDITIONAL+1)
+		pr.write(" ? ")
+		pr.expression(exp.Consequence, parser.LOWEST)
+		pr.write(" : ")
+		pr.expression(exp.Alternative, parser.CONDITIONAL)
+	case *ast.IfExpression:
+		pr.write("if (")
+		pr.expression(exp.Condition, parser.LOWEST)
+		pr.write(") ")
+		pr.block(exp.Consequence)
+
+		if exp.Alternative != nil {
+			pr.write(" else ")
+			pr.block(exp.Alternative)
+		}
+	case *ast.SwitchExpression:
+		pr.write("switch (")
+		pr.expression(exp.Subject, parser.LOWEST)
+		pr.write(") {")
+
+		for _, c := range exp.Cases {
+			pr.newline()
+			pr.write("case ")
+			pr.list(c.Values)
+			pr.write(":")
+			pr.caseBody(c.Body)
+		}
+
+		if exp.Default != nil {
+			pr.newline()
+			pr.write("default:")
+			pr.caseBody(exp.Default)
+		}
+
+		pr.newline()
+		pr.write("}")
+	case *ast.TryExpression:
+		pr.write("try ")
+		pr.block(exp.Block)
+		pr.write(" catch (", exp.Parameter.Value, ") ")
+		pr.block(exp.Catch)
+	case *ast.FunctionLiteral:
+		pr.write("fn")
+		pr.parameters(exp.Parameters, exp.Defaults, exp.Variadic)
+		pr.write(" ")
+		pr.block(exp.Body)
+	case *ast.MacroLiteral:
+		pr.write("macro")
+		pr.parameters(exp.Parameters, nil, false)
+		pr.write(" ")
+		pr.block(exp.Body)
+	case *ast.SpreadExpression:
+		pr.write("...")
+		pr.expression(exp.Value, parser.LOWEST)
+	case *ast.CallExpression:
+		args := exp.Arguments
+
+		if exp.Pipe {
+			pr.expression(args[0], parser.PIPE)
+			pr.write(" |> ")
+			args = args[1:]
+		}
+
+		pr.expression(exp.Function, parser.CALL)
+		pr.write("(")
+		pr.list(args)
+
+		for i, arg := range exp.Named {
+			if i > 0 || len(args) > 0 {
+				pr.write(", ")
+			}
+
+			pr.write(arg.Name + ": ")
+			pr.expression(arg.Value, parser.LOWEST)
+		}
+
+		pr.write(")")
+	case *ast.ArrayLiteral:
+		pr.write("[")
+		pr.list(exp.Elements)
+		pr.write("]")
+	case *ast.IndexExpression:
+		pr.expression(exp.Left, parser.CALL)
+
+		if exp.Optional {
+			pr.write("?")
+		}
+
+		pr.index(exp.Index)
+	case *ast.SliceExpression:
+		pr.expression(exp.Left, parser.CALL)
+
+		if exp.Optional {
+			pr.write("?")
+		}
+
+		pr.write("[")
+
+		if exp.Low != nil {
+			pr.expression(exp.Low, parser.LOWEST)
+		}
+
+		pr.write(":")
+
+		if exp.High != nil {
+			pr.expression(exp.High, parser.LOWEST)
+		}
+
+		pr.write("]")
+	case *ast.HashLiteral:
+		pr.write("{")
+
+		for i, pair := range exp.Pairs {
+			if i > 0 {
+				pr.write(", ")
+			}
+
+			pr.expression(pair.Key, parser.LOWEST)
+			pr.write(": ")
+			pr.expression(pair.Value, parser.LOWEST)
+		}
+
+		pr.write("}")
+	}
+}
+
+// assignedValue prints the operator and right-hand side of an assignment.
+// The parser desugars x += y into x = x + y but keeps the += token, so the
+// compound form can be put back.
+func (pr *printer) assignedValue(tok token.Token, value ast.Expression) {
+	if infix, ok := value.(*ast.InfixExpression); ok && tok.Literal != "=" {
+		pr.write(" ", tok.Literal, " ")
+		pr.expression(infix.Right, parser.ASSIGN)
+
+		return
+	}
+
+	pr.write(" = ")
+	pr.expression(value, parser.ASSIGN)
+}
+
+// index prints the [index] of an index expression or assignment, or .name
+// if the source used a dot.
+func (pr *printer) index(index ast.Expression) {
+	if name, ok := ast.PropertyName(index); ok {
+		pr.write(".", name)
+
+		return
+	}
+
+	pr.write("[")
+	pr.expression(index, parser.LOWEST)
+	pr.write("]")
+}
+
+// caseBody prints the statements of a case indented under its label.
+func (pr *printer) caseBody(body *ast.BlockStatement) {
+	if len(body.Statements) == 0 {
+		return
+	}
+
+	pr.indent++
+	pr.newline()
+	pr.statements(body.Statements)
+	pr.indent--
+}
+
+func (pr *printer) parameters(params []*ast.Identifier, defaults []ast.Expression, variadic bool) {
+	pr.write("(")
+
+	for i, param := range params {
+		if i > 0 {
+			pr.write(", ")
+		}
+
+		if variadic && i == len(params)-1 {
+			pr.write("...")
+		}
+
+		pr.write(param.Value)
+
+		if i < len(defaults) && defaults[i] != nil {
+			pr.write(" = ")
+			pr.expression(defaults[i], parser.LOWEST)
+		}
+	}
+
+	pr.write(")")
+}
+
+// stringText escapes s for use between the quotes of a string literal.
+func stringText(s string) string {
+	quoted := (&object.String{Value: s}).Inspect()
+
+	return quoted[1 : len(quoted)-1]
+}
+
+func (pr *printer) list(exps []ast.Expression) {
+	for i, exp := range exps {
+		if i > 0 {
+			pr.write(", ")
+		}
+
+		pr.expression(exp, parser.LOWEST)
+	}
+}
@@ -0,0 +1,141 @@
+package printer
+
+import (
+	"monkey/lexer"
+	"monkey/parser"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=1", "let x = 1;\n"},
+		{"let   add = fn(a,b){a+b}", "let add = fn(a, b) {\n    a + b;\n};\n"},
+		{"(1 + 2) * 3", "(1 + 2) * 3;\n"},
+		{"1 + (2 * 3)", "1 + 2 * 3;\n"},
+		{"a - (b - c)", "a - (b - c);\n"},
+		{"(a - b) - c", "a - b - c;\n"},
+		{"(a ** b) ** c", "(a ** b) ** c;\n"},
+		{"a ** (b ** c)", "a ** b ** c;\n"},
+		{"(-a) ** 2", "(-a) ** 2;\n"},
+		{"- -a", "-(-a);\n"},
+		{"x = (y = 1)", "x = y = 1;\n"},
+		{"(x = 1) + 2", "(x = 1) + 2;\n"},
+		{"x += 1; a[0] *= 2 + 3", "x += 1;\na[0] *= 2 + 3;\n"},
+		{"i++; i--", "i++;\ni--;\n"},
+		{`{"a":[1,2][0:1], true : 1.50}`, "{\"a\": [1, 2][0:1], true: 1.5};\n"},
+		{`"line\nbreak"`, "\"line\\nbreak\";\n"},
+		{"a[:2]; a[1:]; a[:]", "a[:2];\na[1:];\na[:];\n"},
+		{"h ?. a ?[ 0 ]?[1 :]", "h?.a?[0]?[1:];\n"},
+		{"let [a,b]=xs;const {name , age}=p", "let [a, b] = xs;\nconst {name, age} = p;\n"},
+		{"let q,r=f(); return q , [r]", "let q, r = f();\nreturn q, [r];\n"},
+		{"f(x)(y)[0]", "f(x)(y)[0];\n"},
+		{"(fn(){1})()", "fn() {\n    1;\n}();\n"},
+		{"a ?? (b ?? c)", "a ?? (b ?? c);\n"},
+		{"(a|b)&~c<<1", "(a | b) & ~c << 1;\n"},
+		{"0xFF+1_000+0b1", "0xFF + 1_000 + 0b1;\n"},
+		{"0x1_0000_0000_0000_0000+18446744073709551616", "0x1_0000_0000_0000_0000 + 18446744073709551616;\n"},
+		{"xs|>filter(even)|>len", "xs |> filter(even) |> len();\n"},
+		{"(x ?? y) |> f; x |> (y |> f)", "x ?? y |> f();\nx |> (y |> f())();\n"},
+		{"if(x){1}else{2}", "if (x) {\n    1;\n} else {\n    2;\n}\n"},
+		{"if (x) {1}; -y", "if (x) {\n    1;\n};\n-y;\n"},
+		{"if (x) {1}; y", "if (x) {\n    1;\n}\ny;\n"},
+		{"switch(x){case 1,2: \"a\" default: \"b\"}", "switch (x) {\ncase 1, 2:\n    \"a\";\ndefault:\n    \"b\";\n}\n"},
+		{"switch (x) { case 1: }; -y", "switch (x) {\ncase 1:\n};\n-y;\n"},
+		{"try{f()}catch(e){puts(e)}", "try {\n    f();\n} catch (e) {\n    puts(e);\n}\n"},
+		{"try {} catch (e) {}; [1]", "try {} catch (e) {};\n[1];\n"},
+		{"while(i<3){i=i+1;}", "while (i < 3) {\n    i = i + 1;\n}\n"},
+		{"do{i=i+1}while(i<3)", "do {\n    i = i + 1;\n} while (i < 3);\n"},
+		{"loop{break}", "loop {\n    break;\n}\n"},
+		{"for(;;){break;}", "for (;;) {\n    break;\n}\n"},
+		{"for(let i=0;i<3;i++){continue}", "for (let i = 0; i < 3; i++) {\n    continue;\n}\n"},
+		{"const  x=1;for(const i=0;;){}", "const x = 1;\nfor (const i = 0;;) {}\n"},
+		{"for(x in[1,2]){puts(x)}", "for (x in [1, 2]) {\n    puts(x);\n}\n"},
+		{"p . name=p.greet( 1 ).x;p . n+=1", "p.name = p.greet(1).x;\np.n += 1;\n"},
+		{"fn() {}", "fn() {};\n"},
+		{"let f = fn(a, b=a+1, ...rest) { g(a, b) }", "let f = fn(a, b = a + 1, ...rest) {\n    g(a, b);\n};\n"},
+		{"let f = fn(a, ...rest) { g(a, ...rest) }", "let f = fn(a, ...rest) {\n    g(a, ...rest);\n};\n"},
+		{"f(1,b:2,  c : x+1)", "f(1, b: 2, c: x + 1);\n"},
+		{"f(a:1)", "f(a: 1);\n"},
+		{"x=a||b ?c:d", "x = a || b ? c : d;\n"},
+		{"a ?b:c ?d:e", "a ? b : c ? d : e;\n"},
+		{"(a ?b:c)?d:e", "(a ? b : c) ? d : e;\n"},
+		{"(a ? b : c)(1) + 1", "(a ? b : c)(1) + 1;\n"},
+		{`"a${x+1}\"b\"${ f(y) }$"`, `"a${x + 1}\"b\"${f(y)}$";` + "\n"},
+		{`"\${x} ${y}"`, `"\${x} ${y}";` + "\n"},
+		{"let m = macro(a) { quote(unquote(a)) }", "let m = macro(a) {\n    quote(unquote(a));\n};\n"},
+		{"return 1", "return 1;\n"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		formatted, errors := Format(tt.input)
+
+		if len(errors) != 0 {
+			t.Errorf("Format(%q) returned errors: %v", tt.input, errors)
+			continue
+		}
+
+		if formatted != tt.expected {
+			t.Errorf("Format(%q) wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, formatted)
+		}
+	}
+}
+
+// TestFormatRoundTrip checks that formatting keeps the meaning of a program
+// and that formatting twice changes nothing.
+func TestFormatRoundTrip(t *testing.T) {
+	inputs := []string{
+		"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(10)",
+		"-a * b + !c - d / e % f ** g ** h",
+		"a || b && c == d ?? e <= f",
+		"(a | b) ^ c & d << 2 >> e == ~f",
+		"let r = [1, 2, 3] |> map(fn(x) { x * 2 }) |> reduce(0, fn(a, b) { a + b }) ? 1 : 0",
+		"let h = {}; h[1 + 2] = [1, 2, 3][1:][0] * -x[0]",
+		"let name = data?.user?[\"name\"] ?? (-x)?[0:2]",
+		"for (let i = 0; i < 10; i += 2) { if (i > 4) { break; } }",
+		"for (c in \"abc\") { if (c == \"b\") { continue; } puts(c); }",
+		"if (a) { 1 } else { if (b) { 2 } }; [1]",
+		"let r = try { let y = a[0]; y / 2 } catch (err) { len(err) } + 1",
+		"let f = fn(x) { switch (x % 3) { case 0: let y = x; y * 2 case 1, 2: x default: 0 } }",
+	}
+
+	for _, input := range inputs {
+		formatted, errors := Format(input)
+
+		if len(errors) != 0 {
+			t.Fatalf("Format(%q) returned errors: %v", input, errors)
+		}
+
+		if parse(t, formatted) != parse(t, input) {
+			t.Errorf("formatting changed the program.\ninput=%q\nformatted=%q", input, formatted)
+		}
+
+		again, _ := Format(formatted)
+
+		if again != formatted {
+			t.Errorf("formatting is not idempotent.\nfirst=%q\nsecond=%q", formatted, again)
+		}
+	}
+}
+
+func TestFormatParserErrors(t *testing.T) {
+	_, errors := Format("let = 1")
+
+	if len(errors) == 0 {
+		t.Errorf("expected parser errors")
+	}
+}
+
+func parse(t *testing.T, input string) string {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+
+	return program.String()
+}
@@ -0,0 +1,148 @@
+// Package profile counts the calls made to each function of a Monkey
+// program and the time spent in them. It knows nothing of how the calls are
+// made, so either engine can report to it.
+package profile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Entry is what a profile knows about one function.
+type Entry struct {
+	Name  string
+	Calls int
+	Total time.Duration // from the outermost call's start to its return
+}
+
+// Profile collects entries as functions are called. It is safe to use from
+// the goroutines spawned tasks run on.
+type Profile struct {
+	mu      sync.Mutex
+	entries map[string]*Entry
+	active  map[string]int // calls in progress, so recursion is timed once
+	now     func() time.Time
+}
+
+func New() *Profile {
+	return &Profile{
+		entries: make(map[string]*Entry),
+		active:  make(map[string]int),
+		now:     time.Now,
+	}
+}
+
+// Enter records a call to the function called name and returns the function
+// to call when it returns. Only the outermost of a recursive function's
+// calls adds to its time, so that the time of the calls it makes itself is
+// not counted again.
+func (p *Profile) Enter(name string) func() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry := p.entry(name)
+	entry.Calls++
+	p.active[name]++
+
+	start := p.now()
+
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.active[name]--
+
+		if p.active[name] == 0 {
+			entry.Total += p.now().Sub(start)
+		}
+	}
+}
+
+// Count records a call to the function called name without timing it.
+func (p *Profile) Count(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.entry(name).Calls++
+}
+
+func (p *Profile) entry(name string) *Entry {
+	entry, ok := p.entries[name]
+
+	if !ok {
+		entry = &Entry{Name: name}
+		p.entries[name] = entry
+	}
+
+	return entry
+}
+
+// Entries returns what the profile has collected, the functions that took
+// the longest first, then those called most often.
+func (p *Profile) Entries() []Entry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entries := make([]Entry, 0, len(p.entries))
+
+	for _, entry := range p.entries {
+		entries = append(entries, *entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+
+		if a.Calls != b.Calls {
+			return a.Calls > b.Calls
+		}
+
+		return a.Name < b.Name
+	})
+
+	return entries
+}
+
+// Reset forgets everything collected so far.
+func (p *Profile) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.entries = make(map[string]*Entry)
+	p.active = make(map[string]int)
+}
+
+// WriteReport writes the entries to w as a table, with the average time of
+// a call alongside the total.
+func (p *Profile) WriteReport(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Fprintln(tw, "calls\ttotal\taverage\t  function")
+
+	for _, entry := range p.Entries() {
+		average := entry.Total / time.Duration(entry.Calls)
+
+		fmt.Fprintf(tw, "%d\t%s\t%s\t  %s\n", entry.Calls, round(entry.Total), round(average), entry.Name)
+	}
+
+	tw.Flush()
+}
+
+// round shortens a duration to a few significant digits.
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(time.Microsecond)
+	default:
+		return d
+	}
+}
@@ -0,0 +1,76 @@
+package profile
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock moves on by a millisecond every time it is read.
+func fakeClock() func() time.Time {
+	now := time.Unix(0, 0)
+
+	return func() time.Time {
+		now = now.Add(time.Millisecond)
+
+		return now
+	}
+}
+
+func TestProfile(t *testing.T) {
+	p := New()
+	p.now = fakeClock()
+
+	outer := p.Enter("fib")
+	inner := p.Enter("fib")
+	p.Enter("len")()
+	inner()
+	outer()
+	p.Count("count")
+
+	expected := []Entry{
+		{Name: "fib", Calls: 2, Total: 4 * time.Millisecond},
+		{Name: "len", Calls: 1, Total: time.Millisecond},
+		{Name: "count", Calls: 1},
+	}
+
+	entries := p.Entries()
+
+	if len(entries) != len(expected) {
+		t.Fatalf("wrong number of entries. expected=%d, got=%d", len(expected), len(entries))
+	}
+
+	for i, entry := range entries {
+		if entry != expected[i] {
+			t.Errorf("wrong entry %d. expected=%+v, got=%+v", i, expected[i], entry)
+		}
+	}
+
+	p.Reset()
+
+	if len(p.Entries()) != 0 {
+		t.Errorf("entries not reset. got=%+v", p.Entries())
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	p := New()
+	p.now = fakeClock()
+
+	p.Enter("fib")()
+	p.Count("fib")
+
+	var out bytes.Buffer
+
+	p.WriteReport(&out)
+
+	expected := []string{
+		"  calls  total  average  function",
+		"      2    1ms    500µs  fib",
+	}
+
+	if got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong report.\nexpected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), out.String())
+	}
+}
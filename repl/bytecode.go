@@ -0,0 +1,113 @@
+package repl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/optimizer"
+	"monkey/parser"
+	"monkey/vm"
+	"os"
+)
+
+// Compile turns the script source into bytecode for the VM, going through
+// the same steps as the VM engine does before running it: macro expansion,
+// the checker, and the optimizer if optimize is set. It returns the
+// messages of whichever step failed instead, leaving out the checker's
+// warnings.
+func Compile(source string, optimize bool) (*compiler.Bytecode, []string) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		return nil, p.Errors()
+	}
+
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	node, err := evaluator.ExpandMacros(program, macroEnv)
+
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+
+	expanded := node.(*ast.Program)
+
+	session, _ := newEngineSession(ENGINE_VM, evaluator.Limits{})
+
+	if problems, _ := check(expanded, session); len(problems) != 0 {
+		return nil, problems
+	}
+
+	if optimize {
+		expanded = optimizer.Optimize(expanded)
+	}
+
+	comp := compiler.New()
+
+	if err := comp.Compile(expanded); err != nil {
+		return nil, []string{err.Error()}
+	}
+
+	return comp.Bytecode(), nil
+}
+
+// RunBytecode runs the compiled program at path, a file written by monkey
+// build, on the VM. Like RunFile, the program's output goes to out, errors
+// are written to errOut and the returned value is meant to be used as the
+// process exit code.
+func RunBytecode(path string, opts Options, out io.Writer, errOut io.Writer) int {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		fmt.Fprintf(errOut, "could not read %s: %s\n", path, err)
+
+		return 1
+	}
+
+	bytecode, err := compiler.DecodeBytecode(data)
+
+	if err != nil {
+		fmt.Fprintf(errOut, "could not load %s: %s\n", path, err)
+
+		return 1
+	}
+
+	ctx, stop := interruptible(opts.Interrupts)
+	defer stop()
+
+	if err := runDecoded(ctx, bytecode, object.NewIO(nil, out)); err != nil {
+		errObj := vm.ErrorObject(err)
+
+		if code, ok := exitCode(errObj); ok {
+			return code
+		}
+
+		newFormatter(opts).printTraceback(errOut, errObj)
+
+		return 1
+	}
+
+	return 0
+}
+
+// runDecoded runs bytecode read from a file. Decoding checks every
+// operand the VM reads, but the file did not come from this compiler, so
+// a panic the checks missed is reported as an error rather than a crash.
+func runDecoded(ctx context.Context, bytecode *compiler.Bytecode, io *object.IO) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed bytecode: %v", r)
+		}
+	}()
+
+	machine := vm.New(bytecode)
+	machine.SetIO(io)
+
+	return machine.RunContext(ctx)
+}
@@ -0,0 +1,141 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"monkey/evaluator"
+	"monkey/object"
+	"os"
+	"sort"
+	"strings"
+)
+
+// COMMAND_PREFIX starts a line the REPL handles itself instead of passing it
+// to the parser.
+const COMMAND_PREFIX = ":"
+
+var commandHelp = []struct {
+	usage       string
+	description string
+}{
+	{":help", "show this message"},
+	{":quit", "leave the REPL"},
+	{":load <file>", "run a file in the current session"},
+	{":reset", "forget every binding and macro, then rerun ~/.monkeyrc"},
+	{":env", "list the current bindings"},
+	{":type <expr>", "print the type of expr's value"},
+	{":debug", "turn the debugger on or off"},
+	{":break <line>", "pause at statements on line while debugging"},
+	{":clear <line>", "remove the breakpoint on line"},
+}
+
+// replState is what a meta-command can inspect and replace.
+type replState struct {
+	session  Session
+	opts     Options
+	out      io.Writer
+	format   formatter
+	debugger *debugger
+	io       *object.IO // what the session's readLine and puts use
+	results  int        // how many results have been kept as _1, _2 and so on
+}
+
+// runCommand executes one meta-command line. It reports false when the
+// REPL should stop.
+func (r *replState) runCommand(line string) bool {
+	name, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case ":help":
+		for _, cmd := range commandHelp {
+			fmt.Fprintf(r.out, "  %-14s %s\n", cmd.usage, cmd.description)
+		}
+	case ":quit":
+		return false
+	case ":load":
+		if arg == "" {
+			fmt.Fprintln(r.out, "usage: :load <file>")
+
+			break
+		}
+
+		source, err := os.ReadFile(arg)
+
+		if err != nil {
+			fmt.Fprintf(r.out, "could not read %s: %s\n", arg, err)
+
+			break
+		}
+
+		evaluated, errors := executeInterruptibly(string(source), r.session, r.opts.Interrupts, r.out)
+		r.format.printResult(r.out, evaluated, errors)
+	case ":reset":
+		session, err := NewSession(r.opts)
+
+		if err != nil {
+			fmt.Fprintln(r.out, err)
+
+			break
+		}
+
+		session.SetIO(r.io)
+		r.session = session
+		r.results = 0
+
+		if _, ok := r.runStartup(); ok {
+			return false
+		}
+	case ":env":
+		bindings := r.session.Bindings()
+		names := make([]string, 0, len(bindings))
+
+		for name := range bindings {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Fprintf(r.out, "%s = %s\n", name, bindings[name].Inspect())
+		}
+	case ":type":
+		if arg == "" {
+			fmt.Fprintln(r.out, "usage: :type <expr>")
+
+			break
+		}
+
+		evaluated, errors := executeInterruptibly(arg, r.session, r.opts.Interrupts, r.out)
+
+		if len(errors) != 0 || isError(evaluated) || evaluated == nil {
+			r.format.printResult(r.out, evaluated, errors)
+
+			break
+		}
+
+		fmt.Fprintln(r.out, evaluated.Type())
+	case ":debug":
+		if r.opts.Engine != ENGINE_EVAL {
+			fmt.Fprintf(r.out, "the debugger needs the %s engine\n", ENGINE_EVAL)
+
+			break
+		}
+
+		r.opts.Debug = !r.opts.Debug
+
+		if r.opts.Debug {
+			evaluator.SetDebugger(r.debugger)
+			fmt.Fprintln(r.out, "debugger on")
+		} else {
+			evaluator.SetDebugger(nil)
+			fmt.Fprintln(r.out, "debugger off")
+		}
+	case ":break", ":clear":
+		r.debugger.setBreakpoint(name == ":break", arg)
+	default:
+		fmt.Fprintf(r.out, "unknown command %s, try :help\n", name)
+	}
+
+	return true
+}
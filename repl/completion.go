@@ -0,0 +1,93 @@
+package repl
+
+import (
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/token"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// hashIndex matches an index into a name being typed at the end of a line,
+// such as `h[` or `h["na`.
+var hashIndex = regexp.MustCompile(`([\pL_?!][\pL\d_?!]*)\[\s*("[^"]*)?$`)
+
+// complete is the REPL's Completer, drawing on what the session has bound.
+func (r *replState) complete(before string) (string, []string) {
+	return completions(before, r.session.Bindings())
+}
+
+// completions finishes the hash key or the name at the end of before. After
+// h[ it offers the keys of the hash bound to h, each as the rest of the
+// index expression, such as "name"]. Otherwise it offers the keywords,
+// builtins and bindings that start with the name being typed.
+func completions(before string, bindings map[string]object.Object) (string, []string) {
+	if match := hashIndex.FindStringSubmatch(before); match != nil {
+		if hash, ok := bindings[match[1]].(*object.Hash); ok {
+			return match[2], hashKeyCompletions(hash, match[2])
+		}
+	}
+
+	start := len(before)
+
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(before[:start])
+
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '?' && r != '!' {
+			break
+		}
+
+		start -= size
+	}
+
+	word := before[start:]
+
+	if word == "" {
+		return "", nil
+	}
+
+	seen := map[string]bool{}
+	var candidates []string
+
+	add := func(name string) {
+		if strings.HasPrefix(name, word) && !seen[name] {
+			seen[name] = true
+			candidates = append(candidates, name)
+		}
+	}
+
+	for _, name := range token.Keywords() {
+		add(name)
+	}
+
+	for _, name := range evaluator.BuiltinNames() {
+		add(name)
+	}
+
+	for name := range bindings {
+		add(name)
+	}
+
+	sort.Strings(candidates)
+
+	return word, candidates
+}
+
+// hashKeyCompletions returns the keys of hash, in insertion order, written
+// as they would be indexed and closed with a ], that start with typed.
+func hashKeyCompletions(hash *object.Hash, typed string) []string {
+	var candidates []string
+
+	for _, pair := range hash.OrderedPairs() {
+		key := pair.Key.Inspect() + "]"
+
+		if strings.HasPrefix(key, typed) {
+			candidates = append(candidates, key)
+		}
+	}
+
+	return candidates
+}
@@ -0,0 +1,93 @@
+package repl
+
+import (
+	"bytes"
+	"monkey/object"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompletions(t *testing.T) {
+	session, _ := NewSession(Options{Engine: ENGINE_EVAL})
+	Execute(`let person = {"name": 1, "nickname": 2, 7: 3}; let counter = 0; let count = 0;`, session)
+	bindings := session.Bindings()
+
+	tests := []struct {
+		before     string
+		word       string
+		candidates []string
+	}{
+		{"le", "le", []string{"len", "let"}},
+		{"let x = cou", "cou", []string{"count", "counter"}},
+		{"puts(pers", "pers", []string{"person"}},
+		{"fil", "fil", []string{"filter"}},
+		{"whi", "whi", []string{"while"}},
+		{"person[", "", []string{`"name"]`, `"nickname"]`, "7]"}},
+		{`person["n`, `"n`, []string{`"name"]`, `"nickname"]`}},
+		{`person["ni`, `"ni`, []string{`"nickname"]`}},
+		{"counter[", "", nil},
+		{"zzz", "zzz", nil},
+		{"1 + ", "", nil},
+	}
+
+	for _, tt := range tests {
+		word, candidates := completions(tt.before, bindings)
+
+		if word != tt.word {
+			t.Errorf("%q: wrong word. expected=%q, got=%q", tt.before, tt.word, word)
+		}
+
+		if !reflect.DeepEqual(candidates, tt.candidates) {
+			t.Errorf("%q: wrong candidates. expected=%q, got=%q", tt.before, tt.candidates, candidates)
+		}
+	}
+}
+
+func TestLineEditorCompletion(t *testing.T) {
+	complete := func(before string) (string, []string) {
+		return completions(before, map[string]object.Object{"counter": object.NewInteger(0), "count": object.NewInteger(0)})
+	}
+
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"parseJ\t(s)\r", "parseJSON(s)"},
+		{"cou\t\r", "count"},
+		{"cou\te\t\r", "counter"},
+		{"(x)\x01parseJ\t\r", "parseJSON(x)"},
+		{"zz\t\r", "zz"},
+	}
+
+	for _, tt := range tests {
+		editor := NewLineEditor(strings.NewReader(tt.keys), &bytes.Buffer{}, nil)
+		editor.SetCompleter(complete)
+
+		line, err := editor.ReadLine(PROMPT)
+
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", tt.keys, err)
+		}
+
+		if line != tt.expected {
+			t.Errorf("wrong line for %q. expected=%q, got=%q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+func TestLineEditorListsCandidates(t *testing.T) {
+	out := &bytes.Buffer{}
+	editor := NewLineEditor(strings.NewReader("le\t\r"), out, nil)
+	editor.SetCompleter(func(before string) (string, []string) {
+		return completions(before, nil)
+	})
+
+	if _, err := editor.ReadLine(PROMPT); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !strings.Contains(out.String(), "\r\nlen  let\r\n") {
+		t.Errorf("candidates not listed. got=%q", out.String())
+	}
+}
@@ -0,0 +1,161 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/token"
+	"strconv"
+	"strings"
+)
+
+const DEBUG_PROMPT = "debug>>"
+
+var debugHelp = []struct {
+	usage       string
+	description string
+}{
+	{"continue, c", "run until the next breakpoint"},
+	{"step, s", "run the next statement and pause again"},
+	{"print <expr>, p", "evaluate expr where the program is paused"},
+	{"env", "list the bindings in scope, innermost first"},
+	{"where", "show where the program is paused"},
+	{"break <line>", "pause at statements on line"},
+	{"clear <line>", "remove the breakpoint on line"},
+	{"help", "show this message"},
+}
+
+// debugger is the REPL's evaluator.Debugger. It pauses at breakpoint()
+// calls, at statements on a line set with :break or break, and after every
+// step, and then reads commands from the REPL's input until told to carry
+// on.
+type debugger struct {
+	reader   LineReader
+	out      io.Writer
+	format   formatter
+	lines    map[int]bool
+	stepping bool
+}
+
+func newDebugger(reader LineReader, out io.Writer, format formatter) *debugger {
+	return &debugger{reader: reader, out: out, format: format, lines: make(map[int]bool)}
+}
+
+func (d *debugger) Statement(stmt ast.Statement, env *object.Environment) {
+	tok := ast.StatementToken(stmt)
+
+	if d.stepping || d.lines[tok.Line] {
+		d.pause(tok, stmt.String(), env)
+	}
+}
+
+func (d *debugger) Breakpoint(call *ast.CallExpression, env *object.Environment) {
+	tok := call.Token
+
+	if ident, ok := call.Function.(*ast.Identifier); ok {
+		tok = ident.Token
+	}
+
+	d.pause(tok, call.String(), env)
+}
+
+// pause reports where the program stopped and runs debugger commands.
+// Running out of input lets the program finish.
+func (d *debugger) pause(tok token.Token, source string, env *object.Environment) {
+	where := fmt.Sprintf("paused at line %d, col %d: %s\n", tok.Line, tok.Column, source)
+	io.WriteString(d.out, where)
+
+	for {
+		line, err := d.reader.ReadLine(d.format.prompt(DEBUG_PROMPT))
+
+		if err == ErrInterrupted {
+			continue
+		}
+
+		if err != nil {
+			d.stepping = false
+			d.lines = make(map[int]bool)
+
+			return
+		}
+
+		name, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+		arg = strings.TrimSpace(arg)
+
+		switch name {
+		case "continue", "c":
+			d.stepping = false
+
+			return
+		case "step", "s":
+			d.stepping = true
+
+			return
+		case "print", "p":
+			d.print(arg, env)
+		case "env":
+			for scope := env; scope != nil; scope = scope.Outer() {
+				for _, name := range scope.Names() {
+					value, _ := scope.Get(name)
+					fmt.Fprintf(d.out, "%s = %s\n", name, value.Inspect())
+				}
+			}
+		case "where":
+			io.WriteString(d.out, where)
+		case "break", "clear":
+			d.setBreakpoint(name == "break", arg)
+		case "help":
+			for _, cmd := range debugHelp {
+				fmt.Fprintf(d.out, "  %-16s %s\n", cmd.usage, cmd.description)
+			}
+		case "":
+		default:
+			fmt.Fprintf(d.out, "unknown debugger command %s, try help\n", name)
+		}
+	}
+}
+
+// print evaluates source in env with the debugger switched off, so it does
+// not pause inside its own evaluation.
+func (d *debugger) print(source string, env *object.Environment) {
+	if source == "" {
+		io.WriteString(d.out, "usage: print <expr>\n")
+
+		return
+	}
+
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		d.format.printErrors(d.out, p.Errors())
+
+		return
+	}
+
+	evaluator.SetDebugger(nil)
+	defer evaluator.SetDebugger(d)
+
+	d.format.printResult(d.out, evaluator.Eval(program, env), nil)
+}
+
+// setBreakpoint adds or removes the breakpoint on the line arg names.
+func (d *debugger) setBreakpoint(set bool, arg string) {
+	line, err := strconv.Atoi(arg)
+
+	if err != nil || line < 1 {
+		fmt.Fprintf(d.out, "a breakpoint needs a line number, got %q\n", arg)
+
+		return
+	}
+
+	if set {
+		d.lines[line] = true
+	} else {
+		delete(d.lines, line)
+	}
+}
@@ -0,0 +1,493 @@
+package repl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/checker"
+	"monkey/compiler"
+	"monkey/coverage"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/optimizer"
+	"monkey/parser"
+	"monkey/profile"
+	"monkey/vm"
+	"os"
+)
+
+const (
+	ENGINE_EVAL = "eval"
+	ENGINE_VM   = "vm"
+)
+
+// Options configures the sessions the REPL and the file runner create.
+type Options struct {
+	Engine   string // ENGINE_EVAL or ENGINE_VM
+	Optimize bool   // run the optimizer on every program before it runs
+	Debug    bool   // start with the debugger on; needs ENGINE_EVAL
+	Trace    bool   // print every node the evaluator runs; needs ENGINE_EVAL
+	Profile  bool   // report the calls made to each function; needs ENGINE_EVAL
+	Color    bool   // color prompts, results and errors with ANSI escape codes
+	NoBanner bool   // leave the monkey face out of parser and checker errors
+	Prompt   string // shown instead of PROMPT, if set
+	MaxDepth int    // fail calls nested deeper than this, if set; needs ENGINE_EVAL
+
+	// Startup names a script the REPL runs in its session before the first
+	// prompt, and again after :reset, so that it can define helpers. It is
+	// skipped if the file does not exist.
+	Startup string
+
+	// Cover and CoverProfile report which lines of a script, and of the
+	// modules it imports, ran once it finishes: as a listing of the source
+	// annotated with counts, written where errors go, and as an LCOV
+	// tracefile written to the file CoverProfile names. Both need
+	// ENGINE_EVAL.
+	Cover        bool
+	CoverProfile string
+
+	// Interrupts, if set, stops the evaluation in progress when a signal
+	// arrives on it, as main does for Ctrl-C. Signals that arrive between
+	// evaluations are dropped.
+	Interrupts <-chan os.Signal
+}
+
+// Session is the state an engine keeps between inputs: an environment for
+// the tree-walking evaluator, or globals and constants for the VM. Either
+// way it also keeps the macros defined so far.
+type Session interface {
+	Run(ctx context.Context, program *ast.Program) object.Object
+	MacroEnv() *object.Environment
+	Bindings() map[string]object.Object
+	Bind(name string, value object.Object)
+	SetIO(io *object.IO)
+
+	// Defines reports whether name is bound before the next input runs:
+	// by an earlier input, or as one of the engine's builtins. The VM has
+	// only the shared object.Builtins, not those the evaluator registers
+	// for itself, such as map and spawn.
+	Defines(name string) bool
+}
+
+// macros holds the environment macro definitions are bound in. Macro
+// expansion always runs on the evaluator, whichever engine runs the result.
+type macros struct {
+	env *object.Environment
+}
+
+func (m *macros) MacroEnv() *object.Environment {
+	return m.env
+}
+
+func NewSession(opts Options) (Session, error) {
+	if opts.Debug && opts.Engine != ENGINE_EVAL {
+		return nil, fmt.Errorf("the debugger needs the %s engine", ENGINE_EVAL)
+	}
+
+	if opts.Trace && opts.Engine != ENGINE_EVAL {
+		return nil, fmt.Errorf("tracing needs the %s engine", ENGINE_EVAL)
+	}
+
+	if opts.Profile && opts.Engine != ENGINE_EVAL {
+		return nil, fmt.Errorf("profiling needs the %s engine", ENGINE_EVAL)
+	}
+
+	if (opts.Cover || opts.CoverProfile != "") && opts.Engine != ENGINE_EVAL {
+		return nil, fmt.Errorf("coverage needs the %s engine", ENGINE_EVAL)
+	}
+
+	if opts.MaxDepth != 0 && opts.Engine != ENGINE_EVAL {
+		return nil, fmt.Errorf("a maximum call depth needs the %s engine", ENGINE_EVAL)
+	}
+
+	session, err := newEngineSession(opts.Engine, evaluator.Limits{MaxDepth: opts.MaxDepth})
+
+	if err != nil || !opts.Optimize {
+		return session, err
+	}
+
+	return &optimizingSession{Session: session}, nil
+}
+
+// newEngineSession makes a session for engine. limits only bound the
+// tree-walking evaluator.
+func newEngineSession(engine string, limits evaluator.Limits) (Session, error) {
+	switch engine {
+	case ENGINE_EVAL:
+		return &evalSession{
+			macros: macros{env: object.NewEnvironment()},
+			env:    object.NewEnvironment(),
+			limits: limits,
+		}, nil
+	case ENGINE_VM:
+		return &vmSession{
+			macros:      macros{env: object.NewEnvironment()},
+			constants:   []object.Object{},
+			globals:     make([]object.Value, vm.GlobalsSize),
+			symbolTable: compiler.NewSymbolTableWithBuiltins(),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown engine %q, want %s or %s", engine, ENGINE_EVAL, ENGINE_VM)
+	}
+}
+
+// optimizingSession optimizes each program, after macro expansion, before
+// handing it to the engine.
+type optimizingSession struct {
+	Session
+}
+
+func (s *optimizingSession) Run(ctx context.Context, program *ast.Program) object.Object {
+	return s.Session.Run(ctx, optimizer.Optimize(program))
+}
+
+type evalSession struct {
+	macros
+	env    *object.Environment
+	limits evaluator.Limits
+}
+
+func (s *evalSession) Run(ctx context.Context, program *ast.Program) object.Object {
+	return evaluator.EvalContext(ctx, program, s.env, s.limits)
+}
+
+func (s *evalSession) Bind(name string, value object.Object) {
+	s.env.Set(name, value)
+}
+
+func (s *evalSession) Defines(name string) bool {
+	_, ok := s.env.Get(name)
+
+	return ok || evaluator.IsBuiltin(name)
+}
+
+func (s *evalSession) SetIO(io *object.IO) {
+	s.env.SetIO(io)
+	s.macros.env.SetIO(io)
+}
+
+func (s *evalSession) Bindings() map[string]object.Object {
+	bindings := make(map[string]object.Object)
+
+	for _, name := range s.env.Names() {
+		bindings[name], _ = s.env.Get(name)
+	}
+
+	return bindings
+}
+
+type vmSession struct {
+	macros
+	constants   []object.Object
+	globals     []object.Value
+	symbolTable *compiler.SymbolTable
+	io          *object.IO
+}
+
+// Run compiles and executes program. Compiler and VM failures are reported
+// as *object.Error so callers can treat both engines the same way.
+func (s *vmSession) Run(ctx context.Context, program *ast.Program) object.Object {
+	comp := compiler.NewWithState(s.symbolTable, s.constants)
+
+	err := comp.Compile(program)
+
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+
+	code := comp.Bytecode()
+	s.constants = code.Constants
+
+	machine := vm.NewWithGlobalsStore(code, s.globals)
+	machine.SetIO(s.io)
+
+	err = machine.RunContext(ctx)
+
+	if err != nil {
+		return vm.ErrorObject(err)
+	}
+
+	return machine.LastPoppedStackElem()
+}
+
+// Bind defines name as a global, reusing its slot if it has one.
+func (s *vmSession) Bind(name string, value object.Object) {
+	symbol, ok := s.symbolTable.Resolve(name)
+
+	if !ok || symbol.Scope != compiler.GlobalScope || symbol.Constant {
+		symbol = s.symbolTable.Define(name)
+	}
+
+	s.globals[symbol.Index] = object.ValueOf(value)
+}
+
+// Defines, like Bindings, skips globals that were defined but never set.
+func (s *vmSession) Defines(name string) bool {
+	symbol, ok := s.symbolTable.Resolve(name)
+
+	switch {
+	case !ok:
+		return false
+	case symbol.Scope == compiler.GlobalScope:
+		return s.globals[symbol.Index].Object() != nil
+	default:
+		return symbol.Scope == compiler.BuiltinScope
+	}
+}
+
+func (s *vmSession) SetIO(io *object.IO) {
+	s.io = io
+	s.macros.env.SetIO(io)
+}
+
+// Bindings skips globals that were defined but never set, which happens when
+// a run fails before reaching the let.
+func (s *vmSession) Bindings() map[string]object.Object {
+	bindings := make(map[string]object.Object)
+
+	for _, symbol := range s.symbolTable.Globals() {
+		if value := s.globals[symbol.Index].Object(); value != nil {
+			bindings[symbol.Name] = value
+		}
+	}
+
+	return bindings
+}
+
+// Execute parses input, expands its macros and runs it in session. It is the
+// single execution path shared by the REPL and the file runner, so both see
+// the same engine setup. Parser errors, and the errors the checker finds
+// once macros are expanded, are returned instead of being evaluated; the
+// checker's warnings are dropped.
+func Execute(input string, session Session) (object.Object, []string) {
+	return ExecuteContext(context.Background(), input, session)
+}
+
+// ExecuteContext is Execute stopped early, with an error, once ctx is done.
+func ExecuteContext(ctx context.Context, input string, session Session) (object.Object, []string) {
+	return execute(ctx, input, session, io.Discard)
+}
+
+// execute is ExecuteContext writing the checker's warnings to warnings, a
+// line each, before running the input.
+func execute(ctx context.Context, input string, session Session, warnings io.Writer) (object.Object, []string) {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		return nil, p.Errors()
+	}
+
+	evaluator.DefineMacros(program, session.MacroEnv())
+	node, err := evaluator.ExpandMacros(program, session.MacroEnv())
+
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+
+	expanded := node.(*ast.Program)
+
+	problems, cautions := check(expanded, session)
+
+	if len(problems) != 0 {
+		return nil, problems
+	}
+
+	for _, caution := range cautions {
+		fmt.Fprintln(warnings, caution)
+	}
+
+	return session.Run(ctx, expanded), nil
+}
+
+// check runs the checker on program, counting whatever session defines,
+// from its globals to its engine's builtins, as bound. Warnings are
+// returned apart from the errors that stop program from running.
+func check(program *ast.Program, session Session) ([]string, []string) {
+	var errors, warnings []string
+
+	for _, problem := range checker.Check(program, session.Defines) {
+		if problem.Warning {
+			warnings = append(warnings, problem.String())
+		} else {
+			errors = append(errors, problem.String())
+		}
+	}
+
+	return errors, warnings
+}
+
+// RunFile evaluates the Monkey script at path from top to bottom. The
+// script's output goes to out and errors are written to errOut; the
+// returned value is meant to be used as the process exit code.
+func RunFile(path string, opts Options, out io.Writer, errOut io.Writer) int {
+	source, err := os.ReadFile(path)
+
+	if err != nil {
+		fmt.Fprintf(errOut, "could not read %s: %s\n", path, err)
+
+		return 1
+	}
+
+	session, err := NewSession(opts)
+
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+
+		return 1
+	}
+
+	if opts.Debug {
+		reader := NewLineReader(os.Stdin, out, nil, nil)
+		session.SetIO(object.NewIO(&lineInput{reader: reader}, out))
+		evaluator.SetDebugger(newDebugger(reader, out, newFormatter(opts)))
+
+		defer evaluator.SetDebugger(nil)
+	} else {
+		session.SetIO(object.NewIO(nil, out))
+	}
+
+	if opts.Trace {
+		evaluator.SetTrace(errOut)
+
+		defer evaluator.SetTrace(nil)
+	}
+
+	var prof *profile.Profile
+
+	if opts.Profile {
+		prof = profile.New()
+		evaluator.SetProfiler(prof)
+
+		defer evaluator.SetProfiler(nil)
+	}
+
+	var cov *coverage.Coverage
+
+	if opts.Cover || opts.CoverProfile != "" {
+		cov = coverage.New()
+		evaluator.SetCoverage(cov, path)
+
+		defer evaluator.SetCoverage(nil, "")
+	}
+
+	var errors []string
+
+	// Relative imports resolve against the script's directory, not the
+	// working directory.
+	evaluated := evaluator.InFile(path, func() object.Object {
+		var evaluated object.Object
+
+		evaluated, errors = executeInterruptibly(string(source), session, opts.Interrupts, errOut)
+
+		return evaluated
+	})
+
+	if prof != nil && len(errors) == 0 {
+		prof.WriteReport(errOut)
+	}
+
+	if cov != nil && len(errors) == 0 && !writeCoverage(cov, opts, errOut) {
+		return 1
+	}
+
+	format := newFormatter(opts)
+
+	if len(errors) != 0 {
+		format.printErrors(errOut, errors)
+
+		return 1
+	}
+
+	if code, ok := exitCode(evaluated); ok {
+		return code
+	}
+
+	if errObj, ok := evaluated.(*object.Error); ok {
+		format.printTraceback(errOut, errObj)
+
+		return 1
+	}
+
+	return 0
+}
+
+// exitCode returns the status exit(code) asked for, if that is what ended
+// a run with the result evaluated.
+func exitCode(evaluated object.Object) (int, bool) {
+	if errObj, ok := evaluated.(*object.Error); ok && errObj.Exit != nil {
+		return errObj.Exit.Code, true
+	}
+
+	return 0, false
+}
+
+// writeCoverage writes what cov recorded as opts asks, reporting whether
+// it could.
+func writeCoverage(cov *coverage.Coverage, opts Options, errOut io.Writer) bool {
+	if opts.Cover {
+		for _, file := range cov.Files() {
+			source, err := os.ReadFile(file)
+
+			if err != nil {
+				fmt.Fprintf(errOut, "could not read %s: %s\n", file, err)
+
+				return false
+			}
+
+			cov.WriteListing(errOut, file, string(source))
+		}
+	}
+
+	if opts.CoverProfile != "" {
+		if err := cov.WriteLCOVFile(opts.CoverProfile); err != nil {
+			fmt.Fprintf(errOut, "could not write %s: %s\n", opts.CoverProfile, err)
+
+			return false
+		}
+	}
+
+	return true
+}
+
+// executeInterruptibly is Execute stopped early by a signal on interrupts,
+// writing the checker's warnings to warnings.
+func executeInterruptibly(input string, session Session, interrupts <-chan os.Signal, warnings io.Writer) (object.Object, []string) {
+	ctx, stop := interruptible(interrupts)
+	defer stop()
+
+	return execute(ctx, input, session, warnings)
+}
+
+// interruptible returns a context that is cancelled when a signal arrives on
+// interrupts, and a function that stops watching for one. Signals already
+// waiting are dropped first, as they were meant for an earlier evaluation.
+func interruptible(interrupts <-chan os.Signal) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if interrupts == nil {
+		return ctx, cancel
+	}
+
+	for len(interrupts) > 0 {
+		<-interrupts
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+		case <-done:
+		}
+	}()
+
+	return ctx, func() {
+		close(done)
+		cancel()
+	}
+}
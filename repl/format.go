@@ -0,0 +1,99 @@
+package repl
+
+import (
+	"io"
+	"monkey/object"
+)
+
+// ANSI escape codes for the colors the REPL uses.
+const (
+	COLOR_RESET   = "\x1b[0m"
+	COLOR_RED     = "\x1b[31m"
+	COLOR_GREEN   = "\x1b[32m"
+	COLOR_YELLOW  = "\x1b[33m"
+	COLOR_BLUE    = "\x1b[34m"
+	COLOR_MAGENTA = "\x1b[35m"
+	COLOR_GRAY    = "\x1b[90m"
+	COLOR_PROMPT  = "\x1b[1;32m"
+)
+
+// typeColors colors a result by its type. Arrays, hashes and the other
+// containers are left alone, as they mix values of every type.
+var typeColors = map[object.ObjectType]string{
+	object.INTEGER_OBJ:           COLOR_YELLOW,
+	object.BIGINT_OBJ:            COLOR_YELLOW,
+	object.FLOAT_OBJ:             COLOR_YELLOW,
+	object.STRING_OBJ:            COLOR_GREEN,
+	object.BOOLEAN_OBJ:           COLOR_MAGENTA,
+	object.NULL_OBJ:              COLOR_GRAY,
+	object.FUNCTION_OBJ:          COLOR_BLUE,
+	object.BUILTIN_OBJ:           COLOR_BLUE,
+	object.CLOSURE_OBJ:           COLOR_BLUE,
+	object.COMPILED_FUNCTION_OBJ: COLOR_BLUE,
+}
+
+// formatter is how the REPL shows prompts, results and errors: in color or
+// not, and with or without the monkey face above parser errors.
+type formatter struct {
+	color  bool
+	banner bool
+}
+
+func newFormatter(opts Options) formatter {
+	return formatter{color: opts.Color, banner: !opts.NoBanner}
+}
+
+// paint wraps text in color, if colors are on.
+func (f formatter) paint(text, color string) string {
+	if !f.color || color == "" || text == "" {
+		return text
+	}
+
+	return color + text + COLOR_RESET
+}
+
+func (f formatter) prompt(prompt string) string {
+	return f.paint(prompt, COLOR_PROMPT)
+}
+
+// printResult writes what the REPL shows after running an input: parser
+// or checker errors, an error's traceback, or the value itself, spread over
+// several lines if it is too wide for one.
+func (f formatter) printResult(out io.Writer, evaluated object.Object, errors []string) {
+	if len(errors) != 0 {
+		f.printErrors(out, errors)
+
+		return
+	}
+
+	if isError(evaluated) {
+		f.printTraceback(out, evaluated.(*object.Error))
+	} else if evaluated != nil {
+		io.WriteString(out, f.paint(object.Pretty(evaluated, -1), typeColors[evaluated.Type()]))
+		io.WriteString(out, "\n")
+	}
+}
+
+func (f formatter) printTraceback(out io.Writer, err *object.Error) {
+	io.WriteString(out, f.paint(err.Traceback(), COLOR_RED))
+	io.WriteString(out, "\n")
+}
+
+func (f formatter) printErrors(out io.Writer, errors []string) {
+	if f.banner {
+		io.WriteString(out, MONKEY_FACE)
+		io.WriteString(out, "Whoops! we ran into some monkey business here!\n")
+	}
+
+	io.WriteString(out, " errors:\n")
+
+	for _, msg := range errors {
+		io.WriteString(out, "\t"+f.paint(msg, COLOR_RED)+"\n")
+	}
+}
+
+func isError(obj object.Object) bool {
+	_, ok := obj.(*object.Error)
+
+	return ok
+}
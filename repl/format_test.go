@@ -0,0 +1,85 @@
+package repl
+
+import (
+	"bytes"
+	"monkey/object"
+	"strings"
+	"testing"
+)
+
+func TestFormatterColors(t *testing.T) {
+	tests := []struct {
+		value    object.Object
+		expected string
+	}{
+		{object.NewInteger(5), "\x1b[33m5\x1b[0m\n"},
+		{&object.Float{Value: 1.5}, "\x1b[33m1.5\x1b[0m\n"},
+		{&object.String{Value: "hi"}, "\x1b[32m\"hi\"\x1b[0m\n"},
+		{object.TRUE, "\x1b[35mtrue\x1b[0m\n"},
+		{object.NULL, "\x1b[90mnull\x1b[0m\n"},
+		{&object.Array{Elements: []object.Object{object.NewInteger(1)}}, "[1]\n"},
+		{&object.Error{Message: "boom"}, "\x1b[31mERROR: boom\x1b[0m\n"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+
+		formatter{color: true}.printResult(&out, tt.value, nil)
+
+		if out.String() != tt.expected {
+			t.Errorf("wrong output for %s. expected=%q, got=%q", tt.value.Inspect(), tt.expected, out.String())
+		}
+
+		out.Reset()
+		formatter{}.printResult(&out, tt.value, nil)
+
+		if strings.Contains(out.String(), "\x1b[") {
+			t.Errorf("colored output with colors off: %q", out.String())
+		}
+	}
+}
+
+func TestFormatterSpreadsWideResults(t *testing.T) {
+	word := &object.String{Value: strings.Repeat("a", 40)}
+	value := &object.Array{Elements: []object.Object{word, word}}
+
+	var out bytes.Buffer
+
+	formatter{}.printResult(&out, value, nil)
+
+	expected := "[\n  \"" + word.Value + "\",\n  \"" + word.Value + "\"\n]\n"
+
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestFormatterBanner(t *testing.T) {
+	var out bytes.Buffer
+
+	formatter{banner: true}.printErrors(&out, []string{"oops"})
+
+	if !strings.HasPrefix(out.String(), MONKEY_FACE) || !strings.HasSuffix(out.String(), " errors:\n\toops\n") {
+		t.Errorf("wrong errors with the banner. got=%q", out.String())
+	}
+
+	out.Reset()
+	formatter{}.printErrors(&out, []string{"oops"})
+
+	if out.String() != " errors:\n\toops\n" {
+		t.Errorf("wrong errors without the banner. got=%q", out.String())
+	}
+}
+
+func TestColoredRepl(t *testing.T) {
+	var out bytes.Buffer
+
+	Start(strings.NewReader("1 + 1\nlet = 1\n"), &out, Options{Engine: ENGINE_EVAL, Color: true, NoBanner: true})
+
+	expected := "\x1b[1;32m>>\x1b[0m\x1b[33m2\x1b[0m\n" +
+		"\x1b[1;32m>>\x1b[0m errors:\n\t\x1b[31mparse error at line 1, col 5: expected next token to be IDENT, got = instead\x1b[0m\n"
+
+	if !strings.HasPrefix(out.String(), expected) {
+		t.Errorf("wrong output. expected prefix=%q, got=%q", expected, out.String())
+	}
+}
@@ -0,0 +1,104 @@
+package repl
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const HISTORY_FILE = ".monkey_history"
+const MAX_HISTORY = 1000
+
+// History keeps previously entered lines, oldest first, and optionally
+// persists them to a file so they survive between sessions.
+type History struct {
+	entries []string
+	path    string
+}
+
+func NewHistory() *History {
+	return &History{entries: []string{}}
+}
+
+// DefaultHistoryPath returns ~/.monkey_history, or "" if there is no home
+// directory to put it in.
+func DefaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, HISTORY_FILE)
+}
+
+// LoadHistory reads the history stored at path. A missing file is not an
+// error; it simply starts an empty history that will be saved to path.
+func LoadHistory(path string) (*History, error) {
+	h := NewHistory()
+	h.path = path
+
+	file, err := os.Open(path)
+
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+
+	if err != nil {
+		return h, err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		h.push(scanner.Text())
+	}
+
+	return h, scanner.Err()
+}
+
+// Add records line, skipping blank lines and immediate repeats, and appends
+// it to the history file if there is one.
+func (h *History) Add(line string) error {
+	if strings.TrimSpace(line) == "" || (h.Len() > 0 && h.entries[h.Len()-1] == line) {
+		return nil
+	}
+
+	h.push(line)
+
+	if h.path == "" {
+		return nil
+	}
+
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	_, err = file.WriteString(line + "\n")
+
+	return err
+}
+
+func (h *History) push(line string) {
+	h.entries = append(h.entries, line)
+
+	if len(h.entries) > MAX_HISTORY {
+		h.entries = h.entries[len(h.entries)-MAX_HISTORY:]
+	}
+}
+
+func (h *History) Len() int {
+	return len(h.entries)
+}
+
+// At returns the i-th entry, where 0 is the oldest.
+func (h *History) At(i int) string {
+	return h.entries[i]
+}
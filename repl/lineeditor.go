@@ -0,0 +1,357 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// ErrInterrupted is returned by LineReader.ReadLine when the user pressed
+// Ctrl-C; the partially typed line is discarded.
+var ErrInterrupted = errors.New("interrupted")
+
+// LineReader reads one line of input after showing prompt. io.EOF means the
+// input is exhausted (or Ctrl-D was pressed on an empty line).
+type LineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// Completer suggests how to finish the text before the cursor. It returns
+// the word at the end of before that the candidates would replace, and the
+// candidates, each a full replacement for it.
+type Completer func(before string) (word string, candidates []string)
+
+// NewLineReader picks a line editor with history, and Tab completion with
+// complete if it is not nil, when in and out are both a terminal, and a
+// plain line scanner otherwise (pipes, files, tests).
+func NewLineReader(in io.Reader, out io.Writer, history *History, complete Completer) LineReader {
+	inFile, inOk := in.(*os.File)
+	outFile, outOk := out.(*os.File)
+
+	if inOk && outOk && term.IsTerminal(int(inFile.Fd())) && term.IsTerminal(int(outFile.Fd())) {
+		editor := NewLineEditor(in, out, history)
+		editor.SetCompleter(complete)
+
+		return &terminalReader{fd: int(inFile.Fd()), editor: editor}
+	}
+
+	return &scannerReader{scanner: bufio.NewScanner(in), out: out}
+}
+
+type scannerReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (s *scannerReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(s.out, prompt)
+
+	if !s.scanner.Scan() {
+		if err := s.scanner.Err(); err != nil {
+			return "", err
+		}
+
+		return "", io.EOF
+	}
+
+	return s.scanner.Text(), nil
+}
+
+// terminalReader switches the terminal into raw mode only while a line is
+// being edited, so evaluation output is printed normally.
+type terminalReader struct {
+	fd     int
+	editor *LineEditor
+}
+
+func (t *terminalReader) ReadLine(prompt string) (string, error) {
+	state, err := term.MakeRaw(t.fd)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer term.Restore(t.fd, state)
+
+	return t.editor.ReadLine(prompt)
+}
+
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyCtrlK     = 11
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyEnter     = '\r'
+	keyNewline   = '\n'
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// LineEditor implements readline-style editing on a raw terminal: cursor
+// movement with the arrow keys and Ctrl-A/E/B/F, Ctrl-K/U to kill text,
+// Up/Down (or Ctrl-P/N) to walk through the history and Tab to complete the
+// word before the cursor.
+type LineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	history  *History
+	complete Completer
+
+	buf    []rune
+	cursor int
+	prompt string
+}
+
+func NewLineEditor(in io.Reader, out io.Writer, history *History) *LineEditor {
+	if history == nil {
+		history = NewHistory()
+	}
+
+	return &LineEditor{in: bufio.NewReader(in), out: out, history: history}
+}
+
+// SetCompleter makes Tab complete with complete. A nil complete turns
+// completion off, and Tab does nothing.
+func (e *LineEditor) SetCompleter(complete Completer) {
+	e.complete = complete
+}
+
+func (e *LineEditor) ReadLine(prompt string) (string, error) {
+	e.buf = e.buf[:0]
+	e.cursor = 0
+	e.prompt = prompt
+
+	// historyIndex == history.Len() is the line being typed; draft keeps it
+	// while the user browses older entries.
+	historyIndex := e.history.Len()
+	draft := ""
+
+	e.refresh()
+
+	for {
+		r, _, err := e.in.ReadRune()
+
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case keyEnter, keyNewline:
+			fmt.Fprint(e.out, "\r\n")
+
+			line := string(e.buf)
+			e.history.Add(line)
+
+			return line, nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+
+			return "", ErrInterrupted
+		case keyCtrlD:
+			if len(e.buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+
+				return "", io.EOF
+			}
+
+			e.deleteForward()
+		case keyBackspace, keyCtrlH:
+			e.deleteBackward()
+		case keyCtrlA:
+			e.cursor = 0
+		case keyCtrlE:
+			e.cursor = len(e.buf)
+		case keyCtrlB:
+			e.moveLeft()
+		case keyCtrlF:
+			e.moveRight()
+		case keyCtrlK:
+			e.buf = e.buf[:e.cursor]
+		case keyCtrlU:
+			e.buf = append([]rune{}, e.buf[e.cursor:]...)
+			e.cursor = 0
+		case keyCtrlP:
+			historyIndex, draft = e.browse(historyIndex-1, historyIndex, draft)
+		case keyCtrlN:
+			historyIndex, draft = e.browse(historyIndex+1, historyIndex, draft)
+		case keyTab:
+			e.completeWord()
+		case keyEscape:
+			switch e.readEscapeSequence() {
+			case "[A":
+				historyIndex, draft = e.browse(historyIndex-1, historyIndex, draft)
+			case "[B":
+				historyIndex, draft = e.browse(historyIndex+1, historyIndex, draft)
+			case "[C":
+				e.moveRight()
+			case "[D":
+				e.moveLeft()
+			case "[H", "[1~", "OH":
+				e.cursor = 0
+			case "[F", "[4~", "OF":
+				e.cursor = len(e.buf)
+			case "[3~":
+				e.deleteForward()
+			}
+		default:
+			if r >= ' ' {
+				e.insert(r)
+			}
+		}
+
+		e.refresh()
+	}
+}
+
+// readEscapeSequence reads the rest of an ANSI escape sequence after ESC,
+// e.g. "[A" for the up arrow or "[3~" for delete.
+func (e *LineEditor) readEscapeSequence() string {
+	first, _, err := e.in.ReadRune()
+
+	if err != nil || (first != '[' && first != 'O') {
+		return ""
+	}
+
+	seq := []rune{first}
+
+	for {
+		r, _, err := e.in.ReadRune()
+
+		if err != nil {
+			return string(seq)
+		}
+
+		seq = append(seq, r)
+
+		// Parameters are digits and ';'; anything else ends the sequence.
+		if (r < '0' || r > '9') && r != ';' {
+			return string(seq)
+		}
+	}
+}
+
+// browse moves to history entry target, saving the in-progress line when
+// leaving it and restoring it when coming back.
+func (e *LineEditor) browse(target, current int, draft string) (int, string) {
+	if target < 0 || target > e.history.Len() {
+		return current, draft
+	}
+
+	if current == e.history.Len() {
+		draft = string(e.buf)
+	}
+
+	if target == e.history.Len() {
+		e.buf = []rune(draft)
+	} else {
+		e.buf = []rune(e.history.At(target))
+	}
+
+	e.cursor = len(e.buf)
+
+	return target, draft
+}
+
+// completeWord extends the word before the cursor as far as every
+// candidate agrees. When that adds nothing and there is a choice, the
+// candidates are listed below the line instead.
+func (e *LineEditor) completeWord() {
+	if e.complete == nil {
+		return
+	}
+
+	word, candidates := e.complete(string(e.buf[:e.cursor]))
+
+	if len(candidates) == 0 {
+		return
+	}
+
+	common := []rune(candidates[0])
+
+	for _, candidate := range candidates[1:] {
+		common = commonPrefix(common, []rune(candidate))
+	}
+
+	start := e.cursor - len([]rune(word))
+
+	if len(common) > e.cursor-start {
+		rest := append([]rune{}, e.buf[e.cursor:]...)
+		e.buf = append(append(e.buf[:start], common...), rest...)
+		e.cursor = start + len(common)
+
+		return
+	}
+
+	if len(candidates) > 1 {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	}
+}
+
+func commonPrefix(a, b []rune) []rune {
+	n := 0
+
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+
+	return a[:n]
+}
+
+func (e *LineEditor) insert(r rune) {
+	e.buf = append(e.buf, 0)
+	copy(e.buf[e.cursor+1:], e.buf[e.cursor:])
+	e.buf[e.cursor] = r
+	e.cursor++
+}
+
+func (e *LineEditor) deleteBackward() {
+	if e.cursor == 0 {
+		return
+	}
+
+	e.buf = append(e.buf[:e.cursor-1], e.buf[e.cursor:]...)
+	e.cursor--
+}
+
+func (e *LineEditor) deleteForward() {
+	if e.cursor == len(e.buf) {
+		return
+	}
+
+	e.buf = append(e.buf[:e.cursor], e.buf[e.cursor+1:]...)
+}
+
+func (e *LineEditor) moveLeft() {
+	if e.cursor > 0 {
+		e.cursor--
+	}
+}
+
+func (e *LineEditor) moveRight() {
+	if e.cursor < len(e.buf) {
+		e.cursor++
+	}
+}
+
+// refresh redraws the whole line and puts the terminal cursor back where the
+// edit cursor is.
+func (e *LineEditor) refresh() {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", e.prompt, string(e.buf))
+
+	if back := len(e.buf) - e.cursor; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
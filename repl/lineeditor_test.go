@@ -0,0 +1,103 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLineEditorEditing(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"let x = 5;\r", "let x = 5;"},
+		{"1 + 3\x7f2\r", "1 + 2"},
+		{"ac\x1b[Db\r", "abc"},
+		{"bc\x01a\x05d\r", "abcd"},
+		{"hello world\x01\x1b[C\x1b[C\x1b[C\x1b[C\x1b[C\x0b\r", "hello"},
+		{"hello world\x1b[D\x1b[D\x1b[D\x1b[D\x1b[D\x15\r", "world"},
+		{"abc\x1b[H\x1b[3~\r", "bc"},
+		{"abc\x02\x02\x04\r", "ac"},
+	}
+
+	for _, tt := range tests {
+		editor := NewLineEditor(strings.NewReader(tt.keys), &bytes.Buffer{}, nil)
+
+		line, err := editor.ReadLine(PROMPT)
+
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", tt.keys, err)
+		}
+
+		if line != tt.expected {
+			t.Errorf("wrong line for %q. expected=%q, got=%q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+func TestLineEditorControlKeys(t *testing.T) {
+	editor := NewLineEditor(strings.NewReader("abc\x03\x04"), &bytes.Buffer{}, nil)
+
+	if _, err := editor.ReadLine(PROMPT); err != ErrInterrupted {
+		t.Errorf("expected ErrInterrupted on Ctrl-C, got=%v", err)
+	}
+
+	if _, err := editor.ReadLine(PROMPT); err != io.EOF {
+		t.Errorf("expected io.EOF on Ctrl-D, got=%v", err)
+	}
+}
+
+func TestLineEditorHistory(t *testing.T) {
+	keys := "first\r" +
+		"second\r" +
+		"\x1b[A\x1b[A\r" + // recall "first"
+		"draft\x1b[A\x1b[B\r" // browse away and back to the draft
+
+	editor := NewLineEditor(strings.NewReader(keys), &bytes.Buffer{}, nil)
+
+	expected := []string{"first", "second", "first", "draft"}
+
+	for _, want := range expected {
+		line, err := editor.ReadLine(PROMPT)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if line != want {
+			t.Errorf("wrong line. expected=%q, got=%q", want, line)
+		}
+	}
+}
+
+func TestHistoryPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), HISTORY_FILE)
+
+	history, err := LoadHistory(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	history.Add("let a = 1;")
+	history.Add("let a = 1;")
+	history.Add("   ")
+	history.Add("a + 1")
+
+	reloaded, err := LoadHistory(path)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if reloaded.Len() != 2 {
+		t.Fatalf("wrong history length. expected=2, got=%d", reloaded.Len())
+	}
+
+	if reloaded.At(0) != "let a = 1;" || reloaded.At(1) != "a + 1" {
+		t.Errorf("wrong history entries. got=%q, %q", reloaded.At(0), reloaded.At(1))
+	}
+}
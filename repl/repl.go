@@ -1,13 +1,16 @@
 package repl
 
 import (
-	"bufio"
 	"fmt"
 	"io"
+	"monkey/ast"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"monkey/profile"
+	"monkey/token"
+	"strings"
 )
 
 const MONKEY_FACE = `            __,__
@@ -24,45 +27,181 @@ const MONKEY_FACE = `            __,__
 `
 
 const PROMPT = ">>"
+const CONTINUATION_PROMPT = ".."
 
-func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+// Start runs the REPL until its input ends, :quit or exit(code), and
+// returns the status the process should exit with.
+func Start(in io.Reader, out io.Writer, opts Options) int {
+	session, err := NewSession(opts)
+
+	if err != nil {
+		fmt.Fprintln(out, err)
+
+		return 1
+	}
+
+	history, err := LoadHistory(DefaultHistoryPath())
+
+	if err != nil {
+		fmt.Fprintf(out, "could not load history: %s\n", err)
+	}
+
+	state := &replState{session: session, opts: opts, out: out, format: newFormatter(opts)}
+	reader := NewLineReader(in, out, history, state.complete)
+	state.debugger = newDebugger(reader, out, state.format)
+	state.io = object.NewIO(&lineInput{reader: reader}, out)
+	session.SetIO(state.io)
+
+	if opts.Debug {
+		evaluator.SetDebugger(state.debugger)
+	}
+
+	defer evaluator.SetDebugger(nil)
+
+	if opts.Trace {
+		evaluator.SetTrace(out)
+
+		defer evaluator.SetTrace(nil)
+	}
+
+	var prof *profile.Profile
+
+	if opts.Profile {
+		prof = profile.New()
+		evaluator.SetProfiler(prof)
+
+		defer evaluator.SetProfiler(nil)
+	}
+
+	if code, ok := state.runStartup(); ok {
+		return code
+	}
+
+	input := ""
 
 	for {
-		fmt.Fprintf(out, PROMPT)
-		scanned := scanner.Scan()
+		prompt := PROMPT
+
+		if opts.Prompt != "" {
+			prompt = opts.Prompt
+		}
 
-		if !scanned {
-			return
+		if input != "" {
+			prompt = CONTINUATION_PROMPT
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
-		program := p.ParseProgram()
+		line, err := reader.ReadLine(state.format.prompt(prompt))
 
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		if err == ErrInterrupted {
+			input = ""
 
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
+		if err != nil {
+			return 0
+		}
+
+		if input == "" && strings.HasPrefix(strings.TrimSpace(line), COMMAND_PREFIX) {
+			if !state.runCommand(line) {
+				return 0
+			}
 
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+			continue
+		}
+
+		input += line + "\n"
+
+		if !IsComplete(input) {
+			continue
 		}
+
+		evaluated, errors := executeInterruptibly(input, state.session, opts.Interrupts, out)
+
+		if code, ok := exitCode(evaluated); ok {
+			return code
+		}
+
+		state.format.printResult(out, evaluated, errors)
+		state.keepResult(input, evaluated, errors)
+
+		if prof != nil && len(errors) == 0 {
+			prof.WriteReport(out)
+			prof.Reset()
+		}
+
+		input = ""
+	}
+}
+
+// keepResult binds the value of an input that ends in an expression to _
+// and to the next of _1, _2 and so on, so later inputs can use it. Inputs
+// ending in a statement, such as let, and those that fail or give null
+// leave them alone.
+func (r *replState) keepResult(input string, evaluated object.Object, errors []string) {
+	if len(errors) != 0 || evaluated == nil || evaluated == object.NULL || isError(evaluated) {
+		return
+	}
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	if len(program.Statements) == 0 {
+		return
+	}
+
+	if _, ok := program.Statements[len(program.Statements)-1].(*ast.ExpressionStatement); !ok {
+		return
+	}
+
+	r.results++
+	r.session.Bind("_", evaluated)
+	r.session.Bind(fmt.Sprintf("_%d", r.results), evaluated)
+}
+
+// lineInput lets readLine() take the next line typed at the REPL instead of
+// competing with the line reader for the input stream.
+type lineInput struct {
+	reader  LineReader
+	pending []byte
+}
+
+func (l *lineInput) Read(p []byte) (int, error) {
+	if len(l.pending) == 0 {
+		line, err := l.reader.ReadLine("")
+
+		if err != nil {
+			return 0, err
+		}
+
+		l.pending = []byte(line + "\n")
 	}
+
+	n := copy(p, l.pending)
+	l.pending = l.pending[n:]
+
+	return n, nil
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	io.WriteString(out, MONKEY_FACE)
-	io.WriteString(out, "Whoops! we ran into some monkey business here!\n")
-	io.WriteString(out, " parser errors:\n")
+// IsComplete reports whether input can be handed to the parser, i.e. every
+// brace and paren opened so far has been closed and no block comment or
+// string is left open. Lexing the input keeps delimiters inside strings and comments
+// from being counted.
+func IsComplete(input string) bool {
+	l := lexer.New(input)
+	depth := 0
 
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		case token.ILLEGAL:
+			if tok.Literal == lexer.UNTERMINATED_COMMENT || tok.Literal == lexer.UNTERMINATED_STRING {
+				return false
+			}
+		}
 	}
+
+	return depth <= 0
 }
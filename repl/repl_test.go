@@ -0,0 +1,855 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsComplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 5;", true},
+		{"let add = fn(x, y) {", false},
+		{"let add = fn(x, y) {\n  x + y\n};", true},
+		{"add(1,", false},
+		{"add(1,\n 2)", true},
+		{`"{ not a brace"`, true},
+		{`let s = "two`, false},
+		{"let s = \"two\nlines\";", true},
+		{"// { comment\n1", true},
+		{"/* still open", false},
+		{"/* closed */ 1", true},
+		{"}", true},
+		{"[1,", false},
+		{"[1,\n 2]", true},
+	}
+
+	for _, tt := range tests {
+		if got := IsComplete(tt.input); got != tt.expected {
+			t.Errorf("IsComplete(%q) wrong. expected=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected int
+		out      string
+		errOut   map[string]string
+	}{
+		{"let x = 2;\nputs(x * 3);\n", 0, "6\n", nil},
+		{"let x = ;\nputs(1);\n", 1, "", map[string]string{
+			ENGINE_EVAL: " errors:\n\tparse error at line 1, col 9: no prefix parse function for ; found\n",
+			ENGINE_VM:   " errors:\n\tparse error at line 1, col 9: no prefix parse function for ; found\n",
+		}},
+		{"puts(1);\nlet x = 1 + true;\nputs(2);\n", 1, "1\n", map[string]string{
+			ENGINE_EVAL: "ERROR at line 2, col 11: type mismatch: INTEGER + BOOLEAN\n",
+			ENGINE_VM:   "ERROR: type mismatch: INTEGER + BOOLEAN\n",
+		}},
+		{"puts(y);\n", 1, "", map[string]string{
+			ENGINE_EVAL: " errors:\n\terror at line 1, col 6: identifier not found: y\n",
+			ENGINE_VM:   " errors:\n\terror at line 1, col 6: identifier not found: y\n",
+		}},
+		{"let f = fn() { return 1; 2 };\nputs(f());\n", 0, "1\n", map[string]string{
+			ENGINE_EVAL: "warning at line 1, col 26: unreachable code\n",
+			ENGINE_VM:   "warning at line 1, col 26: unreachable code\n",
+		}},
+	}
+
+	for _, engine := range []string{ENGINE_EVAL, ENGINE_VM} {
+		for _, tt := range tests {
+			path := filepath.Join(t.TempDir(), "script.mky")
+
+			if err := os.WriteFile(path, []byte(tt.source), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			var out, errOut bytes.Buffer
+
+			code := RunFile(path, Options{Engine: engine, NoBanner: true}, &out, &errOut)
+
+			if code != tt.expected {
+				t.Errorf("%s %q: wrong exit code. expected=%d, got=%d", engine, tt.source, tt.expected, code)
+			}
+
+			if out.String() != tt.out {
+				t.Errorf("%s %q: wrong output. expected=%q, got=%q", engine, tt.source, tt.out, out.String())
+			}
+
+			if errOut.String() != tt.errOut[engine] {
+				t.Errorf("%s %q: wrong error output.\nexpected=%q\ngot=%q", engine, tt.source, tt.errOut[engine], errOut.String())
+			}
+		}
+	}
+}
+
+func TestRunFileImportsRelativeToScript(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"main.mky": "let m1 = import(\"m1.mky\");\nputs(m1[\"v\"]);\n",
+		"m1.mky":   "let v = 1;\n",
+	}
+
+	for name, source := range files {
+		if err := os.WriteFile(filepath.Join(sub, name), []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Chdir(dir)
+
+	var out, errOut bytes.Buffer
+
+	if code := RunFile(filepath.Join("sub", "main.mky"), Options{Engine: ENGINE_EVAL}, &out, &errOut); code != 0 {
+		t.Fatalf("wrong exit code. expected=0, got=%d: %s", code, errOut.String())
+	}
+
+	if out.String() != "1\n" {
+		t.Errorf("wrong output. expected=%q, got=%q", "1\n", out.String())
+	}
+}
+
+func TestRunFileMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.mky")
+
+	var out, errOut bytes.Buffer
+
+	if code := RunFile(path, Options{Engine: ENGINE_EVAL}, &out, &errOut); code != 1 {
+		t.Errorf("wrong exit code. expected=1, got=%d", code)
+	}
+
+	if !strings.HasPrefix(errOut.String(), "could not read "+path+": ") {
+		t.Errorf("wrong error output. got=%q", errOut.String())
+	}
+}
+
+func TestRunFileTraceback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.mky")
+	source := "let f = fn(x) {\n  x + true\n};\nf(1);\n"
+
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errOut bytes.Buffer
+
+	code := RunFile(path, Options{Engine: ENGINE_EVAL}, &out, &errOut)
+
+	if code != 1 {
+		t.Errorf("wrong exit code. expected=1, got=%d", code)
+	}
+
+	expected := "ERROR at line 2, col 5: type mismatch: INTEGER + BOOLEAN\n" +
+		"  in f, called at line 4, col 2\n"
+
+	if errOut.String() != expected {
+		t.Errorf("wrong error output.\nexpected=%q\ngot=%q", expected, errOut.String())
+	}
+}
+
+func TestRunFileExit(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected int
+		errOut   string
+	}{
+		{"puts(1);\nexit(3);\nputs(2);\n", 3, ""},
+		{"exit();\n", 0, ""},
+		{"let f = fn() { panic(\"broken\") };\ntry { f() } catch (e) { 0 };\n", 1, "ERROR at line 1, col 21: panic: broken\n  in f, called at line 2, col 8\n"},
+	}
+
+	for _, engine := range []string{ENGINE_EVAL, ENGINE_VM} {
+		for _, tt := range tests {
+			path := filepath.Join(t.TempDir(), "script.mky")
+
+			if err := os.WriteFile(path, []byte(tt.source), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			var out, errOut bytes.Buffer
+
+			code := RunFile(path, Options{Engine: engine}, &out, &errOut)
+
+			if code != tt.expected {
+				t.Errorf("%s %q: wrong exit code. expected=%d, got=%d", engine, tt.source, tt.expected, code)
+			}
+
+			if engine == ENGINE_EVAL && errOut.String() != tt.errOut {
+				t.Errorf("%s %q: wrong error output.\nexpected=%q\ngot=%q", engine, tt.source, tt.errOut, errOut.String())
+			}
+		}
+	}
+}
+
+func TestExecuteExpandsMacros(t *testing.T) {
+	inputs := []string{
+		"let unless = macro(cond, a, b) { quote(if (!(unquote(cond))) { unquote(a) } else { unquote(b) }) };",
+		"unless(1 > 2, 10, 20)",
+	}
+
+	for _, engine := range []string{ENGINE_EVAL, ENGINE_VM} {
+		session, err := NewSession(Options{Engine: engine})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var result object.Object
+
+		for _, input := range inputs {
+			var errors []string
+
+			result, errors = Execute(input, session)
+
+			if len(errors) != 0 {
+				t.Fatalf("parser errors: %v", errors)
+			}
+		}
+
+		if result == nil || result.Inspect() != "10" {
+			t.Errorf("[%s] wrong result. expected=10, got=%v", engine, result)
+		}
+	}
+}
+
+func TestReplCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lib.mky")
+
+	if err := os.WriteFile(path, []byte("let double = fn(x) { x * 2 };\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	input := strings.Join([]string{
+		"let a = 1;",
+		":load " + path,
+		"double(a)",
+		":env",
+		":type double(a)",
+		`:type "a"`,
+		":type nope",
+		":bogus",
+		":reset",
+		":env",
+		"a",
+		":quit",
+		"99",
+	}, "\n")
+
+	for _, engine := range []string{ENGINE_EVAL, ENGINE_VM} {
+		var out bytes.Buffer
+
+		Start(strings.NewReader(input), &out, Options{Engine: engine})
+
+		expected := []string{
+			"2\n",
+			"a = 1\ndouble = ",
+			"INTEGER\n",
+			"STRING\n",
+			"identifier not found: nope",
+			"unknown command :bogus, try :help\n",
+		}
+
+		for _, want := range expected {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("[%s] output does not contain %q. got=%q", engine, want, out.String())
+			}
+		}
+
+		if strings.Count(out.String(), "a = 1") != 1 {
+			t.Errorf("[%s] :reset did not clear bindings. got=%q", engine, out.String())
+		}
+
+		if strings.Contains(out.String(), ">>99") {
+			t.Errorf("[%s] input after :quit was run. got=%q", engine, out.String())
+		}
+	}
+}
+
+func TestResetForgetsModules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lib.mky")
+
+	write := func(source string) {
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("let v = 1;")
+
+	opts := Options{Engine: ENGINE_EVAL}
+	session, err := NewSession(opts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+
+	state := &replState{session: session, opts: opts, out: &out, format: newFormatter(opts)}
+	input := `import("` + path + `")["v"]`
+
+	for _, tt := range []struct {
+		reset    bool
+		expected string
+	}{
+		{false, "1"},
+		{false, "1"},
+		{true, "2"},
+	} {
+		if tt.reset {
+			state.runCommand(":reset")
+		}
+
+		result, errors := Execute(input, state.session)
+
+		if len(errors) != 0 || result.Inspect() != tt.expected {
+			t.Errorf("wrong result. expected=%s, got=%v, errors=%v", tt.expected, result, errors)
+		}
+
+		write("let v = 2;")
+	}
+}
+
+func TestStartupFile(t *testing.T) {
+	dir := t.TempDir()
+	startup := filepath.Join(dir, STARTUP_FILE)
+	source := "let double = fn(x) { x * 2 };\nlet greeting = \"hi\";\ngreeting\n"
+
+	if err := os.WriteFile(startup, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	broken := filepath.Join(dir, "broken")
+
+	if err := os.WriteFile(broken, []byte("let a = 1;\n-true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	exiting := filepath.Join(dir, "exiting")
+
+	if err := os.WriteFile(exiting, []byte("exit(4)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The VM does not know where its errors happened.
+	errorPrefix := map[string]string{ENGINE_EVAL: "ERROR at line 2, col 1: ", ENGINE_VM: "ERROR: "}
+
+	for _, engine := range []string{ENGINE_EVAL, ENGINE_VM} {
+		tests := []struct {
+			startup  string
+			input    string
+			expected string
+			code     int
+		}{
+			{startup, "double(21)\n:reset\ndouble(1)\n", ">>42\n>>>>2\n>>", 0},
+			{filepath.Join(dir, "missing"), "1\n", ">>1\n>>", 0},
+			{broken, "a\n", "in " + broken + ":\n" + errorPrefix[engine] + "unknown operator: -BOOLEAN\n>>1\n>>", 0},
+			{exiting, "1\n", "", 4},
+		}
+
+		for _, tt := range tests {
+			var out bytes.Buffer
+
+			code := Start(strings.NewReader(tt.input), &out, Options{Engine: engine, Startup: tt.startup})
+
+			if out.String() != tt.expected || code != tt.code {
+				t.Errorf("[%s] %s: wrong output or exit code. expected=%q (%d), got=%q (%d)", engine, tt.startup, tt.expected, tt.code, out.String(), code)
+			}
+		}
+	}
+}
+
+func TestCustomPrompt(t *testing.T) {
+	var out bytes.Buffer
+
+	Start(strings.NewReader("let f = fn() {\n1 }\nf()\n"), &out, Options{Engine: ENGINE_EVAL, Prompt: "monkey> "})
+
+	expected := "monkey> " + CONTINUATION_PROMPT + "monkey> 1\nmonkey> "
+
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	session, err := NewSession(Options{Engine: ENGINE_EVAL, MaxDepth: 50})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evaluated, _ := Execute("let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(40)", session)
+
+	if evaluated == nil || evaluated.Inspect() != "40" {
+		t.Errorf("wrong result within the call depth. got=%+v", evaluated)
+	}
+
+	evaluated, _ = Execute("f(60)", session)
+	errObj, ok := evaluated.(*object.Error)
+
+	if !ok || !strings.HasPrefix(errObj.Message, "maximum call depth exceeded") {
+		t.Errorf("expected the call depth to be exceeded, got=%+v", evaluated)
+	}
+
+	if _, err := NewSession(Options{Engine: ENGINE_VM, MaxDepth: 50}); err == nil {
+		t.Errorf("expected an error for a maximum call depth on the %s engine", ENGINE_VM)
+	}
+}
+
+func TestResultVariables(t *testing.T) {
+	input := strings.Join([]string{
+		"1 + 1",
+		"_ * 10",
+		"_1 + _2",
+		"let x = 5;",
+		"_",
+		"[_3, _4]",
+		`puts("hi")`,
+		"1 + true",
+		"_",
+		":reset",
+		"_1",
+	}, "\n")
+
+	// The VM shows the value a let binds, but neither engine keeps it.
+	letOutput := map[string]string{ENGINE_EVAL: "", ENGINE_VM: "5\n"}
+
+	for _, engine := range []string{ENGINE_EVAL, ENGINE_VM} {
+		var out bytes.Buffer
+
+		Start(strings.NewReader(input), &out, Options{Engine: engine})
+
+		expected := ">>2\n>>20\n>>22\n>>" + letOutput[engine] + ">>22\n>>[22, 22]\n>>hi\nnull\n"
+
+		if !strings.HasPrefix(out.String(), expected) {
+			t.Errorf("[%s] wrong output. expected prefix=%q, got=%q", engine, expected, out.String())
+		}
+
+		if !strings.Contains(out.String(), "BOOLEAN\n>>[22, 22]\n") {
+			t.Errorf("[%s] _ was replaced by null or an error. got=%q", engine, out.String())
+		}
+
+		if !strings.Contains(out.String(), "identifier not found: _1") {
+			t.Errorf("[%s] _1 was kept after :reset. got=%q", engine, out.String())
+		}
+	}
+}
+
+func TestOptimizeOption(t *testing.T) {
+	for _, engine := range []string{ENGINE_EVAL, ENGINE_VM} {
+		session, err := NewSession(Options{Engine: engine, Optimize: true})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, errors := Execute("let f = fn() { if (1 < 2) { return 2 * 5 } 20 }; f()", session)
+
+		if len(errors) != 0 {
+			t.Fatalf("parser errors: %v", errors)
+		}
+
+		if result == nil || result.Inspect() != "10" {
+			t.Errorf("[%s] wrong result. expected=10, got=%v", engine, result)
+		}
+	}
+}
+
+func TestCheckerRefusesToRun(t *testing.T) {
+	for _, engine := range []string{ENGINE_EVAL, ENGINE_VM} {
+		session, err := NewSession(Options{Engine: engine})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, errors := Execute("let x = 1; x + y; return x;", session)
+		expected := []string{
+			"error at line 1, col 16: identifier not found: y",
+			"error at line 1, col 19: return outside of a function",
+		}
+
+		if strings.Join(errors, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("[%s] wrong errors. expected=%q, got=%q", engine, expected, errors)
+		}
+
+		if _, ok := session.Bindings()["x"]; ok {
+			t.Errorf("[%s] program was run despite its errors", engine)
+		}
+
+		result, errors := Execute("let y = 2; y", session)
+
+		if len(errors) != 0 || result == nil || result.Inspect() != "2" {
+			t.Errorf("[%s] wrong result after a refused input. got=%v, errors=%v", engine, result, errors)
+		}
+	}
+}
+
+func TestCheckerSeesEarlierInputs(t *testing.T) {
+	inputs := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1; let [a, b] = [2, 3];", ""},
+		{"let f = fn() { x + a + b };", ""},
+		{"f() + x", "7"},
+		{"let g = fn(n) { f() * n }; g(2)", "12"},
+	}
+
+	for _, engine := range []string{ENGINE_EVAL, ENGINE_VM} {
+		for _, optimize := range []bool{false, true} {
+			session, err := NewSession(Options{Engine: engine, Optimize: optimize})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, tt := range inputs {
+				result, errors := Execute(tt.input, session)
+
+				if len(errors) != 0 {
+					t.Errorf("[%s] %q was refused: %q", engine, tt.input, errors)
+				} else if tt.expected != "" && (result == nil || result.Inspect() != tt.expected) {
+					t.Errorf("[%s] wrong result for %q. expected=%s, got=%v", engine, tt.input, tt.expected, result)
+				}
+			}
+		}
+	}
+}
+
+func TestCheckerWarnings(t *testing.T) {
+	input := "let f = fn() { return 1; puts(2) }; f()"
+
+	for _, engine := range []string{ENGINE_EVAL, ENGINE_VM} {
+		for _, optimize := range []bool{false, true} {
+			session, err := NewSession(Options{Engine: engine, Optimize: optimize})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var warnings bytes.Buffer
+
+			result, errors := executeInterruptibly(input, session, nil, &warnings)
+
+			if len(errors) != 0 || result == nil || result.Inspect() != "1" {
+				t.Errorf("[%s] wrong result. got=%v, errors=%q", engine, result, errors)
+			}
+
+			expected := "warning at line 1, col 26: unreachable code\n"
+
+			if warnings.String() != expected {
+				t.Errorf("[%s] wrong warnings. expected=%q, got=%q", engine, expected, warnings.String())
+			}
+		}
+	}
+}
+
+func TestCheckerUsesEngineBuiltins(t *testing.T) {
+	session, err := NewSession(Options{Engine: ENGINE_EVAL})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result, errors := Execute("map([1], fn(x) { x + 1 })", session); len(errors) != 0 || result.Inspect() != "[2]" {
+		t.Errorf("[%s] wrong result. got=%v, errors=%v", ENGINE_EVAL, result, errors)
+	}
+
+	session, err = NewSession(Options{Engine: ENGINE_VM})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, errors := Execute("map([1], fn(x) { x + 1 })", session)
+	expected := "error at line 1, col 1: identifier not found: map"
+
+	if len(errors) != 1 || errors[0] != expected {
+		t.Errorf("[%s] wrong errors. expected=%q, got=%q", ENGINE_VM, expected, errors)
+	}
+}
+
+func TestInterrupts(t *testing.T) {
+	for _, engine := range []string{ENGINE_EVAL, ENGINE_VM} {
+		session, err := NewSession(Options{Engine: engine})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		interrupts := make(chan os.Signal, 1)
+
+		// A signal sent before the evaluation starts is not meant for it.
+		interrupts <- os.Interrupt
+
+		result, _ := executeInterruptibly("let x = 0; while (x < 100) { x++ }; x", session, interrupts, io.Discard)
+
+		if result == nil || result.Inspect() != "100" {
+			t.Errorf("[%s] a stale interrupt stopped the evaluation. got=%v", engine, result)
+		}
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			interrupts <- os.Interrupt
+		}()
+
+		result, _ = executeInterruptibly("while (true) { }", session, interrupts, io.Discard)
+
+		errObj, ok := result.(*object.Error)
+
+		if !ok || errObj.Message != "evaluation stopped: context canceled" {
+			t.Errorf("[%s] wrong result of an interrupted evaluation. got=%v", engine, result)
+		}
+
+		result, _ = executeInterruptibly("x + 1", session, interrupts, io.Discard)
+
+		if result == nil || result.Inspect() != "101" {
+			t.Errorf("[%s] session unusable after an interrupt. got=%v", engine, result)
+		}
+	}
+}
+
+func TestReplInputOutput(t *testing.T) {
+	input := strings.Join([]string{
+		"let name = readLine();",
+		"Monkey",
+		`puts("hi " + name); printf("%d!", len(name))`,
+	}, "\n")
+
+	for _, engine := range []string{ENGINE_EVAL, ENGINE_VM} {
+		var out bytes.Buffer
+
+		Start(strings.NewReader(input), &out, Options{Engine: engine})
+
+		if !strings.Contains(out.String(), "hi Monkey\n6!") {
+			t.Errorf("[%s] output does not contain the script's output. got=%q", engine, out.String())
+		}
+	}
+}
+
+func TestRunFileOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.mky")
+
+	if err := os.WriteFile(path, []byte(`print("a"); puts("b")`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errOut bytes.Buffer
+
+	if code := RunFile(path, Options{Engine: ENGINE_VM}, &out, &errOut); code != 0 {
+		t.Fatalf("wrong exit code. expected=0, got=%d: %s", code, errOut.String())
+	}
+
+	if out.String() != "ab\n" {
+		t.Errorf("wrong output. expected=%q, got=%q", "ab\n", out.String())
+	}
+}
+
+func TestRunFileProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.mky")
+	source := "let double = fn(x) { x * 2 };\ndouble(1); double(2);\n"
+
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errOut bytes.Buffer
+
+	if code := RunFile(path, Options{Engine: ENGINE_EVAL, Profile: true}, &out, &errOut); code != 0 {
+		t.Fatalf("wrong exit code. expected=0, got=%d: %s", code, errOut.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(errOut.String()), "\n")
+
+	if len(lines) != 2 || !strings.HasPrefix(strings.TrimSpace(lines[0]), "calls") || !strings.HasSuffix(lines[1], "  double") || strings.Fields(lines[1])[0] != "2" {
+		t.Errorf("wrong profile. got=%q", errOut.String())
+	}
+
+	if _, err := NewSession(Options{Engine: ENGINE_VM, Profile: true}); err == nil {
+		t.Errorf("expected an error for the vm engine")
+	}
+}
+
+func TestRunFileCoverage(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "script.mky")
+	profile := filepath.Join(dir, "script.lcov")
+	source := "let x = 1;\nif (x > 1) {\n  puts(x);\n}\n"
+
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errOut bytes.Buffer
+
+	if code := RunFile(path, Options{Engine: ENGINE_EVAL, Cover: true, CoverProfile: profile}, &out, &errOut); code != 0 {
+		t.Fatalf("wrong exit code. expected=0, got=%d: %s", code, errOut.String())
+	}
+
+	listing := path + ": 2 of 3 lines covered (66.7%)\n" +
+		"     1: let x = 1;\n" +
+		"     1: if (x > 1) {\n" +
+		" #####:   puts(x);\n" +
+		"     -: }\n"
+
+	if errOut.String() != listing {
+		t.Errorf("wrong listing.\nexpected=%q\ngot=%q", listing, errOut.String())
+	}
+
+	tracefile, err := os.ReadFile(profile)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "SF:" + path + "\nDA:1,1\nDA:2,1\nDA:3,0\nLH:2\nLF:3\nend_of_record\n"
+
+	if string(tracefile) != expected {
+		t.Errorf("wrong tracefile.\nexpected=%q\ngot=%q", expected, string(tracefile))
+	}
+
+	if _, err := NewSession(Options{Engine: ENGINE_VM, Cover: true}); err == nil {
+		t.Errorf("expected an error for the vm engine")
+	}
+}
+
+func TestRunBytecode(t *testing.T) {
+	bytecode, errors := Compile(`let f = fn(x) { x * 2 }; puts(f(21)); puts(1 / 0)`, true)
+
+	if len(errors) != 0 {
+		t.Fatalf("compile errors: %v", errors)
+	}
+
+	encoded, err := bytecode.Encode()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "script.mkyc")
+
+	if err := os.WriteFile(path, encoded, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errOut bytes.Buffer
+
+	if code := RunBytecode(path, Options{}, &out, &errOut); code != 1 {
+		t.Errorf("wrong exit code. expected=1, got=%d", code)
+	}
+
+	if out.String() != "42\n" || errOut.String() != "ERROR: division by zero: 1 / 0\n" {
+		t.Errorf("wrong output. got out=%q, errOut=%q", out.String(), errOut.String())
+	}
+
+	malformed := &compiler.Bytecode{Instructions: append(code.Make(code.OpTrue), code.Make(code.OpIterNext, 4)...)}
+	encoded, err = malformed.Encode()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, encoded, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	errOut.Reset()
+
+	if code := RunBytecode(path, Options{}, &out, &errOut); code != 1 || !strings.Contains(errOut.String(), "malformed bytecode") {
+		t.Errorf("expected a malformed bytecode error, got code=%d, errOut=%q", code, errOut.String())
+	}
+
+	if _, errors := Compile("let x = ;", false); len(errors) == 0 {
+		t.Errorf("expected parse errors")
+	}
+
+	if _, errors := Compile("puts(y)", false); len(errors) == 0 {
+		t.Errorf("expected checker errors")
+	}
+}
+
+func TestDebugger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.mky")
+	script := "let a = 1;\nlet b = a + 1;\nlet c = b + 1;\n"
+
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	input := strings.Join([]string{
+		":debug",
+		"let f = fn(n) { let m = n * 2; breakpoint(); m + 1 };",
+		"f(4)",
+		"p m",
+		"env",
+		"s",
+		"c",
+		":break 2",
+		":load " + path,
+		"p a",
+		"s",
+		"c",
+		":debug",
+		"f(1)",
+	}, "\n")
+
+	var out bytes.Buffer
+
+	Start(strings.NewReader(input), &out, Options{Engine: ENGINE_EVAL})
+
+	expected := []string{
+		"debugger on\n",
+		"paused at line 1, col 32: breakpoint()\n",
+		"debug>>8\n",
+		"m = 8\nn = 4\n",
+		"paused at line 1, col 46: (m + 1)\n",
+		"debug>>9\n",
+		"paused at line 2, col 1: let b = (a + 1);\n",
+		"debug>>1\n",
+		"paused at line 3, col 1: let c = (b + 1);\n",
+		"debugger off\n",
+		">>3\n",
+	}
+
+	for _, want := range expected {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output does not contain %q. got=%q", want, out.String())
+		}
+	}
+
+	if strings.Count(out.String(), "paused at") != 4 {
+		t.Errorf("wrong number of pauses. got=%q", out.String())
+	}
+}
+
+func TestDebuggerNeedsEvaluator(t *testing.T) {
+	if _, err := NewSession(Options{Engine: ENGINE_VM, Debug: true}); err == nil {
+		t.Errorf("expected an error for the vm engine")
+	}
+
+	var out bytes.Buffer
+
+	Start(strings.NewReader(":debug"), &out, Options{Engine: ENGINE_VM})
+
+	if !strings.Contains(out.String(), "the debugger needs the eval engine") {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
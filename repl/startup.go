@@ -0,0 +1,55 @@
+package repl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const STARTUP_FILE = ".monkeyrc"
+
+// DefaultStartupPath returns ~/.monkeyrc, or "" if there is no home
+// directory to look in.
+func DefaultStartupPath() string {
+	home, err := os.UserHomeDir()
+
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, STARTUP_FILE)
+}
+
+// runStartup runs the startup file in the REPL's session, if there is one,
+// showing its errors but not its value. It reports the status to exit with
+// if the file called exit(code).
+func (r *replState) runStartup() (int, bool) {
+	if r.opts.Startup == "" {
+		return 0, false
+	}
+
+	source, err := os.ReadFile(r.opts.Startup)
+
+	if os.IsNotExist(err) {
+		return 0, false
+	}
+
+	if err != nil {
+		fmt.Fprintf(r.out, "could not read %s: %s\n", r.opts.Startup, err)
+
+		return 0, false
+	}
+
+	evaluated, errors := executeInterruptibly(string(source), r.session, r.opts.Interrupts, r.out)
+
+	if code, ok := exitCode(evaluated); ok {
+		return code, true
+	}
+
+	if len(errors) != 0 || isError(evaluated) {
+		fmt.Fprintf(r.out, "in %s:\n", r.opts.Startup)
+		r.format.printResult(r.out, evaluated, errors)
+	}
+
+	return 0, false
+}
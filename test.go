@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"monkey/coverage"
+	"monkey/evaluator"
+	"monkey/tester"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runTest implements `monkey test [-v] [-cover] [-coverprofile file]
+// [path...]`, running the tests in the _test.mky files under each path, or
+// under the working directory if none is given. The result is meant to be
+// used as the process exit code.
+func runTest(args []string, out io.Writer, errOut io.Writer) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	verbose := flags.Bool("v", false, "list every test, not only the ones that fail")
+	cover := flags.Bool("cover", false, "report how much of each file the tests ran, leaving out the test files")
+	coverProfile := flags.String("coverprofile", "", "write which lines the tests ran to this file, as an LCOV tracefile")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	paths := flags.Args()
+
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	var cov *coverage.Coverage
+
+	if *cover || *coverProfile != "" {
+		cov = coverage.New()
+		evaluator.SetCoverage(cov, "")
+
+		defer evaluator.SetCoverage(nil, "")
+	}
+
+	passed := tester.Run(paths, *verbose, out)
+
+	if cov != nil && !writeTestCoverage(cov, *cover, *coverProfile, out, errOut) {
+		return 1
+	}
+
+	if !passed {
+		return 1
+	}
+
+	return 0
+}
+
+// writeTestCoverage reports the coverage of the files the tests ran, other
+// than the test files, as runTest's flags ask.
+func writeTestCoverage(cov *coverage.Coverage, summary bool, profile string, out io.Writer, errOut io.Writer) bool {
+	for _, file := range cov.Files() {
+		if strings.HasSuffix(file, tester.SUFFIX) {
+			cov.Forget(file)
+		}
+	}
+
+	if summary {
+		for _, file := range cov.Files() {
+			covered, total := cov.Summary(file)
+
+			fmt.Fprintf(out, "coverage: %.1f%% of lines in %s (%d of %d)\n", coverage.Percent(covered, total), relative(file), covered, total)
+		}
+	}
+
+	if profile != "" {
+		if err := cov.WriteLCOVFile(profile); err != nil {
+			fmt.Fprintf(errOut, "could not write %s: %s\n", profile, err)
+
+			return false
+		}
+	}
+
+	return true
+}
+
+// relative is path relative to the working directory, if it is below it.
+func relative(path string) string {
+	wd, err := os.Getwd()
+
+	if err != nil {
+		return path
+	}
+
+	rel, err := filepath.Rel(wd, path)
+
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+
+	return rel
+}
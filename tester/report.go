@@ -0,0 +1,92 @@
+package tester
+
+import (
+	"fmt"
+	"io"
+	"monkey/object"
+	"strings"
+)
+
+// Run finds the test files among paths, runs them and writes a report to
+// out: a line for each failed or broken test, or for every test when
+// verbose is set, and a summary. It reports whether everything passed.
+func Run(paths []string, verbose bool, out io.Writer) bool {
+	files, err := Find(paths)
+
+	if err != nil {
+		fmt.Fprintf(out, "%s\n", err)
+
+		return false
+	}
+
+	if len(files) == 0 {
+		fmt.Fprintln(out, "no test files")
+
+		return true
+	}
+
+	passed, failed, broken := 0, 0, 0
+
+	for _, file := range files {
+		results, err := RunFile(file)
+
+		if err != nil {
+			fmt.Fprintf(out, "--- ERROR: %s\n%s\n", file, indent(err.Error()))
+			broken++
+
+			continue
+		}
+
+		for _, result := range results {
+			switch {
+			case result.Failed():
+				failed++
+				fmt.Fprintf(out, "--- FAIL: %s (%s)\n%s\n", result.Name, location(file, result.Err), indent(details(result.Err)))
+			case result.Broken():
+				broken++
+				fmt.Fprintf(out, "--- ERROR: %s (%s)\n%s\n", result.Name, location(file, result.Err), indent(details(result.Err)))
+			default:
+				passed++
+
+				if verbose {
+					fmt.Fprintf(out, "--- PASS: %s (%s)\n", result.Name, file)
+				}
+			}
+		}
+	}
+
+	if failed == 0 && broken == 0 {
+		fmt.Fprintf(out, "PASS: %d passed\n", passed)
+
+		return true
+	}
+
+	fmt.Fprintf(out, "FAIL: %d passed, %d failed, %d broken\n", passed, failed, broken)
+
+	return false
+}
+
+// location is file:line:col of where err was raised, or just file if its
+// position is unknown.
+func location(file string, err *object.Error) string {
+	if err.Line == 0 {
+		return file
+	}
+
+	return fmt.Sprintf("%s:%d:%d", file, err.Line, err.Column)
+}
+
+// details is the message of err followed by the calls it unwound through.
+func details(err *object.Error) string {
+	lines := []string{err.Message}
+
+	for _, frame := range err.Stack {
+		lines = append(lines, fmt.Sprintf("in %s, called at line %d, col %d", frame.Function, frame.Line, frame.Column))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func indent(text string) string {
+	return "    " + strings.ReplaceAll(text, "\n", "\n    ")
+}
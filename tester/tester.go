@@ -0,0 +1,159 @@
+// Package tester runs Monkey tests. A test file's name ends in _test.mky,
+// and every function it binds at top level to a name starting with "test"
+// and taking no parameters is a test. Tests run in source order, each
+// after the whole file has been evaluated, and fail when an assert or
+// assertEqual inside them does.
+package tester
+
+import (
+	"fmt"
+	"io/fs"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const SUFFIX = "_test.mky"
+
+// Result is the outcome of one test.
+type Result struct {
+	Name string
+	Err  *object.Error // nil if the test passed
+}
+
+// Failed reports whether an assertion in the test failed. A test with an
+// error that is not a failed assertion is broken rather than failed.
+func (r Result) Failed() bool {
+	return r.Err != nil && r.Err.Failure != nil
+}
+
+// Broken reports whether the test stopped on an error other than a failed
+// assertion.
+func (r Result) Broken() bool {
+	return r.Err != nil && r.Err.Failure == nil
+}
+
+// Find returns the test files among paths. Directories are searched
+// recursively, skipping any testdata directory below them as go test does;
+// files are taken as they are, whatever their name.
+func Find(paths []string) ([]string, error) {
+	files := []string{}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if entry.IsDir() && entry.Name() == "testdata" && file != path {
+				return filepath.SkipDir
+			}
+
+			if !entry.IsDir() && strings.HasSuffix(file, SUFFIX) {
+				files = append(files, file)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// RunFile evaluates the test file at path and runs its tests. It returns an
+// error, and no results, if the file cannot be read or parsed or fails
+// before its tests get to run.
+func RunFile(path string) ([]Result, error) {
+	source, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("%s", strings.Join(p.Errors(), "\n"))
+	}
+
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	node, err := evaluator.ExpandMacros(program, macroEnv)
+
+	if err != nil {
+		return nil, err
+	}
+
+	expanded := node.(*ast.Program)
+
+	env := object.NewEnvironment()
+
+	if errObj, ok := evaluator.InFile(path, func() object.Object {
+		return evaluator.Eval(expanded, env)
+	}).(*object.Error); ok {
+		return nil, fmt.Errorf("%s", errObj.Traceback())
+	}
+
+	results := []Result{}
+
+	for _, name := range testNames(expanded) {
+		fn, ok := env.Get(name)
+
+		if f, isFunction := fn.(*object.Function); !ok || !isFunction || len(f.Parameters) != 0 {
+			continue
+		}
+
+		result := evaluator.InFile(path, func() object.Object {
+			return evaluator.Apply(fn)
+		})
+
+		errObj, _ := result.(*object.Error)
+		results = append(results, Result{Name: name, Err: errObj})
+	}
+
+	return results, nil
+}
+
+// testNames lists, in source order and without repeats, the names bound to
+// function literals by top-level lets that start with "test".
+func testNames(program *ast.Program) []string {
+	names := []string{}
+	seen := make(map[string]bool)
+
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+
+		if !ok || !strings.HasPrefix(let.Name.Value, "test") || seen[let.Name.Value] {
+			continue
+		}
+
+		if _, ok := let.Value.(*ast.FunctionLiteral); ok {
+			names = append(names, let.Name.Value)
+			seen[let.Name.Value] = true
+		}
+	}
+
+	return names
+}
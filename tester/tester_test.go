@@ -0,0 +1,91 @@
+package tester
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunFile(t *testing.T) {
+	results, err := RunFile(filepath.Join("testdata", "math_test.mky"))
+
+	if err != nil {
+		t.Fatalf("RunFile failed: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		failed  bool
+		broken  bool
+		message string
+	}{
+		{"testAdd", false, false, ""},
+		{"testBroken", false, true, "identifier not found: missing"},
+		{"testFails", true, false, "assertEqual failed: expected 5, got 4"},
+		{"testCaught", true, false, "assertion failed: not swallowed"},
+		{"testPasses", false, false, ""},
+	}
+
+	if len(results) != len(tests) {
+		t.Fatalf("wrong number of results. want=%d, got=%+v", len(tests), results)
+	}
+
+	for i, tt := range tests {
+		result := results[i]
+
+		if result.Name != tt.name || result.Failed() != tt.failed || result.Broken() != tt.broken {
+			t.Errorf("results[%d] wrong. want=%+v, got=%+v", i, tt, result)
+
+			continue
+		}
+
+		if result.Err != nil && result.Err.Message != tt.message {
+			t.Errorf("%s: wrong message. want=%q, got=%q", tt.name, tt.message, result.Err.Message)
+		}
+	}
+
+	failure := results[2].Err.Failure
+
+	if failure.Expected.Inspect() != "5" || failure.Actual.Inspect() != "4" {
+		t.Errorf("wrong failure. got=%+v", failure)
+	}
+}
+
+func TestRun(t *testing.T) {
+	var out bytes.Buffer
+
+	if Run([]string{"testdata"}, false, &out) {
+		t.Errorf("Run passed with failing tests")
+	}
+
+	file := filepath.Join("testdata", "math_test.mky")
+
+	expected := "--- ERROR: testBroken (" + file + ":10:25)\n" +
+		"    identifier not found: missing\n" +
+		"--- FAIL: testFails (" + file + ":13:33)\n" +
+		"    assertEqual failed: expected 5, got 4\n" +
+		"    in check, called at line 14, col 7\n" +
+		"--- FAIL: testCaught (" + file + ":18:14)\n" +
+		"    assertion failed: not swallowed\n" +
+		"FAIL: 2 passed, 2 failed, 1 broken\n"
+
+	if out.String() != expected {
+		t.Errorf("wrong report.\nwant=%q\ngot= %q", expected, out.String())
+	}
+}
+
+func TestRunWithoutTests(t *testing.T) {
+	var out bytes.Buffer
+
+	if !Run([]string{t.TempDir()}, true, &out) || out.String() != "no test files\n" {
+		t.Errorf("wrong report. got=%q", out.String())
+	}
+}
+
+func TestFindSkipsTestdata(t *testing.T) {
+	files, err := Find([]string{"."})
+
+	if err != nil || len(files) != 0 {
+		t.Errorf("testdata should be skipped. got=%v, err=%v", files, err)
+	}
+}
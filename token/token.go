@@ -1,21 +1,36 @@
 package token
 
+import "sort"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int // 1-based line the token starts on
+	Column  int // 1-based column of the token's first character
+	Offset  int // byte offset of the token's first character
+	End     int // byte offset just past the token's last character
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT" // only produced for syntax highlighting
 
 	// Identifiers + literals
 	IDENT  = "IDENT"
 	INT    = "INT"
+	FLOAT  = "FLOAT"
 	STRING = "STRING"
 
+	// An interpolated string such as "a${x}b${y}c" lexes as INTERP_START
+	// "a", the tokens of x, INTERP_MID "b", the tokens of y and INTERP_END
+	// "c". Their literals are the decoded text between the interpolations.
+	INTERP_START = "INTERP_START"
+	INTERP_MID   = "INTERP_MID"
+	INTERP_END   = "INTERP_END"
+
 	// Operators
 	ASSIGN   = "="
 	PLUS     = "+"
@@ -23,38 +38,103 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+	POWER    = "**"
 	LT       = "<"
 	GT       = ">"
+	LT_EQ    = "<="
+	GT_EQ    = ">="
+	AND      = "&&"
+	OR       = "||"
+
+	BIT_AND     = "&"
+	BIT_OR      = "|"
+	BIT_XOR     = "^"
+	BIT_NOT     = "~"
+	SHIFT_LEFT  = "<<"
+	SHIFT_RIGHT = ">>"
+
+	COALESCE = "??"
+	PIPE     = "|>"
+	QUESTION = "?"
+
+	// left?.name and left?[index] are null, not an error, when left is null
+	OPTIONAL_DOT      = "?."
+	OPTIONAL_LBRACKET = "?["
+
+	INCREMENT = "++"
+	DECREMENT = "--"
+
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
 
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	ELLIPSIS  = "..."
+	DOT       = "."
 
 	LPAREN = "("
 	RPAREN = ")"
 	LBRACE = "{"
 	RBRACE = "}"
 
+	LBRACKET = "["
+	RBRACKET = "]"
+
 	// Keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
-	EQ       = "=="
-	NOT_EQ   = "!="
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	MACRO    = "MACRO"
+	SWITCH   = "SWITCH"
+	CASE     = "CASE"
+	DEFAULT  = "DEFAULT"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+
+	// DO and LOOP are not reserved: the lexer makes do and loop IDENTs,
+	// and the parser gives them these types where they start a loop.
+	DO   = "DO"
+	LOOP = "LOOP"
+
+	EQ     = "=="
+	NOT_EQ = "!="
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"const":    CONST,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"macro":    MACRO,
+	"switch":   SWITCH,
+	"case":     CASE,
+	"default":  DEFAULT,
+	"try":      TRY,
+	"catch":    CATCH,
 }
 
 func LookupIdent(ident string) TokenType {
@@ -64,3 +144,56 @@ func LookupIdent(ident string) TokenType {
 
 	return IDENT
 }
+
+// Keywords returns the keywords, sorted.
+func Keywords() []string {
+	names := make([]string, 0, len(keywords))
+
+	for name := range keywords {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Category groups token types the way a syntax highlighter colours them.
+type Category string
+
+const (
+	CATEGORY_KEYWORD    Category = "keyword"
+	CATEGORY_IDENTIFIER Category = "identifier"
+	CATEGORY_LITERAL    Category = "literal"
+	CATEGORY_OPERATOR   Category = "operator"
+	CATEGORY_DELIMITER  Category = "delimiter"
+	CATEGORY_COMMENT    Category = "comment"
+	CATEGORY_ILLEGAL    Category = "illegal"
+)
+
+// CategoryOf classifies t. true and false are literals, not keywords, and
+// EOF has no category.
+func CategoryOf(t TokenType) Category {
+	switch t {
+	case IDENT:
+		return CATEGORY_IDENTIFIER
+	case INT, FLOAT, STRING, INTERP_START, INTERP_MID, INTERP_END, TRUE, FALSE:
+		return CATEGORY_LITERAL
+	case COMMA, SEMICOLON, COLON, ELLIPSIS, DOT, OPTIONAL_DOT, LPAREN, RPAREN, LBRACE, RBRACE, LBRACKET, OPTIONAL_LBRACKET, RBRACKET:
+		return CATEGORY_DELIMITER
+	case COMMENT:
+		return CATEGORY_COMMENT
+	case ILLEGAL:
+		return CATEGORY_ILLEGAL
+	case EOF:
+		return ""
+	}
+
+	for _, keyword := range keywords {
+		if keyword == t {
+			return CATEGORY_KEYWORD
+		}
+	}
+
+	return CATEGORY_OPERATOR
+}
@@ -0,0 +1,1523 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/object"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const StackSize = 2048
+const GlobalsSize = 65536
+const MaxFrames = 1024
+
+var True = object.TRUE
+var False = object.FALSE
+var Null = object.NULL
+
+var trueValue = object.ValueOf(True)
+var falseValue = object.ValueOf(False)
+var nullValue = object.ValueOf(Null)
+
+// missingValue fills the slot of a parameter a call left out, until the
+// function's OpDefault for it stores the default value there. It is never
+// seen by Monkey code.
+var missingValue = object.ValueOf(&missing{})
+
+type missing struct{}
+
+func (m *missing) Type() object.ObjectType { return "MISSING" }
+func (m *missing) Inspect() string         { return "missing" }
+
+// VM keeps its stack and globals as object.Values, so integer and float
+// arithmetic does not allocate. Values are boxed into Objects only where
+// they leave the VM: into arrays, hashes, closures and builtin arguments.
+type VM struct {
+	constants []object.Value
+
+	stack []object.Value
+	sp    int // Always points to the next free slot. Top of stack is stack[sp-1]
+
+	globals []object.Value // grown as globals are set, up to GlobalsSize
+
+	frames      []*Frame
+	framesIndex int
+
+	handlers []handler // active try blocks, innermost last
+
+	args []object.Object // reused for the arguments of each builtin call
+
+	ctx   context.Context // set by RunContext; nil means Run was called
+	ticks int             // jumps and calls since the last check of ctx
+
+	io *object.IO // set by SetIO; nil means the process's
+}
+
+// contextCheckInterval is how many jumps and calls pass between checks of
+// the context, which cost more than counting does.
+const contextCheckInterval = 1024
+
+// handler records where an error inside a try block resumes: the catch
+// block's address in the frame that was current at OpTry, and the stack
+// and frame depth to cut back to.
+type handler struct {
+	catch       int
+	sp          int
+	framesIndex int
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	constants := make([]object.Value, len(bytecode.Constants))
+
+	for i, constant := range bytecode.Constants {
+		constants[i] = object.ValueOf(constant)
+	}
+
+	return &VM{
+		constants: constants,
+
+		stack: make([]object.Value, StackSize),
+		sp:    0,
+
+		globals: []object.Value{},
+
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+// NewWithGlobalsStore creates a VM that reads and writes an existing globals
+// slice, so global bindings survive between REPL inputs.
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Value) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+
+	return vm
+}
+
+// SetIO makes the input and output builtins the program calls read and
+// write through io instead of the process's.
+func (vm *VM) SetIO(io *object.IO) {
+	vm.io = io
+}
+
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.stack[vm.sp].Object()
+}
+
+// Run executes the bytecode. An error raised inside a try block resumes
+// execution at its catch block, unless it is a failed assertion or the run
+// was stopped; any other error stops the VM and is returned.
+func (vm *VM) Run() error {
+	for {
+		err := vm.run()
+
+		if err == nil || !catchable(err) || len(vm.handlers) == 0 {
+			return err
+		}
+
+		h := vm.handlers[len(vm.handlers)-1]
+		vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		vm.framesIndex = h.framesIndex
+		vm.sp = h.sp
+		vm.currentFrame().ip = h.catch - 1
+
+		err = vm.push(object.ValueOf(&object.String{Value: err.Error()}))
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// RunContext is Run stopped early, with an error try cannot catch, once ctx
+// is done. ctx is checked at jumps and calls, so every loop and recursion
+// sees it.
+func (vm *VM) RunContext(ctx context.Context) error {
+	vm.ctx = ctx
+
+	defer func() { vm.ctx = nil }()
+
+	return vm.Run()
+}
+
+// tick counts a jump or call, checking the context of RunContext every
+// contextCheckInterval of them.
+func (vm *VM) tick() error {
+	if vm.ctx == nil {
+		return nil
+	}
+
+	vm.ticks++
+
+	if vm.ticks < contextCheckInterval {
+		return nil
+	}
+
+	vm.ticks = 0
+
+	if err := vm.ctx.Err(); err != nil {
+		return stoppedError{err}
+	}
+
+	return nil
+}
+
+func (vm *VM) run() error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err := vm.push(vm.constants[constIndex])
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpDup:
+			err := vm.push(vm.stack[vm.sp-1])
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight:
+			err := vm.executeBinaryOperation(op)
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpTrue:
+			err := vm.push(trueValue)
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpFalse:
+			err := vm.push(falseValue)
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpNull:
+			err := vm.push(nullValue)
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpGreaterThanOrEqual:
+			err := vm.executeComparison(op)
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpBang:
+			err := vm.executeBangOperator()
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpMinus:
+			err := vm.executeMinusOperator()
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpBitNot:
+			err := vm.executeBitNotOperator()
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+
+			if err := vm.tick(); err != nil {
+				return err
+			}
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+
+			if !isTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpJumpNotNull:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if vm.stack[vm.sp-1] != nullValue {
+				vm.currentFrame().ip = pos - 1
+			} else {
+				vm.pop()
+			}
+
+		case code.OpDefault:
+			slot := code.ReadUint8(ins[ip+1:])
+			pos := int(code.ReadUint16(ins[ip+2:]))
+			vm.currentFrame().ip += 3
+
+			if vm.stack[vm.currentFrame().basePointer+int(slot)] != missingValue {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpTry:
+			catch := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			vm.handlers = append(vm.handlers, handler{catch: catch, sp: vm.sp, framesIndex: vm.framesIndex})
+
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if int(globalIndex) >= len(vm.globals) {
+				vm.growGlobals(int(globalIndex) + 1)
+			}
+
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			var global object.Value
+
+			if int(globalIndex) < len(vm.globals) {
+				global = vm.globals[globalIndex]
+			}
+
+			err := vm.push(global)
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+
+			err := vm.push(vm.stack[frame.basePointer+int(localIndex)])
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			definition := object.Builtins[builtinIndex]
+
+			err := vm.push(object.ValueOf(vm.io.Bind(definition.Builtin)))
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+
+			err := vm.push(object.ValueOf(currentClosure.Free[freeIndex]))
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			value := vm.pop()
+			closure := vm.pop().Object().(*object.Closure)
+
+			closure.Free[freeIndex] = value.Object()
+
+		case code.OpCell:
+			cell := &object.Cell{Value: vm.pop()}
+
+			err := vm.push(object.ValueOf(cell))
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpGetCell:
+			cell := vm.pop().Object().(*object.Cell)
+
+			err := vm.push(cell.Value)
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpSetCell:
+			cell := vm.pop().Object().(*object.Cell)
+			cell.Value = vm.pop()
+
+		case code.OpCurrentClosure:
+			currentClosure := vm.currentFrame().cl
+
+			err := vm.push(object.ValueOf(currentClosure))
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			err := vm.pushClosure(int(constIndex), int(numFree))
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+
+			err := vm.push(object.ValueOf(array))
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpConcat:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			var out strings.Builder
+
+			for _, part := range vm.stack[vm.sp-numParts : vm.sp] {
+				out.WriteString(object.Display(part.Object()))
+			}
+
+			vm.sp = vm.sp - numParts
+
+			err := vm.push(object.ValueOf(&object.String{Value: out.String()}))
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpIter:
+			collection := vm.pop().Object()
+
+			iterable, ok := collection.(object.Iterable)
+
+			if !ok {
+				return fmt.Errorf("not iterable: %s", collection.Type())
+			}
+
+			err := vm.push(object.ValueOf(iterable.Iterate()))
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			value, ok := vm.pop().Object().(*object.Iterator).Next()
+
+			if !ok {
+				vm.currentFrame().ip = pos - 1
+
+				break
+			}
+
+			err := vm.push(object.ValueOf(value))
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
+
+			if err != nil {
+				return err
+			}
+
+			vm.sp = vm.sp - numElements
+
+			err = vm.push(object.ValueOf(hash))
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			err := vm.executeIndexExpression(left.Object(), index.Object())
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpProperty:
+			name := vm.constants[code.ReadUint16(ins[ip+1:])].Object().(*object.String)
+			vm.currentFrame().ip += 2
+
+			receiver := vm.pop().Object()
+			value, ok := object.Property(receiver, name.Value, object.GetBuiltinByName)
+
+			if !ok && receiver.Type() != object.HASH_OBJ {
+				return fmt.Errorf("no method %s on %s", name.Value, receiver.Type())
+			}
+
+			result := nullValue
+
+			if ok {
+				result = object.ValueOf(value)
+			}
+
+			err := vm.push(result)
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpSlice:
+			high := vm.pop()
+			low := vm.pop()
+			left := vm.pop()
+
+			err := vm.executeSlice(left.Object(), low.Object(), high.Object())
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			err := vm.executeSetIndex(left.Object(), index.Object(), value)
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err := vm.executeCall(int(numArgs))
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpCallSpread:
+			numPieces := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err := vm.executeSpreadCall(int(numPieces))
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpCallNamed:
+			numPositional := code.ReadUint8(ins[ip+1:])
+			namesIndex := code.ReadUint16(ins[ip+2:])
+			vm.currentFrame().ip += 3
+
+			names := vm.constants[namesIndex].Object().(*object.Array)
+
+			err := vm.executeNamedCall(int(numPositional), names.Elements)
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			// A top-level return stops the program, leaving its value as
+			// the last popped element.
+			if vm.framesIndex == 1 {
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			err := vm.push(returnValue)
+
+			if err != nil {
+				return err
+			}
+
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			err := vm.push(nullValue)
+
+			if err != nil {
+				return err
+			}
+
+		default:
+			def, err := code.Lookup(byte(op))
+
+			if err != nil {
+				return err
+			}
+
+			return fmt.Errorf("opcode %s not implemented", def.Name)
+		}
+	}
+
+	return nil
+}
+
+func (vm *VM) push(v object.Value) error {
+	if vm.sp >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+
+	vm.stack[vm.sp] = v
+	vm.sp++
+
+	return nil
+}
+
+func (vm *VM) pop() object.Value {
+	v := vm.stack[vm.sp-1]
+	vm.sp--
+
+	return v
+}
+
+// growGlobals makes room for at least n globals. It is only needed by a VM
+// that was not given a store, whose programs rarely use more than a few.
+func (vm *VM) growGlobals(n int) {
+	size := max(n, 2*len(vm.globals), 16)
+	globals := make([]object.Value, min(size, GlobalsSize))
+	copy(globals, vm.globals)
+	vm.globals = globals
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return fmt.Errorf("stack overflow: exceeded %d frames", MaxFrames)
+	}
+
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+
+	return nil
+}
+
+// popFrame also drops the handlers of try blocks the frame returned out of.
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+
+	for len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].framesIndex > vm.framesIndex {
+		vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	}
+
+	return vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	leftType := left.Type()
+	rightType := right.Type()
+
+	switch {
+	case left.IsInt() && right.IsInt():
+		return vm.executeBinaryIntegerOperation(op, left, right)
+	case isInteger(left) && isInteger(right):
+		return vm.executeBinaryBigIntOperation(op, left.Object(), right.Object())
+	case isNumber(left) && isNumber(right):
+		return vm.executeBinaryFloatOperation(op, left, right)
+	case leftType == object.STRING_OBJ && rightType == object.STRING_OBJ:
+		return vm.executeBinaryStringOperation(op, left.Object(), right.Object())
+	case leftType == object.BYTES_OBJ && rightType == object.BYTES_OBJ && op == code.OpAdd:
+		return vm.push(object.ValueOf(object.ConcatBytes(left.Object().(*object.Bytes), right.Object().(*object.Bytes))))
+	case leftType != rightType:
+		return fmt.Errorf("type mismatch: %s %s %s", leftType, operatorSymbols[op], rightType)
+	default:
+		return fmt.Errorf("unknown operator: %s %s %s", leftType, operatorSymbols[op], rightType)
+	}
+}
+
+func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.Value) error {
+	leftValue := left.Int()
+	rightValue := right.Int()
+
+	var result int64
+	ok := true
+
+	switch op {
+	case code.OpAdd:
+		result, ok = object.AddInt(leftValue, rightValue)
+	case code.OpSub:
+		result, ok = object.SubInt(leftValue, rightValue)
+	case code.OpMul:
+		result, ok = object.MulInt(leftValue, rightValue)
+	case code.OpDiv:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero: %d / 0", leftValue)
+		}
+
+		result, ok = object.DivInt(leftValue, rightValue)
+	case code.OpMod:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero: %d %% 0", leftValue)
+		}
+
+		result = leftValue % rightValue
+	case code.OpPow:
+		if rightValue < 0 {
+			return vm.executeBinaryFloatOperation(op, left, right)
+		}
+
+		result, ok = object.PowInt(leftValue, rightValue)
+	case code.OpBitAnd:
+		result = leftValue & rightValue
+	case code.OpBitOr:
+		result = leftValue | rightValue
+	case code.OpBitXor:
+		result = leftValue ^ rightValue
+	case code.OpShiftLeft:
+		result, ok = object.ShiftLeftInt(leftValue, rightValue)
+	case code.OpShiftRight:
+		result, ok = leftValue>>min(rightValue, 63), rightValue >= 0
+	default:
+		return fmt.Errorf("unknown integer operator: %d", op)
+	}
+
+	if !ok {
+		return vm.executeBinaryBigIntOperation(op, left.Object(), right.Object())
+	}
+
+	return vm.push(object.IntValue(result))
+}
+
+// executeBinaryBigIntOperation handles integers that are BigInts, or whose
+// result would be one.
+func (vm *VM) executeBinaryBigIntOperation(op code.Opcode, left, right object.Object) error {
+	leftValue, _ := object.ToBigInt(left)
+	rightValue, _ := object.ToBigInt(right)
+
+	result, err := object.BigArithmetic(operatorSymbols[op], leftValue, rightValue)
+
+	if err != nil {
+		return err
+	}
+
+	return vm.push(object.ValueOf(result))
+}
+
+func (vm *VM) executeBinaryFloatOperation(op code.Opcode, left, right object.Value) error {
+	leftValue := toFloat(left)
+	rightValue := toFloat(right)
+
+	var result float64
+
+	switch op {
+	case code.OpAdd:
+		result = leftValue + rightValue
+	case code.OpSub:
+		result = leftValue - rightValue
+	case code.OpMul:
+		result = leftValue * rightValue
+	case code.OpDiv:
+		result = leftValue / rightValue
+	case code.OpMod:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero: %s %% 0", left.Object().Inspect())
+		}
+
+		result = math.Mod(leftValue, rightValue)
+	case code.OpPow:
+		result = math.Pow(leftValue, rightValue)
+	default:
+		return fmt.Errorf("unknown operator: %s %s %s", left.Type(), operatorSymbols[op], right.Type())
+	}
+
+	return vm.push(object.FloatValue(result))
+}
+
+func (vm *VM) executeBinaryStringOperation(op code.Opcode, left, right object.Object) error {
+	if op != code.OpAdd {
+		return fmt.Errorf("unknown operator: %s %s %s", left.Type(), operatorSymbols[op], right.Type())
+	}
+
+	return vm.push(object.ValueOf(object.Concat(left.(*object.String), right.(*object.String))))
+}
+
+func (vm *VM) executeComparison(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	if left.IsInt() && right.IsInt() {
+		return vm.executeIntegerComparison(op, left, right)
+	}
+
+	if isInteger(left) && isInteger(right) {
+		return vm.executeBigIntComparison(op, left.Object(), right.Object())
+	}
+
+	if isNumber(left) && isNumber(right) {
+		return vm.executeFloatComparison(op, left, right)
+	}
+
+	if left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ {
+		return vm.executeStringComparison(op, left.Object(), right.Object())
+	}
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(right == left || object.Equal(left.Object(), right.Object())))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(right != left && !object.Equal(left.Object(), right.Object())))
+	default:
+		return fmt.Errorf("unknown operator: %s %s %s", left.Type(), operatorSymbols[op], right.Type())
+	}
+}
+
+func (vm *VM) executeIntegerComparison(op code.Opcode, left, right object.Value) error {
+	leftValue := left.Int()
+	rightValue := right.Int()
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue == leftValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue != leftValue))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	default:
+		return fmt.Errorf("unknown operator: %d", op)
+	}
+}
+
+func (vm *VM) executeBigIntComparison(op code.Opcode, left, right object.Object) error {
+	leftValue, _ := object.ToBigInt(left)
+	rightValue, _ := object.ToBigInt(right)
+
+	cmp := leftValue.Cmp(rightValue)
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(cmp == 0))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(cmp != 0))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(cmp > 0))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(cmp >= 0))
+	default:
+		return fmt.Errorf("unknown operator: %d", op)
+	}
+}
+
+func (vm *VM) executeFloatComparison(op code.Opcode, left, right object.Value) error {
+	leftValue := toFloat(left)
+	rightValue := toFloat(right)
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue == leftValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue != leftValue))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	default:
+		return fmt.Errorf("unknown operator: %d", op)
+	}
+}
+
+func (vm *VM) executeStringComparison(op code.Opcode, left, right object.Object) error {
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue == leftValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(rightValue != leftValue))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	default:
+		return fmt.Errorf("unknown operator: %d", op)
+	}
+}
+
+func (vm *VM) executeBangOperator() error {
+	operand := vm.pop()
+
+	return vm.push(nativeBoolToBooleanObject(!operand.Truthy()))
+}
+
+func (vm *VM) executeMinusOperator() error {
+	operand := vm.pop()
+
+	switch {
+	case operand.IsInt() && operand.Int() == math.MinInt64:
+		return vm.push(object.ValueOf(object.NormalizeInt(new(big.Int).Neg(big.NewInt(operand.Int())))))
+	case operand.IsInt():
+		return vm.push(object.IntValue(-operand.Int()))
+	case operand.Type() == object.BIGINT_OBJ:
+		return vm.push(object.ValueOf(object.NormalizeInt(new(big.Int).Neg(operand.Object().(*object.BigInt).Value))))
+	case operand.IsFloat():
+		return vm.push(object.FloatValue(-operand.Float()))
+	default:
+		return fmt.Errorf("unknown operator: -%s", operand.Type())
+	}
+}
+
+func (vm *VM) executeBitNotOperator() error {
+	operand := vm.pop()
+
+	switch {
+	case operand.IsInt():
+		return vm.push(object.IntValue(^operand.Int()))
+	case operand.Type() == object.BIGINT_OBJ:
+		return vm.push(object.ValueOf(object.NormalizeInt(new(big.Int).Not(operand.Object().(*object.BigInt).Value))))
+	default:
+		return fmt.Errorf("unknown operator: ~%s", operand.Type())
+	}
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs].Object()
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return fmt.Errorf("not a function: %s", callee.Type())
+	}
+}
+
+// executeSpreadCall replaces the arrays holding a call's arguments with
+// their elements and makes the call.
+func (vm *VM) executeSpreadCall(numPieces int) error {
+	pieces := make([]*object.Array, numPieces)
+
+	for i := numPieces - 1; i >= 0; i-- {
+		piece := vm.pop()
+
+		array, ok, err := object.AsArray(piece.Object())
+
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return fmt.Errorf("spread argument must be ARRAY, got %s", piece.Type())
+		}
+
+		pieces[i] = array
+	}
+
+	numArgs := 0
+
+	for _, piece := range pieces {
+		for _, el := range piece.Elements {
+			err := vm.push(object.ValueOf(el))
+
+			if err != nil {
+				return err
+			}
+		}
+
+		numArgs += len(piece.Elements)
+	}
+
+	return vm.executeCall(numArgs)
+}
+
+// executeNamedCall moves the values of a call's named arguments, which sit
+// on the stack after its positional ones, into the places of the parameters
+// they name, and makes the call. The parameters left out are marked missing,
+// as they are when a call passes too few arguments.
+func (vm *VM) executeNamedCall(numPositional int, names []object.Object) error {
+	base := vm.sp - numPositional - len(names)
+	callee := vm.stack[base-1].Object()
+
+	cl, ok := callee.(*object.Closure)
+
+	if !ok {
+		return fmt.Errorf("named arguments not supported: %s", callee.Type())
+	}
+
+	params := cl.Fn.Parameters
+
+	if cl.Fn.Variadic {
+		params = params[:len(params)-1]
+	}
+
+	args := make([]object.Value, max(numPositional, len(params)))
+
+	for i := range args {
+		args[i] = missingValue
+	}
+
+	copy(args, vm.stack[base:base+numPositional])
+
+	for i, name := range names {
+		name := name.(*object.String).Value
+		j := slices.Index(params, name)
+
+		if j < 0 {
+			return fmt.Errorf("unknown argument name: %s", name)
+		}
+
+		if args[j] != missingValue {
+			return fmt.Errorf("duplicate argument: %s", name)
+		}
+
+		args[j] = vm.stack[base+numPositional+i]
+	}
+
+	for i, param := range params[:len(params)-cl.Fn.NumDefaults] {
+		if args[i] == missingValue {
+			return fmt.Errorf("missing argument: %s", param)
+		}
+	}
+
+	vm.sp = base
+
+	for _, arg := range args {
+		err := vm.push(arg)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return vm.callClosure(cl, len(args))
+}
+
+// callClosure sets up a frame for cl. A variadic closure's extra
+// arguments are collected into an array in its last parameter's slot, and
+// the parameters a call leaves out are marked missing, for the closure's
+// OpDefault instructions to fill in.
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	fn := cl.Fn
+	fixed := fn.NumParameters
+
+	if fn.Variadic {
+		fixed--
+	}
+
+	required := fixed - fn.NumDefaults
+
+	if numArgs < required || (!fn.Variadic && numArgs > fixed) {
+		max := fixed
+
+		if fn.Variadic {
+			max = -1
+		}
+
+		return fmt.Errorf("%s", object.WrongArguments(required, max, numArgs))
+	}
+
+	var rest object.Value
+
+	if fn.Variadic {
+		elements := []object.Object{}
+
+		if numArgs > fixed {
+			elements = make([]object.Object, numArgs-fixed)
+
+			for i, arg := range vm.stack[vm.sp-len(elements) : vm.sp] {
+				elements[i] = arg.Object()
+			}
+
+			vm.sp -= len(elements)
+			numArgs = fixed
+		}
+
+		rest = object.ValueOf(&object.Array{Elements: elements})
+	}
+
+	if err := vm.tick(); err != nil {
+		return err
+	}
+
+	frame := NewFrame(cl, vm.sp-numArgs)
+
+	err := vm.pushFrame(frame)
+
+	if err != nil {
+		return err
+	}
+
+	vm.sp = frame.basePointer + fn.NumLocals
+
+	if vm.sp >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+
+	for i := numArgs; i < fixed; i++ {
+		vm.stack[frame.basePointer+i] = missingValue
+	}
+
+	if fn.Variadic {
+		vm.stack[frame.basePointer+fixed] = rest
+	}
+
+	return nil
+}
+
+// callBuiltin runs a builtin; an *object.Error result aborts execution the
+// same way it does in the evaluator. The arguments are boxed into a slice
+// the VM reuses, which is safe because no builtin keeps the slice itself.
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	vm.args = vm.args[:0]
+
+	for _, arg := range vm.stack[vm.sp-numArgs : vm.sp] {
+		vm.args = append(vm.args, arg.Object())
+	}
+
+	if builtin == sleep {
+		return vm.sleep(numArgs)
+	}
+
+	result := builtin.Fn(vm.args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	if errObj, ok := result.(*object.Error); ok {
+		if !errObj.Catchable() {
+			return uncatchableError{errObj}
+		}
+
+		return fmt.Errorf("%s", errObj.Message)
+	}
+
+	if result == nil {
+		return vm.push(nullValue)
+	}
+
+	return vm.push(object.ValueOf(result))
+}
+
+// sleep is handled by the VM rather than called, so that it can give up
+// when the context of RunContext is done.
+var sleep = object.GetBuiltinByName("sleep")
+
+func (vm *VM) sleep(numArgs int) error {
+	d, errObj := object.SleepDuration(vm.args)
+
+	if errObj != nil {
+		return fmt.Errorf("%s", errObj.Message)
+	}
+
+	var done <-chan struct{}
+
+	if vm.ctx != nil {
+		done = vm.ctx.Done()
+	}
+
+	if !object.Sleep(d, done) {
+		return stoppedError{vm.ctx.Err()}
+	}
+
+	vm.sp = vm.sp - numArgs - 1
+
+	return vm.push(nullValue)
+}
+
+// uncatchableError is an error a builtin raised that try does not catch,
+// a failed assertion, an exit or a panic, on its way out of the VM.
+type uncatchableError struct {
+	err *object.Error
+}
+
+func (e uncatchableError) Error() string {
+	return e.err.Message
+}
+
+// ErrorObject returns err, which running the VM failed with, as an
+// *object.Error: the one a builtin raised, if try could not catch it, so
+// that an exit keeps its status, or else one with err's message.
+func ErrorObject(err error) *object.Error {
+	var uncatchable uncatchableError
+
+	if errors.As(err, &uncatchable) {
+		return uncatchable.err
+	}
+
+	return &object.Error{Message: err.Error()}
+}
+
+// stoppedError ends a run whose context was done. try does not catch it.
+type stoppedError struct {
+	err error
+}
+
+func (e stoppedError) Error() string {
+	return "evaluation stopped: " + e.err.Error()
+}
+
+func catchable(err error) bool {
+	switch err.(type) {
+	case uncatchableError, stoppedError:
+		return false
+	default:
+		return true
+	}
+}
+
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+
+	for i := startIndex; i < endIndex; i++ {
+		elements[i-startIndex] = vm.stack[i].Object()
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
+	hash := object.NewHash()
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i].Object()
+		value := vm.stack[i+1].Object()
+
+		hashKey, ok := key.(object.Hashable)
+
+		if !ok {
+			return nil, fmt.Errorf("%s", object.UnusableHashKey(key))
+		}
+
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) executeIndexExpression(left, index object.Object) error {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.RANGE_OBJ && index.Type() == object.INTEGER_OBJ:
+		value, ok := left.(*object.Range).At(index.(*object.Integer).Value)
+
+		if !ok {
+			return vm.push(nullValue)
+		}
+
+		return vm.push(object.IntValue(value))
+	case left.Type() == object.BYTES_OBJ && index.Type() == object.INTEGER_OBJ:
+		value, ok := left.(*object.Bytes).At(index.(*object.Integer).Value)
+
+		if !ok {
+			return vm.push(nullValue)
+		}
+
+		return vm.push(object.IntValue(value))
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeStringIndex(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return vm.executeHashIndex(left, index)
+	default:
+		return fmt.Errorf("index operator not supported: %s", left.Type())
+	}
+}
+
+func (vm *VM) executeArrayIndex(array, index object.Object) error {
+	arrayObject := array.(*object.Array)
+	i := index.(*object.Integer).Value
+	max := int64(len(arrayObject.Elements) - 1)
+
+	if i < 0 || i > max {
+		return vm.push(nullValue)
+	}
+
+	return vm.push(object.ValueOf(arrayObject.Elements[i]))
+}
+
+func (vm *VM) executeStringIndex(str, index object.Object) error {
+	r, ok := object.RuneAt(str.(*object.String).Value, index.(*object.Integer).Value)
+
+	if !ok {
+		return vm.push(nullValue)
+	}
+
+	return vm.push(object.ValueOf(object.Intern(string(r))))
+}
+
+func (vm *VM) executeHashIndex(hash, index object.Object) error {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+
+	if !ok {
+		return fmt.Errorf("%s", object.UnusableHashKey(index))
+	}
+
+	pair, ok := hashObject.Pairs[key.HashKey()]
+
+	if !ok {
+		return vm.push(nullValue)
+	}
+
+	return vm.push(object.ValueOf(pair.Value))
+}
+
+// executeSlice pushes a new array, string or bytes holding left[low:high]. A
+// null bound means the start or end, and bounds are clamped to the length.
+func (vm *VM) executeSlice(left, low, high object.Object) error {
+	var length int
+
+	switch left := left.(type) {
+	case *object.Array:
+		length = len(left.Elements)
+	case *object.String:
+		length = utf8.RuneCountInString(left.Value)
+	case *object.Bytes:
+		length = len(left.Value)
+	default:
+		return fmt.Errorf("slice operator not supported: %s", left.Type())
+	}
+
+	start, err := sliceBound(low, 0, length)
+
+	if err != nil {
+		return err
+	}
+
+	end, err := sliceBound(high, length, length)
+
+	if err != nil {
+		return err
+	}
+
+	if start > end {
+		start = end
+	}
+
+	if array, ok := left.(*object.Array); ok {
+		elements := make([]object.Object, end-start)
+		copy(elements, array.Elements[start:end])
+
+		return vm.push(object.ValueOf(&object.Array{Elements: elements}))
+	}
+
+	if bytes, ok := left.(*object.Bytes); ok {
+		return vm.push(object.ValueOf(bytes.Slice(start, end)))
+	}
+
+	return vm.push(object.ValueOf(&object.String{Value: string([]rune(left.(*object.String).Value)[start:end])}))
+}
+
+func sliceBound(bound object.Object, def, length int) (int, error) {
+	if bound == Null {
+		return def, nil
+	}
+
+	integer, ok := bound.(*object.Integer)
+
+	if !ok {
+		return 0, fmt.Errorf("slice bounds must be INTEGER, got %s", bound.Type())
+	}
+
+	switch {
+	case integer.Value < 0:
+		return 0, nil
+	case integer.Value > int64(length):
+		return length, nil
+	default:
+		return int(integer.Value), nil
+	}
+}
+
+// executeSetIndex stores value at index in the array or hash left and pushes
+// value back as the result of the assignment.
+func (vm *VM) executeSetIndex(left, index object.Object, value object.Value) error {
+	switch {
+	case (left.Type() == object.ARRAY_OBJ || left.Type() == object.HASH_OBJ) && object.IsFrozen(left):
+		return fmt.Errorf("cannot assign to frozen %s", left.Type())
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		arrayObject := left.(*object.Array)
+		i := index.(*object.Integer).Value
+
+		if i < 0 || i >= int64(len(arrayObject.Elements)) {
+			return fmt.Errorf("index out of range: %d, length %d", i, len(arrayObject.Elements))
+		}
+
+		arrayObject.Elements[i] = value.Object()
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+
+		if !ok {
+			return fmt.Errorf("%s", object.UnusableHashKey(index))
+		}
+
+		left.(*object.Hash).Set(key.HashKey(), object.HashPair{Key: index, Value: value.Object()})
+	default:
+		return fmt.Errorf("index assignment not supported: %s", left.Type())
+	}
+
+	return vm.push(value)
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	constant := vm.constants[constIndex].Object()
+	function, ok := constant.(*object.CompiledFunction)
+
+	if !ok {
+		return fmt.Errorf("not a function: %+v", constant)
+	}
+
+	free := make([]object.Object, numFree)
+
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i].Object()
+	}
+
+	vm.sp = vm.sp - numFree
+
+	closure := &object.Closure{Fn: function, Free: free}
+
+	return vm.push(object.ValueOf(closure))
+}
+
+var operatorSymbols = map[code.Opcode]string{
+	code.OpAdd:                "+",
+	code.OpSub:                "-",
+	code.OpMul:                "*",
+	code.OpDiv:                "/",
+	code.OpMod:                "%",
+	code.OpPow:                "**",
+	code.OpBitAnd:             "&",
+	code.OpBitOr:              "|",
+	code.OpBitXor:             "^",
+	code.OpShiftLeft:          "<<",
+	code.OpShiftRight:         ">>",
+	code.OpEqual:              "==",
+	code.OpNotEqual:           "!=",
+	code.OpGreaterThan:        ">",
+	code.OpGreaterThanOrEqual: ">=",
+}
+
+func nativeBoolToBooleanObject(input bool) object.Value {
+	if input {
+		return trueValue
+	}
+
+	return falseValue
+}
+
+func isTruthy(v object.Value) bool {
+	return v.Truthy()
+}
+
+func isNumber(v object.Value) bool {
+	return isInteger(v) || v.IsFloat()
+}
+
+func isInteger(v object.Value) bool {
+	return v.IsInt() || v.Type() == object.BIGINT_OBJ
+}
+
+func toFloat(v object.Value) float64 {
+	switch {
+	case v.IsInt():
+		return float64(v.Int())
+	case v.IsFloat():
+		return v.Float()
+	case v.Type() == object.BIGINT_OBJ:
+		return v.Object().(*object.BigInt).Float()
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,1173 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1", 1},
+		{"2", 2},
+		{"1 + 2", 3},
+		{"1 - 2", -1},
+		{"1 * 2", 2},
+		{"4 / 2", 2},
+		{"50 / 2 * 2 + 10 - 5", 55},
+		{"5 * (2 + 10)", 60},
+		{"-5", -5},
+		{"-50 + 100 + -50", 0},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"0xFF + 0o7 + 0b11 + 1_000", 1265},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBigIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"9223372036854775807 + 1", bigInt("9223372036854775808")},
+		{"9223372036854775808", bigInt("9223372036854775808")},
+		{"-9223372036854775808", -9223372036854775808},
+		{"0x1_0000_0000_0000_0000 == 2 ** 64", true},
+		{"-9223372036854775807 - 2", bigInt("-9223372036854775809")},
+		{"4294967296 * 4294967296", bigInt("18446744073709551616")},
+		{"2 ** 100", bigInt("1267650600228229401496703205376")},
+		{"(-9223372036854775807 - 1) / -1", bigInt("9223372036854775808")},
+		{"-(-9223372036854775807 - 1)", bigInt("9223372036854775808")},
+		{"2 ** 64 + 2 ** 64", bigInt("36893488147419103232")},
+		{"-(2 ** 66) / 3", bigInt("-24595658764946068821")},
+		{"2 ** 64 - 2 ** 64", 0},
+		{"-(2 ** 63)", -9223372036854775808},
+		{"(2 ** 64 + 7) % 10", 3},
+		{"2 ** 64 > 9223372036854775807", true},
+		{"2 ** 64 < 1", false},
+		{"2 ** 64 == 2 ** 64", true},
+		{"2 ** 64 != 2 ** 65", true},
+		{"2 ** 64 == 2.0 ** 64", true},
+		{"let x = 9223372036854775807; x++; x", bigInt("9223372036854775808")},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBitwiseOperators(t *testing.T) {
+	tests := []vmTestCase{
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"~5", -6},
+		{"1 << 10", 1024},
+		{"-8 >> 1", -4},
+		{"-1 >> 100", -1},
+		{"1 << 64", bigInt("18446744073709551616")},
+		{"(1 << 64) >> 64", 1},
+		{"(2 ** 64 + 5) & 7", 5},
+		{"~(2 ** 64)", bigInt("-18446744073709551617")},
+		{"1 + 1 << 2", 8},
+		{"5 & 1 == 1", true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFloatArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1.5", 1.5},
+		{"1.5 + 1.5", 3.0},
+		{"1 + 0.5", 1.5},
+		{"5 / 2.0", 2.5},
+		{"-2.25", -2.25},
+		{"7.5 % 2", 1.5},
+		{"2 ** -1", 0.5},
+		{"4 ** 0.5", 2.0},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 == 1", true},
+		{"1 != 2", true},
+		{"true == false", false},
+		{"(1 < 2) == true", true},
+		{"1.5 < 2", true},
+		{"2.0 == 2", true},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"1.5 >= 1", true},
+		{"1 <= 0.5", false},
+		{`"a" < "b"`, true},
+		{`"b" <= "a"`, false},
+		{`"b" >= "a"`, true},
+		{`"abc" == "abc"`, true},
+		{`"abc" != "abd"`, true},
+		{"!true", false},
+		{"!!5", true},
+		{"!(if (false) { 5; })", true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []vmTestCase{
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"true || false", true},
+		{"1 && 2", true},
+		{"if (false) { 1 } || true", true},
+		{"false && (1 + true)", false},
+		{"true || (1 + true)", true},
+		{"let x = 0; false && (x = 1); x", 0},
+		{"let x = 0; true && (x = 1); x", 1},
+		{"let x = 0; false || (x = 2); x", 2},
+		{"if (1 < 2 && 3 > 2) { 10 } else { 20 }", 10},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCoalesceOperator(t *testing.T) {
+	tests := []vmTestCase{
+		{`{"a": 1}["a"] ?? 2`, 1},
+		{`{"a": 1}["b"] ?? 2`, 2},
+		{"if (false) { 1 } ?? 3", 3},
+		{"[][0] ?? [][1] ?? 4", 4},
+		{"false ?? 5", false},
+		{"let x = 0; 1 ?? (x = 1); x", 0},
+		{"let f = fn(h) { h[1] ?? 7 }; f({})", 7},
+		{`let h = {}; isNull(h["a"])`, true},
+		{"isNull(0)", false},
+		{`isHashable("a")`, true},
+		{"isHashable([1])", false},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestOptionalChaining(t *testing.T) {
+	tests := []vmTestCase{
+		{`let h = {"a": {"b": [1, 2]}}; h?.a?.b?[1]`, 2},
+		{`let h = {}; h.a?.b?[0] ?? 3`, 3},
+		{`let n = if (false) { 1 }; n?.a`, Null},
+		{`let n = if (false) { 1 }; n?[0:1]`, Null},
+		{`[1, 2, 3]?[1:]?[0]`, 2},
+		{`let f = fn(h) { h?.a ?? 4 }; f(if (false) { 1 })`, 4},
+		{`let calls = 0; let n = if (false) { 1 }; n?[calls += 1]; calls`, 0},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestSwitchExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"switch (2) { case 1: 10 case 2: 20 default: 30 }", 20},
+		{`switch ("foo") { case "bar": 1 case "foo": 2 }`, 2},
+		{"switch (3) { case 1, 2: 10 case 3, 4: 20 }", 20},
+		{"switch (5) { case 1: 10 default: 30 }", 30},
+		{"switch (5) { case 1: 10 }", Null},
+		{"switch (1) { case 1: 10 case 1: 20 }", 10},
+		{`switch ("1") { case 1: 10 case "1": 20 }`, 20},
+		{"switch (1) { case 1: default: 30 }", Null},
+		{"let x = 0; switch (1) { case 1: x = 5; x * 2 }", 10},
+		{"let f = fn(n) { switch (n) { case 0: return 1; default: 2 }; 3 }; f(0) + f(1)", 4},
+		{"let i = 0; switch (i++) { case 0: i }", 1},
+		{"let a = [switch (1) { case 1: 2 }, 3]; a[1]", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"try { 1 / 0 } catch (e) { e }", "division by zero: 1 / 0"},
+		{"try { [1, 2] + 1 } catch (e) { e }", "type mismatch: ARRAY + INTEGER"},
+		{"try { len(1) } catch (e) { e }", "argument to `len` not supported, got INTEGER"},
+		{"try { 1 } catch (e) { 2 }", 1},
+		{"try { 1 + true; 3 } catch (e) { 4 }", 4},
+		{"try { } catch (e) { 1 }", Null},
+		{"try { 1 / 0 } catch (e) { }", Null},
+		{"let f = fn() { 1 / 0 }; try { f() } catch (e) { 5 }", 5},
+		{"let f = fn() { try { return 1; } catch (e) { 2 }; 3 }; f()", 1},
+		{"try { try { 1 / 0 } catch (e) { -true } } catch (e) { e }", "unknown operator: -BOOLEAN"},
+		{"let e = 1; try { 1 / 0 } catch (e) { e }; e", 1},
+		{"let x = 0; try { x = 1; 1 / 0; x = 2 } catch (e) { x = x + 10 }; x", 11},
+		{"let n = 0; for (let i = 0; i < 5; i++) { try { if (i == 3) { break; } n = n + i } catch (e) { 0 } }; n", 3},
+		{"let safe = fn(n) { try { if (n == 0) { 1 / n } else { safe(n - 1) } } catch (e) { n } }; safe(3)", 0},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase{
+		{"if (true) { 10 }", 10},
+		{"if (true) { 10 } else { 20 }", 10},
+		{"if (false) { 10 } else { 20 } ", 20},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", Null},
+		{"if (false) { 10 }", Null},
+		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+		{"if (true) { let x = 1; }", Null},
+		{"true ? 10 : 20", 10},
+		{"false ? 10 : 20", 20},
+		{"1 > 2 ? 10 : 20", 20},
+		{"[][0] ? 10 : 20", 20},
+		{"let x = 5; x > 3 ? x < 4 ? 1 : 2 : 3", 2},
+		{"let x = 0; true ? 1 : (x = 9); x", 0},
+		{"let f = fn(n) { n > 0 ? \"pos\" : \"other\" }; f(1) + f(0)", "posother"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let one = 1; one", 1},
+		{"let one = 1; let two = 2; one + two", 3},
+		{"let one = 1; let two = one + one; one + two", 3},
+		{manyGlobals(40) + "gaa + gbn", 39},
+	}
+
+	runVmTests(t, tests)
+}
+
+// manyGlobals defines n globals, gaa, gab and so on, each holding its own
+// number.
+func manyGlobals(n int) string {
+	var out strings.Builder
+
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&out, "let g%c%c = %d; ", 'a'+i/26, 'a'+i%26, i)
+	}
+
+	return out.String()
+}
+
+func TestUnboxedValues(t *testing.T) {
+	tests := []vmTestCase{
+		{"let a = 100000; let b = 100000; a == b", true},
+		{"100000 * 100000 != 10000000000", false},
+		{"[1.5][0] == 1.5", true},
+		{"let h = {1: 2000}; h[1] + h[1]", 4000},
+		{"let f = fn(x) { fn() { x * 1000 } }; f(5)()", 5000},
+		{"if (0) { 1 } else { 2 }", 1},
+		{"!0", false},
+		{"-(2.5)", -2.5},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStringExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{`"monkey"`, "monkey"},
+		{`"mon" + "key"`, "monkey"},
+		{`"mon" + "key" + "banana"`, "monkeybanana"},
+		{`let a = "x" + "y"; let b = a + "1"; let c = a + "2"; b + "|" + c`, "xy1|xy2"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStringInterpolation(t *testing.T) {
+	tests := []vmTestCase{
+		{`let name = "Bob"; "Hello, ${name}!"`, "Hello, Bob!"},
+		{`let age = 41; "You are ${age + 1}"`, "You are 42"},
+		{`"${1}${2.5}${true}"`, "12.5true"},
+		{`"${[1, "a"]} ${{"k": 2}["k"]}"`, `[1, "a"] 2`},
+		{`let f = fn(x) { "<${x}>" }; "${f("in ${f(1)}")}"`, "<in <1>>"},
+		{`"\${x} $5 $"`, "${x} $5 $"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestWhileStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"while (false) { 10 }; 5", 5},
+		{"let f = fn() { while (true) { return 7; } }; f();", 7},
+		{"let f = fn(x) { while (x > 0) { return x * 2; }; 0 }; f(-1);", 0},
+		{"let x = 1; let f = fn() { while (true) { let x = 2; return x; } }; f() + x;", 3},
+		{"let i = 5; do { i = i + 1 } while (i < 3); i", 6},
+		{"let i = 0; do { i = i + 1 } while (i < 3); i", 3},
+		{"let f = fn() { loop { return 7; } }; f();", 7},
+		{"let i = 0; loop { i = i + 1; if (i == 4) { break } }; i", 4},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestForStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"for (let i = 0; false; i) { 10 }; 5", 5},
+		{"let f = fn() { for (;;) { return 5; } }; f();", 5},
+		{"let f = fn() { for (let i = 3; i > 0; i) { return i * 2; } }; f();", 6},
+		{"let i = 1; let f = fn() { for (let i = 10; true; i) { return i; } }; f() + i;", 11},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestForInStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let sum = 0; for (x in [1, 2, 3]) { sum += x; }; sum", 6},
+		{"let sum = 0; for (i in range(10)) { if (i % 2 == 0) { continue; } sum += i; }; sum", 25},
+		{"let sum = 0; for (i in range(1000000000)) { if (i == 4) { break; } sum += i; }; sum", 6},
+		{`let n = 0; for (k in {"a": 1, "b": 2}) { n += len(k); }; n`, 2},
+		{`let n = 0; for (c in "héllo") { if (c == "l") { n++; } }; n`, 2},
+		{"let n = 0; for (x in []) { n = 1; }; n", 0},
+		{"let xs = [1, 2]; let n = 0; for (x in xs) { xs = push(xs, x); n++; }; n", 2},
+		{"let f = fn() { for (x in [7, 8]) { return x; } }; f();", 7},
+		{"let f = fn() { let fs = []; for (i in range(3)) { fs = push(fs, fn() { i }); }; fs[0]() + fs[2]() }; f();", 2},
+		{"let f = fn() { let n = 0; for (x in [1, 2]) { for (y in [10, 20]) { n += x * y; } }; n }; f();", 90},
+		{"let x = 1; for (x in [5]) { x; }; x", 1},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBreakAndContinue(t *testing.T) {
+	tests := []vmTestCase{
+		{"while (true) { break; }; 5", 5},
+		{"for (;;) { if (true) { break; } }; 6", 6},
+		{"let f = fn() { while (true) { if (true) { break; } return 1; }; 2 }; f();", 2},
+		{"let f = fn() { while (true) { while (true) { break; } return 3; } }; f();", 3},
+		{"let i = 0; let n = 0; do { i++; if (i % 2 == 0) { continue; } n++; } while (i < 5); n", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let a = 5; a = 10; a;", 10},
+		{"let a = 5; a = a * 2;", 10},
+		{"let a = 1; let b = 2; a = b = 3; a + b;", 6},
+		{"let a = 1; let f = fn() { a = 2; }; f(); a;", 2},
+		{"let a = 1; let f = fn() { let a = 5; a = 2; }; f(); a;", 1},
+		{"let i = 0; while (i < 5) { i = i + 1; }; i;", 5},
+		{"let sum = 0; for (let i = 0; i < 5; i = i + 1) { sum = sum + i; }; sum;", 10},
+		{"let sum = 0; for (let i = 0; i < 10; i = i + 1) { if (i > 3) { continue; } sum = sum + i; }; sum;", 6},
+		{"let i = 0; while (true) { i = i + 1; if (i == 7) { break; } }; i;", 7},
+		{"let f = fn() { let n = 0; for (let i = 0; i < 4; i = i + 1) { n = n + i; }; n }; f();", 6},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestPostfixAndCompoundAssignment(t *testing.T) {
+	tests := []vmTestCase{
+		{"let i = 5; i++", 5},
+		{"let i = 5; i++; i", 6},
+		{"let i = 5; i--; i", 4},
+		{"let i = 0; let j = i++ + i++; j", 1},
+		{"let a = 10; a += 5; a", 15},
+		{"let a = 10; a -= 5", 5},
+		{"let a = 10; a *= 2; a", 20},
+		{"let a = 10; a /= 4; a", 2},
+		{"let sum = 0; for (let i = 0; i < 5; i++) { sum += i; }; sum;", 10},
+		{"let f = fn() { let n = 0; n++; n += 2; n }; f();", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let fivePlusTen = fn() { 5 + 10; }; fivePlusTen();", 15},
+		{"let earlyExit = fn() { return 99; 100; }; earlyExit();", 99},
+		{"let noReturn = fn() { }; noReturn();", Null},
+		{"let identity = fn(a) { a; }; identity(4);", 4},
+		{"let sum = fn(a, b) { let c = a + b; c; }; sum(1, 2);", 3},
+		{`
+		let globalSeed = 50;
+		let minusOne = fn() { let num = 1; globalSeed - num; }
+		let minusTwo = fn() { let num = 2; globalSeed - num; }
+		minusOne() + minusTwo();
+		`, 97},
+		{"return 10; 9;", 10},
+		{"let add = fn(x, y) { x + y; }; add(...[1, 2]);", 3},
+		{"let add = fn(x, y) { x + y; }; add(...[], 1, ...[2], ...[]);", 3},
+		{"let count = fn(...xs) { len(xs) }; count();", 0},
+		{"let count = fn(x, ...xs) { len(xs) }; count(1, 2, 3);", 2},
+		{"let rest = fn(x, ...xs) { xs }; rest(1, 2, 3);", []int{2, 3}},
+		{"let f = fn(...xs) { len(xs) }; let g = fn(...ys) { f(...ys, ...ys) }; g(1, 2);", 4},
+		{"let f = fn(a, ...xs) { let b = 10; a + b + len(xs) }; f(1, 2);", 12},
+		{"len(...[[1, 2]]);", 2},
+		{"let add = fn(x, y = 10) { x + y }; add(1);", 11},
+		{"let add = fn(x, y = 10) { x + y }; add(1, 2);", 3},
+		{"let add = fn(x = 1, y = 2) { x + y }; add();", 3},
+		{"let add = fn(x = 1, y = 2) { x + y }; add(5);", 7},
+		{"let y = 5; let f = fn(x, y = y * 2) { x + y }; f(1);", 11},
+		{"let x = 100; let f = fn(x, y = x) { y }; f(1);", 100},
+		{"let n = 1; let f = fn(x = n) { x }; n = 7; f();", 7},
+		{"let f = fn(a, x = 1, ...xs) { [x, xs] }; f(0)[1];", []int{}},
+		{"let f = fn(x = 1, ...xs) { x + len(xs) }; f(5, 6, 7);", 7},
+		{"let make = fn(b) { fn(x = b) { let c = 1; x + c } }; make(4)();", 5},
+		{"let f = fn() { let a = 3; let g = fn(x = a) { x }; g() }; f();", 3},
+		{"let sub = fn(x, y) { x - y }; sub(y: 1, x: 10);", 9},
+		{"let sub = fn(x, y) { x - y }; sub(10, y: 1);", 9},
+		{"let f = fn(x, y = 2, z = 3) { x * 100 + y * 10 + z }; f(1, z: 9);", 129},
+		{"let f = fn(x = 1, y = 2) { x * 10 + y }; f(y: 5);", 15},
+		{"let f = fn(x, ...xs) { x + len(xs) }; f(x: 5);", 5},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{`
+		let newClosure = fn(a) { fn() { a; }; };
+		let closure = newClosure(99);
+		closure();
+		`, 99},
+		{`
+		let newAdderOuter = fn(a, b) {
+			let c = a + b;
+			fn(d) {
+				let e = d + c;
+				fn(f) { e + f; };
+			};
+		};
+		let newAdderInner = newAdderOuter(1, 2)
+		let adder = newAdderInner(3);
+		adder(8);
+		`, 14},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestClosureMutation(t *testing.T) {
+	tests := []vmTestCase{
+		{`
+		let makeCounter = fn() {
+			let count = 0;
+			fn() { count = count + 1 };
+		};
+		let c = makeCounter();
+		c();
+		c();
+		c();`, 3},
+		{`
+		let makeCounter = fn() {
+			let count = 0;
+			fn() { count = count + 1 };
+		};
+		let a = makeCounter();
+		let b = makeCounter();
+		a();
+		a();
+		b();`, 1},
+		{`
+		let makePair = fn() {
+			let value = 0;
+			[fn() { value = value + 10 }, fn() { value }];
+		};
+		let pair = makePair();
+		pair[0]();
+		pair[0]();
+		pair[1]();`, 20},
+		{`
+		let total = 0;
+		let add = fn(n) { total = total + n };
+		add(5);
+		add(7);
+		total;`, 12},
+		{`
+		let x = 1;
+		let shadow = fn() { let x = 100; x = x + 1; x };
+		shadow();
+		x;`, 1},
+		{`
+		let fns = [];
+		let i = 0;
+		while (i < 3) {
+			let j = i;
+			fns = push(fns, fn() { j });
+			i = i + 1;
+		}
+		fns[0]() + fns[1]() + fns[2]();`, 3},
+		{"let f = fn(n) { let g = fn() { n }; n = 5; g() }; f(1)", 5},
+		{"let f = fn(n = 1) { let g = fn() { n += 1 }; g(); g(); n }; f()", 3},
+		{"let f = fn() { let n = 0; let g = fn() { fn() { n++ } }; let h = g(); h(); h(); n }; f()", 2},
+		{"let f = fn() { let fns = []; for (i in range(3)) { let j = i; fns = push(fns, fn() { j += 10; j }) }; fns[0]() + fns[1]() + fns[2]() }; f()", 33},
+		{"let f = fn() { let n = 0; let g = fn() { h() }; let h = fn() { n = n + 1 }; g(); g(); n }; f()", 2},
+		{"let fns = []; for (i in range(3)) { let j = i; fns = push(fns, fn() { j += 1; j }) }; fns[0]() + fns[0]() + fns[2]()", 6},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRecursiveFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`
+		let countDown = fn(x) { if (x == 0) { return 0; } else { countDown(x - 1); } };
+		countDown(1);
+		`, 0},
+		{`
+		let wrapper = fn() {
+			let countDown = fn(x) { if (x == 0) { return 0; } else { countDown(x - 1); } };
+			countDown(1);
+		};
+		wrapper();
+		`, 0},
+		{`
+		let fibonacci = fn(x) {
+			if (x == 0) { return 0; }
+			if (x == 1) { return 1; }
+			fibonacci(x - 1) + fibonacci(x - 2);
+		};
+		fibonacci(15);
+		`, 610},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConstStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"const x = 5; x * 2", 10},
+		{"const x = 5; let f = fn() { let x = 1; x = 2; x }; f() + x", 7},
+		{"const x = 5; let f = fn(x) { x = x + 1; x }; f(1) + x", 7},
+		{"const x = 5; let x = 6; x = 7; x", 7},
+		{"const double = fn(n) { n * 2 }; double(4)", 8},
+		{"let f = fn() { const n = 3; n + 1 }; f()", 4},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestDestructuringStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let [a, b, c] = [1, 2, 3]; a * 100 + b * 10 + c", 123},
+		{"let [a, b] = [1]; b", Null},
+		{`let {name, age} = {"name": "ann", "age": 30}; age`, 30},
+		{`let {name, age} = {"name": "ann"}; age`, Null},
+		{"let f = fn(pair) { let [a, b] = pair; a - b }; f([5, 3])", 2},
+		{"let divmod = fn(a, b) { return a / b, a % b }; let q, r = divmod(17, 5); q * 10 + r", 32},
+		{"let a = 1; let b = 2; let f = fn() { let [a, b] = [b, a]; a * 10 + b }; f()", 21},
+		{"let f = fn() { const {x} = {\"x\": 4}; let g = fn() { x * 2 }; g() }; f()", 8},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestMutualRecursion(t *testing.T) {
+	tests := []vmTestCase{
+		{`
+		let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+		let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+		isEven(10);
+		`, true},
+		{`
+		let f = fn() {
+			let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+			let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+			isOdd(7);
+		};
+		f();
+		`, true},
+		{`
+		let f = fn(x) {
+			let a = fn() { let inner = fn() { b() }; inner() };
+			let y = x * 2;
+			let b = fn() { y + 1 };
+			a();
+		};
+		f(4) + f(5);
+		`, 20},
+		{`
+		let f = fn() {
+			let n = 0;
+			for (i in range(3)) {
+				let ping = fn(k) { if (k == 0) { 0 } else { pong(k - 1) + 1 } };
+				let pong = fn(k) { if (k == 0) { 0 } else { ping(k - 1) + 1 } };
+				n += ping(i);
+			}
+			n;
+		};
+		f();
+		`, 3},
+		{"let f = fn() { let g = 1; let g = fn() { 2 }; g() }; f();", 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestArrayLiterals(t *testing.T) {
+	tests := []vmTestCase{
+		{"[]", []int{}},
+		{"[1, 2, 3]", []int{1, 2, 3}},
+		{"[1 + 2, 3 * 4, 5 + 6]", []int{3, 12, 11}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestHashLiterals(t *testing.T) {
+	tests := []vmTestCase{
+		{"{}", "{}"},
+		{"{1: 2, 2: 3}", "{1: 2, 2: 3}"},
+		{`{"b": 1 + 1, "a": 3 * 4}`, `{"b": 2, "a": 12}`},
+		{`let h = {"b": 1}; h["a"] = 2; h["b"] = 3; h`, `{"b": 3, "a": 2}`},
+		{`delete({"c": 1, "b": 2, "a": 3}, "b")`, `{"c": 1, "a": 3}`},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+
+		err := comp.Compile(program)
+
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+
+		err = vm.Run()
+
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		actual := vm.LastPoppedStackElem()
+
+		if actual.Type() != object.HASH_OBJ || actual.Inspect() != tt.expected {
+			t.Errorf("wrong hash. want=%s, got=%s", tt.expected, actual.Inspect())
+		}
+	}
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3][1]", 2},
+		{"[[1, 1, 1]][0][0]", 1},
+		{"[][0]", Null},
+		{"[1, 2, 3][99]", Null},
+		{"[1][-1]", Null},
+		{"{1: 1, 2: 2}[1]", 1},
+		{"{1: 1}[0]", Null},
+		{"{}[0]", Null},
+		{`"hello"[1]`, "e"},
+		{`"añb"[1]`, "ñ"},
+		{`"abc"[3]`, Null},
+		{`"abc"[-1]`, Null},
+		{`{"foo": 5}.foo`, 5},
+		{`{"foo": 5}.bar`, Null},
+		{`let p = {"pos": {"x": 1, "y": 2}}; p.pos.y`, 2},
+		{`let p = {"double": fn(x) { x * 2 }}; p.double(3)`, 6},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3, 4][1:3]", []int{2, 3}},
+		{"[1, 2, 3, 4][:2]", []int{1, 2}},
+		{"[1, 2, 3, 4][2:]", []int{3, 4}},
+		{"[1, 2, 3][:]", []int{1, 2, 3}},
+		{"[1, 2, 3][-5:99]", []int{1, 2, 3}},
+		{"[1, 2, 3][2:1]", []int{}},
+		{"let a = [1, 2, 3]; let b = a[:]; b[0] = 9; a[0]", 1},
+		{`"hello"[1:3]`, "el"},
+		{`"hello"[:2]`, "he"},
+		{`"hello"[3:]`, "lo"},
+		{`"añb😀c"[1:4]`, "ñb😀"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBytes(t *testing.T) {
+	tests := []vmTestCase{
+		{`len(bytes("añb"))`, 4},
+		{`bytes([104, 0, 255])[2]`, 255},
+		{`bytes("abc")[3]`, Null},
+		{`hexEncode(bytes("hello")[1:3])`, "656c"},
+		{`hexEncode(bytes("ab") + bytes([0]))`, "616200"},
+		{`bytes("ab") == bytes([97, 98])`, true},
+		{`bytes("ab") != bytes("a")`, true},
+		{`base64Decode("aGk=").hexEncode()`, "6869"},
+		{`str(hexDecode("6869"))`, "hi"},
+		{`let total = 0; for (x in bytes([1, 2, 3])) { total += x }; total`, 6},
+		{`type(bytes(""))`, "BYTES"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestSets(t *testing.T) {
+	tests := []vmTestCase{
+		{`len(set([1, 2, 2, 3]))`, 3},
+		{`has(set(["a"]), "a")`, true},
+		{`set([1]).has(2)`, false},
+		{`len(union(set([1, 2]), set([2, 3])))`, 3},
+		{`intersect(set([1, 2, 3]), set([3, 2])) == set([2, 3])`, true},
+		{`difference(set([1, 2, 3]), set([2])) == set([3, 1])`, true},
+		{`let total = 0; for (x in set([1, 2, 2])) { total += x }; total`, 3},
+		{`type(set())`, "SET"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestIndexAssignment(t *testing.T) {
+	tests := []vmTestCase{
+		{"let a = [1, 2, 3]; a[0] = 5; a[0]", 5},
+		{"let a = [1, 2, 3]; a[2] = 7", 7},
+		{"let a = [1, 2, 3]; let b = a; b[1] = 9; a", []int{1, 9, 3}},
+		{"let m = [[1, 2], [3, 4]]; m[1][0] = 8; m[1][0]", 8},
+		{"let a = [0]; let f = fn(arr) { arr[0] = 4 }; f(a); a[0]", 4},
+		{"let a = [1, 2]; a[0] += 1; a[0]", 2},
+		{`let h = {}; h["x"] = 1; h["x"]`, 1},
+		{`let h = {"x": 1}; h["x"] = 2; len(keys(h))`, 1},
+		{`let h = {"n": 1}; h["n"] *= 5; h["n"]`, 5},
+		{"let f = fn() { let h = {}; h[1] = 1; h[2] = 2; h[1] + h[2] }; f()", 3},
+		{`let p = {"age": 30}; p.age = 31; p["age"]`, 31},
+		{`let p = {}; p.count = 1; p.count += 2; p.count`, 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("naïve")`, 5},
+		{`puts("hello", "world!")`, Null},
+		{`len([1, 2, 3])`, 3},
+		{`first([])`, Null},
+		{`last([1, 2, 3])`, 3},
+		{`join(split("a,b,c", ","), "-")`, "a-b-c"},
+		{`upper(trim(" hi "))`, "HI"},
+		{`indexOf([1, 2, 3], 2)`, 1},
+		{`contains("abc", "b") == true`, true},
+		{`len(keys({"a": 1, "b": 2}))`, 2},
+		{`join(keys({"z": 1, "y": 2, "x": 3}), "")`, "zyx"},
+		{`values({"a": 1, "b": 2})[1]`, 2},
+		{`entries({"a": 1})[0][0]`, "a"},
+		{`len(keys(delete({"a": 1, "b": 2}, "a")))`, 1},
+		{`format("%s=%d", "n", 3)`, "n=3"},
+		{`print()`, Null},
+		{`printf("")`, Null},
+		{`len(args())`, 0},
+		{`assert(1 < 2, "ordered")`, Null},
+		{`assertEqual([1, {"a": 2}], [1, {"a": 2}])`, Null},
+		{`charCodeAt("añb", 1)`, 241},
+		{`charCodeAt("a", 1)`, Null},
+		{`fromCharCode(104, 105)`, "hi"},
+		{`parseJSON("{\"a\": [1, 2]}")["a"][1]`, 2},
+		{`toJSON({"a": [1, "b"]})`, `{"a":[1,"b"]}`},
+		{`match("(\\d+)", "ab12")["groups"][0]`, "12"},
+		{`len(findAll("o", "foo"))`, 2},
+		{`replaceRegex("o+", "foo", "0")`, "f0"},
+		{`abs(-3) + max(1, 4) + pow(2, 3) + round(2.5)`, 18},
+		{`seedRandom(1); let a = randomInt(1000); seedRandom(1); a == randomInt(1000)`, true},
+		{`formatTime(parseTime("2024-03-01 12:30:00", "DateTime", "UTC"), "RFC3339", "UTC")`, "2024-03-01T12:30:00Z"},
+		{`let t = timestamp(); sleep(5); timestamp() - t >= 5`, true},
+		{`int("4") + int(2.5) + int(float("1.5"))`, 7},
+		{`str(12) + type(1.5) + str(bool(0))`, "12FLOATtrue"},
+		{`type(fn() {})`, "FUNCTION"},
+		{`[1, [2]] == [1, [2]]`, true},
+		{`{"a": 1} != {"a": 1}`, false},
+		{`range(2) == [0, 1]`, true},
+		{`deepEqual([1], [1.0])`, false},
+		{`compare([1, 2], [1, 3])`, -1},
+		{`let a = freeze([1, {"b": 2}]); isFrozen(a[1])`, true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRanges(t *testing.T) {
+	tests := []vmTestCase{
+		{"len(range(10))", 10},
+		{"len(range(10, 0, -3))", 4},
+		{"range(10, 0, -3)[3]", 1},
+		{"range(3)[3]", Null},
+		{"let r = range(2, 5); r[0] + r[2]", 6},
+		{"first(range(5, 0, -2))", 5},
+		{"last(range(0))", Null},
+		{"contains(range(0, 100, 5), 35)", true},
+		{"push(range(2), 9)", []int{0, 1, 9}},
+		{"let add = fn(a, b, c) { a + b + c }; add(...range(1, 4))", 6},
+		{"let count = fn(...xs) { len(xs) }; count(...range(100))", 100},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestMethodCalls(t *testing.T) {
+	tests := []vmTestCase{
+		{`"hello".len()`, 5},
+		{`"a,b".split(",")[1]`, "b"},
+		{`" Hi ".trim().upper()`, "HI"},
+		{`[1, 2].push(3)`, []int{1, 2, 3}},
+		{`["a", "b"].join("-")`, "a-b"},
+		{`range(5).last()`, 4},
+		{`{"a": 1, "b": 2}.keys()[1]`, "b"},
+		{`{"a": 1}.delete("a").len`, Null},
+		{`let h = {"keys": fn() { 42 }}; h.keys()`, 42},
+		{`let up = "abc".upper; up()`, "ABC"},
+		{`let f = fn(xs) { xs.contains(2) }; f([1, 2])`, true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestPipelines(t *testing.T) {
+	tests := []vmTestCase{
+		{`let double = fn(x) { x * 2 }; 3 |> double`, 6},
+		{`let add = fn(a, b) { a + b }; 1 + 2 |> add(10) |> add(100)`, 113},
+		{`"a,b" |> split(",") |> len()`, 2},
+		{`[1, 2] |> push(3)`, []int{1, 2, 3}},
+		{`let adder = fn(n) { fn(x) { x + n } }; 1 |> (2 |> adder)`, 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
+		{"1.5 & 1", "unknown operator: FLOAT & INTEGER"},
+		{"~true", "unknown operator: ~BOOLEAN"},
+		{"1 << -1", "negative shift count: 1 << -1"},
+		{"fn() { 1; }(1);", "wrong number of arguments: want=0, got=1"},
+		{"fn(x, y, ...z) { x; }(1);", "wrong number of arguments: want at least 2, got=1"},
+		{"fn(x) { x; }(...1);", "spread argument must be ARRAY, got INTEGER"},
+		{"fn(x) { x; }(...range(100000000000));", "range too large to make an array: range(0, 100000000000)"},
+		{"range(1, 2, 0)", "range step cannot be zero"},
+		{"for (x in 5) { x }", "not iterable: INTEGER"},
+		{"let a = freeze([1]); a[0] = 2", "cannot assign to frozen ARRAY"},
+		{`let h = freeze({}); h["k"] = 1`, "cannot assign to frozen HASH"},
+		{`"abc".push(1)`, "no method push on STRING"},
+		{"[3, 1].sort()", "no method sort on ARRAY"},
+		{"fn(x, y = 1) { x; }();", "wrong number of arguments: want 1 to 2, got=0"},
+		{"fn(x, y = 1) { x; }(1, 2, 3);", "wrong number of arguments: want 1 to 2, got=3"},
+		{"fn(x = 1 + true) { x; }();", "type mismatch: INTEGER + BOOLEAN"},
+		{"fn(x) { x; }(y: 1);", "unknown argument name: y"},
+		{"fn(x, ...xs) { x; }(xs: 1);", "unknown argument name: xs"},
+		{`bytes("a") - bytes("b")`, "unknown operator: BYTES - BYTES"},
+		{`let b = bytes("a"); b[0] = 1`, "index assignment not supported: BYTES"},
+		{"fn(x) { x; }(1, x: 2);", "duplicate argument: x"},
+		{"fn(x) { x; }(x: 1, x: 2);", "duplicate argument: x"},
+		{"fn(x, y = 1) { x; }(y: 2);", "missing argument: x"},
+		{"len(x: 1);", "named arguments not supported: BUILTIN"},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{"1();", "not a function: INTEGER"},
+		{"1[0]", "index operator not supported: INTEGER"},
+		{"5 / 0", "division by zero: 5 / 0"},
+		{"5 % 0", "division by zero: 5 % 0"},
+		{"2 ** 64 / 0", "division by zero: 18446744073709551616 / 0"},
+		{"2 ** 1000000000000", "integer too large: 2 ** 1000000000000"},
+		{"{[1]: 2}", "unusable as hash key: ARRAY (keys must be INTEGER, BOOLEAN or STRING)"},
+		{"let a = [1]; a[1] = 2", "index out of range: 1, length 1"},
+		{"1[0:1]", "slice operator not supported: INTEGER"},
+		{"[1][true:]", "slice bounds must be INTEGER, got BOOLEAN"},
+		{"let h = {}; h[[1]] = 2", "unusable as hash key: ARRAY (keys must be INTEGER, BOOLEAN or STRING)"},
+		{"let x = 1; x[0] = 2", "index assignment not supported: INTEGER"},
+		{"for (;;) { try { break; } catch (e) { 1 } }; 1 + true", "type mismatch: INTEGER + BOOLEAN"},
+		{"let f = fn() { try { return 1; } catch (e) { 2 } }; f(); 1 + true", "type mismatch: INTEGER + BOOLEAN"},
+		{"try { 1 } catch (e) { 2 }; -true", "unknown operator: -BOOLEAN"},
+		{`assertEqual(1, 2)`, "assertEqual failed: expected 2, got 1"},
+		{`let f = fn() { try { assert(false, "kept") } catch (e) { e } }; f()`, "assertion failed: kept"},
+		{"try { exit(3) } catch (e) { 0 }", "exit(3)"},
+		{`let f = fn() { try { panic("lost") } catch (e) { e } }; f()`, "panic: lost"},
+		{`exit("1")`, "argument to `exit` must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+
+		err := comp.Compile(program)
+
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+
+		err = vm.Run()
+
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none.")
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong VM error: want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestExit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"exit()", 0},
+		{"exit(3); 1 + true", 3},
+		{"let f = fn() { try { exit(255) } catch (e) { 0 } }; f()", 255},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		errObj := ErrorObject(New(comp.Bytecode()).Run())
+
+		if errObj.Exit == nil || errObj.Exit.Code != tt.expected {
+			t.Errorf("%q: expected exit(%d), got=%+v", tt.input, tt.expected, errObj)
+		}
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	tests := []string{
+		"while (true) { }",
+		"while (true) { try { while (true) { } } catch (e) { 1 } }",
+		"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; while (true) { f(500) }",
+		"try { sleep(60000) } catch (e) { 1 }",
+	}
+
+	for _, input := range tests {
+		comp := compiler.New()
+
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+
+		err := New(comp.Bytecode()).RunContext(ctx)
+		cancel()
+
+		if err == nil || err.Error() != "evaluation stopped: context deadline exceeded" {
+			t.Errorf("%q: wrong VM error: got=%v", input, err)
+		}
+	}
+}
+
+func TestSetIO(t *testing.T) {
+	comp := compiler.New()
+
+	if err := comp.Compile(parse(`let p = print; puts(readLine()); p("x"); printf("%d", 2)`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	var out strings.Builder
+
+	machine := New(comp.Bytecode())
+	machine.SetIO(object.NewIO(strings.NewReader("line\n"), &out))
+
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	if got, want := out.String(), "line\nx2"; got != want {
+		t.Errorf("wrong output. want=%q, got=%q", want, got)
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+
+	return p.ParseProgram()
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+
+		err := comp.Compile(program)
+
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+
+		err = vm.Run()
+
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		stackElem := vm.LastPoppedStackElem()
+
+		testExpectedObject(t, tt.expected, stackElem)
+	}
+}
+
+func testExpectedObject(t *testing.T, expected interface{}, actual object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		err := testIntegerObject(int64(expected), actual)
+
+		if err != nil {
+			t.Errorf("testIntegerObject failed: %s", err)
+		}
+
+	case float64:
+		result, ok := actual.(*object.Float)
+
+		if !ok {
+			t.Errorf("object is not Float. got=%T (%+v)", actual, actual)
+		} else if result.Value != expected {
+			t.Errorf("object has wrong value. got=%f, want=%f", result.Value, expected)
+		}
+
+	case *big.Int:
+		result, ok := actual.(*object.BigInt)
+
+		if !ok {
+			t.Errorf("object is not BigInt. got=%T (%+v)", actual, actual)
+		} else if result.Value.Cmp(expected) != 0 {
+			t.Errorf("object has wrong value. got=%s, want=%s", result.Value, expected)
+		}
+
+	case bool:
+		err := testBooleanObject(expected, actual)
+
+		if err != nil {
+			t.Errorf("testBooleanObject failed: %s", err)
+		}
+
+	case string:
+		err := testStringObject(expected, actual)
+
+		if err != nil {
+			t.Errorf("testStringObject failed: %s", err)
+		}
+
+	case []int:
+		array, ok := actual.(*object.Array)
+
+		if !ok {
+			t.Errorf("object not Array: %T (%+v)", actual, actual)
+
+			return
+		}
+
+		if len(array.Elements) != len(expected) {
+			t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+
+			return
+		}
+
+		for i, expectedElem := range expected {
+			err := testIntegerObject(int64(expectedElem), array.Elements[i])
+
+			if err != nil {
+				t.Errorf("testIntegerObject failed: %s", err)
+			}
+		}
+
+	case *object.Null:
+		if actual != Null {
+			t.Errorf("object is not Null: %T (%+v)", actual, actual)
+		}
+	}
+}
+
+func bigInt(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 10)
+
+	return n
+}
+
+func testIntegerObject(expected int64, actual object.Object) error {
+	result, ok := actual.(*object.Integer)
+
+	if !ok {
+		return fmt.Errorf("object is not Integer. got=%T (%+v)", actual, actual)
+	}
+
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+	}
+
+	return nil
+}
+
+func testBooleanObject(expected bool, actual object.Object) error {
+	result, ok := actual.(*object.Boolean)
+
+	if !ok {
+		return fmt.Errorf("object is not Boolean. got=%T (%+v)", actual, actual)
+	}
+
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. got=%t, want=%t", result.Value, expected)
+	}
+
+	return nil
+}
+
+func testStringObject(expected string, actual object.Object) error {
+	result, ok := actual.(*object.String)
+
+	if !ok {
+		return fmt.Errorf("object is not String. got=%T (%+v)", actual, actual)
+	}
+
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. got=%q, want=%q", result.Value, expected)
+	}
+
+	return nil
+}